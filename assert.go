@@ -0,0 +1,144 @@
+package gdbx
+
+import "fmt"
+
+// Internal invariant checks. They report to the hook installed with
+// Env.SetAssertHook and do nothing when no hook is set.
+
+// assertPage checks the header bounds of a page just modified by the cursor
+// and, for pages of the cursor's main tree, that its keys are strictly ascending.
+func (c *Cursor) assertPage(p *page, where string) {
+	hook := c.txn.env.assertHook
+	if hook == nil {
+		return
+	}
+
+	if err := p.validate(uint(c.txn.env.pageSize)); err != nil {
+		hook(false, fmt.Sprintf("%s: page %d: %v", where, p.pageNo(), err))
+		return
+	}
+	hook(true, where+": page bounds")
+
+	// Sub-tree pages hold duplicate values ordered by the dup comparator
+	if !c.onMainTree() || p.isDupfix() {
+		return
+	}
+
+	// Entry 0 of a branch page has no key
+	start := 0
+	if p.isBranch() {
+		start = 1
+	}
+	n := p.numEntries()
+	for i := start + 1; i < n; i++ {
+		prev, cur := nodeGetKeyDirect(p, i-1), nodeGetKeyDirect(p, i)
+		if c.txn.compareKeys(c.dbi, prev, cur) >= 0 {
+			hook(false, fmt.Sprintf("%s: page %d: key %d (%x) not above key %d (%x)", where, p.pageNo(), i, cur, i-1, prev))
+			return
+		}
+	}
+	hook(true, where+": page key order")
+}
+
+// assertSplit checks that every key left on the original page of a split
+// sorts before the first key moved to the new right page.
+func (c *Cursor) assertSplit(left, right *page) {
+	hook := c.txn.env.assertHook
+	if hook == nil {
+		return
+	}
+
+	c.assertPage(left, "split left")
+	c.assertPage(right, "split right")
+	if !c.onMainTree() || left.isDupfix() {
+		return
+	}
+
+	n := left.numEntries()
+	if n == 0 || right.numEntries() == 0 || (left.isBranch() && n == 1) {
+		return
+	}
+	last, first := nodeGetKeyDirect(left, n-1), nodeGetKeyDirect(right, 0)
+	hook(c.txn.compareKeys(c.dbi, last, first) < 0,
+		fmt.Sprintf("split: left page %d last key %x must sort before right page %d first key %x",
+			left.pageNo(), last, right.pageNo(), first))
+}
+
+// assertSubPage checks the inline sub-page of node idx of p, just written by
+// the cursor: its layout (see checkSubPage), that it holds want values, and
+// that they ascend by the dup comparator.
+func (c *Cursor) assertSubPage(p *page, idx, want int, where string) {
+	hook := c.txn.env.assertHook
+	if hook == nil {
+		return
+	}
+
+	count, err := checkSubPage(nodeGetDataDirect(p, idx))
+	if err != nil {
+		hook(false, fmt.Sprintf("%s: page %d entry %d: %v", where, p.pageNo(), idx, err))
+		return
+	}
+	hook(count == want, fmt.Sprintf("%s: page %d entry %d: sub-page holds %d values, want %d", where, p.pageNo(), idx, count, want))
+
+	values, err := c.parseSubPageValues(nodeGetDataDirect(p, idx))
+	if err != nil {
+		hook(false, fmt.Sprintf("%s: page %d entry %d: %v", where, p.pageNo(), idx, err))
+		return
+	}
+	for i := 1; i < len(values); i++ {
+		if c.txn.compareDupValues(c.dbi, values[i-1], values[i]) >= 0 {
+			hook(false, fmt.Sprintf("%s: page %d entry %d: value %d (%x) not above value %d (%x)", where, p.pageNo(), idx, i, values[i], i-1, values[i-1]))
+			return
+		}
+	}
+	hook(true, where+": sub-page value order")
+}
+
+// assertItems checks the tree's Items count after a successful insert or delete.
+func (c *Cursor) assertItems(err error, want uint64, where string) {
+	hook := c.txn.env.assertHook
+	if hook == nil || err != nil {
+		return
+	}
+	hook(c.tree.Items == want, fmt.Sprintf("%s: Items = %d, want %d", where, c.tree.Items, want))
+	if c.tree.isEmpty() {
+		hook(c.tree.Items == 0, fmt.Sprintf("%s: empty tree has Items = %d", where, c.tree.Items))
+	}
+}
+
+// assertHeight checks that the tree's Height equals the number of levels on
+// its leftmost root-to-leaf path, and that an empty tree has Height 0.
+func (c *Cursor) assertHeight(where string) {
+	hook := c.txn.env.assertHook
+	if hook == nil {
+		return
+	}
+
+	levels := 0
+	for pg := c.tree.Root; pg != invalidPgno; levels++ {
+		p, err := c.txn.getPage(pg)
+		if err != nil {
+			hook(false, fmt.Sprintf("%s: page %d: %v", where, pg, err))
+			return
+		}
+		if p.isLeaf() || p.numEntries() == 0 {
+			levels++
+			break
+		}
+		pg = nodeGetChildPgnoFast(p, 0)
+	}
+	hook(int(c.tree.Height) == levels, fmt.Sprintf("%s: Height = %d, tree has %d levels", where, c.tree.Height, levels))
+}
+
+// assertRemovable checks that deleting n items cannot underflow the tree's Items count.
+func (c *Cursor) assertRemovable(n uint64) {
+	if hook := c.txn.env.assertHook; hook != nil {
+		hook(n <= c.tree.Items, fmt.Sprintf("delete: removing %d items from tree with Items = %d", n, c.tree.Items))
+	}
+}
+
+// onMainTree returns true if the cursor operates on its DBI's tree rather
+// than on a DUPSORT sub-tree.
+func (c *Cursor) onMainTree() bool {
+	return int(c.dbi) < len(c.txn.trees) && c.tree == &c.txn.trees[c.dbi]
+}
@@ -0,0 +1,82 @@
+package benchmarks
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// BenchmarkIntegerKeyAppend benchmarks appending 1M sequential uint64 keys with
+// the Append flag. The IntegerKey table takes the cached max-key fast path,
+// the plain table takes the generic Append path.
+func BenchmarkIntegerKeyAppend(b *testing.B) {
+	const numKeys = 1_000_000
+
+	b.Run("Generic/gdbx", func(b *testing.B) {
+		benchSeqAppendGdbx(b, numKeys, 0)
+	})
+	b.Run("IntegerKey/gdbx", func(b *testing.B) {
+		benchSeqAppendGdbx(b, numKeys, gdbx.IntegerKey)
+	})
+}
+
+func benchSeqAppendGdbx(b *testing.B, numKeys int, dbiFlags uint) {
+	dir, err := os.MkdirTemp("", "gdbx-bench-*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer env.Close()
+
+	if err := env.SetGeometry(-1, -1, 4<<30, -1, -1, 4096); err != nil {
+		b.Fatal(err)
+	}
+	if err := env.SetMaxDBs(10); err != nil {
+		b.Fatal(err)
+	}
+	if err := env.Open(filepath.Join(dir, "test.db"), gdbx.NoSubdir|gdbx.NoMetaSync|gdbx.WriteMap, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	key := make([]byte, 8)
+	val := make([]byte, 32)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		dbi, err := txn.OpenDBISimple("append", gdbx.Create|dbiFlags)
+		if err != nil {
+			txn.Abort()
+			b.Fatal(err)
+		}
+		b.StartTimer()
+
+		for k := 0; k < numKeys; k++ {
+			binary.BigEndian.PutUint64(key, uint64(k))
+			binary.BigEndian.PutUint64(val, uint64(k))
+			if err := txn.Put(dbi, key, val, gdbx.Append); err != nil {
+				txn.Abort()
+				b.Fatal(err)
+			}
+		}
+
+		b.StopTimer()
+		txn.Abort()
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*numKeys), "ns/key")
+}
@@ -0,0 +1,86 @@
+package benchmarks
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// BenchmarkClusteredPut puts runs of keys sharing a random prefix into a
+// table of 1M keys, in random order within each run, so consecutive puts
+// mostly land on the same leaf and skip the descent. Random puts, each on a
+// leaf of its own, are the baseline.
+func BenchmarkClusteredPut(b *testing.B) {
+	const (
+		numKeys = 1_000_000
+		run     = 64
+	)
+	b.Run("Clustered", func(b *testing.B) {
+		benchPutPatternGdbx(b, numKeys, run)
+	})
+	b.Run("Random", func(b *testing.B) {
+		benchPutPatternGdbx(b, numKeys, 1)
+	})
+}
+
+// benchPutPatternGdbx loads numKeys random keys, then times puts of new
+// keys in runs of run keys under one random 8-byte prefix.
+func benchPutPatternGdbx(b *testing.B, numKeys, run int) {
+	dir, err := os.MkdirTemp("", "gdbx-bench-*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.SetGeometry(-1, -1, 4<<30, -1, -1, 4096); err != nil {
+		b.Fatal(err)
+	}
+	if err := env.Open(filepath.Join(dir, "test.db"), gdbx.NoSubdir|gdbx.NoMetaSync|gdbx.WriteMap, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	key := make([]byte, 16)
+	val := make([]byte, 32)
+	err = env.Update(func(txn *gdbx.Txn) error {
+		for i := 0; i < numKeys; i++ {
+			binary.BigEndian.PutUint64(key, rng.Uint64())
+			binary.BigEndian.PutUint64(key[8:], rng.Uint64())
+			if err := txn.Put(gdbx.MainDBI, key, val, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer txn.Abort()
+	perm := rng.Perm(run)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%run == 0 {
+			binary.BigEndian.PutUint64(key, rng.Uint64())
+		}
+		binary.BigEndian.PutUint64(key[8:], uint64(perm[i%run])<<32|uint64(i))
+		if err := txn.Put(gdbx.MainDBI, key, val, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -382,3 +382,81 @@ func BenchmarkDupsortSetLastDup_Mdbx(b *testing.B) {
 		mcursor.Get(nil, nil, mdbxgo.LastDup)
 	}
 }
+
+// BenchmarkDupsortFirstLastDup_Gdbx alternates LastDup and FirstDup on one
+// key with a large sub-tree, the case served by the cached edge paths.
+func BenchmarkDupsortFirstLastDup_Gdbx(b *testing.B) {
+	dbPath, cleanup := setupDupsortDB(b, 4, 100000)
+	defer cleanup()
+
+	env, _ := gdbx.NewEnv(gdbx.Default)
+	env.SetMaxDBs(10)
+	env.Open(dbPath, gdbx.NoSubdir|gdbx.ReadOnly, 0644)
+	defer env.Close()
+
+	txn, _ := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	defer txn.Abort()
+	dbi, _ := txn.OpenDBISimple("dupsort", 0)
+	cursor, _ := txn.OpenCursor(dbi)
+	defer cursor.Close()
+
+	key := make([]byte, 32)
+	binary.BigEndian.PutUint64(key[:8], 2)
+	if _, _, err := cursor.Get(key, nil, gdbx.Set); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cursor.Get(nil, nil, gdbx.LastDup)
+		cursor.Get(nil, nil, gdbx.FirstDup)
+	}
+}
+
+// BenchmarkIntegerDupGetBothRange_Gdbx seeks 8-byte values in the sub-trees of
+// an IntegerKey table, with and without DupFixed, the id-index case that the
+// search serves by comparing values as integers.
+func BenchmarkIntegerDupGetBothRange_Gdbx(b *testing.B) {
+	for _, bc := range []struct {
+		name  string
+		flags uint
+	}{
+		{"DupSort", gdbx.DupSort | gdbx.IntegerKey},
+		{"DupFixed", gdbx.DupSort | gdbx.DupFixed | gdbx.IntegerKey | gdbx.IntegerDup},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			dir := b.TempDir()
+			env, _ := gdbx.NewEnv(gdbx.Default)
+			env.SetMaxDBs(10)
+			env.SetGeometry(-1, -1, 4<<30, -1, -1, 4096)
+			env.Open(filepath.Join(dir, "test.db"), gdbx.NoSubdir, 0644)
+			defer env.Close()
+
+			const numKeys, numDupVals = 100, 20000
+			txn, _ := env.BeginTxn(nil, 0)
+			dbi, _ := txn.OpenDBISimple("ids", gdbx.Create|bc.flags)
+			key := make([]byte, 8)
+			val := make([]byte, 8)
+			for i := 0; i < numKeys; i++ {
+				binary.BigEndian.PutUint64(key, uint64(i))
+				for j := 0; j < numDupVals; j++ {
+					binary.BigEndian.PutUint64(val, uint64(j*2))
+					txn.Put(dbi, key, val, 0)
+				}
+			}
+			txn.Commit()
+
+			txn, _ = env.BeginTxn(nil, gdbx.TxnReadOnly)
+			defer txn.Abort()
+			cursor, _ := txn.OpenCursor(dbi)
+			defer cursor.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				binary.BigEndian.PutUint64(key, uint64(i%numKeys))
+				binary.BigEndian.PutUint64(val, uint64(i*7919%(2*numDupVals)))
+				cursor.Get(key, val, gdbx.GetBothRange)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package benchmarks
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// BenchmarkGroupCommit commits small durable write transactions from
+// parallel goroutines, each syncing on its own or sharing syncs through a
+// group commit window. fsyncs/commit reports how many syncs were saved.
+func BenchmarkGroupCommit(b *testing.B) {
+	for _, window := range []time.Duration{0, 200 * time.Microsecond, time.Millisecond} {
+		b.Run("window="+window.String(), func(b *testing.B) {
+			benchGroupCommitGdbx(b, window)
+		})
+	}
+}
+
+func benchGroupCommitGdbx(b *testing.B, window time.Duration) {
+	dir, err := os.MkdirTemp("", "gdbx-bench-*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.Open(filepath.Join(dir, "test.db"), gdbx.NoSubdir, 0644); err != nil {
+		b.Fatal(err)
+	}
+	if err := env.SetGroupCommit(window); err != nil {
+		b.Fatal(err)
+	}
+	info, err := env.Info(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	startFsyncs := info.PageOps.Fsync
+
+	var next atomic.Uint64
+	b.SetParallelism(8)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		key := make([]byte, 8)
+		for pb.Next() {
+			binary.BigEndian.PutUint64(key, next.Add(1))
+			err := env.Update(func(txn *gdbx.Txn) error {
+				return txn.Put(gdbx.MainDBI, key, key, 0)
+			})
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+
+	b.StopTimer()
+	if info, err = env.Info(nil); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(info.PageOps.Fsync-startFsyncs)/float64(b.N), "fsyncs/commit")
+}
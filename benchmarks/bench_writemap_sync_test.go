@@ -0,0 +1,89 @@
+package benchmarks
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// BenchmarkWriteMapSmallUpdates commits a few random small overwrites per
+// durable transaction to a WriteMap database of 64KB pages. sync-B/commit
+// reports the bytes of the map msynced per commit, and copied-B/commit the
+// bytes of the pages copied on write, which a whole-page sync would write.
+func BenchmarkWriteMapSmallUpdates(b *testing.B) {
+	const (
+		pageSize = 64 << 10
+		numKeys  = 200000
+		perTxn   = 4
+	)
+	dir, err := os.MkdirTemp("", "gdbx-bench-*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.SetGeometry(-1, -1, 4<<30, -1, -1, pageSize); err != nil {
+		b.Fatal(err)
+	}
+	if err := env.Open(filepath.Join(dir, "test.db"), gdbx.NoSubdir|gdbx.WriteMap, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	// Filled in random order, leaves are left part empty as they split
+	rng := rand.New(rand.NewSource(1))
+	key := make([]byte, 8)
+	val := make([]byte, 32)
+	err = env.Update(func(txn *gdbx.Txn) error {
+		for _, i := range rng.Perm(numKeys) {
+			binary.BigEndian.PutUint64(key, uint64(i))
+			if err := txn.Put(gdbx.MainDBI, key, val, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	info, err := env.Info(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	startSynced := info.MsyncBytes
+
+	var copied uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := env.Update(func(txn *gdbx.Txn) error {
+			for j := 0; j < perTxn; j++ {
+				binary.BigEndian.PutUint64(key, uint64(rng.Intn(numKeys)))
+				binary.BigEndian.PutUint64(val, uint64(i))
+				if err := txn.Put(gdbx.MainDBI, key, val, 0); err != nil {
+					return err
+				}
+			}
+			copied += txn.Counters().PagesCOW * pageSize
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.StopTimer()
+	if info, err = env.Info(nil); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(float64(info.MsyncBytes-startSynced)/float64(b.N), "sync-B/commit")
+	b.ReportMetric(float64(copied)/float64(b.N), "copied-B/commit")
+}
@@ -96,9 +96,12 @@ func (c *Cursor) Bind(txn *Txn, dbi DBI) error {
 	c.txn = txn
 	c.dbi = dbi
 	c.tree = &txn.trees[dbi]
+	c.safeValues = txn.env.safeValues.Load()
 	c.state = cursorUninitialized
+	c.atEnd = false
 	c.top = -1
 	c.dirtyMask = 0
+	c.dup.edges[0].txnid, c.dup.edges[1].txnid = 0, 0
 
 	// Register cursor with transaction
 	txn.cursors = append(txn.cursors, c)
@@ -346,7 +349,7 @@ type LoggerFunc func(msg string, args ...any)
 // global logger settings
 var (
 	globalLogLevel LogLvl     = LogLvlDoNotChange
-	globalLogger   LoggerFunc = nil //nolint:unused // Part of mdbx-go compat API
+	globalLogger   LoggerFunc = nil
 	globalDebug    uint       = 0
 )
 
@@ -413,6 +416,9 @@ func (c *Cursor) PutReserve(key []byte, n int, flags uint) ([]byte, error) {
 		return nil, NewError(ErrPermissionDenied)
 	}
 
+	if err := checkDataSize(n); err != nil {
+		return nil, err
+	}
 	// Allocate and store empty value
 	value := make([]byte, n)
 	if err := c.Put(key, value, flags); err != nil {
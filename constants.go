@@ -44,7 +44,12 @@ const (
 	// MaxDBI is the maximum number of named databases
 	MaxDBI = 32765
 
-	// MaxDataSize is the maximum size of a data item
+	// MaxDataSize is the maximum size of a data item, as in libmdbx. Node
+	// headers hold a value's size in 32 bits and the format has no way to
+	// chain overflow runs, so larger values, those of 4GB and up included,
+	// fail with ErrBadValSize before anything is written or allocated for
+	// them. Blobs that may exceed it must be split across keys: a wider
+	// encoding would make the file unreadable by libmdbx.
 	MaxDataSize = 0x7fff0000
 
 	// MaxPageNo is the maximum page number
@@ -144,7 +149,9 @@ const (
 	// EnvDefaults is the default (durable) mode
 	EnvDefaults uint = 0
 
-	// Validation enables extra validation of DB structure
+	// Validation makes Open run Env.Verify, a check of every page of the
+	// database that takes as long as reading the file, and fail with
+	// ErrCorrupted, closing the files again, if it finds an inconsistency
 	Validation uint = 0x00002000
 
 	// NoSubdir means the path is a filename, not a directory
@@ -153,7 +160,9 @@ const (
 	// ReadOnly opens the environment in read-only mode
 	ReadOnly uint = 0x00020000
 
-	// Exclusive opens in exclusive/monopolistic mode
+	// Exclusive opens in exclusive/monopolistic mode: Open fails with
+	// ErrBusy while any other Env, in this process or another, has the data
+	// file open, and other opens fail while this one holds it
 	Exclusive uint = 0x00400000
 
 	// Accede uses existing mode if opened by other processes
@@ -180,9 +189,23 @@ const (
 	// NoMetaSync skips meta page sync after commit
 	NoMetaSync uint = 0x00040000
 
-	// SafeNoSync skips sync but keeps steady commits
+	// SafeNoSync skips the sync on commit. The commits are signed weak and
+	// never overwrite the last steady (synced) meta, which Open falls back to
+	// if the system restarted before they were synced
 	SafeNoSync uint = 0x00010000
 
+	// RedoLog makes commits durable by syncing a redo log next to the data
+	// file instead of the data file itself, which is synced at checkpoints;
+	// Open replays the log after a crash (gdbx extension). Every writer of
+	// the environment must set it, and it can't be combined with WriteMap
+	RedoLog uint = 0x20000000
+
+	// NoLock opens a ReadOnly environment without its lock file, for data
+	// files on read-only media: no lock file is created or read and the data
+	// file is not locked, so nothing coordinates with other openers, and no
+	// process may write the file meanwhile (gdbx extension)
+	NoLock uint = 0x10000000
+
 	// UtterlyNoSync skips all syncs (dangerous)
 	UtterlyNoSync = SafeNoSync | NoMetaSync
 
@@ -239,10 +262,16 @@ const (
 	// DupSort allows multiple values per key (sorted)
 	DupSort uint = 0x04
 
-	// IntegerKey uses uint32/uint64 keys in native byte order
+	// IntegerKey uses uint32/uint64 keys in native byte order. All keys of a
+	// table are 4 or 8 bytes, as wide as its first key; others fail with
+	// ErrBadKeySize. gdbx orders them bytewise, the numeric order of
+	// big-endian keys, where libmdbx compares them as native integers; open
+	// a table libmdbx filled with DBISpec.NativeIntegers to order them as it
+	// does. IntegerDup values are ordered alike
 	IntegerKey uint = 0x08
 
-	// DupFixed uses fixed-size values in DUPSORT tables
+	// DupFixed uses fixed-size values in DUPSORT tables. All values of a
+	// table are as wide as its first value; others fail with ErrBadValSize
 	DupFixed uint = 0x10
 
 	// IntegerDup uses fixed-size integer values in DUPSORT
@@ -254,6 +283,11 @@ const (
 	// Create creates the database if it doesn't exist
 	Create uint = 0x40000
 
+	// Purge empties an existing database when it is opened, like Drop with
+	// del=false. Together with Create it always yields an empty database,
+	// whereas Create alone keeps existing data. Requires a write transaction.
+	Purge uint = 0x80000
+
 	// DBAccede opens with unknown flags
 	DBAccede uint = 0x40000000
 )
@@ -263,12 +297,19 @@ const (
 	// Upsert is the default insert-or-update mode
 	Upsert uint = 0
 
-	// NoOverwrite returns error if key exists
+	// NoOverwrite returns error if key exists; on DUPSORT tables, like
+	// NoDupData, only if the key-value pair exists
 	NoOverwrite uint = 0x10
 
 	// NoDupData returns error if key-value pair exists (DUPSORT)
 	NoDupData uint = 0x20
 
+	// NoOverwriteKey returns error if the key exists with any value. On
+	// DUPSORT tables, where NoOverwrite and NoDupData only refuse a pair
+	// already present, it adds a value only to a key that has none (gdbx
+	// extension)
+	NoOverwriteKey uint = 0x100000
+
 	// Current overwrites current item (cursor put)
 	Current uint = 0x40
 
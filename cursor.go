@@ -1,9 +1,9 @@
 package gdbx
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
+	"iter"
 	"unsafe"
 )
 
@@ -11,11 +11,11 @@ import (
 const (
 	// First positions at the first key
 	First uint = iota
-	// FirstDup positions at the first duplicate of current key
+	// FirstDup positions at the first duplicate of current key (DUPSORT only)
 	FirstDup
-	// GetBoth positions at exact key-value pair
+	// GetBoth positions at exact key-value pair (requires a value)
 	GetBoth
-	// GetBothRange positions at key with value >= specified
+	// GetBothRange positions at key with value >= specified (requires a value)
 	GetBothRange
 	// GetCurrent returns current key-value
 	GetCurrent
@@ -23,11 +23,11 @@ const (
 	GetMultiple
 	// Last positions at the last key
 	Last
-	// LastDup positions at the last duplicate of current key
+	// LastDup positions at the last duplicate of current key (DUPSORT only)
 	LastDup
 	// Next moves to the next key-value
 	Next
-	// NextDup moves to the next duplicate of current key
+	// NextDup moves to the next duplicate of current key (DUPSORT only)
 	NextDup
 	// NextMultiple returns next multiple values (DUPFIXED)
 	NextMultiple
@@ -35,7 +35,7 @@ const (
 	NextNoDup
 	// Prev moves to the previous key-value
 	Prev
-	// PrevDup moves to the previous duplicate of current key
+	// PrevDup moves to the previous duplicate of current key (DUPSORT only)
 	PrevDup
 	// PrevNoDup moves to the last value of previous key
 	PrevNoDup
@@ -90,6 +90,22 @@ type dupState struct {
 	dupfixSize       int      // Size of each value for DUPFIX
 	nodePositions    []int    // Node positions (from entry pointers + PAGEHDRSZ)
 	nodePositionsBuf [256]int // Pre-allocated buffer for nodePositions (covers most cases)
+	writes           uint64   // txn.pageWrites when subPageData was loaded
+
+	// Leftmost and rightmost paths of the sub-tree last positioned by
+	// FirstDup and LastDup, so repeating them on the same key skips the descent
+	edges [2]subTreeEdge
+}
+
+// subTreeEdge is a cached path from a sub-tree's root to its first (edges[0])
+// or last (edges[1]) value. Only read-only transactions use it: their pages
+// can't change, so the sub-tree root and snapshot identify the path.
+type subTreeEdge struct {
+	txnid   txnid // Snapshot the path was taken in, 0 if unset
+	subTree tree
+	top     int8
+	pages   [CursorStackSize]page
+	indices [CursorStackSize]uint16
 }
 
 // Cursor provides navigation through a database.
@@ -108,6 +124,8 @@ type Cursor struct {
 	readOnly    bool   // True if transaction is read-only
 	isDupSort   bool   // True if this is a DUPSORT database (cached for fast path)
 	afterDelete bool   // True after Del() - next move returns current position
+	atEnd       bool   // True after Next ran off the end - still on the last entry, but it isn't current
+	dupValues   bool   // True for a cursor over a DupSort sub-tree, whose keys are duplicate values
 	dirtyMask   uint32 // Bitmask of which stack levels have dirty pages
 
 	// Page stack for tree traversal - pages points to pagesBuf to avoid allocation
@@ -125,6 +143,22 @@ type Cursor struct {
 	// Linked list in transaction
 	next *Cursor
 
+	// ID in the environment's op log (0 = not logged)
+	logID uint64
+
+	// Key range set by SetBounds (bounded is false when both sides are open)
+	boundLow, boundHigh []byte
+	bounded             bool
+
+	// Get returns copies of keys and values (see Env.SetSafeValues)
+	safeValues bool
+
+	// Value capacity to reserve on overflow pages for the current put (see Txn.PutWithCap)
+	overflowCap int
+
+	// Error that ended the last IterateKeys iteration (see Err)
+	iterErr error
+
 	// Scratch buffers for building nodes (avoids allocation)
 	nodeBuf    [512]byte  // For leaf nodes
 	branchBuf  [128]byte  // For branch nodes (smaller, just key + 8 byte header)
@@ -211,6 +245,9 @@ func (c *Cursor) Close() {
 	// Remove from transaction's cursor list
 	txn := c.txn
 	if txn != nil {
+		if c.logID != 0 && txn.logOps {
+			txn.env.opLog.start(opCursorClose).uint(c.logID).end(nil)
+		}
 		txn.removeCursor(c)
 	}
 
@@ -229,10 +266,34 @@ func (c *Cursor) UserCtx() any {
 }
 
 // Get retrieves key-value at the cursor position based on operation.
+// FirstDup, LastDup, NextDup, PrevDup, GetBoth and GetBothRange fail with
+// ErrIncompatible on tables without DupSort, as in libmdbx, and GetBoth and
+// GetBothRange also without a value.
 func (c *Cursor) Get(key, value []byte, op CursorOp) ([]byte, []byte, error) {
 	if !c.valid() {
 		return nil, nil, ErrBadCursorError
 	}
+	if err := c.checkOp(value, op); err != nil {
+		return nil, nil, err
+	}
+
+	if c.logID != 0 {
+		return c.getLogged(key, value, op)
+	}
+	if c.safeValues {
+		return c.getCopied(key, value, op)
+	}
+	if c.bounded {
+		return c.getBounded(key, value, op)
+	}
+	if c.atEnd {
+		switch op {
+		case GetCurrent, FirstDup, LastDup, NextDup, PrevDup:
+			// Past the end there is no current key
+			return nil, nil, ErrNotFoundError
+		}
+		c.atEnd = false
+	}
 
 	switch op {
 	case First:
@@ -240,7 +301,7 @@ func (c *Cursor) Get(key, value []byte, op CursorOp) ([]byte, []byte, error) {
 	case Last:
 		return c.last()
 	case Next:
-		return c.moveNext()
+		return c.pastEnd(c.moveNext())
 	case Prev:
 		return c.movePrev()
 	case GetCurrent:
@@ -250,7 +311,7 @@ func (c *Cursor) Get(key, value []byte, op CursorOp) ([]byte, []byte, error) {
 	case SetKey:
 		return c.setKey(key)
 	case SetRange:
-		return c.setRange(key)
+		return c.pastEnd(c.setRange(key))
 	case FirstDup:
 		return c.firstDup()
 	case LastDup:
@@ -268,14 +329,48 @@ func (c *Cursor) Get(key, value []byte, op CursorOp) ([]byte, []byte, error) {
 	case GetBothRange:
 		return c.getBothRange(key, value)
 	case SetLowerbound:
-		return c.setLowerbound(key, value)
+		return c.pastEnd(c.setLowerbound(key, value))
 	case SetUpperbound:
-		return c.setUpperbound(key, value)
+		return c.pastEnd(c.setUpperbound(key, value))
 	default:
 		return nil, nil, NewError(ErrInvalid)
 	}
 }
 
+// pastEnd marks the cursor atEnd when a forward move or search found no
+// entry: moveNext leaves the cursor on the last entry when it runs off the end.
+func (c *Cursor) pastEnd(k, v []byte, err error) ([]byte, []byte, error) {
+	c.atEnd = IsNotFound(err) && c.state == cursorPointing
+	return k, v, err
+}
+
+// checkOp rejects operations that don't apply to the cursor's table.
+func (c *Cursor) checkOp(value []byte, op CursorOp) error {
+	switch op {
+	case FirstDup, LastDup, NextDup, PrevDup:
+		if c.tree.Flags&uint16(DupSort) == 0 {
+			return NewError(ErrIncompatible)
+		}
+	case GetBoth, GetBothRange:
+		if value == nil || c.tree.Flags&uint16(DupSort) == 0 {
+			return NewError(ErrIncompatible)
+		}
+	}
+	return nil
+}
+
+// getLogged runs Get with logging suspended and records the call.
+func (c *Cursor) getLogged(key, value []byte, op CursorOp) ([]byte, []byte, error) {
+	id := c.logID
+	c.logID = 0
+	k, v, err := c.Get(key, value, op)
+	c.logID = id
+	if c.txn.logOps {
+		c.txn.env.opLog.start(opCursorGet).uint(id).uint(uint64(op)).bytes(key).bytes(value).end(err)
+	}
+	return k, v, err
+}
+
 // Put stores a key-value pair at the cursor position.
 func (c *Cursor) Put(key, value []byte, flags uint) error {
 	if !c.valid() {
@@ -286,7 +381,22 @@ func (c *Cursor) Put(key, value []byte, flags uint) error {
 		return NewError(ErrPermissionDenied)
 	}
 
-	return c.put(key, value, flags)
+	err := c.txn.checkAppendGuard(c.dbi, key)
+	if err == nil {
+		err = c.txn.beginWrite(c.writeReserve(len(value)))
+		if err == nil {
+			err = c.put(key, value, flags)
+			c.txn.endWrite(err)
+		}
+	}
+	if err == nil {
+		c.atEnd = false
+		c.txn.advanceAppendGuard(c.dbi, key)
+	}
+	if c.logID != 0 && c.txn.logOps {
+		c.txn.env.opLog.start(opCursorPut).uint(c.logID).uint(uint64(flags)).bytes(key).bytes(value).end(err)
+	}
+	return err
 }
 
 // Del deletes the current key-value pair.
@@ -299,20 +409,27 @@ func (c *Cursor) Del(flags uint) error {
 		return NewError(ErrPermissionDenied)
 	}
 
-	if c.state != cursorPointing {
-		return ErrNotFoundError
+	err := c.txn.beginWrite(c.writeReserve(0))
+	if err == nil && (c.state != cursorPointing || c.atEnd) {
+		err = ErrNotFoundError
+	} else if err == nil {
+		err = c.del(flags)
+		c.txn.endWrite(err)
 	}
-
-	return c.del(flags)
+	if c.logID != 0 && c.txn.logOps {
+		c.txn.env.opLog.start(opCursorDel).uint(c.logID).uint(uint64(flags)).end(err)
+	}
+	return err
 }
 
-// Count returns the number of values for the current key.
+// Count returns the number of values for the current key, which is always
+// 1 on tables without DupSort.
 func (c *Cursor) Count() (uint64, error) {
 	if !c.valid() {
 		return 0, ErrBadCursorError
 	}
 
-	if c.state != cursorPointing {
+	if c.state != cursorPointing || c.atEnd {
 		return 0, ErrNotFoundError
 	}
 
@@ -324,6 +441,102 @@ func (c *Cursor) Count() (uint64, error) {
 	return c.countDuplicates()
 }
 
+// SetWithCount positions at key like Get with Set and also returns the
+// number of values stored under it. On DUPSORT tables the count comes from
+// the sub-tree or sub-page loaded for the first value, so it costs no
+// second lookup; other tables always report 1.
+func (c *Cursor) SetWithCount(key []byte) ([]byte, uint64, error) {
+	_, v, err := c.Get(key, nil, Set)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Set leaves the dup state initialized at the first value, unless the
+	// key holds a single value
+	switch {
+	case c.tree.Flags&uint16(DupSort) == 0 || !c.dup.initialized:
+		return v, 1, nil
+	case c.dup.isSubTree:
+		return v, c.dup.subTree.Items, nil
+	default:
+		return v, uint64(c.dup.subPageNum), nil
+	}
+}
+
+// IterateKeys returns an iterator over the table's distinct keys, from the
+// first to the last. It moves with NextNoDup, so a DupSort key is visited
+// once, without stepping through its values. Each key stays valid as a key
+// returned by Get does. The cursor is left on the last key yielded; Err
+// reports the error that ended the iteration, if it wasn't the end of the
+// table.
+func (c *Cursor) IterateKeys() iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		c.iterErr = nil
+		k, _, err := c.Get(nil, nil, First)
+		for ; err == nil; k, _, err = c.Get(nil, nil, NextNoDup) {
+			if !yield(k) {
+				return
+			}
+		}
+		if !IsNotFound(err) {
+			c.iterErr = err
+		}
+	}
+}
+
+// Err returns the error that ended the last IterateKeys iteration, or nil if
+// it ran to the end of the table or the caller stopped it.
+func (c *Cursor) Err() error {
+	return c.iterErr
+}
+
+// AtEnd reports whether the cursor stands past the data, with no current
+// entry. That is the case after Next, SetRange, SetLowerbound or
+// SetUpperbound runs off the end of the table, and after any operation that
+// leaves the cursor at EOF, such as NextNoDup or Prev running off either end.
+// GetCurrent, the Dup operations, Count and Del then return ErrNotFound and
+// Next keeps returning it. Prev still works: after running off the end it
+// moves to the entry before the last one, as in libmdbx, and from EOF it
+// positions at the last entry. Any other operation that finds an entry, and a
+// successful Put, clear it.
+func (c *Cursor) AtEnd() bool {
+	return c.atEnd || c.state == cursorEOF
+}
+
+// NodeInfo describes how the entry at a cursor is stored.
+type NodeInfo struct {
+	Flags    NodeFlags // NodeBig, NodeDup, NodeTree, or 0 for an inline value
+	DataSize uint32    // Size of the node's data: the value, or the sub-page or sub-tree header of a DupSort key
+	Count    uint64    // Number of values under the key
+}
+
+// CurrentNodeInfo reports how the entry at the cursor is stored: inline, on
+// overflow pages, as a duplicate sub-page, or as a duplicate sub-tree. It
+// reads the leaf node only and doesn't fetch big values.
+func (c *Cursor) CurrentNodeInfo() (NodeInfo, error) {
+	if !c.valid() {
+		return NodeInfo{}, ErrBadCursorError
+	}
+	if c.state != cursorPointing {
+		return NodeInfo{}, ErrNotFoundError
+	}
+
+	p := c.pages[c.top]
+	idx := int(c.indices[c.top])
+	info := NodeInfo{
+		Flags:    NodeFlags(nodeGetFlagsDirect(p, idx) & (nodeBig | nodeTree | nodeDup)),
+		DataSize: nodeGetDataSizeDirect(p, idx),
+		Count:    1,
+	}
+	if info.Flags&(NodeDup|NodeTree) != 0 {
+		n, err := c.countDuplicates()
+		if err != nil {
+			return NodeInfo{}, err
+		}
+		info.Count = n
+	}
+	return info, nil
+}
+
 // EOF returns true if the cursor is at end-of-file.
 func (c *Cursor) EOF() bool {
 	return c.state == cursorEOF
@@ -629,6 +842,9 @@ func (c *Cursor) reset() {
 	c.afterDelete = false
 	c.dirtyMask = 0
 	c.clearDupState()
+	// Position flags of the previous key must not steer NextDup/PrevDup on the new one
+	c.dup.atFirst = false
+	c.dup.atLast = false
 }
 
 // first positions at the first key.
@@ -774,9 +990,14 @@ func (c *Cursor) moveNext() ([]byte, []byte, error) {
 
 	// Reached the end - restore cursor to last valid position
 	// This matches libmdbx behavior: cursor stays at last entry when Next returns NOTFOUND
-	// Keep state as cursorPointing so Prev() works correctly from this position
+	// Keep state as cursorPointing so Prev() works correctly from this position;
+	// Get marks the cursor atEnd so that GetCurrent reports no entry
 	c.top = savedTop
 	c.indices = savedIndices
+	if c.isDupSort {
+		// The dup state was cleared above; Prev resumes from the key's last value
+		c.dup.atLast = true
+	}
 	// Don't change state - cursor is still pointing at a valid entry
 	return nil, nil, ErrNotFoundError
 }
@@ -885,6 +1106,9 @@ func (c *Cursor) getCurrent() ([]byte, []byte, error) {
 
 	// Refresh page in case another cursor modified it
 	c.refreshPage()
+	if err := c.syncDupSubPage(); err != nil {
+		return nil, nil, err
+	}
 
 	p := c.pages[c.top]
 	idx := int(c.indices[c.top])
@@ -1008,6 +1232,7 @@ func (c *Cursor) initDupSubPage(subPageData []byte) error {
 
 	c.dup.isSubTree = false
 	c.dup.subPageData = subPageData
+	c.dup.writes = c.txn.pageWrites
 	c.dup.subPageIdx = 0
 
 	// Parse sub-page header using unsafe for speed
@@ -1020,7 +1245,7 @@ func (c *Cursor) initDupSubPage(subPageData []byte) error {
 	if (flags&uint16(pageDupfix) != 0) && dupfixKsize > 0 && dupfixKsize < 65535 {
 		// DUPFIX: fixed-size values after 20-byte header
 		c.dup.dupfixSize = dupfixKsize
-		c.dup.subPageNum = (len(subPageData) - pageHeaderSize) / dupfixKsize
+		c.dup.subPageNum = subPageDupfixCount(subPageData, dupfixKsize)
 		c.dup.nodePositions = nil
 	} else if lower > 0 && lower < len(subPageData) {
 		// Variable-size: lower = numEntries * 2
@@ -1112,6 +1337,7 @@ func (c *Cursor) initDupSubPageLast(subPageData []byte) error {
 
 	c.dup.isSubTree = false
 	c.dup.subPageData = subPageData
+	c.dup.writes = c.txn.pageWrites
 
 	// Parse sub-page header using unsafe for speed
 	ptr := unsafe.Pointer(&subPageData[0])
@@ -1123,7 +1349,7 @@ func (c *Cursor) initDupSubPageLast(subPageData []byte) error {
 	if (flags&uint16(pageDupfix) != 0) && dupfixKsize > 0 && dupfixKsize < 65535 {
 		// DUPFIX: fixed-size values after 20-byte header
 		c.dup.dupfixSize = dupfixKsize
-		c.dup.subPageNum = (len(subPageData) - pageHeaderSize) / dupfixKsize
+		c.dup.subPageNum = subPageDupfixCount(subPageData, dupfixKsize)
 		c.dup.nodePositions = nil
 		// Position at last entry
 		c.dup.subPageIdx = c.dup.subPageNum - 1
@@ -1185,7 +1411,7 @@ func (c *Cursor) getDupValue() ([]byte, error) {
 
 		// Use fast path - bounds already verified during initialization
 		// In sub-trees, the key IS the duplicate value
-		return nodeGetKeyFast(subPage, subIdx), nil
+		return subTreeKey(subPage, subIdx), nil
 	}
 
 	// Get value from inline sub-page
@@ -1261,6 +1487,10 @@ func (c *Cursor) getFirstSubTreeValue(treeData []byte) ([]byte, error) {
 		}
 
 		// Get first key from leaf - for DUPSORT sub-trees, the "key" is the value
+		if pageFlags(*(*uint16)(unsafe.Add(pagePtr, 10)))&pageDupfix != 0 {
+			ksize := int(*(*uint16)(unsafe.Add(pagePtr, 8)))
+			return unsafe.Slice((*byte)(unsafe.Add(pagePtr, pageHeaderSize)), ksize), nil
+		}
 		storedOffset := *(*uint16)(unsafe.Add(pagePtr, 20))
 		nodeOffset := uintptr(storedOffset + 20)
 		keySize := int(*(*uint16)(unsafe.Add(pagePtr, nodeOffset+6)))
@@ -1286,6 +1516,10 @@ func (c *Cursor) getFirstSubTreeValue(treeData []byte) ([]byte, error) {
 	}
 
 	// Get first key from leaf - for DUPSORT sub-trees, the "key" is the value
+	if pageFlags(*(*uint16)(unsafe.Add(pagePtr, 10)))&pageDupfix != 0 {
+		ksize := int(*(*uint16)(unsafe.Add(pagePtr, 8)))
+		return unsafe.Slice((*byte)(unsafe.Add(pagePtr, pageHeaderSize)), ksize), nil
+	}
 	storedOffset := *(*uint16)(unsafe.Add(pagePtr, 20))
 	nodeOffset := uintptr(storedOffset + 20)
 	// Key size is at node+6 (2 bytes)
@@ -1353,13 +1587,43 @@ func (c *Cursor) set(key []byte) ([]byte, []byte, error) {
 	return c.search(key, false)
 }
 
+// seekForward positions at the exact key and returns its value. When the key
+// falls within the current leaf page it binary-searches that page instead of
+// descending from the root, so callers visiting keys in ascending order
+// (Txn.MultiGet) amortize descents across neighbouring keys.
+func (c *Cursor) seekForward(key []byte) ([]byte, error) {
+	if c.state == cursorPointing && c.top >= 0 {
+		p := c.pages[c.top]
+		n := p.numEntriesFast()
+		// Keys arrive in ascending order, so key >= the previous key on this page;
+		// if it is also <= the page's last key, it can only be on this page.
+		if n > 0 && p.isLeafFast() && c.txn.compareKeys(c.dbi, key, nodeGetKeyDirect(p, n-1)) <= 0 {
+			idx := c.searchPage(p, key)
+			c.clearDupState()
+			c.indices[c.top] = uint16(idx)
+			if c.txn.compareKeys(c.dbi, key, nodeGetKeyDirect(p, idx)) != 0 {
+				return nil, ErrNotFoundError
+			}
+			_, v, err := c.getCurrent()
+			return v, err
+		}
+	}
+
+	_, v, err := c.set(key)
+	return v, err
+}
+
 // setKey positions at the key, returning both key and value.
 func (c *Cursor) setKey(key []byte) ([]byte, []byte, error) {
 	return c.search(key, false)
 }
 
 // setRange positions at the first key >= specified.
+// On DUPSORT tables it lands on the key's first duplicate with the dup state
+// initialized, so Count and NextDup work without a FirstDup in between.
 func (c *Cursor) setRange(key []byte) ([]byte, []byte, error) {
+	// search resets the dup state and getCurrent initializes it at the first
+	// duplicate of the key it lands on
 	return c.search(key, true)
 }
 
@@ -1438,9 +1702,13 @@ func (c *Cursor) searchPage(p *page, key []byte) int {
 	if n == 0 {
 		return 0
 	}
+	if pageFlagsDirect(p.Data)&pageDupfix != 0 {
+		return c.searchDupfixPage(p, key, n)
+	}
 
 	// Use assembly-optimized path for default comparator (most common case)
-	if c.txn.dbiUsesDefaultCmp[c.dbi] {
+	compare, isDefault := c.keyComparator()
+	if isDefault {
 		return c.searchPageAsm(p, key, n)
 	}
 
@@ -1454,7 +1722,7 @@ func (c *Cursor) searchPage(p *page, key []byte) int {
 
 		// Fast path for append-only workloads: check last entry first
 		lastKey := nodeGetKeyFast(p, n-1)
-		cmp := c.txn.compareKeys(c.dbi, key, lastKey)
+		cmp := compare(key, lastKey)
 		if cmp > 0 {
 			return n - 1 // Key is greater than all separators, use rightmost child
 		}
@@ -1467,7 +1735,7 @@ func (c *Cursor) searchPage(p *page, key []byte) int {
 		for low <= high {
 			mid := (low + high) / 2
 			nodeKey := nodeGetKeyFast(p, mid)
-			cmp = c.txn.compareKeys(c.dbi, key, nodeKey)
+			cmp = compare(key, nodeKey)
 
 			if cmp < 0 {
 				high = mid - 1
@@ -1486,7 +1754,7 @@ func (c *Cursor) searchPage(p *page, key []byte) int {
 
 	// Leaf page: fast path for append-only workloads
 	lastKey := nodeGetKeyFast(p, n-1)
-	cmp := c.txn.compareKeys(c.dbi, key, lastKey)
+	cmp := compare(key, lastKey)
 	if cmp > 0 {
 		return n // Insert after last
 	}
@@ -1499,7 +1767,7 @@ func (c *Cursor) searchPage(p *page, key []byte) int {
 	for low <= high {
 		mid := (low + high) / 2
 		nodeKey := nodeGetKeyFast(p, mid)
-		cmp = c.txn.compareKeys(c.dbi, key, nodeKey)
+		cmp = compare(key, nodeKey)
 
 		if cmp < 0 {
 			high = mid - 1
@@ -1513,6 +1781,94 @@ func (c *Cursor) searchPage(p *page, key []byte) int {
 	return low
 }
 
+// searchDupfixPage does binary search within a DUPFIX leaf page.
+func (c *Cursor) searchDupfixPage(p *page, key []byte, n int) int {
+	compare, isDefault := c.keyComparator()
+	if isDefault && len(key) == 8 && pageDupfixSizeDirect(p.Data) == 8 {
+		return searchDupfix8(p.Data, binary.BigEndian.Uint64(key), n)
+	}
+	low, high := 0, n-1
+	for low <= high {
+		mid := (low + high) / 2
+		cmp := compare(key, p.dupfixKey(mid))
+		if cmp < 0 {
+			high = mid - 1
+		} else if cmp > 0 {
+			low = mid + 1
+		} else {
+			return mid
+		}
+	}
+	return low
+}
+
+// searchDupfix8 does binary search within a DUPFIX page of 8-byte keys
+// ordered bytewise, comparing them as big-endian integers. It returns the
+// index of key or of the first key above it.
+func searchDupfix8(data []byte, key uint64, n int) int {
+	keys := data[pageHeaderSize : pageHeaderSize+n*8]
+	low, high := 0, n-1
+	for low <= high {
+		mid := (low + high) / 2
+		k := binary.BigEndian.Uint64(keys[mid*8:])
+		if key < k {
+			high = mid - 1
+		} else if key > k {
+			low = mid + 1
+		} else {
+			return mid
+		}
+	}
+	return low
+}
+
+// searchPage4 does binary search on a page of 4-byte IntegerKey keys,
+// comparing them as big-endian integers, which orders them like
+// bytes.Compare. Like binarySearchLeaf8 and binarySearchBranch8 it returns
+// the index of key or of the first key above it on a leaf page, the child to
+// follow on a branch page, and -1 if a key on the page isn't 4 bytes long.
+func searchPage4(p *page, key uint32, n int) int {
+	// On branch pages, entry 0 has no key (it's the leftmost child pointer)
+	isBranch := p.isBranchFast()
+	low, high := 0, n-1
+	if isBranch {
+		if n == 1 {
+			return 0
+		}
+		low = 1
+	}
+	for low <= high {
+		mid := (low + high) / 2
+		k := nodeGetKeyFast(p, mid)
+		if len(k) != 4 {
+			return -1
+		}
+		v := binary.BigEndian.Uint32(k)
+		if key < v {
+			high = mid - 1
+		} else if key > v {
+			low = mid + 1
+		} else {
+			return mid
+		}
+	}
+	if isBranch {
+		return low - 1
+	}
+	return low
+}
+
+// keyComparator returns the comparator for the keys of the cursor's tree and
+// whether it is bytes.Compare. The keys of a DupSort sub-tree are duplicate
+// values, so sub-tree cursors use the dup comparator.
+func (c *Cursor) keyComparator() (func(a, b []byte) int, bool) {
+	if c.dupValues {
+		c.txn.initDupComparator(c.dbi)
+		return c.txn.dbiDupComparators[c.dbi], c.txn.dbiUsesDefaultDupCmp[c.dbi]
+	}
+	return c.txn.dbiComparators[c.dbi], c.txn.dbiUsesDefaultCmp[c.dbi]
+}
+
 // searchPageAsm is the assembly-optimized version of searchPage for default comparator.
 // Uses specialized assembly for 8-byte keys (common case) or generic N-byte assembly for others.
 func (c *Cursor) searchPageAsm(p *page, key []byte, n int) int {
@@ -1537,6 +1893,13 @@ func (c *Cursor) searchPageAsm(p *page, key []byte, n int) int {
 		}
 	}
 
+	// uint32 keys of IntegerKey tables get a search of their own
+	if keyLen == 4 && c.tree.Flags&treeFlagIntegerKey != 0 {
+		if result := searchPage4(p, binary.BigEndian.Uint32(key), n); result >= 0 {
+			return result
+		}
+	}
+
 	// For keys > 8 bytes, use generic N-byte assembly with SSE2
 	if keyLen > 8 {
 		if p.isBranchFast() {
@@ -1676,6 +2039,7 @@ func (c *Cursor) descendRight() ([]byte, []byte, error) {
 			c.pages[c.top] = childPage
 			lastIdx := uint16(childPage.numEntriesFast() - 1)
 			c.indices[c.top] = lastIdx
+			c.numExpected[c.top] = uint16(childPage.numEntriesFast())
 		}
 	}
 
@@ -1720,8 +2084,11 @@ func (c *Cursor) firstDup() ([]byte, []byte, error) {
 	if c.tree != nil && c.tree.Flags&uint16(DupSort) != 0 {
 		if nodeFlags&nodeTree != 0 && dataSize >= 48 {
 			// Sub-tree: initialize full dup state for subsequent navigation
-			if err := c.initDupSubTree(data); err != nil {
-				return nil, nil, err
+			if !c.restoreSubTreeEdge(data, 0) {
+				if err := c.initDupSubTree(data); err != nil {
+					return nil, nil, err
+				}
+				c.saveSubTreeEdge(0)
 			}
 			return c.getCurrent()
 		}
@@ -1777,8 +2144,11 @@ func (c *Cursor) lastDup() ([]byte, []byte, error) {
 	if c.tree != nil && c.tree.Flags&uint16(DupSort) != 0 {
 		if nodeFlags&nodeTree != 0 && dataSize >= 48 {
 			// Sub-tree: initialize full dup state at last position
-			if err := c.initDupSubTreeLast(data); err != nil {
-				return nil, nil, err
+			if !c.restoreSubTreeEdge(data, 1) {
+				if err := c.initDupSubTreeLast(data); err != nil {
+					return nil, nil, err
+				}
+				c.saveSubTreeEdge(1)
 			}
 			return c.getCurrent()
 		}
@@ -1796,11 +2166,51 @@ func (c *Cursor) lastDup() ([]byte, []byte, error) {
 	return key, data, nil
 }
 
+// saveSubTreeEdge caches the sub-tree path just set up by initDupSubTree
+// (edge 0) or initDupSubTreeLast (edge 1).
+func (c *Cursor) saveSubTreeEdge(edge int) {
+	if !c.readOnly {
+		return
+	}
+	e := &c.dup.edges[edge]
+	e.txnid = c.txn.txnID
+	e.subTree = c.dup.subTree
+	e.top = c.dup.subTop
+	for i := 0; i <= int(e.top); i++ {
+		e.pages[i] = *c.dup.subPages[i]
+		e.indices[i] = c.dup.subIndices[i]
+	}
+}
+
+// restoreSubTreeEdge sets up the dup state from a cached path when it
+// belongs to the sub-tree described by treeData in this snapshot.
+func (c *Cursor) restoreSubTreeEdge(treeData []byte, edge int) bool {
+	e := &c.dup.edges[edge]
+	if !c.readOnly || e.txnid != c.txn.txnID || pgno(binary.LittleEndian.Uint32(treeData[8:])) != e.subTree.Root {
+		return false
+	}
+	c.dup.subTree = e.subTree
+	c.dup.subTop = e.top
+	for i := 0; i <= int(e.top); i++ {
+		c.dup.subPagesBuf[i] = e.pages[i]
+		c.dup.subPages[i] = &c.dup.subPagesBuf[i]
+		c.dup.subIndices[i] = e.indices[i]
+	}
+	c.dup.isSubTree = true
+	c.dup.initialized = true
+	c.dup.atFirst = edge == 0
+	c.dup.atLast = edge == 1
+	return true
+}
+
 // nextDup moves to the next duplicate of the current key
 func (c *Cursor) nextDup() ([]byte, []byte, error) {
 	if c.state != cursorPointing {
 		return nil, nil, ErrNotFoundError
 	}
+	if err := c.syncDupSubPage(); err != nil {
+		return nil, nil, err
+	}
 
 	// After delete, cursor is already at the "next" position - just return current
 	if c.afterDelete {
@@ -1848,6 +2258,9 @@ func (c *Cursor) prevDup() ([]byte, []byte, error) {
 	if c.state != cursorPointing {
 		return nil, nil, ErrNotFoundError
 	}
+	if err := c.syncDupSubPage(); err != nil {
+		return nil, nil, err
+	}
 
 	// After delete, cursor is already at the correct position - just return current
 	if c.afterDelete {
@@ -2305,6 +2718,10 @@ func (c *Cursor) getLastSubTreeValue(treeData []byte) ([]byte, error) {
 		lower := *(*uint16)(unsafe.Add(pagePtr, 12))
 		numEntries := int(lower) >> 1
 		lastIdx := numEntries - 1
+		if pageFlags(*(*uint16)(unsafe.Add(pagePtr, 10)))&pageDupfix != 0 {
+			ksize := int(*(*uint16)(unsafe.Add(pagePtr, 8)))
+			return unsafe.Slice((*byte)(unsafe.Add(pagePtr, pageHeaderSize+lastIdx*ksize)), ksize), nil
+		}
 		storedOffset := *(*uint16)(unsafe.Add(pagePtr, uintptr(20+lastIdx*2)))
 		nodeOffset := uintptr(storedOffset + 20)
 		keySize := int(*(*uint16)(unsafe.Add(pagePtr, nodeOffset+6)))
@@ -2337,6 +2754,10 @@ func (c *Cursor) getLastSubTreeValue(treeData []byte) ([]byte, error) {
 	lower := *(*uint16)(unsafe.Add(pagePtr, 12))
 	numEntries := int(lower) >> 1
 	lastIdx := numEntries - 1
+	if pageFlags(*(*uint16)(unsafe.Add(pagePtr, 10)))&pageDupfix != 0 {
+		ksize := int(*(*uint16)(unsafe.Add(pagePtr, 8)))
+		return unsafe.Slice((*byte)(unsafe.Add(pagePtr, pageHeaderSize+lastIdx*ksize)), ksize), nil
+	}
 	storedOffset := *(*uint16)(unsafe.Add(pagePtr, uintptr(20+lastIdx*2)))
 	nodeOffset := uintptr(storedOffset + 20)
 	// Key size is at node+6 (2 bytes)
@@ -2358,7 +2779,7 @@ func (c *Cursor) getLastSubPageValue(subPageData []byte) ([]byte, error) {
 
 	// Check for DUPFIX (fixed-size values)
 	if (flags&uint16(pageDupfix) != 0) && dupfixKsize > 0 && dupfixKsize < 65535 {
-		numEntries := (len(subPageData) - pageHeaderSize) / dupfixKsize
+		numEntries := subPageDupfixCount(subPageData, dupfixKsize)
 		if numEntries == 0 {
 			return nil, ErrNotFoundError
 		}
@@ -2436,6 +2857,7 @@ func (c *Cursor) descendRightFast() ([]byte, []byte, error) {
 		c.pages[c.top] = childPage
 		lastIdx := uint16(childPage.numEntriesFast() - 1)
 		c.indices[c.top] = lastIdx
+		c.numExpected[c.top] = uint16(childPage.numEntriesFast())
 	}
 }
 
@@ -2521,6 +2943,9 @@ func (c *Cursor) getBoth(key, value []byte) ([]byte, []byte, error) {
 }
 
 // getBothRange positions at the key with the first value >= specified (DUPSORT databases).
+// If the key exists but all its values are < specified, it returns ErrNotFound
+// (matching libmdbx) and leaves the cursor on the first value of the next key,
+// so GetCurrent resumes a scan from there. Without a next key the cursor is at EOF.
 func (c *Cursor) getBothRange(key, value []byte) ([]byte, []byte, error) {
 	// First, find the key using optimized search that skips dup init
 	foundKey, err := c.setNoGetCurrent(key)
@@ -2537,7 +2962,16 @@ func (c *Cursor) getBothRange(key, value []byte) ([]byte, []byte, error) {
 	}
 
 	// For DUPSORT, search directly without full dup init
-	return c.searchDupValueDirect(foundKey, value, false)
+	k, v, err := c.searchDupValueDirect(foundKey, value, false)
+	if err != ErrNotFoundError {
+		return k, v, err
+	}
+
+	// No value >= specified under this key: move on to the next key
+	if _, _, err := c.nextNoDup(); err != nil {
+		c.state = cursorEOF
+	}
+	return nil, nil, ErrNotFoundError
 }
 
 // setNoGetCurrent positions at the exact key without calling getCurrent.
@@ -2653,6 +3087,15 @@ func (c *Cursor) searchDupSubTreeDirect(key []byte, treeData []byte, value []byt
 	subPage := c.txn.fillPageHotPath(pgno(root), &c.dup.subPagesBuf[c.dup.subTop])
 	c.dup.subPages[c.dup.subTop] = subPage
 
+	// 8-byte values under the bytewise order, the common case for IntegerDup
+	// id indexes, are searched as big-endian integers
+	c.txn.initDupComparator(c.dbi)
+	value8 := len(value) == 8 && c.txn.dbiUsesDefaultDupCmp[c.dbi]
+	var value64 uint64
+	if value8 {
+		value64 = binary.BigEndian.Uint64(value)
+	}
+
 	// Navigate to leaf using value as search key
 	// Use tree height to avoid IsBranchFast check in loop
 	for level := 1; level < height; level++ {
@@ -2664,10 +3107,14 @@ func (c *Cursor) searchDupSubTreeDirect(key []byte, treeData []byte, value []byt
 
 		// On branch pages, entry 0 has no key (it's the leftmost child pointer).
 		// We search entries 1 to n-1 for the key position.
-		var idx int
-		if n <= 1 {
+		idx := -1
+		if value8 {
+			// -1 if a separator isn't 8 bytes long
+			idx = binarySearchBranch8(pageData, value64, n)
+		}
+		if idx < 0 && n <= 1 {
 			idx = 0 // Only leftmost child
-		} else {
+		} else if idx < 0 {
 			// Binary search entries 1 to n-1
 			low, high := 1, n-1
 			for low <= high {
@@ -2678,14 +3125,10 @@ func (c *Cursor) searchDupSubTreeDirect(key []byte, treeData []byte, value []byt
 				keySize := int(*(*uint16)(unsafe.Add(pagePtr, nodeOffset+6)))
 				nodeKey := unsafe.Slice((*byte)(unsafe.Add(pagePtr, nodeOffset+8)), keySize)
 
+				// Separators are the first values of their children, so the
+				// dup order alone picks the child that could hold value
 				cmp := c.txn.compareDupValues(c.dbi, value, nodeKey)
 				if cmp < 0 {
-					// For range search: if search value is a prefix of separator,
-					// the separator IS >= search value, so descend to this child.
-					if len(value) < keySize && bytes.Equal(value, nodeKey[:len(value)]) {
-						low = mid + 1 // Treat as match, descend to child mid
-						break
-					}
 					high = mid - 1
 				} else if cmp > 0 {
 					low = mid + 1
@@ -2715,16 +3158,43 @@ func (c *Cursor) searchDupSubTreeDirect(key []byte, treeData []byte, value []byt
 	pagePtr := unsafe.Pointer(&pageData[0])
 	lower := *(*uint16)(unsafe.Add(pagePtr, 12))
 	n := int(lower) >> 1
+	isDupfix := pageFlagsDirect(pageData)&pageDupfix != 0
 
 	low, high := 0, n-1
 	foundIdx := n
+	if value8 {
+		// Both searches return the index of value or of the first value above it
+		idx := -1
+		if isDupfix {
+			if pageDupfixSizeDirect(pageData) == 8 {
+				idx = searchDupfix8(pageData, value64, n)
+			}
+		} else {
+			idx = binarySearchLeaf8(pageData, value64, n)
+		}
+		if idx >= 0 && idx < n {
+			if k := subTreeKey(subPage, idx); len(k) == 8 && binary.BigEndian.Uint64(k) == value64 {
+				c.dup.subIndices[c.dup.subTop] = uint16(idx)
+				c.dup.initialized = true
+				return key, k, nil
+			}
+		}
+		if idx >= 0 {
+			foundIdx, low = idx, high+1 // Skip the generic search
+		}
+	}
 	for low <= high {
 		mid := (low + high) / 2
-		// Inline GetKeyFast
-		storedOffset := *(*uint16)(unsafe.Add(pagePtr, uintptr(20+mid*2)))
-		nodeOffset := uintptr(storedOffset + 20)
-		keySize := int(*(*uint16)(unsafe.Add(pagePtr, nodeOffset+6)))
-		nodeKey := unsafe.Slice((*byte)(unsafe.Add(pagePtr, nodeOffset+8)), keySize)
+		var nodeKey []byte
+		if isDupfix {
+			nodeKey = subPage.dupfixKey(mid)
+		} else {
+			// Inline GetKeyFast
+			storedOffset := *(*uint16)(unsafe.Add(pagePtr, uintptr(20+mid*2)))
+			nodeOffset := uintptr(storedOffset + 20)
+			keySize := int(*(*uint16)(unsafe.Add(pagePtr, nodeOffset+6)))
+			nodeKey = unsafe.Slice((*byte)(unsafe.Add(pagePtr, nodeOffset+8)), keySize)
+		}
 
 		cmp := c.txn.compareDupValues(c.dbi, value, nodeKey)
 		if cmp < 0 {
@@ -2744,18 +3214,20 @@ func (c *Cursor) searchDupSubTreeDirect(key []byte, treeData []byte, value []byt
 		return nil, nil, ErrNotFoundError
 	}
 
-	// For GetBothRange, return first value >= target
+	// For GetBothRange, return first value >= target. Past the end of the
+	// leaf, that is the first value of the next one.
 	if foundIdx >= n {
-		return nil, nil, ErrNotFoundError
+		if n == 0 {
+			return nil, nil, ErrNotFoundError
+		}
+		c.dup.subIndices[c.dup.subTop] = uint16(n - 1)
+		c.dup.initialized = true
+		return c.dupSubTreeNext()
 	}
 	c.dup.subIndices[c.dup.subTop] = uint16(foundIdx)
 	c.dup.initialized = true
 	// Get found value
-	storedOffset := *(*uint16)(unsafe.Add(pagePtr, uintptr(20+foundIdx*2)))
-	nodeOffset := uintptr(storedOffset + 20)
-	keySize := int(*(*uint16)(unsafe.Add(pagePtr, nodeOffset+6)))
-	foundVal := unsafe.Slice((*byte)(unsafe.Add(pagePtr, nodeOffset+8)), keySize)
-	return key, foundVal, nil
+	return key, subTreeKey(subPage, foundIdx), nil
 }
 
 // searchDupSubPageDirect does binary search in inline sub-page without full init.
@@ -2876,6 +3348,7 @@ func (c *Cursor) initDupSubPageState(subPageData []byte, numEntries int, isDupfi
 	c.dup.initialized = true
 	c.dup.isSubTree = false
 	c.dup.subPageData = subPageData
+	c.dup.writes = c.txn.pageWrites
 	c.dup.subPageIdx = idx
 	c.dup.subPageNum = numEntries
 
@@ -3009,6 +3482,10 @@ func (c *Cursor) countDuplicates() (uint64, error) {
 		return 0, ErrNotFoundError
 	}
 
+	if err := c.syncDupSubPage(); err != nil {
+		return 0, err
+	}
+
 	// Fast path: if dup state is already initialized, use it
 	if c.dup.initialized {
 		if c.dup.isSubTree {
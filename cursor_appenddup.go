@@ -0,0 +1,83 @@
+package gdbx
+
+import "bytes"
+
+// appendDupSubTree puts value at the end of the sub-tree of the node at idx
+// of the current leaf, checking that it sorts at or after the last value,
+// as AppendDup requires. A value equal to the last is already present. The
+// value goes at the right edge of the sub-tree with no search, and a full
+// leaf starts a new one rather than splitting, so a run of appends builds a
+// packed sub-tree.
+func (c *Cursor) appendDupSubTree(p *page, idx int, value []byte) error {
+	treeData := nodeGetDataDirect(p, idx)
+	subTree := parseTreeFromBytes(treeData)
+	if subTree == nil || subTree.Root == invalidPgno {
+		return ErrCorruptedError
+	}
+	sub := c.dupAppendCursor(subTree)
+
+	// Position past the last value, at the end of the rightmost leaf
+	if err := sub.positionAfterLast(); err != nil {
+		return err
+	}
+	leaf := sub.pages[sub.top]
+	n := leaf.numEntriesFast()
+	if n == 0 {
+		return ErrCorruptedError
+	}
+	var last []byte
+	if leaf.isDupfix() {
+		last = leaf.dupfixKey(n - 1)
+	} else {
+		last = nodeGetKeyDirect(leaf, n-1)
+	}
+	cmp := c.txn.compareDupValues(c.dbi, value, last)
+	if cmp < 0 {
+		return WrapError(ErrKeyMismatch, &AppendDupError{Last: bytes.Clone(last)})
+	}
+	if cmp == 0 {
+		return nil
+	}
+
+	// insertNode and insertDupfix count the value in subTree.Items
+	if subTree.DupfixSize != 0 {
+		if err := sub.insertDupfix(value); err != nil {
+			return err
+		}
+	} else if err := sub.insertNode(c.buildSubTreeNode(value), 0); err != nil {
+		return err
+	}
+	subTree.ModTxnid = txnid(c.txn.txnID)
+
+	mainPage, err := c.touchPage()
+	if err != nil {
+		return err
+	}
+	nodeData := c.buildNodeWithDupTree(nodeGetKeyDirect(mainPage, idx), subTree)
+	if !mainPage.updateEntry(idx, nodeData) {
+		// The record is the same size as before
+		return NewError(ErrPageFull)
+	}
+	c.pages[c.top] = mainPage
+	c.tree.Items++
+	c.markTreeDirty()
+	return nil
+}
+
+// dupAppendCursor returns the cursor's sub-cursor, set up on subTree with
+// an empty stack. It is allocated by the first append to a sub-tree and
+// reused by the rest of the run.
+func (c *Cursor) dupAppendCursor(subTree *tree) *Cursor {
+	sub := c.subcur
+	if sub == nil {
+		sub = &Cursor{}
+		c.subcur = sub
+	}
+	sub.reset()
+	sub.signature = cursorSignature
+	sub.dbi = c.dbi
+	sub.txn = c.txn
+	sub.tree = subTree
+	sub.dupValues = true
+	return sub
+}
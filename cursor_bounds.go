@@ -0,0 +1,116 @@
+package gdbx
+
+// SetBounds restricts the cursor to keys in [low, high). A nil low leaves the
+// range open below and a nil high leaves it open above; SetBounds(nil, nil)
+// removes the restriction. The bounds are copied and stay in effect until
+// changed, including across Renew.
+//
+// While bounds are set, Get never returns a key outside the range: First
+// positions at the first key >= low, Last at the largest key < high, and any
+// operation that would land outside the range leaves the cursor at EOF and
+// returns ErrNotFound, as does running off either end of the table. Prev from
+// EOF positions at the last key in range. A SetRange or SetLowerbound key
+// below low searches from low instead.
+func (c *Cursor) SetBounds(low, high []byte) {
+	c.boundLow, c.boundHigh = nil, nil
+	if low != nil {
+		c.boundLow = append([]byte{}, low...)
+	}
+	if high != nil {
+		c.boundHigh = append([]byte{}, high...)
+	}
+	c.bounded = low != nil || high != nil
+
+	if c.logID != 0 && c.txn != nil && c.txn.logOps {
+		c.txn.env.opLog.start(opCursorSetBounds).uint(c.logID).bytes(low).bytes(high).end(nil)
+	}
+}
+
+// Bounds returns the range set with SetBounds (nil for an open side).
+func (c *Cursor) Bounds() (low, high []byte) {
+	return c.boundLow, c.boundHigh
+}
+
+// getBounded runs a Get operation clamped to the cursor's bounds.
+func (c *Cursor) getBounded(key, value []byte, op CursorOp) ([]byte, []byte, error) {
+	c.txn.cacheComparator(c.dbi)
+
+	var k, v []byte
+	var err error
+	switch op {
+	case First:
+		k, v, err = c.boundedFirst()
+	case Last:
+		k, v, err = c.boundedLast()
+	case Next, NextNoDup:
+		if c.state == cursorUninitialized {
+			k, v, err = c.boundedFirst()
+		} else {
+			k, v, err = c.getUnbounded(key, value, op)
+		}
+	case Prev, PrevNoDup:
+		if c.state != cursorPointing {
+			k, v, err = c.boundedLast()
+		} else {
+			k, v, err = c.getUnbounded(key, value, op)
+		}
+	case SetRange, SetLowerbound:
+		if c.boundLow != nil && c.txn.compareKeys(c.dbi, key, c.boundLow) < 0 {
+			key, value = c.boundLow, nil
+		}
+		k, v, err = c.getUnbounded(key, value, op)
+	default:
+		k, v, err = c.getUnbounded(key, value, op)
+	}
+
+	if err == nil && c.inBounds(k) {
+		return k, v, nil
+	}
+	if err != nil && !IsNotFound(err) {
+		return nil, nil, err
+	}
+	// Running off the table behaves like crossing a bound
+	c.state = cursorEOF
+	c.afterDelete = false
+	return nil, nil, ErrNotFoundError
+}
+
+// getUnbounded runs a Get operation with the bounds suspended.
+func (c *Cursor) getUnbounded(key, value []byte, op CursorOp) ([]byte, []byte, error) {
+	c.bounded = false
+	k, v, err := c.Get(key, value, op)
+	c.bounded = true
+	return k, v, err
+}
+
+// boundedFirst positions at the first key >= low.
+func (c *Cursor) boundedFirst() ([]byte, []byte, error) {
+	if c.boundLow == nil {
+		return c.first()
+	}
+	return c.setRange(c.boundLow)
+}
+
+// boundedLast positions at the last value of the largest key < high.
+func (c *Cursor) boundedLast() ([]byte, []byte, error) {
+	if c.boundHigh == nil {
+		return c.last()
+	}
+	_, _, err := c.setRange(c.boundHigh)
+	if IsNotFound(err) {
+		// Every key is below high
+		return c.last()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.movePrev()
+}
+
+// inBounds returns true if key lies within [low, high).
+func (c *Cursor) inBounds(key []byte) bool {
+	if c.boundLow != nil && c.txn.compareKeys(c.dbi, key, c.boundLow) < 0 {
+		return false
+	}
+	return c.boundHigh == nil || c.txn.compareKeys(c.dbi, key, c.boundHigh) < 0
+}
@@ -0,0 +1,107 @@
+package gdbx
+
+import "encoding/binary"
+
+// CountRange returns the number of keys k with low <= k < high in the
+// cursor's table. A nil low counts from the first key and a nil high to the
+// last. A DupSort key counts once; CountRangeValues counts its values. The
+// cursor is not moved, and bounds set with SetBounds do not apply. Only the
+// paths to the bounds are searched; the leaves between them are counted from
+// their page headers, one page read per leaf.
+func (c *Cursor) CountRange(low, high []byte) (uint64, error) {
+	return c.countRange(low, high, false)
+}
+
+// CountRangeValues returns the number of values stored under the keys k
+// with low <= k < high, bounds as in CountRange. It equals CountRange on
+// tables without DupSort.
+func (c *Cursor) CountRangeValues(low, high []byte) (uint64, error) {
+	return c.countRange(low, high, c.tree.Flags&uint16(DupSort) != 0)
+}
+
+func (c *Cursor) countRange(low, high []byte, values bool) (uint64, error) {
+	if !c.valid() {
+		return 0, ErrBadCursorError
+	}
+	if c.tree.isEmpty() {
+		return 0, nil
+	}
+	c.txn.cacheComparator(c.dbi)
+	if low != nil && high != nil && c.txn.compareKeys(c.dbi, low, high) >= 0 {
+		return 0, nil
+	}
+	return c.countSubtree(c.tree.Root, low, high, values, 0)
+}
+
+// countSubtree counts the keys, or values, of the subtree rooted at pn that
+// lie within [low, high), a nil bound meaning the subtree is known to lie
+// within it on that side.
+func (c *Cursor) countSubtree(pn pgno, low, high []byte, values bool, depth int) (uint64, error) {
+	if depth >= CursorStackSize {
+		return 0, ErrCursorFullError
+	}
+	var buf page
+	p := c.txn.fillPageHotPath(pn, &buf)
+	if p.Data == nil {
+		return 0, ErrCorruptedError
+	}
+	n := p.numEntriesFast()
+	from, to := 0, n
+	if low != nil {
+		from = c.searchPage(p, low)
+	}
+	if high != nil {
+		to = c.searchPage(p, high)
+	}
+
+	if p.isLeaf() {
+		if !values {
+			return uint64(max(to-from, 0)), nil
+		}
+		var count uint64
+		for i := from; i < to; i++ {
+			count += nodeDupCount(p, i)
+		}
+		return count, nil
+	}
+
+	// On a branch the bounds fall in children from and to, which are
+	// searched; the children between them are counted whole
+	if high != nil {
+		to++
+	}
+	var count uint64
+	for i := from; i < min(to, n); i++ {
+		var l, h []byte
+		if i == from {
+			l = low
+		}
+		if high != nil && i == to-1 {
+			h = high
+		}
+		sub, err := c.countSubtree(nodeGetChildPgnoFast(p, i), l, h, values, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		count += sub
+	}
+	return count, nil
+}
+
+// nodeDupCount returns the number of values of the leaf node at idx: the
+// items of its sub-tree, the entries of its sub-page, or 1.
+func nodeDupCount(p *page, idx int) uint64 {
+	flags := nodeGetFlagsDirect(p, idx)
+	if flags&(nodeTree|nodeDup) == 0 {
+		return 1
+	}
+	data := nodeGetDataDirect(p, idx)
+	switch {
+	case flags&nodeTree != 0 && len(data) >= treeSize:
+		return binary.LittleEndian.Uint64(data[32:])
+	case flags&nodeTree == 0 && len(data) >= pageHeaderSize:
+		// Sub-page lower is the size of its entry pointers
+		return uint64(binary.LittleEndian.Uint16(data[12:]) >> 1)
+	}
+	return 1
+}
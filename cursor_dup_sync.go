@@ -0,0 +1,154 @@
+package gdbx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// subPageHoleFraction bounds the holes a leaf keeps after a sub-page write:
+// once the bytes no node occupies exceed 1/subPageHoleFraction of the page,
+// the leaf is compacted. Each insert into a sub-page grows its node, which
+// moves to the free end of the page and leaves its old place as a hole.
+const subPageHoleFraction = 4
+
+// syncDupSubPage reads the cursor's inline sub-page view again from its node
+// if pages were written since it was loaded, keeping its index: any write to
+// the leaf can move the node, leaving the view on other bytes. If the node
+// no longer holds a sub-page, the dup state is dropped and the next move
+// starts over from the node.
+func (c *Cursor) syncDupSubPage() error {
+	if c.readOnly || !c.dup.initialized || c.dup.isSubTree || c.dup.writes == c.txn.pageWrites {
+		return nil
+	}
+	c.refreshPage()
+	if c.top < 0 || c.state != cursorPointing {
+		c.clearDupState()
+		return nil
+	}
+	p := c.pages[c.top]
+	idx := int(c.indices[c.top])
+	if idx >= p.numEntriesFast() || nodeGetFlagsDirect(p, idx)&(nodeDup|nodeTree) != nodeDup {
+		c.clearDupState()
+		return nil
+	}
+
+	pos, first, last := c.dup.subPageIdx, c.dup.atFirst, c.dup.atLast
+	if err := c.initDupSubPage(nodeGetDataDirect(p, idx)); err != nil {
+		return err
+	}
+	c.dup.subPageIdx = min(pos, c.dup.subPageNum-1)
+	c.dup.atFirst = first && c.dup.subPageIdx == 0
+	c.dup.atLast = last && c.dup.subPageIdx == c.dup.subPageNum-1
+	return nil
+}
+
+// sharesDupNode reports whether oc, another cursor of c's transaction, has an
+// inline sub-page view of the node c is on.
+func (c *Cursor) sharesDupNode(oc *Cursor) bool {
+	if oc == c || oc.tree != c.tree || oc.dupValues || oc.state != cursorPointing ||
+		oc.top != c.top || !oc.dup.initialized || oc.dup.isSubTree {
+		return false
+	}
+	for i := 0; i <= int(c.top); i++ {
+		if oc.indices[i] != c.indices[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// trackDupSubPage keeps the other cursors on c's node on their values after
+// c rewrote the node's sub-page in place, with a value put at pos (delta 1)
+// or the value at pos deleted (delta -1), leaving n values. They take c's
+// page stack, and reload their views when next read.
+func (c *Cursor) trackDupSubPage(pos, delta, n int) {
+	for _, oc := range c.txn.cursors {
+		if !c.sharesDupNode(oc) {
+			continue
+		}
+		top := int(c.top)
+		copy(oc.pages[:top+1], c.pages[:top+1])
+		copy(oc.stackDirty[:top+1], c.stackDirty[:top+1])
+		oc.dirtyMask = c.dirtyMask
+		oc.dup.atFirst = false
+		oc.dup.atLast = false
+
+		switch idx := oc.dup.subPageIdx; {
+		case idx > pos || (delta > 0 && idx == pos):
+			oc.dup.subPageIdx += delta
+		case delta < 0 && idx == pos:
+			// Its value is gone: as the deleting cursor, it stands on the
+			// next one, or past the last it goes on from the new last
+			if pos < n {
+				oc.afterDelete = true
+			} else {
+				oc.dup.subPageIdx = n - 1
+			}
+		}
+	}
+}
+
+// dropDupSubPage clears the views of the other cursors on c's node before c
+// replaces its sub-page with something else: a sub-tree, a single value, or
+// the same node moved by a split. Their next move starts over from the node.
+func (c *Cursor) dropDupSubPage() {
+	for _, oc := range c.txn.cursors {
+		if c.sharesDupNode(oc) {
+			oc.clearDupState()
+			oc.dup.atFirst = false
+			oc.dup.atLast = false
+		}
+	}
+}
+
+// compactSubPageLeaf compacts leaf p after a sub-page write once its holes
+// exceed the bound set by subPageHoleFraction.
+func (c *Cursor) compactSubPageLeaf(p *page) {
+	if p.holes() > len(p.Data)/subPageHoleFraction {
+		p.compactWithBuf(c.txn.compactBuf[:])
+	}
+}
+
+// checkSubPage checks that data is a well-formed inline sub-page and returns
+// the number of values it holds. Besides the header, the values of a
+// variable-size sub-page must each lie between the pointer table and the end
+// of the sub-page without overlapping another, so that reading any of them
+// never lands in a hole or in a neighbour.
+func checkSubPage(data []byte) (int, error) {
+	if len(data) < pageHeaderSize {
+		return 0, fmt.Errorf("sub-page of %d bytes", len(data))
+	}
+	sp := &page{Data: data}
+	h := sp.header()
+	count := sp.numEntries()
+	if h.Flags&pageSubP == 0 || count == 0 {
+		return 0, fmt.Errorf("sub-page of type %#x with %d values", h.Flags, count)
+	}
+	if h.Flags&pageDupfix != 0 {
+		if h.DupfixKsize == 0 || pageHeaderSize+count*int(h.DupfixKsize) > len(data) {
+			return 0, fmt.Errorf("sub-page of %d bytes holds %d values of %d", len(data), count, h.DupfixKsize)
+		}
+		return count, nil
+	}
+	if pageHeaderSize+2*count > len(data) {
+		return 0, fmt.Errorf("sub-page of %d bytes holds %d values", len(data), count)
+	}
+	type span struct{ start, end, value int }
+	spans := make([]span, count)
+	for j := range spans {
+		off := int(binary.LittleEndian.Uint16(data[pageHeaderSize+2*j:])) + pageHeaderSize
+		if off < pageHeaderSize+2*count || off+nodeSize > len(data) ||
+			off+nodeSize+int(binary.LittleEndian.Uint16(data[off+6:])) > len(data) {
+			return 0, fmt.Errorf("value %d runs past its sub-page", j)
+		}
+		spans[j] = span{off, off + nodeSize + int(binary.LittleEndian.Uint16(data[off+6:])), j}
+	}
+	slices.SortFunc(spans, func(a, b span) int { return a.start - b.start })
+	for j := 1; j < count; j++ {
+		if spans[j].start < spans[j-1].end {
+			return 0, fmt.Errorf("values %d and %d overlap in their sub-page", spans[j-1].value, spans[j].value)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,117 @@
+package gdbx
+
+import "bytes"
+
+// SeekFloor positions at the largest key <= key, the floor of key, where
+// SetRange finds its ceiling, and returns it with its value; on DUPSORT
+// tables it lands on the key's last duplicate, so PrevDup walks back through
+// the rest. It returns ErrNotFound, leaving the cursor at EOF, only when
+// every key is greater. With SetBounds in effect it positions at the floor
+// within the range: a key at or above high gives the last key below high.
+//
+// The floor is found in the one descent that looks for key. Only when key
+// sorts before the first key of the leaf it leads to does the cursor climb
+// to the nearest branch with a child further left and descend that child's
+// right edge, without searching again.
+func (c *Cursor) SeekFloor(key []byte) ([]byte, []byte, error) {
+	if !c.valid() {
+		return nil, nil, ErrBadCursorError
+	}
+	c.txn.cacheComparator(c.dbi)
+	c.atEnd = false
+
+	var k, v []byte
+	var err error
+	if c.bounded && c.boundHigh != nil && c.txn.compareKeys(c.dbi, key, c.boundHigh) >= 0 {
+		k, v, err = c.boundedLast()
+	} else {
+		k, v, err = c.seekFloor(key)
+	}
+	if err == nil && c.bounded && !c.inBounds(k) {
+		c.state = cursorEOF
+		return nil, nil, ErrNotFoundError
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.safeValues {
+		return bytes.Clone(k), bytes.Clone(v), nil
+	}
+	return k, v, nil
+}
+
+// SetKeyNearest positions at key as SetKey does and returns it with exact
+// set, or, if key is absent, at the smallest key above it, as SetRange does,
+// and returns that one, the next match for an auto-complete to show;
+// PeekPrev then gives the key below it. On DUPSORT tables it lands on the
+// key's first value. It returns ErrNotFound, leaving the cursor at EOF, only
+// when no key is at or above key, within SetBounds if they are in effect.
+func (c *Cursor) SetKeyNearest(key []byte) (exact bool, k, v []byte, err error) {
+	if !c.valid() {
+		return false, nil, nil, ErrBadCursorError
+	}
+	k, v, err = c.Get(key, nil, SetRange)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	return c.txn.compareKeys(c.dbi, k, key) == 0, k, v, nil
+}
+
+// seekFloor positions at the last value of the largest key <= key.
+func (c *Cursor) seekFloor(key []byte) ([]byte, []byte, error) {
+	c.reset()
+	if c.tree.isEmpty() {
+		c.state = cursorEOF
+		return nil, nil, ErrNotFoundError
+	}
+
+	c.top = 0
+	p := c.txn.fillPageHotPath(c.tree.Root, &c.pagesBuf[0])
+	c.pages[0] = p
+	c.numExpected[0] = uint16(p.numEntriesFast())
+	for p.isBranchFast() {
+		idx := c.searchPage(p, key)
+		c.indices[c.top] = uint16(idx)
+		if err := c.pushPageByPgno(c.getChildPgno(p, idx), 0); err != nil {
+			return nil, nil, err
+		}
+		p = c.pages[c.top]
+	}
+
+	// searchPage gives the first key >= key; the floor is it or the one before
+	idx := c.searchPage(p, key)
+	if idx >= p.numEntriesFast() || c.txn.compareKeys(c.dbi, key, nodeGetKeyDirect(p, idx)) != 0 {
+		idx--
+	}
+	if idx < 0 {
+		// Every key of the leaf is greater: the floor ends the subtree left
+		// of the path, found from the deepest branch not on its first child
+		level := int(c.top) - 1
+		for level >= 0 && c.indices[level] == 0 {
+			level--
+		}
+		if level < 0 {
+			c.state = cursorEOF
+			return nil, nil, ErrNotFoundError
+		}
+		c.top = int8(level)
+		c.indices[level]--
+		for p = c.pages[c.top]; p.isBranchFast(); p = c.pages[c.top] {
+			if err := c.pushPageByPgno(c.getChildPgno(p, int(c.indices[c.top])), 0); err != nil {
+				return nil, nil, err
+			}
+			c.indices[c.top] = uint16(max(c.pages[c.top].numEntriesFast()-1, 0))
+		}
+		if p.numEntriesFast() == 0 {
+			return nil, nil, ErrCorruptedError
+		}
+		idx = p.numEntriesFast() - 1
+	}
+	c.indices[c.top] = uint16(idx)
+	c.state = cursorPointing
+
+	if c.tree.Flags&uint16(DupSort) != 0 {
+		return c.lastDup()
+	}
+	return c.getCurrent()
+}
@@ -0,0 +1,152 @@
+package gdbx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// errFramedStream is wrapped in the error ImportFramed returns for a stream
+// that ends inside a frame or holds a malformed length.
+var errFramedStream = errors.New("malformed framed stream")
+
+// framedChunk bounds what ImportFramed reads into memory ahead of the bytes
+// arriving, so a corrupt length can't make it allocate gigabytes.
+const framedChunk = 1 << 20
+
+// ExportFramed writes the cursor's table from its first entry to its last,
+// within the cursor's SetBounds, to w as length-prefixed frames, and returns
+// the number of frames written. Each key/value pair, and each value of a
+// DupSort key, is one frame, with nothing before, between or after them:
+//
+//	keyLen uvarint
+//	key    [keyLen]byte
+//	valLen uvarint
+//	value  [valLen]byte
+//
+// The stream carries no table flags or comparators. The cursor is left past
+// the last entry. Errors from w are returned as they are.
+func (c *Cursor) ExportFramed(w io.Writer) (uint64, error) {
+	if !c.valid() {
+		return 0, ErrBadCursorError
+	}
+	bw := bufio.NewWriterSize(w, framedChunk)
+	var hdr [binary.MaxVarintLen64]byte
+	var n uint64
+	for k, v, err := c.Get(nil, nil, First); ; k, v, err = c.Get(nil, nil, Next) {
+		if IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if _, err := bw.Write(binary.AppendUvarint(hdr[:0], uint64(len(k)))); err != nil {
+			return n, err
+		}
+		if _, err := bw.Write(k); err != nil {
+			return n, err
+		}
+		if _, err := bw.Write(binary.AppendUvarint(hdr[:0], uint64(len(v)))); err != nil {
+			return n, err
+		}
+		if _, err := bw.Write(v); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, bw.Flush()
+}
+
+// ImportFramed reads frames written by ExportFramed from r and puts each
+// pair into dbi with flags, such as Append or AppendDup for a stream that
+// follows the table's order, until r ends. It returns the number of pairs
+// put. A key or value beyond the table's limits fails with ErrBadKeySize or
+// ErrBadValSize, and a stream that ends inside a frame with ErrInvalid; the
+// pairs put before the failure stay in the transaction, which the caller
+// can abort. Errors from r other than io.EOF are wrapped in ErrProblem.
+func (txn *Txn) ImportFramed(dbi DBI, r io.Reader, flags uint) (uint64, error) {
+	if !txn.valid() {
+		return 0, NewError(ErrBadTxn)
+	}
+	br := bufio.NewReaderSize(r, framedChunk)
+	maxKey := uint64(txn.env.MaxKeySize())
+	var key, value []byte
+	var n uint64
+	for {
+		keyLen, err := readFramedLen(br)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, framedReadError(err)
+		}
+		if keyLen > maxKey {
+			return n, newSizeError(ErrBadKeySize, int(min(keyLen, MaxDataSize+1)), int(maxKey))
+		}
+		if key, err = readFramed(br, key, keyLen); err != nil {
+			return n, err
+		}
+		valLen, err := readFramedLen(br)
+		if err != nil {
+			return n, framedReadError(err)
+		}
+		if valLen > MaxDataSize {
+			return n, newSizeError(ErrBadValSize, int(min(valLen, MaxDataSize+1)), MaxDataSize)
+		}
+		if value, err = readFramed(br, value, valLen); err != nil {
+			return n, err
+		}
+		if err := txn.Put(dbi, key, value, flags); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// readFramed reads size bytes from br into buf, growing it as they arrive.
+func readFramed(br *bufio.Reader, buf []byte, size uint64) ([]byte, error) {
+	buf = buf[:0]
+	for uint64(len(buf)) < size {
+		start := len(buf)
+		chunk := int(min(size-uint64(start), framedChunk))
+		buf = slices.Grow(buf, chunk)[:start+chunk]
+		if _, err := io.ReadFull(br, buf[start:]); err != nil {
+			return nil, framedReadError(err)
+		}
+	}
+	return buf, nil
+}
+
+// readFramedLen reads a uvarint length from br. It returns io.EOF only if br
+// ends before the first byte, and errFramedStream for a varint that
+// overflows 64 bits.
+func readFramedLen(br *bufio.Reader) (uint64, error) {
+	var x uint64
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		b, err := br.ReadByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if b < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b > 1 {
+				break
+			}
+			return x | uint64(b)<<(7*i), nil
+		}
+		x |= uint64(b&0x7f) << (7 * i)
+	}
+	return 0, errFramedStream
+}
+
+// framedReadError maps an error reading inside a frame to ImportFramed's.
+func framedReadError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == errFramedStream {
+		return WrapError(ErrInvalid, errFramedStream)
+	}
+	return WrapError(ErrProblem, err)
+}
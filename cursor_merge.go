@@ -0,0 +1,261 @@
+package gdbx
+
+import (
+	"container/heap"
+	"reflect"
+)
+
+// MergeMode selects what a MergeCursor yields for an entry present in more
+// than one of its sources.
+type MergeMode int
+
+const (
+	// MergeAll yields the entry of every source that has it, in source order.
+	MergeAll MergeMode = iota
+	// MergeDedup yields the entry once, from the first source that has it,
+	// and skips it in the others. Entries are equal when their keys are, and
+	// in DupSort databases when their values are too.
+	MergeDedup
+)
+
+// mergeOrderFlags are the database flags that change how entries are ordered.
+const mergeOrderFlags = uint16(ReverseKey | IntegerKey | DupSort | ReverseDup | IntegerDup)
+
+// MergeCursor iterates over the entries of several databases in sorted order.
+// Source reports which database the current entry came from. Entries are
+// ordered by key, then by value in DupSort databases, then by source, so the
+// sources must share the environment, ordering flags and comparators.
+//
+// The sources must not be moved or written through while the MergeCursor
+// uses them. Keys and values returned are valid as those of Cursor.Get.
+type MergeCursor struct {
+	cursors []*Cursor
+	mode    MergeMode
+	txn     *Txn // Transaction of the first source, whose comparators are used
+	dbi     DBI
+	dupSort bool
+	heads   []mergeHead
+	h       mergeHeap
+	cur     int // Source of the current entry, -1 if there is none
+	started bool
+	err     error
+}
+
+// mergeHead is the entry a source cursor is positioned on.
+type mergeHead struct {
+	key, value []byte
+}
+
+// mergeHeap is a min-heap of source indexes ordered by their heads.
+type mergeHeap struct {
+	m   *MergeCursor
+	idx []int
+}
+
+// NewMergeCursor returns a cursor over the entries of the databases of
+// cursors. It fails with ErrIncompatible if the sources belong to different
+// environments or order their entries differently. The MergeCursor takes
+// over the cursors: Close closes them.
+func NewMergeCursor(cursors []*Cursor, mode MergeMode) (*MergeCursor, error) {
+	if len(cursors) == 0 || mode < MergeAll || mode > MergeDedup {
+		return nil, NewError(ErrInvalid)
+	}
+	for _, c := range cursors {
+		if !c.valid() {
+			return nil, ErrBadCursorError
+		}
+	}
+	first := cursors[0]
+	for _, c := range cursors[1:] {
+		if !first.sameOrder(c) {
+			return nil, NewError(ErrIncompatible)
+		}
+	}
+	m := &MergeCursor{
+		cursors: cursors,
+		mode:    mode,
+		txn:     first.txn,
+		dbi:     first.dbi,
+		dupSort: first.tree.Flags&uint16(DupSort) != 0,
+		heads:   make([]mergeHead, len(cursors)),
+		cur:     -1,
+	}
+	m.h = mergeHeap{m: m, idx: make([]int, 0, len(cursors))}
+	m.txn.cacheComparator(m.dbi)
+	return m, nil
+}
+
+// sameOrder reports whether the database of o orders its entries as that of c.
+func (c *Cursor) sameOrder(o *Cursor) bool {
+	if c.txn.env != o.txn.env || c.tree.Flags&mergeOrderFlags != o.tree.Flags&mergeOrderFlags {
+		return false
+	}
+	cmp, dcmp := c.txn.env.comparators(c.dbi)
+	ocmp, odcmp := o.txn.env.comparators(o.dbi)
+	return sameFunc(cmp, ocmp) && sameFunc(dcmp, odcmp)
+}
+
+// comparators returns the custom key and value comparators of dbi, nil where
+// it uses the default order.
+func (e *Env) comparators(dbi DBI) (cmp, dcmp CmpFunc) {
+	e.dbisMu.RLock()
+	defer e.dbisMu.RUnlock()
+	if int(dbi) < len(e.dbis) && e.dbis[dbi] != nil {
+		return e.dbis[dbi].cmp, e.dbis[dbi].dcmp
+	}
+	return nil, nil
+}
+
+// sameFunc reports whether a and b are both nil or the same function.
+func sameFunc(a, b CmpFunc) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// First positions every source on its first entry and returns the smallest.
+func (m *MergeCursor) First() ([]byte, []byte, error) {
+	return m.position(nil, First)
+}
+
+// Seek returns the first entry whose key is greater than or equal to key.
+func (m *MergeCursor) Seek(key []byte) ([]byte, []byte, error) {
+	return m.position(key, SetRange)
+}
+
+// Next returns the entry after the current one, or the first entry if the
+// cursor has not been positioned. It returns ErrNotFound past the last entry.
+func (m *MergeCursor) Next() ([]byte, []byte, error) {
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	if !m.started {
+		return m.First()
+	}
+	if m.cur < 0 {
+		return nil, nil, ErrNotFoundError
+	}
+	if err := m.advance(); err != nil {
+		return nil, nil, err
+	}
+	return m.settle()
+}
+
+// Source returns the index, in the slice given to NewMergeCursor, of the
+// source the current entry came from, or -1 if there is no current entry.
+func (m *MergeCursor) Source() int {
+	return m.cur
+}
+
+// Err returns the error that stopped the cursor, if any. Once a source has
+// failed, every operation returns its error.
+func (m *MergeCursor) Err() error {
+	return m.err
+}
+
+// Close closes the source cursors.
+func (m *MergeCursor) Close() {
+	for _, c := range m.cursors {
+		c.Close()
+	}
+	m.cursors = nil
+	m.h.idx = nil
+	m.cur = -1
+	m.err = ErrBadCursorError
+}
+
+// position moves every source with op and rebuilds the heap.
+func (m *MergeCursor) position(key []byte, op CursorOp) ([]byte, []byte, error) {
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+	m.started = true
+	m.h.idx = m.h.idx[:0]
+	for i, c := range m.cursors {
+		k, v, err := c.Get(key, nil, op)
+		if err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			m.err = err
+			m.cur = -1
+			return nil, nil, err
+		}
+		m.heads[i] = mergeHead{k, v}
+		m.h.idx = append(m.h.idx, i)
+	}
+	heap.Init(&m.h)
+	return m.settle()
+}
+
+// advance moves the source at the top of the heap to its next entry.
+func (m *MergeCursor) advance() error {
+	s := m.h.idx[0]
+	k, v, err := m.cursors[s].Get(nil, nil, Next)
+	switch {
+	case err == nil:
+		m.heads[s] = mergeHead{k, v}
+		heap.Fix(&m.h, 0)
+	case IsNotFound(err):
+		heap.Pop(&m.h)
+	default:
+		m.err = err
+		m.cur = -1
+		return err
+	}
+	return nil
+}
+
+// settle makes the top of the heap the current entry. In MergeDedup mode it
+// first moves the other sources past the same entry; the top, being the
+// first source to hold it, stays smallest afterwards.
+func (m *MergeCursor) settle() ([]byte, []byte, error) {
+	if m.h.Len() == 0 {
+		m.cur = -1
+		return nil, nil, ErrNotFoundError
+	}
+	top := m.h.idx[0]
+	if m.mode == MergeDedup && m.h.Len() > 1 {
+		heap.Pop(&m.h)
+		for m.h.Len() > 0 && m.compare(m.h.idx[0], top) == 0 {
+			if err := m.advance(); err != nil {
+				return nil, nil, err
+			}
+		}
+		heap.Push(&m.h, top)
+	}
+	m.cur = top
+	return m.heads[top].key, m.heads[top].value, nil
+}
+
+// compare orders the heads of sources a and b by key, then by value in
+// DupSort databases.
+func (m *MergeCursor) compare(a, b int) int {
+	ha, hb := &m.heads[a], &m.heads[b]
+	if c := m.txn.compareKeys(m.dbi, ha.key, hb.key); c != 0 || !m.dupSort {
+		return c
+	}
+	return m.txn.compareDupValues(m.dbi, ha.value, hb.value)
+}
+
+func (h *mergeHeap) Len() int { return len(h.idx) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.idx[i], h.idx[j]
+	if c := h.m.compare(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.idx[i], h.idx[j] = h.idx[j], h.idx[i] }
+
+func (h *mergeHeap) Push(x any) { h.idx = append(h.idx, x.(int)) }
+
+func (h *mergeHeap) Pop() any {
+	n := len(h.idx) - 1
+	x := h.idx[n]
+	h.idx = h.idx[:n]
+	return x
+}
@@ -1,44 +1,115 @@
 package gdbx
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"unsafe"
 
 	"github.com/Giulio2002/gdbx/spill"
 )
 
 // put inserts or updates a key-value pair.
-func (c *Cursor) put(key, value []byte, flags uint) error {
+func (c *Cursor) put(key, value []byte, flags uint) (err error) {
 	// Validate key size
 	maxKey := c.txn.env.MaxKeySize()
 	if len(key) > maxKey {
-		return NewError(ErrBadValSize)
+		return newSizeError(ErrBadKeySize, len(key), maxKey)
+	}
+	// The GC tree, IntegerKey too, is left to gdbx itself
+	if c.tree.Flags&treeFlagIntegerKey != 0 && !c.dupValues && c.dbi != FreeDBI {
+		if err := c.checkIntegerKey(key); err != nil {
+			return err
+		}
 	}
 
 	// Check if this is a DUPSORT database
 	isDupSort := c.tree.Flags&uint16(DupSort) != 0
 
+	// Validate value size: duplicates are stored as keys of the nested tree,
+	// so they share the key limit
+	if isDupSort && len(value) > maxKey {
+		return newDupSizeError(len(value), maxKey)
+	}
+	if len(value) > MaxDataSize {
+		return newSizeError(ErrBadValSize, len(value), MaxDataSize)
+	}
+	if limit := c.txn.env.valueLimit(c.dbi); limit > 0 && len(value) > limit {
+		return newSizeError(ErrBadValSize, len(value), limit)
+	}
+
+	// The values of a DupFixed database all have the width of the first one
+	if isDupSort && c.tree.Flags&uint16(DupFixed) != 0 && !c.dupValues {
+		switch {
+		case c.tree.DupfixSize == 0:
+			c.tree.DupfixSize = uint32(len(value))
+			defer func() {
+				if err != nil {
+					c.tree.DupfixSize = 0 // Set only once a value is stored
+				}
+			}()
+		case int(c.tree.DupfixSize) != len(value):
+			return NewError(ErrBadValSize)
+		}
+	}
+
 	// OPTIMIZATION: Append flag - position at end without binary search
 	if flags&Append != 0 {
+		intKey, intAppend := c.integerAppendKey(key, isDupSort)
+
+		// Sequential IntegerKey appends trust the cached max key and skip the last-key compare
+		if intAppend && c.txn.appendHintBelow(c.dbi, intKey) {
+			c.reset()
+			if err := c.positionAfterLast(); err != nil {
+				c.txn.setAppendHint(c.dbi, 0, false)
+				return err
+			}
+			if err := c.putAfterPosition(key, value, flags, false, false); err != nil {
+				c.txn.setAppendHint(c.dbi, 0, false)
+				return err
+			}
+			c.txn.setAppendHint(c.dbi, intKey, true)
+			return nil
+		}
+
 		c.reset()
 		exact, err := c.positionForAppend(key)
 		if err != nil {
 			return err
 		}
+		if exact && flags&NoOverwriteKey != 0 {
+			return NewError(ErrKeyExist)
+		}
 		// For append, we never have an exact match (we're appending new key)
 		// But if exact is true, it means key equals last key
 		if exact && !isDupSort {
 			// Update existing key
-			return c.putAfterPosition(key, value, flags, true, isDupSort)
+			err = c.putAfterPosition(key, value, flags, true, isDupSort)
+		} else {
+			err = c.putAfterPosition(key, value, flags, exact, isDupSort)
 		}
-		return c.putAfterPosition(key, value, flags, exact, isDupSort)
+		// The generic path verified key >= last key, which seeds the fast path
+		c.txn.setAppendHint(c.dbi, intKey, intAppend && err == nil)
+		return err
 	}
 
-	// Normal path: Search for the key position
-	c.reset()
-	exact, err := c.searchForInsert(key)
-	if err != nil && !IsNotFound(err) {
-		return err
+	// Any other insert may place a key above the cached max
+	c.txn.setAppendHint(c.dbi, 0, false)
+
+	// Normal path: Search for the key position, from the leaf of the last
+	// write when the key falls in it
+	exact, near := c.searchLastLeaf(key)
+	if !near {
+		c.reset()
+		exact, err = c.searchForInsert(key)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+	}
+
+	// NoOverwriteKey refuses a key with any value, duplicates included
+	if exact && flags&NoOverwriteKey != 0 {
+		return NewError(ErrKeyExist)
 	}
 
 	// Handle NoOverwrite flag
@@ -67,12 +138,18 @@ func (c *Cursor) put(key, value []byte, flags uint) error {
 		return c.putDupSort(key, value, flags)
 	}
 
+	// Writing back the stored value changes nothing, so skip the copy-on-write
+	if exact && flags&Reserve == 0 && c.overflowCap == 0 && c.valueUnchanged(value) {
+		return nil
+	}
+
 	// Determine if value is too large for inline storage
 	// Must check both: value exceeds maxVal OR combined node exceeds page capacity
 	maxVal := c.txn.env.MaxValSize()
 	pageCapacity := int(c.txn.env.pageSize) - 20 - 2 // pageSize - header - entry pointer
-	nodeSize := 8 + len(key) + len(value)            // header + key + value
-	isBig := len(value) > maxVal || nodeSize > pageCapacity
+	size := max(len(value), c.overflowCap)           // A capacity reservation forces overflow storage
+	nodeSize := 8 + len(key) + size                  // header + key + value
+	isBig := size > maxVal || nodeSize > pageCapacity || (exact && c.growsInReservation(value))
 
 	// Fast path: if updating a big value with another big value, try in-place update
 	if exact && isBig {
@@ -108,12 +185,48 @@ func (c *Cursor) put(key, value []byte, flags uint) error {
 	}
 
 	// If key exists, update it (non-DUPSORT case)
+	itemsBefore := c.tree.Items
 	if exact {
-		return c.updateNode(nodeData, overflowPgno)
+		err = c.updateNode(nodeData, overflowPgno)
+		c.assertItems(err, itemsBefore, "put: update")
+		return err
 	}
 
 	// Insert new node
-	return c.insertNode(nodeData, overflowPgno)
+	err = c.insertNode(nodeData, overflowPgno)
+	c.assertItems(err, itemsBefore+1, "put: insert")
+	return err
+}
+
+// valueUnchanged reports whether the value at the cursor equals value.
+// Values on overflow pages are only read when the sizes match.
+func (c *Cursor) valueUnchanged(value []byte) bool {
+	p := c.pages[c.top]
+	idx := int(c.indices[c.top])
+	if nodeGetFlagsDirect(p, idx)&nodeBig == 0 {
+		return bytes.Equal(nodeGetDataFast(p, idx), value)
+	}
+	size := nodeGetDataSizeDirect(p, idx)
+	if size != uint32(len(value)) {
+		return false
+	}
+	old, err := c.txn.getLargeData(nodeGetOverflowPgnoDirect(p, idx), size)
+	return err == nil && bytes.Equal(old, value)
+}
+
+// writeReserve returns an upper bound on the pages one put or delete of a
+// value of valueSize bytes can allocate: a copy of every page on the path and
+// a split at every level plus a new root, room for the same in a DUPSORT
+// sub-tree, and the value's overflow pages.
+func (c *Cursor) writeReserve(valueSize int) int {
+	n := 2*int(c.tree.Height) + 1
+	if c.tree.Flags&uint16(DupSort) != 0 {
+		n += 2*CursorStackSize + 1
+	}
+	if size := max(valueSize, c.overflowCap); size > 0 {
+		n += overflowPagesFor(size, int(c.txn.env.pageSize))
+	}
+	return n
 }
 
 // PutTree inserts or updates a sub-database entry in the main database.
@@ -123,7 +236,7 @@ func (c *Cursor) PutTree(key, treeData []byte, flags uint) error {
 	// Validate key size
 	maxKey := c.txn.env.MaxKeySize()
 	if len(key) > maxKey {
-		return NewError(ErrBadValSize)
+		return newSizeError(ErrBadKeySize, len(key), maxKey)
 	}
 
 	// Tree data should never be "big" (it's always 48 bytes)
@@ -139,7 +252,7 @@ func (c *Cursor) PutTree(key, treeData []byte, flags uint) error {
 	}
 
 	// Handle NoOverwrite flag
-	if exact && flags&NoOverwrite != 0 {
+	if exact && flags&(NoOverwrite|NoOverwriteKey) != 0 {
 		return NewError(ErrKeyExist)
 	}
 
@@ -202,7 +315,7 @@ func (c *Cursor) hasDupValueInSubPage(subPageData []byte, value []byte) bool {
 	// Check for DUPFIX format (P_DUPFIX = 0x20)
 	if (flags&uint16(pageDupfix) != 0) && dupfixKsize > 0 && dupfixKsize < 65535 {
 		// DUPFIX: fixed-size values after 20-byte header
-		numEntries := (len(subPageData) - pageHeaderSize) / dupfixKsize
+		numEntries := subPageDupfixCount(subPageData, dupfixKsize)
 
 		// Binary search
 		low, high := 0, numEntries-1
@@ -282,89 +395,43 @@ func (c *Cursor) hasDupValueInSubTree(treeData []byte, value []byte) bool {
 		return false
 	}
 
-	// Search in sub-tree (values are stored as keys in sub-tree)
-	// Use cached mmap data to avoid race with write txn remap
-	mmapData := c.txn.mmapData
-	pageSize := int(c.txn.pageSize)
-
-	currentPgno := rootPgno
-	for {
-		offset := int(currentPgno) * pageSize
-		if offset+pageSize > len(mmapData) {
-			return false
-		}
-		pageData := mmapData[offset : offset+pageSize]
-
-		flags := binary.LittleEndian.Uint16(pageData[10:])
-		lower := int(binary.LittleEndian.Uint16(pageData[12:]))
-		n := lower >> 1
-
-		isLeaf := flags&0x02 != 0
-
-		if !isLeaf {
-			// Branch page: binary search entries 1 to n-1
-			idx := 0
-			if n > 1 {
-				low, high := 1, n-1
-				for low <= high {
-					mid := (low + high) / 2
-					storedOffset := int(binary.LittleEndian.Uint16(pageData[20+mid*2:]))
-					nodeOffset := storedOffset + 20
-					keySize := int(binary.LittleEndian.Uint16(pageData[nodeOffset+6:]))
-					keyStart := nodeOffset + 8
-					if keyStart+keySize > len(pageData) {
-						return false
-					}
-					midKey := pageData[keyStart : keyStart+keySize]
-
-					cmp := c.txn.compareDupValues(c.dbi, value, midKey)
-					if cmp < 0 {
-						high = mid - 1
-					} else if cmp > 0 {
-						low = mid + 1
-					} else {
-						idx = mid
-						goto descend
-					}
-				}
-				idx = low - 1
-			}
-		descend:
-			// Get child pgno
-			storedOffset := int(binary.LittleEndian.Uint16(pageData[20+idx*2:]))
-			nodeOffset := storedOffset + 20
-			currentPgno = pgno(binary.LittleEndian.Uint32(pageData[nodeOffset:]))
-			continue
-		}
-
-		// Leaf page: binary search
-		if n == 0 {
-			return false
-		}
-
-		low, high := 0, n-1
+	// Search in sub-tree (values are stored as keys in sub-tree), reading
+	// pages through the dirty list so this transaction's changes are seen
+	compare := func(a, b []byte) int { return c.txn.compareDupValues(c.dbi, a, b) }
+	var buf page
+	p := c.txn.fillPageHotPath(rootPgno, &buf)
+	for p.Data != nil && p.isBranchFast() {
+		// Branch page: binary search entries 1 to n-1
+		n := p.numEntriesFast()
+		low, high := 1, n-1
 		for low <= high {
 			mid := (low + high) / 2
-			storedOffset := int(binary.LittleEndian.Uint16(pageData[20+mid*2:]))
-			nodeOffset := storedOffset + 20
-			keySize := int(binary.LittleEndian.Uint16(pageData[nodeOffset+6:]))
-			keyStart := nodeOffset + 8
-			if keyStart+keySize > len(pageData) {
-				return false
-			}
-			midKey := pageData[keyStart : keyStart+keySize]
-
-			cmp := c.txn.compareDupValues(c.dbi, value, midKey)
-			if cmp < 0 {
+			if compare(value, nodeGetKeyFast(p, mid)) < 0 {
 				high = mid - 1
-			} else if cmp > 0 {
-				low = mid + 1
 			} else {
-				return true
+				low = mid + 1
 			}
 		}
+		p = c.txn.fillPageHotPath(nodeGetChildPgnoFast(p, low-1), &buf)
+	}
+	if p.Data == nil {
 		return false
 	}
+
+	// Leaf page: binary search
+	low, high := 0, p.numEntriesFast()-1
+	for low <= high {
+		mid := (low + high) / 2
+		cmp := compare(value, subTreeKey(p, mid))
+		if cmp < 0 {
+			high = mid - 1
+		} else if cmp > 0 {
+			low = mid + 1
+		} else {
+			return true
+		}
+	}
+	return false
 }
 
 // positionForAppend positions the cursor at the end of the tree for append operations.
@@ -428,6 +495,121 @@ func (c *Cursor) positionForAppend(key []byte) (bool, error) {
 	}
 }
 
+// checkIntegerKey enforces the key width of an IntegerKey table, as libmdbx
+// does: keys are uint32 or uint64, 4 or 8 bytes, and all as wide as the keys
+// already stored, so the first key put fixes the width.
+func (c *Cursor) checkIntegerKey(key []byte) error {
+	if len(key) != 4 && len(key) != 8 {
+		return newKeyWidthError(len(key), 0)
+	}
+	if c.tree.Root == invalidPgno || c.tree.Items == 0 {
+		return nil
+	}
+	root, err := c.txn.getPage(c.tree.Root)
+	if err != nil {
+		return err
+	}
+	// Entry 0 of a branch page has no key
+	idx := 0
+	if root.isBranchFast() {
+		idx = 1
+	}
+	if root.numEntriesFast() <= idx {
+		return nil
+	}
+	if width := len(nodeGetKeyFast(root, idx)); width != len(key) {
+		return newKeyWidthError(len(key), width)
+	}
+	return nil
+}
+
+// appendHint caches the largest key appended to an IntegerKey DBI in the
+// current write transaction, so sequential appends can skip the last-key compare.
+type appendHint struct {
+	maxKey uint64 // Key read big-endian, which orders like bytes.Compare
+	valid  bool
+}
+
+// integerAppendKey returns the key's value if the put qualifies for the
+// IntegerKey append fast path: an 8-byte key in a non-DUPSORT IntegerKey
+// table that uses the default comparator.
+func (c *Cursor) integerAppendKey(key []byte, isDupSort bool) (uint64, bool) {
+	if isDupSort || len(key) != 8 || c.tree.Flags&uint16(IntegerKey) == 0 {
+		return 0, false
+	}
+	c.txn.cacheComparator(c.dbi)
+	if int(c.dbi) >= len(c.txn.dbiUsesDefaultCmp) || !c.txn.dbiUsesDefaultCmp[c.dbi] {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(key), true
+}
+
+// appendHintBelow returns true if key is known to sort after every key in the DBI.
+func (txn *Txn) appendHintBelow(dbi DBI, key uint64) bool {
+	if int(dbi) >= len(txn.appendHints) {
+		return false
+	}
+	h := txn.appendHints[dbi]
+	return h.valid && key > h.maxKey
+}
+
+// setAppendHint records the largest appended key for a DBI, or forgets it if !valid.
+func (txn *Txn) setAppendHint(dbi DBI, key uint64, valid bool) {
+	if int(dbi) >= len(txn.appendHints) {
+		if !valid || int(dbi) >= len(txn.trees) {
+			return
+		}
+		if cap(txn.appendHints) >= len(txn.trees) {
+			txn.appendHints = txn.appendHints[:len(txn.trees)]
+			clear(txn.appendHints)
+		} else {
+			txn.appendHints = make([]appendHint, len(txn.trees))
+		}
+	}
+	txn.appendHints[dbi] = appendHint{maxKey: key, valid: valid}
+}
+
+// positionAfterLast positions the cursor after the last entry of the tree
+// without reading any key. The caller must already know the new key sorts
+// after every existing key.
+func (c *Cursor) positionAfterLast() error {
+	if c.tree.isEmpty() {
+		return nil
+	}
+
+	// Walk the rightmost path using embedded buffers, like searchForInsert
+	c.top = -1
+	c.dirtyMask = 0
+	currentPgno := c.tree.Root
+	for {
+		if c.top >= CursorStackSize-1 {
+			return ErrCursorFullError
+		}
+		c.top++
+		level := c.top
+
+		buf := &c.pagesBuf[level]
+		p := c.txn.fillPageHotPath(currentPgno, buf)
+		c.pages[level] = p
+		if p != buf {
+			c.dirtyMask |= uint32(1) << level
+		}
+
+		n := p.numEntriesFast()
+		if p.isLeafFast() {
+			c.indices[level] = uint16(n)
+			c.state = cursorPointing
+			return nil
+		}
+		if n == 0 {
+			return ErrCorruptedError
+		}
+
+		c.indices[level] = uint16(n - 1)
+		currentPgno = c.getChildPgno(p, n-1)
+	}
+}
+
 // putAfterPosition completes the put operation after cursor is positioned.
 // This is used by the append optimization path.
 func (c *Cursor) putAfterPosition(key, value []byte, flags uint, exact, isDupSort bool) error {
@@ -440,8 +622,9 @@ func (c *Cursor) putAfterPosition(key, value []byte, flags uint, exact, isDupSor
 	// Must check both: value exceeds maxVal OR combined node exceeds page capacity
 	maxVal := c.txn.env.MaxValSize()
 	pageCapacity := int(c.txn.env.pageSize) - 20 - 2 // pageSize - header - entry pointer
-	nodeSize := 8 + len(key) + len(value)            // header + key + value
-	isBig := len(value) > maxVal || nodeSize > pageCapacity
+	size := max(len(value), c.overflowCap)           // A capacity reservation forces overflow storage
+	nodeSize := 8 + len(key) + size                  // header + key + value
+	isBig := size > maxVal || nodeSize > pageCapacity || (exact && c.growsInReservation(value))
 
 	// Fast path: if updating a big value with another big value, try in-place update
 	if exact && isBig {
@@ -517,7 +700,7 @@ func (c *Cursor) checkAppendDup(value []byte) error {
 	// Compare value with last value
 	cmp := c.txn.compareDupValues(c.dbi, value, lastValue)
 	if cmp < 0 {
-		return NewError(ErrKeyMismatch)
+		return WrapError(ErrKeyMismatch, &AppendDupError{Last: bytes.Clone(lastValue)})
 	}
 	return nil
 }
@@ -536,11 +719,11 @@ func (c *Cursor) getLastDupValueSubPage(p *page, idx int) []byte {
 
 	// Check for DUPFIX format
 	if flags&uint16(pageDupfix) != 0 && dupfixKsize > 0 && dupfixKsize < 65535 {
-		numValues := (len(data) - pageHeaderSize) / int(dupfixKsize)
+		numValues := subPageDupfixCount(data, int(dupfixKsize))
 		if numValues == 0 {
 			return nil
 		}
-		// Last value is at the end
+		// The last value follows the others, before any room left free
 		start := pageHeaderSize + (numValues-1)*int(dupfixKsize)
 		return data[start : start+int(dupfixKsize)]
 	}
@@ -584,53 +767,21 @@ func (c *Cursor) getLastDupValueSubTree(p *page, idx int) []byte {
 		return nil
 	}
 
-	// Navigate to rightmost leaf
-	// Use cached mmap data to avoid race with write txn remap
-	mmapData := c.txn.mmapData
-	pageSize := int(c.txn.pageSize)
-
-	currentPgno := rootPgno
-	for {
-		offset := int(currentPgno) * pageSize
-		if offset+pageSize > len(mmapData) {
-			return nil
-		}
-		pageData := mmapData[offset : offset+pageSize]
-
-		flags := binary.LittleEndian.Uint16(pageData[10:])
-		lower := int(binary.LittleEndian.Uint16(pageData[12:]))
-		n := lower >> 1
-
-		isLeaf := flags&0x02 != 0
-
-		if !isLeaf {
-			// Branch page: go to rightmost child
-			if n == 0 {
-				return nil
-			}
-			lastIdx := n - 1
-			storedOffset := int(binary.LittleEndian.Uint16(pageData[20+lastIdx*2:]))
-			nodeOffset := storedOffset + 20
-			currentPgno = pgno(binary.LittleEndian.Uint32(pageData[nodeOffset:]))
-			continue
-		}
-
-		// Leaf page: get last entry
+	// Navigate to rightmost leaf, reading pages through the dirty list so
+	// this transaction's changes are seen
+	var buf page
+	sub := c.txn.fillPageHotPath(rootPgno, &buf)
+	for sub.Data != nil && sub.isBranchFast() {
+		n := sub.numEntriesFast()
 		if n == 0 {
 			return nil
 		}
-		lastIdx := n - 1
-		storedOffset := int(binary.LittleEndian.Uint16(pageData[20+lastIdx*2:]))
-		nodeOffset := storedOffset + 20
-		keySize := int(binary.LittleEndian.Uint16(pageData[nodeOffset+6:]))
-		keyStart := nodeOffset + 8
-		keyEnd := keyStart + keySize
-		if keyEnd > len(pageData) {
-			return nil
-		}
-		// Use three-index slice to cap capacity at length
-		return pageData[keyStart:keyEnd:keyEnd]
+		sub = c.txn.fillPageHotPath(nodeGetChildPgnoFast(sub, n-1), &buf)
 	}
+	if sub.Data == nil || sub.numEntriesFast() == 0 {
+		return nil
+	}
+	return subTreeKey(sub, sub.numEntriesFast()-1)
 }
 
 // putDupSort handles insertion of a duplicate value for DUPSORT databases.
@@ -639,6 +790,11 @@ func (c *Cursor) putDupSort(key, value []byte, flags uint) error {
 		return ErrCorruptedError
 	}
 
+	// Appends to a sub-tree go to its right edge, which checks the order
+	if flags&AppendDup != 0 && nodeGetFlagsDirect(c.pages[c.top], int(c.indices[c.top]))&nodeTree != 0 {
+		return c.appendDupSubTree(c.pages[c.top], int(c.indices[c.top]), value)
+	}
+
 	// Handle AppendDup flag - value must be >= last value for this key
 	if flags&AppendDup != 0 {
 		if err := c.checkAppendDup(value); err != nil {
@@ -773,15 +929,20 @@ func (c *Cursor) putDupSubPage(p *page, idx int, key, value []byte) error {
 	totalNodeSize := nodeSize + len(key) + len(subPageData)
 	if totalNodeSize > c.txn.env.LeafNodeMax() {
 		// Convert to sub-tree
+		c.dropDupSubPage()
 		return c.convertToSubTree(p, idx, key, newValues)
 	}
 
 	// Build new node
 	nodeData := c.buildDupNode(key, subPageData)
 
-	// Update the node
-	if p.updateEntry(idx, nodeData) {
+	// Update the node; if that fails, the page may have room only in holes
+	// left by earlier updates, so compact it and retry
+	if p.updateEntry(idx, nodeData) || (p.compactWithBuf(c.txn.compactBuf[:]) > 0 && p.updateEntry(idx, nodeData)) {
 		c.pages[c.top] = p
+		c.compactSubPageLeaf(p)
+		c.trackDupSubPage(insertPos, 1, len(newValues))
+		c.assertSubPage(p, idx, len(newValues), "put: sub-page")
 		// Increment Items for the new duplicate value (for mdbx compatibility,
 		// Items counts total data items, not just unique keys)
 		c.tree.Items++
@@ -789,25 +950,8 @@ func (c *Cursor) putDupSubPage(p *page, idx int, key, value []byte) error {
 		return nil
 	}
 
-	// UpdateEntry failed - might be due to fragmentation (holes from previous updates).
-	// Try compacting the page and retrying.
-	pageSize := uint16(c.txn.env.pageSize)
-	tempData := make([]byte, pageSize)
-	tempPage := &page{Data: tempData}
-	p.compactTo(tempPage, pageSize)
-
-	// Try update on compacted page
-	if tempPage.updateEntry(idx, nodeData) {
-		// Copy compacted page back
-		copy(p.Data, tempPage.Data)
-		c.pages[c.top] = p
-		// Increment Items for the new duplicate value
-		c.tree.Items++
-		c.markTreeDirty()
-		return nil
-	}
-
 	// Still not enough space on page - need to split
+	c.dropDupSubPage()
 	p.removeEntry(idx)
 	err = c.insertNodeAt(p, idx, nodeData, 0, true)
 	if err == nil {
@@ -826,45 +970,34 @@ func (c *Cursor) convertToSubTree(p *page, idx int, key []byte, values [][]byte)
 		return err
 	}
 
-	// Initialize as a leaf page
+	// Initialize as a leaf page, a DUPFIX one for fixed-size values
 	pageSize := uint16(c.txn.env.pageSize)
-	subRoot.init(subRootPgno, pageLeaf, pageSize)
-	subRoot.header().Txnid = txnid(c.txn.txnID)
-
-	// Check if this is DUPFIXED (fixed-size values)
 	isDupFixed := c.tree.Flags&uint16(DupFixed) != 0
 	var dupfixSize uint32 = 0
 	if isDupFixed && len(values) > 0 {
 		dupfixSize = uint32(len(values[0]))
-		// Set DUPFIX flag on the page
-		subRoot.header().Flags |= pageDupfix
-		subRoot.header().DupfixKsize = uint16(dupfixSize)
+		subRoot.initDupfix(subRootPgno, int(dupfixSize), pageSize)
+	} else {
+		subRoot.init(subRootPgno, pageLeaf, pageSize)
 	}
+	subRoot.header().Txnid = txnid(c.txn.txnID)
 
 	// Insert all values into the sub-tree root page
 	// In a DUPSORT sub-tree, each value becomes a key with empty data
 	for i, val := range values {
+		var ok bool
 		if isDupFixed {
-			// For DUPFIX pages, values are stored contiguously after header
-			// without node headers or entry indices
-			offset := pageHeaderSize + i*int(dupfixSize)
-			copy(subRoot.Data[offset:], val)
+			ok = subRoot.insertDupfix(i, val)
 		} else {
 			// Build a leaf node for the sub-tree
 			// In sub-trees: the "key" is the duplicate value, data is empty
-			subNode := c.buildSubTreeNode(val)
-			if !subRoot.insertEntry(i, subNode) {
-				// Page is full - this shouldn't happen for a fresh page
-				// with the same data that fit in a sub-page
-				return NewError(ErrPageFull)
-			}
+			ok = subRoot.insertEntry(i, c.buildSubTreeNode(val))
+		}
+		if !ok {
+			// Page is full - this shouldn't happen for a fresh page
+			// with the same data that fit in a sub-page
+			return NewError(ErrPageFull)
 		}
-	}
-
-	// For DUPFIX pages, manually set lower (no entries pointer) and upper
-	if isDupFixed {
-		subRoot.header().Lower = 0 // No entry pointers for DUPFIX
-		subRoot.header().Upper = pageSize - pageHeaderSize - uint16(len(values))*uint16(dupfixSize)
 	}
 
 	// Create the tree_t structure for the sub-tree
@@ -884,16 +1017,19 @@ func (c *Cursor) convertToSubTree(p *page, idx int, key []byte, values [][]byte)
 	// Build new node with N_DUP | N_TREE flags (serializes tree directly, no allocation)
 	nodeData := c.buildNodeWithDupTree(key, &subTree)
 
+	// The values include the one being put, which the main tree counts too
+	c.tree.Items++
+	c.tree.LeafPages++ // Sub-tree adds a leaf page
+
 	// Replace the existing node
 	if p.updateEntry(idx, nodeData) {
 		c.pages[c.top] = p
-		c.tree.LeafPages++ // Sub-tree adds a leaf page
 		c.markTreeDirty()
 		return nil
 	}
 
 	// Not enough space - need to split the main tree page
-	// This is an update (key already exists), so don't increment Items
+	// This is an update (key already exists), so insertNodeAt doesn't count it
 	p.removeEntry(idx)
 	return c.insertNodeAt(p, idx, nodeData, 0, true)
 }
@@ -1022,6 +1158,7 @@ func (c *Cursor) putDupSubTree(key, value []byte) error {
 		dbi:       c.dbi,
 		txn:       c.txn,
 		tree:      subTree,
+		dupValues: true,
 	}
 
 	// Search for the insert position in the sub-tree
@@ -1036,13 +1173,18 @@ func (c *Cursor) putDupSubTree(key, value []byte) error {
 		return nil
 	}
 
-	// Build a node for the sub-tree (key=value, data=empty)
-	subNode := c.buildSubTreeNode(value)
-
 	// Insert into sub-tree
-	// Note: insertNode will increment subTree.Items via insertNodeAt
-	if err := subCursor.insertNode(subNode, 0); err != nil {
-		return err
+	// Note: insertNode and insertDupfix increment subTree.Items
+	if subTree.DupfixSize != 0 {
+		if err := subCursor.insertDupfix(value); err != nil {
+			return err
+		}
+	} else {
+		// Build a node for the sub-tree (key=value, data=empty)
+		subNode := c.buildSubTreeNode(value)
+		if err := subCursor.insertNode(subNode, 0); err != nil {
+			return err
+		}
 	}
 
 	// Update sub-tree metadata (Items already incremented by insertNode)
@@ -1072,6 +1214,13 @@ func (c *Cursor) putDupSubTree(key, value []byte) error {
 	return NewError(ErrPageFull)
 }
 
+// subPageDupfixCount returns the number of values of a DUPFIX sub-page with
+// values of ksize bytes. As on any page it is lower/2: libmdbx leaves room
+// for more values after them, so it can't be taken from the sub-page length.
+func subPageDupfixCount(data []byte, ksize int) int {
+	return min(int(binary.LittleEndian.Uint16(data[12:]))/2, (len(data)-pageHeaderSize)/ksize)
+}
+
 // parseSubPageValues extracts values from an inline sub-page.
 // libmdbx format: 20-byte page header, entry pointers at offset 20, 8-byte node headers.
 // Uses scratch buffer (c.valuesBuf) for small numbers of values to avoid allocation.
@@ -1088,7 +1237,7 @@ func (c *Cursor) parseSubPageValues(data []byte) ([][]byte, error) {
 	// Check for DUPFIX format (fixed-size values)
 	if flags&uint16(pageDupfix) != 0 && dupfixKsize > 0 && dupfixKsize < 65535 {
 		// Fixed-size values stored contiguously after header
-		numValues := (len(data) - 20) / int(dupfixKsize)
+		numValues := subPageDupfixCount(data, int(dupfixKsize))
 		// Use scratch buffer if possible
 		var values [][]byte
 		if numValues <= len(c.valuesBuf) {
@@ -1294,11 +1443,17 @@ func (c *Cursor) searchForInsert(key []byte) (bool, error) {
 			}
 
 			// Check for exact match using allocation-free method
-			foundKey := nodeGetKeyDirect(p, idx)
+			var foundKey []byte
+			if p.isDupfix() {
+				foundKey = p.dupfixKey(idx)
+			} else {
+				foundKey = nodeGetKeyDirect(p, idx)
+			}
 			if foundKey == nil {
 				return false, ErrCorruptedError
 			}
-			cmp := c.txn.compareKeys(c.dbi, key, foundKey)
+			compare, _ := c.keyComparator()
+			cmp := compare(key, foundKey)
 
 			c.state = cursorPointing
 			return cmp == 0, nil
@@ -1309,6 +1464,74 @@ func (c *Cursor) searchForInsert(key []byte) (bool, error) {
 	}
 }
 
+// searchLastLeaf positions the cursor at key within the leaf its stack
+// already holds, as a write left it, and reports whether key is present.
+// Keys put close together in one transaction mostly land on the same leaf,
+// so each put after the first skips the descent. It applies only while the
+// stack is still the tree's path to that leaf: every page on it the current
+// dirty copy of its page number, each the child its parent's index points
+// to, and key between the separators that bound the leaf. Otherwise near is
+// false and the cursor is untouched, for searchForInsert to descend.
+func (c *Cursor) searchLastLeaf(key []byte) (exact, near bool) {
+	top := int(c.top)
+	if top < 0 || c.dupValues || c.tree.isEmpty() || top != int(c.tree.Height)-1 ||
+		c.mmapVersion != c.txn.env.mmapVersion || c.pages[0].pageNo() != c.tree.Root {
+		return false, false
+	}
+	for i := 0; i <= top; i++ {
+		p := c.pages[i]
+		if c.txn.dirtyTracker.get(p.pageNo()) != p {
+			return false, false
+		}
+		if i == top {
+			break
+		}
+		idx := int(c.indices[i])
+		if !p.isBranchFast() || idx >= p.numEntriesFast() || c.getChildPgno(p, idx) != c.pages[i+1].pageNo() {
+			return false, false
+		}
+	}
+	leaf := c.pages[top]
+	if !leaf.isLeafFast() || leaf.isDupfix() {
+		return false, false
+	}
+
+	// The leaf holds the keys from the separator before it up to the one
+	// after it, each found at the deepest level that has one
+	compare, _ := c.keyComparator()
+	for i := top - 1; i >= 0; i-- {
+		if idx := int(c.indices[i]); idx > 0 {
+			if compare(key, nodeGetKeyDirect(c.pages[i], idx)) < 0 {
+				return false, false
+			}
+			break
+		}
+	}
+	for i := top - 1; i >= 0; i-- {
+		if idx := int(c.indices[i]) + 1; idx < c.pages[i].numEntriesFast() {
+			if compare(key, nodeGetKeyDirect(c.pages[i], idx)) >= 0 {
+				return false, false
+			}
+			break
+		}
+	}
+
+	// As reset, but keeping the stack
+	c.state = cursorPointing
+	c.afterDelete = false
+	c.dirtyMask = uint32(1)<<(top+1) - 1
+	c.clearDupState()
+	c.dup.atFirst = false
+	c.dup.atLast = false
+
+	idx := c.searchPage(leaf, key)
+	c.indices[top] = uint16(idx)
+	if idx >= leaf.numEntriesFast() {
+		return false, true
+	}
+	return compare(key, nodeGetKeyDirect(leaf, idx)) == 0, true
+}
+
 // buildNode constructs the node data for insertion.
 // Uses cursor's scratch buffer when possible to avoid allocation.
 func (c *Cursor) buildNode(key, value []byte, isBig bool) ([]byte, pgno, error) {
@@ -1434,6 +1657,7 @@ func (c *Cursor) insertNodeAt(p *page, idx int, nodeData []byte, overflowPgno pg
 		if !isUpdate {
 			c.tree.Items++
 		}
+		c.assertPage(p, "insert")
 		// Note: LeafPages counts NUMBER of leaf pages, not entries.
 		// It's only incremented when new pages are created (createRoot, splitAndInsert).
 		return nil
@@ -1443,6 +1667,62 @@ func (c *Cursor) insertNodeAt(p *page, idx int, nodeData []byte, overflowPgno pg
 	return c.splitAndInsert(p, idx, nodeData, overflowPgno, isUpdate)
 }
 
+// insertDupfix inserts key at the cursor position of a DUPFIXED sub-tree,
+// whose leaves are DUPFIX pages, splitting the leaf if it is full.
+func (c *Cursor) insertDupfix(key []byte) error {
+	if c.top < 0 {
+		return ErrCorruptedError
+	}
+	p, err := c.touchPage()
+	if err != nil {
+		return err
+	}
+	idx := int(c.indices[c.top])
+	if len(key) != int(p.header().DupfixKsize) {
+		return NewError(ErrBadValSize)
+	}
+	if p.insertDupfix(idx, key) {
+		c.pages[c.top] = p
+		c.tree.Items++
+		return nil
+	}
+
+	c.txn.counters.Splits++
+
+	// Split in half, or start a new page when appending
+	numEntries := p.numEntries()
+	splitIdx := numEntries / 2
+	if idx == numEntries {
+		splitIdx = numEntries
+	}
+
+	newPgno, newPage, err := c.allocatePage()
+	if err != nil {
+		return err
+	}
+	newPage.initDupfix(newPgno, len(key), uint16(c.txn.env.pageSize))
+	newPage.header().Txnid = txnid(c.txn.txnID)
+	for i := splitIdx; i < numEntries; i++ {
+		newPage.insertDupfix(i-splitIdx, p.dupfixKey(i))
+	}
+	for i := numEntries - 1; i >= splitIdx; i-- {
+		p.removeDupfix(i)
+	}
+
+	if idx < splitIdx {
+		p.insertDupfix(idx, key)
+	} else {
+		newPage.insertDupfix(idx-splitIdx, key)
+	}
+
+	c.tree.LeafPages++
+	c.tree.Items++
+	c.tree.ModTxnid = txnid(c.txn.txnID)
+
+	// Insert separator (first key of the new page) into parent
+	return c.insertIntoParent(p.pageNo(), newPgno, newPage.dupfixKey(0))
+}
+
 // createRoot creates a new root page for an empty tree.
 func (c *Cursor) createRoot(nodeData []byte, overflowPgno pgno) error {
 	// Allocate a new page
@@ -1487,6 +1767,8 @@ func (c *Cursor) createRoot(nodeData []byte, overflowPgno pgno) error {
 func (c *Cursor) splitAndInsert(p *page, idx int, nodeData []byte, overflowPgno pgno, isUpdate bool) error {
 	pageSize := c.txn.env.pageSize
 
+	c.txn.counters.Splits++
+
 	// Find split point
 	splitIdx := p.splitPoint(len(nodeData), idx)
 
@@ -1553,11 +1835,14 @@ func (c *Cursor) splitAndInsert(p *page, idx int, nodeData []byte, overflowPgno
 	} else {
 		c.tree.BranchPages++
 	}
-	if !isUpdate {
+	// A branch split inserts a separator, not an item
+	if !isUpdate && p.isLeaf() {
 		c.tree.Items++
 	}
 	c.tree.ModTxnid = txnid(c.txn.txnID)
 
+	c.assertSplit(p, newPage)
+
 	// Get the separator key (first key of new page) - allocation-free
 	sepKey := nodeGetKeyDirect(newPage, 0)
 	if sepKey == nil {
@@ -1570,6 +1855,10 @@ func (c *Cursor) splitAndInsert(p *page, idx int, nodeData []byte, overflowPgno
 
 // insertIntoParent inserts a separator key into the parent branch page.
 func (c *Cursor) insertIntoParent(leftPgno, rightPgno pgno, sepKey []byte) error {
+	if c.txn.onSplit != nil && !c.dupValues {
+		c.txn.onSplit(c.dbi, uint32(leftPgno), uint32(rightPgno), sepKey)
+	}
+
 	// If we're at the root, create a new root
 	if c.top == 0 {
 		return c.createNewRoot(leftPgno, rightPgno, sepKey)
@@ -1600,10 +1889,19 @@ func (c *Cursor) insertIntoParent(leftPgno, rightPgno pgno, sepKey []byte) error
 	return c.splitAndInsert(parentPage, idx, branchNode, rightPgno, false)
 }
 
+// errTreeTooDeep is wrapped in the ErrCursorFull a write returns when a
+// root split would make a tree deeper than a cursor can follow.
+var errTreeTooDeep = errors.New("tree would exceed CursorStackSize levels")
+
 // createNewRoot creates a new root with two children.
 func (c *Cursor) createNewRoot(leftPgno, rightPgno pgno, sepKey []byte) error {
 	pageSize := c.txn.env.pageSize
 
+	// A cursor couldn't descend a tree one level deeper
+	if int(c.tree.Height) >= CursorStackSize {
+		return WrapError(ErrCursorFull, errTreeTooDeep)
+	}
+
 	// Allocate new root page
 	rootPgno, rootPage, err := c.allocatePage()
 	if err != nil {
@@ -1630,10 +1928,38 @@ func (c *Cursor) createNewRoot(leftPgno, rightPgno pgno, sepKey []byte) error {
 	c.tree.ModTxnid = txnid(c.txn.txnID)
 
 	c.markTreeDirty()
+	c.assertHeight("split: new root")
 
 	return nil
 }
 
+// collapseRoot replaces a root branch page that has a single child with that
+// child, repeating while the new root is again a single-child branch.
+// Height drops by one per removed level. The cursor is left at the new root.
+func (c *Cursor) collapseRoot(root *page) error {
+	for !root.isLeaf() && root.numEntries() == 1 {
+		childPgno := c.getChildPgno(root, 0)
+		child, err := c.txn.getPage(childPgno)
+		if err != nil {
+			return err
+		}
+		c.tree.Root = childPgno
+		c.tree.Height--
+		// Free the old root
+		c.txn.freePage(root.pageNo())
+		if c.tree.BranchPages > 0 {
+			c.tree.BranchPages--
+		}
+		root = child
+	}
+	c.assertHeight("delete: collapse root")
+
+	// The old root pages are freed, so the cursor stack is invalid.
+	// Restart it at the new root (a leaf, or a branch the caller descends).
+	c.top = -1
+	return c.pushPageByPgno(c.tree.Root, 0)
+}
+
 // buildBranchNode builds a branch node pointing to a child page.
 // Uses cursor's branch scratch buffer when possible to avoid allocation.
 func (c *Cursor) buildBranchNode(key []byte, childPgno pgno) []byte {
@@ -1667,6 +1993,11 @@ func (c *Cursor) del(flags uint) error {
 
 	// Check if this is a DUPSORT database with duplicate values
 	isDupSort := c.tree.Flags&uint16(DupSort) != 0
+	if isDupSort {
+		if err := c.syncDupSubPage(); err != nil {
+			return err
+		}
+	}
 
 	// NoDupData or AllDups flag means delete all values for the key (the entire node)
 	if flags&NoDupData != 0 || flags&AllDups != 0 {
@@ -1682,8 +2013,15 @@ func (c *Cursor) del(flags uint) error {
 	return c.delNode()
 }
 
-// delNode deletes the entire node at the current cursor position.
+// delNode deletes the entire node at the current cursor position, freeing
+// the pages of a DupSort sub-tree it holds.
 func (c *Cursor) delNode() error {
+	return c.removeNode(true)
+}
+
+// removeNode deletes the node at the cursor position; with freeTree it
+// frees the pages of the sub-tree the node refers to.
+func (c *Cursor) removeNode(freeTree bool) error {
 	// Get dirty copy of the page
 	p, err := c.touchPage()
 	if err != nil {
@@ -1711,6 +2049,11 @@ func (c *Cursor) delNode() error {
 				if len(data) >= 40 {
 					itemsToDecrement = binary.LittleEndian.Uint64(data[32:40])
 				}
+				if freeTree {
+					if err := c.freeSubTree(pgno(binary.LittleEndian.Uint32(data[8:12]))); err != nil {
+						return err
+					}
+				}
 			}
 		} else if oldFlags&nodeDup != 0 {
 			// N_DUP: count from sub-page header (lower field / 2)
@@ -1722,6 +2065,9 @@ func (c *Cursor) delNode() error {
 		}
 	}
 
+	c.assertRemovable(itemsToDecrement)
+	itemsBefore := c.tree.Items
+
 	// Remove the entry
 	if !p.removeEntry(idx) {
 		return ErrCorruptedError
@@ -1729,6 +2075,7 @@ func (c *Cursor) delNode() error {
 
 	c.pages[c.top] = p
 	c.tree.Items -= itemsToDecrement
+	c.assertPage(p, "delete")
 	c.tree.ModTxnid = txnid(c.txn.txnID)
 
 	// Handle underflow (if page becomes too empty)
@@ -1745,8 +2092,8 @@ func (c *Cursor) delNode() error {
 			c.tree.Root = invalidPgno
 			c.tree.Height = 0
 			c.tree.LeafPages = 0
-			// Add root page to free list
-			c.txn.freePages = append(c.txn.freePages, p.pageNo())
+			// Free the root page
+			c.txn.freePage(p.pageNo())
 			c.state = cursorEOF
 			c.afterDelete = false
 		} else {
@@ -1793,6 +2140,7 @@ func (c *Cursor) delNode() error {
 	}
 
 	c.markTreeDirty()
+	c.assertItems(nil, itemsBefore-itemsToDecrement, "delete")
 
 	return nil
 }
@@ -1804,8 +2152,8 @@ func (c *Cursor) freeEmptyPage(emptyPage *page) error {
 		return nil
 	}
 
-	// Add page to free list
-	c.txn.freePages = append(c.txn.freePages, emptyPage.pageNo())
+	// Free the page
+	c.txn.freePage(emptyPage.pageNo())
 
 	// Update page counts
 	if emptyPage.isLeaf() {
@@ -1836,44 +2184,23 @@ func (c *Cursor) freeEmptyPage(emptyPage *page) error {
 
 	c.pages[c.top] = parentPage
 
-	// If parent is now empty too and it's the root, tree shrinks
+	// If parent is now empty too, remove it as well
 	if parentPage.numEntries() == 0 {
-		if c.top == 0 {
-			// Root is now empty - tree is empty
-			c.tree.Root = invalidPgno
-			c.tree.Height = 0
-			// Add the root to free list
-			c.txn.freePages = append(c.txn.freePages, parentPage.pageNo())
-			if c.tree.BranchPages > 0 {
-				c.tree.BranchPages--
-			}
-		}
-		// Note: If non-root parent is empty, we should recursively handle it,
-		// but that's a complex rebalancing operation. For now, leave it empty
-		// and let compaction handle it later.
-	} else if parentPage.numEntries() == 1 && c.top == 0 && !parentPage.isLeaf() {
-		// Root branch with only one child - can collapse tree height
-		// Get the only remaining child and make it the new root
-		childPgno := c.getChildPgno(parentPage, 0)
-		c.tree.Root = childPgno
-		c.tree.Height--
-		// Add old root to free list
-		c.txn.freePages = append(c.txn.freePages, parentPage.pageNo())
+		if c.top > 0 {
+			// An empty branch would leave a level without leaves below it
+			return c.freeEmptyPage(parentPage)
+		}
+		// Root is now empty - tree is empty
+		c.tree.Root = invalidPgno
+		c.tree.Height = 0
+		// Free the root
+		c.txn.freePage(parentPage.pageNo())
 		if c.tree.BranchPages > 0 {
 			c.tree.BranchPages--
 		}
-
-		// CRITICAL: Re-initialize cursor to point at the new root.
-		// The old root (branch page) is now freed, cursor stack is invalid.
-		// Push the new root (the remaining child) onto the cursor stack.
-		c.top = -1 // Reset stack
-		if err := c.pushPageByPgno(childPgno, 0); err != nil {
-			return err
-		}
-		// After collapse, the cursor is at the new root (which is a leaf or branch).
-		// If it's a leaf, index 0 is the first entry.
-		// If it's still a branch (multi-level collapse), caller will handle descent.
-		return nil
+		c.assertHeight("delete: empty root")
+	} else if parentPage.numEntries() == 1 && c.top == 0 && !parentPage.isLeaf() {
+		return c.collapseRoot(parentPage)
 	}
 
 	// Adjust cursor position
@@ -1922,6 +2249,16 @@ func (c *Cursor) delDupSubTreeValue() error {
 	// Make a copy since we'll be modifying the page
 	mainKey = append([]byte(nil), mainKey...)
 
+	// The dup state only tracks the sub-tree fields needed to navigate;
+	// load the others so the rewritten node keeps them
+	if full := parseTreeFromBytes(nodeGetDataDirect(mainPage, mainIdx)); full != nil {
+		c.dup.subTree.DupfixSize = full.DupfixSize
+		c.dup.subTree.BranchPages = full.BranchPages
+		c.dup.subTree.LeafPages = full.LeafPages
+		c.dup.subTree.LargePages = full.LargePages
+		c.dup.subTree.Sequence = full.Sequence
+	}
+
 	// Touch sub-tree pages from root to leaf (COW for sub-tree)
 	// This allocates new pages for the entire path
 	subLeafPage, err := c.touchSubTreePath()
@@ -1930,9 +2267,16 @@ func (c *Cursor) delDupSubTreeValue() error {
 	}
 
 	subIdx := int(c.dup.subIndices[c.dup.subTop])
+	deleted := append([]byte(nil), subTreeKey(subLeafPage, subIdx)...)
 
 	// Remove the entry from the sub-tree leaf page
-	if !subLeafPage.removeEntry(subIdx) {
+	var removed bool
+	if subLeafPage.isDupfix() {
+		removed = subLeafPage.removeDupfix(subIdx)
+	} else {
+		removed = subLeafPage.removeEntry(subIdx)
+	}
+	if !removed {
 		return ErrCorruptedError
 	}
 
@@ -1942,22 +2286,30 @@ func (c *Cursor) delDupSubTreeValue() error {
 
 	// Check if sub-tree is now empty
 	if c.dup.subTree.Items == 0 {
-		// Sub-tree is empty - delete the entire node
-		// Free sub-tree pages
-		c.freeSubTreePages()
-		return c.delNode()
+		// Sub-tree is empty - delete the entire node. The node still names
+		// the root from before the path was touched, so free the touched tree
+		if err := c.freeSubTree(c.dup.subTree.Root); err != nil {
+			return err
+		}
+		return c.removeNode(false)
 	}
 
-	// Check if we should convert back to sub-page (optional optimization)
-	// If sub-tree has very few items and they would fit in a sub-page
-	// For now, we keep it as a sub-tree - conversion is optional
+	// Values that fit inline again leave the sub-tree
+	if demoted, err := c.demoteDupSubTree(mainPage, mainIdx, mainKey, deleted); err != nil || demoted {
+		if demoted {
+			c.tree.Items--
+			c.tree.ModTxnid = txnid(c.txn.txnID)
+			c.markTreeDirty()
+		}
+		return err
+	}
 
-	// Check if leaf page is empty (but tree not empty - need rebalancing)
-	if subLeafPage.numEntries() == 0 && c.dup.subTop > 0 {
-		// Leaf is empty but tree has other entries - need to handle underflow
-		// For now, just free the empty page
-		// Full rebalancing (merge/borrow) is deferred
-		c.dup.subTree.LeafPages--
+	// An empty leaf would still be visited by cursors, so unlink it
+	// (merging half-empty pages is deferred, as in the main tree)
+	if subLeafPage.numEntries() == 0 {
+		if err := c.unlinkEmptySubTreeLeaf(); err != nil {
+			return err
+		}
 	}
 
 	// Update the main node with new sub-tree metadata (serializes directly, no allocation)
@@ -1974,23 +2326,215 @@ func (c *Cursor) delDupSubTreeValue() error {
 	c.tree.ModTxnid = txnid(c.txn.txnID)
 	c.markTreeDirty()
 
-	// Adjust sub-tree cursor position
-	if subIdx >= subLeafPage.numEntries() {
-		if subLeafPage.numEntries() > 0 {
-			c.dup.subIndices[c.dup.subTop] = uint16(subLeafPage.numEntries() - 1)
+	// Reset position tracking
+	c.dup.atFirst = false
+	c.dup.atLast = false
+
+	// Position on the value after the deleted one, so the next move returns
+	// it; after the last value, the next move goes on from the last one
+	c.afterDelete = c.seekSubTree(deleted)
+
+	return nil
+}
+
+// demoteDupSubTree stores the values of the sub-tree at the cursor inline
+// again, as a sub-page or a plain node, once they fit in half the room the
+// main node has for them, so that a key whose values hover around the limit
+// doesn't move back and forth between a sub-tree and a sub-page. The values
+// are only read while their count fits that room too, and no further than
+// the room, so a delete reads at most about half a node's worth of them. It
+// frees the sub-tree's pages and positions the cursor after deleted, and
+// reports whether it did.
+func (c *Cursor) demoteDupSubTree(mainPage *page, mainIdx int, mainKey, deleted []byte) (bool, error) {
+	limit := (c.txn.env.LeafNodeMax() - nodeSize - len(mainKey)) / 2
+	// Every sub-page value takes a node header and a pointer
+	if c.dup.subTree.Items > uint64(limit/(nodeSize+2)) {
+		return false, nil
+	}
+
+	var values [][]byte
+	size := 0
+	var walk func(pn pgno, depth int) (bool, error)
+	walk = func(pn pgno, depth int) (bool, error) {
+		if depth > CursorStackSize {
+			return false, ErrCorruptedError
+		}
+		p, err := c.txn.getPage(pn)
+		if err != nil {
+			return false, err
+		}
+		for i := 0; i < p.numEntriesFast(); i++ {
+			if p.isBranchFast() {
+				if ok, err := walk(nodeGetChildPgnoFast(p, i), depth+1); !ok || err != nil {
+					return false, err
+				}
+				continue
+			}
+			v := subTreeKey(p, i)
+			if size += len(v); size > limit {
+				return false, nil
+			}
+			values = append(values, v)
+		}
+		return true, nil
+	}
+	if ok, err := walk(c.dup.subTree.Root, 1); !ok || err != nil {
+		return false, err
+	}
+
+	var nodeData, subPage []byte
+	if len(values) == 1 {
+		nodeData = make([]byte, nodeSize+len(mainKey)+len(values[0]))
+		binary.LittleEndian.PutUint32(nodeData[0:], uint32(len(values[0])))
+		binary.LittleEndian.PutUint16(nodeData[6:], uint16(len(mainKey)))
+		copy(nodeData[nodeSize:], mainKey)
+		copy(nodeData[nodeSize+len(mainKey):], values[0])
+	} else {
+		subPage = c.buildDupSubPage(values)
+		if len(subPage) > limit {
+			return false, nil
+		}
+		nodeData = c.buildDupNode(mainKey, subPage)
+	}
+	pos := len(values)
+	for i, v := range values {
+		if c.txn.compareDupValues(c.dbi, v, deleted) > 0 {
+			pos = i
+			break
 		}
 	}
 
-	// Reset position tracking
+	// The node no longer refers to the sub-tree, whose pages may be reused
+	if err := c.replaceNodeAt(mainPage, mainIdx, nodeData); err != nil {
+		return false, err
+	}
+	c.pages[c.top] = mainPage
+	if err := c.freeSubTree(c.dup.subTree.Root); err != nil {
+		return false, err
+	}
+
+	c.dup.isSubTree = false
+	c.dup.subTop = -1
 	c.dup.atFirst = false
 	c.dup.atLast = false
+	if subPage == nil {
+		c.dup.initialized = false
+		c.dup.subPageNum = 0
+		c.dup.subPageData = nil
+		c.dup.nodePositions = nil
+		c.afterDelete = pos == 0
+		return true, nil
+	}
+	if err := c.initDupSubPage(subPage); err != nil {
+		return false, err
+	}
+	// Position on the value after the deleted one, so the next move returns
+	// it; after the last value, the next move goes on from the last one
+	if pos < len(values) {
+		c.dup.subPageIdx = pos
+		c.afterDelete = true
+	} else {
+		c.dup.subPageIdx = len(values) - 1
+		c.afterDelete = false
+	}
+	return true, nil
+}
 
-	// Mark that next move should return current position
-	c.afterDelete = true
+// unlinkEmptySubTreeLeaf removes the empty leaf at the bottom of the dup
+// sub-tree stack from its parent, along with branches that become empty,
+// and collapses a branch root left with a single child. The stack must
+// have been touched and is invalid afterwards.
+func (c *Cursor) unlinkEmptySubTreeLeaf() error {
+	for c.dup.subTop > 0 && c.dup.subPages[c.dup.subTop].numEntries() == 0 {
+		empty := c.dup.subPages[c.dup.subTop]
+		c.txn.freePage(empty.pageNo())
+		if empty.isLeaf() {
+			if c.dup.subTree.LeafPages > 0 {
+				c.dup.subTree.LeafPages--
+			}
+		} else if c.dup.subTree.BranchPages > 0 {
+			c.dup.subTree.BranchPages--
+		}
+		c.dup.subPages[c.dup.subTop] = nil
+		c.dup.subTop--
+
+		parent := c.dup.subPages[c.dup.subTop]
+		if !parent.removeEntry(int(c.dup.subIndices[c.dup.subTop])) {
+			return ErrCorruptedError
+		}
+	}
 
+	root := c.dup.subPages[0]
+	for !root.isLeaf() && root.numEntries() == 1 {
+		child, err := c.txn.getPage(c.getChildPgno(root, 0))
+		if err != nil {
+			return err
+		}
+		c.txn.freePage(root.pageNo())
+		c.dup.subTree.Root = child.pageNo()
+		c.dup.subTree.Height--
+		if c.dup.subTree.BranchPages > 0 {
+			c.dup.subTree.BranchPages--
+		}
+		root = child
+	}
 	return nil
 }
 
+// seekSubTree positions the dup sub-tree stack on the first value >= value
+// and reports whether there is one. If there is none, the stack is left on
+// the last value.
+func (c *Cursor) seekSubTree(value []byte) bool {
+	c.dup.subTop = 0
+	p := c.txn.fillPageHotPath(c.dup.subTree.Root, &c.dup.subPagesBuf[0])
+	c.dup.subPages[0] = p
+
+	// Descend to the leaf that would hold value
+	for p.isBranchFast() {
+		low, high := 1, p.numEntriesFast()-1
+		for low <= high {
+			mid := (low + high) / 2
+			if c.txn.compareDupValues(c.dbi, value, nodeGetKeyFast(p, mid)) < 0 {
+				high = mid - 1
+			} else {
+				low = mid + 1
+			}
+		}
+		c.dup.subIndices[c.dup.subTop] = uint16(low - 1)
+		childPgno := nodeGetChildPgnoFast(p, low-1)
+		c.dup.subTop++
+		p = c.txn.fillPageHotPath(childPgno, &c.dup.subPagesBuf[c.dup.subTop])
+		c.dup.subPages[c.dup.subTop] = p
+	}
+
+	n := p.numEntriesFast()
+	low, high := 0, n-1
+	for low <= high {
+		mid := (low + high) / 2
+		if c.txn.compareDupValues(c.dbi, subTreeKey(p, mid), value) < 0 {
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+	if low < n {
+		c.dup.subIndices[c.dup.subTop] = uint16(low)
+		return true
+	}
+
+	// Past the end of this leaf: the next value starts the next leaf
+	c.dup.subIndices[c.dup.subTop] = uint16(n - 1)
+	for level := c.dup.subTop - 1; level >= 0; level-- {
+		if int(c.dup.subIndices[level])+1 < c.dup.subPages[level].numEntriesFast() {
+			c.dup.subIndices[level]++
+			c.dup.subTop = level
+			c.dupSubTreeDescendLeft()
+			return true
+		}
+	}
+	return false
+}
+
 // touchSubTreePath touches all pages in the sub-tree path from root to leaf.
 // Returns the touched leaf page.
 func (c *Cursor) touchSubTreePath() (*page, error) {
@@ -2019,27 +2563,24 @@ func (c *Cursor) touchSubTreePath() (*page, error) {
 			continue
 		}
 
-		// Allocate new page (COW)
-		newPgno := c.txn.allocatedPg
-		c.txn.allocatedPg++
+		// Allocate new page (COW), reusing one freed earlier in this transaction
+		newPgno, newData, err := c.txn.allocPage()
+		if err != nil {
+			return nil, err
+		}
+		c.txn.counters.PagesCOW++
 
-		var newData []byte
-		var usedMmap bool
-		if c.txn.env.isWriteMap() {
+		if newData == nil && c.txn.env.isWriteMap() {
 			// WriteMap mode: try mmap directly (no remap during transaction)
 			newData = c.txn.env.getMmapPageData(newPgno)
-			if newData != nil {
-				copy(newData, origPage.Data)
-				usedMmap = true
-			}
 		}
-		if !usedMmap {
+		if newData == nil {
 			// Normal mode or mmap out of bounds
 			newData = c.txn.env.getPageDataFromCache()
-			copy(newData, origPage.Data)
 			c.txn.pooledPageData = append(c.txn.pooledPageData, newData)
 			c.txn.hasNonMmapPages = true // Track that we have pages outside mmap
 		}
+		copyPage(newData, origPage.Data)
 
 		newPage := getPooledPageStruct(newData)
 		c.txn.pooledPageStructs = append(c.txn.pooledPageStructs, newPage)
@@ -2065,16 +2606,41 @@ func (c *Cursor) touchSubTreePath() (*page, error) {
 	return c.dup.subPages[c.dup.subTop], nil
 }
 
-// freeSubTreePages adds all sub-tree pages to the free list.
-func (c *Cursor) freeSubTreePages() {
-	// Simple implementation: just decrement page counts
-	// The pages will be reclaimed during garbage collection
-	// or reused after commit
-	if c.dup.subTree.LeafPages > 0 {
+// freeSubTree releases the pages of the DupSort sub-tree rooted at root,
+// which no node refers to any longer, and takes back the leaf page its
+// creation added to the table's count. Only pages this transaction wrote
+// can be reused, and a page it didn't write has none below it, so the walk
+// stops at the first older page on each path.
+func (c *Cursor) freeSubTree(root pgno) error {
+	if c.tree.LeafPages > 0 {
 		c.tree.LeafPages--
 	}
-	// Branch pages are already tracked in c.dup.subTree.BranchPages
-	// but we don't maintain per-tree page counts precisely
+	if c.txn.allocSequential {
+		return nil
+	}
+	var walk func(pn pgno, depth int) error
+	walk = func(pn pgno, depth int) error {
+		if pn < c.txn.firstNewPg {
+			return nil
+		}
+		if depth > CursorStackSize {
+			return ErrCorruptedError
+		}
+		p, err := c.txn.getPage(pn)
+		if err != nil {
+			return err
+		}
+		if p.isBranchFast() {
+			for i := 0; i < p.numEntriesFast(); i++ {
+				if err := walk(nodeGetChildPgnoFast(p, i), depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		c.txn.freePage(pn)
+		return nil
+	}
+	return walk(root, 1)
 }
 
 // delDupSubPageValue removes a single value from an inline sub-page.
@@ -2123,7 +2689,14 @@ func (c *Cursor) delDupSubPageValue() error {
 
 	if len(newValues) == 1 {
 		// Only one value left - convert to regular node (no sub-page)
-		return c.convertDupToSingle(p, idx, key, newValues[0])
+		pos := c.dup.subPageIdx
+		c.dropDupSubPage()
+		if err := c.convertDupToSingle(p, idx, key, newValues[0]); err != nil {
+			return err
+		}
+		// The remaining value comes next only if the first one was deleted
+		c.afterDelete = pos == 0
+		return nil
 	}
 
 	// Multiple values remain - rebuild the sub-page
@@ -2133,17 +2706,16 @@ func (c *Cursor) delDupSubPageValue() error {
 	nodeData := c.buildDupNode(key, newSubPage)
 
 	// Update the node in place
+	pos := c.dup.subPageIdx
 	if err := c.replaceNodeAt(p, idx, nodeData); err != nil {
 		return err
 	}
+	c.compactSubPageLeaf(p)
+	c.trackDupSubPage(pos, -1, len(newValues))
+	c.assertSubPage(p, idx, len(newValues), "delete: sub-page")
 
 	// Re-parse node positions (this resets subPageIdx to 0)
-	c.initDupSubPage(newSubPage)
-
-	// Restore cursor position after re-initialization
-	if c.dup.subPageIdx >= len(newValues) {
-		c.dup.subPageIdx = len(newValues) - 1
-	}
+	c.initDupSubPage(nodeGetDataDirect(p, idx))
 
 	c.pages[c.top] = p
 	// Decrement tree.Items since we're deleting a dup value (Items tracks all values including dups)
@@ -2151,8 +2723,15 @@ func (c *Cursor) delDupSubPageValue() error {
 	c.tree.ModTxnid = txnid(c.txn.txnID)
 	c.markTreeDirty()
 
-	// Mark that next move should return current position
-	c.afterDelete = true
+	// Position on the value after the deleted one, so the next move returns
+	// it; after the last value, the next move goes on from the last one
+	if pos < len(newValues) {
+		c.dup.subPageIdx = pos
+		c.afterDelete = true
+	} else {
+		c.dup.subPageIdx = len(newValues) - 1
+		c.afterDelete = false
+	}
 
 	return nil
 }
@@ -2193,8 +2772,8 @@ func (c *Cursor) convertDupToSingle(p *page, idx int, key, value []byte) error {
 	c.afterDelete = true
 
 	c.pages[c.top] = p
-	// Note: Do NOT decrement tree.Items here - we're converting a dup to single, not deleting a key
-	// Items counts the number of distinct keys in the tree
+	// Items counts every value, and one of the two is gone
+	c.tree.Items--
 	c.tree.ModTxnid = txnid(c.txn.txnID)
 	c.markTreeDirty()
 
@@ -2261,6 +2840,7 @@ func (c *Cursor) touchPageAt(level int) (*page, error) {
 	if level < 0 || level > int(c.top) {
 		return nil, ErrCorruptedError
 	}
+	c.txn.pageWrites++
 
 	levelBit := uint32(1) << level
 
@@ -2320,36 +2900,31 @@ func (c *Cursor) touchPageAt(level int) (*page, error) {
 			continue
 		}
 
-		// Allocate a NEW page number (proper COW)
-		newPgno := c.txn.allocatedPg
-		c.txn.allocatedPg++
-
-		var newData []byte
-		var usedMmap bool
+		// Allocate a NEW page number (proper COW), reusing one freed earlier in this transaction
+		newPgno, newData, err := c.txn.allocPage()
+		if err != nil {
+			return nil, err
+		}
+		c.txn.counters.PagesCOW++
 
-		if c.txn.env.isWriteMap() {
+		if newData == nil && c.txn.env.isWriteMap() {
 			// WriteMap mode: try mmap directly (zero allocation)
 			// Note: We intentionally do NOT call extendMmap here. Remapping during a
 			// transaction would invalidate all cached page references, causing crashes.
 			// If the page is beyond mmap bounds, fall back to spill buffer.
 			newData = c.txn.env.getMmapPageData(newPgno)
-			if newData != nil {
-				copy(newData, origPage.Data)
-				usedMmap = true
-			}
 		}
-		if !usedMmap {
+		if newData == nil {
 			// Use spill buffer (reduces heap pressure)
 			var spillSlot *spill.Slot
-			var err error
 			newData, spillSlot, err = c.txn.env.spillBuf.Allocate()
 			if err != nil {
 				panic("gdbx: spill buffer allocation failed: " + err.Error())
 			}
-			copy(newData, origPage.Data)
 			// Track spill slot for release after commit/abort
 			c.txn.spillSlots.Set(uint32(newPgno), unsafe.Pointer(spillSlot))
 		}
+		copyPage(newData, origPage.Data)
 
 		newPage := getPooledPageStruct(newData)
 		c.txn.pooledPageStructs = append(c.txn.pooledPageStructs, newPage)
@@ -2393,34 +2968,20 @@ func (c *Cursor) updateChildPointer(parentPage *page, idx int, newChildPgno pgno
 
 // allocatePage allocates a new page.
 func (c *Cursor) allocatePage() (pgno, *page, error) {
-	var newPgno pgno
-
-	// Check free list first
-	if len(c.txn.freePages) > 0 {
-		// Pop a page from the free list
-		newPgno = c.txn.freePages[len(c.txn.freePages)-1]
-		c.txn.freePages = c.txn.freePages[:len(c.txn.freePages)-1]
-	} else {
-		// Allocate from end of file
-		newPgno = c.txn.allocatedPg
-		c.txn.allocatedPg++
+	// Reuse a page freed earlier in this transaction, or take one from the end of file
+	newPgno, data, err := c.txn.allocPage()
+	if err != nil {
+		return 0, nil, err
 	}
 
-	var data []byte
-	var usedMmap bool
-
-	if c.txn.env.isWriteMap() {
+	if data == nil && c.txn.env.isWriteMap() {
 		// WriteMap mode: try to use mmap directly (zero allocation)
 		// Note: No remap during transaction to avoid invalidating page references
 		data = c.txn.env.getMmapPageData(newPgno)
-		if data != nil {
-			usedMmap = true
-		}
 	}
-	if !usedMmap {
+	if data == nil {
 		// Use spill buffer (reduces heap pressure)
 		var spillSlot *spill.Slot
-		var err error
 		data, spillSlot, err = c.txn.env.spillBuf.Allocate()
 		if err != nil {
 			panic("gdbx: spill buffer allocation failed: " + err.Error())
@@ -2443,21 +3004,19 @@ func (c *Cursor) allocatePage() (pgno, *page, error) {
 
 // allocateOverflow allocates overflow pages for large values.
 // MDBX format: first page has header, subsequent pages are raw data with no header.
+// The run is sized for the cursor's overflowCap when that exceeds the data, and
+// the first page's header records the full page count.
 func (c *Cursor) allocateOverflow(data []byte) (pgno, error) {
 	pageSize := int(c.txn.env.pageSize)
 	firstPageData := pageSize - pageHeaderSize // First page has header
-
-	// Calculate number of pages needed
-	// First page holds (pageSize - headerSize) bytes, subsequent pages hold pageSize bytes each
-	remaining := len(data) - firstPageData
-	numPages := 1
-	if remaining > 0 {
-		numPages += (remaining + pageSize - 1) / pageSize
-	}
+	numPages := overflowPagesFor(max(len(data), c.overflowCap), pageSize)
 
 	// Allocate consecutive pages
-	firstPgno := c.txn.allocatedPg
-	c.txn.allocatedPg += pgno(numPages)
+	firstPgno, err := c.txn.allocOverflowPages(numPages)
+	if err != nil {
+		return 0, err
+	}
+	c.txn.counters.OverflowPages += uint64(numPages)
 
 	// Write data to overflow pages
 	offset := 0
@@ -2517,22 +3076,36 @@ func (c *Cursor) allocateOverflow(data []byte) (pgno, error) {
 	return firstPgno, nil
 }
 
-// freeOverflow frees overflow pages.
-// MDBX format: first page has header, subsequent pages are raw data with no header.
-func (c *Cursor) freeOverflow(overflowPgno pgno, dataSize uint32) {
-	pageSize := int(c.txn.env.pageSize)
-	firstPageData := pageSize - pageHeaderSize
-
-	// Calculate number of pages (same logic as allocateOverflow)
-	remaining := int(dataSize) - firstPageData
+// overflowPagesFor returns the number of overflow pages needed for size bytes.
+// First page holds (pageSize - headerSize) bytes, subsequent pages hold pageSize bytes each.
+func overflowPagesFor(size, pageSize int) int {
+	remaining := size - (pageSize - pageHeaderSize)
 	numPages := 1
 	if remaining > 0 {
 		numPages += (remaining + pageSize - 1) / pageSize
 	}
+	return numPages
+}
 
-	// Add pages to free list
+// overflowRunPages returns the number of pages allocated to the overflow run
+// starting at overflowPgno. Its first page header records the count, which
+// exceeds what dataSize needs when capacity was reserved with Txn.PutWithCap.
+func (c *Cursor) overflowRunPages(overflowPgno pgno, dataSize uint32) int {
+	numPages := overflowPagesFor(int(dataSize), int(c.txn.env.pageSize))
+	if p, err := c.txn.getPage(overflowPgno); err == nil && p.isLarge() {
+		numPages = max(numPages, int(p.overflowPages()))
+	}
+	return numPages
+}
+
+// freeOverflow frees overflow pages.
+// MDBX format: first page has header, subsequent pages are raw data with no header.
+func (c *Cursor) freeOverflow(overflowPgno pgno, dataSize uint32) {
+	numPages := c.overflowRunPages(overflowPgno, dataSize)
+
+	// Free the pages
 	for i := 0; i < numPages; i++ {
-		c.txn.freePages = append(c.txn.freePages, overflowPgno+pgno(i))
+		c.txn.freePage(overflowPgno + pgno(i))
 	}
 
 	if c.tree.LargePages >= pgno(numPages) {
@@ -2550,31 +3123,49 @@ func (c *Cursor) markTreeDirty() {
 	}
 }
 
+// growsInReservation reports whether value grows the big value at the cursor
+// without outgrowing its overflow run. Such an update is written in place,
+// even while the value would fit inline, so that a value appended to within
+// the capacity reserved by Txn.PutWithCap never gives up its pages.
+func (c *Cursor) growsInReservation(value []byte) bool {
+	p := c.pages[c.top]
+	idx := int(c.indices[c.top])
+	if nodeGetFlagsDirect(p, idx)&nodeBig == 0 {
+		return false
+	}
+	oldSize := nodeGetDataSizeDirect(p, idx)
+	if len(value) <= int(oldSize) {
+		return false
+	}
+	runPages := c.overflowRunPages(nodeGetOverflowPgnoDirect(p, idx), oldSize)
+	return overflowPagesFor(len(value), int(c.txn.env.pageSize)) <= runPages
+}
+
 // updateOverflowInPlace attempts to update overflow data in place when the new value
-// fits within the same number of pages as the old value. Returns true on success.
+// fits within the pages allocated to the old value. Returns true on success.
 func (c *Cursor) updateOverflowInPlace(oldPgno pgno, oldSize uint32, newData []byte) bool {
 	pageSize := int(c.txn.env.pageSize)
 	firstPageData := pageSize - pageHeaderSize
 	newSize := len(newData)
 
 	// Calculate number of pages for old and new data
-	oldRemaining := int(oldSize) - firstPageData
-	oldNumPages := 1
-	if oldRemaining > 0 {
-		oldNumPages += (oldRemaining + pageSize - 1) / pageSize
-	}
-
-	newRemaining := newSize - firstPageData
-	newNumPages := 1
-	if newRemaining > 0 {
-		newNumPages += (newRemaining + pageSize - 1) / pageSize
-	}
+	oldNumPages := c.overflowRunPages(oldPgno, oldSize)
+	newNumPages := overflowPagesFor(newSize, pageSize)
 
 	// Can only update in place if new data fits in same or fewer pages
 	if newNumPages > oldNumPages {
 		return false
 	}
 
+	// A run with reserved capacity keeps all its pages; otherwise extra pages are freed
+	keepPages := newNumPages
+	if oldNumPages > overflowPagesFor(int(oldSize), pageSize) || c.overflowCap > newSize {
+		if overflowPagesFor(c.overflowCap, pageSize) > oldNumPages {
+			return false // Reallocate with the larger reservation
+		}
+		keepPages = oldNumPages
+	}
+
 	// Fast path for WriteMap mode - write directly to mmap
 	if c.txn.env.isWriteMap() {
 		// Get direct pointer to overflow data in mmap
@@ -2582,12 +3173,18 @@ func (c *Cursor) updateOverflowInPlace(oldPgno pgno, oldSize uint32, newData []b
 		if mmapData == nil {
 			return false // Page not in mmap bounds
 		}
+		c.txn.noteMapWrite(oldPgno, oldNumPages)
 
 		// For multi-page overflow, all pages are contiguous in mmap
 		// First page: [header 20 bytes][data]
 		// Subsequent pages: [data only]
 		// Since pages are contiguous, we can write in one copy for same-size updates
-		if newNumPages == oldNumPages && newSize == int(oldSize) {
+		if c.txn.env.changesOn.Load() {
+			for i := 0; i < oldNumPages; i++ {
+				c.txn.inPlacePages = append(c.txn.inPlacePages, oldPgno+pgno(i))
+			}
+		}
+		if keepPages == oldNumPages && newSize == int(oldSize) {
 			// Same size - just copy the data directly (no header update needed)
 			copy(mmapData[pageHeaderSize:pageHeaderSize+newSize], newData)
 			return true
@@ -2597,7 +3194,7 @@ func (c *Cursor) updateOverflowInPlace(oldPgno pgno, oldSize uint32, newData []b
 		// Update header on first page
 		p := &page{Data: mmapData}
 		p.header().Txnid = txnid(c.txn.txnID)
-		p.setOverflowPages(uint32(newNumPages))
+		p.setOverflowPages(uint32(keepPages))
 
 		// Copy new data
 		copy(mmapData[pageHeaderSize:pageHeaderSize+newSize], newData)
@@ -2609,8 +3206,8 @@ func (c *Cursor) updateOverflowInPlace(oldPgno pgno, oldSize uint32, newData []b
 		}
 
 		// If we used fewer pages, free the extra ones
-		for i := newNumPages; i < oldNumPages; i++ {
-			c.txn.freePages = append(c.txn.freePages, oldPgno+pgno(i))
+		for i := keepPages; i < oldNumPages; i++ {
+			c.txn.freePage(oldPgno + pgno(i))
 			if c.tree.LargePages > 0 {
 				c.tree.LargePages--
 			}
@@ -2634,6 +3231,7 @@ func (c *Cursor) updateOverflowInPlace(oldPgno pgno, oldSize uint32, newData []b
 			pdata = c.txn.env.getPageDataFromCache()
 			srcData := c.txn.getPageDataFast(currentPgno)
 			copy(pdata, srcData)
+			c.txn.counters.PagesCOW++
 			c.txn.pooledPageData = append(c.txn.pooledPageData, pdata)
 			p = getPooledPageStruct(pdata)
 			c.txn.pooledPageStructs = append(c.txn.pooledPageStructs, p)
@@ -2643,7 +3241,7 @@ func (c *Cursor) updateOverflowInPlace(oldPgno pgno, oldSize uint32, newData []b
 		if i == 0 {
 			// First page has header
 			p.header().Txnid = txnid(c.txn.txnID)
-			p.setOverflowPages(uint32(newNumPages))
+			p.setOverflowPages(uint32(keepPages))
 			// Copy data after header
 			end := min(offset+firstPageData, newSize)
 			copy(pdata[pageHeaderSize:], newData[offset:end])
@@ -2663,8 +3261,8 @@ func (c *Cursor) updateOverflowInPlace(oldPgno pgno, oldSize uint32, newData []b
 	}
 
 	// If we used fewer pages, free the extra ones
-	for i := newNumPages; i < oldNumPages; i++ {
-		c.txn.freePages = append(c.txn.freePages, oldPgno+pgno(i))
+	for i := keepPages; i < oldNumPages; i++ {
+		c.txn.freePage(oldPgno + pgno(i))
 		if c.tree.LargePages > 0 {
 			c.tree.LargePages--
 		}
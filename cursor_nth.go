@@ -0,0 +1,153 @@
+package gdbx
+
+import "bytes"
+
+// SeekNth positions at the n-th key (0-based) of the cursor's table in key
+// order and returns it with its value; a DupSort key counts once and the
+// cursor lands on its first value. It returns ErrNotFound, leaving the
+// cursor at EOF, when the table has n keys or fewer. Bounds set with
+// SetBounds do not apply. The leaves before the target are counted from
+// their headers rather than visited, but a deep n still costs in proportion
+// to the table's size; paging with SetRange is cheaper.
+func (c *Cursor) SeekNth(n uint64) ([]byte, []byte, error) {
+	return c.seekNthPublic(n, false)
+}
+
+// SeekNthValue positions at the n-th value (0-based) of the cursor's table,
+// counting every value of a DupSort key, and returns it with its key. It is
+// SeekNth on tables without DupSort.
+func (c *Cursor) SeekNthValue(n uint64) ([]byte, []byte, error) {
+	return c.seekNthPublic(n, c.isDupSort)
+}
+
+func (c *Cursor) seekNthPublic(n uint64, values bool) ([]byte, []byte, error) {
+	if !c.valid() {
+		return nil, nil, ErrBadCursorError
+	}
+	c.atEnd = false
+	k, v, err := c.seekNth(n, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.safeValues {
+		return bytes.Clone(k), bytes.Clone(v), nil
+	}
+	return k, v, nil
+}
+
+// seekNth positions at the n-th key, or with values the n-th value.
+func (c *Cursor) seekNth(n uint64, values bool) ([]byte, []byte, error) {
+	c.reset()
+	// Items counts values, which is also an upper bound on the keys
+	if c.tree.isEmpty() || n >= c.tree.Items {
+		c.state = cursorEOF
+		return nil, nil, ErrNotFoundError
+	}
+
+	c.top = 0
+	p := c.txn.fillPageHotPath(c.tree.Root, &c.pagesBuf[0])
+	c.pages[0] = p
+	c.numExpected[0] = uint16(p.numEntriesFast())
+	for p.isBranchFast() {
+		idx, rest, err := c.skipChildren(p, n, values, int(c.top)+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		n = rest
+		c.indices[c.top] = uint16(idx)
+		if err := c.pushPageByPgno(c.getChildPgno(p, idx), 0); err != nil {
+			return nil, nil, err
+		}
+		p = c.pages[c.top]
+	}
+
+	idx, num := 0, p.numEntriesFast()
+	for ; idx < num; idx++ {
+		count := uint64(1)
+		if values {
+			count = nodeDupCount(p, idx)
+		}
+		if n < count {
+			break
+		}
+		n -= count
+	}
+	if idx == num {
+		// Fewer keys than Items suggested
+		c.reset()
+		c.state = cursorEOF
+		return nil, nil, ErrNotFoundError
+	}
+	c.indices[c.top] = uint16(idx)
+	c.state = cursorPointing
+	if values && n > 0 {
+		if err := c.seekNthDup(p, idx, n); err != nil {
+			return nil, nil, err
+		}
+	}
+	return c.getCurrent()
+}
+
+// skipChildren returns the child of branch p holding the n-th key or value
+// below p, and n less the entries of the children before it. The last child
+// takes whatever is left.
+func (c *Cursor) skipChildren(p *page, n uint64, values bool, depth int) (int, uint64, error) {
+	last := p.numEntriesFast() - 1
+	for i := 0; i < last; i++ {
+		count, err := c.countSubtree(nodeGetChildPgnoFast(p, i), nil, nil, values, depth)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n < count {
+			return i, n, nil
+		}
+		n -= count
+	}
+	return max(last, 0), n, nil
+}
+
+// seekNthDup sets the dup state of the leaf node at idx, which has more
+// than n values, on its n-th value.
+func (c *Cursor) seekNthDup(p *page, idx int, n uint64) error {
+	flags := nodeGetFlagsDirect(p, idx)
+	data := nodeGetDataDirect(p, idx)
+	if flags&nodeTree == 0 {
+		if err := c.initDupSubPage(data); err != nil {
+			return err
+		}
+		if n >= uint64(c.dup.subPageNum) {
+			return ErrCorruptedError
+		}
+		c.dup.subPageIdx = int(n)
+		// Off the first value; atLast is a shortcut that may stay unset
+		c.dup.atFirst = false
+		return nil
+	}
+
+	if err := c.initDupSubTree(data); err != nil {
+		return err
+	}
+	c.dup.subTop = 0
+	sp := c.txn.fillPageHotPath(c.dup.subTree.Root, &c.dup.subPagesBuf[0])
+	c.dup.subPages[0] = sp
+	for sp.isBranchFast() {
+		if int(c.dup.subTop) >= CursorStackSize-1 {
+			return ErrCursorFullError
+		}
+		i, rest, err := c.skipChildren(sp, n, false, int(c.dup.subTop)+1)
+		if err != nil {
+			return err
+		}
+		n = rest
+		c.dup.subIndices[c.dup.subTop] = uint16(i)
+		c.dup.subTop++
+		sp = c.txn.fillPageHotPath(nodeGetChildPgnoFast(sp, i), &c.dup.subPagesBuf[c.dup.subTop])
+		c.dup.subPages[c.dup.subTop] = sp
+	}
+	if n >= uint64(sp.numEntriesFast()) {
+		return ErrCorruptedError
+	}
+	c.dup.subIndices[c.dup.subTop] = uint16(n)
+	c.dup.atFirst = false
+	return nil
+}
@@ -0,0 +1,81 @@
+package gdbx
+
+// PeekNext returns the key Next would move to, without moving: the cursor
+// stays where it is, so the following Next returns that key after all. On
+// DupSort tables that is the current key while it has values after the
+// current one. It returns found == false where Next would return
+// ErrNotFound, at the end of the table or of the SetBounds range. The key is
+// valid as long as one returned by Get.
+//
+// It runs Next and then puts back the cursor's page stack and duplicate
+// position, so it costs a Next and a copy of a few kilobytes of cursor state.
+// The op log does not record it.
+func (c *Cursor) PeekNext() ([]byte, bool, error) {
+	return c.peek(Next)
+}
+
+// PeekPrev returns the key Prev would move to, without moving, as PeekNext
+// does for Next.
+func (c *Cursor) PeekPrev() ([]byte, bool, error) {
+	return c.peek(Prev)
+}
+
+// cursorPosition is a copy of what moving a cursor changes.
+type cursorPosition struct {
+	state       cursorState
+	top         int8
+	afterDelete bool
+	atEnd       bool
+	dirtyMask   uint32
+	pages       [CursorStackSize]*page
+	pagesBuf    [CursorStackSize]page
+	pgnoCache   [CursorStackSize]pgno
+	indices     [CursorStackSize]uint16
+	stackDirty  [CursorStackSize]*page
+	numExpected [CursorStackSize]uint16
+	dup         dupState
+}
+
+// peek runs op and returns the key it lands on, then moves the cursor back.
+func (c *Cursor) peek(op CursorOp) ([]byte, bool, error) {
+	if !c.valid() {
+		return nil, false, ErrBadCursorError
+	}
+	var pos cursorPosition
+	c.savePosition(&pos)
+	defer c.restorePosition(&pos)
+
+	logID := c.logID
+	c.logID = 0
+	k, _, err := c.Get(nil, nil, op)
+	c.logID = logID
+	if IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return k, true, nil
+}
+
+// savePosition copies the cursor's position to pos. The page pointers of the
+// stack point into the cursor's own buffers, which restorePosition puts back
+// with them.
+func (c *Cursor) savePosition(pos *cursorPosition) {
+	pos.state, pos.top = c.state, c.top
+	pos.afterDelete, pos.atEnd = c.afterDelete, c.atEnd
+	pos.dirtyMask = c.dirtyMask
+	pos.pages, pos.pagesBuf, pos.pgnoCache = c.pages, c.pagesBuf, c.pgnoCache
+	pos.indices, pos.stackDirty, pos.numExpected = c.indices, c.stackDirty, c.numExpected
+	pos.dup = c.dup
+}
+
+// restorePosition moves the cursor back to the position saved in pos.
+func (c *Cursor) restorePosition(pos *cursorPosition) {
+	c.state, c.top = pos.state, pos.top
+	c.afterDelete, c.atEnd = pos.afterDelete, pos.atEnd
+	c.dirtyMask = pos.dirtyMask
+	c.pages, c.pagesBuf, c.pgnoCache = pos.pages, pos.pagesBuf, pos.pgnoCache
+	c.indices, c.stackDirty, c.numExpected = pos.indices, pos.stackDirty, pos.numExpected
+	c.dup = pos.dup
+}
@@ -0,0 +1,101 @@
+package gdbx
+
+import "bytes"
+
+// siblingAnchor is the entry a cursor was on before a Txn.Del by another
+// cursor of its database: its key, its value on a DupSort table, whether
+// Next was to return the entry itself, and whether the delete removes it.
+// The cursor searches for it again after the delete, which rebuilds its page
+// stack; if the entry was deleted it lands on the one after, as after its
+// own Del.
+type siblingAnchor struct {
+	c           *Cursor
+	key, value  []byte
+	afterDelete bool
+	deleted     bool
+}
+
+// anchorSiblings returns the entries of the transaction's cursors on del's
+// database other than del, or nil if there are none, before Txn.Del deletes
+// key, with all its values when value is nil or the table is not DupSort.
+// Key and value may point into the pages the delete changes, so they are
+// compared now.
+func (txn *Txn) anchorSiblings(del *Cursor, key, value []byte) []siblingAnchor {
+	var anchors []siblingAnchor
+	for _, c := range txn.cursors {
+		if c == nil || c == del || c.dbi != del.dbi || c.signature != cursorSignature || c.state != cursorPointing {
+			continue
+		}
+		k, v, err := c.getCurrent()
+		if err != nil {
+			continue
+		}
+		a := siblingAnchor{c: c, key: bytes.Clone(k), afterDelete: c.afterDelete}
+		if c.isDupSort {
+			a.value = bytes.Clone(v)
+		}
+		a.deleted = txn.compareKeys(c.dbi, k, key) == 0 &&
+			(value == nil || !c.isDupSort || txn.compareDupValues(c.dbi, v, value) == 0)
+		anchors = append(anchors, a)
+	}
+	return anchors
+}
+
+// repositionSiblings moves each anchored cursor back to its entry after the
+// delete, or to the entry after it if the delete removed it.
+func (txn *Txn) repositionSiblings(anchors []siblingAnchor) {
+	for i := range anchors {
+		a := &anchors[i]
+		c := a.c
+		c.clearDupState()
+		c.afterDelete = false
+		if !a.deleted {
+			var err error
+			if c.isDupSort {
+				_, _, err = c.getBoth(a.key, a.value)
+			} else {
+				_, _, err = c.set(a.key)
+			}
+			if err == nil {
+				c.afterDelete = a.afterDelete
+				continue
+			}
+		}
+		c.afterDelete = c.seekSuccessor(a.key, a.value) == nil
+	}
+}
+
+// seekSuccessor positions at the first entry after key and value, which
+// are no longer in the tree; value is ignored unless the table is DupSort.
+// Without one it positions past the last entry, where Next finds nothing
+// and Prev the last entry, and returns ErrNotFound.
+func (c *Cursor) seekSuccessor(key, value []byte) error {
+	k, _, err := c.setRange(key)
+	if err == nil && c.isDupSort && c.txn.compareKeys(c.dbi, k, key) == 0 {
+		// The key keeps other values: the successor is its first greater
+		// value, or else the first value of the next key
+		c.clearDupState()
+		_, _, err = c.getBothRange(key, value)
+		if IsNotFound(err) && c.state == cursorPointing {
+			err = nil
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	c.clearDupState()
+	c.reset()
+	if c.tree.isEmpty() {
+		c.state = cursorEOF
+		return ErrNotFoundError
+	}
+	if err := c.positionAfterLast(); err != nil {
+		c.state = cursorEOF
+		return err
+	}
+	for level := int8(0); level <= c.top; level++ {
+		c.numExpected[level] = uint16(c.pages[level].numEntriesFast())
+	}
+	return ErrNotFoundError
+}
@@ -1,15 +1,173 @@
 package gdbx
 
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+)
+
 // DBI is a database handle (index into environment's database array).
 type DBI uint32
 
+// DBISpec declares a table for OpenDBIWithSpec: its flags and comparators,
+// as OpenDBI takes them, and the policies gdbx enforces on its writes.
+type DBISpec struct {
+	Flags uint
+	Cmp   CmpFunc
+	DCmp  CmpFunc
+
+	// MaxValue is the largest value Put accepts, in bytes; larger ones fail
+	// with ErrBadValSize whatever the page size allows. 0 means no limit.
+	MaxValue int
+
+	// BigEndianKeys orders keys as unsigned big-endian integers of any
+	// width, by value, the order of formats that store integer keys
+	// big-endian for sorting. Keys of one width already sort this way
+	// bytewise, IntegerKey ones included; the option orders keys of mixed
+	// widths, such as integers stored without their leading zero bytes,
+	// which bytewise order puts out of numeric order. Like Cmp, which it
+	// replaces, it decides where keys go, so a table must be opened with it
+	// from its first key on.
+	BigEndianKeys bool
+
+	// NativeIntegers orders the keys of an IntegerKey table, and the values
+	// of an IntegerDup one, as unsigned integers in native byte order, the
+	// order libmdbx gives them. gdbx otherwise orders them bytewise, the
+	// numeric order of big-endian integers, so a table libmdbx filled must
+	// be opened with it for lookups to find its keys, and one gdbx filled
+	// must not. It replaces Cmp and DCmp, and does nothing for tables of
+	// other kinds.
+	NativeIntegers bool
+}
+
+// OpenDBIWithSpec opens a named table like OpenDBI and registers the
+// spec's policies for it. The policies live in the Env, not in the data
+// file: they hold for every transaction until the table is opened with
+// another spec, and must be declared again after the Env is reopened.
+func (txn *Txn) OpenDBIWithSpec(name string, spec DBISpec) (DBI, error) {
+	if name == "" || spec.MaxValue < 0 || spec.BigEndianKeys && spec.Cmp != nil ||
+		spec.NativeIntegers && (spec.BigEndianKeys || spec.Cmp != nil || spec.DCmp != nil) {
+		return 0, NewError(ErrInvalid)
+	}
+	keyCmp := spec.Cmp
+	if spec.BigEndianKeys {
+		keyCmp = cmpBigEndian
+	}
+	dbi, err := txn.OpenDBI(name, spec.Flags, keyCmp, spec.DCmp)
+	if err != nil {
+		return 0, err
+	}
+	txn.applyDBISpec(dbi, spec)
+
+	// OpenDBI logged the open; the policies follow it
+	if txn.logOps {
+		var order uint64
+		if spec.BigEndianKeys {
+			order |= specBigEndianKeys
+		}
+		if spec.NativeIntegers {
+			order |= specNativeIntegers
+		}
+		txn.env.opLog.start(opDBISpec).uint(uint64(dbi)).uint(uint64(spec.MaxValue)).uint(order).end(nil)
+	}
+	return dbi, nil
+}
+
+// applyDBISpec registers the policies of spec for the open table dbi.
+func (txn *Txn) applyDBISpec(dbi DBI, spec DBISpec) {
+	e := txn.env
+	e.dbisMu.Lock()
+	defer e.dbisMu.Unlock()
+	if info := e.dbis[dbi]; info != nil {
+		info.maxValue = spec.MaxValue
+		if spec.BigEndianKeys {
+			// OpenDBI keeps the comparator of a table already open
+			info.cmp = cmpBigEndian
+			if int(dbi) < len(txn.dbiComparators) {
+				txn.dbiComparators[dbi] = nil
+			}
+		}
+		if spec.NativeIntegers && int(dbi) < len(txn.trees) {
+			if txn.trees[dbi].Flags&treeFlagIntegerKey != 0 {
+				info.cmp = cmpNativeInteger
+				if int(dbi) < len(txn.dbiComparators) {
+					txn.dbiComparators[dbi] = nil
+				}
+			}
+			if txn.trees[dbi].Flags&treeFlagIntegerDup != 0 {
+				info.dcmp = cmpNativeInteger
+				if int(dbi) < len(txn.dbiDupComparators) {
+					txn.dbiDupComparators[dbi] = nil
+				}
+			}
+		}
+	}
+	if spec.MaxValue > 0 {
+		e.valueLimits.Store(true)
+	}
+}
+
+// valueLimit returns the DBISpec.MaxValue registered for dbi, 0 if none.
+func (e *Env) valueLimit(dbi DBI) int {
+	if !e.valueLimits.Load() {
+		return 0
+	}
+	e.dbisMu.RLock()
+	defer e.dbisMu.RUnlock()
+	if int(dbi) < len(e.dbis) && e.dbis[dbi] != nil {
+		return e.dbis[dbi].maxValue
+	}
+	return 0
+}
+
+// cmpBigEndian compares keys as unsigned big-endian integers of any width:
+// leading zero bytes aside, the longer key is the larger, and keys of one
+// length compare bytewise. Keys of one value order by width, so that only
+// identical keys are equal.
+func cmpBigEndian(a, b []byte) int {
+	if len(a) == 8 && len(b) == 8 {
+		return cmp.Compare(binary.BigEndian.Uint64(a), binary.BigEndian.Uint64(b))
+	}
+	sa, sb := bytes.TrimLeft(a, "\x00"), bytes.TrimLeft(b, "\x00")
+	if len(sa) != len(sb) {
+		return cmp.Compare(len(sa), len(sb))
+	}
+	if c := bytes.Compare(sa, sb); c != 0 {
+		return c
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// cmpNativeInteger compares IntegerKey keys and IntegerDup values as
+// unsigned integers in native byte order, as libmdbx does. Keys of
+// different widths, which such tables refuse, order by width.
+func cmpNativeInteger(a, b []byte) int {
+	switch {
+	case len(a) == 8 && len(b) == 8:
+		return cmp.Compare(binary.NativeEndian.Uint64(a), binary.NativeEndian.Uint64(b))
+	case len(a) == 4 && len(b) == 4:
+		return cmp.Compare(binary.NativeEndian.Uint32(a), binary.NativeEndian.Uint32(b))
+	case len(a) != len(b):
+		return cmp.Compare(len(a), len(b))
+	}
+	return bytes.Compare(a, b)
+}
+
 // Drop deletes all data in a database, or deletes the database entirely.
 // If del is true, the database is deleted; otherwise it is emptied.
-func (txn *Txn) Drop(dbi DBI, del bool) error {
+func (txn *Txn) Drop(dbi DBI, del bool) (err error) {
 	if !txn.valid() {
 		return NewError(ErrBadTxn)
 	}
 
+	if txn.logOps {
+		var delArg uint64
+		if del {
+			delArg = 1
+		}
+		defer func() { txn.env.opLog.start(opDrop).uint(uint64(dbi)).uint(delArg).end(err) }()
+	}
+
 	if txn.IsReadOnly() {
 		return NewError(ErrPermissionDenied)
 	}
@@ -22,6 +180,21 @@ func (txn *Txn) Drop(dbi DBI, del bool) error {
 		return NewError(ErrBadDBI)
 	}
 
+	txn.emptyTree(dbi)
+
+	if del {
+		// Remove from environment's DBI list
+		txn.env.dbisMu.Lock()
+		txn.env.dbis[dbi] = nil
+		txn.env.dbisMu.Unlock()
+	}
+
+	return nil
+}
+
+// emptyTree resets a database to the empty state and marks it dirty so the
+// change is persisted on commit.
+func (txn *Txn) emptyTree(dbi DBI) {
 	// TODO: Implement tree deletion
 	// 1. Walk the tree and add all pages to free list
 	// 2. Reset tree to empty state
@@ -36,15 +209,6 @@ func (txn *Txn) Drop(dbi DBI, del bool) error {
 	if int(dbi) < len(txn.dbiDirty) {
 		txn.dbiDirty[dbi] = true
 	}
-
-	if del {
-		// Remove from environment's DBI list
-		txn.env.dbisMu.Lock()
-		txn.env.dbis[dbi] = nil
-		txn.env.dbisMu.Unlock()
-	}
-
-	return nil
 }
 
 // DBIFlags returns the flags for a database.
@@ -61,13 +225,20 @@ func (txn *Txn) DBIFlags(dbi DBI) (uint, error) {
 }
 
 // Sequence gets or updates the sequence number for a database.
-// If increment > 0, adds to the sequence and returns the new value.
+// It returns the value the sequence had before the call and, if increment > 0,
+// advances it by increment within the write transaction (like mdbx_dbi_sequence).
+// The counter lives in the tree header, so it is persisted on commit and
+// discarded on abort together with the rest of the transaction's changes.
 // If increment == 0, returns the current value without changing it.
-func (txn *Txn) Sequence(dbi DBI, increment uint64) (uint64, error) {
+func (txn *Txn) Sequence(dbi DBI, increment uint64) (result uint64, err error) {
 	if !txn.valid() {
 		return 0, NewError(ErrBadTxn)
 	}
 
+	if txn.logOps {
+		defer func() { txn.env.opLog.start(opSequence).uint(uint64(dbi)).uint(increment).end(err) }()
+	}
+
 	if int(dbi) >= len(txn.trees) {
 		return 0, NewError(ErrBadDBI)
 	}
@@ -77,10 +248,22 @@ func (txn *Txn) Sequence(dbi DBI, increment uint64) (uint64, error) {
 	}
 
 	t := &txn.trees[dbi]
-	result := t.Sequence
+	result = t.Sequence
 
 	if increment > 0 {
+		// Refuse to wrap around, matching libmdbx (MDBX_RESULT_TRUE)
+		if result+increment < result {
+			return result, NewError(ResultTrue)
+		}
 		t.Sequence += increment
+
+		// Mark the tree as dirty so the new sequence is persisted
+		if txn.dbiDirty == nil {
+			txn.dbiDirty = make([]bool, len(txn.trees))
+		}
+		if int(dbi) < len(txn.dbiDirty) {
+			txn.dbiDirty[dbi] = true
+		}
 	}
 
 	return result, nil
@@ -6,6 +6,9 @@ import "encoding/binary"
 
 // On big-endian architectures, use encoding/binary for correctness
 
+// foreignByteOrder describes a file written on a host of the other byte order.
+const foreignByteOrder = "little-endian file on big-endian host"
+
 //go:nosplit
 func putUint64LE(b []byte, v uint64) {
 	binary.LittleEndian.PutUint64(b, v)
@@ -6,6 +6,9 @@ import "unsafe"
 
 // On little-endian architectures, use direct pointer casts (zero overhead)
 
+// foreignByteOrder describes a file written on a host of the other byte order.
+const foreignByteOrder = "big-endian file on little-endian host"
+
 //go:nosplit
 func putUint64LE(b []byte, v uint64) {
 	*(*uint64)(unsafe.Pointer(&b[0])) = v
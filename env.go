@@ -1,6 +1,9 @@
 package gdbx
 
 import (
+	"crypto/rand"
+	"io"
+	"math/bits"
 	"os"
 	"path/filepath"
 	"sync"
@@ -43,6 +46,10 @@ type Env struct {
 	dataMap  *mmappkg.Map
 	lockFile *lockFile
 
+	// Process-wide sharing (see OpenShared), guarded by openEnvsMu
+	dataFileInfo os.FileInfo // Identity of the data file while registered
+	sharedRefs   int         // References beyond the one taken by Open
+
 	// Old mmaps waiting to be cleaned up (for COW safety)
 	// These are kept alive until no readers need them
 	oldMmaps   []*mmappkg.Map
@@ -64,6 +71,48 @@ type Env struct {
 	geoGrow   uint64 // Growth step in bytes
 	geoShrink uint64 // Shrink threshold in bytes
 
+	geoUpperSet bool // SetGeometry gave an upper limit, which takes precedence over the meta's
+
+	// Adaptive growth (see SetGeometryAdaptive), used by the writer only
+	geoAdaptive bool
+	geoGrowCap  uint64 // Largest growth step in bytes
+	geoStep     uint64 // Current growth step in bytes
+	lastGrowTxn txnid  // Transaction that last grew the map, 0 if none has
+
+	mapGrowths atomic.Uint64 // Times the map was grown since Open, for EnvInfo
+
+	// Readahead policy (see SetReadaheadLimit)
+	readaheadLimit atomic.Int64 // Mapping size above which readahead is disabled; 0 = physical memory
+	readaheadOff   atomic.Bool  // Readahead is currently disabled on the mapping
+
+	allocSequential atomic.Bool // Write txns never reuse freed pages (see SetAllocStrategy)
+
+	safeValues atomic.Bool // Reads return copies rather than slices of the map (see SetSafeValues)
+
+	// Background sync (see StartAutoSync)
+	autoSync      atomic.Pointer[autoSyncer] // Running auto-sync goroutine, if any
+	autoSyncMu    sync.Mutex                 // Serializes starting and stopping it
+	unsyncedBytes atomic.Int64               // Bytes committed since the last sync
+
+	// Group commit (see SetGroupCommit)
+	groupWindow atomic.Int64  // Window in which commits share a sync; 0 = off
+	group       groupSync     // Shared syncs of group commit
+	fsyncs      atomic.Uint64 // Data file syncs, for EnvInfo
+	msyncs      atomic.Uint64 // Ranges of the map synced by WriteMap commits, for EnvInfo
+	msyncBytes  atomic.Uint64 // Bytes of those ranges, for EnvInfo
+
+	redo *redoLog // Redo log, with RedoLog
+
+	// Commit verification (see SetCommitVerify)
+	commitVerify atomic.Bool // Commits read their meta page back
+	metaWriter   io.WriterAt // Writes meta pages; nil writes them to dataFile
+
+	// Change history (see SetChangeHistory)
+	changesOn  atomic.Bool     // Commits record the pages they wrote
+	changesMu  sync.Mutex      // Guards changesMax and changes
+	changesMax int             // Commits to remember
+	changes    []commitChanges // Recent commits, oldest first
+
 	// Meta page tracking (atomic for concurrent read/write txn access)
 	meta atomic.Pointer[metaTriple]
 
@@ -73,14 +122,23 @@ type Env struct {
 	txnCond  *sync.Cond // Condition variable for waiting on write txn
 
 	// Database handles
-	dbis    []*dbiInfo
-	dbisMu  sync.RWMutex
-	mainDBI DBI
-	freeDBI DBI
+	dbis      []*dbiInfo
+	dbisMu    sync.RWMutex
+	dbisTxnID txnid // Snapshot the trees in dbis come from in a read-only Env, 0 if mixed
+	mainDBI   DBI
+	freeDBI   DBI
+
+	valueLimits atomic.Bool // Some table has a DBISpec.MaxValue
 
 	// User context
 	userCtx any
 
+	// Invariant check callback (nil disables checks, see SetAssertHook)
+	assertHook func(cond bool, msg string)
+
+	// Write operation log (nil unless EnableOpLog was called)
+	opLog *opLog
+
 	// mmap version counter - incremented on each remap
 	// Used by cursors to detect stale page references
 	mmapVersion uint64
@@ -96,6 +154,8 @@ type dbiInfo struct {
 	tree  *tree
 	cmp   func(a, b []byte) int // Key comparator
 	dcmp  func(a, b []byte) int // Data comparator (for DUPSORT)
+
+	maxValue int // Largest value Put accepts (DBISpec.MaxValue), 0 for no limit
 }
 
 // NewEnv creates a new environment handle.
@@ -108,7 +168,7 @@ func NewEnv(label Label) (*Env, error) {
 		maxReaders: 126,
 		maxDBs:     16,
 		pageSize:   DefaultPageSize,
-		dbis:       make([]*dbiInfo, MaxDBI),
+		dbis:       make([]*dbiInfo, CoreDBs+MaxDBI),
 	}
 	e.txnCond = sync.NewCond(&e.txnMu)
 	return e, nil
@@ -120,7 +180,24 @@ func (e *Env) valid() bool {
 }
 
 // Open opens the environment at the given path.
+// Another Env in this process with the same data file open maps it again;
+// use OpenShared to share that Env instead.
 func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
+	unlock := lockOpenPath(path, flags)
+	defer unlock()
+
+	if err := e.open(path, flags, mode); err != nil {
+		return err
+	}
+	if err := e.verifyOpened(); err != nil {
+		return err
+	}
+	e.register()
+	return nil
+}
+
+// open opens the data and lock files, maps the data file and reads the metas.
+func (e *Env) open(path string, flags uint, mode os.FileMode) error {
 	if !e.valid() {
 		return NewError(ErrInvalid)
 	}
@@ -132,30 +209,38 @@ func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
 		return NewError(ErrInvalid) // Already open
 	}
 
+	// Without a lock file nothing stops a writer, so there can be none
+	noLock := flags&NoLock != 0
+	if noLock && flags&ReadOnly == 0 {
+		return NewError(ErrIncompatible)
+	}
+
 	e.flags = flags
 	e.path = path
+	if abs, err := filepath.Abs(path); err == nil {
+		e.path = abs
+	}
 
 	// Determine file paths
-	var dataPath, lockPath string
-	if flags&NoSubdir != 0 {
-		dataPath = path
-		lockPath = path + LockSuffix
-	} else {
+	dataPath, lockPath := envFilePaths(path, flags)
+	if flags&NoSubdir == 0 && !noLock {
 		// Create directory if needed
 		if err := os.MkdirAll(path, mode|0700); err != nil {
 			return WrapError(ErrInvalid, err)
 		}
-		dataPath = filepath.Join(path, DataFileName)
-		lockPath = filepath.Join(path, LockFileName)
 	}
 
 	// Open lock file first
-	create := flags&ReadOnly == 0
-	lf, err := openLockFile(lockPath, int(e.maxReaders), create)
-	if err != nil {
-		return WrapError(ErrInvalid, err)
+	if noLock {
+		e.lockFile = memLockFile(int(e.maxReaders))
+	} else {
+		create := flags&ReadOnly == 0
+		lf, err := openLockFile(lockPath, int(e.maxReaders), create)
+		if err != nil {
+			return WrapError(ErrInvalid, err)
+		}
+		e.lockFile = lf
 	}
-	e.lockFile = lf
 
 	// Open data file
 	fileFlags := os.O_RDWR
@@ -172,6 +257,19 @@ func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
 	}
 	e.dataFile = dataFile
 
+	// Every opener holds a shared lock on the data file and an Exclusive
+	// opener an exclusive one, so each kind refuses the other. A NoLock
+	// opener takes none: its media may not support locks.
+	if !noLock {
+		if err := lockDataFile(dataFile, flags&Exclusive != 0); err != nil {
+			e.closeFiles()
+			if err == errLockBusy {
+				return WrapError(ErrBusy, err)
+			}
+			return WrapError(ErrInvalid, err)
+		}
+	}
+
 	// Get file info
 	fi, err := dataFile.Stat()
 	if err != nil {
@@ -181,8 +279,15 @@ func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
 
 	fileSize := fi.Size()
 
-	// Initialize new database if empty
-	if fileSize == 0 {
+	// Refuse foreign or truncated files before mapping them
+	uninitialized, err := e.probeDataFile(fileSize)
+	if err != nil {
+		e.closeFiles()
+		return err
+	}
+
+	// Initialize new database if empty or left half-created
+	if uninitialized {
 		if flags&ReadOnly != 0 {
 			e.closeFiles()
 			return NewError(ErrInvalid)
@@ -195,6 +300,20 @@ func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
 		fileSize = fi.Size()
 	}
 
+	// Replay the redo log onto the data file before mapping it
+	if flags&RedoLog != 0 && flags&ReadOnly == 0 {
+		if flags&WriteMap != 0 {
+			e.closeFiles()
+			return NewError(ErrIncompatible)
+		}
+		if err := e.openRedoLog(dataPath, mode); err != nil {
+			e.closeFiles()
+			return err
+		}
+		fi, _ = dataFile.Stat()
+		fileSize = fi.Size()
+	}
+
 	// Memory-map the data file
 	writable := flags&ReadOnly == 0 && flags&WriteMap != 0
 	dm, err := mmappkg.New(int(dataFile.Fd()), 0, int(fileSize), writable)
@@ -203,12 +322,17 @@ func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
 		return WrapError(ErrInvalid, err)
 	}
 	e.dataMap = dm
+	e.adviseReadahead(dm)
 
 	// Read and validate meta pages
 	if err := e.readMeta(); err != nil {
 		e.closeFiles()
 		return err
 	}
+	if err := e.recoverSteadyMeta(); err != nil {
+		e.closeFiles()
+		return err
+	}
 
 	// Update geometry from meta
 	m := e.meta.Load().recentMeta()
@@ -216,13 +340,19 @@ func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
 		e.closeFiles()
 		return NewError(ErrCorrupted)
 	}
+	if err := e.claimLockFile(m); err != nil {
+		e.closeFiles()
+		return err
+	}
 	e.pageSize = m.pageSize()
 	if e.pageSize == 0 {
 		e.pageSize = DefaultPageSize
 	}
 
 	e.geoLower = uint64(m.Geometry.Lower) * uint64(e.pageSize)
-	e.geoUpper = uint64(m.Geometry.DBPgsize) * uint64(e.pageSize) // DBPgsize holds upper limit, not page size
+	if !e.geoUpperSet {
+		e.geoUpper = uint64(m.Geometry.DBPgsize) * uint64(e.pageSize) // DBPgsize holds upper limit, not page size
+	}
 	e.geoNow = uint64(m.Geometry.Now) * uint64(e.pageSize)
 
 	// Initialize core DBIs
@@ -247,10 +377,95 @@ func (e *Env) Open(path string, flags uint, mode os.FileMode) error {
 	return nil
 }
 
+// probeDataFile inspects the head of the data file before it is mapped.
+// It reports whether the file is uninitialized: either zero length, or zero
+// throughout, which is what a crash inside initNewDB leaves behind (metas
+// are always synced before any data page is written, so no committed data
+// can exist without them). A file with zeroed meta pages but other bytes set
+// has lost its metas, not never had them, and is ErrCorrupted.
+// Otherwise it rejects files that don't start with a gdbx meta page
+// (ErrInvalid), gdbx files written on a host of the other byte order
+// (ErrIncompatible) and gdbx files truncated below their meta pages
+// (ErrCorrupted).
+func (e *Env) probeDataFile(fileSize int64) (bool, error) {
+	if fileSize == 0 {
+		return true, nil
+	}
+
+	headSize := int64(NumMetas) * int64(e.pageSize)
+	if fileSize < headSize {
+		headSize = fileSize
+	}
+	head := make([]byte, headSize)
+	if _, err := e.dataFile.ReadAt(head, 0); err != nil {
+		return false, WrapError(ErrInvalid, err)
+	}
+
+	if isZero(head) {
+		// The file's page size is unknown without a meta, so the rest of
+		// the file is checked whatever the configured one
+		buf := make([]byte, 1<<20)
+		for off := headSize; off < fileSize; off += int64(len(buf)) {
+			n, err := e.dataFile.ReadAt(buf[:min(int64(len(buf)), fileSize-off)], off)
+			if err != nil {
+				return false, WrapError(ErrInvalid, err)
+			}
+			if !isZero(buf[:n]) {
+				return false, WrapError(ErrCorrupted, errDataNoMetas)
+			}
+		}
+		return true, nil
+	}
+
+	// The first meta's magic sits right after the page header
+	if len(head) < pageHeaderSize+8 {
+		return false, WrapError(ErrInvalid, errDataNotGdbx)
+	}
+	magic := *(*uint64)(unsafe.Pointer(&head[pageHeaderSize]))
+	if bits.ReverseBytes64(magic)>>8 == metaMagic {
+		return false, WrapError(ErrIncompatible, errMetaByteSwapped)
+	}
+	if magic>>8 != metaMagic {
+		return false, WrapError(ErrInvalid, errDataNotGdbx)
+	}
+
+	m, err := readMeta(head[pageHeaderSize:])
+	if err != nil {
+		return false, WrapError(ErrCorrupted, errDataTruncated)
+	}
+	pageSize := int64(m.pageSize())
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	if fileSize < int64(NumMetas)*pageSize {
+		return false, WrapError(ErrCorrupted, errDataTruncated)
+	}
+	// The metas sit a page apart: read at another page size, only the first
+	// would be found, and the commits recorded in the others lost
+	e.pageSize = uint32(pageSize)
+	return false, nil
+}
+
+// isZero reports whether b holds only zero bytes.
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // initNewDB initializes a new database file.
 func (e *Env) initNewDB() error {
-	// Use geometry for initial size, with minimum of 3 meta pages
-	initialSize := int64(e.geoNow)
+	e.checkPageSize()
+
+	// Use geometry for initial size, at least the lower bound, with minimum
+	// of 3 meta pages
+	initialSize := int64(max(e.geoNow, e.geoLower))
+	if e.geoUpperSet && uint64(initialSize) > e.geoUpper {
+		initialSize = int64(e.geoUpper)
+	}
 	minSize := int64(NumMetas) * int64(e.pageSize)
 	if initialSize < minSize {
 		initialSize = minSize
@@ -264,7 +479,9 @@ func (e *Env) initNewDB() error {
 		return WrapError(ErrInvalid, err)
 	}
 
-	// Write meta pages
+	// Write meta pages, all with the database's unique id
+	var dxbid [16]byte
+	rand.Read(dxbid[:])
 	for i := 0; i < NumMetas; i++ {
 		metaPage := make([]byte, e.pageSize)
 		txnID := txnid(InitialTxnID - uint64(NumMetas-1-i))
@@ -279,6 +496,10 @@ func (e *Env) initNewDB() error {
 		// Write meta content starting after page header (offset 20)
 		m := (*meta)(unsafe.Pointer(&metaPage[pageHeaderSize]))
 		initMeta(m, e.pageSize, txnID)
+		m.DXBID = dxbid
+		if e.geoUpperSet {
+			m.Geometry.DBPgsize = pgno(e.geoUpper / uint64(e.pageSize))
+		}
 
 		offset := int64(i) * int64(e.pageSize)
 		if _, err := e.dataFile.WriteAt(metaPage, offset); err != nil {
@@ -309,6 +530,9 @@ func (e *Env) readMeta() error {
 	// Always create a new metaTriple and atomically swap to avoid race
 	// with concurrent readers calling recentMeta()
 	mt, err := newMetaTriple(pages)
+	if err == errMetaByteSwapped {
+		return WrapError(ErrIncompatible, err)
+	}
 	if err != nil {
 		return WrapError(ErrCorrupted, err)
 	}
@@ -317,6 +541,45 @@ func (e *Env) readMeta() error {
 	return nil
 }
 
+// recoverSteadyMeta falls back to the last steady meta when the newer ones
+// are weak and the system has restarted since they were written: their
+// pages were never synced, so they may not have reached the disk. The weak
+// metas are overwritten with the steady one, which a ReadOnly environment
+// can't do, so it fails with ErrWannaRecovery instead. Where the boot ID is
+// unknown the weak metas are kept.
+func (e *Env) recoverSteadyMeta() error {
+	mt := e.meta.Load()
+	m := mt.recentMeta()
+	boot := bootID()
+	if m.isSteady() || mt.steady == mt.recent || boot == [16]byte{} || m.BootID == boot {
+		return nil
+	}
+	// The redo log has already brought the data file up to its last commit
+	if e.redo != nil {
+		return nil
+	}
+	if e.flags&ReadOnly != 0 {
+		return NewError(ErrWannaRecovery)
+	}
+
+	pageSize := int(e.pageSize)
+	steady := e.dataMap.Data()[mt.steady*pageSize : (mt.steady+1)*pageSize]
+	for i := 0; i < NumMetas; i++ {
+		if mt.metas[i] == nil || mt.txnids[i] <= mt.txnids[mt.steady] {
+			continue
+		}
+		page := append([]byte(nil), steady...)
+		(*pageHeader)(unsafe.Pointer(&page[0])).PageNo = pgno(i)
+		if _, err := e.dataFile.WriteAt(page, int64(i*pageSize)); err != nil {
+			return WrapError(ErrProblem, err)
+		}
+	}
+	if err := e.syncDataFile(); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	return e.readMeta()
+}
+
 // closeFiles closes all open files.
 func (e *Env) closeFiles() {
 	if e.spillBuf != nil {
@@ -337,6 +600,10 @@ func (e *Env) closeFiles() {
 	e.oldMmaps = nil
 	e.oldMmapsMu.Unlock()
 
+	if e.redo != nil {
+		e.redo.file.Close()
+		e.redo = nil
+	}
 	if e.dataFile != nil {
 		e.dataFile.Close()
 		e.dataFile = nil
@@ -388,10 +655,33 @@ func (e *Env) tryCleanupOldMmaps() {
 // Close closes the environment and releases resources.
 // Waits for all active read transactions to finish before unmapping.
 func (e *Env) Close() {
+	e.close(false)
+}
+
+// close implements Close and CloseEx.
+func (e *Env) close(dontSync bool) {
 	if !e.valid() {
 		return
 	}
 
+	// Shared environments stay open until their last reference is closed
+	if !e.release() {
+		return
+	}
+
+	// Stop the auto-sync goroutine, and sync what was committed without a
+	// sync unless a write transaction is still running
+	if e.autoSync.Load() != nil {
+		e.StopAutoSync()
+	}
+	if !dontSync {
+		e.txnMu.Lock()
+		if e.writeTxn == nil {
+			e.syncUnsynced()
+		}
+		e.txnMu.Unlock()
+	}
+
 	// Mark as closing first (under lock) to prevent new readers
 	e.mu.Lock()
 	e.signature = 0
@@ -401,6 +691,12 @@ func (e *Env) Close() {
 	// This prevents SIGSEGV from readers accessing unmapped memory
 	e.txnWg.Wait()
 
+	// Fold the redo log into the data file, unless the caller asked not to sync
+	if e.redo != nil && !dontSync && e.lockFile.lockWriter() == nil {
+		e.checkpointRedo()
+		e.lockFile.unlockWriter()
+	}
+
 	// Now safe to close files and unmap
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -413,28 +709,40 @@ func (e *Env) CloseEx(dontSync bool) {
 	if !dontSync && e.dataMap != nil && e.dataMap.Writable() {
 		e.dataMap.Sync()
 	}
-	e.Close()
+	e.close(dontSync)
 }
 
 // Sync flushes the environment to disk.
 // If force is true, a synchronous flush is performed.
 // If nonblock is true, the function returns immediately if a sync is already in progress.
+// A synchronous flush also signs the last commit steady, unless a write
+// transaction is running, so that Open keeps it after a system restart.
 func (e *Env) Sync(force bool, nonblock bool) error {
 	if !e.valid() {
 		return NewError(ErrInvalid)
 	}
 
 	e.mu.RLock()
-	defer e.mu.RUnlock()
-
 	if e.dataMap == nil {
+		e.mu.RUnlock()
 		return NewError(ErrInvalid)
 	}
+	if !force {
+		defer e.mu.RUnlock()
+		return e.dataMap.SyncAsync()
+	}
+	err := e.dataMap.Sync()
+	e.mu.RUnlock()
+	if err != nil {
+		return err
+	}
 
-	if force {
-		return e.dataMap.Sync()
+	e.txnMu.Lock()
+	defer e.txnMu.Unlock()
+	if e.writeTxn != nil {
+		return nil
 	}
-	return e.dataMap.SyncAsync()
+	return e.syncUnsynced()
 }
 
 // SetMaxDBs sets the maximum number of named databases.
@@ -495,7 +803,11 @@ func (e *Env) SetPageSize(size uint32) error {
 	return nil
 }
 
-// SetGeometry sets the database size parameters.
+// SetGeometry sets the database size parameters. A database created by the
+// next Open starts at sizeNow or sizeLower bytes, whichever is larger, so a
+// bulk load into it doesn't remap until it outgrows them; the file is
+// extended without writing, so on file systems with sparse files the space
+// is only allocated as pages are written.
 func (e *Env) SetGeometry(sizeLower, sizeNow, sizeUpper, growthStep, shrinkThreshold int64, pageSize int) error {
 	if !e.valid() {
 		return NewError(ErrInvalid)
@@ -520,6 +832,7 @@ func (e *Env) SetGeometry(sizeLower, sizeNow, sizeUpper, growthStep, shrinkThres
 	}
 	if sizeUpper > 0 {
 		e.geoUpper = uint64(sizeUpper)
+		e.geoUpperSet = true
 	}
 	if sizeNow > 0 {
 		e.geoNow = uint64(sizeNow)
@@ -534,7 +847,90 @@ func (e *Env) SetGeometry(sizeLower, sizeNow, sizeUpper, growthStep, shrinkThres
 	return nil
 }
 
-// Path returns the environment path.
+// Adaptive growth tuning, in commits.
+const (
+	// adaptiveGrowBurst is how close to the last growth the map must fill
+	// again for the step to double.
+	adaptiveGrowBurst = 4
+	// adaptiveGrowCalm is how many commits without growth halve the step.
+	adaptiveGrowCalm = 64
+	// adaptiveGrowStart is the first step when SetGeometry gave none.
+	adaptiveGrowStart = 1 << 20
+)
+
+// SetGeometryAdaptive bounds the map between min and max bytes, as the
+// lower and upper sizes of SetGeometry, and makes its growth step adapt to
+// the write rate. The step starts at the growth step given to SetGeometry,
+// or 1MB, and doubles, up to cap bytes, each time the map fills again within
+// a few commits of growing; after a long run of commits that did not grow the
+// map it halves back towards its start. A steady stream of writes then
+// remaps the file ever more rarely, while a quiet database does not reserve
+// space it will not use. Values <= 0 for min and max keep the current sizes.
+func (e *Env) SetGeometryAdaptive(min, max, cap int64) error {
+	if cap <= 0 || (min > 0 && max > 0 && min > max) {
+		return NewError(ErrInvalid)
+	}
+	if err := e.SetGeometry(min, -1, max, -1, -1, -1); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.geoAdaptive = true
+	e.geoGrowCap = uint64(cap)
+	e.geoStep = e.baseGrowStep()
+	return nil
+}
+
+// baseGrowStep returns the step adaptive growth starts from and falls back to.
+func (e *Env) baseGrowStep() uint64 {
+	step := e.geoGrow
+	if step == 0 {
+		step = adaptiveGrowStart
+	}
+	return min(step, e.geoGrowCap)
+}
+
+// growSize returns the size to grow the map to for txn to hold needed
+// bytes, stepping by the growth step or, without one, by defaultStep. With
+// adaptive growth it first adjusts the step to how recently the map last
+// grew. The size never exceeds the upper limit unless needed does.
+func (e *Env) growSize(needed int64, txn txnid, defaultStep int64) int64 {
+	step := int64(e.geoGrow)
+	if e.geoAdaptive {
+		if e.lastGrowTxn != 0 {
+			switch since := txn - e.lastGrowTxn; {
+			case since <= adaptiveGrowBurst:
+				e.geoStep = min(e.geoStep*2, e.geoGrowCap)
+			case since > adaptiveGrowCalm:
+				e.geoStep = max(e.geoStep/2, e.baseGrowStep())
+			}
+		}
+		e.lastGrowTxn = txn
+		step = int64(e.geoStep)
+	}
+	if step <= 0 {
+		step = defaultStep
+	}
+	size := needed
+	if step > 0 {
+		size = (needed + step - 1) / step * step
+	}
+	// Align to system page size for mdbx read-only compatibility
+	size = alignToSysPageSize(size)
+	if e.geoUpper > 0 && uint64(size) > e.geoUpper {
+		size = max(int64(e.geoUpper), alignToSysPageSize(needed))
+	}
+	return size
+}
+
+// pageLimit returns the maximum number of pages in the data file, or 0 if
+// the map has no upper limit.
+func (e *Env) pageLimit() uint64 {
+	return e.geoUpper / uint64(e.pageSize)
+}
+
+// Path returns the environment path, made absolute when the environment
+// was opened.
 func (e *Env) Path() string {
 	return e.path
 }
@@ -609,6 +1005,17 @@ func (e *Env) UserCtx() any {
 	return e.userCtx
 }
 
+// SetAssertHook installs a callback for internal invariant checks on the
+// write path: page bounds, key order within pages after insert and split,
+// and Items accounting after insert and delete. Each check calls hook with
+// its outcome and a description; a test or fuzzer typically fails when cond
+// is false. Checks only run while a hook is installed, so environments
+// without one pay a single nil check. Pass nil to disable.
+// Install the hook before starting write transactions.
+func (e *Env) SetAssertHook(hook func(cond bool, msg string)) {
+	e.assertHook = hook
+}
+
 // BeginTxn starts a new transaction.
 func (e *Env) BeginTxn(parent *Txn, flags uint) (*Txn, error) {
 	if !e.valid() {
@@ -621,8 +1028,28 @@ func (e *Env) BeginTxn(parent *Txn, flags uint) (*Txn, error) {
 	return e.beginWriteTxn(parent, flags)
 }
 
-// beginReadTxn starts a read-only transaction.
+// beginReadTxn starts a read-only transaction. In a read-only Env it first
+// catches up with commits made by other processes (see followWriter).
 func (e *Env) beginReadTxn() (*Txn, error) {
+	if e.flags&ReadOnly == 0 {
+		return e.openReadTxn()
+	}
+	if err := e.followWriter(); err != nil {
+		return nil, err
+	}
+	txn, err := e.openReadTxn()
+	if err != nil {
+		return nil, err
+	}
+	if err := txn.followNamedTrees(); err != nil {
+		txn.Abort()
+		return nil, err
+	}
+	return txn, nil
+}
+
+// openReadTxn starts a read-only transaction on the newest meta the Env knows.
+func (e *Env) openReadTxn() (*Txn, error) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
@@ -642,7 +1069,12 @@ func (e *Env) beginReadTxn() (*Txn, error) {
 		e.lockFile.releaseReaderSlot(slot, slotIdx)
 		return nil, NewError(ErrCorrupted)
 	}
+	return e.initReadTxn(slot, slotIdx, meta), nil
+}
 
+// initReadTxn sets up a read-only transaction on the snapshot of meta in
+// reader slot slot, pinning it there. The caller holds e.mu for reading.
+func (e *Env) initReadTxn(slot *readerSlot, slotIdx int, meta *meta) *Txn {
 	// Get transaction from cache
 	txn := getReadTxnFromCache()
 
@@ -663,25 +1095,30 @@ func (e *Env) beginReadTxn() (*Txn, error) {
 	txn.userCtx = nil
 
 	// Reuse or allocate slices - avoid clearing loops by using clear() builtin
-	maxDBs := int(e.maxDBs)
-	if cap(txn.dbiComparators) >= maxDBs {
-		txn.dbiComparators = txn.dbiComparators[:maxDBs]
+	slots := e.dbiSlots()
+	if cap(txn.dbiComparators) >= slots {
+		txn.dbiComparators = txn.dbiComparators[:slots]
 		clear(txn.dbiComparators)
 	} else {
-		txn.dbiComparators = make([]func(a, b []byte) int, maxDBs)
+		txn.dbiComparators = make([]func(a, b []byte) int, slots)
 	}
 
-	if cap(txn.dbiUsesDefaultCmp) >= maxDBs {
-		txn.dbiUsesDefaultCmp = txn.dbiUsesDefaultCmp[:maxDBs]
+	if cap(txn.dbiUsesDefaultCmp) >= slots {
+		txn.dbiUsesDefaultCmp = txn.dbiUsesDefaultCmp[:slots]
 		clear(txn.dbiUsesDefaultCmp)
 	} else {
-		txn.dbiUsesDefaultCmp = make([]bool, maxDBs)
+		txn.dbiUsesDefaultCmp = make([]bool, slots)
 	}
 
-	if cap(txn.trees) >= maxDBs {
-		txn.trees = txn.trees[:maxDBs]
+	// Dup comparators are filled lazily by initDupComparator; drop the previous
+	// transaction's, whose DBI slots may now hold other tables
+	clear(txn.dbiDupComparators)
+	clear(txn.dbiUsesDefaultDupCmp)
+
+	if cap(txn.trees) >= slots {
+		txn.trees = txn.trees[:slots]
 	} else {
-		txn.trees = make([]tree, maxDBs)
+		txn.trees = make([]tree, slots)
 	}
 
 	// Set reader's txnid
@@ -693,22 +1130,23 @@ func (e *Env) beginReadTxn() (*Txn, error) {
 
 	// Track reader for safe Close() - Close() will wait for all readers to finish
 	e.txnWg.Add(1)
+	txn.syncGuard()
 
 	// Copy tree state for core DBIs
 	txn.trees[FreeDBI] = meta.GCTree
 	txn.trees[MainDBI] = meta.MainTree
 
 	// Copy tree state for named DBIs that are already opened
-	// Use maxDBs (user-configured limit) instead of len(e.dbis) which is MaxDBI (32765)
+	// Only the configured slots can be in use, not all of e.dbis
 	e.dbisMu.RLock()
-	for i := CoreDBs; i < maxDBs; i++ {
+	for i := CoreDBs; i < slots; i++ {
 		if e.dbis[i] != nil && e.dbis[i].tree != nil {
 			txn.trees[i] = *e.dbis[i].tree
 		}
 	}
 	e.dbisMu.RUnlock()
 
-	return txn, nil
+	return txn
 }
 
 // beginWriteTxn starts a write transaction.
@@ -761,13 +1199,24 @@ func (e *Env) beginWriteTxn(parent *Txn, flags uint) (*Txn, error) {
 	txn.txnID = meta.txnID() + 1
 	txn.parent = parent
 	txn.allocatedPg = meta.Geometry.Now
+	txn.firstNewPg = txn.allocatedPg
 	txn.cursors = nil
 	txn.dbiDirty = nil
+	txn.appendHints = txn.appendHints[:0]
+	txn.appendGuards = txn.appendGuards[:0]
+	txn.onSplit = nil
+	if parent != nil {
+		txn.onSplit = parent.onSplit
+	}
+	txn.broken = false
+	txn.counters = TxnCounters{}
 	txn.userCtx = nil
 
 	// Clear dirty page tracker for reuse
 	txn.dirtyTracker.clear()
 	txn.hasNonMmapPages = false
+	txn.inPlacePages = txn.inPlacePages[:0]
+	txn.mapSpans = txn.mapSpans[:0]
 
 	// Reuse or create free pages slice
 	if txn.freePages == nil {
@@ -775,23 +1224,31 @@ func (e *Env) beginWriteTxn(parent *Txn, flags uint) (*Txn, error) {
 	} else {
 		txn.freePages = txn.freePages[:0]
 	}
+	txn.allocSequential = e.allocSequential.Load()
+	txn.arenaNext, txn.arenaEnd, txn.arenaChunk = 0, 0, 0
 
 	// Reuse or create caches
-	if txn.dbiComparators == nil || len(txn.dbiComparators) < int(e.maxDBs) {
-		txn.dbiComparators = make([]func(a, b []byte) int, e.maxDBs)
+	slots := e.dbiSlots()
+	if txn.dbiComparators == nil || len(txn.dbiComparators) < slots {
+		txn.dbiComparators = make([]func(a, b []byte) int, slots)
 	} else {
-		clear(txn.dbiComparators[:e.maxDBs])
+		clear(txn.dbiComparators[:slots])
 	}
 
-	if txn.dbiUsesDefaultCmp == nil || len(txn.dbiUsesDefaultCmp) < int(e.maxDBs) {
-		txn.dbiUsesDefaultCmp = make([]bool, e.maxDBs)
+	if txn.dbiUsesDefaultCmp == nil || len(txn.dbiUsesDefaultCmp) < slots {
+		txn.dbiUsesDefaultCmp = make([]bool, slots)
 	} else {
-		clear(txn.dbiUsesDefaultCmp[:e.maxDBs])
+		clear(txn.dbiUsesDefaultCmp[:slots])
 	}
 
+	// Dup comparators are filled lazily by initDupComparator; drop the previous
+	// transaction's, whose DBI slots may now hold other tables
+	clear(txn.dbiDupComparators)
+	clear(txn.dbiUsesDefaultDupCmp)
+
 	// Reuse or create trees slice
-	if txn.trees == nil || len(txn.trees) < int(e.maxDBs) {
-		txn.trees = make([]tree, e.maxDBs)
+	if txn.trees == nil || len(txn.trees) < slots {
+		txn.trees = make([]tree, slots)
 	}
 
 	// Initialize mmap cache while holding the lock
@@ -806,9 +1263,9 @@ func (e *Env) beginWriteTxn(parent *Txn, flags uint) (*Txn, error) {
 	txn.trees[MainDBI] = meta.MainTree
 
 	// Copy tree state for named DBIs that are already opened
-	// Use e.maxDBs (user-configured limit) instead of len(e.dbis) which is MaxDBI (32765)
+	// Only the configured slots can be in use, not all of e.dbis
 	e.dbisMu.RLock()
-	for i := CoreDBs; i < int(e.maxDBs); i++ {
+	for i := CoreDBs; i < slots; i++ {
 		if e.dbis[i] != nil && e.dbis[i].tree != nil {
 			txn.trees[i] = *e.dbis[i].tree
 		}
@@ -818,6 +1275,11 @@ func (e *Env) beginWriteTxn(parent *Txn, flags uint) (*Txn, error) {
 	e.writeTxn = txn
 	e.txnMu.Unlock()
 
+	txn.logOps = e.opLog != nil
+	if txn.logOps {
+		e.opLog.start(opBegin).uint(uint64(flags)).end(nil)
+	}
+
 	return txn, nil
 }
 
@@ -873,7 +1335,8 @@ type EnvInfo struct {
 	LastPgNo          int64
 	LastTxnID         uint64
 	RecentTxnID       uint64
-	LatterReaderTxnID uint64
+	LatterReaderTxnID uint64 // Snapshot of the oldest reader, or RecentTxnID without readers
+	ReaderLag         uint64 // Commits since the oldest reader's snapshot (RecentTxnID - LatterReaderTxnID)
 	MaxReaders        uint32
 	NumReaders        uint32
 	PageSize          uint32
@@ -885,6 +1348,12 @@ type EnvInfo struct {
 	AutosyncPeriod    Duration16dot16
 	SinceReaderCheck  Duration16dot16
 	Flags             uint32
+	MapGrowths        uint64 // Times the map was grown since Open
+	MsyncBytes        uint64 // Bytes of the map synced by range by WriteMap commits since Open
+	ActiveMetaIndex   int    // Meta page slot (0-2) holding RecentTxnID
+	Meta0Txnid        uint64 // Txnid of meta slot 0, or 0 if it isn't valid
+	Meta1Txnid        uint64 // Txnid of meta slot 1, or 0 if it isn't valid
+	Meta2Txnid        uint64 // Txnid of meta slot 2, or 0 if it isn't valid
 	// Legacy fields for backward compatibility
 	GeoLower   uint64
 	GeoUpper   uint64
@@ -942,6 +1411,24 @@ func (e *Env) Info(txn *Txn) (*EnvInfo, error) {
 		lastTxnID = uint64(txn.txnID)
 	}
 
+	// The oldest reader pins the pages freed since its snapshot
+	recentTxnID := uint64(m.txnID())
+	latterReader := recentTxnID
+	var numReaders int
+	if e.lockFile != nil {
+		if oldest := e.lockFile.oldestReader(); oldest < latterReader {
+			latterReader = oldest
+		}
+		numReaders = e.lockFile.numActiveReaders()
+	}
+
+	var autoSyncBytes uint64
+	var autoSyncPeriod time.Duration
+	if s := e.autoSync.Load(); s != nil {
+		autoSyncBytes = uint64(s.bytes)
+		autoSyncPeriod = s.period
+	}
+
 	return &EnvInfo{
 		Geo: EnvInfoGeo{
 			Lower:   geoLower,
@@ -950,24 +1437,31 @@ func (e *Env) Info(txn *Txn) (*EnvInfo, error) {
 			Shrink:  geoShrink,
 			Grow:    geoGrow,
 		},
-		PageOps:           EnvInfoPageOps{}, // Page op stats not tracked yet
+		PageOps:           EnvInfoPageOps{Msync: e.msyncs.Load(), Fsync: e.fsyncs.Load()}, // Other page op stats not tracked yet
+		MsyncBytes:        e.msyncBytes.Load(),
 		MapSize:           mapSize,
 		LastPNO:           lastPgNo,
 		LastPgNo:          lastPgNo,
 		LastTxnID:         lastTxnID,
-		RecentTxnID:       uint64(m.txnID()),
-		LatterReaderTxnID: 0, // Would need to scan reader table
+		RecentTxnID:       recentTxnID,
+		LatterReaderTxnID: latterReader,
+		ReaderLag:         recentTxnID - latterReader,
 		MaxReaders:        e.maxReaders,
-		NumReaders:        0, // Would need to scan reader table
+		NumReaders:        uint32(numReaders),
 		PageSize:          e.pageSize,
 		SystemPageSize:    4096, // OS page size, typically 4KB
 		MiLastPgNo:        uint64(lastPgNo),
-		AutoSyncThreshold: 0,
-		UnsyncedBytes:     0,
+		AutoSyncThreshold: autoSyncBytes,
+		UnsyncedBytes:     uint64(e.unsyncedBytes.Load()),
 		SinceSync:         0,
-		AutosyncPeriod:    0,
+		AutosyncPeriod:    NewDuration16dot16(autoSyncPeriod),
 		SinceReaderCheck:  0,
 		Flags:             uint32(e.flags),
+		MapGrowths:        e.mapGrowths.Load(),
+		ActiveMetaIndex:   mt.recent,
+		Meta0Txnid:        uint64(mt.txnids[0]),
+		Meta1Txnid:        uint64(mt.txnids[1]),
+		Meta2Txnid:        uint64(mt.txnids[2]),
 		GeoLower:          geoLower,
 		GeoUpper:          geoUpper,
 		GeoCurrent:        geoCurrent,
@@ -991,6 +1485,24 @@ func (e *Env) MaxDBs() uint32 {
 	return e.maxDBs
 }
 
+// dbiSlots returns the number of handles: the core databases' and one per
+// named database allowed.
+func (e *Env) dbiSlots() int {
+	return CoreDBs + int(e.maxDBs)
+}
+
+// hasFreeDBISlot reports whether another named database can get a handle.
+func (e *Env) hasFreeDBISlot() bool {
+	e.dbisMu.RLock()
+	defer e.dbisMu.RUnlock()
+	for i := CoreDBs; i < e.dbiSlots(); i++ {
+		if e.dbis[i] == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // MaxReaders returns the maximum number of readers.
 func (e *Env) MaxReaders() uint32 {
 	return e.maxReaders
@@ -1043,13 +1555,23 @@ type ReaderInfo struct {
 	Thread uint64
 	Bytes  uint64
 	RetxL  uint64
+	Lag    uint64 // Commits since the reader's snapshot
 }
 
-// ReaderList returns information about all active readers.
+// ReaderList returns information about all active readers. With Info's
+// LatterReaderTxnID it finds the reader that keeps old pages from being
+// reused.
 func (e *Env) ReaderList(fn func(info ReaderInfo) error) error {
 	if e.lockFile == nil {
 		return NewError(ErrInvalid)
 	}
+	var recent uint64
+	if mt := e.meta.Load(); mt != nil {
+		if m := mt.recentMeta(); m != nil {
+			recent = uint64(m.txnID())
+		}
+	}
+
 	// Iterate through reader slots
 	slots := e.lockFile.slots
 	if e.lockFile.lockless {
@@ -1060,6 +1582,10 @@ func (e *Env) ReaderList(fn func(info ReaderInfo) error) error {
 		if slot.txnid == 0 {
 			continue
 		}
+		var lag uint64
+		if slot.txnid != ^uint64(0) && slot.txnid < recent {
+			lag = recent - slot.txnid
+		}
 		info := ReaderInfo{
 			Slot:   i,
 			TxnID:  slot.txnid,
@@ -1067,6 +1593,7 @@ func (e *Env) ReaderList(fn func(info ReaderInfo) error) error {
 			Thread: slot.tid,
 			Bytes:  uint64(slot.snapshotPagesUsed) * uint64(e.pageSize),
 			RetxL:  slot.snapshotPagesRetired,
+			Lag:    lag,
 		}
 		if err := fn(info); err != nil {
 			return err
@@ -1076,10 +1603,15 @@ func (e *Env) ReaderList(fn func(info ReaderInfo) error) error {
 }
 
 // Copy copies the environment to a path.
+// With CopyCompact the copy rebuilds every table densely instead of copying
+// pages, leaving out the space the source's history has left unused.
 func (e *Env) Copy(path string, flags uint) error {
 	if !e.valid() {
 		return NewError(ErrInvalid)
 	}
+	if flags&CopyCompact != 0 {
+		return e.copyCompact(path)
+	}
 
 	// Open destination file
 	f, err := os.Create(path)
@@ -1088,15 +1620,23 @@ func (e *Env) Copy(path string, flags uint) error {
 	}
 	defer f.Close()
 
-	return e.CopyFD(f.Fd(), flags)
+	return e.copyTo(f)
 }
 
 // CopyFD copies the environment to a file descriptor.
+// CopyCompact is honored as in Copy.
 func (e *Env) CopyFD(fd uintptr, flags uint) error {
 	if !e.valid() {
 		return NewError(ErrInvalid)
 	}
+	if flags&CopyCompact != 0 {
+		return e.copyCompactTo(os.NewFile(fd, ""))
+	}
+	return e.copyTo(os.NewFile(fd, ""))
+}
 
+// copyTo writes the data file, as of a read snapshot, to dstFile.
+func (e *Env) copyTo(dstFile *os.File) error {
 	// Start a read transaction to get a consistent snapshot
 	txn, err := e.BeginTxn(nil, TxnReadOnly)
 	if err != nil {
@@ -1104,28 +1644,24 @@ func (e *Env) CopyFD(fd uintptr, flags uint) error {
 	}
 	defer txn.Abort()
 
-	// Get the source file
-	srcFD, err := e.FD()
-	if err != nil {
-		return err
-	}
-
 	// Get file size from meta
 	m := e.meta.Load().recentMeta()
 	if m == nil {
 		return NewError(ErrCorrupted)
 	}
-	fileSize := int64(m.Geometry.Now) * int64(e.pageSize)
-
-	// Copy the data
-	srcFile := os.NewFile(srcFD, "")
-	dstFile := os.NewFile(fd, "")
-
-	// Reset to beginning
-	if _, err := srcFile.Seek(0, 0); err != nil {
+	if err := e.copyDataFile(dstFile, int64(m.Geometry.Now)*int64(e.pageSize)); err != nil {
 		return err
 	}
 
+	// Sync destination
+	return dstFile.Sync()
+}
+
+// copyDataFile writes the first fileSize bytes of the data file to dstFile.
+func (e *Env) copyDataFile(dstFile *os.File, fileSize int64) error {
+	// Read the data file at explicit offsets, so the environment's own
+	// handle is neither moved nor wrapped in a second *os.File that would
+	// close it when collected
 	buf := make([]byte, 64*1024) // 64KB buffer
 	var written int64
 	for written < fileSize {
@@ -1133,7 +1669,7 @@ func (e *Env) CopyFD(fd uintptr, flags uint) error {
 		if toRead > int64(len(buf)) {
 			toRead = int64(len(buf))
 		}
-		n, err := srcFile.Read(buf[:toRead])
+		n, err := e.dataFile.ReadAt(buf[:toRead], written)
 		if err != nil {
 			return err
 		}
@@ -1142,9 +1678,7 @@ func (e *Env) CopyFD(fd uintptr, flags uint) error {
 		}
 		written += int64(n)
 	}
-
-	// Sync destination
-	return dstFile.Sync()
+	return nil
 }
 
 // UpdateLocked behaves like Update but does not lock the calling goroutine.
@@ -1218,7 +1752,7 @@ func (e *Env) SetOption(option uint, value uint64) error {
 	}
 	switch option {
 	case OptMaxDB:
-		e.maxDBs = uint32(value)
+		return e.SetMaxDBs(uint32(min(value, MaxDBI)))
 	case OptMaxReaders:
 		e.maxReaders = uint32(value)
 	default:
@@ -1399,23 +1933,16 @@ func (e *Env) extendMmap(needPgno pgno) bool {
 		return true
 	}
 
-	// Calculate new size with growth step
-	growStep := int64(e.geoGrow)
-	if growStep <= 0 {
-		growStep = 64 * 1024 * 1024 // Default 64MB growth
+	if e.geoUpper > 0 && uint64(neededSize) > e.geoUpper {
+		return false // Can't grow enough
 	}
-	newSize := ((neededSize + growStep - 1) / growStep) * growStep
-
-	// Align to system page size for mdbx read-only compatibility
-	newSize = alignToSysPageSize(newSize)
 
-	// Cap at upper limit
-	if e.geoUpper > 0 && uint64(newSize) > e.geoUpper {
-		newSize = int64(e.geoUpper)
-		if neededSize > newSize {
-			return false // Can't grow enough
-		}
+	// Calculate new size with growth step, 64MB by default
+	var txn txnid
+	if e.writeTxn != nil {
+		txn = e.writeTxn.txnID
 	}
+	newSize := e.growSize(neededSize, txn, 64*1024*1024)
 
 	// Extend the file first
 	if err := e.dataFile.Truncate(newSize); err != nil {
@@ -1426,9 +1953,11 @@ func (e *Env) extendMmap(needPgno pgno) bool {
 	if err := e.dataMap.Remap(newSize); err != nil {
 		return false
 	}
+	e.adviseReadahead(e.dataMap)
 
 	// Increment mmap version so cursors know to refresh their cached page references
 	e.mmapVersion++
+	e.mapGrowths.Add(1)
 
 	// Reload meta pointers to point to new mmap
 	if err := e.readMeta(); err != nil {
@@ -1477,6 +2006,7 @@ func (e *Env) PreExtendMmap(size int64) error {
 	if err != nil {
 		return WrapError(ErrProblem, err)
 	}
+	e.adviseReadahead(newMap)
 
 	// Update dataMap atomically
 	e.mu.Lock()
@@ -0,0 +1,82 @@
+package gdbx
+
+// AllocStrategy selects how write transactions allocate pages.
+type AllocStrategy int
+
+const (
+	// AllocReuseFirst reuses pages freed earlier in the same transaction
+	// before extending the file. It is the default.
+	AllocReuseFirst AllocStrategy = iota
+
+	// AllocSequential always takes new pages from the end of the file,
+	// keeping append-heavy trees in file order at the cost of leaving the
+	// pages a transaction frees unused until a later one.
+	AllocSequential
+)
+
+// SetAllocStrategy selects the page allocation strategy of write
+// transactions. It can be called before or after Open and applies to write
+// transactions that begin afterwards.
+func (e *Env) SetAllocStrategy(strategy AllocStrategy) error {
+	if !e.valid() || strategy < AllocReuseFirst || strategy > AllocSequential {
+		return NewError(ErrInvalid)
+	}
+	e.allocSequential.Store(strategy == AllocSequential)
+	return nil
+}
+
+// overflowArenaMaxPages bounds the pages reserved for an overflow arena at
+// once.
+const overflowArenaMaxPages = 256
+
+// allocOverflowPages takes n consecutive page numbers for an overflow run
+// from the transaction's overflow arena, reserving a new arena if the
+// current one is too small. Arenas keep a transaction's overflow runs back
+// to back instead of interleaved with its tree pages, and grow with the
+// overflow pages used, up to overflowArenaMaxPages. It fails with ErrMapFull
+// only if n pages can't be allocated.
+func (txn *Txn) allocOverflowPages(n int) (pgno, error) {
+	if int(txn.arenaEnd-txn.arenaNext) < n {
+		if txn.arenaEnd != 0 && txn.arenaEnd == txn.allocatedPg {
+			// Nothing was allocated after the arena: extend it in place
+			grow := max(n-int(txn.arenaEnd-txn.arenaNext), txn.arenaChunk)
+			if _, err := txn.allocPages(grow); err != nil {
+				grow = n - int(txn.arenaEnd-txn.arenaNext)
+				if _, err := txn.allocPages(grow); err != nil {
+					return 0, err
+				}
+			}
+			txn.arenaEnd += pgno(grow)
+		} else {
+			size := max(n, txn.arenaChunk)
+			first, err := txn.allocPages(size)
+			if err != nil && size > n {
+				size = n
+				first, err = txn.allocPages(size)
+			}
+			if err != nil {
+				return 0, err
+			}
+			txn.releaseOverflowArena()
+			txn.arenaNext, txn.arenaEnd = first, first+pgno(size)
+		}
+	}
+	first := txn.arenaNext
+	txn.arenaNext += pgno(n)
+	txn.arenaChunk = min(txn.arenaChunk+n, overflowArenaMaxPages)
+	return first, nil
+}
+
+// releaseOverflowArena gives back the unused pages of the overflow arena:
+// to the end of the file if the arena ends there, otherwise to the free
+// pages of the transaction.
+func (txn *Txn) releaseOverflowArena() {
+	if txn.arenaEnd == txn.allocatedPg {
+		txn.allocatedPg = txn.arenaNext
+	} else {
+		for pg := txn.arenaNext; pg < txn.arenaEnd; pg++ {
+			txn.freePage(pg)
+		}
+	}
+	txn.arenaNext, txn.arenaEnd = 0, 0
+}
@@ -0,0 +1,187 @@
+package gdbx
+
+import (
+	"time"
+	"unsafe"
+)
+
+// autoSyncer is the state of a running auto-sync goroutine.
+type autoSyncer struct {
+	period time.Duration
+	bytes  int64
+	kick   chan struct{} // Signalled by commits crossing the byte threshold
+	stop   chan struct{}
+	done   chan struct{}
+	err    error // First failed sync, read after done is closed
+}
+
+// StartAutoSync starts a goroutine that syncs the environment every period
+// and whenever bytesThreshold bytes have been committed without a sync, so
+// commits made with SafeNoSync, NoMetaSync or TxnNoSync lose a bounded
+// amount of work on a crash. Either threshold may be 0 to disable it, but
+// not both. Starting again replaces the running goroutine's thresholds.
+//
+// Syncs never overlap a commit: the goroutine waits for the current write
+// transaction to finish and holds back new ones while it syncs. Close stops
+// the goroutine and syncs whatever is still pending.
+func (e *Env) StartAutoSync(period time.Duration, bytesThreshold int) error {
+	if !e.valid() {
+		return NewError(ErrInvalid)
+	}
+	if period < 0 || bytesThreshold < 0 || (period == 0 && bytesThreshold == 0) {
+		return NewError(ErrInvalid)
+	}
+
+	e.mu.RLock()
+	open := e.dataMap != nil
+	e.mu.RUnlock()
+	if !open {
+		return NewError(ErrInvalid)
+	}
+	if e.flags&ReadOnly != 0 {
+		return NewError(ErrPermissionDenied)
+	}
+
+	s := &autoSyncer{
+		period: period,
+		bytes:  int64(bytesThreshold),
+		kick:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	// Data committed before the start may already be over the threshold
+	if s.bytes > 0 && e.unsyncedBytes.Load() >= s.bytes {
+		s.kick <- struct{}{}
+	}
+
+	e.autoSyncMu.Lock()
+	defer e.autoSyncMu.Unlock()
+	if old := e.autoSync.Swap(s); old != nil {
+		old.halt()
+	}
+	go e.runAutoSync(s)
+	return nil
+}
+
+// StopAutoSync stops the auto-sync goroutine, if any, and returns the error
+// of the first sync it failed. Data committed since its last sync stays
+// unsynced until the next Sync.
+func (e *Env) StopAutoSync() error {
+	e.autoSyncMu.Lock()
+	defer e.autoSyncMu.Unlock()
+	if s := e.autoSync.Swap(nil); s != nil {
+		return s.halt()
+	}
+	return nil
+}
+
+// halt stops the goroutine and waits for it to exit.
+func (s *autoSyncer) halt() error {
+	close(s.stop)
+	<-s.done
+	return s.err
+}
+
+// runAutoSync is the auto-sync goroutine.
+func (e *Env) runAutoSync(s *autoSyncer) {
+	defer close(s.done)
+
+	var tick <-chan time.Time
+	if s.period > 0 {
+		ticker := time.NewTicker(s.period)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-tick:
+		case <-s.kick:
+		}
+		if err := e.syncCommitted(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+}
+
+// noteCommit updates the unsynced byte count after a commit that wrote
+// bytes, and wakes the auto-sync goroutine once its threshold is reached.
+func (e *Env) noteCommit(bytes int64, synced bool) {
+	if synced {
+		e.unsyncedBytes.Store(0)
+		return
+	}
+	n := e.unsyncedBytes.Add(bytes)
+	if s := e.autoSync.Load(); s != nil && s.bytes > 0 && n >= s.bytes {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// syncCommitted syncs the data file if any commit since the last sync
+// skipped it. It waits for the active write transaction to finish and keeps
+// new ones from starting until the sync is done.
+func (e *Env) syncCommitted() error {
+	e.txnMu.Lock()
+	defer e.txnMu.Unlock()
+	for e.writeTxn != nil {
+		e.txnCond.Wait()
+	}
+	return e.syncUnsynced()
+}
+
+// syncUnsynced implements syncCommitted. The caller holds txnMu with no write
+// transaction running.
+func (e *Env) syncUnsynced() error {
+	if e.unsyncedBytes.Load() == 0 {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.dataFile == nil {
+		return NewError(ErrInvalid)
+	}
+	if err := e.syncSteady(); err != nil {
+		return err
+	}
+	e.unsyncedBytes.Store(0)
+	return nil
+}
+
+// syncSteady syncs the data file and then signs the last commit's meta
+// steady, so that Open keeps it after a system restart. It holds the writer
+// lock, so that no other process commits meanwhile.
+func (e *Env) syncSteady() error {
+	if err := e.lockFile.lockWriter(); err != nil {
+		return WrapError(ErrBusy, err)
+	}
+	defer e.lockFile.unlockWriter()
+
+	if err := e.readMeta(); err != nil {
+		return err
+	}
+	mt := e.meta.Load()
+	if err := e.syncDataFile(); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	if mt.recentMeta().isSteady() {
+		return nil
+	}
+
+	pageSize := int(e.pageSize)
+	offset := mt.recent * pageSize
+	page := append([]byte(nil), e.dataMap.Data()[offset:offset+pageSize]...)
+	(*meta)(unsafe.Pointer(&page[pageHeaderSize])).setSignSteady()
+	if err := e.writeMetaPage(page, int64(offset)); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	if err := e.syncDataFile(); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	return e.readMeta()
+}
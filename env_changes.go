@@ -0,0 +1,306 @@
+package gdbx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"unsafe"
+)
+
+// changeStreamMagic starts every change stream, which is laid out as
+// follows, all integers little-endian:
+//
+//	magic     [8]byte // changeStreamMagic
+//	pageSize  uint32
+//	filePages uint32  // Pages the data file must hold
+//	since     uint64  // Txnid the stream starts from
+//	txnid     uint64  // Txnid the stream leads to
+//	count     uint32  // Data pages that follow
+//	count × {pgno uint32, data [pageSize]byte}
+//	meta:    {pgno uint32, data [pageSize]byte}
+var changeStreamMagic = [8]byte{'g', 'd', 'b', 'x', 'c', 'h', 'g', '1'}
+
+// changeStreamHeaderSize is the size of the fixed stream header.
+const changeStreamHeaderSize = 8 + 4 + 4 + 8 + 8 + 4
+
+// snapshotMetaAttempts bounds the retries for catching a snapshot's meta page
+// before a busy writer recycles its slot.
+const snapshotMetaAttempts = 10
+
+var (
+	// errChangesUntracked is wrapped in the error StreamChanges returns when
+	// the history doesn't cover every commit after the requested txnid.
+	errChangesUntracked = errors.New("changes since the txnid are not in the change history")
+
+	// errChangeStream is wrapped in the error ApplyChanges returns for a
+	// malformed stream.
+	errChangeStream = errors.New("malformed change stream")
+
+	// errChangeBase is wrapped in the error ApplyChanges returns when the
+	// target is not at the txnid the stream starts from.
+	errChangeBase = errors.New("change stream does not start at the target's txnid")
+)
+
+// commitChanges records the pages written by one commit.
+type commitChanges struct {
+	txnid txnid
+	pages []pgno
+}
+
+// SetChangeHistory makes the environment remember the pages written by its
+// last commits commits, so StreamChanges can ship them. 0 disables the
+// history and forgets it. Only commits made through this Env after the call
+// are recorded; commits by other processes leave a gap that StreamChanges
+// refuses to stream across.
+func (e *Env) SetChangeHistory(commits int) error {
+	if !e.valid() || commits < 0 {
+		return NewError(ErrInvalid)
+	}
+	e.changesMu.Lock()
+	defer e.changesMu.Unlock()
+
+	e.changesMax = commits
+	if drop := len(e.changes) - commits; drop > 0 {
+		e.changes = slices.Delete(e.changes, 0, drop)
+	}
+	e.changesOn.Store(commits > 0)
+	return nil
+}
+
+// recordChanges adds a committed transaction's pages to the change history.
+func (e *Env) recordChanges(txn *Txn) {
+	if !e.changesOn.Load() {
+		return
+	}
+	pages := make([]pgno, 0, txn.dirtyTracker.len()+len(txn.inPlacePages))
+	txn.dirtyTracker.forEach(func(pn pgno, _ *page) {
+		pages = append(pages, pn)
+	})
+	pages = append(pages, txn.inPlacePages...)
+
+	e.changesMu.Lock()
+	defer e.changesMu.Unlock()
+	if e.changesMax == 0 {
+		return
+	}
+	if len(e.changes) == e.changesMax {
+		e.changes = slices.Delete(e.changes, 0, 1)
+	}
+	e.changes = append(e.changes, commitChanges{txnid: txn.txnID, pages: pages})
+}
+
+// changedPages returns the sorted, distinct pages written by the commits
+// after since up to and including until, or an error if the history misses
+// any of those commits.
+func (e *Env) changedPages(since, until txnid) ([]pgno, error) {
+	e.changesMu.Lock()
+	defer e.changesMu.Unlock()
+
+	var pages []pgno
+	next := since + 1
+	for _, c := range e.changes {
+		if c.txnid < next || c.txnid > until {
+			continue
+		}
+		if c.txnid != next {
+			break
+		}
+		pages = append(pages, c.pages...)
+		next++
+	}
+	if next <= until {
+		return nil, WrapError(ErrNotFound, errChangesUntracked)
+	}
+	slices.Sort(pages)
+	return slices.Compact(pages), nil
+}
+
+// StreamChanges writes to w the pages changed by the commits after
+// sinceTxnID, up to the newest commit, as a stream for ApplyChanges. The
+// pages are read from a read-only snapshot, so writers may keep committing
+// while the stream is produced; it ends with the snapshot's meta page.
+//
+// The commits must be in the change history (see SetChangeHistory);
+// otherwise StreamChanges fails with ErrNotFound and a new full copy is
+// needed. A sinceTxnID beyond the newest commit fails with ErrInvalid.
+func (e *Env) StreamChanges(sinceTxnID uint64, w io.Writer) error {
+	if !e.valid() {
+		return NewError(ErrInvalid)
+	}
+	txn, metaPage, err := e.beginChangeSnapshot()
+	if err != nil {
+		return err
+	}
+	defer txn.Abort()
+
+	if sinceTxnID > uint64(txn.txnID) {
+		return NewError(ErrInvalid)
+	}
+	pages, err := e.changedPages(txnid(sinceTxnID), txn.txnID)
+	if err != nil {
+		return err
+	}
+
+	m := (*meta)(unsafe.Pointer(&metaPage[pageHeaderSize]))
+	bw := bufio.NewWriterSize(w, 1<<20)
+	var hdr [changeStreamHeaderSize]byte
+	copy(hdr[:], changeStreamMagic[:])
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(len(metaPage)))
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(m.Geometry.Now))
+	binary.LittleEndian.PutUint64(hdr[16:], sinceTxnID)
+	binary.LittleEndian.PutUint64(hdr[24:], uint64(txn.txnID))
+	binary.LittleEndian.PutUint32(hdr[32:], uint32(len(pages)))
+	if _, err := bw.Write(hdr[:]); err != nil {
+		return err
+	}
+
+	writePage := func(pn pgno, data []byte) error {
+		var rec [4]byte
+		binary.LittleEndian.PutUint32(rec[:], uint32(pn))
+		if _, err := bw.Write(rec[:]); err != nil {
+			return err
+		}
+		_, err := bw.Write(data)
+		return err
+	}
+	for _, pn := range pages {
+		data := txn.getPageDataFast(pn)
+		if data == nil {
+			return ErrPageNotFoundError
+		}
+		if err := writePage(pn, data); err != nil {
+			return err
+		}
+	}
+	if err := writePage(pgno(binary.LittleEndian.Uint32(metaPage[16:])), metaPage); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// beginChangeSnapshot starts a read-only transaction and returns it with a
+// copy of the meta page it was started from. The copy is taken right away,
+// since later commits recycle meta slots.
+func (e *Env) beginChangeSnapshot() (*Txn, []byte, error) {
+	for range snapshotMetaAttempts {
+		txn, err := e.BeginTxn(nil, TxnReadOnly)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < NumMetas; i++ {
+			data := txn.getPageDataFast(pgno(i))
+			if data == nil {
+				break
+			}
+			metaPage := append([]byte(nil), data...)
+			m, err := readMeta(metaPage[pageHeaderSize:])
+			if err == nil && m.validate() == nil && m.txnID() == txn.txnID {
+				return txn, metaPage, nil
+			}
+		}
+		txn.Abort()
+	}
+	return nil, nil, NewError(ErrBusy)
+}
+
+// ApplyChanges applies a stream written by StreamChanges to f, the data
+// file of a copy of the database that is closed and at the stream's
+// starting txnid. The data pages are written and synced before the meta
+// page, which is marked steady, so a crash leaves the copy at either txnid.
+// It returns the txnid the copy is at afterwards.
+//
+// A copy at another txnid fails with ErrIncompatible and a malformed stream
+// with ErrInvalid; a stream cut short may leave data pages written, but the
+// copy stays at its old txnid.
+func ApplyChanges(f *os.File, r io.Reader) (uint64, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	var hdr [changeStreamHeaderSize]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return 0, WrapError(ErrInvalid, errChangeStream)
+	}
+	if [8]byte(hdr[:8]) != changeStreamMagic {
+		return 0, WrapError(ErrInvalid, errChangeStream)
+	}
+	pageSize := int(binary.LittleEndian.Uint32(hdr[8:]))
+	filePages := int64(binary.LittleEndian.Uint32(hdr[12:]))
+	since := txnid(binary.LittleEndian.Uint64(hdr[16:]))
+	until := binary.LittleEndian.Uint64(hdr[24:])
+	count := binary.LittleEndian.Uint32(hdr[32:])
+	if pageSize < MinPageSize || pageSize > MaxPageSize {
+		return 0, WrapError(ErrInvalid, errChangeStream)
+	}
+
+	// The copy must be where the stream starts
+	var metas [NumMetas][]byte
+	for i := range metas {
+		metas[i] = make([]byte, pageSize)
+		if _, err := f.ReadAt(metas[i], int64(i*pageSize)); err != nil {
+			return 0, WrapError(ErrInvalid, err)
+		}
+		metas[i] = metas[i][pageHeaderSize:]
+	}
+	mt, err := newMetaTriple(metas)
+	if err != nil {
+		return 0, WrapError(ErrCorrupted, err)
+	}
+	if mt.txnids[mt.recent] != since {
+		return 0, WrapError(ErrIncompatible, errChangeBase)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, WrapError(ErrProblem, err)
+	}
+	if size := filePages * int64(pageSize); fi.Size() < size {
+		if err := f.Truncate(size); err != nil {
+			return 0, WrapError(ErrProblem, err)
+		}
+	}
+
+	rec := make([]byte, 4+pageSize)
+	readPage := func() (pgno, []byte, error) {
+		if _, err := io.ReadFull(br, rec); err != nil {
+			return 0, nil, WrapError(ErrInvalid, errChangeStream)
+		}
+		pn := pgno(binary.LittleEndian.Uint32(rec))
+		if int64(pn) >= filePages {
+			return 0, nil, WrapError(ErrInvalid, errChangeStream)
+		}
+		return pn, rec[4:], nil
+	}
+	for range count {
+		pn, data, err := readPage()
+		if err != nil {
+			return 0, err
+		}
+		if pn < NumMetas {
+			return 0, WrapError(ErrInvalid, errChangeStream)
+		}
+		if _, err := f.WriteAt(data, int64(pn)*int64(pageSize)); err != nil {
+			return 0, WrapError(ErrProblem, err)
+		}
+	}
+
+	pn, metaPage, err := readPage()
+	if err != nil {
+		return 0, err
+	}
+	m, err := readMeta(metaPage[pageHeaderSize:])
+	if pn >= NumMetas || err != nil || m.validate() != nil || uint64(m.txnID()) != until {
+		return 0, WrapError(ErrInvalid, errChangeStream)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, WrapError(ErrProblem, err)
+	}
+	m.setSignSteady()
+	if _, err := f.WriteAt(metaPage, int64(pn)*int64(pageSize)); err != nil {
+		return 0, WrapError(ErrProblem, err)
+	}
+	if err := f.Sync(); err != nil {
+		return 0, WrapError(ErrProblem, err)
+	}
+	return until, nil
+}
@@ -0,0 +1,100 @@
+package gdbx
+
+import (
+	"os"
+	"unsafe"
+)
+
+// CloneTo copies the database, as of the last commit, to a new environment
+// at path and returns it open, with the source's flags less ReadOnly and
+// NoLock, and its table and reader limits. Unlike a compacting copy it copies
+// the snapshot page for page, layout and free space included, and neither
+// readers nor writers of the source are held up. The clone is independent of
+// the source: either can be written, moved or closed without the other
+// noticing.
+// Tables with a custom comparator must be opened in the clone with the same
+// DBISpec as in the source.
+//
+// path is a directory, or the data file with NoSubdir, as for Open; a data
+// file already there fails with ErrInvalid, untouched. The caller closes
+// the returned Env.
+func (e *Env) CloneTo(path string) (*Env, error) {
+	if !e.valid() {
+		return nil, NewError(ErrInvalid)
+	}
+	flags := e.flags &^ (ReadOnly | NoLock)
+	dataPath, _ := envFilePaths(path, flags)
+	if flags&NoSubdir == 0 {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return nil, WrapError(ErrInvalid, err)
+		}
+	}
+	f, err := os.OpenFile(dataPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, WrapError(ErrInvalid, err)
+	}
+	err = e.cloneTo(f)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = WrapError(ErrProblem, cerr)
+	}
+	if err != nil {
+		os.Remove(dataPath)
+		return nil, err
+	}
+
+	clone, err := NewEnv(e.label)
+	if err != nil {
+		return nil, err
+	}
+	clone.SetMaxDBs(e.maxDBs)
+	clone.SetMaxReaders(e.maxReaders)
+	upper := int64(-1)
+	if e.geoUpper > 0 {
+		upper = int64(e.geoUpper)
+	}
+	if err := clone.SetGeometry(-1, -1, upper, -1, -1, int(e.pageSize)); err != nil {
+		clone.Close()
+		return nil, err
+	}
+	if err := clone.Open(path, flags, 0644); err != nil {
+		clone.Close()
+		return nil, err
+	}
+	return clone, nil
+}
+
+// cloneTo writes the pages of the last commit's snapshot to f and leaves
+// that snapshot's meta the most recent of f's.
+func (e *Env) cloneTo(f *os.File) error {
+	txn, metaPage, err := e.beginChangeSnapshot()
+	if err != nil {
+		return err
+	}
+	defer txn.Abort()
+
+	pageSize := len(metaPage)
+	m := (*meta)(unsafe.Pointer(&metaPage[pageHeaderSize]))
+	if err := e.copyDataFile(f, int64(m.Geometry.Now)*int64(pageSize)); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+
+	slot := make([]byte, pageSize)
+	for i := 0; i < NumMetas; i++ {
+		if _, err := f.ReadAt(slot, int64(i*pageSize)); err != nil {
+			return WrapError(ErrProblem, err)
+		}
+		sm, err := readMeta(slot[pageHeaderSize:])
+		if err == nil && sm.validate() == nil && sm.txnID() <= txn.txnID {
+			continue
+		}
+		copy(slot, metaPage)
+		(*pageHeader)(unsafe.Pointer(&slot[0])).PageNo = pgno(i)
+		if _, err := f.WriteAt(slot, int64(i*pageSize)); err != nil {
+			return WrapError(ErrProblem, err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package gdbx
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+)
+
+// SetCommitVerify turns on or off reading back the meta page of every commit,
+// once written and synced, to check that the data file holds the txnid,
+// roots, geometry and signature written. A commit that fails the check fails
+// with ErrProblem and is aborted, and the Env keeps the previous commit as
+// its newest. The read goes through the page cache, so it catches writes a
+// faulty file system lost or mangled, not ones a device dropped after a
+// sync. It costs each commit a read of one page, a few microseconds while
+// the page is cached and a disk read when it isn't, and is off by default.
+// With RedoLog, where a commit is durable once logged, commits are not
+// checked. It can be changed at any time.
+func (e *Env) SetCommitVerify(on bool) error {
+	if !e.valid() {
+		return NewError(ErrInvalid)
+	}
+	e.commitVerify.Store(on)
+	return nil
+}
+
+// writeMetaPage writes a meta page at offset off of the data file.
+func (e *Env) writeMetaPage(page []byte, off int64) error {
+	w := e.metaWriter
+	if w == nil {
+		w = e.dataFile
+	}
+	_, err := w.WriteAt(page, off)
+	return err
+}
+
+// verifyMeta checks, with SetCommitVerify on, that meta page metaIdx of the
+// data file reads back as page, the one txn wrote.
+func (txn *Txn) verifyMeta(metaIdx int, page []byte) error {
+	e := txn.env
+	if !e.commitVerify.Load() || e.redo != nil {
+		return nil
+	}
+	n := pageHeaderSize + int(unsafe.Sizeof(meta{}))
+	got := make([]byte, n)
+	if _, err := e.dataFile.ReadAt(got, int64(metaIdx)*int64(e.pageSize)); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	if bytes.Equal(got, page[:n]) {
+		return nil
+	}
+	m := (*meta)(unsafe.Pointer(&got[pageHeaderSize]))
+	return WrapError(ErrProblem, fmt.Errorf(
+		"gdbx: meta page %d holds txnid %d after commit %d wrote it; the storage lost or altered the write",
+		metaIdx, m.txnidASafe(), txn.txnID))
+}
@@ -0,0 +1,188 @@
+package gdbx
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// compactBatchBytes is how much key and value data the copy writes per
+// commit, bounding the dirty pages it holds in memory.
+const compactBatchBytes = 64 << 20
+
+// compactor rebuilds the tables of src in the environment dst.
+type compactor struct {
+	src     *Txn
+	dst     *Env
+	txn     *Txn // Current write transaction of dst
+	pending int  // Bytes put since txn began
+}
+
+// copyCompact writes a compacted copy of the environment to the data file at
+// path, replacing whatever is there. It puts the keys and duplicates of each
+// table in order with Append and AppendDup, which fill each page before the
+// next.
+func (e *Env) copyCompact(path string) error {
+	src, err := e.BeginTxn(nil, TxnReadOnly)
+	if err != nil {
+		return err
+	}
+	defer src.Abort()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return WrapError(ErrInvalid, err)
+	}
+	dst, err := NewEnv(e.label)
+	if err != nil {
+		return err
+	}
+	defer removeLockFile(path + LockSuffix)
+	defer dst.Close()
+	dst.SetMaxDBs(e.maxDBs)
+	upper := int64(-1)
+	if e.geoUpper > 0 {
+		upper = int64(e.geoUpper)
+	}
+	if err := dst.SetGeometry(-1, -1, upper, -1, -1, int(e.pageSize)); err != nil {
+		return err
+	}
+	if err := dst.Open(path, NoSubdir, 0644); err != nil {
+		return err
+	}
+
+	c := &compactor{src: src, dst: dst}
+	if c.txn, err = dst.BeginTxn(nil, 0); err != nil {
+		return err
+	}
+	if err := c.copyMain(); err != nil {
+		c.txn.Abort()
+		return err
+	}
+	_, err = c.txn.Commit()
+	return err
+}
+
+// copyMain copies the main table: its plain entries, and each named table
+// it records.
+func (c *compactor) copyMain() error {
+	cur, err := c.src.OpenCursor(MainDBI)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	dupSort := c.src.trees[MainDBI].Flags&uint16(DupSort) != 0
+	for k, v, err := cur.Get(nil, nil, First); ; k, v, err = cur.Get(nil, nil, Next) {
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info, err := cur.CurrentNodeInfo()
+		if err != nil {
+			return err
+		}
+		if info.Flags&(NodeTree|NodeDup) == NodeTree {
+			if err := c.copyNamed(string(k)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.put(MainDBI, k, v, dupSort); err != nil {
+			return err
+		}
+	}
+}
+
+// copyNamed creates the named table in the copy, with the source's flags,
+// comparators and sequence, and copies its entries.
+func (c *compactor) copyNamed(name string) error {
+	srcDBI, err := c.src.OpenDBISimple(name, 0)
+	if err != nil {
+		return err
+	}
+	t := c.src.trees[srcDBI]
+
+	e := c.src.env
+	var cmp, dcmp CmpFunc
+	e.dbisMu.RLock()
+	if info := e.dbis[srcDBI]; info != nil {
+		cmp, dcmp = info.cmp, info.dcmp
+	}
+	e.dbisMu.RUnlock()
+
+	dstDBI, err := c.txn.OpenDBI(name, Create|uint(t.Flags), cmp, dcmp)
+	if err != nil {
+		return err
+	}
+	if t.Sequence > 0 {
+		if _, err := c.txn.Sequence(dstDBI, t.Sequence); err != nil {
+			return err
+		}
+	}
+
+	cur, err := c.src.OpenCursor(srcDBI)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	dupSort := t.Flags&uint16(DupSort) != 0
+	for k, v, err := cur.Get(nil, nil, First); ; k, v, err = cur.Get(nil, nil, Next) {
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.put(dstDBI, k, v, dupSort); err != nil {
+			return err
+		}
+	}
+}
+
+// put appends an entry to the copy, committing once the batch is full.
+func (c *compactor) put(dbi DBI, k, v []byte, dupSort bool) error {
+	flags := Append
+	if dupSort {
+		flags |= AppendDup
+	}
+	if err := c.txn.Put(dbi, k, v, flags); err != nil {
+		return err
+	}
+	c.pending += len(k) + len(v)
+	if c.pending < compactBatchBytes {
+		return nil
+	}
+	if _, err := c.txn.Commit(); err != nil {
+		return err
+	}
+	c.pending = 0
+	var err error
+	c.txn, err = c.dst.BeginTxn(nil, 0)
+	return err
+}
+
+// copyCompactTo writes a compacted copy of the environment to w, building it
+// in a temporary file first.
+func (e *Env) copyCompactTo(w *os.File) error {
+	dir, err := os.MkdirTemp("", "gdbx-compact-*")
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, DataFileName)
+	if err := e.copyCompact(path); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+	return w.Sync()
+}
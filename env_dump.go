@@ -0,0 +1,113 @@
+package gdbx
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DumpTree writes the B+tree of database dbi, as of the last commit, to w:
+// one line per page with its number, kind, entry count and first and last
+// key, indented by level. The sub-tree of a DupSort key follows the leaf
+// holding it, one level deeper, with values in place of keys. Printable keys
+// are quoted, others are shown in hex.
+//
+// It is meant for looking at split and merge behavior and for bug reports;
+// the format may change.
+func (e *Env) DumpTree(dbi DBI, w io.Writer) error {
+	if !e.valid() {
+		return NewError(ErrInvalid)
+	}
+	if dbi >= CoreDBs {
+		e.dbisMu.RLock()
+		opened := int(dbi) < len(e.dbis) && e.dbis[dbi] != nil && e.dbis[dbi].tree != nil
+		e.dbisMu.RUnlock()
+		if !opened {
+			return NewError(ErrBadDBI)
+		}
+	}
+	return e.View(func(txn *Txn) error {
+		if int(dbi) >= len(txn.trees) {
+			return NewError(ErrBadDBI)
+		}
+		bw := bufio.NewWriter(w)
+		t := &txn.trees[dbi]
+		if t.Root == invalidPgno {
+			fmt.Fprintln(bw, "empty tree")
+		} else if err := txn.dumpPage(bw, t.Root, 0, t.Flags&uint16(DupSort) != 0); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+}
+
+// dumpPage writes page pg at depth and the pages below it.
+func (txn *Txn) dumpPage(w io.Writer, pg pgno, depth int, dupSort bool) error {
+	if depth >= 2*CursorStackSize {
+		return NewError(ErrCorrupted) // A cycle, or a sub-tree under a sub-tree
+	}
+	p, err := txn.getPage(pg)
+	if err != nil {
+		return err
+	}
+	n := p.numEntriesFast()
+	indent := strings.Repeat("  ", depth)
+
+	var kind string
+	switch {
+	case p.isBranch():
+		kind = "branch"
+	case p.isDupfix():
+		kind = "leaf dupfix"
+	case p.isLeaf():
+		kind = "leaf"
+	default:
+		return NewError(ErrCorrupted)
+	}
+	if n == 0 {
+		fmt.Fprintf(w, "%spage %d %s 0 entries\n", indent, pg, kind)
+		return nil
+	}
+	fmt.Fprintf(w, "%spage %d %s %d entries [%s .. %s]\n", indent, pg, kind, n,
+		dumpKey(subTreeKey(p, 0)), dumpKey(subTreeKey(p, n-1)))
+
+	if p.isBranch() {
+		for i := 0; i < n; i++ {
+			if err := txn.dumpPage(w, nodeGetChildPgnoFast(p, i), depth+1, dupSort); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !dupSort || p.isDupfix() {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		key, flags, data := nodeGetKeyFlagsDataFast(p, i)
+		if flags&(nodeTree|nodeDup) != nodeTree|nodeDup {
+			continue
+		}
+		sub := parseTreeFromBytes(data)
+		if sub == nil {
+			return NewError(ErrCorrupted)
+		}
+		fmt.Fprintf(w, "%s  sub-tree of %s, %d values\n", indent, dumpKey(key), sub.Items)
+		if err := txn.dumpPage(w, sub.Root, depth+2, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumpKey formats a key for DumpTree.
+func dumpKey(k []byte) string {
+	for _, b := range k {
+		if b < 0x20 || b > 0x7e {
+			return "0x" + hex.EncodeToString(k)
+		}
+	}
+	return strconv.Quote(string(k))
+}
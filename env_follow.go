@@ -0,0 +1,145 @@
+package gdbx
+
+import (
+	"unsafe"
+
+	mmappkg "github.com/Giulio2002/gdbx/mmap"
+)
+
+// followWriter brings a read-only Env up to the newest commit in the file,
+// mapping the file again if that commit's pages reach past the mapping. The
+// old mapping stays until Close for the transactions still reading it. Read
+// transactions of a read-only Env call it on begin and on Renew.
+func (e *Env) followWriter() error {
+	e.mu.RLock()
+	moved := e.dataMap != nil && e.metaMoved()
+	e.mu.RUnlock()
+	if !moved {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dataMap == nil || !e.metaMoved() {
+		return nil // Another transaction followed first
+	}
+	if err := e.readMeta(); err != nil {
+		return err
+	}
+	need := int64(e.meta.Load().recentMeta().Geometry.Next) * int64(e.pageSize)
+	if need <= int64(len(e.dataMap.Data())) {
+		return nil
+	}
+
+	fi, err := e.dataFile.Stat()
+	if err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	if fi.Size() < need {
+		return NewError(ErrCorrupted)
+	}
+	newMap, err := mmappkg.New(int(e.dataFile.Fd()), 0, int(fi.Size()), false)
+	if err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	e.adviseReadahead(newMap)
+
+	e.oldMmapsMu.Lock()
+	e.oldMmaps = append(e.oldMmaps, e.dataMap)
+	e.oldMmapsMu.Unlock()
+	e.dataMap = newMap
+	e.mmapVersion++
+
+	// Point the metas at the new mapping
+	return e.readMeta()
+}
+
+// metaMoved reports whether a meta page holds a complete commit newer than
+// the one the Env knows of. The caller must hold e.mu.
+func (e *Env) metaMoved() bool {
+	mt := e.meta.Load()
+	known := mt.txnids[mt.recent]
+	data := e.dataMap.Data()
+	for i := 0; i < NumMetas; i++ {
+		m := (*meta)(unsafe.Pointer(&data[i*int(e.pageSize)+pageHeaderSize]))
+		if m.txnidASafe() > known && m.isConsistent() {
+			return true
+		}
+	}
+	return false
+}
+
+// followNamedTrees re-reads the roots of the named databases opened in a
+// read-only Env from the main tree of txn's snapshot, unless the roots the
+// Env holds already come from that snapshot.
+func (txn *Txn) followNamedTrees() error {
+	e := txn.env
+	type named struct {
+		dbi  int
+		info *dbiInfo
+	}
+	var opened []named
+	e.dbisMu.RLock()
+	current := e.dbisTxnID == txnid(txn.txnID)
+	for i := CoreDBs; i < len(e.dbis) && i < len(txn.trees); i++ {
+		if e.dbis[i] != nil {
+			opened = append(opened, named{i, e.dbis[i]})
+		}
+	}
+	e.dbisMu.RUnlock()
+	if current || len(opened) == 0 {
+		return nil
+	}
+
+	cursor, err := txn.openCursor(MainDBI)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	trees := make([]*tree, len(opened))
+	for j, n := range opened {
+		if trees[j], err = readNamedTree(cursor, n.info); err != nil {
+			return err
+		}
+		txn.trees[n.dbi] = *trees[j]
+	}
+
+	e.dbisMu.Lock()
+	defer e.dbisMu.Unlock()
+	// A handle opened meanwhile holds a root from some other snapshot
+	count := 0
+	for i := CoreDBs; i < len(e.dbis) && i < len(txn.trees); i++ {
+		if e.dbis[i] != nil {
+			count++
+		}
+	}
+	stable := count == len(opened)
+	for j, n := range opened {
+		if e.dbis[n.dbi] == n.info {
+			n.info.tree = trees[j]
+		} else {
+			stable = false
+		}
+	}
+	if stable {
+		e.dbisTxnID = txnid(txn.txnID)
+	} else {
+		e.dbisTxnID = 0
+	}
+	return nil
+}
+
+// readNamedTree reads the tree of the named database info from the main
+// tree cursor is on. A database the snapshot lacks is an empty one.
+func readNamedTree(cursor *Cursor, info *dbiInfo) (*tree, error) {
+	_, data, err := cursor.Get([]byte(info.name), nil, Set)
+	switch {
+	case err == nil && len(data) >= 48:
+		return parseTreeFromBytes(data), nil
+	case err == nil || IsNotFound(err):
+		// Dropped by the writer: the handle sees an empty database
+		return &tree{Flags: uint16(info.flags & 0xFFFF), Root: invalidPgno}, nil
+	}
+	return nil, err
+}
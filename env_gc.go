@@ -0,0 +1,59 @@
+package gdbx
+
+import "slices"
+
+// WalkGC calls fn for each record of the GC database as of the last commit,
+// in ascending transaction order, with the transaction that freed the pages
+// and their numbers in ascending order. The slice is fn's to keep. An error
+// returned by fn stops the walk and is returned. A record that does not
+// decode as a page list gives ErrCorrupted. gdbx reads the records MDBX
+// wrote but adds none, so a database only gdbx has written has none.
+func (e *Env) WalkGC(fn func(txnid uint64, pages []uint32) error) error {
+	if !e.valid() {
+		return NewError(ErrInvalid)
+	}
+	return e.View(func(txn *Txn) error {
+		if txn.trees[FreeDBI].isEmpty() {
+			return nil
+		}
+		c, err := txn.openCursor(FreeDBI)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		for k, v, err := c.Get(nil, nil, First); ; k, v, err = c.Get(nil, nil, Next) {
+			if IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			pages, err := decodeGCRecord(k, v)
+			if err != nil {
+				return err
+			}
+			if err := fn(getUint64LE(k), pages); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// decodeGCRecord returns the page numbers of the GC record k, v in
+// ascending order.
+func decodeGCRecord(k, v []byte) ([]uint32, error) {
+	if len(k) != 8 || len(v) < 4 {
+		return nil, NewError(ErrCorrupted)
+	}
+	// MDBX reserves a record before filling it, and may leave room unused
+	n := int(getUint32LE(v))
+	if n > (len(v)-4)/4 {
+		return nil, NewError(ErrCorrupted)
+	}
+	pages := make([]uint32, n)
+	for i := range pages {
+		pages[i] = getUint32LE(v[4+4*i:])
+	}
+	slices.Sort(pages)
+	return pages, nil
+}
@@ -0,0 +1,114 @@
+package gdbx
+
+import "bytes"
+
+// KeyRangeSplits returns up to n key ranges [low, high) that together cover
+// database dbi, as of the last commit, in order and without overlap, each
+// holding about the same share of its pages. The first range has a nil low
+// and the last a nil high, the open sides of Cursor.SetBounds, so each range
+// can be given to a goroutine that scans it with SetBounds on a cursor of its
+// own read transaction. Ranges computed on one snapshot cover the table in
+// any other; only their balance may differ. An empty table or a table of few
+// keys gives fewer ranges than n, down to one with both sides open. An n
+// below 1 fails with ErrInvalid. The ranges are cut at branch separators,
+// without reading leaves unless the tree is too small, so a DupSort key's
+// values always fall in one range.
+func (e *Env) KeyRangeSplits(dbi DBI, n int) ([][2][]byte, error) {
+	if !e.valid() || n < 1 {
+		return nil, NewError(ErrInvalid)
+	}
+	if dbi >= CoreDBs {
+		e.dbisMu.RLock()
+		opened := int(dbi) < len(e.dbis) && e.dbis[dbi] != nil && e.dbis[dbi].tree != nil
+		e.dbisMu.RUnlock()
+		if !opened {
+			return nil, NewError(ErrBadDBI)
+		}
+	}
+	var ranges [][2][]byte
+	err := e.View(func(txn *Txn) error {
+		if int(dbi) >= len(txn.trees) {
+			return NewError(ErrBadDBI)
+		}
+		bounds, err := txn.keyRangeBounds(&txn.trees[dbi], n)
+		if err != nil {
+			return err
+		}
+		var low []byte
+		for _, b := range bounds {
+			ranges = append(ranges, [2][]byte{low, b})
+			low = b
+		}
+		ranges = append(ranges, [2][]byte{low, nil})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// keyRangeBounds returns up to n-1 keys, in order and copied, that cut t
+// into parts of about the same number of pages.
+func (txn *Txn) keyRangeBounds(t *tree, n int) ([][]byte, error) {
+	if t.Root == invalidPgno || n == 1 {
+		return nil, nil
+	}
+
+	// lows[i] is the lower bound of level[i], nil for the leftmost page
+	level, lows := []pgno{t.Root}, [][]byte{nil}
+	for depth := 1; depth < int(t.Height) && len(level) < n; depth++ {
+		if depth >= CursorStackSize {
+			return nil, NewError(ErrCorrupted)
+		}
+		var next []pgno
+		var nextLows [][]byte
+		for i, pn := range level {
+			p, err := txn.getPage(pn)
+			if err != nil {
+				return nil, err
+			}
+			if !p.isBranch() {
+				return nil, NewError(ErrCorrupted)
+			}
+			for j := 0; j < p.numEntriesFast(); j++ {
+				low := lows[i]
+				if j > 0 {
+					low = nodeGetKeyDirect(p, j)
+				}
+				next = append(next, nodeGetChildPgnoFast(p, j))
+				nextLows = append(nextLows, low)
+			}
+		}
+		level, lows = next, nextLows
+	}
+
+	// Too few leaves: cut between their keys instead
+	if len(level) < n {
+		lows = lows[:0]
+		for _, pn := range level {
+			p, err := txn.getPage(pn)
+			if err != nil {
+				return nil, err
+			}
+			if !p.isLeaf() {
+				return nil, NewError(ErrCorrupted)
+			}
+			for j := 0; j < p.numEntriesFast(); j++ {
+				lows = append(lows, nodeGetKeyDirect(p, j))
+			}
+		}
+	}
+
+	var bounds [][]byte
+	last := 0
+	for part := 1; part < n; part++ {
+		i := part * len(lows) / n
+		if i == last {
+			continue
+		}
+		last = i
+		bounds = append(bounds, bytes.Clone(lows[i]))
+	}
+	return bounds, nil
+}
@@ -0,0 +1,34 @@
+package gdbx
+
+import "fmt"
+
+// SysPageSize returns the OS memory page size, the unit in which the data
+// file is mapped, synced and sized.
+func (e *Env) SysPageSize() int {
+	return int(sysPageSize)
+}
+
+// RecommendedPageSize returns the page size that suits the current platform
+// best: the OS page size, but no less than DefaultPageSize and no more than
+// MaxPageSize. Pass it to SetPageSize or SetGeometry before creating a
+// database; on the usual 4KB-page systems it is DefaultPageSize.
+func RecommendedPageSize() int {
+	size := DefaultPageSize
+	for int64(size) < sysPageSize && size < MaxPageSize {
+		size <<= 1
+	}
+	return size
+}
+
+// checkPageSize warns when a database about to be created has pages smaller
+// than the OS page, each write of which dirties and syncs the whole OS page.
+// Existing files keep their page size and open silently.
+func (e *Env) checkPageSize() {
+	if int64(e.pageSize) >= sysPageSize {
+		return
+	}
+	if globalLogger != nil && (globalLogLevel == LogLvlDoNotChange || globalLogLevel >= LogLvlWarn) {
+		globalLogger(fmt.Sprintf("gdbx: %s: page size %d is below the OS page size %d, so each page write dirties and syncs %d bytes; RecommendedPageSize gives %d",
+			e.path, e.pageSize, sysPageSize, sysPageSize, RecommendedPageSize()))
+	}
+}
@@ -0,0 +1,54 @@
+package gdbx
+
+import mmappkg "github.com/Giulio2002/gdbx/mmap"
+
+// SetReadaheadLimit sets the mapping size above which readahead is disabled,
+// as libmdbx does, since past RAM each fault would evict pages still needed.
+// A limit of 0 restores the default, the physical memory size; a negative
+// limit keeps readahead enabled at any size. The NoReadAhead flag disables
+// readahead regardless of the limit.
+//
+// It can be called before or after Open and takes effect immediately.
+func (e *Env) SetReadaheadLimit(bytes int64) error {
+	if !e.valid() {
+		return NewError(ErrInvalid)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.readaheadLimit.Store(bytes)
+	if e.dataMap != nil {
+		e.adviseReadahead(e.dataMap)
+	}
+	return nil
+}
+
+// readaheadThreshold returns the mapping size above which readahead is
+// disabled, or 0 if it never is.
+func (e *Env) readaheadThreshold() uint64 {
+	switch limit := e.readaheadLimit.Load(); {
+	case limit < 0:
+		return 0
+	case limit > 0:
+		return uint64(limit)
+	default:
+		return mmappkg.PhysicalMemory()
+	}
+}
+
+// adviseReadahead applies the readahead policy to m, the environment's new
+// or resized mapping. Advice is only a hint, so failures are ignored.
+func (e *Env) adviseReadahead(m *mmappkg.Map) {
+	off := e.flags&NoReadAhead != 0
+	if limit := e.readaheadThreshold(); limit > 0 && uint64(m.Size()) > limit {
+		off = true
+	}
+
+	if off {
+		m.AdviseRandom()
+	} else {
+		m.AdviseNormal()
+	}
+	e.readaheadOff.Store(off)
+}
@@ -0,0 +1,235 @@
+package gdbx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// RedoSuffix is appended to the data file's path to name its redo log.
+const RedoSuffix = "-redo"
+
+// redoCheckpointBytes is the log size past which a commit checkpoints:
+// syncs the data file and empties the log, as Close does.
+const redoCheckpointBytes = 64 << 20
+
+// A log record is a header, the pages and their meta, and a CRC-32C of
+// both. Replay stops at the first record cut short or failing its checksum,
+// whose commit never returned.
+const (
+	redoMagic      = 0x4f445247 // "GRDO"
+	redoHeaderSize = 20         // Magic, page size, page count, file size
+)
+
+var redoCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// errRedoTorn reports a log record that is cut short or fails its checksum.
+var errRedoTorn = errors.New("torn redo log record")
+
+// redoLog is the open redo log of an Env.
+type redoLog struct {
+	file *os.File
+	size int64  // Size after this process's last append or checkpoint
+	buf  []byte // Record being built
+}
+
+// openRedoLog opens the redo log of the data file at dataPath, first
+// replaying and emptying it if no other process has it open. It is called
+// from open, before the data file is mapped.
+func (e *Env) openRedoLog(dataPath string, mode os.FileMode) error {
+	path := dataPath + RedoSuffix
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, mode)
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	// An exclusive lock means no other process is using the log; one that is
+	// has written the log's commits to the data file, and checkpoints itself
+	if lockDataFile(f, true) == nil {
+		err := e.replayRedo(f)
+		if err == nil {
+			err = truncateRedo(f)
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	// Reopening drops the exclusive lock on every platform
+	f.Close()
+
+	f, err = os.OpenFile(path, os.O_RDWR, mode)
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	if err := lockDataFile(f, false); err != nil {
+		f.Close()
+		return WrapError(ErrBusy, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return WrapError(ErrInvalid, err)
+	}
+	e.redo = &redoLog{file: f, size: fi.Size()}
+	return nil
+}
+
+// replayRedo writes the complete records of the log to the data file and
+// syncs it.
+func (e *Env) replayRedo(f *os.File) error {
+	type redoPage struct {
+		pgno pgno
+		data []byte
+	}
+	r := bufio.NewReader(f)
+	var hdr [redoHeaderSize]byte
+	var entry [8]byte
+	var pages []redoPage
+	var fileSize int64
+	replayed := false
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			break
+		}
+		if binary.LittleEndian.Uint32(hdr[0:]) != redoMagic {
+			break
+		}
+		pageSize := int(binary.LittleEndian.Uint32(hdr[4:]))
+		count := binary.LittleEndian.Uint32(hdr[8:])
+		size := int64(binary.LittleEndian.Uint64(hdr[12:]))
+
+		// Pages are only written once the whole record checks out
+		crc := crc32.New(redoCRC)
+		crc.Write(hdr[:])
+		pages = pages[:0]
+		if err := func() error {
+			for i := uint32(0); i < count; i++ {
+				if _, err := io.ReadFull(r, entry[:]); err != nil {
+					return err
+				}
+				crc.Write(entry[:])
+				n := int(binary.LittleEndian.Uint32(entry[4:]))
+				if pageSize < MinPageSize || n == 0 || n%pageSize != 0 || n > MaxDataSize+pageSize {
+					return errRedoTorn
+				}
+				data := make([]byte, n)
+				if _, err := io.ReadFull(r, data); err != nil {
+					return err
+				}
+				crc.Write(data)
+				pn := pgno(binary.LittleEndian.Uint32(entry[0:]))
+				pages = append(pages, redoPage{pn, data})
+			}
+			var sum [4]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				return err
+			}
+			if binary.LittleEndian.Uint32(sum[:]) != crc.Sum32() {
+				return errRedoTorn
+			}
+			return nil
+		}(); err != nil {
+			break
+		}
+
+		for _, p := range pages {
+			if _, err := e.dataFile.WriteAt(p.data, int64(p.pgno)*int64(pageSize)); err != nil {
+				return WrapError(ErrProblem, err)
+			}
+		}
+		fileSize = max(fileSize, size)
+		replayed = true
+	}
+	if !replayed {
+		return nil
+	}
+
+	fi, err := e.dataFile.Stat()
+	if err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	if fi.Size() < fileSize {
+		if err := e.dataFile.Truncate(fileSize); err != nil {
+			return WrapError(ErrProblem, err)
+		}
+	}
+	if err := e.syncDataFile(); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	return nil
+}
+
+// truncateRedo empties the log.
+func truncateRedo(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	if err := f.Sync(); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	return nil
+}
+
+// appendRedo logs the transaction's dirty pages and its meta page, about to
+// be written to slot metaIdx, syncing the log if sync is set. metaPage holds
+// the meta before its two-phase update. It is called with the writer lock
+// held, before the meta reaches the data file.
+func (txn *Txn) appendRedo(metaIdx int, metaPage []byte, sync bool) error {
+	r := txn.env.redo
+	pageSize := int64(txn.env.pageSize)
+
+	buf := r.buf[:0]
+	buf = binary.LittleEndian.AppendUint32(buf, redoMagic)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(pageSize))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(txn.dirtyTracker.len()+1))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(alignToSysPageSize(int64(txn.allocatedPg)*pageSize)))
+	txn.dirtyTracker.forEach(func(pn pgno, p *page) {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(pn))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(p.Data)))
+		buf = append(buf, p.Data...)
+	})
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(metaIdx))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(metaPage)))
+	start := len(buf)
+	buf = append(buf, metaPage...)
+	m := (*meta)(unsafe.Pointer(&buf[start+pageHeaderSize]))
+	m.beginMetaUpdate(txn.txnID)
+	m.endMetaUpdate(txn.txnID)
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, redoCRC))
+	r.buf = buf
+
+	// Other processes append to the log too
+	fi, err := r.file.Stat()
+	if err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	end := fi.Size()
+	if _, err = r.file.WriteAt(buf, end); err == nil && sync {
+		txn.env.fsyncs.Add(1)
+		err = r.file.Sync()
+	}
+	if err != nil {
+		// Replay would stop at a partial record, and miss any after it
+		r.file.Truncate(end)
+		return WrapError(ErrProblem, err)
+	}
+	r.size = end + int64(len(buf))
+	return nil
+}
+
+// checkpointRedo syncs the data file and empties the log. It is called with
+// the writer lock held.
+func (e *Env) checkpointRedo() error {
+	if err := e.syncDataFile(); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	if err := truncateRedo(e.redo.file); err != nil {
+		return err
+	}
+	e.redo.size = 0
+	return nil
+}
@@ -0,0 +1,120 @@
+package gdbx
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+)
+
+// errRelocateNotGdbx is wrapped in the error OpenRelocated returns when the
+// data file at the new path is missing its metas.
+var errRelocateNotGdbx = errors.New("no gdbx database at the relocated path")
+
+// errRelocateBadMeta is wrapped in the error OpenRelocated returns when one
+// of the meta pages is damaged.
+var errRelocateBadMeta = errors.New("relocated data file has an invalid meta page")
+
+// errRelocateTruncated is wrapped in the error OpenRelocated returns when the
+// data file is shorter than its metas say.
+var errRelocateTruncated = errors.New("relocated data file is shorter than its metas")
+
+// OpenRelocated opens a database that was created elsewhere and moved to
+// newPath while closed. Unlike Open it never creates a database: the data
+// file must exist at newPath, every meta page must be valid, and the file
+// must hold all the pages the metas refer to, otherwise it fails with
+// ErrInvalid or ErrCorrupted. Unless flags include ReadOnly, any lock file
+// carried over from the old location, whose reader table describes that
+// location's processes, is replaced by a new one, and it fails with ErrBusy
+// if another Env or process has the database open.
+func (e *Env) OpenRelocated(newPath string, flags uint) error {
+	dataPath, lockPath := envFilePaths(newPath, flags)
+	fi, err := os.Stat(dataPath)
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	if err := checkRelocatedHead(dataPath); err != nil {
+		return err
+	}
+
+	unlock := lockOpenPath(newPath, flags)
+	defer unlock()
+
+	if isOpenInProcess(newPath, flags) {
+		return WrapError(ErrBusy, errEnvOpenInProcess)
+	}
+	if flags&ReadOnly == 0 {
+		if err := removeStaleLockFile(dataPath, lockPath); err != nil {
+			return err
+		}
+	}
+	if err := e.open(newPath, flags, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := e.checkRelocatedMetas(fi.Size()); err != nil {
+		e.mu.Lock()
+		e.closeFiles()
+		e.mu.Unlock()
+		return err
+	}
+	if err := e.verifyOpened(); err != nil {
+		return err
+	}
+	e.register()
+	return nil
+}
+
+// removeStaleLockFile removes the lock file at lockPath while holding the
+// data file at dataPath exclusively, failing with ErrBusy if another process
+// has it open and so relies on the lock file.
+func removeStaleLockFile(dataPath, lockPath string) error {
+	f, err := os.OpenFile(dataPath, os.O_RDWR, 0)
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	defer f.Close()
+	if err := lockDataFile(f, true); err != nil {
+		if err == errLockBusy {
+			return WrapError(ErrBusy, err)
+		}
+		return WrapError(ErrInvalid, err)
+	}
+	if err := removeLockFile(lockPath); err != nil && !os.IsNotExist(err) {
+		return WrapError(ErrInvalid, err)
+	}
+	return nil
+}
+
+// checkRelocatedHead makes sure the file at path starts with a gdbx meta
+// page, so open doesn't treat an empty or zeroed file as a new database.
+func checkRelocatedHead(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, pageHeaderSize+8)
+	if _, err := f.ReadAt(head, 0); err != nil {
+		return WrapError(ErrInvalid, errRelocateNotGdbx)
+	}
+	magic := *(*uint64)(unsafe.Pointer(&head[pageHeaderSize]))
+	if magic>>8 != metaMagic {
+		return WrapError(ErrInvalid, errRelocateNotGdbx)
+	}
+	return nil
+}
+
+// checkRelocatedMetas verifies that all meta pages are valid and that the
+// data file, of fileSize bytes, holds every page they have allocated.
+func (e *Env) checkRelocatedMetas(fileSize int64) error {
+	mt := e.meta.Load()
+	for _, m := range mt.metas {
+		if m == nil {
+			return WrapError(ErrCorrupted, errRelocateBadMeta)
+		}
+		if int64(m.Geometry.Next)*int64(e.pageSize) > fileSize {
+			return WrapError(ErrCorrupted, errRelocateTruncated)
+		}
+	}
+	return nil
+}
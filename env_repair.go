@@ -0,0 +1,252 @@
+package gdbx
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"unsafe"
+)
+
+var (
+	// errRepairNoMeta is wrapped in the error OpenRepair returns when no meta
+	// page describes intact trees.
+	errRepairNoMeta = errors.New("no meta page with intact trees")
+
+	// errRepairPage is returned by the tree checks for a page that doesn't
+	// belong where a tree points.
+	errRepairPage = errors.New("tree refers to a missing or foreign page")
+)
+
+// repairMeta is a meta page found by OpenRepair.
+type repairMeta struct {
+	slot     int
+	pageSize int
+	page     []byte // The whole meta page
+	meta     *meta
+}
+
+// OpenRepair opens the database at path like Open, after rolling it back to
+// the newest meta page whose trees check out, if the newest meta's don't, as
+// after a commit cut short. The newer metas are overwritten with a copy of
+// that one stamped txnid 0, and the pages of the discarded commits are
+// allocated again. It returns the txnid the database is at, and reports a
+// rollback through the logger set with SetLogger.
+//
+// The check is light: the roots of the GC and main trees, every page of the
+// main tree, and the root of each named database must be pages of the
+// committed file that carry their own page number and a txnid no newer than
+// the meta. The data file must exist, and no other process may have it open.
+// If no meta passes, OpenRepair fails with ErrCorrupted and leaves the file
+// as it was. ReadOnly is refused with ErrInvalid, since a rollback writes.
+func (e *Env) OpenRepair(path string, flags uint) (uint64, error) {
+	if !e.valid() || flags&ReadOnly != 0 {
+		return 0, NewError(ErrInvalid)
+	}
+
+	unlock := lockOpenPath(path, flags)
+	defer unlock()
+
+	if isOpenInProcess(path, flags) {
+		return 0, WrapError(ErrBusy, errEnvOpenInProcess)
+	}
+
+	dataPath, lockPath := envFilePaths(path, flags)
+	f, err := os.OpenFile(dataPath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, WrapError(ErrInvalid, err)
+	}
+	from, to, rewritten, err := repairDataFile(f)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	// The reader table may describe the snapshots that were just discarded
+	if from != to {
+		if err := removeLockFile(lockPath); err != nil && !os.IsNotExist(err) {
+			return 0, WrapError(ErrInvalid, err)
+		}
+	}
+	fi, err := os.Stat(dataPath)
+	if err != nil {
+		return 0, WrapError(ErrInvalid, err)
+	}
+	if err := e.open(path, flags, fi.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	if err := e.verifyOpened(); err != nil {
+		return 0, err
+	}
+	e.register()
+
+	if rewritten > 0 {
+		repairLogf("gdbx: repaired %s: rolled back from txnid %d to txnid %d, rewrote %d meta pages", dataPath, from, to, rewritten)
+	}
+	return uint64(to), nil
+}
+
+// repairDataFile rolls the data file in f back to its newest meta with
+// intact trees. It returns the txnid of the newest well-formed meta, the one
+// the file is at now, and the number of meta slots it overwrote.
+func repairDataFile(f *os.File) (from, to txnid, rewritten int, err error) {
+	// Nobody else may commit while the metas are rewritten
+	if err := lockDataFile(f, true); err != nil {
+		if err == errLockBusy {
+			return 0, 0, 0, WrapError(ErrBusy, err)
+		}
+		return 0, 0, 0, WrapError(ErrInvalid, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, 0, WrapError(ErrInvalid, err)
+	}
+
+	metas := findRepairMetas(f, fi.Size())
+	if len(metas) == 0 {
+		return 0, 0, 0, WrapError(ErrCorrupted, errRepairNoMeta)
+	}
+	slices.SortFunc(metas, func(a, b repairMeta) int {
+		return cmp.Compare(b.meta.txnID(), a.meta.txnID())
+	})
+	from = metas[0].meta.txnID()
+
+	chosen := -1
+	for i := range metas {
+		if checkRepairTrees(f, fi.Size(), &metas[i]) == nil {
+			chosen = i
+			break
+		}
+	}
+	if chosen < 0 {
+		return 0, 0, 0, WrapError(ErrCorrupted, errRepairNoMeta)
+	}
+	good := metas[chosen]
+	to = good.meta.txnID()
+
+	// Slots holding an older meta of the same file stay as they are
+	keep := map[int]bool{good.slot: true}
+	for _, m := range metas[chosen+1:] {
+		if m.pageSize == good.pageSize {
+			keep[m.slot] = true
+		}
+	}
+	if len(keep) == NumMetas {
+		return from, to, 0, nil
+	}
+
+	blank := slices.Clone(good.page)
+	bm := (*meta)(unsafe.Pointer(&blank[pageHeaderSize]))
+	bm.setTxnid(0)
+	bm.Sign = [2]uint32{}
+	for slot := 0; slot < NumMetas; slot++ {
+		if keep[slot] {
+			continue
+		}
+		(*pageHeader)(unsafe.Pointer(&blank[0])).PageNo = pgno(slot)
+		if _, err := f.WriteAt(blank, int64(slot)*int64(good.pageSize)); err != nil {
+			return 0, 0, 0, WrapError(ErrProblem, err)
+		}
+		rewritten++
+	}
+	if err := f.Sync(); err != nil {
+		return 0, 0, 0, WrapError(ErrProblem, err)
+	}
+	return from, to, rewritten, nil
+}
+
+// findRepairMetas returns the well-formed meta pages in the data file. The
+// page size comes from the metas themselves, so a damaged first meta doesn't
+// hide the others.
+func findRepairMetas(f *os.File, fileSize int64) []repairMeta {
+	var found []repairMeta
+	for slot := 0; slot < NumMetas; slot++ {
+		for ps := MinPageSize; ps <= MaxPageSize; ps *= 2 {
+			off := int64(slot) * int64(ps)
+			if off+int64(ps) > fileSize {
+				break
+			}
+			data := make([]byte, ps)
+			if _, err := f.ReadAt(data, off); err != nil {
+				break
+			}
+			m, err := readMeta(data[pageHeaderSize:])
+			if err != nil || m.validate() != nil || int(m.pageSize()) != ps || m.txnID() == 0 {
+				continue
+			}
+			found = append(found, repairMeta{slot: slot, pageSize: ps, page: data, meta: m})
+			break
+		}
+	}
+	return found
+}
+
+// checkRepairTrees runs the light tree check of OpenRepair on one meta.
+func checkRepairTrees(f *os.File, fileSize int64, rm *repairMeta) error {
+	m := rm.meta
+	next := m.Geometry.Next
+	if next < NumMetas || int64(next)*int64(rm.pageSize) > fileSize {
+		return errRepairPage
+	}
+	read := func(pn pgno) (*page, error) {
+		if pn < NumMetas || pn >= next {
+			return nil, errRepairPage
+		}
+		p := &page{Data: make([]byte, rm.pageSize)}
+		if _, err := f.ReadAt(p.Data, int64(pn)*int64(rm.pageSize)); err != nil {
+			return nil, err
+		}
+		h := p.header()
+		if h.PageNo != pn || h.Txnid > m.txnID() || (!p.isBranchFast() && !p.isLeafFast()) {
+			return nil, errRepairPage
+		}
+		return p, nil
+	}
+	checkRoot := func(t *tree) error {
+		if t.Root == invalidPgno {
+			return nil
+		}
+		_, err := read(t.Root)
+		return err
+	}
+
+	if err := checkRoot(&m.GCTree); err != nil {
+		return err
+	}
+	if m.MainTree.Root == invalidPgno {
+		return nil
+	}
+	// Walk the main tree, checking the root of every named database
+	var walk func(pn pgno, depth int) error
+	walk = func(pn pgno, depth int) error {
+		if depth > CursorStackSize {
+			return errRepairPage
+		}
+		p, err := read(pn)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < p.numEntriesFast(); i++ {
+			if p.isBranchFast() {
+				err = walk(nodeGetChildPgnoFast(p, i), depth+1)
+			} else if nodeGetFlagsFast(p, i)&nodeTree != 0 {
+				if t := parseTreeFromBytes(nodeGetDataFast(p, i)); t != nil {
+					err = checkRoot(t)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(m.MainTree.Root, 1)
+}
+
+// repairLogf reports a repair through the logger set with SetLogger.
+func repairLogf(format string, args ...any) {
+	if globalLogger != nil && (globalLogLevel == LogLvlDoNotChange || globalLogLevel >= LogLvlWarn) {
+		globalLogger(fmt.Sprintf(format, args...))
+	}
+}
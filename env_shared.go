@@ -0,0 +1,158 @@
+package gdbx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// openEnvs records the environments open in this process. openEnvsMu is
+// held only to look up and record them, never across an open.
+var (
+	openEnvsMu sync.Mutex // Guards openEnvs, openPaths and Env.sharedRefs
+	openEnvs   []*Env
+	openPaths  = map[string]*pathLock{}
+)
+
+// pathLock serializes the opens of one data file.
+type pathLock struct {
+	mu   sync.Mutex
+	refs int // Opens holding or waiting for mu
+}
+
+// errEnvOpenInProcess is wrapped in the ErrBusy returned by opens that need
+// the database closed, such as OpenRelocated.
+var errEnvOpenInProcess = errors.New("environment already open in this process")
+
+// lockOpenPath holds off other opens of the data file of path, however it is
+// spelled, until the returned function is called.
+func lockOpenPath(path string, flags uint) func() {
+	key, _ := envFilePaths(path, flags)
+	if abs, err := filepath.Abs(key); err == nil {
+		key = abs
+	}
+	if real, err := filepath.EvalSymlinks(key); err == nil {
+		key = real
+	}
+
+	openEnvsMu.Lock()
+	l := openPaths[key]
+	if l == nil {
+		l = &pathLock{}
+		openPaths[key] = l
+	}
+	l.refs++
+	openEnvsMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		openEnvsMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(openPaths, key)
+		}
+		openEnvsMu.Unlock()
+	}
+}
+
+// isOpenInProcess reports whether an Env in this process has the data file
+// of path open.
+func isOpenInProcess(path string, flags uint) bool {
+	openEnvsMu.Lock()
+	defer openEnvsMu.Unlock()
+	return findOpenEnv(path, flags) != nil
+}
+
+// envFilePaths returns the data and lock file paths of an environment.
+func envFilePaths(path string, flags uint) (dataPath, lockPath string) {
+	if flags&NoSubdir != 0 {
+		return path, path + LockSuffix
+	}
+	return filepath.Join(path, DataFileName), filepath.Join(path, LockFileName)
+}
+
+// findOpenEnv returns the open environment whose data file is the one at
+// path, or nil. Files are compared by identity, so different spellings of a
+// path and links to the file match. The caller must hold openEnvsMu.
+func findOpenEnv(path string, flags uint) *Env {
+	dataPath, _ := envFilePaths(path, flags)
+	fi, err := os.Stat(dataPath)
+	if err != nil {
+		return nil
+	}
+	for _, e := range openEnvs {
+		if os.SameFile(fi, e.dataFileInfo) {
+			return e
+		}
+	}
+	return nil
+}
+
+// register records a freshly opened environment.
+func (e *Env) register() {
+	fi, err := e.dataFile.Stat()
+	if err != nil {
+		return
+	}
+	openEnvsMu.Lock()
+	defer openEnvsMu.Unlock()
+	e.dataFileInfo = fi
+	e.sharedRefs = 0
+	openEnvs = append(openEnvs, e)
+}
+
+// release drops one reference to the environment. It returns true when that
+// was the last one, after removing the environment from the registry.
+func (e *Env) release() bool {
+	openEnvsMu.Lock()
+	defer openEnvsMu.Unlock()
+
+	if e.sharedRefs > 0 {
+		e.sharedRefs--
+		return false
+	}
+	for i, other := range openEnvs {
+		if other == e {
+			openEnvs = append(openEnvs[:i], openEnvs[i+1:]...)
+			break
+		}
+	}
+	e.dataFileInfo = nil
+	return true
+}
+
+// OpenShared opens the environment at path like Open, unless another Env in
+// this process already has the data file open. In that case it returns that
+// Env with one more reference and e stays unopened; its settings are ignored.
+// Two Envs mapping one data file would each run their own writer and reader
+// tracking, so isolation would not hold between them.
+// The flags must then equal the ones the environment was opened with, or
+// OpenShared fails with ErrIncompatible.
+//
+// Every Env returned by OpenShared must be closed once; the environment is
+// closed when its last reference is.
+func (e *Env) OpenShared(path string, flags uint, mode os.FileMode) (*Env, error) {
+	unlock := lockOpenPath(path, flags)
+	defer unlock()
+
+	openEnvsMu.Lock()
+	if other := findOpenEnv(path, flags); other != nil {
+		defer openEnvsMu.Unlock()
+		if other.flags != flags {
+			return nil, NewError(ErrIncompatible)
+		}
+		other.sharedRefs++
+		return other, nil
+	}
+	openEnvsMu.Unlock()
+
+	if err := e.open(path, flags, mode); err != nil {
+		return nil, err
+	}
+	if err := e.verifyOpened(); err != nil {
+		return nil, err
+	}
+	e.register()
+	return e, nil
+}
@@ -0,0 +1,340 @@
+package gdbx
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Verify checks the structure of the database as of the last commit and
+// returns ErrCorrupted, wrapping a description of the first inconsistency
+// found, or nil. The Validation open flag runs it during Open.
+//
+// It reads every page reachable from the GC and main trees, checking each
+// page's number, txnid, kind and bounds, that no page is reached twice, the
+// tree heights and item counts, and that the pages the GC lists as free are
+// unreachable. Page counts, key order and the values themselves are not
+// checked. It holds a read transaction for as long as reading the file
+// takes.
+func (e *Env) Verify() error {
+	if !e.valid() {
+		return NewError(ErrInvalid)
+	}
+	return e.View(func(txn *Txn) error {
+		m := e.meta.Load().recentMeta()
+		if m == nil {
+			return NewError(ErrCorrupted)
+		}
+		v := &verifier{
+			txn:   txn,
+			next:  m.Geometry.Next,
+			seen:  make([]uint64, m.Geometry.Next/64+1),
+			table: "GC",
+		}
+		if err := v.checkTree(&txn.trees[FreeDBI], verifyPlain, nil); err != nil {
+			return err
+		}
+		v.table = "main"
+		if err := v.checkTree(&txn.trees[MainDBI], verifyMain, nil); err != nil {
+			return err
+		}
+		return v.checkGC()
+	})
+}
+
+// verifyKind says what the leaf entries of a tree hold.
+type verifyKind int
+
+const (
+	verifyPlain   verifyKind = iota // Values, possibly big
+	verifyMain                      // Values and named table records
+	verifyDupSort                   // Values, sub-pages and sub-trees
+	verifySubTree                   // Duplicate values, as keys
+)
+
+// verifier holds the state of a Verify walk.
+type verifier struct {
+	txn   *Txn
+	next  pgno     // First page past the allocated ones
+	seen  []uint64 // Bitmap of the pages reached
+	table string   // Table being walked, for error messages
+}
+
+// fail returns ErrCorrupted describing an inconsistency at page pn.
+func (v *verifier) fail(pn pgno, format string, args ...any) error {
+	return WrapError(ErrCorrupted, fmt.Errorf("verify: table %s, page %d: %s", v.table, pn, fmt.Sprintf(format, args...)))
+}
+
+// mark records page pn as reached, failing if it was already.
+func (v *verifier) mark(pn pgno) error {
+	if pn < NumMetas || pn >= v.next {
+		return v.fail(pn, "outside the %d allocated pages", v.next)
+	}
+	word, bit := pn/64, uint64(1)<<(pn%64)
+	if v.seen[word]&bit != 0 {
+		return v.fail(pn, "reached twice")
+	}
+	v.seen[word] |= bit
+	return nil
+}
+
+// page marks and returns page pn after checking its header.
+func (v *verifier) page(pn pgno) (*page, error) {
+	if err := v.mark(pn); err != nil {
+		return nil, err
+	}
+	data, err := v.txn.env.getPageData(pn)
+	if err != nil {
+		return nil, v.fail(pn, "beyond the end of the map")
+	}
+	p := &page{Data: data}
+	h := p.header()
+	if h.PageNo != pn {
+		return nil, v.fail(pn, "carries page number %d", h.PageNo)
+	}
+	if h.Txnid > txnid(v.txn.txnID) {
+		return nil, v.fail(pn, "written by txnid %d, after the snapshot's %d", h.Txnid, v.txn.txnID)
+	}
+	return p, nil
+}
+
+// checkTree walks tree t and compares the items it finds with the count the
+// tree records, adding them to total, if not nil.
+func (v *verifier) checkTree(t *tree, kind verifyKind, total *uint64) error {
+	if t.Root == invalidPgno {
+		if t.Items != 0 {
+			return v.fail(invalidPgno, "empty tree records %d items", t.Items)
+		}
+		return nil
+	}
+	if t.Height == 0 || int(t.Height) > CursorStackSize {
+		return v.fail(t.Root, "tree height %d", t.Height)
+	}
+	var found uint64
+	if err := v.walk(t, t.Root, 1, kind, &found); err != nil {
+		return err
+	}
+	if found != t.Items {
+		return v.fail(t.Root, "tree records %d items, found %d", t.Items, found)
+	}
+	if total != nil {
+		*total += found
+	}
+	return nil
+}
+
+// walk checks page pn at depth of tree t and the pages below it.
+func (v *verifier) walk(t *tree, pn pgno, depth int, kind verifyKind, items *uint64) error {
+	p, err := v.page(pn)
+	if err != nil {
+		return err
+	}
+	h := p.header()
+	entries := p.numEntries()
+	leaf := depth == int(t.Height)
+	switch kind := h.Flags & pageTypeMask; {
+	case kind == pageBranch && !leaf:
+	case kind == pageLeaf && leaf:
+	case kind == pageLeaf|pageDupfix && leaf && t.DupfixSize != 0:
+		if int(h.DupfixKsize) != int(t.DupfixSize) || pageHeaderSize+entries*int(h.DupfixKsize) > len(p.Data) {
+			return v.fail(pn, "%d values of %d bytes on a page of %d, in a tree of %d-byte values",
+				entries, h.DupfixKsize, len(p.Data), t.DupfixSize)
+		}
+		*items += uint64(entries)
+		return nil
+	default:
+		return v.fail(pn, "page of type %#x at depth %d of a tree of height %d", kind, depth, t.Height)
+	}
+	if entries == 0 {
+		return v.fail(pn, "no entries")
+	}
+	if err := v.checkNodes(p, !leaf); err != nil {
+		return err
+	}
+
+	for i := 0; i < entries; i++ {
+		if !leaf {
+			if err := v.walk(t, nodeGetChildPgnoFast(p, i), depth+1, kind, items); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.checkLeafNode(p, i, kind, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNodes checks that the entries of node page p lie within it.
+func (v *verifier) checkNodes(p *page, branch bool) error {
+	h := p.header()
+	size := len(p.Data)
+	if h.Lower&1 != 0 || h.Lower > h.Upper || pageHeaderSize+int(h.Upper) > size {
+		return v.fail(h.PageNo, "free space from %d to %d on a page of %d", h.Lower, h.Upper, size)
+	}
+	for i := 0; i < p.numEntries(); i++ {
+		off := int(binary.LittleEndian.Uint16(p.Data[pageHeaderSize+2*i:])) + pageHeaderSize
+		if off < pageHeaderSize+int(h.Upper) || off+nodeSize > size {
+			return v.fail(h.PageNo, "entry %d at offset %d, outside the node area", i, off)
+		}
+		end := off + nodeSize + int(binary.LittleEndian.Uint16(p.Data[off+6:]))
+		switch {
+		case branch:
+		case nodeFlags(p.Data[off+4])&nodeBig != 0:
+			end += 4
+		default:
+			end += int(binary.LittleEndian.Uint32(p.Data[off:]))
+		}
+		if end > size {
+			return v.fail(h.PageNo, "entry %d runs to offset %d, past the page", i, end)
+		}
+	}
+	return nil
+}
+
+// checkLeafNode checks entry i of leaf page p of a tree of the given kind,
+// and what it refers to, and counts its items.
+func (v *verifier) checkLeafNode(p *page, i int, kind verifyKind, items *uint64) error {
+	pn := p.pageNo()
+	flags := nodeGetFlagsFast(p, i)
+	switch {
+	case flags&nodeBig != 0:
+		if kind == verifySubTree || flags&(nodeTree|nodeDup) != 0 {
+			return v.fail(pn, "entry %d: big value with flags %#x", i, flags)
+		}
+		if err := v.overflow(nodeGetOverflowPgnoDirect(p, i), nodeGetDataSizeDirect(p, i)); err != nil {
+			return err
+		}
+		*items++
+
+	case flags&nodeTree != 0 && kind == verifyMain:
+		// A named table
+		data := nodeGetDataFast(p, i)
+		t := parseTreeFromBytes(data)
+		if t == nil || flags&nodeDup != 0 {
+			return v.fail(pn, "entry %d: table record of %d bytes with flags %#x", i, len(data), flags)
+		}
+		table := v.table
+		v.table = fmt.Sprintf("%q", nodeGetKeyFast(p, i))
+		sub := verifyPlain
+		if t.Flags&treeFlagDupSort != 0 {
+			sub = verifyDupSort
+		}
+		if err := v.checkTree(t, sub, nil); err != nil {
+			return err
+		}
+		v.table = table
+		*items++
+
+	case flags&nodeTree != 0 && kind == verifyDupSort:
+		data := nodeGetDataFast(p, i)
+		t := parseTreeFromBytes(data)
+		if t == nil || flags&nodeDup == 0 || t.Root == invalidPgno {
+			return v.fail(pn, "entry %d: sub-tree record of %d bytes with flags %#x", i, len(data), flags)
+		}
+		if err := v.checkTree(t, verifySubTree, items); err != nil {
+			return err
+		}
+
+	case flags&nodeDup != 0 && kind == verifyDupSort:
+		count, err := v.checkSubPage(pn, i, nodeGetDataFast(p, i))
+		if err != nil {
+			return err
+		}
+		*items += count
+
+	case flags&(nodeTree|nodeDup) != 0:
+		return v.fail(pn, "entry %d: flags %#x in a table that can't hold them", i, flags)
+
+	default:
+		*items++
+	}
+	return nil
+}
+
+// overflow checks the overflow run at pn holding a value of size bytes.
+func (v *verifier) overflow(pn pgno, size uint32) error {
+	p, err := v.page(pn)
+	if err != nil {
+		return err
+	}
+	if !p.isLarge() {
+		return v.fail(pn, "a big value's first page is of type %#x", p.pageType())
+	}
+	run := pgno(p.overflowPages())
+	if need := overflowPagesFor(int(size), len(p.Data)); int(run) < need || run > v.next-pn {
+		return v.fail(pn, "overflow run of %d pages for %d bytes", run, size)
+	}
+	for pg := pn + 1; pg < pn+run; pg++ {
+		if err := v.mark(pg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSubPage checks the sub-page of entry i of page pn and returns the
+// number of values it holds.
+func (v *verifier) checkSubPage(pn pgno, i int, data []byte) (uint64, error) {
+	count, err := checkSubPage(data)
+	if err != nil {
+		return 0, v.fail(pn, "entry %d: %v", i, err)
+	}
+	return uint64(count), nil
+}
+
+// checkGC checks the pages the GC records list: each must be allocated,
+// listed once, and not reachable from any tree.
+func (v *verifier) checkGC() error {
+	v.table = "GC"
+	if v.txn.trees[FreeDBI].isEmpty() {
+		return nil
+	}
+	listed := make([]uint64, len(v.seen))
+	c, err := v.txn.openCursor(FreeDBI)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, val, err := c.Get(nil, nil, First); ; k, val, err = c.Get(nil, nil, Next) {
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		pages, err := decodeGCRecord(k, val)
+		if err != nil {
+			return v.fail(invalidPgno, "record of %d bytes for txnid key %x", len(val), k)
+		}
+		for _, pg := range pages {
+			pn := pgno(pg)
+			if pn < NumMetas || pn >= v.next {
+				return v.fail(pn, "listed free by txnid %d, outside the %d allocated pages", getUint64LE(k), v.next)
+			}
+			word, bit := pn/64, uint64(1)<<(pn%64)
+			if v.seen[word]&bit != 0 {
+				return v.fail(pn, "listed free by txnid %d, and in use", getUint64LE(k))
+			}
+			if listed[word]&bit != 0 {
+				return v.fail(pn, "listed free twice")
+			}
+			listed[word] |= bit
+		}
+	}
+}
+
+// verifyOpened runs Verify on an environment open has just opened with the
+// Validation flag, and closes its files again if Verify fails.
+func (e *Env) verifyOpened() error {
+	if e.flags&Validation == 0 {
+		return nil
+	}
+	if err := e.Verify(); err != nil {
+		e.mu.Lock()
+		e.closeFiles()
+		e.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package gdbx
+
+import (
+	"cmp"
+	"runtime"
+	"slices"
+)
+
+// mapSpan is a byte range of the map, [start, end).
+type mapSpan struct {
+	start, end int64
+}
+
+// noteMapWrite records that pages [pn, pn+n) were written through the map
+// without going through the dirty page tracker.
+func (txn *Txn) noteMapWrite(pn pgno, n int) {
+	pageSize := int64(txn.env.pageSize)
+	txn.mapSpans = append(txn.mapSpans, mapSpan{int64(pn) * pageSize, (int64(pn) + int64(n)) * pageSize})
+}
+
+// syncWriteMapPages msyncs the pages a WriteMap commit wrote, ahead of its
+// meta, and reports whether it could. Only the used bytes of each page are
+// synced, rounded out to system pages and merged into runs. It can't off
+// Linux, where a ranged msync isn't as durable as an fsync, or while an
+// earlier commit awaits a sync; the data file is then to be fsynced once the
+// meta is written.
+func (txn *Txn) syncWriteMapPages() (bool, error) {
+	e := txn.env
+	if runtime.GOOS != "linux" || !e.meta.Load().recentMeta().isSteady() || e.groupSyncPending() {
+		return false, nil
+	}
+
+	pageSize := int64(e.pageSize)
+	mapSize := int64(len(e.dataMap.Data()))
+	spans := txn.mapSpans
+	inMap := true
+	txn.dirtyTracker.forEach(func(pn pgno, p *page) {
+		start := int64(pn) * pageSize
+		if start+int64(len(p.Data)) > mapSize {
+			inMap = false
+			return
+		}
+		if p.isLarge() {
+			// The pages after the first carry no header, and are written whole
+			spans = append(spans, mapSpan{start, start + int64(p.overflowPages())*pageSize})
+			return
+		}
+		lo, hi := p.usedSpan()
+		if p.pageNo() != pn {
+			lo, hi = len(p.Data), len(p.Data)
+		}
+		spans = append(spans, mapSpan{start, start + int64(lo)})
+		if hi < len(p.Data) {
+			spans = append(spans, mapSpan{start + int64(hi), start + int64(len(p.Data))})
+		}
+	})
+	txn.mapSpans = spans
+	if !inMap {
+		return false, nil
+	}
+
+	// Whole system pages, merged where they touch
+	for i := range spans {
+		spans[i] = e.sysPageSpan(spans[i])
+	}
+	slices.SortFunc(spans, func(a, b mapSpan) int { return cmp.Compare(a.start, b.start) })
+	merged := spans[:0]
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	for _, s := range merged {
+		if err := e.syncMapSpan(s); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// syncWriteMapMeta msyncs the meta a WriteMap commit wrote at metaOffset,
+// after syncWriteMapPages synced its pages.
+func (e *Env) syncWriteMapMeta(metaOffset int) error {
+	start := int64(metaOffset)
+	return e.syncMapSpan(e.sysPageSpan(mapSpan{start, start + min(pageHeaderSize+metaSize, int64(e.pageSize))}))
+}
+
+// syncMapSpan msyncs the bytes of s, counting the sync for EnvInfo.
+func (e *Env) syncMapSpan(s mapSpan) error {
+	if s.end <= s.start {
+		return nil
+	}
+	if err := e.dataMap.SyncRange(s.start, s.end-s.start); err != nil {
+		return err
+	}
+	e.msyncs.Add(1)
+	e.msyncBytes.Add(uint64(s.end - s.start))
+	return nil
+}
+
+// sysPageSpan widens s to whole system pages, within the map.
+func (e *Env) sysPageSpan(s mapSpan) mapSpan {
+	return mapSpan{s.start - s.start%sysPageSize, min(alignToSysPageSize(s.end), int64(len(e.dataMap.Data())))}
+}
+
+// groupSyncPending reports whether commits left to a group commit sync are
+// still waiting for it.
+func (e *Env) groupSyncPending() bool {
+	g := &e.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.written > max(g.synced, g.failed)
+}
@@ -91,7 +91,7 @@ const (
 	// ErrBadTxn indicates the transaction is invalid
 	ErrBadTxn ErrorCode = -30782
 
-	// ErrBadValSize indicates invalid key or data size
+	// ErrBadValSize indicates invalid data size
 	ErrBadValSize ErrorCode = -30781
 
 	// ErrBadDBI indicates the DBI handle is invalid
@@ -141,6 +141,10 @@ const (
 
 	// ErrMVCCRetarded indicates parked transaction's snapshot is too old
 	ErrMVCCRetarded ErrorCode = -30410
+
+	// ErrBadKeySize indicates invalid key size. This code is gdbx-specific:
+	// libmdbx reports oversized keys as MDBX_BAD_VALSIZE.
+	ErrBadKeySize ErrorCode = -30409
 )
 
 // Error descriptions
@@ -164,7 +168,7 @@ var errorMessages = map[ErrorCode]string{
 	ErrIncompatible:        "incompatible operation or flags",
 	ErrBadRSlot:            "reader slot corrupted",
 	ErrBadTxn:              "transaction is invalid",
-	ErrBadValSize:          "invalid key or value size",
+	ErrBadValSize:          "invalid value size",
 	ErrBadDBI:              "invalid DBI handle",
 	ErrProblem:             "unexpected internal error",
 	ErrBusy:                "another write transaction is running",
@@ -180,6 +184,7 @@ var errorMessages = map[ErrorCode]string{
 	ErrDanglingDBI:         "dangling DBI handle",
 	ErrOusted:              "parked transaction was evicted",
 	ErrMVCCRetarded:        "MVCC snapshot is too old",
+	ErrBadKeySize:          "invalid key size",
 }
 
 // NewError creates a new Error with the given code
@@ -198,6 +203,65 @@ func WrapError(code ErrorCode, err error) *Error {
 	return e
 }
 
+// newSizeError creates a size error that reports the rejected size and the limit
+func newSizeError(code ErrorCode, size, limit int) *Error {
+	e := NewError(code)
+	e.Message = fmt.Sprintf("%s: %d bytes exceeds maximum of %d", e.Message, size, limit)
+	return e
+}
+
+// newDupSizeError creates the ErrBadValSize for a DupSort value longer than
+// limit, the maximum key size: the values of a key that has several are
+// stored as the keys of its nested tree, so MaxValSize does not apply.
+func newDupSizeError(size, limit int) *Error {
+	e := newSizeError(ErrBadValSize, size, limit)
+	e.Message += " (a DupSort value is stored as a key of its nested tree and is limited to the maximum key size)"
+	return e
+}
+
+// AppendDupError is wrapped in the ErrKeyMismatch returned by a put with
+// AppendDup whose value sorts before the last value of the key. Last is a
+// copy of that value, so a bulk load that hits unsorted input can tell how
+// far off it is and fall back to a put without AppendDup.
+type AppendDupError struct {
+	Last []byte
+}
+
+func (e *AppendDupError) Error() string {
+	const show = 32
+	if len(e.Last) > show {
+		return fmt.Sprintf("AppendDup value sorts before the key's last value %x... (%d bytes)", e.Last[:show], len(e.Last))
+	}
+	return fmt.Sprintf("AppendDup value sorts before the key's last value %x", e.Last)
+}
+
+// AppendGuardError is wrapped in the ErrKeyMismatch returned by a put to a
+// table under Txn.SetAppendGuard whose key does not sort after the last key
+// put to it. Last is a copy of that key.
+type AppendGuardError struct {
+	Last []byte
+}
+
+func (e *AppendGuardError) Error() string {
+	const show = 32
+	if len(e.Last) > show {
+		return fmt.Sprintf("append guard: key does not sort after the last key put %x... (%d bytes)", e.Last[:show], len(e.Last))
+	}
+	return fmt.Sprintf("append guard: key does not sort after the last key put %x", e.Last)
+}
+
+// newKeyWidthError creates the ErrBadKeySize error for an IntegerKey key of
+// size bytes in a table whose keys are width bytes wide, 0 if it is empty
+func newKeyWidthError(size, width int) *Error {
+	e := NewError(ErrBadKeySize)
+	if width == 0 {
+		e.Message = fmt.Sprintf("%s: IntegerKey key of %d bytes, want 4 or 8", e.Message, size)
+	} else {
+		e.Message = fmt.Sprintf("%s: IntegerKey key of %d bytes in a table of %d-byte keys", e.Message, size, width)
+	}
+	return e
+}
+
 // Common error variables for convenience
 var (
 	ErrKeyExistError            = NewError(ErrKeyExist)
@@ -218,6 +282,7 @@ var (
 	ErrBadRSlotError            = NewError(ErrBadRSlot)
 	ErrBadTxnError              = NewError(ErrBadTxn)
 	ErrBadValSizeError          = NewError(ErrBadValSize)
+	ErrBadKeySizeError          = NewError(ErrBadKeySize)
 	ErrBadDBIError              = NewError(ErrBadDBI)
 	ErrProblemError             = NewError(ErrProblem)
 	ErrBusyError                = NewError(ErrBusy)
@@ -0,0 +1,367 @@
+package gdbx
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// estNone is the parent of a modeled page that is the root.
+const estNone int64 = -1
+
+// estNode is a node of a modeled leaf: its key and its size.
+type estNode struct {
+	key  []byte
+	size int
+}
+
+// estChain models the leaves one leaf of the tree becomes: its nodes and
+// those of the pages split off it, in key order.
+type estChain struct {
+	pages  [][]estNode
+	parent int64 // Modeled branch above, estNone for the root
+}
+
+// estBranch models a branch page by the bytes it uses.
+type estBranch struct {
+	used   int
+	parent int64
+}
+
+// estimator holds the model of one EstimateSize call, which replays the
+// descents and splits of the batch's puts on the pages they would touch,
+// leaving the tree as it is. Modeled pages are keyed by page number; pages
+// that do not exist yet get negative ids.
+type estimator struct {
+	c        *Cursor
+	dup      *Cursor // For the DupSort lookups, which move a cursor
+	pageSize int
+	maxSpace int
+	pages    uint64
+	copied   map[pgno]bool
+	chains   map[int64]*estChain
+	branches map[int64]*estBranch
+	lastID   int64
+}
+
+// EstimateSize returns about how many pages writing each keys[i], vals[i] to
+// dbi with Put would allocate, leaf, branch and large pages together,
+// without writing anything. A key given more than once counts once, with its
+// last value, except in DupSort databases, where each of its values counts.
+// The estimate takes every page the batch copies or splits to need a new one,
+// ignoring pages the batch or the garbage collector would free; it is meant
+// to be compared, with some margin, with the room left in the map, to pre-grow
+// the geometry or refuse the batch.
+//
+// It fails with ErrInvalid if keys and vals differ in length, and with
+// ErrBadKeySize or ErrBadValSize for a pair Put would refuse.
+func (txn *Txn) EstimateSize(dbi DBI, keys, vals [][]byte) (pages uint64, err error) {
+	if !txn.valid() {
+		return 0, NewError(ErrBadTxn)
+	}
+	if len(keys) != len(vals) {
+		return 0, NewError(ErrInvalid)
+	}
+	c, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	for i := range keys {
+		if err := c.checkPutSize(keys[i], vals[i]); err != nil {
+			return 0, err
+		}
+	}
+
+	pageSize := int(txn.env.pageSize)
+	e := &estimator{
+		c:        c,
+		pageSize: pageSize,
+		maxSpace: pageSize - pageHeaderSize,
+		copied:   make(map[pgno]bool),
+		chains:   make(map[int64]*estChain),
+		branches: make(map[int64]*estBranch),
+		lastID:   estNone,
+	}
+	dupSort := c.tree.Flags&uint16(DupSort) != 0
+	if dupSort {
+		if e.dup, err = txn.OpenCursor(dbi); err != nil {
+			return 0, err
+		}
+		defer e.dup.Close()
+	}
+
+	// The order of the writes decides how full the leaves are left, so they
+	// are replayed in the order given
+	txn.cacheComparator(dbi)
+	if !dupSort {
+		for i := range keys {
+			if err := e.put(keys[i], vals[i]); err != nil {
+				return 0, err
+			}
+		}
+		return e.pages, nil
+	}
+
+	// Duplicates are charged by key, the keys taken in order of first use
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return txn.compareKeys(dbi, keys[a], keys[b])
+	})
+	var groups [][]int
+	for i := 0; i < len(order); {
+		j := i + 1
+		for j < len(order) && txn.compareKeys(dbi, keys[order[j]], keys[order[i]]) == 0 {
+			j++
+		}
+		groups = append(groups, order[i:j])
+		i = j
+	}
+	slices.SortFunc(groups, func(a, b []int) int { return a[0] - b[0] })
+	var values [][]byte
+	for _, g := range groups {
+		values = values[:0]
+		for _, i := range g {
+			values = append(values, vals[i])
+		}
+		if err := e.putDups(keys[g[0]], values); err != nil {
+			return 0, err
+		}
+	}
+	return e.pages, nil
+}
+
+// checkPutSize returns the error Put would return for the size of key or
+// value.
+func (c *Cursor) checkPutSize(key, value []byte) error {
+	maxKey := c.txn.env.MaxKeySize()
+	if len(key) > maxKey {
+		return newSizeError(ErrBadKeySize, len(key), maxKey)
+	}
+	if c.tree.Flags&uint16(DupSort) != 0 && len(value) > maxKey {
+		return newDupSizeError(len(value), maxKey)
+	}
+	if len(value) > MaxDataSize {
+		return newSizeError(ErrBadValSize, len(value), MaxDataSize)
+	}
+	if limit := c.txn.env.valueLimit(c.dbi); limit > 0 && len(value) > limit {
+		return newSizeError(ErrBadValSize, len(value), limit)
+	}
+	return nil
+}
+
+// locate finds the leaf key goes to and charges the copies of the pages on
+// its path. It returns the leaf's chain, and the index in the leaf and
+// whether key is there as the tree stands, before the batch.
+func (e *estimator) locate(key []byte) (*estChain, int, bool, error) {
+	c := e.c
+	found, err := c.searchForInsert(key)
+	if IsNotFound(err) && c.tree.isEmpty() {
+		// The batch builds the tree from a first leaf
+		ch := e.chains[estNone]
+		if ch == nil {
+			e.pages++
+			ch = &estChain{pages: [][]estNode{nil}, parent: estNone}
+			e.chains[estNone] = ch
+		}
+		return ch, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	parent := estNone
+	for level := int8(0); level <= c.top; level++ {
+		p := c.pages[level]
+		pn := p.pageNo()
+		if c.dirtyMask&(1<<level) == 0 && !e.copied[pn] {
+			e.copied[pn] = true
+			e.pages++
+		}
+		id := int64(pn)
+		if level < c.top {
+			if e.branches[id] == nil {
+				b := &estBranch{parent: parent}
+				for i := 0; i < p.numEntriesFast(); i++ {
+					b.used += 2 + p.calcNodeSizeFast(i)
+				}
+				e.branches[id] = b
+			}
+			parent = id
+			continue
+		}
+		if e.chains[id] == nil {
+			n := p.numEntriesFast()
+			nodes := make([]estNode, n)
+			for i := range nodes {
+				nodes[i] = estNode{nodeGetKeyDirect(p, i), p.calcNodeSizeFast(i)}
+			}
+			e.chains[id] = &estChain{pages: [][]estNode{nodes}, parent: parent}
+		}
+	}
+	return e.chains[int64(c.pages[c.top].pageNo())], int(c.indices[c.top]), found, nil
+}
+
+// put models storing value under key in a plain database.
+func (e *estimator) put(key, value []byte) error {
+	ch, _, _, err := e.locate(key)
+	if err != nil {
+		return err
+	}
+	size := nodeSize + len(key) + len(value)
+	if len(value) > e.c.txn.env.MaxValSize() || size > e.maxSpace-2 {
+		e.pages += uint64(overflowPagesFor(len(value), e.pageSize))
+		size = nodeSize + len(key) + 4
+	}
+	e.store(ch, estNode{key, size})
+	return nil
+}
+
+// putDups models adding values to key in a DupSort database.
+func (e *estimator) putDups(key []byte, values [][]byte) error {
+	txn, dbi := e.c.txn, e.c.dbi
+	slices.SortFunc(values, func(a, b []byte) int { return txn.compareDupValues(dbi, a, b) })
+	values = slices.CompactFunc(values, func(a, b []byte) bool { return txn.compareDupValues(dbi, a, b) == 0 })
+	// Values already stored change nothing
+	values = slices.DeleteFunc(values, func(v []byte) bool {
+		_, _, err := e.dup.Get(key, v, GetBoth)
+		return err == nil
+	})
+	if len(values) == 0 {
+		return nil
+	}
+	added := 0
+	for _, v := range values {
+		added += 2 + nodeSize + len(v)
+	}
+
+	ch, idx, found, err := e.locate(key)
+	if err != nil {
+		return err
+	}
+	subPage := pageHeaderSize + added
+	if found {
+		p := e.c.pages[e.c.top]
+		flags := nodeGetFlagsDirect(p, idx)
+		switch {
+		case flags&nodeTree != 0:
+			// The values go into the existing sub-tree, whose path is copied
+			height := 0
+			if data := nodeGetDataDirect(p, idx); len(data) >= treeSize {
+				height = int(binary.LittleEndian.Uint16(data[2:]))
+			}
+			e.pages += uint64(height + (added+e.maxSpace-1)/e.maxSpace)
+			e.store(ch, estNode{key, nodeSize + len(key) + treeSize})
+			return nil
+		case flags&nodeDup != 0:
+			subPage = int(nodeGetDataSizeDirect(p, idx)) + added
+		default:
+			// A single value becomes a sub-page with the new ones
+			subPage += 2 + nodeSize + int(nodeGetDataSizeDirect(p, idx))
+		}
+	}
+	size := nodeSize + len(key) + subPage
+	if size > txn.env.LeafNodeMax() {
+		// Too many for a sub-page: they move to a sub-tree of their own
+		size = nodeSize + len(key) + treeSize
+		e.pages += e.treePages(subPage-pageHeaderSize, added/len(values)-2)
+	}
+	e.store(ch, estNode{key, size})
+	return nil
+}
+
+// treePages returns the pages of a tree built from nodes taking used bytes
+// of leaf, keys of about klen bytes.
+func (e *estimator) treePages(used, klen int) uint64 {
+	n := max((used+e.maxSpace-1)/e.maxSpace, 1)
+	pages := uint64(n)
+	for n > 1 {
+		n = (n*(2+nodeSize+klen) + e.maxSpace - 1) / e.maxSpace
+		pages += uint64(n)
+	}
+	return pages
+}
+
+// store puts node in the leaves modeled by ch, in place of the node with the
+// same key if there is one, splitting the leaf it lands in if it is full.
+func (e *estimator) store(ch *estChain, node estNode) {
+	// Past the first leaf, a key goes to the last whose first key is not
+	// above it, as the descent takes it there
+	i := len(ch.pages) - 1
+	for i > 0 && e.compare(node.key, ch.pages[i][0].key) < 0 {
+		i--
+	}
+	pg := ch.pages[i]
+	pos, found := slices.BinarySearchFunc(pg, node.key, func(n estNode, key []byte) int {
+		return e.compare(n.key, key)
+	})
+	used := estUsed(pg)
+	if found {
+		if used-pg[pos].size+node.size <= e.maxSpace {
+			pg[pos] = node
+			return
+		}
+		// A node that outgrows its page is put again, as a Put does
+		pg = slices.Delete(pg, pos, pos+1)
+		used = estUsed(pg)
+	}
+	if used+2+node.size <= e.maxSpace {
+		ch.pages[i] = slices.Insert(pg, pos, node)
+		return
+	}
+	split := splitIndex(len(pg), func(j int) int { return pg[j].size }, e.pageSize, node.size, pos)
+	var left, right []estNode
+	switch {
+	case split == 0:
+		left, right = []estNode{node}, pg
+	case pos < split:
+		left = slices.Insert(slices.Clone(pg[:split]), pos, node)
+		right = slices.Clone(pg[split:])
+	default:
+		left = slices.Clone(pg[:split])
+		right = slices.Insert(slices.Clone(pg[split:]), pos-split, node)
+	}
+	ch.pages[i] = left
+	ch.pages = slices.Insert(ch.pages, i+1, right)
+	e.pages++
+	ch.parent = e.separate(ch.parent, len(right[0].key))
+}
+
+// compare orders keys as the database does.
+func (e *estimator) compare(a, b []byte) int {
+	return e.c.txn.compareKeys(e.c.dbi, a, b)
+}
+
+// separate adds a separator for a key of klen bytes to the branch id, and
+// returns the branch, which is new if the split page was the root.
+func (e *estimator) separate(id int64, klen int) int64 {
+	size := 2 + nodeSize + klen
+	if id == estNone {
+		// The root split: a new root holds both halves
+		e.pages++
+		e.lastID--
+		e.branches[e.lastID] = &estBranch{used: 2 + nodeSize + size, parent: estNone}
+		return e.lastID
+	}
+	b := e.branches[id]
+	if b.used+size > e.maxSpace {
+		e.pages++
+		b.used = (b.used + size) / 2
+		b.parent = e.separate(b.parent, klen)
+		return id
+	}
+	b.used += size
+	return id
+}
+
+// estUsed returns the bytes the nodes of a modeled leaf take, pointers
+// included.
+func estUsed(nodes []estNode) int {
+	used := 0
+	for _, n := range nodes {
+		used += 2 + n.size
+	}
+	return used
+}
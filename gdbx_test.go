@@ -1,9 +1,15 @@
 package gdbx
 
 import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -4073,6 +4079,9 @@ func TestMultipleNamedDatabases(t *testing.T) {
 			}
 
 			val := []byte(fmt.Sprintf("val%d_%s", j, name))
+			if dbFlags[i]&DupFixed != 0 {
+				val = []byte(fmt.Sprintf("val%d____", j)) // All values 8 bytes wide
+			}
 			if err := txn.Put(dbi, key, val, 0); err != nil {
 				txn.Abort()
 				t.Fatalf("Put to %s failed: %v", name, err)
@@ -4136,3 +4145,674 @@ func TestMultipleNamedDatabases(t *testing.T) {
 
 	t.Log("Multiple named databases test passed")
 }
+
+// checkTreeShape walks every page of tr and verifies that all leaves sit at
+// depth tr.Height, that no reachable page is empty, and that the page counts
+// add up. It returns the number of levels found.
+func checkTreeShape(t *testing.T, txn *Txn, tr *tree) int {
+	t.Helper()
+	if tr.isEmpty() {
+		if tr.Height != 0 {
+			t.Fatalf("empty tree has Height %d", tr.Height)
+		}
+		return 0
+	}
+
+	leafDepth := -1
+	var walk func(pg pgno, depth int)
+	walk = func(pg pgno, depth int) {
+		p, err := txn.getPage(pg)
+		if err != nil {
+			t.Fatalf("page %d: %v", pg, err)
+		}
+		n := p.numEntries()
+		if n == 0 {
+			t.Fatalf("page %d at depth %d is empty", pg, depth)
+		}
+		if p.isLeaf() {
+			if leafDepth == -1 {
+				leafDepth = depth
+			} else if depth != leafDepth {
+				t.Fatalf("leaf %d at depth %d, other leaves at depth %d", pg, depth, leafDepth)
+			}
+			return
+		}
+		for i := 0; i < n; i++ {
+			walk(nodeGetChildPgnoFast(p, i), depth+1)
+		}
+	}
+	walk(tr.Root, 1)
+
+	if int(tr.Height) != leafDepth {
+		t.Fatalf("tree Height %d, real depth %d", tr.Height, leafDepth)
+	}
+	return leafDepth
+}
+
+// TestTreeHeightMaintenance verifies that Height always matches the real
+// number of levels while a tree grows through splits and shrinks through
+// deletes in several orders, including down to an empty tree.
+func TestTreeHeightMaintenance(t *testing.T) {
+	orders := map[string]func(n int) []int{
+		"ascending": func(n int) []int {
+			idx := make([]int, n)
+			for i := range idx {
+				idx[i] = i
+			}
+			return idx
+		},
+		"descending": func(n int) []int {
+			idx := make([]int, n)
+			for i := range idx {
+				idx[i] = n - 1 - i
+			}
+			return idx
+		},
+		"random": func(n int) []int {
+			return rand.New(rand.NewSource(3)).Perm(n)
+		},
+	}
+
+	for name, order := range orders {
+		t.Run(name, func(t *testing.T) {
+			env, err := NewEnv(Default)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer env.Close()
+			if err := env.Open(filepath.Join(t.TempDir(), "test.db"), NoSubdir, 0644); err != nil {
+				t.Fatal(err)
+			}
+			env.SetAssertHook(func(cond bool, msg string) {
+				if !cond {
+					t.Fatalf("invariant violated: %s", msg)
+				}
+			})
+
+			txn, err := env.BeginTxn(nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer txn.Abort()
+
+			// Long keys keep fan-out low so the tree reaches several levels
+			const numKeys = 3000
+			key := func(i int) []byte { return []byte(fmt.Sprintf("%0400d", i)) }
+			for _, i := range order(numKeys) {
+				if err := txn.Put(MainDBI, key(i), []byte("v"), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			maxDepth := checkTreeShape(t, txn, &txn.trees[MainDBI])
+			if maxDepth < 3 {
+				t.Fatalf("tree only reached depth %d", maxDepth)
+			}
+
+			for n, i := range order(numKeys) {
+				if err := txn.Del(MainDBI, key(i), nil); err != nil {
+					t.Fatalf("Del %d: %v", i, err)
+				}
+				if n%50 == 0 || n > numKeys-50 {
+					checkTreeShape(t, txn, &txn.trees[MainDBI])
+				}
+			}
+			if !txn.trees[MainDBI].isEmpty() || txn.trees[MainDBI].Items != 0 {
+				t.Fatalf("tree not empty after deleting all keys: %+v", txn.trees[MainDBI])
+			}
+
+			// Deleting through a cursor scan must keep its position across freed levels
+			for _, i := range order(numKeys) {
+				if err := txn.Put(MainDBI, key(i), []byte("v"), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cursor, err := txn.OpenCursor(MainDBI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cursor.Close()
+			deleted := 0
+			for k, _, err := cursor.Get(nil, nil, First); err == nil; k, _, err = cursor.Get(nil, nil, Next) {
+				if string(k) != string(key(deleted)) {
+					t.Fatalf("scan returned %.10q..., want key %d", k, deleted)
+				}
+				if err := cursor.Del(0); err != nil {
+					t.Fatal(err)
+				}
+				deleted++
+				if deleted%50 == 0 {
+					checkTreeShape(t, txn, &txn.trees[MainDBI])
+				}
+			}
+			if deleted != numKeys {
+				t.Fatalf("cursor scan deleted %d keys, want %d", deleted, numKeys)
+			}
+			checkTreeShape(t, txn, &txn.trees[MainDBI])
+		})
+	}
+}
+
+// TestReadaheadLimit checks that readahead is turned off when the mapping
+// outgrows the limit, both when the limit changes and when the map grows.
+func TestReadaheadLimit(t *testing.T) {
+	open := func(flags uint) *Env {
+		t.Helper()
+		env, err := NewEnv(Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Open(filepath.Join(t.TempDir(), "test.db"), NoSubdir|flags, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return env
+	}
+	setLimit := func(env *Env, limit int64, wantOff bool) {
+		t.Helper()
+		if err := env.SetReadaheadLimit(limit); err != nil {
+			t.Fatal(err)
+		}
+		if off := env.readaheadOff.Load(); off != wantOff {
+			t.Fatalf("limit %d, map size %d: readahead off = %v, want %v", limit, env.dataMap.Size(), off, wantOff)
+		}
+	}
+
+	env := open(0)
+	defer env.Close()
+	size := env.dataMap.Size()
+	setLimit(env, size, false)
+	setLimit(env, size-1, true)
+	setLimit(env, -1, false)
+
+	// Growing the map past the limit disables readahead
+	setLimit(env, size, false)
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := txn.Put(MainDBI, []byte(fmt.Sprintf("key%05d", i)), make([]byte, 1000), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if env.dataMap.Size() <= size {
+		t.Fatalf("map did not grow from %d bytes", size)
+	}
+	if !env.readaheadOff.Load() {
+		t.Fatal("readahead still on after the map outgrew the limit")
+	}
+
+	// NoReadAhead disables readahead whatever the limit
+	noRA := open(NoReadAhead)
+	defer noRA.Close()
+	setLimit(noRA, -1, true)
+}
+
+// TestPutUnchangedValue checks that writing back the stored value of a key,
+// inline or on overflow pages, dirties no page, while a changed value does.
+func TestPutUnchangedValue(t *testing.T) {
+	env, err := NewEnv(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.Open(filepath.Join(t.TempDir(), "test.db"), NoSubdir, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	small := []byte("value")
+	big := make([]byte, 3*int(env.pageSize))
+	for i := range big {
+		big[i] = byte(i)
+	}
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range [][2][]byte{{[]byte("small"), small}, {[]byte("big"), big}} {
+		if err := txn.Put(MainDBI, kv[0], kv[1], 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	items := txn.trees[MainDBI].Items
+	for _, kv := range [][2][]byte{{[]byte("small"), small}, {[]byte("big"), big}} {
+		if err := txn.Put(MainDBI, kv[0], append([]byte(nil), kv[1]...), 0); err != nil {
+			t.Fatal(err)
+		}
+		if n := txn.dirtyTracker.len(); n != 0 {
+			t.Fatalf("Put of the unchanged %s value dirtied %d pages", kv[0], n)
+		}
+	}
+	if txn.trees[MainDBI].Items != items {
+		t.Fatalf("Items changed from %d to %d", items, txn.trees[MainDBI].Items)
+	}
+
+	// A value differing in its last byte is written
+	changed := append([]byte(nil), big...)
+	changed[len(changed)-1]++
+	if err := txn.Put(MainDBI, []byte("big"), changed, 0); err != nil {
+		t.Fatal(err)
+	}
+	if txn.dirtyTracker.len() == 0 {
+		t.Fatal("Put of a changed value dirtied no page")
+	}
+	if v, err := txn.Get(MainDBI, []byte("big")); err != nil || !bytes.Equal(v, changed) {
+		t.Fatalf("Get after the changed Put: %v", err)
+	}
+}
+
+// TestAllocStrategy deletes every key of a large tree and appends new keys in
+// the same transaction. Sequential allocation must lay the new leaves out in
+// key order at the end of the file; reuse-first must build them from the
+// freed pages and leave the file smaller.
+func TestAllocStrategy(t *testing.T) {
+	build := func(strategy AllocStrategy) (leaves []pgno, allocated pgno) {
+		t.Helper()
+		env, err := NewEnv(Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.Close()
+		if err := env.SetAllocStrategy(strategy); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Open(filepath.Join(t.TempDir(), "test.db"), NoSubdir, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		key := func(i int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(i)) }
+		value := make([]byte, 100)
+		for i := 0; i < 5000; i++ {
+			if err := txn.Put(MainDBI, key(i), value, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < 5000; i++ {
+			if err := txn.Del(MainDBI, key(i), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 5000; i < 10000; i++ {
+			if err := txn.Put(MainDBI, key(i), value, Append); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		c, err := txn.OpenCursor(MainDBI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		for _, _, err := c.Get(nil, nil, First); err == nil; _, _, err = c.Get(nil, nil, Next) {
+			if pn := c.pages[c.top].pageNo(); len(leaves) == 0 || leaves[len(leaves)-1] != pn {
+				leaves = append(leaves, pn)
+			}
+		}
+		return leaves, txn.allocatedPg
+	}
+
+	seqLeaves, seqAllocated := build(AllocSequential)
+	for i := 1; i < len(seqLeaves); i++ {
+		if seqLeaves[i] <= seqLeaves[i-1] {
+			t.Fatalf("sequential leaf %d is page %d after page %d", i, seqLeaves[i], seqLeaves[i-1])
+		}
+	}
+	reuseLeaves, reuseAllocated := build(AllocReuseFirst)
+	if reuseAllocated >= seqAllocated {
+		t.Fatalf("reuse-first allocated %d pages, sequential %d", reuseAllocated, seqAllocated)
+	}
+	if len(reuseLeaves) != len(seqLeaves) {
+		t.Fatalf("reuse-first built %d leaves, sequential %d", len(reuseLeaves), len(seqLeaves))
+	}
+
+	env, err := NewEnv(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.SetAllocStrategy(AllocSequential + 1); Code(err) != ErrInvalid {
+		t.Fatalf("unknown strategy: got %v, want ErrInvalid", err)
+	}
+}
+
+// TestCommitVerifiesTreeHeaders modifies several named databases in one
+// transaction. All their headers must reach MainDBI and survive a reopen, and
+// a commit that would lose one of them must fail instead.
+func TestCommitVerifiesTreeHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	open := func() *Env {
+		t.Helper()
+		env, err := NewEnv(Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.SetMaxDBs(10)
+		if err := env.Open(path, NoSubdir, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return env
+	}
+	names := []string{"a", "b", "c", "d"}
+	fill := func(txn *Txn, from, to int) []DBI {
+		t.Helper()
+		dbis := make([]DBI, len(names))
+		for n, name := range names {
+			dbi, err := txn.OpenDBISimple(name, Create)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dbis[n] = dbi
+			for i := from; i < to; i++ {
+				if err := txn.Put(dbi, []byte(fmt.Sprintf("%s%05d", name, i)), make([]byte, 64), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return dbis
+	}
+
+	env := open()
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(txn, 0, 1000)
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A modified database whose dirty mark went missing fails the commit
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbis := fill(txn, 1000, 2000)
+	txn.dbiDirty[dbis[2]] = false
+	if _, err := txn.Commit(); Code(err) != ErrProblem || !errors.Is(err, errTreeNotPersisted) {
+		t.Fatalf("commit with a lost tree header: got %v, want ErrProblem", err)
+	}
+
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(txn, 1000, 2000)
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	env = open()
+	defer env.Close()
+	err = env.View(func(txn *Txn) error {
+		for _, name := range names {
+			dbi, err := txn.OpenDBISimple(name, 0)
+			if err != nil {
+				return err
+			}
+			stat, err := txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+			if stat.Entries != 2000 {
+				return fmt.Errorf("database %s has %d entries after reopen, want 2000", name, stat.Entries)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestOverflowArena stores many big values in one transaction. Their
+// overflow runs must come out of a few contiguous arenas rather than being
+// interleaved with the tree pages, every value must read back, and every
+// page of the file must belong to the tree.
+func TestOverflowArena(t *testing.T) {
+	env, err := NewEnv(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.Open(filepath.Join(t.TempDir(), "test.db"), NoSubdir, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pageSize := int(env.pageSize)
+
+	const n = 1000
+	key := func(i int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(i*7919%n)) }
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i)}, (1+i%4)*pageSize-i%100)
+	}
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := txn.Put(MainDBI, key(i), value(i), 0); err != nil {
+			txn.Abort()
+			t.Fatal(err)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err = env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	type run struct{ first, end pgno }
+	var runs []run
+	for i := 0; i < n; i++ {
+		c, err := txn.OpenCursor(MainDBI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, v, err := c.Get(key(i), nil, Set); err != nil || !bytes.Equal(v, value(i)) {
+			t.Fatalf("value %d: %d bytes, %v", i, len(v), err)
+		}
+		p := c.pages[c.top]
+		first := nodeGetOverflowPgnoDirect(p, int(c.indices[c.top]))
+		runs = append(runs, run{first, first + pgno(overflowPagesFor(len(value(i)), pageSize))})
+		c.Close()
+	}
+	slices.SortFunc(runs, func(a, b run) int { return cmp.Compare(a.first, b.first) })
+	breaks := 0
+	for i := 1; i < len(runs); i++ {
+		if runs[i].first < runs[i-1].end {
+			t.Fatalf("overflow runs at pages %d and %d overlap", runs[i-1].first, runs[i].first)
+		}
+		if runs[i].first != runs[i-1].end {
+			breaks++
+		}
+	}
+	if breaks > 5 {
+		t.Fatalf("overflow runs broken up %d times", breaks)
+	}
+
+	treePages := map[pgno]bool{}
+	c, err := txn.OpenCursor(MainDBI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, _, err := c.Get(nil, nil, First); err == nil; _, _, err = c.Get(nil, nil, Next) {
+		for i := 0; i <= int(c.top); i++ {
+			treePages[c.pages[i].pageNo()] = true
+		}
+	}
+	used := NumMetas + len(treePages)
+	for _, r := range runs {
+		used += int(r.end - r.first)
+	}
+	info, err := env.Info(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LastPgNo != int64(used) {
+		t.Fatalf("file has %d pages, the tree uses %d", info.LastPgNo, used)
+	}
+}
+
+// TestTreeDepthLimit fakes a tree at the depth a cursor can follow, since a
+// real one would take billions of entries. A put that splits its root must
+// fail with ErrCursorFull and leave the transaction unusable, while a tree one
+// level shallower still grows to the limit.
+func TestTreeDepthLimit(t *testing.T) {
+	env, err := NewEnv(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.Open(filepath.Join(t.TempDir(), "test.db"), NoSubdir, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// fill puts keys until the root leaf splits, and returns that put's error
+	fill := func(height int) (*Txn, error) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Put(MainDBI, []byte("k0000"), make([]byte, 100), 0); err != nil {
+			t.Fatal(err)
+		}
+		txn.trees[MainDBI].Height = uint16(height)
+		root := txn.trees[MainDBI].Root
+		for i := 1; txn.trees[MainDBI].Root == root; i++ {
+			if err := txn.Put(MainDBI, []byte(fmt.Sprintf("k%04d", i)), make([]byte, 100), 0); err != nil {
+				return txn, err
+			}
+		}
+		return txn, nil
+	}
+
+	txn, err := fill(CursorStackSize - 1)
+	if err != nil {
+		t.Fatalf("splitting a root %d levels deep: %v", CursorStackSize-1, err)
+	}
+	if h := txn.trees[MainDBI].Height; h != CursorStackSize {
+		t.Fatalf("height %d after the root split, want %d", h, CursorStackSize)
+	}
+	txn.Abort()
+
+	txn, err = fill(CursorStackSize)
+	defer txn.Abort()
+	if Code(err) != ErrCursorFull || !errors.Is(err, errTreeTooDeep) {
+		t.Fatalf("splitting a root %d levels deep: got %v, want ErrCursorFull", CursorStackSize, err)
+	}
+	if err := txn.Put(MainDBI, []byte("more"), nil, 0); Code(err) != ErrBadTxn {
+		t.Fatalf("put after the failed split: got %v, want ErrBadTxn", err)
+	}
+	if h := txn.trees[MainDBI].Height; h != CursorStackSize {
+		t.Fatalf("height %d after the failed split, want %d", h, CursorStackSize)
+	}
+}
+
+// metaFaultWriter writes meta pages to f unless drop is set, in which case
+// it reports success without writing, like storage that loses the write.
+type metaFaultWriter struct {
+	f    *os.File
+	drop bool
+}
+
+func (w *metaFaultWriter) WriteAt(p []byte, off int64) (int, error) {
+	if w.drop {
+		return len(p), nil
+	}
+	return w.f.WriteAt(p, off)
+}
+
+// TestCommitVerify loses the meta page writes of a commit. Without commit
+// verification the commit reports success; with it, it fails with
+// ErrProblem and the Env stays on the previous commit, which the next good
+// commit then builds on.
+func TestCommitVerify(t *testing.T) {
+	env, err := NewEnv(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := env.Open(path, NoSubdir, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := &metaFaultWriter{f: env.dataFile}
+	env.metaWriter = w
+
+	put := func(k string) error {
+		return env.Update(func(txn *Txn) error {
+			return txn.Put(MainDBI, []byte(k), []byte("v"), 0)
+		})
+	}
+	if err := env.SetCommitVerify(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := put("a"); err != nil {
+		t.Fatalf("commit with verification: %v", err)
+	}
+
+	w.drop = true
+	if err := env.SetCommitVerify(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := put("lost"); err != nil {
+		t.Fatalf("unverified commit of a lost meta: %v", err)
+	}
+	if err := env.SetCommitVerify(true); err != nil {
+		t.Fatal(err)
+	}
+	before := env.meta.Load().txnids
+	if err := put("b"); Code(err) != ErrProblem {
+		t.Fatalf("verified commit of a lost meta: got %v, want ErrProblem", err)
+	}
+	if after := env.meta.Load().txnids; after != before {
+		t.Fatalf("failed commit moved the metas from %v to %v", before, after)
+	}
+
+	w.drop = false
+	if err := put("c"); err != nil {
+		t.Fatalf("commit after the fault: %v", err)
+	}
+	env.Close()
+
+	env, err = NewEnv(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.Open(path, NoSubdir, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) error {
+		for k, want := range map[string]bool{"a": true, "b": false, "c": true} {
+			if _, err := txn.Get(MainDBI, []byte(k)); (err == nil) != want {
+				t.Errorf("%s after reopening: %v, want present %v", k, err, want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@
 package gdbx
 
 import (
+	"encoding/binary"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -167,8 +168,19 @@ func openLockFileReadOnly(path string, maxReaders int) (*lockFile, error) {
 		f = nil
 	}
 
+	lf := memLockFile(maxReaders)
+	lf.file = f
+	return lf, nil
+}
+
+// memLockFile returns a lockless lock file whose header and reader slots
+// live in memory, shared with no other process.
+func memLockFile(maxReaders int) *lockFile {
+	if maxReaders <= 0 {
+		maxReaders = defaultMaxReaders
+	}
+
 	lf := &lockFile{
-		file:       f,
 		maxReaders: maxReaders,
 		lockless:   true, // Mark as lockless mode
 	}
@@ -183,8 +195,7 @@ func openLockFileReadOnly(path string, maxReaders int) (*lockFile, error) {
 		numReaders:      0,
 	}
 	lf.header = lf.memHeader
-
-	return lf, nil
+	return lf
 }
 
 // initialize creates a new lock file.
@@ -290,6 +301,54 @@ func (lf *lockFile) unlockWriter() error {
 	return nil
 }
 
+// lockDataFile takes the opener lock on an environment's data file: shared
+// for ordinary opens, exclusive for Exclusive ones. It reports errLockBusy
+// when a conflicting lock is held. The lock goes away with the file.
+func lockDataFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errLockBusy
+	}
+	if err != nil && exclusive {
+		return &lockError{"lock data file", err}
+	}
+	// A filesystem without flock support still allows ordinary opens
+	return nil
+}
+
+// fileIdentity returns the device and inode of f, which tell a file apart
+// from a copy of it put in its place.
+func fileIdentity(f *os.File) ([16]byte, error) {
+	var id [16]byte
+	fi, err := f.Stat()
+	if err != nil {
+		return id, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return id, errLockInvalidFile
+	}
+	binary.LittleEndian.PutUint64(id[0:], uint64(st.Dev))
+	binary.LittleEndian.PutUint64(id[8:], uint64(st.Ino))
+	return id, nil
+}
+
+// upgradeDataFileLock trades an ordinary opener's shared lock on the data
+// file for an exclusive one if no other opener holds it, and reports whether
+// it did. downgradeDataFileLock trades it back.
+func upgradeDataFileLock(f *os.File) bool {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil
+}
+
+// downgradeDataFileLock returns the data file lock to a shared one.
+func downgradeDataFileLock(f *os.File) {
+	lockDataFile(f, false)
+}
+
 // hasActiveReaders returns true if any reader slots are in use.
 // Used to determine if old mmaps can be safely cleaned up.
 func (lf *lockFile) hasActiveReaders() bool {
@@ -444,6 +503,7 @@ var (
 	errLockFileTooSmall = &lockError{"lock file too small", nil}
 	errLockInvalidFile  = &lockError{"invalid lock file", nil}
 	errLockReadersFull  = &lockError{"reader slots full", nil}
+	errLockBusy         = &lockError{"data file in use by a conflicting opener", nil}
 )
 
 type lockError struct {
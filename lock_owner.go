@@ -0,0 +1,113 @@
+package gdbx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// lockOwnerSuffix is appended to the lock file name to name its owner record,
+// which ties the lock file to the data file it serves. libmdbx's lock file
+// header has no room for it.
+const lockOwnerSuffix = ".owner"
+
+// lockOwnerMagic starts a lock file owner record.
+const lockOwnerMagic uint64 = 0x4944425844425847 // "GXBDXBDI"
+
+// lockOwnerSize is the size of a lock file owner record: the magic, the
+// DXBID of the data file and the identity of the lock file.
+const lockOwnerSize = 8 + 16 + 16
+
+// claimLockFile ties the lock file to the data file whose meta m is. A lock
+// file recorded for another database, or put in place of the recorded one,
+// is reset if no other opener holds the data file and refused otherwise.
+// Data files without a DXBID are not checked.
+func (e *Env) claimLockFile(m *meta) error {
+	lf := e.lockFile
+	id := m.DXBID
+	if lf.lockless || lf.file == nil || id == ([16]byte{}) {
+		return nil
+	}
+	lockID, err := fileIdentity(lf.file)
+	if err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	path := lf.file.Name() + lockOwnerSuffix
+	owner, ownerLock, ok := readLockOwner(path)
+	if !ok {
+		// Not recorded yet
+		if e.flags&ReadOnly != 0 {
+			return nil
+		}
+		return writeLockOwner(path, id, lockID)
+	}
+	if owner == id && ownerLock == lockID {
+		return nil
+	}
+
+	var why string
+	if owner != id {
+		why = fmt.Sprintf("belongs to database %x, not to this data file's %x", owner, id)
+	} else {
+		why = fmt.Sprintf("was put in place of the one recorded for database %x", id)
+	}
+	if e.flags&ReadOnly != 0 || (e.flags&Exclusive == 0 && !upgradeDataFileLock(e.dataFile)) {
+		return WrapError(ErrInvalid, fmt.Errorf(
+			"gdbx: lock file %s and is in use; close the other openers or remove the lock file", why))
+	}
+	lf.reset()
+	err = writeLockOwner(path, id, lockID)
+	if e.flags&Exclusive == 0 {
+		downgradeDataFileLock(e.dataFile)
+	}
+	if err != nil {
+		return err
+	}
+	if owner != id && globalLogger != nil && (globalLogLevel == LogLvlDoNotChange || globalLogLevel >= LogLvlWarn) {
+		globalLogger(fmt.Sprintf("gdbx: %s: lock file belonged to database %x, not %x; reinitialized it", e.path, owner, id))
+	}
+	return nil
+}
+
+// readLockOwner reads the owner record at path, reporting false if there is
+// none or it is not one.
+func readLockOwner(path string) (id, lockID [16]byte, ok bool) {
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) != lockOwnerSize || binary.LittleEndian.Uint64(b) != lockOwnerMagic {
+		return id, lockID, false
+	}
+	copy(id[:], b[8:24])
+	copy(lockID[:], b[24:40])
+	return id, lockID, true
+}
+
+// writeLockOwner records at path that the lock file lockID serves the data
+// file id.
+func writeLockOwner(path string, id, lockID [16]byte) error {
+	b := make([]byte, lockOwnerSize)
+	binary.LittleEndian.PutUint64(b, lockOwnerMagic)
+	copy(b[8:24], id[:])
+	copy(b[24:40], lockID[:])
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return WrapError(ErrInvalid, err)
+	}
+	return nil
+}
+
+// removeLockFile removes the lock file at path and its owner record.
+func removeLockFile(path string) error {
+	if err := os.Remove(path + lockOwnerSuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// reset clears the lock file's readers and coordination state. The caller
+// must be the lock file's only user.
+func (lf *lockFile) reset() {
+	clear(lf.slots)
+	lf.freeMu.Lock()
+	lf.freeSlots = lf.freeSlots[:0]
+	lf.freeMu.Unlock()
+	*lf.header = lockHeader{magicAndVersion: lockMagic}
+}
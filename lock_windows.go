@@ -3,6 +3,7 @@
 package gdbx
 
 import (
+	"encoding/binary"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -148,8 +149,19 @@ func openLockFileReadOnly(path string, maxReaders int) (*lockFile, error) {
 		f = nil
 	}
 
+	lf := memLockFile(maxReaders)
+	lf.file = f
+	return lf, nil
+}
+
+// memLockFile returns a lockless lock file whose header and reader slots
+// live in memory.
+func memLockFile(maxReaders int) *lockFile {
+	if maxReaders <= 0 {
+		maxReaders = defaultMaxReaders
+	}
+
 	lf := &lockFile{
-		file:       f,
 		maxReaders: maxReaders,
 		lockless:   true,
 	}
@@ -162,8 +174,7 @@ func openLockFileReadOnly(path string, maxReaders int) (*lockFile, error) {
 		numReaders:      0,
 	}
 	lf.header = lf.memHeader
-
-	return lf, nil
+	return lf
 }
 
 // initialize creates a new lock file.
@@ -305,6 +316,63 @@ func (lf *lockFile) unlockWriter() error {
 	return nil
 }
 
+// lockDataFile takes the opener lock on an environment's data file: shared
+// for ordinary opens, exclusive for Exclusive ones. The lock covers one byte
+// far past the end of the file, so it never blocks page reads and writes.
+// It reports errLockBusy when a conflicting lock is held. The lock goes away
+// with the file.
+func lockDataFile(f *os.File, exclusive bool) error {
+	flags := uint32(windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	overlapped := windows.Overlapped{Offset: 0xFFFFFFFF, OffsetHigh: 0x7FFFFFFF}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &overlapped)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errLockBusy
+	}
+	if err != nil && exclusive {
+		return &lockError{"lock data file", err}
+	}
+	// A filesystem without lock support still allows ordinary opens
+	return nil
+}
+
+// fileIdentity returns the volume serial number and file index of f, which
+// tell a file apart from a copy of it put in its place.
+func fileIdentity(f *os.File) ([16]byte, error) {
+	var id [16]byte
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(windows.Handle(f.Fd()), &info); err != nil {
+		return id, err
+	}
+	binary.LittleEndian.PutUint32(id[0:], info.VolumeSerialNumber)
+	binary.LittleEndian.PutUint32(id[4:], info.FileIndexHigh)
+	binary.LittleEndian.PutUint32(id[8:], info.FileIndexLow)
+	return id, nil
+}
+
+// upgradeDataFileLock trades an ordinary opener's shared lock on the data
+// file for an exclusive one if no other opener holds it, and reports whether
+// it did. Windows does not convert locks, so the shared lock is dropped
+// first and taken again if the exclusive one is refused.
+func upgradeDataFileLock(f *os.File) bool {
+	overlapped := windows.Overlapped{Offset: 0xFFFFFFFF, OffsetHigh: 0x7FFFFFFF}
+	windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &overlapped)
+	if lockDataFile(f, true) == nil {
+		return true
+	}
+	lockDataFile(f, false)
+	return false
+}
+
+// downgradeDataFileLock returns the data file lock to a shared one.
+func downgradeDataFileLock(f *os.File) {
+	overlapped := windows.Overlapped{Offset: 0xFFFFFFFF, OffsetHigh: 0x7FFFFFFF}
+	windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &overlapped)
+	lockDataFile(f, false)
+}
+
 // hasActiveReaders returns true if any reader slots are in use.
 func (lf *lockFile) hasActiveReaders() bool {
 	if lf.lockless {
@@ -446,6 +514,7 @@ var (
 	errLockFileTooSmall = &lockError{"lock file too small", nil}
 	errLockInvalidFile  = &lockError{"invalid lock file", nil}
 	errLockReadersFull  = &lockError{"reader slots full", nil}
+	errLockBusy         = &lockError{"data file in use by a conflicting opener", nil}
 )
 
 type lockError struct {
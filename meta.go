@@ -1,7 +1,12 @@
 package gdbx
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/hex"
+	"math/bits"
+	"os"
+	"sync"
 	"sync/atomic"
 	"unsafe"
 )
@@ -104,6 +109,13 @@ func (m *meta) magicValid() bool {
 	return (magic >> 8) == metaMagic
 }
 
+// magicByteSwapped returns true if the magic number is valid with its bytes
+// reversed: the meta was written on a host of the other byte order.
+func (m *meta) magicByteSwapped() bool {
+	magic := uint64(m.MagicAndVersion[0]) | (uint64(m.MagicAndVersion[1]) << 32)
+	return bits.ReverseBytes64(magic)>>8 == metaMagic
+}
+
 // version returns the data format version.
 func (m *meta) version() uint8 {
 	return uint8(m.MagicAndVersion[0])
@@ -180,6 +192,9 @@ func (m *meta) pageSize() uint32 {
 // validate checks if the meta page is valid.
 func (m *meta) validate() error {
 	if !m.magicValid() {
+		if m.magicByteSwapped() {
+			return errMetaByteSwapped
+		}
 		return errMetaInvalidMagic
 	}
 
@@ -217,6 +232,7 @@ func newMetaTriple(pages [numMetas][]byte) (*metaTriple, error) {
 	}
 
 	var maxTxnid, maxSteadyTxnid txnid
+	byteSwapped := false
 
 	for i := 0; i < numMetas; i++ {
 		m, err := readMeta(pages[i])
@@ -225,6 +241,7 @@ func newMetaTriple(pages [numMetas][]byte) (*metaTriple, error) {
 		}
 
 		if err := m.validate(); err != nil {
+			byteSwapped = byteSwapped || err == errMetaByteSwapped
 			continue
 		}
 
@@ -243,6 +260,9 @@ func newMetaTriple(pages [numMetas][]byte) (*metaTriple, error) {
 	}
 
 	if mt.recent < 0 {
+		if byteSwapped {
+			return nil, errMetaByteSwapped
+		}
 		return nil, errMetaNoValid
 	}
 
@@ -318,14 +338,19 @@ func (mt *metaTriple) steadyMeta() *meta {
 }
 
 // nextMetaIndex returns the index to use for the next meta page update.
-// Uses rotating scheme: picks the oldest valid meta.
-func (mt *metaTriple) nextMetaIndex() int {
+// Uses rotating scheme: picks the oldest valid meta. A weak meta never takes
+// the slot of the newest steady one, which Open falls back to if the system
+// restarts before the weak commits are synced.
+func (mt *metaTriple) nextMetaIndex(weak bool) int {
 	// Find meta with lowest txnid (oldest)
-	minIdx := 0
-	minTxnid := mt.txnids[0]
+	minIdx := -1
+	var minTxnid txnid
 
-	for i := 1; i < numMetas; i++ {
-		if mt.txnids[i] < minTxnid {
+	for i := 0; i < numMetas; i++ {
+		if weak && i == mt.steady {
+			continue
+		}
+		if minIdx < 0 || mt.txnids[i] < minTxnid {
 			minTxnid = mt.txnids[i]
 			minIdx = i
 		}
@@ -334,13 +359,36 @@ func (mt *metaTriple) nextMetaIndex() int {
 	return minIdx
 }
 
+// bootID returns the ID of the current system boot, or zeros where it is
+// unknown. Commits record it in their meta, so that Open can tell whether
+// the system has restarted since a weak meta was written.
+var bootID = sync.OnceValue(func() [16]byte {
+	var id [16]byte
+	b, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return id
+	}
+	b = bytes.ReplaceAll(bytes.TrimSpace(b), []byte("-"), nil)
+	if len(b) != 2*len(id) {
+		return id
+	}
+	if _, err := hex.Decode(id[:], b); err != nil {
+		return [16]byte{}
+	}
+	return id
+})
+
 // Meta page errors
 var (
 	errMetaTooSmall       = &pageError{"meta page too small"}
 	errMetaInvalidMagic   = &pageError{"invalid magic number"}
+	errMetaByteSwapped    = &pageError{foreignByteOrder}
 	errMetaInvalidVersion = &pageError{"invalid format version"}
 	errMetaInconsistent   = &pageError{"meta page inconsistent (incomplete write)"}
 	errMetaNoValid        = &pageError{"no valid meta page found"}
+	errDataNotGdbx        = &pageError{"data file is not a gdbx database"}
+	errDataTruncated      = &pageError{"data file truncated below meta pages"}
+	errDataNoMetas        = &pageError{"data file holds pages but its meta pages are zeroed"}
 )
 
 // beginMetaUpdate starts a two-phase meta update by setting txnid_b to 0.
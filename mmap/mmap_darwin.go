@@ -2,9 +2,22 @@
 
 package mmap
 
-import "errors"
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
 
 // tryMremap is not available on macOS, always returns error to trigger fallback.
 func (m *Map) tryMremap(newSize int) ([]byte, error) {
 	return nil, errors.New("mremap not available on darwin")
 }
+
+// PhysicalMemory returns the total physical memory in bytes, or 0 if unknown.
+func PhysicalMemory() uint64 {
+	mem, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0
+	}
+	return mem
+}
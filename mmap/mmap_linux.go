@@ -36,3 +36,12 @@ func (m *Map) tryMremap(newSize int) ([]byte, error) {
 
 	return newData, nil
 }
+
+// PhysicalMemory returns the total physical memory in bytes, or 0 if unknown.
+func PhysicalMemory() uint64 {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0
+	}
+	return uint64(info.Totalram) * uint64(info.Unit)
+}
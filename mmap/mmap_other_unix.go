@@ -8,3 +8,8 @@ import "errors"
 func (m *Map) tryMremap(newSize int) ([]byte, error) {
 	return nil, errors.New("mremap not available on this platform")
 }
+
+// PhysicalMemory returns 0: the total physical memory is not queried on this platform.
+func PhysicalMemory() uint64 {
+	return 0
+}
@@ -306,4 +306,7 @@ func TestAdvise(t *testing.T) {
 	if err := m.AdviseDontNeed(); err != nil {
 		t.Errorf("AdviseDontNeed failed: %v", err)
 	}
+	if err := m.AdviseNormal(); err != nil {
+		t.Errorf("AdviseNormal failed: %v", err)
+	}
 }
@@ -182,6 +182,11 @@ func (m *Map) Advise(advice int) error {
 	return unix.Madvise(m.data, advice)
 }
 
+// AdviseNormal restores the default access pattern, with readahead.
+func (m *Map) AdviseNormal() error {
+	return m.Advise(unix.MADV_NORMAL)
+}
+
 // AdviseSequential hints that pages will be accessed sequentially.
 func (m *Map) AdviseSequential() error {
 	return m.Advise(unix.MADV_SEQUENTIAL)
@@ -242,6 +242,11 @@ func (m *Map) Advise(advice int) error {
 	return nil
 }
 
+// AdviseNormal restores the default access pattern, with readahead.
+func (m *Map) AdviseNormal() error {
+	return m.Advise(0)
+}
+
 // AdviseSequential hints that pages will be accessed sequentially.
 func (m *Map) AdviseSequential() error {
 	return m.Advise(0)
@@ -266,3 +271,8 @@ func (m *Map) AdviseDontNeed() error {
 func (m *Map) tryMremap(newSize int) ([]byte, error) {
 	return nil, &Error{Op: "mremap not available on windows"}
 }
+
+// PhysicalMemory returns 0: the total physical memory is not queried on Windows.
+func PhysicalMemory() uint64 {
+	return 0
+}
@@ -0,0 +1,338 @@
+package gdbx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// opCode identifies a logged operation.
+type opCode byte
+
+const (
+	opBegin opCode = iota + 1
+	opCommit
+	opAbort
+	opOpenDBI
+	opPut
+	opDel
+	opDrop
+	opSequence
+	opCursorOpen
+	opCursorClose
+	opCursorGet
+	opCursorPut
+	opCursorDel
+	opPutWithCap
+	opCursorSetBounds
+	opDelDupRange
+	opDBISpec
+	opSetAppendGuard
+)
+
+// Key order policies of a DBISpec, as opDBISpec records them.
+const (
+	specBigEndianKeys uint64 = 1 << iota
+	specNativeIntegers
+)
+
+var opNames = [...]string{
+	opBegin:           "Begin",
+	opCommit:          "Commit",
+	opAbort:           "Abort",
+	opOpenDBI:         "OpenDBI",
+	opPut:             "Put",
+	opDel:             "Del",
+	opDrop:            "Drop",
+	opSequence:        "Sequence",
+	opCursorOpen:      "CursorOpen",
+	opCursorClose:     "CursorClose",
+	opCursorGet:       "CursorGet",
+	opCursorPut:       "CursorPut",
+	opCursorDel:       "CursorDel",
+	opPutWithCap:      "PutWithCap",
+	opCursorSetBounds: "CursorSetBounds",
+	opDelDupRange:     "DelDupRange",
+	opDBISpec:         "DBISpec",
+	opSetAppendGuard:  "SetAppendGuard",
+}
+
+func (op opCode) String() string {
+	if int(op) < len(opNames) && opNames[op] != "" {
+		return opNames[op]
+	}
+	return fmt.Sprintf("op(%d)", byte(op))
+}
+
+// opLog is the in-memory operation log of an environment. Each record is an
+// opcode byte, the arguments (uvarint integers, byte slices as uvarint(len+1)
+// followed by the bytes, 0 meaning nil), then the result code as a varint.
+type opLog struct {
+	mu         sync.Mutex
+	buf        []byte
+	nextCursor uint64 // Last cursor ID handed out
+}
+
+// start begins a record; it must be followed by argument appends and end.
+func (l *opLog) start(op opCode) *opLog {
+	l.mu.Lock()
+	l.buf = append(l.buf, byte(op))
+	return l
+}
+
+// uint appends an unsigned integer argument.
+func (l *opLog) uint(v uint64) *opLog {
+	l.buf = binary.AppendUvarint(l.buf, v)
+	return l
+}
+
+// bytes appends a byte slice argument, keeping nil distinct from empty.
+func (l *opLog) bytes(b []byte) *opLog {
+	if b == nil {
+		return l.uint(0)
+	}
+	l.uint(uint64(len(b)) + 1)
+	l.buf = append(l.buf, b...)
+	return l
+}
+
+// end finishes a record with the operation's result code.
+func (l *opLog) end(err error) {
+	l.buf = binary.AppendVarint(l.buf, int64(Code(err)))
+	l.mu.Unlock()
+}
+
+// cursorID hands out the ID under which a logged cursor is recorded.
+func (l *opLog) cursorID() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nextCursor++
+	return l.nextCursor
+}
+
+// EnableOpLog starts recording write-transaction operations into a fresh
+// in-memory log, discarding any previous one. Enable it before starting
+// transactions; transactions already running are not recorded. Read
+// transactions are never recorded: with a single writer the log alone
+// determines the database contents.
+// Custom comparators are not recorded, so replay uses the default ones.
+func (e *Env) EnableOpLog() {
+	e.opLog = &opLog{}
+}
+
+// WriteOpLog writes the operations recorded since EnableOpLog to w.
+func (e *Env) WriteOpLog(w io.Writer) error {
+	l := e.opLog
+	if l == nil {
+		return NewError(ErrInvalid)
+	}
+	l.mu.Lock()
+	data := append([]byte(nil), l.buf...)
+	l.mu.Unlock()
+
+	if _, err := w.Write(data); err != nil {
+		return WrapError(ErrProblem, err)
+	}
+	return nil
+}
+
+// errOpLogTruncated is returned when a log ends in the middle of a record.
+var errOpLogTruncated = errors.New("oplog: truncated record")
+
+// opReader decodes records written by opLog.
+type opReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *opReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data[r.off:])
+	if n <= 0 {
+		r.err = errOpLogTruncated
+		return 0
+	}
+	r.off += n
+	return v
+}
+
+func (r *opReader) bytes() []byte {
+	n := r.uint()
+	if n == 0 || r.err != nil {
+		return nil
+	}
+	n--
+	if uint64(len(r.data)-r.off) < n {
+		r.err = errOpLogTruncated
+		return nil
+	}
+	b := r.data[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b
+}
+
+func (r *opReader) code() ErrorCode {
+	if r.err != nil {
+		return Success
+	}
+	v, n := binary.Varint(r.data[r.off:])
+	if n <= 0 {
+		r.err = errOpLogTruncated
+		return Success
+	}
+	r.off += n
+	return ErrorCode(v)
+}
+
+// ReplayOpLog applies a log written by WriteOpLog to this environment, which
+// should be freshly created with the same named-database limit. It returns an
+// error naming the first operation whose result code differs from the
+// recorded one. A transaction left open at the end of the log is aborted.
+func (e *Env) ReplayOpLog(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return WrapError(ErrProblem, err)
+	}
+
+	var txn *Txn
+	dbis := map[uint64]DBI{uint64(MainDBI): MainDBI}
+	cursors := make(map[uint64]*Cursor)
+	defer func() {
+		if txn != nil {
+			txn.Abort()
+		}
+	}()
+
+	rd := &opReader{data: data}
+	for n := 0; rd.off < len(data); n++ {
+		op := opCode(data[rd.off])
+		rd.off++
+
+		// A Begin inside a transaction would wait on the writer lock forever
+		switch {
+		case op == opBegin && txn != nil:
+			return WrapError(ErrInvalid, fmt.Errorf("oplog: Begin at offset %d inside a transaction", rd.off-1))
+		case (op == opCommit || op == opAbort) && txn == nil:
+			return WrapError(ErrInvalid, fmt.Errorf("oplog: %s at offset %d outside a transaction", op, rd.off-1))
+		}
+
+		var got error
+		switch op {
+		case opBegin:
+			flags := uint(rd.uint())
+			if rd.err == nil {
+				txn, got = e.BeginTxn(nil, flags)
+			}
+		case opCommit:
+			_, got = txn.Commit()
+			txn = nil
+			clear(cursors)
+		case opAbort:
+			txn.Abort()
+			txn = nil
+			clear(cursors)
+		case opOpenDBI:
+			name, flags, recorded := rd.bytes(), uint(rd.uint()), rd.uint()
+			if rd.err == nil {
+				var dbi DBI
+				if dbi, got = txn.OpenDBI(string(name), flags, nil, nil); got == nil {
+					dbis[recorded] = dbi
+				}
+			}
+		case opDBISpec:
+			dbi, maxValue, order := rd.uint(), int(rd.uint()), rd.uint()
+			if rd.err == nil {
+				txn.applyDBISpec(dbis[dbi], DBISpec{
+					MaxValue:       maxValue,
+					BigEndianKeys:  order&specBigEndianKeys != 0,
+					NativeIntegers: order&specNativeIntegers != 0,
+				})
+			}
+		case opSetAppendGuard:
+			dbi := rd.uint()
+			if rd.err == nil {
+				got = txn.SetAppendGuard(dbis[dbi])
+			}
+		case opPut:
+			dbi, flags, key, value := rd.uint(), uint(rd.uint()), rd.bytes(), rd.bytes()
+			if rd.err == nil {
+				got = txn.Put(dbis[dbi], key, value, flags)
+			}
+		case opPutWithCap:
+			dbi, flags, capacity, key, value := rd.uint(), uint(rd.uint()), int(rd.uint()), rd.bytes(), rd.bytes()
+			if rd.err == nil {
+				got = txn.PutWithCap(dbis[dbi], key, value, capacity, flags)
+			}
+		case opDel:
+			dbi, key, value := rd.uint(), rd.bytes(), rd.bytes()
+			if rd.err == nil {
+				got = txn.Del(dbis[dbi], key, value)
+			}
+		case opDelDupRange:
+			dbi, key, vlow, vhigh := rd.uint(), rd.bytes(), rd.bytes(), rd.bytes()
+			if rd.err == nil {
+				_, got = txn.DelDupRange(dbis[dbi], key, vlow, vhigh)
+			}
+		case opDrop:
+			dbi, del := rd.uint(), rd.uint() != 0
+			if rd.err == nil {
+				got = txn.Drop(dbis[dbi], del)
+			}
+		case opSequence:
+			dbi, increment := rd.uint(), rd.uint()
+			if rd.err == nil {
+				_, got = txn.Sequence(dbis[dbi], increment)
+			}
+		case opCursorOpen:
+			dbi, id := rd.uint(), rd.uint()
+			if rd.err == nil {
+				var c *Cursor
+				if c, got = txn.OpenCursor(dbis[dbi]); got == nil {
+					cursors[id] = c
+				}
+			}
+		case opCursorClose:
+			id := rd.uint()
+			if rd.err == nil {
+				cursors[id].Close()
+				delete(cursors, id)
+			}
+		case opCursorSetBounds:
+			id, low, high := rd.uint(), rd.bytes(), rd.bytes()
+			if rd.err == nil {
+				cursors[id].SetBounds(low, high)
+			}
+		case opCursorGet:
+			id, cop, key, value := rd.uint(), CursorOp(rd.uint()), rd.bytes(), rd.bytes()
+			if rd.err == nil {
+				_, _, got = cursors[id].Get(key, value, cop)
+			}
+		case opCursorPut:
+			id, flags, key, value := rd.uint(), uint(rd.uint()), rd.bytes(), rd.bytes()
+			if rd.err == nil {
+				got = cursors[id].Put(key, value, flags)
+			}
+		case opCursorDel:
+			id, flags := rd.uint(), uint(rd.uint())
+			if rd.err == nil {
+				got = cursors[id].Del(flags)
+			}
+		default:
+			return WrapError(ErrInvalid, fmt.Errorf("oplog: unknown opcode %d at offset %d", byte(op), rd.off-1))
+		}
+
+		want := rd.code()
+		if rd.err != nil {
+			return WrapError(ErrInvalid, rd.err)
+		}
+		if Code(got) != want {
+			return WrapError(ErrProblem, fmt.Errorf("oplog: operation %d (%s) returned %v, recorded code %d", n, op, got, want))
+		}
+	}
+	return nil
+}
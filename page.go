@@ -292,6 +292,18 @@ func pageNumEntriesDirect(data []byte) int {
 	return int(lower) >> 1
 }
 
+// pageDupfixSizeDirect returns the key size of a DUPFIX page from raw page data.
+func pageDupfixSizeDirect(data []byte) int {
+	return int(uint16(data[8]) | uint16(data[9])<<8)
+}
+
+// pageDupfixKeyDirect returns the key at index from raw DUPFIX page data.
+func pageDupfixKeyDirect(data []byte, idx int) []byte {
+	ksize := int(uint16(data[8]) | uint16(data[9])<<8)
+	start := pageHeaderSize + idx*ksize
+	return data[start : start+ksize : start+ksize]
+}
+
 // pageEntryOffsetDirect returns the entry offset at index from raw page data.
 // libmdbx: return ptr_disp(mp, mp->entries[i] + PAGEHDRSZ)
 func pageEntryOffsetDirect(data []byte, idx int) uint16 {
@@ -446,6 +458,69 @@ func (p *page) removeEntriesFrom(startIdx int) {
 	h.Lower -= uint16(entriesToRemove * 2)
 }
 
+// ============== DUPFIX leaf pages ==============
+//
+// The leaves of a DUPFIXED sub-tree are DUPFIX pages: no entry pointers and
+// no node headers, just keys of DupfixKsize bytes stored in order right after
+// the page header. As in libmdbx, lower and upper still move as if each key
+// had a 2-byte entry pointer, so numEntries and freeSpace work unchanged.
+
+// initDupfix initializes an empty DUPFIX leaf page for keys of ksize bytes.
+func (p *page) initDupfix(pno pgno, ksize int, pageSize uint16) {
+	p.init(pno, pageLeaf|pageDupfix, pageSize)
+	p.header().DupfixKsize = uint16(ksize)
+}
+
+// dupfixKey returns the key at idx of a DUPFIX page.
+func (p *page) dupfixKey(idx int) []byte {
+	return pageDupfixKeyDirect(p.Data, idx)
+}
+
+// insertDupfix inserts key at idx of a DUPFIX page.
+// Returns false if the page is full.
+func (p *page) insertDupfix(idx int, key []byte) bool {
+	h := p.header()
+	ksize := int(h.DupfixKsize)
+	numEntries := p.numEntries()
+	if idx < 0 || idx > numEntries || len(key) != ksize || p.freeSpace() < ksize {
+		return false
+	}
+
+	start := pageHeaderSize + idx*ksize
+	end := pageHeaderSize + numEntries*ksize
+	copy(p.Data[start+ksize:end+ksize], p.Data[start:end])
+	copy(p.Data[start:], key)
+	h.Lower += 2
+	h.Upper -= uint16(ksize - 2)
+	return true
+}
+
+// removeDupfix removes the key at idx of a DUPFIX page.
+func (p *page) removeDupfix(idx int) bool {
+	h := p.header()
+	ksize := int(h.DupfixKsize)
+	numEntries := p.numEntries()
+	if idx < 0 || idx >= numEntries {
+		return false
+	}
+
+	start := pageHeaderSize + idx*ksize
+	end := pageHeaderSize + numEntries*ksize
+	copy(p.Data[start:], p.Data[start+ksize:end])
+	h.Lower -= 2
+	h.Upper += uint16(ksize - 2)
+	return true
+}
+
+// subTreeKey returns the key at idx of a DUPSORT sub-tree leaf, which is a
+// DUPFIX page for DUPFIXED tables and a page of nodes otherwise.
+func subTreeKey(p *page, idx int) []byte {
+	if pageFlagsDirect(p.Data)&pageDupfix != 0 {
+		return p.dupfixKey(idx)
+	}
+	return nodeGetKeyFast(p, idx)
+}
+
 // compact eliminates holes in the data area by repacking all node data.
 // This reclaims space left by removed entries.
 // Returns the amount of space reclaimed.
@@ -516,21 +591,22 @@ func (p *page) compactWithBuf(scratchBuf []byte) int {
 	tempPos := uint16(0)
 	for i := 0; i < numEntries; i++ {
 		srcOffset := p.entryOffsetFast(i)
-		copy(tempBuf[tempPos:tempPos+sizes[i]], p.Data[srcOffset:srcOffset+sizes[i]])
+		copy(tempBuf[tempPos:tempPos+sizes[i]], p.Data[srcOffset:int(srcOffset)+int(sizes[i])])
 		tempPos += sizes[i]
 	}
 
-	// Write back contiguously from end of page, update pointers
-	writePos := pageSize
+	// Write back contiguously from end of page, update pointers. Positions
+	// are ints: the end of a 64KB page doesn't fit in a uint16.
+	writePos := len(p.Data)
 	tempPos = 0
 	for i := 0; i < numEntries; i++ {
-		writePos -= sizes[i]
-		copy(p.Data[writePos:writePos+sizes[i]], tempBuf[tempPos:tempPos+sizes[i]])
+		writePos -= int(sizes[i])
+		copy(p.Data[writePos:writePos+int(sizes[i])], tempBuf[tempPos:tempPos+sizes[i]])
 		tempPos += sizes[i]
 
 		// Update entry pointer (stored offset is relative to pageHeaderSize)
 		entryPtrOffset := pageHeaderSize + i*2
-		putUint16LE(p.Data[entryPtrOffset:], writePos-pageHeaderSize)
+		putUint16LE(p.Data[entryPtrOffset:], uint16(writePos-pageHeaderSize))
 	}
 
 	// Return buffer if we used the pool (no defer to avoid allocation)
@@ -540,7 +616,7 @@ func (p *page) compactWithBuf(scratchBuf []byte) int {
 
 	// Update upper to new position
 	oldUpper := h.Upper
-	h.Upper = writePos - pageHeaderSize
+	h.Upper = uint16(writePos - pageHeaderSize)
 
 	return int(h.Upper - oldUpper)
 }
@@ -620,6 +696,60 @@ func (p *page) updateEntry(idx int, nodeData []byte) bool {
 	return true
 }
 
+// holes returns the bytes of the data area that no node occupies: the space
+// of nodes removed, or moved away by updateEntry, which compact reclaims.
+func (p *page) holes() int {
+	used := 0
+	for i := 0; i < p.numEntriesFast(); i++ {
+		used += p.calcNodeSizeFast(i)
+	}
+	return len(p.Data) - pageHeaderSize - int(p.header().Upper) - used
+}
+
+// pageCopyMinGap is the smallest free gap copyPage leaves out; a smaller one
+// is cheaper to copy along than to split the copy around.
+const pageCopyMinGap = 256
+
+// usedSpan returns where the used bytes of a branch or leaf page stop before
+// its free gap and start again after it: the header and the entry pointers
+// (the keys, on a DUPFIX page) lie below lo, the nodes from hi on. Large and
+// meta pages, and pages whose bounds don't add up, are used throughout, with
+// lo == hi == len(p.Data).
+func (p *page) usedSpan() (lo, hi int) {
+	n := len(p.Data)
+	if n < pageHeaderSize {
+		return n, n
+	}
+	h := p.header()
+	if h.Flags&(pageBranch|pageLeaf) == 0 || h.Flags&(pageLarge|pageMeta|pageSubP) != 0 {
+		return n, n
+	}
+	lo, hi = pageHeaderSize+int(h.Lower), pageHeaderSize+int(h.Upper)
+	if h.Flags&pageDupfix != 0 {
+		lo, hi = pageHeaderSize+p.numEntries()*int(h.DupfixKsize), n
+	}
+	if lo > hi || hi > n {
+		return n, n
+	}
+	return lo, hi
+}
+
+// copyPage copies page data src into dst, of the same size, leaving out the
+// free gap of a branch or leaf page as libmdbx's page_copy does. The gap's
+// bytes in dst keep whatever they held, as nothing reads them, so with a
+// large page a copy made into the map dirties only the system pages under
+// the used bytes.
+func copyPage(dst, src []byte) {
+	p := page{Data: src}
+	lo, hi := p.usedSpan()
+	if hi-lo < pageCopyMinGap {
+		copy(dst, src)
+		return
+	}
+	copy(dst[:lo], src[:lo])
+	copy(dst[hi:], src[hi:])
+}
+
 // calcNodeSize calculates the size of the node at the given index.
 func (p *page) calcNodeSize(idx int) int {
 	numEntries := p.numEntriesFast()
@@ -660,22 +790,28 @@ func (p *page) calcNodeSizeFast(idx int) int {
 // splitPoint finds the optimal split point for this page.
 // Returns the index at which to split (entries 0..idx-1 go to left, idx.. go to right).
 // Takes newNodeSize and insertIdx to ensure both resulting pages will have enough space.
-// Uses O(n) single-pass algorithm without heap allocation.
 func (p *page) splitPoint(newNodeSize int, insertIdx int) int {
-	numEntries := p.numEntriesFast()
+	return splitIndex(p.numEntriesFast(), p.calcNodeSizeFast, len(p.Data), newNodeSize, insertIdx)
+}
+
+// splitIndex chooses where to split a page of numEntries nodes, of the sizes
+// nodeSize reports, to insert a node of newNodeSize at insertIdx. It is the
+// choice splitPoint makes, over sizes alone so that EstimateSize can make it
+// for pages that exist only in its model.
+// Uses O(n) single-pass algorithm without heap allocation.
+func splitIndex(numEntries int, nodeSize func(int) int, pageSize, newNodeSize, insertIdx int) int {
 	if numEntries == 0 {
 		return 0
 	}
 
 	// Calculate the total available space per page
 	// For N entries: needs N*2 bytes for pointers + sum(nodeSizes) for data
-	pageSize := len(p.Data)
 	maxSpace := pageSize - pageHeaderSize
 
 	// Calculate total size of all existing entries in one pass
 	totalExisting := 0
 	for i := 0; i < numEntries; i++ {
-		totalExisting += p.calcNodeSizeFast(i)
+		totalExisting += nodeSize(i)
 	}
 
 	// OPTIMIZATION: Append-optimized split
@@ -704,7 +840,7 @@ func (p *page) splitPoint(newNodeSize int, insertIdx int) int {
 		// Calculate left side data size: entries [0, splitIdx)
 		leftDataSize := 0
 		for i := 0; i < splitIdx; i++ {
-			leftDataSize += p.calcNodeSizeFast(i)
+			leftDataSize += nodeSize(i)
 		}
 
 		// Right side data size is totalExisting - leftDataSize
@@ -799,7 +935,7 @@ func (p *page) compactTo(dst *page, pageSize uint16) {
 		offset := p.entryOffset(i)
 		nodeSize := p.calcNodeSize(i)
 		if nodeSize > 0 && int(offset)+nodeSize <= len(p.Data) {
-			dst.insertEntry(i, p.Data[offset:offset+uint16(nodeSize)])
+			dst.insertEntry(i, p.Data[offset:int(offset)+nodeSize])
 		}
 	}
 }
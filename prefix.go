@@ -0,0 +1,134 @@
+package gdbx
+
+// PrefixDBI is the table of keys starting with a prefix in a database,
+// opened with Txn.OpenPrefix, so that many tables can share one database. Its
+// methods take and return keys without the prefix, and its cursors are
+// bounded to the prefix's range. It is valid for the transaction that opened
+// it.
+type PrefixDBI struct {
+	txn    *Txn
+	dbi    DBI
+	prefix []byte
+	high   []byte // First key past the prefix's range, nil if there is none
+	keyBuf []byte // Prefixed key of the current call
+}
+
+// PrefixCursor is a cursor over a PrefixDBI.
+type PrefixCursor struct {
+	c      *Cursor
+	p      *PrefixDBI
+	keyBuf []byte
+}
+
+// OpenPrefix returns the table of the keys of dbi that start with prefix.
+// The database must order keys bytewise: it fails with ErrIncompatible for
+// ReverseKey and IntegerKey databases and those with a custom comparator.
+func (txn *Txn) OpenPrefix(dbi DBI, prefix []byte) (*PrefixDBI, error) {
+	if !txn.valid() {
+		return nil, NewError(ErrBadTxn)
+	}
+	if dbi == FreeDBI || int(dbi) >= len(txn.trees) {
+		return nil, NewError(ErrBadDBI)
+	}
+	txn.cacheComparator(dbi)
+	if !txn.dbiUsesDefaultCmp[dbi] || txn.trees[dbi].Flags&uint16(ReverseKey|IntegerKey) != 0 {
+		return nil, NewError(ErrIncompatible)
+	}
+	p := &PrefixDBI{txn: txn, dbi: dbi, prefix: append([]byte{}, prefix...)}
+	p.high = prefixEnd(p.prefix)
+	return p, nil
+}
+
+// prefixEnd returns the smallest key above every key starting with prefix,
+// or nil if there is none (prefix is empty or all 0xff).
+func prefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := append([]byte{}, prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
+
+// Prefix returns the table's prefix.
+func (p *PrefixDBI) Prefix() []byte {
+	return p.prefix
+}
+
+// DBI returns the database the table lives in.
+func (p *PrefixDBI) DBI() DBI {
+	return p.dbi
+}
+
+// key returns key with the prefix prepended, in buf.
+func (p *PrefixDBI) key(buf *[]byte, key []byte) []byte {
+	*buf = append(append((*buf)[:0], p.prefix...), key...)
+	return *buf
+}
+
+// Get retrieves the value of key, as Txn.Get.
+func (p *PrefixDBI) Get(key []byte) ([]byte, error) {
+	return p.txn.Get(p.dbi, p.key(&p.keyBuf, key))
+}
+
+// Put stores a key-value pair, as Txn.Put.
+func (p *PrefixDBI) Put(key, value []byte, flags uint) error {
+	return p.txn.Put(p.dbi, p.key(&p.keyBuf, key), value, flags)
+}
+
+// Del deletes key, or one of its values, as Txn.Del.
+func (p *PrefixDBI) Del(key, value []byte) error {
+	return p.txn.Del(p.dbi, p.key(&p.keyBuf, key), value)
+}
+
+// OpenCursor opens a cursor over the table.
+func (p *PrefixDBI) OpenCursor() (*PrefixCursor, error) {
+	c, err := p.txn.OpenCursor(p.dbi)
+	if err != nil {
+		return nil, err
+	}
+	c.SetBounds(p.prefix, p.high)
+	return &PrefixCursor{c: c, p: p}, nil
+}
+
+// Get runs a cursor operation as Cursor.Get, within the table. key is given
+// and returned without the prefix.
+func (pc *PrefixCursor) Get(key, value []byte, op CursorOp) ([]byte, []byte, error) {
+	switch op {
+	case Set, SetKey, SetRange, GetBoth, GetBothRange, SetLowerbound, SetUpperbound:
+		key = pc.p.key(&pc.keyBuf, key)
+	}
+	k, v, err := pc.c.Get(key, value, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k[len(pc.p.prefix):], v, nil
+}
+
+// Put stores a key-value pair as Cursor.Put, with key given without the
+// prefix.
+func (pc *PrefixCursor) Put(key, value []byte, flags uint) error {
+	return pc.c.Put(pc.p.key(&pc.keyBuf, key), value, flags)
+}
+
+// Del deletes the entry at the cursor, as Cursor.Del.
+func (pc *PrefixCursor) Del(flags uint) error {
+	return pc.c.Del(flags)
+}
+
+// Count returns the number of values of the current key, as Cursor.Count.
+func (pc *PrefixCursor) Count() (uint64, error) {
+	return pc.c.Count()
+}
+
+// Cursor returns the underlying cursor, bounded to the table's key range.
+func (pc *PrefixCursor) Cursor() *Cursor {
+	return pc.c
+}
+
+// Close closes the cursor.
+func (pc *PrefixCursor) Close() {
+	pc.c.Close()
+}
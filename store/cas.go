@@ -0,0 +1,208 @@
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// HashSize is the size of the hashes of a content-addressed store.
+const HashSize = sha256.Size
+
+// Suffixes of the names of a content-addressed store's blob and reference
+// tables.
+const (
+	casBlobs = ".blobs"
+	casRefs  = ".refs"
+)
+
+// errCAS is returned by content-addressed operations on a store not opened
+// with OpenCAS.
+var errCAS = errors.New("store: not a content-addressed store")
+
+// errCASCorrupt is returned when a key's hash has no blob.
+var errCASCorrupt = errors.New("store: missing content-addressed blob")
+
+// casTables are the blob and reference tables of a content-addressed store.
+type casTables struct {
+	blobs gdbx.DBI
+	refs  gdbx.DBI
+}
+
+// OpenCAS returns a Store for the named table that keeps values content
+// addressed (see PutCAS, GetCAS and DeleteCAS): the table maps each key to
+// the SHA-256 hash of its value, and each distinct value, the blob, is kept
+// once under its hash in the table name+".blobs". The DupSort table
+// name+".refs" maps each hash to the keys referring to it, and a blob is
+// deleted with its last reference. The tables are created unless env is
+// read-only. Keys are the duplicate values of the reference table, so they
+// are limited to env.MaxKeySize() bytes. Put and Delete bypass the blobs
+// and references, and Get returns a key's hash.
+func OpenCAS[K, V any](env *gdbx.Env, name string, key Codec[K], val Codec[V]) (*Store[K, V], error) {
+	s, err := Open(env, name, 0, key, val)
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := Open(env, name+casBlobs, 0, Bytes(), val)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := Open(env, name+casRefs, gdbx.DupSort, Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	s.cas = &casTables{blobs: blobs.dbi, refs: refs.dbi}
+	return s, nil
+}
+
+// PutCAS stores v under k in a transaction of its own and returns its hash.
+func (s *Store[K, V]) PutCAS(k K, v V) (hash [HashSize]byte, err error) {
+	err = s.Update(func(tx *Tx[K, V]) error {
+		hash, err = tx.PutCAS(k, v)
+		return err
+	})
+	return hash, err
+}
+
+// GetCAS returns the value stored for k, or found == false.
+func (s *Store[K, V]) GetCAS(k K) (v V, found bool, err error) {
+	err = s.View(func(tx *Tx[K, V]) error {
+		v, found, err = tx.GetCAS(k)
+		return err
+	})
+	return v, found, err
+}
+
+// DeleteCAS removes k in a transaction of its own.
+func (s *Store[K, V]) DeleteCAS(k K) error {
+	return s.Update(func(tx *Tx[K, V]) error { return tx.DeleteCAS(k) })
+}
+
+// PutCAS stores v under k, replacing its previous value, and returns the
+// hash of v. The blob of v is stored unless another key refers to it
+// already, and that of the previous value deleted if k was its last
+// reference.
+func (tx *Tx[K, V]) PutCAS(k K, v V) (hash [HashSize]byte, err error) {
+	cas := tx.s.cas
+	if cas == nil {
+		return hash, errCAS
+	}
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return hash, err
+	}
+	vb, err := tx.s.val.Encode(v)
+	if err != nil {
+		return hash, err
+	}
+	hash = sha256.Sum256(vb)
+
+	old, err := tx.current(kb)
+	if err != nil {
+		return hash, err
+	}
+	if string(old) == string(hash[:]) {
+		return hash, nil
+	}
+	if old != nil {
+		if err := tx.unref(old, kb); err != nil {
+			return hash, err
+		}
+	}
+
+	// The blob exists if anything refers to it
+	if _, err := tx.txn.Get(cas.refs, hash[:]); gdbx.IsNotFound(err) {
+		if err := tx.txn.Put(cas.blobs, hash[:], vb, 0); err != nil {
+			return hash, err
+		}
+	} else if err != nil {
+		return hash, err
+	}
+	if err := tx.txn.Put(cas.refs, hash[:], kb, 0); err != nil {
+		return hash, err
+	}
+	return hash, tx.txn.Put(tx.s.dbi, kb, hash[:], 0)
+}
+
+// GetCAS returns the value stored for k, or found == false.
+func (tx *Tx[K, V]) GetCAS(k K) (v V, found bool, err error) {
+	cas := tx.s.cas
+	if cas == nil {
+		return v, false, errCAS
+	}
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return v, false, err
+	}
+	hash, err := tx.txn.Get(tx.s.dbi, kb)
+	if gdbx.IsNotFound(err) {
+		return v, false, nil
+	}
+	if err != nil {
+		return v, false, err
+	}
+	data, err := tx.txn.Get(cas.blobs, hash)
+	if gdbx.IsNotFound(err) {
+		return v, false, errCASCorrupt
+	}
+	if err != nil {
+		return v, false, err
+	}
+	v, err = tx.s.val.Decode(data)
+	return v, err == nil, err
+}
+
+// References returns the number of keys whose value has the given hash.
+func (tx *Tx[K, V]) References(hash [HashSize]byte) (uint64, error) {
+	cas := tx.s.cas
+	if cas == nil {
+		return 0, errCAS
+	}
+	c, err := tx.txn.OpenCursor(cas.refs)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	_, n, err := c.SetWithCount(hash[:])
+	if gdbx.IsNotFound(err) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// DeleteCAS removes k, and the blob of its value if k was its last
+// reference. Deleting a missing key is not an error.
+func (tx *Tx[K, V]) DeleteCAS(k K) error {
+	if tx.s.cas == nil {
+		return errCAS
+	}
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	old, err := tx.current(kb)
+	if err != nil || old == nil {
+		return err
+	}
+	if err := tx.txn.Del(tx.s.dbi, kb, nil); err != nil {
+		return err
+	}
+	return tx.unref(old, kb)
+}
+
+// unref removes kb from the references of hash, deleting the blob when
+// none remain.
+func (tx *Tx[K, V]) unref(hash, kb []byte) error {
+	cas := tx.s.cas
+	if err := tx.txn.Del(cas.refs, hash, kb); err != nil && !gdbx.IsNotFound(err) {
+		return err
+	}
+	if _, err := tx.txn.Get(cas.refs, hash); !gdbx.IsNotFound(err) {
+		return err
+	}
+	if err := tx.txn.Del(cas.blobs, hash, nil); err != nil && !gdbx.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
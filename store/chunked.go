@@ -0,0 +1,135 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// chunkHeader is the size of the sequence number prefix of a record, a
+// big-endian uint32. Records 1..n hold the value, the last one padded with
+// zeros.
+const chunkHeader = 4
+
+// chunkMeta is the size of the metadata in record 0: the value length (8
+// bytes) and the chunk size (4 bytes).
+const chunkMeta = 12
+
+// errChunked is returned by chunk operations on a store not opened with OpenChunked.
+var errChunked = errors.New("store: not a chunked store")
+
+// errChunkCorrupt is returned when a key's records don't form a valid chunked value.
+var errChunkCorrupt = errors.New("store: corrupt chunked value")
+
+// OpenChunked returns a Store for the named table that keeps values as
+// ordered chunks of chunkSize bytes (see PutChunked and GetChunked). The
+// table is created with gdbx.DupSort|gdbx.DupFixed unless env is read-only.
+// chunkSize must be at least 12 and leave room for the 4-byte sequence
+// number within env.MaxKeySize(), the limit for duplicate values.
+func OpenChunked[K any](env *gdbx.Env, name string, key Codec[K], chunkSize int) (*Store[K, []byte], error) {
+	if limit := env.MaxKeySize() - chunkHeader; chunkSize < chunkMeta || chunkSize > limit {
+		return nil, fmt.Errorf("store: chunk size %d outside [%d, %d]", chunkSize, chunkMeta, limit)
+	}
+	s, err := Open(env, name, gdbx.DupSort|gdbx.DupFixed, key, Bytes())
+	if err != nil {
+		return nil, err
+	}
+	s.chunkSize = chunkSize
+	return s, nil
+}
+
+// PutChunked stores value under k as chunks in a transaction of its own.
+func (s *Store[K, V]) PutChunked(k K, value []byte) error {
+	return s.Update(func(tx *Tx[K, V]) error { return tx.PutChunked(k, value) })
+}
+
+// GetChunked returns the chunked value stored for k, or found == false.
+func (s *Store[K, V]) GetChunked(k K) (value []byte, found bool, err error) {
+	err = s.View(func(tx *Tx[K, V]) error {
+		value, found, err = tx.GetChunked(k)
+		return err
+	})
+	return value, found, err
+}
+
+// PutChunked stores value under k as a metadata record followed by its
+// chunks, replacing any previous value of k.
+func (tx *Tx[K, V]) PutChunked(k K, value []byte) error {
+	size := tx.s.chunkSize
+	if size == 0 {
+		return errChunked
+	}
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	if err := tx.txn.Del(tx.s.dbi, kb, nil); err != nil && !gdbx.IsNotFound(err) {
+		return err
+	}
+
+	rec := make([]byte, chunkHeader+size)
+	binary.BigEndian.PutUint64(rec[chunkHeader:], uint64(len(value)))
+	binary.BigEndian.PutUint32(rec[chunkHeader+8:], uint32(size))
+	if err := tx.txn.Put(tx.s.dbi, kb, rec, gdbx.AppendDup); err != nil {
+		return err
+	}
+	for seq := uint32(1); len(value) > 0; seq++ {
+		binary.BigEndian.PutUint32(rec, seq)
+		n := copy(rec[chunkHeader:], value)
+		clear(rec[chunkHeader+n:])
+		value = value[n:]
+		if err := tx.txn.Put(tx.s.dbi, kb, rec, gdbx.AppendDup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetChunked returns the chunked value stored for k, or found == false. The
+// chunks are read in order and concatenated into a new slice.
+func (tx *Tx[K, V]) GetChunked(k K) (value []byte, found bool, err error) {
+	if tx.s.chunkSize == 0 {
+		return nil, false, errChunked
+	}
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return nil, false, err
+	}
+	c, err := tx.txn.OpenCursor(tx.s.dbi)
+	if err != nil {
+		return nil, false, err
+	}
+	defer c.Close()
+
+	rec, records, err := c.SetWithCount(kb)
+	if gdbx.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rec) != chunkHeader+tx.s.chunkSize || binary.BigEndian.Uint32(rec) != 0 ||
+		binary.BigEndian.Uint32(rec[chunkHeader+8:]) != uint32(tx.s.chunkSize) {
+		return nil, false, errChunkCorrupt
+	}
+	length := binary.BigEndian.Uint64(rec[chunkHeader:])
+	size := uint64(tx.s.chunkSize)
+	if records != 1+(length+size-1)/size {
+		return nil, false, errChunkCorrupt
+	}
+
+	value = make([]byte, 0, length)
+	for seq := uint32(1); uint64(len(value)) < length; seq++ {
+		if _, rec, err = c.Get(nil, nil, gdbx.NextDup); err != nil {
+			return nil, false, err
+		}
+		if binary.BigEndian.Uint32(rec) != seq {
+			return nil, false, errChunkCorrupt
+		}
+		n := min(size, length-uint64(len(value)))
+		value = append(value, rec[chunkHeader:chunkHeader+n]...)
+	}
+	return value, true, nil
+}
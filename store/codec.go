@@ -0,0 +1,64 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// Codec converts values of type T to and from the bytes stored in gdbx.
+//
+// The order of encoded keys is the order of iteration, so key codecs should
+// encode in a byte-comparable form (see Uint64). Decode receives bytes that
+// are only valid inside the transaction; it must copy them if the decoded
+// value keeps a reference.
+type Codec[T any] struct {
+	Encode func(T) ([]byte, error)
+	Decode func([]byte) (T, error)
+}
+
+// Bytes returns a codec for byte slices. Decoded slices are copies.
+func Bytes() Codec[[]byte] {
+	return Codec[[]byte]{
+		Encode: func(b []byte) ([]byte, error) { return b, nil },
+		Decode: func(b []byte) ([]byte, error) { return append([]byte{}, b...), nil },
+	}
+}
+
+// String returns a codec for strings.
+func String() Codec[string] {
+	return Codec[string]{
+		Encode: func(s string) ([]byte, error) { return []byte(s), nil },
+		Decode: func(b []byte) (string, error) { return string(b), nil },
+	}
+}
+
+// errUint64Size is returned when decoding a Uint64 value that isn't 8 bytes.
+var errUint64Size = errors.New("store: uint64 value is not 8 bytes")
+
+// Uint64 returns a codec for uint64 in big-endian order, so encoded keys
+// sort numerically.
+func Uint64() Codec[uint64] {
+	return Codec[uint64]{
+		Encode: func(v uint64) ([]byte, error) { return binary.BigEndian.AppendUint64(nil, v), nil },
+		Decode: func(b []byte) (uint64, error) {
+			if len(b) != 8 {
+				return 0, errUint64Size
+			}
+			return binary.BigEndian.Uint64(b), nil
+		},
+	}
+}
+
+// JSON returns a codec that stores values as JSON. It suits values, not keys:
+// encoded JSON doesn't sort in the order of the values.
+func JSON[T any]() Codec[T] {
+	return Codec[T]{
+		Encode: func(v T) ([]byte, error) { return json.Marshal(v) },
+		Decode: func(b []byte) (T, error) {
+			var v T
+			err := json.Unmarshal(b, &v)
+			return v, err
+		},
+	}
+}
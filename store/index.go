@@ -0,0 +1,198 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// errIndexDupSort is returned by OpenIndex for a DupSort store, whose keys
+// have several values.
+var errIndexDupSort = errors.New("store: can't index a DupSort store")
+
+// indexer updates one index of a store.
+type indexer interface {
+	// reindex moves primary key kb from the index entry of value old to
+	// that of value new; nil stands for no value.
+	reindex(txn *gdbx.Txn, kb, old, new []byte) error
+}
+
+// Index is a secondary index of a Store[K, V] by keys of type I.
+type Index[K, V, I any] struct {
+	s   *Store[K, V]
+	dbi gdbx.DBI
+	key Codec[I]
+	fn  func(v V) (I, bool)
+}
+
+// OpenIndex opens the named DupSort table as an index of s, creating it
+// unless the environment is read-only. fn derives the index key of a value,
+// and returns false for a value that isn't indexed. The index is kept up to
+// date by PutIndexed and DeleteIndexed, in the transaction that changes the
+// store, from then on; Put and Delete bypass it. OpenIndex must be called
+// before s is used concurrently, and an index table that existed before is
+// trusted to match the store. The keys of s are the duplicate values of the
+// index, so they are limited to env.MaxKeySize() bytes.
+func OpenIndex[K, V, I any](s *Store[K, V], name string, key Codec[I], fn func(v V) (I, bool)) (*Index[K, V, I], error) {
+	var storeFlags uint
+	err := s.env.View(func(txn *gdbx.Txn) (err error) {
+		storeFlags, err = txn.Flags(s.dbi)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if storeFlags&gdbx.DupSort != 0 {
+		return nil, errIndexDupSort
+	}
+
+	t, err := Open(s.env, name, gdbx.DupSort, key, s.key)
+	if err != nil {
+		return nil, err
+	}
+	ix := &Index[K, V, I]{s: s, dbi: t.dbi, key: key, fn: fn}
+	s.indexes = append(s.indexes, ix)
+	return ix, nil
+}
+
+// reindex implements indexer.
+func (ix *Index[K, V, I]) reindex(txn *gdbx.Txn, kb, old, new []byte) error {
+	oldKey, err := ix.indexKey(old)
+	if err != nil {
+		return err
+	}
+	newKey, err := ix.indexKey(new)
+	if err != nil {
+		return err
+	}
+	if oldKey != nil && newKey != nil && bytes.Equal(oldKey, newKey) {
+		return nil
+	}
+	if oldKey != nil {
+		if err := txn.Del(ix.dbi, oldKey, kb); err != nil && !gdbx.IsNotFound(err) {
+			return err
+		}
+	}
+	if newKey != nil {
+		return txn.Put(ix.dbi, newKey, kb, 0)
+	}
+	return nil
+}
+
+// indexKey returns the encoded index key of an encoded value, or nil if
+// there is no value or it isn't indexed.
+func (ix *Index[K, V, I]) indexKey(vb []byte) ([]byte, error) {
+	if vb == nil {
+		return nil, nil
+	}
+	v, err := ix.s.val.Decode(vb)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := ix.fn(v)
+	if !ok {
+		return nil, nil
+	}
+	return ix.key.Encode(i)
+}
+
+// Keys calls fn for each key of the store whose value has index key i, in
+// key order. Returning ErrStop ends the iteration.
+func (ix *Index[K, V, I]) Keys(tx *Tx[K, V], i I, fn func(k K) error) error {
+	ib, err := ix.key.Encode(i)
+	if err != nil {
+		return err
+	}
+	c, err := tx.txn.OpenCursor(ix.dbi)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, kb, err := c.Get(ib, nil, gdbx.Set)
+	for ; err == nil; _, kb, err = c.Get(nil, nil, gdbx.NextDup) {
+		k, err := ix.s.key.Decode(kb)
+		if err != nil {
+			return err
+		}
+		if err := fn(k); err != nil {
+			return stopped(err)
+		}
+	}
+	if gdbx.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// PutIndexed stores v under k and updates the store's indexes, in a
+// transaction of its own.
+func (s *Store[K, V]) PutIndexed(k K, v V) error {
+	return s.Update(func(tx *Tx[K, V]) error { return tx.PutIndexed(k, v) })
+}
+
+// DeleteIndexed removes k and its index entries in a transaction of its own.
+func (s *Store[K, V]) DeleteIndexed(k K) error {
+	return s.Update(func(tx *Tx[K, V]) error { return tx.DeleteIndexed(k) })
+}
+
+// PutIndexed stores v under k, replacing its previous value, and moves k to
+// the index entries of v.
+func (tx *Tx[K, V]) PutIndexed(k K, v V) error {
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	vb, err := tx.s.val.Encode(v)
+	if err != nil {
+		return err
+	}
+	old, err := tx.current(kb)
+	if err != nil {
+		return err
+	}
+	if err := tx.txn.Put(tx.s.dbi, kb, vb, 0); err != nil {
+		return err
+	}
+	return tx.reindex(kb, old, vb)
+}
+
+// DeleteIndexed removes k and its index entries. Deleting a missing key is
+// not an error.
+func (tx *Tx[K, V]) DeleteIndexed(k K) error {
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	old, err := tx.current(kb)
+	if err != nil || old == nil {
+		return err
+	}
+	if err := tx.txn.Del(tx.s.dbi, kb, nil); err != nil {
+		return err
+	}
+	return tx.reindex(kb, old, nil)
+}
+
+// current returns a copy of the value stored under kb, or nil.
+func (tx *Tx[K, V]) current(kb []byte) ([]byte, error) {
+	data, err := tx.txn.Get(tx.s.dbi, kb)
+	if gdbx.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(data), nil
+}
+
+// reindex updates every index of the store for a change of kb's value.
+func (tx *Tx[K, V]) reindex(kb, old, new []byte) error {
+	for _, ix := range tx.s.indexes {
+		if err := ix.reindex(tx.txn, kb, old, new); err != nil {
+			return err
+		}
+	}
+	return nil
+}
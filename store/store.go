@@ -0,0 +1,303 @@
+// Package store is a typed key-value layer over gdbx.
+//
+// A Store[K, V] wraps one named table of an open environment and converts
+// keys and values with codecs. Single operations run in a transaction of
+// their own; View, Snapshot and Update group operations into one consistent
+// read or one atomic batch of writes. A Store opened with gdbx.DupSort maps
+// each key to a sorted set of values.
+//
+// Basic usage:
+//
+//	users, err := store.Open(env, "users", 0, store.Uint64(), store.JSON[User]())
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = users.Update(func(tx *store.Tx[uint64, User]) error {
+//	    if err := tx.Put(1, User{Name: "ada"}); err != nil {
+//	        return err
+//	    }
+//	    return tx.Put(2, User{Name: "bob"})
+//	})
+//	u, found, err := users.Get(1)
+package store
+
+import (
+	"errors"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// ErrStop can be returned by an iteration callback to end the iteration early
+// without an error.
+var ErrStop = errors.New("store: stop iteration")
+
+// Store is a typed view of one table. It is safe for concurrent use; the
+// environment serializes write transactions.
+type Store[K, V any] struct {
+	env *gdbx.Env
+	dbi gdbx.DBI
+	key Codec[K]
+	val Codec[V]
+
+	chunkSize int        // Chunk size of a store opened with OpenChunked, else 0
+	indexes   []indexer  // Indexes opened with OpenIndex
+	cas       *casTables // Blob and reference tables of a store opened with OpenCAS
+}
+
+// Open returns a Store for the named table of env ("" for the main table),
+// creating the table unless env is read-only. flags are table flags such as
+// gdbx.DupSort or gdbx.IntegerKey and must match an existing table's.
+func Open[K, V any](env *gdbx.Env, name string, flags uint, key Codec[K], val Codec[V]) (*Store[K, V], error) {
+	envFlags, err := env.Flags()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store[K, V]{env: env, key: key, val: val}
+	if envFlags&gdbx.ReadOnly != 0 {
+		err = env.View(func(txn *gdbx.Txn) (err error) {
+			s.dbi, err = txn.OpenDBISimple(name, flags)
+			return err
+		})
+	} else {
+		err = env.Update(func(txn *gdbx.Txn) (err error) {
+			s.dbi, err = txn.OpenDBISimple(name, flags|gdbx.Create)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Tx is a transaction on a Store: a snapshot inside View and Snapshot, a
+// batch of writes inside Update. It must not be used after the function it
+// was passed to returns.
+type Tx[K, V any] struct {
+	s   *Store[K, V]
+	txn *gdbx.Txn
+}
+
+// View runs fn on a read-only snapshot of the store.
+func (s *Store[K, V]) View(fn func(tx *Tx[K, V]) error) error {
+	return s.env.View(func(txn *gdbx.Txn) error {
+		return fn(&Tx[K, V]{s: s, txn: txn})
+	})
+}
+
+// Update runs fn in a write transaction. Its writes are committed together
+// if fn returns nil and discarded if it returns an error.
+func (s *Store[K, V]) Update(fn func(tx *Tx[K, V]) error) error {
+	return s.env.Update(func(txn *gdbx.Txn) error {
+		return fn(&Tx[K, V]{s: s, txn: txn})
+	})
+}
+
+// Snapshot is a read-only snapshot that stays open until Close, for reads
+// that span more than one function call.
+type Snapshot[K, V any] struct {
+	*Tx[K, V]
+}
+
+// Snapshot begins a read-only snapshot of the store. It holds a reader slot
+// and keeps its pages alive until Close, so it shouldn't be kept for long.
+func (s *Store[K, V]) Snapshot() (*Snapshot[K, V], error) {
+	txn, err := s.env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot[K, V]{&Tx[K, V]{s: s, txn: txn}}, nil
+}
+
+// Close releases the snapshot.
+func (snap *Snapshot[K, V]) Close() {
+	snap.txn.Abort()
+}
+
+// Get returns the value stored for k, or found == false. In a DupSort store
+// it returns the first of the key's values.
+func (s *Store[K, V]) Get(k K) (v V, found bool, err error) {
+	err = s.View(func(tx *Tx[K, V]) error {
+		v, found, err = tx.Get(k)
+		return err
+	})
+	return v, found, err
+}
+
+// Put stores v under k in a transaction of its own.
+func (s *Store[K, V]) Put(k K, v V) error {
+	return s.Update(func(tx *Tx[K, V]) error { return tx.Put(k, v) })
+}
+
+// Delete removes k and all its values in a transaction of its own.
+func (s *Store[K, V]) Delete(k K) error {
+	return s.Update(func(tx *Tx[K, V]) error { return tx.Delete(k) })
+}
+
+// ForEach calls fn for every key-value pair in key order.
+func (s *Store[K, V]) ForEach(fn func(k K, v V) error) error {
+	return s.View(func(tx *Tx[K, V]) error { return tx.ForEach(fn) })
+}
+
+// Range calls fn for every key-value pair with start <= key < end, in key order.
+func (s *Store[K, V]) Range(start, end K, fn func(k K, v V) error) error {
+	return s.View(func(tx *Tx[K, V]) error { return tx.Range(start, end, fn) })
+}
+
+// Get returns the value stored for k, or found == false. In a DupSort store
+// it returns the first of the key's values.
+func (tx *Tx[K, V]) Get(k K) (v V, found bool, err error) {
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return v, false, err
+	}
+	data, err := tx.txn.Get(tx.s.dbi, kb)
+	if gdbx.IsNotFound(err) {
+		return v, false, nil
+	}
+	if err != nil {
+		return v, false, err
+	}
+	v, err = tx.s.val.Decode(data)
+	return v, err == nil, err
+}
+
+// Put stores v under k. In a DupSort store it adds v to the key's values
+// instead of replacing them.
+func (tx *Tx[K, V]) Put(k K, v V) error {
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	vb, err := tx.s.val.Encode(v)
+	if err != nil {
+		return err
+	}
+	return tx.txn.Put(tx.s.dbi, kb, vb, 0)
+}
+
+// Delete removes k and all its values. Deleting a missing key is not an error.
+func (tx *Tx[K, V]) Delete(k K) error {
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	if err := tx.txn.Del(tx.s.dbi, kb, nil); err != nil && !gdbx.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// DeleteValue removes one value of k in a DupSort store. Deleting a missing
+// pair is not an error.
+func (tx *Tx[K, V]) DeleteValue(k K, v V) error {
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	vb, err := tx.s.val.Encode(v)
+	if err != nil {
+		return err
+	}
+	if err := tx.txn.Del(tx.s.dbi, kb, vb); err != nil && !gdbx.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Values calls fn for each value of k in order: all of them in a DupSort
+// store, the single value otherwise.
+func (tx *Tx[K, V]) Values(k K, fn func(v V) error) error {
+	kb, err := tx.s.key.Encode(k)
+	if err != nil {
+		return err
+	}
+	c, err := tx.txn.OpenCursor(tx.s.dbi)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, data, err := c.Get(kb, nil, gdbx.Set)
+	for ; err == nil; _, data, err = c.Get(nil, nil, gdbx.NextDup) {
+		v, err := tx.s.val.Decode(data)
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return stopped(err)
+		}
+	}
+	if gdbx.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Len returns the number of key-value pairs in the store.
+func (tx *Tx[K, V]) Len() (uint64, error) {
+	stat, err := tx.txn.Stat(tx.s.dbi)
+	if err != nil {
+		return 0, err
+	}
+	return stat.Entries, nil
+}
+
+// ForEach calls fn for every key-value pair in key order. fn must not modify
+// the store; returning ErrStop ends the iteration.
+func (tx *Tx[K, V]) ForEach(fn func(k K, v V) error) error {
+	return tx.scan(nil, nil, fn)
+}
+
+// Range calls fn for every key-value pair with start <= key < end, in the
+// order of the encoded keys. fn must not modify the store; returning ErrStop
+// ends the iteration.
+func (tx *Tx[K, V]) Range(start, end K, fn func(k K, v V) error) error {
+	low, err := tx.s.key.Encode(start)
+	if err != nil {
+		return err
+	}
+	high, err := tx.s.key.Encode(end)
+	if err != nil {
+		return err
+	}
+	return tx.scan(low, high, fn)
+}
+
+// scan iterates the pairs with low <= key < high; nil leaves a side open.
+func (tx *Tx[K, V]) scan(low, high []byte, fn func(k K, v V) error) error {
+	c, err := tx.txn.OpenCursor(tx.s.dbi)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	c.SetBounds(low, high)
+
+	kb, vb, err := c.Get(nil, nil, gdbx.First)
+	for ; err == nil; kb, vb, err = c.Get(nil, nil, gdbx.Next) {
+		k, err := tx.s.key.Decode(kb)
+		if err != nil {
+			return err
+		}
+		v, err := tx.s.val.Decode(vb)
+		if err != nil {
+			return err
+		}
+		if err := fn(k, v); err != nil {
+			return stopped(err)
+		}
+	}
+	if gdbx.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// stopped maps ErrStop to a clean end of iteration.
+func stopped(err error) error {
+	if err == ErrStop {
+		return nil
+	}
+	return err
+}
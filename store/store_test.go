@@ -0,0 +1,449 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func openEnv(t *testing.T) *gdbx.Env {
+	t.Helper()
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.SetMaxDBs(10)
+	if err := env.Open(t.TempDir(), 0, 0644); err != nil {
+		env.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(env.Close)
+	return env
+}
+
+func TestStore(t *testing.T) {
+	env := openEnv(t)
+	users, err := Open(env, "users", 0, Uint64(), JSON[user]())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Single operations
+	if err := users.Put(1, user{"ada", 36}); err != nil {
+		t.Fatal(err)
+	}
+	if u, found, err := users.Get(1); err != nil || !found || u != (user{"ada", 36}) {
+		t.Fatalf("Get(1) = %v, %v, %v", u, found, err)
+	}
+	if _, found, err := users.Get(2); err != nil || found {
+		t.Fatalf("Get(2) = %v, %v, want not found", found, err)
+	}
+
+	// A batch is applied as a whole
+	err = users.Update(func(tx *Tx[uint64, user]) error {
+		for i := uint64(2); i <= 300; i++ {
+			if err := tx.Put(i, user{"u", int(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errRollback := errors.New("rollback")
+	err = users.Update(func(tx *Tx[uint64, user]) error {
+		if err := tx.Delete(1); err != nil {
+			return err
+		}
+		if err := tx.Put(1000, user{"gone", 0}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("Update = %v, want %v", err, errRollback)
+	}
+	if _, found, _ := users.Get(1); !found {
+		t.Fatal("aborted batch deleted key 1")
+	}
+	if _, found, _ := users.Get(1000); found {
+		t.Fatal("aborted batch stored key 1000")
+	}
+
+	// Range uses the numeric order of Uint64 keys
+	var keys []uint64
+	err = users.Range(98, 103, func(k uint64, u user) error {
+		if u.Age != int(k) {
+			t.Fatalf("key %d has age %d", k, u.Age)
+		}
+		keys = append(keys, k)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{98, 99, 100, 101, 102}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("Range = %v, want %v", keys, want)
+	}
+
+	// ErrStop ends ForEach without an error
+	n := 0
+	err = users.ForEach(func(k uint64, u user) error {
+		if n++; n == 10 {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil || n != 10 {
+		t.Fatalf("ForEach stopped after %d with %v", n, err)
+	}
+
+	// A snapshot keeps its view while the store changes
+	snap, err := users.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Close()
+	if err := users.Delete(5); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Delete(5); err != nil {
+		t.Fatalf("Delete of a missing key: %v", err)
+	}
+	if _, found, err := snap.Get(5); err != nil || !found {
+		t.Fatalf("snapshot lost key 5: %v, %v", found, err)
+	}
+	if l, err := snap.Len(); err != nil || l != 300 {
+		t.Fatalf("snapshot Len = %d, %v, want 300", l, err)
+	}
+	if _, found, _ := users.Get(5); found {
+		t.Fatal("key 5 still visible after Delete")
+	}
+}
+
+func TestStoreDupSort(t *testing.T) {
+	env := openEnv(t)
+	tags, err := Open(env, "tags", gdbx.DupSort, String(), String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tags.Update(func(tx *Tx[string, string]) error {
+		for _, kv := range [][2]string{
+			{"go", "fast"}, {"go", "simple"}, {"go", "compiled"}, {"go", "fast"},
+			{"rust", "safe"}, {"c", "old"},
+		} {
+			if err := tx.Put(kv[0], kv[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := func(k string) []string {
+		t.Helper()
+		var vs []string
+		err := tags.View(func(tx *Tx[string, string]) error {
+			return tx.Values(k, func(v string) error {
+				vs = append(vs, v)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vs
+	}
+	if got, want := values("go"), []string{"compiled", "fast", "simple"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values(go) = %v, want %v", got, want)
+	}
+	if got := values("java"); got != nil {
+		t.Fatalf("Values(java) = %v, want none", got)
+	}
+	if v, found, err := tags.Get("go"); err != nil || !found || v != "compiled" {
+		t.Fatalf("Get(go) = %q, %v, %v", v, found, err)
+	}
+
+	err = tags.Update(func(tx *Tx[string, string]) error {
+		if err := tx.DeleteValue("go", "fast"); err != nil {
+			return err
+		}
+		return tx.DeleteValue("go", "missing")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values("go"), []string{"compiled", "simple"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values(go) after DeleteValue = %v, want %v", got, want)
+	}
+
+	// ForEach visits every pair; Delete drops all values of a key
+	if err := tags.Delete("go"); err != nil {
+		t.Fatal(err)
+	}
+	var pairs []string
+	err = tags.ForEach(func(k, v string) error {
+		pairs = append(pairs, k+"="+v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"c=old", "rust=safe"}; !reflect.DeepEqual(pairs, want) {
+		t.Fatalf("ForEach = %v, want %v", pairs, want)
+	}
+}
+
+func TestStoreChunked(t *testing.T) {
+	env := openEnv(t)
+	if _, err := OpenChunked(env, "bad", String(), env.MaxKeySize()); err == nil {
+		t.Fatal("OpenChunked accepted a chunk size above the duplicate limit")
+	}
+	blobs, err := OpenChunked(env, "blobs", String(), 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(i * 31)
+		}
+		return b
+	}
+	sizes := []int{0, 1, 255, 256, 257, 1 << 20}
+	for _, n := range sizes {
+		if err := blobs.PutChunked(fmt.Sprint(n), value(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, n := range sizes {
+		got, found, err := blobs.GetChunked(fmt.Sprint(n))
+		if err != nil || !found || !bytes.Equal(got, value(n)) {
+			t.Fatalf("GetChunked(%d) = %d bytes, %v, %v", n, len(got), found, err)
+		}
+	}
+
+	// Replacing a value drops its old chunks
+	if err := blobs.PutChunked("1048576", []byte("short")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := blobs.GetChunked("1048576"); err != nil || string(got) != "short" {
+		t.Fatalf("GetChunked after replace = %q, %v", got, err)
+	}
+	if err := blobs.Delete("257"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := blobs.GetChunked("257"); err != nil || found {
+		t.Fatalf("GetChunked after Delete = %v, %v, want not found", found, err)
+	}
+
+	plain, err := Open(env, "plain", 0, String(), Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.PutChunked("k", []byte("v")); err == nil {
+		t.Fatal("PutChunked succeeded on a store not opened with OpenChunked")
+	}
+}
+
+func TestStoreIndex(t *testing.T) {
+	env := openEnv(t)
+	users, err := Open(env, "users", 0, Uint64(), JSON[user]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Users by name; the empty name isn't indexed
+	byName, err := OpenIndex(users, "users-by-name", String(), func(u user) (string, bool) {
+		return u.Name, u.Name != ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := func(name string) []uint64 {
+		t.Helper()
+		var got []uint64
+		err := users.View(func(tx *Tx[uint64, user]) error {
+			return byName.Keys(tx, name, func(k uint64) error {
+				got = append(got, k)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	for i, name := range []string{"ada", "bob", "ada", ""} {
+		if err := users.PutIndexed(uint64(i+1), user{name, i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := keys("ada"); !reflect.DeepEqual(got, []uint64{1, 3}) {
+		t.Fatalf("ada: %v", got)
+	}
+
+	// An update moves the key to the new name; an unchanged name stays put
+	if err := users.PutIndexed(1, user{"bob", 40}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.PutIndexed(2, user{"bob", 41}); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys("ada"); !reflect.DeepEqual(got, []uint64{3}) {
+		t.Fatalf("ada after the update: %v", got)
+	}
+	if got := keys("bob"); !reflect.DeepEqual(got, []uint64{1, 2}) {
+		t.Fatalf("bob after the update: %v", got)
+	}
+
+	// Deleting removes the index entry, and a missing key is no error
+	if err := users.DeleteIndexed(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.DeleteIndexed(99); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys("ada"); got != nil {
+		t.Fatalf("ada after the delete: %v", got)
+	}
+
+	// A failed batch leaves the store and the index as they were
+	err = users.Update(func(tx *Tx[uint64, user]) error {
+		if err := tx.PutIndexed(4, user{"cy", 1}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil || keys("cy") != nil {
+		t.Fatalf("rolled back batch: %v, cy has %v", err, keys("cy"))
+	}
+	if u, found, err := users.Get(4); err != nil || !found || u.Name != "" {
+		t.Fatalf("user 4 after the rolled back batch: %v, %v, %v", u, found, err)
+	}
+
+	tags, err := Open(env, "tags", gdbx.DupSort, String(), String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenIndex(tags, "tags-idx", String(), func(v string) (string, bool) { return v, true }); err == nil {
+		t.Fatal("indexing a DupSort store succeeded")
+	}
+}
+
+func TestStoreCAS(t *testing.T) {
+	env := openEnv(t)
+	s, err := OpenCAS(env, "docs", String(), Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := Open(env, "docs.blobs", 0, Bytes(), Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := func(hash [HashSize]byte) uint64 {
+		t.Helper()
+		var n uint64
+		if err := s.View(func(tx *Tx[string, []byte]) (err error) {
+			n, err = tx.References(hash)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	blobCount := func() int {
+		t.Helper()
+		n := 0
+		if err := blobs.View(func(tx *Tx[[]byte, []byte]) error {
+			return tx.ForEach(func([]byte, []byte) error { n++; return nil })
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// Two keys with one value share a blob
+	big := bytes.Repeat([]byte("0123456789abcdef"), 1<<14)
+	h1, err := s.PutCAS("a", big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := s.PutCAS("b", big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 || refs(h1) != 2 || blobCount() != 1 {
+		t.Fatalf("shared value: hashes equal %v, %d refs, %d blobs", h1 == h2, refs(h1), blobCount())
+	}
+	if got, found, err := s.GetCAS("b"); err != nil || !found || !bytes.Equal(got, big) {
+		t.Fatalf("GetCAS(b) = %d bytes, %v, %v", len(got), found, err)
+	}
+
+	// Putting the same value again adds no reference
+	if _, err := s.PutCAS("a", big); err != nil || refs(h1) != 2 {
+		t.Fatalf("re-put: %d refs, %v", refs(h1), err)
+	}
+
+	// Moving a key to another value moves its reference
+	h3, err := s.PutCAS("a", []byte("small"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs(h1) != 1 || refs(h3) != 1 || blobCount() != 2 {
+		t.Fatalf("replace: %d and %d refs, %d blobs", refs(h1), refs(h3), blobCount())
+	}
+
+	// Deleting the last reference frees the blob
+	if err := s.DeleteCAS("b"); err != nil {
+		t.Fatal(err)
+	}
+	if refs(h1) != 0 || blobCount() != 1 {
+		t.Fatalf("delete: %d refs, %d blobs", refs(h1), blobCount())
+	}
+	if _, found, err := s.GetCAS("b"); err != nil || found {
+		t.Fatalf("GetCAS after DeleteCAS = %v, %v, want not found", found, err)
+	}
+	if err := s.DeleteCAS("b"); err != nil {
+		t.Fatalf("DeleteCAS of a missing key: %v", err)
+	}
+
+	// A failed transaction leaves all three tables as they were
+	fail := errors.New("fail")
+	if err := s.Update(func(tx *Tx[string, []byte]) error {
+		if _, err := tx.PutCAS("c", big); err != nil {
+			return err
+		}
+		if err := tx.DeleteCAS("a"); err != nil {
+			return err
+		}
+		return fail
+	}); err != fail {
+		t.Fatalf("Update = %v", err)
+	}
+	if got, _, err := s.GetCAS("a"); err != nil || string(got) != "small" || blobCount() != 1 {
+		t.Fatalf("after rollback: GetCAS(a) = %q, %v, %d blobs", got, err, blobCount())
+	}
+
+	plain, err := Open(env, "plain", 0, String(), Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.PutCAS("k", []byte("v")); err == nil {
+		t.Fatal("PutCAS succeeded on a store not opened with OpenCAS")
+	}
+}
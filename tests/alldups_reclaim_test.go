@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestAllDupsReclaimsSubTree deletes a sub-tree-backed key whole, with
+// Txn.Del and with Cursor.Del(AllDups), and fills the same transaction
+// again. The pages of the deleted sub-tree must be reused, leaving the file
+// no larger than a database that only ever held the second key, and the
+// table's page counts as before the key was put; Verify must find every
+// page referred to once.
+func TestAllDupsReclaimsSubTree(t *testing.T) {
+	const dups = 5000
+	val := func(i int) []byte { return []byte(fmt.Sprintf("value-%06d", i)) }
+
+	// run returns the last page of the file after the transaction
+	run := func(flags uint, how string) int64 {
+		db := newTestDB(t)
+		defer db.cleanup()
+		env := openGdbxEnv(t, db.path, flags)
+		defer env.Close()
+
+		err := env.Update(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+			if err != nil {
+				return err
+			}
+			put := func(key string) error {
+				for i := 0; i < dups; i++ {
+					if err := txn.Put(dbi, []byte(key), val(i), 0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			if err := txn.Put(dbi, []byte("small"), []byte("v"), 0); err != nil {
+				return err
+			}
+			before, err := txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+
+			if how != "" {
+				if err := put("first"); err != nil {
+					return err
+				}
+			}
+			switch how {
+			case "txn":
+				if err := txn.Del(dbi, []byte("first"), nil); err != nil {
+					return err
+				}
+			case "cursor":
+				cur, err := txn.OpenCursor(dbi)
+				if err != nil {
+					return err
+				}
+				defer cur.Close()
+				if _, _, err := cur.Get([]byte("first"), nil, gdbx.Set); err != nil {
+					return err
+				}
+				if err := cur.Del(gdbx.AllDups); err != nil {
+					return err
+				}
+			}
+			after, err := txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+			if after.LeafPages != before.LeafPages || after.BranchPages != before.BranchPages || after.Entries != 1 {
+				t.Errorf("flags %#x, %s: %d leaf, %d branch pages and %d entries after the delete, want %d, %d and 1",
+					flags, how, after.LeafPages, after.BranchPages, after.Entries, before.LeafPages, before.BranchPages)
+			}
+			return put("second")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Verify(); err != nil {
+			t.Fatalf("flags %#x, %s: %v", flags, how, err)
+		}
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.LastPgNo
+	}
+
+	for _, flags := range []uint{0, gdbx.WriteMap} {
+		want := run(flags, "")
+		for _, how := range []string{"txn", "cursor"} {
+			// Allocation is rounded up to the system page size
+			if got := run(flags, how); got > want+1 {
+				t.Errorf("flags %#x, %s: file ends at page %d, want at most %d as without the deleted key", flags, how, got, want+1)
+			}
+		}
+	}
+}
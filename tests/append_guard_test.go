@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestAppendGuard checks that after SetAppendGuard a put whose key does not
+// sort after the last one fails with ErrKeyMismatch naming that key, through
+// Txn.Put, a cursor and GetWriter, and leaves the table as it was; that a
+// DupSort key may repeat; that other tables and other transactions are not
+// guarded; and that the guard follows a custom comparator.
+func TestAppendGuard(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("k%04d", i)) }
+	mismatch := func(t *testing.T, err error, last []byte) {
+		t.Helper()
+		var ge *gdbx.AppendGuardError
+		if gdbx.Code(err) != gdbx.ErrKeyMismatch || !errors.As(err, &ge) || string(ge.Last) != string(last) {
+			t.Fatalf("got %v, want ErrKeyMismatch after %q", err, last)
+		}
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	log, err := txn.OpenDBISimple("log", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := txn.OpenDBISimple("other", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys already in the table don't constrain the first guarded put
+	if err := txn.Put(log, key(500), []byte("old"), 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, dbi := range []gdbx.DBI{log, dups} {
+		if err := txn.SetAppendGuard(dbi); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 10; i < 20; i++ {
+		if err := txn.Put(log, key(i), []byte("v"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mismatch(t, txn.Put(log, key(19), []byte("again"), 0), key(19))
+	mismatch(t, txn.Put(log, key(5), []byte("v"), 0), key(19))
+	if v, err := txn.Get(log, key(19)); err != nil || string(v) != "v" {
+		t.Fatalf("k0019 = %q, %v after the refused overwrite", v, err)
+	}
+	if _, err := txn.Get(log, key(5)); !gdbx.IsNotFound(err) {
+		t.Fatalf("refused k0005 was stored: %v", err)
+	}
+
+	c, err := txn.OpenCursor(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	mismatch(t, c.Put(key(18), []byte("v"), 0), key(19))
+	if err := c.Put(key(20), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	mismatch(t, txn.Put(log, key(19), []byte("v"), gdbx.Append), key(20))
+	if _, err := txn.GetWriter(log, key(20), 10000, 0); gdbx.Code(err) != gdbx.ErrKeyMismatch {
+		t.Fatalf("GetWriter on the last key = %v", err)
+	}
+	if v, err := txn.Get(log, key(20)); err != nil || string(v) != "v" {
+		t.Fatalf("k0020 = %q, %v after the refused GetWriter", v, err)
+	}
+	if _, err := txn.GetWriter(log, key(21), 10000, 0); err != nil {
+		t.Fatal(err)
+	}
+	mismatch(t, txn.Put(log, key(21), nil, 0), key(21))
+
+	// A DupSort key takes more values; an earlier key doesn't
+	for _, v := range []string{"b", "a", "c"} {
+		if err := txn.Put(dups, key(1), []byte(v), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mismatch(t, txn.Put(dups, key(0), []byte("a"), 0), key(1))
+
+	// Unguarded tables take any order
+	for _, i := range []int{3, 1, 2} {
+		if err := txn.Put(other, key(i), nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The guard ends with the transaction
+	err = env.Update(func(txn *gdbx.Txn) error {
+		return txn.Put(log, key(1), []byte("late"), 0)
+	})
+	if err != nil {
+		t.Fatalf("put after the guarded transaction: %v", err)
+	}
+
+	t.Run("Comparator", func(t *testing.T) {
+		err := env.Update(func(txn *gdbx.Txn) error {
+			rev, err := txn.OpenDBI("rev", gdbx.Create, func(a, b []byte) int { return bytes.Compare(b, a) }, nil)
+			if err != nil {
+				return err
+			}
+			if err := txn.SetAppendGuard(rev); err != nil {
+				return err
+			}
+			for i := 9; i >= 0; i-- {
+				if err := txn.Put(rev, key(i), nil, 0); err != nil {
+					return err
+				}
+			}
+			mismatch(t, txn.Put(rev, key(5), nil, 0), key(0))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		err := env.View(func(txn *gdbx.Txn) error {
+			if err := txn.SetAppendGuard(log); gdbx.Code(err) != gdbx.ErrPermissionDenied {
+				return fmt.Errorf("SetAppendGuard in a read transaction = %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
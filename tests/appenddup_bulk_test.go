@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestAppendDupRun loads a key's values with AppendDup, far past the point
+// where its sub-page becomes a sub-tree, while other keys are written and
+// values deleted from it along the way, and checks the values read back
+// before and after commit, that repeating the last value changes nothing,
+// that an out-of-order value is refused, and that the sub-tree takes no
+// more pages than one loaded by plain puts.
+func TestAppendDupRun(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{
+		{"DupSort", gdbx.DupSort},
+		{"DupFixed", gdbx.DupSort | gdbx.DupFixed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			defer db.cleanup()
+			env := openGdbxEnv(t, db.path, 0)
+			defer env.Close()
+
+			const total = 30000
+			value := func(i int) []byte { return binary.BigEndian.AppendUint64(nil, uint64(i)) }
+			deleted := func(i int) bool { return i%5000 == 17 }
+
+			txn, err := env.BeginTxn(nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer txn.Abort()
+			dbi, err := txn.OpenDBISimple("run", gdbx.Create|tc.flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, err := txn.OpenDBISimple("plain", gdbx.Create|tc.flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < total; i++ {
+				if err := txn.Put(dbi, []byte("k"), value(i), gdbx.AppendDup); err != nil {
+					t.Fatalf("AppendDup %d: %v", i, err)
+				}
+				if err := txn.Put(plain, []byte("k"), value(i), 0); err != nil {
+					t.Fatal(err)
+				}
+				if i%1000 == 0 {
+					if err := txn.Put(dbi, value(i), value(i), 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if i%5000 == 30 {
+					if err := txn.Del(dbi, []byte("k"), value(i-13)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			stat, err := txn.Stat(dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := txn.Put(dbi, []byte("k"), value(total-1), gdbx.AppendDup); err != nil {
+				t.Fatalf("AppendDup of the last value: %v", err)
+			}
+			err = txn.Put(dbi, []byte("k"), value(total-2), gdbx.AppendDup)
+			var ae *gdbx.AppendDupError
+			if gdbx.Code(err) != gdbx.ErrKeyMismatch || !errors.As(err, &ae) || string(ae.Last) != string(value(total-1)) {
+				t.Fatalf("out-of-order AppendDup: %v", err)
+			}
+			if after, err := txn.Stat(dbi); err != nil || after.Entries != stat.Entries {
+				t.Fatalf("entries %d -> %+v, %v", stat.Entries, after, err)
+			}
+			plainStat, err := txn.Stat(plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The keys written along the way may take a page of their own
+			if stat.LeafPages > plainStat.LeafPages+1 {
+				t.Fatalf("%d leaf pages, %d loaded by plain puts", stat.LeafPages, plainStat.LeafPages)
+			}
+
+			check := func(txn *gdbx.Txn) {
+				t.Helper()
+				cur, err := txn.OpenCursor(dbi)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer cur.Close()
+				_, v, err := cur.Get([]byte("k"), nil, gdbx.Set)
+				for i := 0; i < total; i++ {
+					if deleted(i) {
+						continue
+					}
+					if err != nil {
+						t.Fatalf("value %d: %v", i, err)
+					}
+					if string(v) != string(value(i)) {
+						t.Fatalf("value %d: got %x", i, v)
+					}
+					_, v, err = cur.Get(nil, nil, gdbx.NextDup)
+				}
+				if !gdbx.IsNotFound(err) {
+					t.Fatalf("past the last value: %x, %v", v, err)
+				}
+			}
+			check(txn)
+			if _, err := txn.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rtxn.Abort()
+			check(rtxn)
+		})
+	}
+}
+
+// BenchmarkAppendDupSorted puts 100k sorted duplicates under one key, in one
+// transaction per iteration, with AppendDup and, for comparison, without.
+func BenchmarkAppendDupSorted(b *testing.B) {
+	const n = 100000
+	for _, tc := range []struct {
+		name     string
+		flags    uint
+		putFlags uint
+	}{
+		{"DupSort/AppendDup", gdbx.DupSort, gdbx.AppendDup},
+		{"DupSort/Put", gdbx.DupSort, 0},
+		{"DupFixed/AppendDup", gdbx.DupSort | gdbx.DupFixed, gdbx.AppendDup},
+		{"DupFixed/Put", gdbx.DupSort | gdbx.DupFixed, 0},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			dir, err := os.MkdirTemp("", "gdbx-bench-*")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			env, err := gdbx.NewEnv(gdbx.Default)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer env.Close()
+			env.SetMaxDBs(10)
+			env.SetGeometry(-1, -1, 1<<30, -1, -1, 4096)
+			if err := env.Open(dir, gdbx.Create, 0644); err != nil {
+				b.Fatal(err)
+			}
+			values := make([][]byte, n)
+			for i := range values {
+				values[i] = binary.BigEndian.AppendUint64(nil, uint64(i))
+			}
+			b.ResetTimer()
+			for it := 0; it < b.N; it++ {
+				txn, err := env.BeginTxn(nil, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				dbi, err := txn.OpenDBISimple("dups", gdbx.Create|tc.flags)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, v := range values {
+					if err := txn.Put(dbi, []byte("key"), v, tc.putFlags); err != nil {
+						b.Fatal(err)
+					}
+				}
+				txn.Abort()
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/value")
+		})
+	}
+}
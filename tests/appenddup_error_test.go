@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestAppendDupError checks that an out-of-order AppendDup reports the key's
+// last value through AppendDupError, whether the key holds a single value, a
+// sub-page or a sub-tree, and that a plain put of the value then succeeds.
+func TestAppendDupError(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		key    string
+		values int
+	}{
+		{"single", 1},
+		{"subpage", 10},
+		{"subtree", 1000},
+	} {
+		key := []byte(tc.key)
+		value := func(i int) []byte { return []byte(fmt.Sprintf("v%05d", i)) }
+		for i := 0; i < tc.values; i++ {
+			if err := txn.Put(dbi, key, value(2*i), gdbx.AppendDup); err != nil {
+				t.Fatal(err)
+			}
+		}
+		last := value(2 * (tc.values - 1))
+		below := value(2*tc.values - 3) // Just below last
+
+		err := txn.Put(dbi, key, below, gdbx.AppendDup)
+		if gdbx.Code(err) != gdbx.ErrKeyMismatch {
+			t.Fatalf("%s: out-of-order AppendDup: got %v, want ErrKeyMismatch", tc.key, err)
+		}
+		var ae *gdbx.AppendDupError
+		if !errors.As(err, &ae) {
+			t.Fatalf("%s: %v does not wrap an AppendDupError", tc.key, err)
+		}
+		if string(ae.Last) != string(last) {
+			t.Fatalf("%s: AppendDupError.Last = %q, want %q", tc.key, ae.Last, last)
+		}
+		// Last is the caller's to keep
+		ae.Last[0] = 'x'
+
+		if err := txn.Put(dbi, key, below, 0); err != nil {
+			t.Fatalf("%s: falling back to a plain put: %v", tc.key, err)
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, v, err := cur.Get(key, nil, gdbx.Set)
+		if err == nil {
+			_, v, err = cur.Get(nil, nil, gdbx.LastDup)
+		}
+		cur.Close()
+		if err != nil || string(v) != string(last) {
+			t.Fatalf("%s: last value %q, %v after the fallback, want %q", tc.key, v, err, last)
+		}
+	}
+}
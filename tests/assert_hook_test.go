@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestAssertHook runs a randomized insert/update/delete workload with the
+// assert hook installed and fails on the first violated invariant.
+func TestAssertHook(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	checks := 0
+	env.SetAssertHook(func(cond bool, msg string) {
+		checks++
+		if !cond {
+			t.Fatalf("invariant violated: %s", msg)
+		}
+	})
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < 20000; i++ {
+		k := []byte(fmt.Sprintf("key%05d", rng.Intn(5000)))
+		switch op := rng.Intn(10); {
+		case op < 6:
+			v := []byte(fmt.Sprintf("val%d", i))
+			if op == 0 {
+				v = make([]byte, 3000) // Overflow value
+			}
+			if err := txn.Put(plain, k, v, 0); err != nil {
+				t.Fatal(err)
+			}
+		case op < 8:
+			if err := txn.Del(plain, k, nil); err != nil && !gdbx.IsNotFound(err) {
+				t.Fatal(err)
+			}
+		case op < 9:
+			if err := txn.Put(dups, k[:5], []byte(fmt.Sprintf("dup%05d", rng.Intn(500))), 0); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			if err := txn.Del(dups, k[:5], nil); err != nil && !gdbx.IsNotFound(err) {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if checks == 0 {
+		t.Fatal("assert hook was never called")
+	}
+
+	// Removing the hook disables checks
+	env.SetAssertHook(nil)
+	before := checks
+	if err := txn.Put(plain, []byte("after"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if checks != before {
+		t.Fatalf("hook called %d times after being removed", checks-before)
+	}
+}
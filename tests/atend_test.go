@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCursorAtEnd walks plain and DupSort cursors off the end of their table
+// and checks the state they are left in: AtEnd is set, GetCurrent, Count, Del
+// and the Dup operations find no entry, Next keeps failing, Prev steps back
+// from the last entry and a Put makes the cursor current again. Running off
+// the beginning and a failed SetRange also leave the cursor at the end.
+func TestCursorAtEnd(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := txn.Put(plain, []byte(fmt.Sprintf("k%03d", i)), []byte(fmt.Sprintf("v%03d", i)), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Put(dups, []byte(fmt.Sprintf("k%03d", i/10)), []byte(fmt.Sprintf("v%03d", i)), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry := func(k, v []byte, err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		return string(k) + "=" + string(v)
+	}
+	for _, tc := range []struct {
+		name             string
+		dbi              gdbx.DBI
+		last, beforeLast string
+	}{
+		{"plain", plain, "k099=v099", "k098=v098"},
+		{"dups", dups, "k009=v099", "k009=v098"},
+	} {
+		cur, err := txn.OpenCursor(tc.dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cur.AtEnd() {
+			t.Fatalf("%s: fresh cursor at the end", tc.name)
+		}
+		n := 0
+		for _, _, err = cur.Get(nil, nil, gdbx.First); err == nil; _, _, err = cur.Get(nil, nil, gdbx.Next) {
+			n++
+		}
+		if !gdbx.IsNotFound(err) || n != 100 {
+			t.Fatalf("%s: %d entries, then %v", tc.name, n, err)
+		}
+
+		checkEnd := func(when string) {
+			t.Helper()
+			if !cur.AtEnd() {
+				t.Fatalf("%s %s: AtEnd false", tc.name, when)
+			}
+			if k, v, err := cur.Get(nil, nil, gdbx.GetCurrent); !gdbx.IsNotFound(err) {
+				t.Fatalf("%s %s: GetCurrent = %s, want not found", tc.name, when, entry(k, v, err))
+			}
+			if c, err := cur.Count(); !gdbx.IsNotFound(err) {
+				t.Fatalf("%s %s: Count = %d, %v, want not found", tc.name, when, c, err)
+			}
+			if err := cur.Del(0); !gdbx.IsNotFound(err) {
+				t.Fatalf("%s %s: Del: %v, want not found", tc.name, when, err)
+			}
+			if tc.dbi == dups {
+				if k, v, err := cur.Get(nil, nil, gdbx.NextDup); !gdbx.IsNotFound(err) {
+					t.Fatalf("%s %s: NextDup = %s, want not found", tc.name, when, entry(k, v, err))
+				}
+			}
+			if !cur.AtEnd() {
+				t.Fatalf("%s %s: failed lookups moved the cursor", tc.name, when)
+			}
+		}
+		checkEnd("after Next ran off")
+		if k, v, err := cur.Get(nil, nil, gdbx.Next); !gdbx.IsNotFound(err) {
+			t.Fatalf("%s: second Next = %s", tc.name, entry(k, v, err))
+		}
+		checkEnd("after a second Next")
+
+		// Prev steps back from the last entry, as in libmdbx
+		if got := entry(cur.Get(nil, nil, gdbx.Prev)); got != tc.beforeLast {
+			t.Fatalf("%s: Prev = %s, want %s", tc.name, got, tc.beforeLast)
+		}
+		if cur.AtEnd() {
+			t.Fatalf("%s: AtEnd after Prev", tc.name)
+		}
+		if got := entry(cur.Get(nil, nil, gdbx.GetCurrent)); got != tc.beforeLast {
+			t.Fatalf("%s: GetCurrent after Prev = %s", tc.name, got)
+		}
+		if got := entry(cur.Get(nil, nil, gdbx.Next)); got != tc.last {
+			t.Fatalf("%s: Next back to the last entry = %s", tc.name, got)
+		}
+
+		// A Put makes its entry current
+		if _, _, err := cur.Get(nil, nil, gdbx.Next); !gdbx.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		if err := cur.Put([]byte("zzz"), []byte("new"), 0); err != nil {
+			t.Fatal(err)
+		}
+		if got := entry(cur.Get(nil, nil, gdbx.GetCurrent)); cur.AtEnd() || got != "zzz=new" {
+			t.Fatalf("%s: after Put: AtEnd %v, GetCurrent = %s", tc.name, cur.AtEnd(), got)
+		}
+		if err := cur.Del(0); err != nil {
+			t.Fatal(err)
+		}
+
+		// Running off the beginning; Prev from there comes back at the end
+		if _, _, err := cur.Get(nil, nil, gdbx.First); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := cur.Get(nil, nil, gdbx.Prev); !gdbx.IsNotFound(err) {
+			t.Fatalf("%s: Prev before the first entry: %v", tc.name, err)
+		}
+		if !cur.AtEnd() {
+			t.Fatalf("%s: AtEnd false after Prev ran off", tc.name)
+		}
+		if got := entry(cur.Get(nil, nil, gdbx.Prev)); got != tc.last {
+			t.Fatalf("%s: Prev from EOF = %s, want %s", tc.name, got, tc.last)
+		}
+
+		if _, _, err := cur.Get([]byte("zzz"), nil, gdbx.SetRange); !gdbx.IsNotFound(err) {
+			t.Fatalf("%s: SetRange past the end: %v", tc.name, err)
+		}
+		checkEnd("after SetRange past the end")
+		if got := entry(cur.Get(nil, nil, gdbx.Last)); cur.AtEnd() || got != tc.last {
+			t.Fatalf("%s: Last = %s, AtEnd %v", tc.name, got, cur.AtEnd())
+		}
+		cur.Close()
+	}
+}
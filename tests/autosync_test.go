@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestAutoSync verifies that the auto-sync goroutine syncs NoSync commits
+// once either threshold is met, runs alongside concurrent writers, and that
+// Close syncs what is still pending.
+func TestAutoSync(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, gdbx.SafeNoSync)
+
+	commit := func(i int) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := txn.Put(gdbx.MainDBI, []byte(fmt.Sprintf("key%05d", i)), []byte("value"), 0); err != nil {
+			txn.Abort()
+			t.Error(err)
+			return
+		}
+		if _, err := txn.Commit(); err != nil {
+			t.Error(err)
+		}
+	}
+	unsynced := func() uint64 {
+		t.Helper()
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.UnsyncedBytes
+	}
+	waitSynced := func() {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); unsynced() != 0; {
+			if time.Now().After(deadline) {
+				t.Fatalf("%d bytes still unsynced", unsynced())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, args := range [][2]int{{0, 0}, {-1, 0}, {0, -1}} {
+		if err := env.StartAutoSync(time.Duration(args[0]), args[1]); gdbx.Code(err) != gdbx.ErrInvalid {
+			t.Fatalf("StartAutoSync(%d, %d): got %v, want ErrInvalid", args[0], args[1], err)
+		}
+	}
+
+	// NoSync commits accumulate until something syncs them
+	commit(0)
+	if unsynced() == 0 {
+		t.Fatal("SafeNoSync commit reported as synced")
+	}
+
+	// Byte threshold
+	if err := env.StartAutoSync(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	waitSynced()
+	commit(1)
+	waitSynced()
+
+	// Period, restarting with new thresholds
+	if err := env.StartAutoSync(5*time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+	info, err := env.Info(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AutoSyncThreshold != 0 || info.AutosyncPeriod != gdbx.NewDuration16dot16(5*time.Millisecond) {
+		t.Fatalf("Info reports threshold %d, period %v", info.AutoSyncThreshold, info.AutosyncPeriod.ToDuration())
+	}
+	commit(2)
+	waitSynced()
+
+	// Syncs interleave with concurrent writers
+	if err := env.StartAutoSync(time.Millisecond, 4096); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				commit(1000 + w*100 + i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	waitSynced()
+	if err := env.StopAutoSync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close stops the goroutine and syncs what it had not yet
+	if err := env.StartAutoSync(0, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	commit(3)
+	if unsynced() == 0 {
+		t.Fatal("commit below the threshold was synced")
+	}
+	env.Close()
+
+	env = openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	stat, err := txn.Stat(gdbx.MainDBI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Entries != 204 {
+		t.Fatalf("reopened with %d entries, want 204", stat.Entries)
+	}
+}
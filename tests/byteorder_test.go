@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestOpenForeignByteOrder byte-swaps the magic of every meta page, as a
+// host of the other byte order would have written it, and checks that Open
+// refuses the file with ErrIncompatible and says why, read-write and
+// read-only, and that the file opens again once the magic is put back.
+func TestOpenForeignByteOrder(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	dataPath := filepath.Join(db.path, gdbx.DataFileName)
+
+	env := openGdbxEnv(t, db.path, 0)
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		return txn.Put(gdbx.MainDBI, []byte("k"), []byte("v"), 0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := env.Info(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageSize := int(info.PageSize)
+	env.Close()
+
+	orig, err := os.ReadFile(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := slices.Clone(orig)
+	const magicOffset = 20 // After the page header
+	for i := 0; i < gdbx.NumMetas; i++ {
+		slices.Reverse(swapped[i*pageSize+magicOffset : i*pageSize+magicOffset+8])
+	}
+	if err := os.WriteFile(dataPath, swapped, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, flags := range []uint{0, gdbx.ReadOnly} {
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = env.Open(db.path, flags, 0644)
+		env.Close()
+		if gdbx.Code(err) != gdbx.ErrIncompatible || !strings.Contains(err.Error(), "endian file on") {
+			t.Fatalf("flags %#x: got %v, want ErrIncompatible for the byte order", flags, err)
+		}
+	}
+	if got, err := os.ReadFile(dataPath); err != nil || !slices.Equal(got, swapped) {
+		t.Fatalf("refused open changed the file: %v", err)
+	}
+
+	if err := os.WriteFile(dataPath, orig, 0644); err != nil {
+		t.Fatal(err)
+	}
+	env = openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	if err := env.View(func(txn *gdbx.Txn) error {
+		v, err := txn.Get(gdbx.MainDBI, []byte("k"))
+		if err == nil && string(v) != "v" {
+			t.Errorf("k = %q", v)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestChangeStream copies a database, keeps writing to the source, and
+// brings the copy up to date by applying change streams. The copy must then
+// hold the same data as the source, for gdbx and for libmdbx.
+func TestChangeStream(t *testing.T) {
+	for _, mode := range []struct {
+		name  string
+		flags uint
+	}{{"default", 0}, {"writemap", gdbx.WriteMap}} {
+		t.Run(mode.name, func(t *testing.T) {
+			testChangeStream(t, mode.flags)
+		})
+	}
+}
+
+func testChangeStream(t *testing.T, flags uint) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, filepath.Join(db.path, "src"), flags)
+	defer env.Close()
+	if err := env.SetChangeHistory(8); err != nil {
+		t.Fatal(err)
+	}
+
+	big := func(i, gen int) []byte {
+		return bytes.Repeat([]byte{byte(i), byte(gen)}, 3000)
+	}
+	// write runs one transaction of generation gen: it replaces values,
+	// rewrites big values at the same size, deletes keys and adds duplicates
+	write := func(gen int) uint64 {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := txn.ID()
+		plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 500; i++ {
+			k := []byte(fmt.Sprintf("k%04d", i))
+			switch {
+			case i%50 == 0:
+				err = txn.Put(plain, k, big(i, gen), 0)
+			case i%7 == gen%7:
+				err = txn.Del(plain, k, nil)
+				if gdbx.IsNotFound(err) {
+					err = nil
+				}
+			default:
+				err = txn.Put(plain, k, []byte(fmt.Sprintf("v%d-%d", i, gen)), 0)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < 300; i++ {
+			if err := txn.Put(dups, []byte(fmt.Sprintf("d%d", gen%3)), []byte(fmt.Sprintf("%d-%05d", gen, i)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	base := write(0)
+	dstPath := filepath.Join(db.path, "copy.dat")
+	if err := env.Copy(dstPath, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bring the copy forward twice, once over several commits
+	apply := func(since uint64) uint64 {
+		t.Helper()
+		var stream bytes.Buffer
+		if err := env.StreamChanges(since, &stream); err != nil {
+			t.Fatalf("StreamChanges(%d): %v", since, err)
+		}
+		f, err := os.OpenFile(dstPath, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		to, err := gdbx.ApplyChanges(f, bytes.NewReader(stream.Bytes()))
+		if err != nil {
+			t.Fatalf("ApplyChanges: %v", err)
+		}
+		// A stream that moved the copy doesn't apply twice
+		if _, err := gdbx.ApplyChanges(f, bytes.NewReader(stream.Bytes())); to != since && gdbx.Code(err) != gdbx.ErrIncompatible {
+			t.Fatalf("applying a stream twice: got %v, want ErrIncompatible", err)
+		}
+		return to
+	}
+	var last uint64
+	for gen := 1; gen <= 3; gen++ {
+		last = write(gen)
+	}
+	if to := apply(base); to != last {
+		t.Fatalf("ApplyChanges led to txnid %d, want %d", to, last)
+	}
+	base = last
+	last = write(4)
+	if to := apply(base); to != last {
+		t.Fatalf("ApplyChanges led to txnid %d, want %d", to, last)
+	}
+	// Nothing changed since the last commit
+	if to := apply(last); to != last {
+		t.Fatalf("empty stream led to txnid %d, want %d", to, last)
+	}
+
+	// Commits that fell out of the history can't be streamed
+	for gen := 5; gen < 15; gen++ {
+		write(gen)
+	}
+	if err := env.StreamChanges(last, &bytes.Buffer{}); !gdbx.IsNotFound(err) {
+		t.Fatalf("StreamChanges past the history: got %v, want not found", err)
+	}
+	if err := env.StreamChanges(1<<40, &bytes.Buffer{}); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("StreamChanges from a future txnid: got %v, want ErrInvalid", err)
+	}
+
+	// The copy matches the source as of the last applied commit, which the
+	// source reached before the commits above
+	want := map[string]string{}
+	dst, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst.SetMaxDBs(10)
+	if err := dst.Open(dstPath, gdbx.NoSubdir|gdbx.ReadOnly, 0644); err != nil {
+		dst.Close()
+		t.Fatal(err)
+	}
+	err = dst.View(func(txn *gdbx.Txn) error {
+		if txn.ID() != last {
+			return fmt.Errorf("copy at txnid %d, want %d", txn.ID(), last)
+		}
+		for _, name := range []string{"plain", "dups"} {
+			dbi, err := txn.OpenDBISimple(name, 0)
+			if err != nil {
+				return err
+			}
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			for k, v, err := cur.Get(nil, nil, gdbx.First); !gdbx.IsNotFound(err); k, v, err = cur.Get(nil, nil, gdbx.Next) {
+				if err != nil {
+					cur.Close()
+					return err
+				}
+				want[name+"/"+string(k)+"/"+string(v)] = ""
+			}
+			cur.Close()
+		}
+		return nil
+	})
+	dst.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) == 0 {
+		t.Fatal("the copy is empty")
+	}
+
+	// Rebuild the expected contents from generations 0..4
+	expect := map[string]string{}
+	plain := map[string]string{}
+	for gen := 0; gen <= 4; gen++ {
+		for i := 0; i < 500; i++ {
+			k := fmt.Sprintf("k%04d", i)
+			switch {
+			case i%50 == 0:
+				plain[k] = string(big(i, gen))
+			case i%7 == gen%7:
+				delete(plain, k)
+			default:
+				plain[k] = fmt.Sprintf("v%d-%d", i, gen)
+			}
+		}
+		for i := 0; i < 300; i++ {
+			expect[fmt.Sprintf("dups/d%d/%d-%05d", gen%3, gen, i)] = ""
+		}
+	}
+	for k, v := range plain {
+		expect["plain/"+k+"/"+v] = ""
+	}
+	if len(want) != len(expect) {
+		t.Fatalf("copy holds %d entries, want %d", len(want), len(expect))
+	}
+	for k := range expect {
+		if _, ok := want[k]; !ok {
+			t.Fatalf("copy is missing %.40q", k)
+		}
+	}
+
+	// libmdbx opens the updated copy
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(dstPath, mdbx.NoSubdir|mdbx.Readonly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = menv.View(func(mtxn *mdbx.Txn) error {
+		mdbi, err := mtxn.OpenDBI("plain", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		stat, err := mtxn.StatDBI(mdbi)
+		if err != nil {
+			return err
+		}
+		if stat.Entries != uint64(len(plain)) {
+			return fmt.Errorf("libmdbx sees %d entries, want %d", stat.Entries, len(plain))
+		}
+		v, err := mtxn.Get(mdbi, []byte("k0050"))
+		if err != nil || !bytes.Equal(v, big(50, 4)) {
+			return fmt.Errorf("libmdbx Get(k0050): %d bytes, %v", len(v), err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("libmdbx: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCloneTo clones a database while a read transaction holds an old
+// snapshot, checks that the clone holds the last commit and passes Verify,
+// and that writes to either side don't show in the other. It then clones
+// while another goroutine commits as fast as it can, and checks that each
+// clone opens at a whole generation. A second clone to the same path is
+// refused.
+func TestCloneTo(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	// putGen rewrites every key with generation gen
+	putGen := func(env *gdbx.Env, gen int) error {
+		return env.Update(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("t", gdbx.Create)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 500; i++ {
+				if err := txn.Put(dbi, []byte(fmt.Sprintf("k%04d", i)), []byte(fmt.Sprintf("v%d-%d", gen, i)), 0); err != nil {
+					return err
+				}
+			}
+			return txn.Put(dbi, []byte("gen"), []byte(fmt.Sprint(gen)), 0)
+		})
+	}
+	// genOf returns the generation env holds, checking every key has it
+	genOf := func(env *gdbx.Env) (gen int, err error) {
+		err = env.View(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("t", 0)
+			if err != nil {
+				return err
+			}
+			v, err := txn.Get(dbi, []byte("gen"))
+			if err != nil {
+				return err
+			}
+			fmt.Sscan(string(v), &gen)
+			for i := 0; i < 500; i++ {
+				v, err := txn.Get(dbi, []byte(fmt.Sprintf("k%04d", i)))
+				if err != nil || string(v) != fmt.Sprintf("v%d-%d", gen, i) {
+					return fmt.Errorf("k%04d = %q, %v in generation %d", i, v, err, gen)
+				}
+			}
+			return nil
+		})
+		return gen, err
+	}
+
+	if err := putGen(env, 1); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := putGen(env, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	clonePath := filepath.Join(db.path, "clone")
+	clone, err := env.CloneTo(clonePath)
+	reader.Abort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.Close()
+	if gen, err := genOf(clone); err != nil || gen != 2 {
+		t.Fatalf("clone at generation %d, %v, want 2", gen, err)
+	}
+	if err := clone.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := putGen(clone, 3); err != nil {
+		t.Fatal(err)
+	}
+	if gen, err := genOf(env); err != nil || gen != 2 {
+		t.Fatalf("source at generation %d, %v after writing the clone, want 2", gen, err)
+	}
+	if err := putGen(env, 4); err != nil {
+		t.Fatal(err)
+	}
+	if gen, err := genOf(clone); err != nil || gen != 3 {
+		t.Fatalf("clone at generation %d, %v after writing the source, want 3", gen, err)
+	}
+
+	if again, err := env.CloneTo(clonePath); gdbx.Code(err) != gdbx.ErrInvalid {
+		if again != nil {
+			again.Close()
+		}
+		t.Fatalf("CloneTo onto an existing clone = %v, want ErrInvalid", err)
+	}
+
+	t.Run("WhileWriting", func(t *testing.T) {
+		var stop atomic.Bool
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for gen := 10; !stop.Load(); gen++ {
+				if err := putGen(env, gen); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		defer func() {
+			stop.Store(true)
+			wg.Wait()
+		}()
+
+		for i := 0; i < 10; i++ {
+			clone, err := env.CloneTo(filepath.Join(db.path, fmt.Sprintf("busy%d", i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = genOf(clone)
+			if err == nil {
+				err = clone.Verify()
+			}
+			clone.Close()
+			if err != nil {
+				t.Fatalf("clone %d: %v", i, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestClusteredPuts puts runs of keys sharing a prefix, which land on the
+// same leaf one after another, interleaved with deletes and with puts
+// through a second cursor that split and merge the leaves under the first.
+// Both a plain and a DupSort table must match a model of their contents, in
+// the transaction, after the commit and in libmdbx.
+func TestClusteredPuts(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	rng := rand.New(rand.NewSource(7))
+	tables := []struct {
+		name  string
+		flags uint
+	}{{"plain", 0}, {"dups", gdbx.DupSort}}
+	models := make([]map[string][]string, len(tables))
+	dbis := make([]gdbx.DBI, len(tables))
+
+	check := func(txn *gdbx.Txn, ti int) {
+		t.Helper()
+		cur, err := txn.OpenCursor(dbis[ti])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		keys := make([]string, 0, len(models[ti]))
+		for k := range models[ti] {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		i, j := 0, 0
+		k, v, err := cur.Get(nil, nil, gdbx.First)
+		for ; err == nil; k, v, err = cur.Get(nil, nil, gdbx.Next) {
+			if i >= len(keys) {
+				t.Fatalf("%s: extra pair %q=%q", tables[ti].name, k, v)
+			}
+			vals := models[ti][keys[i]]
+			if string(k) != keys[i] || string(v) != vals[j] {
+				t.Fatalf("%s: got %q=%q, want %q=%q", tables[ti].name, k, v, keys[i], vals[j])
+			}
+			if j++; j == len(vals) {
+				i, j = i+1, 0
+			}
+		}
+		if !gdbx.IsNotFound(err) || i != len(keys) {
+			t.Fatalf("%s: scan stopped at key %d of %d: %v", tables[ti].name, i, len(keys), err)
+		}
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	for ti, tb := range tables {
+		if dbis[ti], err = txn.OpenDBISimple(tb.name, gdbx.Create|tb.flags); err != nil {
+			t.Fatal(err)
+		}
+		models[ti] = make(map[string][]string)
+	}
+	other := make([]*gdbx.Cursor, len(tables))
+	for ti := range tables {
+		if other[ti], err = txn.OpenCursor(dbis[ti]); err != nil {
+			t.Fatal(err)
+		}
+		defer other[ti].Close()
+	}
+
+	put := func(ti int, k, v string, viaCursor bool) {
+		t.Helper()
+		var err error
+		if viaCursor {
+			err = other[ti].Put([]byte(k), []byte(v), 0)
+		} else {
+			err = txn.Put(dbis[ti], []byte(k), []byte(v), 0)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals := models[ti][k]
+		if tables[ti].flags&gdbx.DupSort == 0 {
+			vals = nil
+		}
+		if i, found := slices.BinarySearch(vals, v); !found {
+			vals = slices.Insert(vals, i, v)
+		}
+		models[ti][k] = vals
+	}
+
+	for round := 0; round < 300; round++ {
+		ti := rng.Intn(len(tables))
+		prefix := fmt.Sprintf("p%05d", rng.Intn(2000))
+		for _, i := range rng.Perm(40) {
+			k := fmt.Sprintf("%s/%03d", prefix, i)
+			put(ti, k, fmt.Sprintf("v%d-%s", rng.Intn(3), bytes.Repeat([]byte{'x'}, rng.Intn(60))), false)
+			switch rng.Intn(10) {
+			case 0:
+				// Another cursor grows the tree elsewhere, or next door
+				put(ti, fmt.Sprintf("p%05d/%03d", rng.Intn(2000), rng.Intn(40)), "other", true)
+			case 1:
+				// A delete may empty the leaf and merge it away
+				for dk := range models[ti] {
+					if err := txn.Del(dbis[ti], []byte(dk), nil); err != nil {
+						t.Fatal(err)
+					}
+					delete(models[ti], dk)
+					break
+				}
+			}
+		}
+	}
+	for ti := range tables {
+		check(txn, ti)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *gdbx.Txn) error {
+		for ti := range tables {
+			check(txn, ti)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, mdbx.Readonly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = menv.View(func(txn *mdbx.Txn) error {
+		for ti, tb := range tables {
+			dbi, err := txn.OpenDBI(tb.name, 0, nil, nil)
+			if err != nil {
+				return err
+			}
+			stat, err := txn.StatDBI(dbi)
+			if err != nil {
+				return err
+			}
+			want := 0
+			for _, vals := range models[ti] {
+				want += len(vals)
+			}
+			if stat.Entries != uint64(want) {
+				return fmt.Errorf("%s: libmdbx counts %d entries, want %d", tb.name, stat.Entries, want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
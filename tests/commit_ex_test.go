@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCommitEx checks that CommitEx reports the old and new roots of the
+// databases a commit changed, and only those: a written table, one created
+// empty-rooted, the main database holding their records, and not a table
+// left alone.
+func TestCommitEx(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	var a, b gdbx.DBI
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if a, err = txn.OpenDBISimple("a", gdbx.Create); err != nil {
+			return err
+		}
+		if b, err = txn.OpenDBISimple("b", gdbx.Create); err != nil {
+			return err
+		}
+		for _, dbi := range []gdbx.DBI{a, b} {
+			if err := txn.Put(dbi, []byte("k"), []byte("v"), 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	root := func(txn *gdbx.Txn, dbi gdbx.DBI) uint32 {
+		t.Helper()
+		info, err := txn.TreeInfo(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Root
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	oldA, oldMain := root(txn, a), root(txn, gdbx.MainDBI)
+	if err := txn.Put(a, []byte("k2"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	c, err := txn.OpenDBISimple("c", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Put(c, []byte("k"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := txn.CommitEx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	for dbi, want := range map[gdbx.DBI]gdbx.RootChange{
+		a:            {OldRoot: oldA, NewRoot: root(rtxn, a)},
+		c:            {OldRoot: 0xFFFFFFFF, NewRoot: root(rtxn, c)},
+		gdbx.MainDBI: {OldRoot: oldMain, NewRoot: root(rtxn, gdbx.MainDBI)},
+	} {
+		if got, ok := roots[dbi]; !ok || got != want {
+			t.Errorf("DBI %d: got %+v (%v), want %+v", dbi, got, ok, want)
+		}
+		if want.OldRoot == want.NewRoot {
+			t.Errorf("DBI %d: root %d did not change", dbi, want.NewRoot)
+		}
+	}
+	if got, ok := roots[b]; ok {
+		t.Errorf("untouched table reported: %+v", got)
+	}
+	for dbi := range roots {
+		if dbi != a && dbi != c && dbi != gdbx.MainDBI && dbi != gdbx.FreeDBI {
+			t.Errorf("unexpected DBI %d reported", dbi)
+		}
+	}
+
+	// A read-only transaction changes nothing
+	rtxn2, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots, err := rtxn2.CommitEx(); err != nil || len(roots) != 0 {
+		t.Fatalf("read-only: %v, %v", roots, err)
+	}
+}
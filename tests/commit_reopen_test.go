@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"encoding/binary"
 	"os"
 	"path/filepath"
 	"testing"
@@ -23,6 +24,7 @@ func TestCommitReopenScenarios(t *testing.T) {
 	t.Run("AppendFlagBasic", testAppendFlagBasic)
 	t.Run("AppendFlagMultipleKeys", testAppendFlagMultipleKeys)
 	t.Run("AppendFlagWithDupSort", testAppendFlagWithDupSort)
+	t.Run("AppendFlagIntegerKey", testAppendFlagIntegerKey)
 }
 
 func testBasicWriteCommitReopen(t *testing.T) {
@@ -674,3 +676,68 @@ func makeTempDir(t *testing.T) (string, func()) {
 		os.RemoveAll(dir)
 	}
 }
+
+func testAppendFlagIntegerKey(t *testing.T) {
+	dir, cleanup := makeTempDir(t)
+	defer cleanup()
+
+	env, _ := gdbx.NewEnv(gdbx.Default)
+	env.SetMaxDBs(10)
+	env.Open(dir, 0, 0644)
+	defer env.Close()
+
+	key := func(n uint64) []byte {
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, n)
+		return k
+	}
+
+	txn, _ := env.BeginTxn(nil, 0)
+	dbi, _ := txn.OpenDBISimple("append_int", gdbx.Create|gdbx.IntegerKey)
+
+	// Sequential appends spanning many leaf splits
+	const numKeys = 20000
+	for i := uint64(0); i < numKeys; i++ {
+		if err := txn.Put(dbi, key(i*2), key(i), gdbx.Append); err != nil {
+			t.Fatalf("Append %d failed: %v", i*2, err)
+		}
+	}
+
+	// A plain Put above the max must invalidate the cached max key
+	if err := txn.Put(dbi, key(numKeys*4), key(0), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Put(dbi, key(numKeys*3), key(0), gdbx.Append); err == nil {
+		t.Error("Append below the last key should fail after a plain Put")
+	}
+
+	// Out-of-order appends must still be rejected
+	if err := txn.Put(dbi, key(1), key(0), gdbx.Append); err == nil {
+		t.Error("Append with out-of-order key should fail")
+	}
+	if err := txn.Put(dbi, key(numKeys*4+1), key(0), gdbx.Append); err != nil {
+		t.Errorf("Append after the new max failed: %v", err)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify ordering and count with a full scan
+	txn, _ = env.BeginTxn(nil, gdbx.TxnReadOnly)
+	defer txn.Abort()
+	cursor, _ := txn.OpenCursor(dbi)
+	defer cursor.Close()
+
+	count := 0
+	var prev []byte
+	for k, _, err := cursor.Get(nil, nil, gdbx.First); err == nil; k, _, err = cursor.Get(nil, nil, gdbx.Next) {
+		if prev != nil && bytes.Compare(prev, k) >= 0 {
+			t.Fatalf("keys out of order: %x then %x", prev, k)
+		}
+		prev = append(prev[:0], k...)
+		count++
+	}
+	if count != numKeys+2 {
+		t.Errorf("Count: got %d, want %d", count, numKeys+2)
+	}
+}
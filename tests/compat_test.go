@@ -38,6 +38,95 @@ func newTestDB(t *testing.T) *testDB {
 	}
 }
 
+// openGdbxEnv opens a fresh gdbx environment in dir with room for named databases.
+func openGdbxEnv(t *testing.T, dir string, flags uint) *gdbx.Env {
+	t.Helper()
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.SetMaxDBs(10)
+	if err := env.Open(dir, flags, 0644); err != nil {
+		env.Close()
+		t.Fatal(err)
+	}
+	return env
+}
+
+// genFixture writes and checks generations of data in the tables t and
+// dups. Each generation rewrites the keys of the ones before it and adds keys
+// of its own, with bigs big values and dups duplicates.
+type genFixture struct {
+	keys, bigs, dups int
+}
+
+// put writes generation gen in txn.
+func (g genFixture) put(txn *gdbx.Txn, gen int) error {
+	dbi, err := txn.OpenDBISimple("t", gdbx.Create)
+	if err != nil {
+		return err
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < g.keys*(gen+1); i++ {
+		if err := txn.Put(dbi, []byte(fmt.Sprintf("k%06d", i)), []byte(fmt.Sprintf("v%d-%d", gen, i)), 0); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < g.bigs; i++ {
+		if err := txn.Put(dbi, []byte(fmt.Sprintf("big%03d-%02d", gen, i)), bytes.Repeat([]byte{byte(gen)}, 20000), 0); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < g.dups; i++ {
+		if err := txn.Put(dups, []byte(fmt.Sprintf("d%02d", i%10)), []byte(fmt.Sprintf("%03d-%04d", gen, i)), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check checks that txn sees generations 0 to last, and none after.
+func (g genFixture) check(txn *gdbx.Txn, last int) error {
+	dbi, err := txn.OpenDBISimple("t", 0)
+	if err != nil {
+		return err
+	}
+	dups, err := txn.OpenDBISimple("dups", 0)
+	if err != nil {
+		return err
+	}
+	stat, err := txn.Stat(dbi)
+	if err != nil {
+		return err
+	}
+	if want := uint64((g.keys + g.bigs) * (last + 1)); stat.Entries != want {
+		return fmt.Errorf("%d entries, want %d", stat.Entries, want)
+	}
+	for i := 0; i < g.keys*(last+1); i++ {
+		v, err := txn.Get(dbi, []byte(fmt.Sprintf("k%06d", i)))
+		if err != nil || string(v) != fmt.Sprintf("v%d-%d", last, i) {
+			return fmt.Errorf("k%06d = %q, %v, want generation %d", i, v, err, last)
+		}
+	}
+	for gen := 0; gen <= last && g.bigs > 0; gen++ {
+		v, err := txn.Get(dbi, []byte(fmt.Sprintf("big%03d-00", gen)))
+		if err != nil || !bytes.Equal(v, bytes.Repeat([]byte{byte(gen)}, 20000)) {
+			return fmt.Errorf("big value of generation %d: %d bytes, %v", gen, len(v), err)
+		}
+	}
+	dstat, err := txn.Stat(dups)
+	if err != nil {
+		return err
+	}
+	if want := uint64(g.dups * (last + 1)); dstat.Entries != want {
+		return fmt.Errorf("%d duplicates, want %d", dstat.Entries, want)
+	}
+	return nil
+}
+
 // TestBasicReadWrite tests basic key-value operations
 func TestBasicReadWrite(t *testing.T) {
 	db := newTestDB(t)
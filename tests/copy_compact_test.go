@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCopyCompact fragments a DupSort table, with keys holding sub-trees and
+// sub-pages, and a plain table through random-order inserts and deletes over
+// many commits, then checks that a CopyCompact copy is smaller than the
+// source yet holds the same entries in the same order, the same sequences,
+// and stores each key's duplicates as their count calls for.
+func TestCopyCompact(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	rng := rand.New(rand.NewSource(1))
+	dupValue := func(i int) []byte { return []byte(fmt.Sprintf("value%06d", i)) }
+	for round := 0; round < 40; round++ {
+		err := env.Update(func(txn *gdbx.Txn) error {
+			dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+			if err != nil {
+				return err
+			}
+			plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 500; i++ {
+				// Few keys with many values, many keys with a few
+				key := fmt.Sprintf("big%02d", rng.Intn(5))
+				if rng.Intn(2) == 0 {
+					key = fmt.Sprintf("small%04d", rng.Intn(400))
+				}
+				v := dupValue(rng.Intn(100000))
+				if rng.Intn(4) == 0 {
+					if err := txn.Del(dups, []byte(key), v); err != nil && !gdbx.IsNotFound(err) {
+						return err
+					}
+				} else if err := txn.Put(dups, []byte(key), v, 0); err != nil {
+					return err
+				}
+				pk := []byte(fmt.Sprintf("plain%06d", rng.Intn(20000)))
+				if err := txn.Put(plain, pk, bytes.Repeat(pk, 1+rng.Intn(8)), 0); err != nil {
+					return err
+				}
+			}
+			if _, err := txn.Sequence(dups, 3); err != nil {
+				return err
+			}
+			return txn.Put(gdbx.MainDBI, []byte("zz-main"), []byte(fmt.Sprint(round)), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "compact.dat")
+	if err := env.Copy(dst, gdbx.CopyCompact); err != nil {
+		t.Fatal(err)
+	}
+	srcInfo, err := os.Stat(filepath.Join(db.path, gdbx.DataFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dstInfo.Size() >= srcInfo.Size() {
+		t.Fatalf("compacted copy has %d bytes, source %d", dstInfo.Size(), srcInfo.Size())
+	}
+
+	// CopyFD writes the same compacted file
+	f, err := os.Create(dst + ".fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := env.CopyFD(f.Fd(), gdbx.CopyCompact); err != nil {
+		t.Fatal(err)
+	}
+	fdInfo, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fdInfo.Size() != dstInfo.Size() {
+		t.Fatalf("CopyFD wrote %d bytes, Copy %d", fdInfo.Size(), dstInfo.Size())
+	}
+
+	cenv, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cenv.Close()
+	cenv.SetMaxDBs(10)
+	if err := cenv.Open(dst, gdbx.NoSubdir|gdbx.ReadOnly, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stxn.Abort()
+	ctxn, err := cenv.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctxn.Abort()
+
+	for _, name := range []string{"", "dups", "plain"} {
+		sdbi, cdbi := gdbx.DBI(gdbx.MainDBI), gdbx.DBI(gdbx.MainDBI)
+		if name != "" {
+			if sdbi, err = stxn.OpenDBISimple(name, 0); err != nil {
+				t.Fatal(err)
+			}
+			if cdbi, err = ctxn.OpenDBISimple(name, 0); err != nil {
+				t.Fatalf("%s missing from the copy: %v", name, err)
+			}
+		}
+		sflags, _ := stxn.Flags(sdbi)
+		cflags, _ := ctxn.Flags(cdbi)
+		if sflags != cflags {
+			t.Fatalf("%q: copy has flags %#x, source %#x", name, cflags, sflags)
+		}
+		sseq, _ := stxn.Sequence(sdbi, 0)
+		cseq, _ := ctxn.Sequence(cdbi, 0)
+		if sseq != cseq {
+			t.Fatalf("%q: copy has sequence %d, source %d", name, cseq, sseq)
+		}
+
+		scur, err := stxn.OpenCursor(sdbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ccur, err := ctxn.OpenCursor(cdbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		sk, sv, serr := scur.Get(nil, nil, gdbx.First)
+		ck, cv, cerr := ccur.Get(nil, nil, gdbx.First)
+		for serr == nil && cerr == nil {
+			if name == "" {
+				// Named tables' records hold their own root pages
+				if info, _ := scur.CurrentNodeInfo(); info.Flags&gdbx.NodeTree != 0 {
+					sv, cv = nil, nil
+				}
+			}
+			if !bytes.Equal(sk, ck) || !bytes.Equal(sv, cv) {
+				t.Fatalf("%q: entry %d is %q=%q in the copy, %q=%q in the source", name, n, ck, cv, sk, sv)
+			}
+			if name == "dups" {
+				info, err := ccur.CurrentNodeInfo()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bytes.HasPrefix(ck, []byte("big")) && info.Flags&gdbx.NodeTree == 0 {
+					t.Fatalf("%q with %d values isn't a sub-tree in the copy", ck, info.Count)
+				}
+				if bytes.HasPrefix(ck, []byte("small")) && info.Flags&gdbx.NodeTree != 0 {
+					t.Fatalf("%q with %d values is a sub-tree in the copy", ck, info.Count)
+				}
+			}
+			n++
+			sk, sv, serr = scur.Get(nil, nil, gdbx.Next)
+			ck, cv, cerr = ccur.Get(nil, nil, gdbx.Next)
+		}
+		if !gdbx.IsNotFound(serr) || !gdbx.IsNotFound(cerr) {
+			t.Fatalf("%q: iteration ended after %d entries with %v in the source, %v in the copy", name, n, serr, cerr)
+		}
+		scur.Close()
+		ccur.Close()
+	}
+}
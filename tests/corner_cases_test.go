@@ -189,15 +189,18 @@ func testDupOpsOnPlainTable(t *testing.T, genv *gdbx.Env, menv *mdbxgo.Env) {
 	gk1, gv1, gerr1 := gc.Get([]byte("b"), []byte("2"), gdbx.GetBoth)
 	mk1, mv1, merr1 := mc.Get([]byte("b"), []byte("2"), mdbxgo.GetBoth)
 	t.Logf("GetBoth on plain: gdbx=(%q,%q,%v), mdbx=(%q,%q,%v)", gk1, gv1, gerr1, mk1, mv1, merr1)
-	// Note: gdbx allows GetBoth on plain tables (works like Set if value matches)
-	// mdbx returns MDBX_INCOMPATIBLE. This is a behavioral difference.
-	t.Log("Note: gdbx is more permissive - allows GetBoth on plain tables")
+	// Both return MDBX_INCOMPATIBLE on a plain table
+	if gdbx.Code(gerr1) != gdbx.ErrIncompatible {
+		t.Errorf("gdbx GetBoth on plain: got %v, want ErrIncompatible", gerr1)
+	}
 
 	// Try GetBothRange on plain table
 	gk2, gv2, gerr2 := gc.Get([]byte("b"), []byte("1"), gdbx.GetBothRange)
 	mk2, mv2, merr2 := mc.Get([]byte("b"), []byte("1"), mdbxgo.GetBothRange)
 	t.Logf("GetBothRange on plain: gdbx=(%q,%q,%v), mdbx=(%q,%q,%v)", gk2, gv2, gerr2, mk2, mv2, merr2)
-	// Same - gdbx allows it, mdbx returns INCOMPATIBLE
+	if gdbx.Code(gerr2) != gdbx.ErrIncompatible {
+		t.Errorf("gdbx GetBothRange on plain: got %v, want ErrIncompatible", gerr2)
+	}
 
 	gc.Close()
 	mc.Close()
@@ -402,8 +405,9 @@ func testGetBothRangeOnPlain(t *testing.T, genv *gdbx.Env, menv *mdbxgo.Env) {
 	mk, mv, merr := mc.Get([]byte("key"), []byte("val"), mdbxgo.GetBothRange)
 
 	t.Logf("GetBothRange on plain: gdbx=(%q,%q,%v), mdbx=(%q,%q,%v)", gk, gv, gerr, mk, mv, merr)
-	// Note: gdbx allows GetBothRange on plain tables, mdbx returns INCOMPATIBLE
-	t.Log("Note: gdbx is more permissive - allows GetBothRange on plain tables")
+	if gdbx.Code(gerr) != gdbx.ErrIncompatible {
+		t.Errorf("gdbx GetBothRange on plain: got %v, want ErrIncompatible", gerr)
+	}
 
 	gc.Close()
 	mc.Close()
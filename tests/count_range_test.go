@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCountRange checks CountRange and CountRangeValues against counting by
+// iteration, over random ranges of a table several levels deep and of a
+// DupSort table whose keys hold one value, a sub-page or a sub-tree, in a
+// write transaction with uncommitted changes and in a read transaction.
+func TestCountRange(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	key := func(n int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(n)) }
+	var plain, dups, empty gdbx.DBI
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if empty, err = txn.OpenDBISimple("empty", gdbx.Create); err != nil {
+			return err
+		}
+		if plain, err = txn.OpenDBISimple("plain", gdbx.Create); err != nil {
+			return err
+		}
+		if dups, err = txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort); err != nil {
+			return err
+		}
+		for i := 0; i < 100000; i += 2 {
+			if err := txn.Put(plain, key(i), make([]byte, 50), 0); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < 2000; i += 2 {
+			values := 1
+			switch i % 10 {
+			case 2:
+				values = 20
+			case 4:
+				values = 500
+			}
+			for v := 0; v < values; v++ {
+				if err := txn.Put(dups, key(i), key(v), 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	check := func(txn *gdbx.Txn, dbi gdbx.DBI, limit int) {
+		t.Helper()
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		scan, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer scan.Close()
+
+		for r := 0; r < 50; r++ {
+			var low, high []byte
+			if r%7 != 0 {
+				low = key(rng.Intn(limit))
+			}
+			if r%5 != 0 {
+				high = key(rng.Intn(limit))
+			}
+			var keys, values uint64
+			k, _, err := scan.Get(low, nil, gdbx.SetRange)
+			if low == nil {
+				k, _, err = scan.Get(nil, nil, gdbx.First)
+			}
+			for ; err == nil && (high == nil || string(k) < string(high)); k, _, err = scan.Get(nil, nil, gdbx.NextNoDup) {
+				n, err := scan.Count()
+				if err != nil {
+					t.Fatal(err)
+				}
+				keys++
+				values += n
+			}
+			if err != nil && !gdbx.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if n, err := cur.CountRange(low, high); err != nil || n != keys {
+				t.Fatalf("CountRange(%x, %x) = %d, %v, want %d", low, high, n, err, keys)
+			}
+			if n, err := cur.CountRangeValues(low, high); err != nil || n != values {
+				t.Fatalf("CountRangeValues(%x, %x) = %d, %v, want %d", low, high, n, err, values)
+			}
+		}
+		if n, err := cur.CountRange(key(10), key(10)); err != nil || n != 0 {
+			t.Fatalf("empty range: %d, %v", n, err)
+		}
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	for i := 1; i < 100000; i += 20 {
+		if err := txn.Put(plain, key(i), nil, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 100000; i += 6 {
+		if err := txn.Del(plain, key(i), nil); err != nil && !gdbx.IsNotFound(err) {
+			t.Fatal(err)
+		}
+	}
+	if err := txn.Del(dups, key(4), nil); err != nil {
+		t.Fatal(err)
+	}
+	check(txn, plain, 110000)
+	check(txn, dups, 2200)
+	txn.Abort()
+
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	check(rtxn, plain, 110000)
+	check(rtxn, dups, 2200)
+
+	cur, err := rtxn.OpenCursor(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	if n, err := cur.CountRange(nil, nil); err != nil || n != 0 {
+		t.Fatalf("empty table: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestReopenAfterCrash has another process commit a generation of data and
+// die inside a second write transaction, after writing enough for its dirty
+// pages to reach the file, with and without WriteMap. Reopening must find
+// the first generation whole and no trace of the second; the pages the
+// second wrote must be free again, so that the database has not grown when
+// the same transaction commits after the reopen; and the database must pass
+// Verify throughout.
+func TestReopenAfterCrash(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{{"Default", 0}, {"WriteMap", gdbx.WriteMap}} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			defer db.cleanup()
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestReopenAfterCrashChild$")
+			cmd.Env = append(os.Environ(), "GDBX_CRASH_PATH="+db.path, "GDBX_CRASH_FLAGS="+strconv.FormatUint(uint64(tc.flags), 10))
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("writer: %v\n%s", err, out)
+			}
+			// The child reports the last page its commit used
+			var committed int64 = -1
+			for _, line := range strings.Split(string(out), "\n") {
+				if s, ok := strings.CutPrefix(line, "last page "); ok {
+					committed, _ = strconv.ParseInt(s, 10, 64)
+				}
+			}
+			if committed < 0 {
+				t.Fatalf("writer did not report its last page:\n%s", out)
+			}
+
+			env := openGdbxEnv(t, db.path, tc.flags|gdbx.Validation)
+			defer env.Close()
+			info, err := env.Info(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.LastPgNo != committed {
+				t.Fatalf("last page after the crash %d, want the commit's %d", info.LastPgNo, committed)
+			}
+			if err := env.View(func(txn *gdbx.Txn) error { return crashGens.check(txn, 0) }); err != nil {
+				t.Fatalf("after the crash: %v", err)
+			}
+
+			// The aborted transaction, committed this time, takes no more
+			// room than on a database that never crashed
+			if err := env.Update(func(txn *gdbx.Txn) error { return crashGens.put(txn, 1) }); err != nil {
+				t.Fatal(err)
+			}
+			if err := env.View(func(txn *gdbx.Txn) error { return crashGens.check(txn, 1) }); err != nil {
+				t.Fatal(err)
+			}
+			if err := env.Verify(); err != nil {
+				t.Fatal(err)
+			}
+			ref := newTestDB(t)
+			defer ref.cleanup()
+			renv := openGdbxEnv(t, ref.path, tc.flags)
+			defer renv.Close()
+			for gen := 0; gen <= 1; gen++ {
+				if err := renv.Update(func(txn *gdbx.Txn) error { return crashGens.put(txn, gen) }); err != nil {
+					t.Fatal(err)
+				}
+			}
+			after, err := env.Info(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := renv.Info(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.LastPgNo != want.LastPgNo {
+				t.Fatalf("last page %d after committing again, %d without the crash", after.LastPgNo, want.LastPgNo)
+			}
+		})
+	}
+}
+
+// TestReopenAfterCrashChild is the writer process of TestReopenAfterCrash.
+// It commits generation 0 and prints the last page in use, then writes
+// generation 1 and exits without committing or closing the environment.
+func TestReopenAfterCrashChild(t *testing.T) {
+	path := os.Getenv("GDBX_CRASH_PATH")
+	if path == "" {
+		t.Skip("only run by TestReopenAfterCrash")
+	}
+	flags, err := strconv.ParseUint(os.Getenv("GDBX_CRASH_FLAGS"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := openGdbxEnv(t, path, uint(flags))
+	if err := env.Update(func(txn *gdbx.Txn) error { return crashGens.put(txn, 0) }); err != nil {
+		t.Fatal(err)
+	}
+	info, err := env.Info(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("last page %d\n", info.LastPgNo)
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crashGens.put(txn, 1); err != nil {
+		t.Fatal(err)
+	}
+	os.Exit(0)
+}
+
+// crashGens are the generations TestReopenAfterCrash writes: the first
+// committed, the second cut short by the crash.
+var crashGens = genFixture{keys: 5000, bigs: 20, dups: 2000}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCursorBounds checks forward and backward scans of bounded cursors against
+// the expected slice of an unbounded scan, for plain and DupSort tables.
+func TestCursorBounds(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Even keys only, so odd bounds fall between keys
+	for i := 0; i < 1000; i += 2 {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		if err := txn.Put(plain, key, []byte("v"), 0); err != nil {
+			t.Fatal(err)
+		}
+		for d := 0; d < 1+i%3; d++ {
+			if err := txn.Put(dups, key, []byte(fmt.Sprintf("d%d", d)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%04d", i)) }
+	ranges := []struct {
+		name      string
+		low, high []byte
+	}{
+		{"low-only", key(501), nil},
+		{"high-only", nil, key(300)},
+		{"on-keys", key(100), key(200)},
+		{"between-keys", key(101), key(199)},
+		{"single", key(400), key(401)},
+		{"empty", key(401), key(402)},
+		{"inverted", key(600), key(500)},
+		{"beyond-end", []byte("key9"), nil},
+		{"before-start", nil, []byte("a")},
+	}
+
+	for _, dbi := range []gdbx.DBI{plain, dups} {
+		// Reference: every pair in order
+		var all [][2][]byte
+		scan, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v, err := scan.Get(nil, nil, gdbx.First); err == nil; k, v, err = scan.Get(nil, nil, gdbx.Next) {
+			all = append(all, [2][]byte{append([]byte(nil), k...), append([]byte(nil), v...)})
+		}
+		scan.Close()
+
+		for _, r := range ranges {
+			t.Run(fmt.Sprintf("dbi%d/%s", dbi, r.name), func(t *testing.T) {
+				var want [][2][]byte
+				for _, kv := range all {
+					if (r.low == nil || bytes.Compare(kv[0], r.low) >= 0) && (r.high == nil || bytes.Compare(kv[0], r.high) < 0) {
+						want = append(want, kv)
+					}
+				}
+
+				c, err := txn.OpenCursor(dbi)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer c.Close()
+				c.SetBounds(r.low, r.high)
+
+				var fwd [][2][]byte
+				for k, v, err := c.Get(nil, nil, gdbx.First); err == nil; k, v, err = c.Get(nil, nil, gdbx.Next) {
+					fwd = append(fwd, [2][]byte{append([]byte(nil), k...), append([]byte(nil), v...)})
+				}
+				checkPairs(t, "forward", fwd, want)
+
+				// Prev after running off the end returns the last pair in range
+				k, v, err := c.Get(nil, nil, gdbx.Prev)
+				if len(want) == 0 {
+					if !gdbx.IsNotFound(err) {
+						t.Fatalf("Prev on empty range: %q, %v", k, err)
+					}
+				} else if err != nil || !bytes.Equal(k, want[len(want)-1][0]) || !bytes.Equal(v, want[len(want)-1][1]) {
+					t.Fatalf("Prev after EOF = %q/%q, %v; want %q/%q", k, v, err, want[len(want)-1][0], want[len(want)-1][1])
+				}
+
+				var bwd [][2][]byte
+				for k, v, err := c.Get(nil, nil, gdbx.Last); err == nil; k, v, err = c.Get(nil, nil, gdbx.Prev) {
+					bwd = append([][2][]byte{{append([]byte(nil), k...), append([]byte(nil), v...)}}, bwd...)
+				}
+				checkPairs(t, "backward", bwd, want)
+
+				// Positioning below low clamps SetRange; exact lookups outside the range miss
+				k, _, err = c.Get([]byte("a"), nil, gdbx.SetRange)
+				if len(want) == 0 {
+					if !gdbx.IsNotFound(err) {
+						t.Fatalf("SetRange on empty range: %q, %v", k, err)
+					}
+				} else if err != nil || !bytes.Equal(k, want[0][0]) {
+					t.Fatalf("SetRange below range = %q, %v; want %q", k, err, want[0][0])
+				}
+				if r.high != nil && len(all) > 0 {
+					if _, _, err := c.Get(all[len(all)-1][0], nil, gdbx.Set); bytes.Compare(all[len(all)-1][0], r.high) >= 0 && !gdbx.IsNotFound(err) {
+						t.Fatalf("Set above high: %v, want ErrNotFound", err)
+					}
+				}
+			})
+		}
+	}
+
+	// Clearing the bounds restores a full scan
+	c, err := txn.OpenCursor(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetBounds(key(10), key(20))
+	c.SetBounds(nil, nil)
+	k, _, err := c.Get(nil, nil, gdbx.Last)
+	if err != nil || !bytes.Equal(k, key(998)) {
+		t.Fatalf("Last after clearing bounds = %q, %v", k, err)
+	}
+}
+
+func checkPairs(t *testing.T, what string, got, want [][2][]byte) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d pairs, want %d", what, len(got), len(want))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i][0], want[i][0]) || !bytes.Equal(got[i][1], want[i][1]) {
+			t.Fatalf("%s: pair %d = %q/%q, want %q/%q", what, i, got[i][0], got[i][1], want[i][0], want[i][1])
+		}
+	}
+}
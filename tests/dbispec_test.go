@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestDBISpecMaxValue checks that a table opened with a DBISpec.MaxValue
+// refuses larger values from Put, cursor puts, PutReserve and GetWriter,
+// accepts values up to the limit, leaves other tables alone, and keeps the
+// policy across transactions until the table is opened with another spec.
+func TestDBISpecMaxValue(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	const limit = 100
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	small, err := txn.OpenDBIWithSpec("small", gdbx.DBISpec{Flags: gdbx.Create, MaxValue: limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := txn.OpenDBISimple("other", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := txn.Put(small, []byte("fits"), make([]byte, limit), 0); err != nil {
+		t.Fatalf("value at the limit: %v", err)
+	}
+	checkTooBig := func(what string, err error) {
+		t.Helper()
+		if gdbx.Code(err) != gdbx.ErrBadValSize {
+			t.Fatalf("%s: got %v, want ErrBadValSize", what, err)
+		}
+	}
+	checkTooBig("Put", txn.Put(small, []byte("big"), make([]byte, limit+1), 0))
+	checkTooBig("whole file", txn.Put(small, []byte("big"), make([]byte, 1<<20), 0))
+	_, err = txn.PutReserve(small, []byte("big"), limit+1, 0)
+	checkTooBig("PutReserve", err)
+	_, err = txn.GetWriter(small, []byte("big"), 1<<20, 0)
+	checkTooBig("GetWriter", err)
+	cur, err := txn.OpenCursor(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTooBig("Cursor.Put", cur.Put([]byte("big"), make([]byte, limit+1), 0))
+	cur.Close()
+	if _, err := txn.Get(small, []byte("big")); !gdbx.IsNotFound(err) {
+		t.Fatalf("a refused value was stored: %v", err)
+	}
+	if err := txn.Put(other, []byte("big"), make([]byte, 1<<20), 0); err != nil {
+		t.Fatalf("table without a policy: %v", err)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The policy outlives the transaction that declared it
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	small, err = txn.OpenDBISimple("small", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTooBig("later transaction", txn.Put(small, []byte("big"), make([]byte, limit+1), 0))
+
+	// Opening with another spec replaces it
+	if small, err = txn.OpenDBIWithSpec("small", gdbx.DBISpec{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Put(small, []byte("big"), make([]byte, limit+1), 0); err != nil {
+		t.Fatalf("after lifting the limit: %v", err)
+	}
+
+	if _, err := txn.OpenDBIWithSpec("", gdbx.DBISpec{MaxValue: limit}); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("spec for the main table: got %v, want ErrInvalid", err)
+	}
+}
+
+// TestDBISpecBigEndianKeys fills a table opened with BigEndianKeys with
+// integers stored big-endian in every width from their shortest up to 8
+// bytes, among them values differing only in their high bytes, and checks
+// that they iterate and SetRange in numeric order, in this transaction and
+// after the Env is reopened with the spec, and that the option can't be
+// combined with a Cmp.
+func TestDBISpecBigEndianKeys(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	// n in width bytes, which must hold it
+	encode := func(n uint64, width int) []byte {
+		return binary.BigEndian.AppendUint64(nil, n)[8-width:]
+	}
+	decode := func(k []byte) uint64 {
+		var b [8]byte
+		copy(b[8-len(k):], k)
+		return binary.BigEndian.Uint64(b[:])
+	}
+	shortest := func(n uint64) int { return max(1, (bits.Len64(n)+7)/8) }
+
+	rng := rand.New(rand.NewSource(1))
+	values := []uint64{0, 1, 255, 256, 1 << 56, 1<<56 + 1, 2 << 56, 1<<63 | 1, ^uint64(0)}
+	for range 3000 {
+		values = append(values, rng.Uint64()>>(rng.Intn(8)*8))
+	}
+	type entry struct {
+		n     uint64
+		width int
+	}
+	var entries []entry
+	for _, n := range values {
+		w := shortest(n)
+		entries = append(entries, entry{n, w})
+		if n%3 == 0 && w < 8 {
+			entries = append(entries, entry{n, 8})
+		}
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		if a.n != b.n {
+			if a.n < b.n {
+				return -1
+			}
+			return 1
+		}
+		return a.width - b.width
+	})
+	entries = slices.Compact(entries)
+
+	spec := gdbx.DBISpec{Flags: gdbx.Create, BigEndianKeys: true}
+	check := func(env *gdbx.Env) {
+		t.Helper()
+		if err := env.View(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBIWithSpec("ints", spec)
+			if err != nil {
+				return err
+			}
+			c, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			i := 0
+			for k, v, err := c.Get(nil, nil, gdbx.First); err == nil; k, v, err = c.Get(nil, nil, gdbx.Next) {
+				if i >= len(entries) || decode(k) != entries[i].n || len(k) != entries[i].width || !bytes.Equal(v, k) {
+					t.Fatalf("entry %d is %x, want %d in %d bytes", i, k, entries[i].n, entries[i].width)
+				}
+				i++
+			}
+			if i != len(entries) {
+				t.Fatalf("iterated %d keys, want %d", i, len(entries))
+			}
+			for range 1000 {
+				target := rng.Uint64() >> (rng.Intn(8) * 8)
+				j, _ := slices.BinarySearchFunc(entries, target, func(e entry, n uint64) int {
+					if e.n < n {
+						return -1
+					} else if e.n > n {
+						return 1
+					}
+					return 0
+				})
+				k, _, err := c.Get(encode(target, 8), nil, gdbx.SetRange)
+				// An 8-byte target sorts after shorter keys of its value
+				for j < len(entries) && entries[j].n == target && entries[j].width < 8 {
+					j++
+				}
+				if j == len(entries) {
+					if !gdbx.IsNotFound(err) {
+						t.Fatalf("SetRange(%d) past the last key: %x, %v", target, k, err)
+					}
+					continue
+				}
+				if err != nil || decode(k) != entries[j].n || len(k) != entries[j].width {
+					t.Fatalf("SetRange(%d) = %x, %v, want %d in %d bytes", target, k, err, entries[j].n, entries[j].width)
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := openGdbxEnv(t, db.path, 0)
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		if _, err := txn.OpenDBIWithSpec("bad", gdbx.DBISpec{Flags: gdbx.Create, BigEndianKeys: true, Cmp: bytes.Compare}); gdbx.Code(err) != gdbx.ErrInvalid {
+			t.Fatalf("BigEndianKeys with a Cmp: got %v, want ErrInvalid", err)
+		}
+		dbi, err := txn.OpenDBIWithSpec("ints", spec)
+		if err != nil {
+			return err
+		}
+		for _, i := range rng.Perm(len(entries)) {
+			k := encode(entries[i].n, entries[i].width)
+			if err := txn.Put(dbi, k, k, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	check(env)
+	env.Close()
+
+	env = openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	check(env)
+}
@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestDelDupRange deletes value ranges under DupSort keys stored as a
+// sub-tree and as a sub-page. The sub-tree must turn back into a sub-page
+// once its values fit, emptied keys must disappear, neighbouring keys must
+// be left alone, and libmdbx must read the result.
+func TestDelDupRange(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	val := func(i int) []byte { return []byte(fmt.Sprintf("v%05d", i)) }
+	values := func(txn *gdbx.Txn, dbi gdbx.DBI, key string) []string {
+		t.Helper()
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		var got []string
+		_, v, err := cur.Get([]byte(key), nil, gdbx.Set)
+		for ; err == nil; _, v, err = cur.Get(nil, nil, gdbx.NextDup) {
+			got = append(got, string(v))
+		}
+		if !gdbx.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return got
+	}
+	flags := func(txn *gdbx.Txn, dbi gdbx.DBI, key string) gdbx.NodeFlags {
+		t.Helper()
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		if _, _, err := cur.Get([]byte(key), nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		info, err := cur.CurrentNodeInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Flags
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("sets", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "c"} {
+		for i := 0; i < 10; i++ {
+			if err := txn.Put(dbi, []byte(k), val(i), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		if err := txn.Put(dbi, []byte("b"), val(i), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f := flags(txn, dbi, "b"); f&gdbx.NodeTree == 0 {
+		t.Fatalf("b stored with flags %#x before the deletes, want a sub-tree", f)
+	}
+
+	// A range inside the sub-tree leaves few enough values for a sub-page
+	n, err := txn.DelDupRange(dbi, []byte("b"), val(30), val(1990))
+	if err != nil || n != 1960 {
+		t.Fatalf("DelDupRange(b) = %d, %v, want 1960", n, err)
+	}
+	if f := flags(txn, dbi, "b"); f != gdbx.NodeDup {
+		t.Fatalf("b stored with flags %#x after the delete, want a sub-page", f)
+	}
+	got := values(txn, dbi, "b")
+	if len(got) != 40 || got[29] != string(val(29)) || got[30] != string(val(1990)) {
+		t.Fatalf("b has %d values after the delete: %v...", len(got), got[:min(len(got), 3)])
+	}
+
+	// Open-ended ranges, down to a single value and to nothing
+	if n, err := txn.DelDupRange(dbi, []byte("b"), nil, val(1999)); err != nil || n != 39 {
+		t.Fatalf("DelDupRange(b, nil, v01999) = %d, %v, want 39", n, err)
+	}
+	if f := flags(txn, dbi, "b"); f != 0 {
+		t.Fatalf("b stored with flags %#x with one value left", f)
+	}
+	if n, err := txn.DelDupRange(dbi, []byte("b"), nil, nil); err != nil || n != 1 {
+		t.Fatalf("DelDupRange(b, nil, nil) = %d, %v, want 1", n, err)
+	}
+	if _, err := txn.Get(dbi, []byte("b")); !gdbx.IsNotFound(err) {
+		t.Fatalf("b after deleting all its values: %v", err)
+	}
+
+	// A range in a sub-page, and one that matches nothing
+	if n, err := txn.DelDupRange(dbi, []byte("a"), val(3), nil); err != nil || n != 7 {
+		t.Fatalf("DelDupRange(a, v00003, nil) = %d, %v, want 7", n, err)
+	}
+	if n, err := txn.DelDupRange(dbi, []byte("a"), val(5), val(9)); err != nil || n != 0 {
+		t.Fatalf("DelDupRange of an empty range = %d, %v", n, err)
+	}
+	if got := values(txn, dbi, "a"); len(got) != 3 {
+		t.Fatalf("a has %v", got)
+	}
+	if got := values(txn, dbi, "c"); len(got) != 10 {
+		t.Fatalf("c has %v", got)
+	}
+	stat, err := txn.Stat(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Entries != 13 {
+		t.Fatalf("%d entries, want 13", stat.Entries)
+	}
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.DelDupRange(plain, []byte("a"), nil, nil); gdbx.Code(err) != gdbx.ErrIncompatible {
+		t.Fatalf("DelDupRange on a plain table: got %v, want ErrIncompatible", err)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, mdbx.Readonly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = menv.View(func(txn *mdbx.Txn) error {
+		dbi, err := txn.OpenDBI("sets", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		stat, err := txn.StatDBI(dbi)
+		if err != nil {
+			return err
+		}
+		if stat.Entries != 13 {
+			return fmt.Errorf("libmdbx counts %d entries, want 13", stat.Entries)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDelDupRangeLowBound deletes a range starting between two values of a
+// key in a table whose cursor has just removed the key before it, so that
+// deleting the key's last value in range moves the cursor back. Only the
+// values in the range may go, for DupSort and DupFixed tables alike.
+func TestDelDupRangeLowBound(t *testing.T) {
+	for _, flags := range []uint{gdbx.DupSort, gdbx.DupSort | gdbx.DupFixed} {
+		db := newTestDB(t)
+		env := openGdbxEnv(t, db.path, 0)
+		err := env.Update(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("dups", gdbx.Create|flags)
+			if err != nil {
+				return err
+			}
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer cur.Close()
+			if err := cur.Put([]byte("k000"), []byte("00002545"), 0); err != nil {
+				return err
+			}
+			if err := txn.Put(dbi, []byte("k001"), []byte("00001633"), 0); err != nil {
+				return err
+			}
+			if err := txn.Del(dbi, []byte("k000"), nil); err != nil {
+				return err
+			}
+			if err := txn.Put(dbi, []byte("k001"), []byte("00001785"), 0); err != nil {
+				return err
+			}
+			n, err := txn.DelDupRange(dbi, []byte("k001"), []byte("00001680"), []byte("00002779"))
+			if err != nil {
+				return err
+			}
+			if n != 1 {
+				return fmt.Errorf("DelDupRange deleted %d values, want 1", n)
+			}
+			if v, err := txn.Get(dbi, []byte("k001")); err != nil || string(v) != "00001633" {
+				return fmt.Errorf("k001 holds %q (%v), want 00001633", v, err)
+			}
+			return nil
+		})
+		env.Close()
+		db.cleanup()
+		if err != nil {
+			t.Fatalf("flags %#x: %v", flags, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestDumpTree checks that DumpTree lists every page of a two-level tree
+// under its root, with entry counts and key ranges, shows DupSort sub-trees
+// below their leaf, and refuses a handle that isn't open.
+func TestDumpTree(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	var plain, dups gdbx.DBI
+	var stat *gdbx.Stat
+	err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if plain, err = txn.OpenDBISimple("plain", gdbx.Create); err != nil {
+			return err
+		}
+		if dups, err = txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort); err != nil {
+			return err
+		}
+		for i := 0; i < 500; i++ {
+			if err := txn.Put(plain, []byte(fmt.Sprintf("key%04d", i)), make([]byte, 40), 0); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < 2000; i++ {
+			if err := txn.Put(dups, []byte("many"), []byte(fmt.Sprintf("v%05d", i)), 0); err != nil {
+				return err
+			}
+		}
+		if err := txn.Put(dups, []byte{0x01, 0xff}, []byte("one"), 0); err != nil {
+			return err
+		}
+		stat, err = txn.Stat(plain)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Depth != 2 {
+		t.Fatalf("test tree has depth %d, want 2", stat.Depth)
+	}
+
+	var buf bytes.Buffer
+	if err := env.DumpTree(plain, &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if want := fmt.Sprintf("page %d branch %d entries [", stat.Root, stat.LeafPages); !strings.HasPrefix(lines[0], want) {
+		t.Fatalf("first line %q, want prefix %q", lines[0], want)
+	}
+	if uint64(len(lines)) != 1+stat.LeafPages {
+		t.Fatalf("%d lines for %d leaves:\n%s", len(lines), stat.LeafPages, buf.String())
+	}
+	entries := 0
+	for _, line := range lines[1:] {
+		var pg, n int
+		if _, err := fmt.Sscanf(line, "  page %d leaf %d entries", &pg, &n); err != nil {
+			t.Fatalf("leaf line %q: %v", line, err)
+		}
+		entries += n
+	}
+	if uint64(entries) != stat.Entries {
+		t.Fatalf("leaves hold %d entries, want %d", entries, stat.Entries)
+	}
+	if !strings.Contains(lines[1], `["key0000" .. "key`) || !strings.HasSuffix(lines[len(lines)-1], `.. "key0499"]`) {
+		t.Fatalf("key ranges missing from the leaves:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := env.DumpTree(dups, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`leaf 2 entries [0x01ff .. "many"]`,
+		`  sub-tree of "many", 2000 values`,
+		`branch`,
+		`.. "v01999"]`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("DupSort dump lacks %q:\n%s", want, out)
+		}
+	}
+
+	if err := env.DumpTree(gdbx.DBI(9), &buf); gdbx.Code(err) != gdbx.ErrBadDBI {
+		t.Fatalf("DumpTree of an unopened handle: got %v, want ErrBadDBI", err)
+	}
+}
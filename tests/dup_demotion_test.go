@@ -0,0 +1,334 @@
+package tests
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestDupSubTreeDemotion grows a key's duplicates into a sub-tree and
+// deletes most of them, one at a time with Txn.Del and while scanning with a
+// cursor. The values must end up inline again, the scan must see every
+// value once, and libmdbx must read the result.
+func TestDupSubTreeDemotion(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	val := func(i int) []byte { return []byte(fmt.Sprintf("value-%04d", i)) }
+	flags := func(txn *gdbx.Txn, dbi gdbx.DBI, key string) (gdbx.NodeFlags, uint64) {
+		t.Helper()
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		if _, _, err := cur.Get([]byte(key), nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		info, err := cur.CurrentNodeInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Flags, info.Count
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"del", "scan"} {
+		for i := 0; i < 200; i++ {
+			if err := txn.Put(dbi, []byte(k), val(i), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if f, _ := flags(txn, dbi, k); f&gdbx.NodeTree == 0 {
+			t.Fatalf("%s: 200 values stored with flags %#x, want a sub-tree", k, f)
+		}
+	}
+
+	// Deleting value by value demotes the sub-tree once the rest fits inline
+	for i := 0; i < 190; i++ {
+		if err := txn.Del(dbi, []byte("del"), val(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f, n := flags(txn, dbi, "del"); f != gdbx.NodeDup || n != 10 {
+		t.Fatalf("del: 10 values left stored with flags %#x, count %d, want a sub-page", f, n)
+	}
+
+	// A cursor deleting every other value keeps its place across the demotion
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	seen := 0
+	_, v, err := cur.Get([]byte("scan"), nil, gdbx.Set)
+	for ; err == nil; _, v, err = cur.Get(nil, nil, gdbx.NextDup) {
+		if string(v) != string(val(seen)) {
+			t.Fatalf("scan returned %s, want %s", v, val(seen))
+		}
+		if seen%20 != 0 {
+			if err := cur.Del(0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		seen++
+	}
+	if !gdbx.IsNotFound(err) || seen != 200 {
+		t.Fatalf("scan saw %d values, ended with %v", seen, err)
+	}
+	if f, n := flags(txn, dbi, "scan"); f != gdbx.NodeDup || n != 10 {
+		t.Fatalf("scan: 10 values left stored with flags %#x, count %d, want a sub-page", f, n)
+	}
+
+	// Down to one value the key is a plain node
+	for i := 190; i < 199; i++ {
+		if err := txn.Del(dbi, []byte("del"), val(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f, n := flags(txn, dbi, "del"); f != 0 || n != 1 {
+		t.Fatalf("del: one value left stored with flags %#x, count %d", f, n)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, mdbx.Readonly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = menv.View(func(txn *mdbx.Txn) error {
+		dbi, err := txn.OpenDBI("dups", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		if _, _, err := cur.Get([]byte("scan"), nil, mdbx.Set); err != nil {
+			return err
+		}
+		if n, err := cur.Count(); err != nil || n != 10 {
+			return fmt.Errorf("libmdbx counts %d values under scan, %v", n, err)
+		}
+		stat, err := txn.StatDBI(dbi)
+		if err != nil {
+			return err
+		}
+		if stat.Entries != 11 {
+			return fmt.Errorf("libmdbx counts %d entries, want 11", stat.Entries)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDupSubTreeHysteresis puts values under a key until they move to a
+// sub-tree, then deletes and puts back one value at a time around that
+// point. The sub-tree must stay until the values fit in about half the room,
+// and only then become a sub-page.
+func TestDupSubTreeHysteresis(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	val := func(i int) []byte { return []byte(fmt.Sprintf("value-%04d", i)) }
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	flags := func() gdbx.NodeFlags {
+		t.Helper()
+		if _, _, err := cur.Get([]byte("k"), nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		info, err := cur.CurrentNodeInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Flags
+	}
+
+	n := 0
+	for n == 0 || flags()&gdbx.NodeTree == 0 {
+		if err := txn.Put(dbi, []byte("k"), val(n), 0); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+
+	// Hovering around the limit keeps the sub-tree
+	for i := 0; i < 10; i++ {
+		if err := txn.Del(dbi, []byte("k"), val(n-1)); err != nil {
+			t.Fatal(err)
+		}
+		if f := flags(); f&gdbx.NodeTree == 0 {
+			t.Fatalf("%d values, one below the sub-tree's, stored with flags %#x", n-1, f)
+		}
+		if err := txn.Put(dbi, []byte("k"), val(n-1), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	left := n
+	for ; flags()&gdbx.NodeTree != 0; left-- {
+		if err := txn.Del(dbi, []byte("k"), val(left-1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if left < n/3 || left > n/2 {
+		t.Fatalf("sub-tree of %d values became a sub-page at %d, want about half", n, left)
+	}
+	if f := flags(); f != gdbx.NodeDup {
+		t.Fatalf("%d values left stored with flags %#x, want a sub-page", left, f)
+	}
+}
+
+// TestDupToSingleValue reduces keys to one value through each delete path:
+// cursor Del on a sub-page, Txn.Del on a sub-page and DelDupRange on a
+// sub-tree. Each key must end as a plain node holding the value left, and
+// libmdbx must read it as one.
+func TestDupToSingleValue(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	val := func(i int) []byte { return []byte(fmt.Sprintf("value-%04d", i)) }
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{"cursor": 5, "del": 5, "range": 300}
+	for k, n := range counts {
+		for i := 0; i < n; i++ {
+			if err := txn.Put(dbi, []byte(k), val(i), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	if _, _, err := cur.Get([]byte("cursor"), nil, gdbx.Set); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := cur.Del(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i < 5; i++ {
+		if err := txn.Del(dbi, []byte("del"), val(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := txn.DelDupRange(dbi, []byte("range"), val(1), nil); err != nil || n != 299 {
+		t.Fatalf("DelDupRange deleted %d values, %v", n, err)
+	}
+
+	want := map[string][]byte{"cursor": val(4), "del": val(0), "range": val(0)}
+	for k, v := range want {
+		if _, _, err := cur.Get([]byte(k), nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		info, err := cur.CurrentNodeInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Flags != 0 || info.Count != 1 || info.DataSize != uint32(len(v)) {
+			t.Fatalf("%s: one value left stored as %+v, want a plain node of %d bytes", k, info, len(v))
+		}
+		if _, value, err := cur.Get(nil, nil, gdbx.GetCurrent); err != nil || string(value) != string(v) {
+			t.Fatalf("%s holds %q (%v), want %q", k, value, err, v)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, mdbx.Readonly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = menv.View(func(txn *mdbx.Txn) error {
+		dbi, err := txn.OpenDBI("dups", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		for k, v := range want {
+			_, value, err := cur.Get([]byte(k), nil, mdbx.Set)
+			if err != nil {
+				return err
+			}
+			if n, err := cur.Count(); err != nil || n != 1 || string(value) != string(v) {
+				return fmt.Errorf("libmdbx reads %d values under %s, first %q, %v", n, k, value, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestDupSubPageStress puts and deletes random values under one key, few
+// enough that they stay in an inline sub-page, through a writing cursor,
+// while a second cursor stands on one of the values and a third on a
+// neighbouring key whose node the writes move around the leaf. After every
+// write the count and a full iteration in both directions must match a model,
+// the standing cursors must still see their values and step to the next ones,
+// and no invariant may fail. Commits along the way move the leaf to new pages.
+func TestDupSubPageStress(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	var violated string
+	env.SetAssertHook(func(cond bool, msg string) {
+		if !cond && violated == "" {
+			violated = msg
+		}
+	})
+
+	rng := rand.New(rand.NewSource(1212))
+	key, neighbour := []byte("key"), []byte("kez")
+	val := func(n int) string { return fmt.Sprintf("v%0*d", 1+n%7, n) }
+	var model []string
+	var dbi gdbx.DBI
+
+	err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if dbi, err = txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort); err != nil {
+			return err
+		}
+		for _, k := range []string{"kex", "kez"} {
+			for i := 0; i < 4; i++ {
+				if err := txn.Put(dbi, []byte(k), []byte(val(i)), 0); err != nil {
+					return err
+				}
+			}
+		}
+		for _, n := range []int{10, 20} {
+			model = append(model, val(n))
+			if err := txn.Put(dbi, key, []byte(val(n)), 0); err != nil {
+				return err
+			}
+		}
+		slices.Sort(model)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(cur *gdbx.Cursor, step int) error {
+		if _, _, err := cur.Get(key, nil, gdbx.Set); err != nil {
+			return err
+		}
+		n, err := cur.Count()
+		if err != nil || int(n) != len(model) {
+			return fmt.Errorf("step %d: Count = %d (%v), want %d", step, n, err, len(model))
+		}
+		var fwd []string
+		for _, v, err := cur.Get(key, nil, gdbx.Set); !gdbx.IsNotFound(err); _, v, err = cur.Get(nil, nil, gdbx.NextDup) {
+			if err != nil {
+				return fmt.Errorf("step %d: forward: %v", step, err)
+			}
+			fwd = append(fwd, string(v))
+		}
+		if !slices.Equal(fwd, model) {
+			return fmt.Errorf("step %d: forward iteration %q, want %q", step, fwd, model)
+		}
+		var back []string
+		if _, _, err := cur.Get(key, nil, gdbx.Set); err != nil {
+			return err
+		}
+		for _, v, err := cur.Get(nil, nil, gdbx.LastDup); !gdbx.IsNotFound(err); _, v, err = cur.Get(nil, nil, gdbx.PrevDup) {
+			if err != nil {
+				return fmt.Errorf("step %d: backward: %v", step, err)
+			}
+			back = append(back, string(v))
+		}
+		slices.Reverse(back)
+		if !slices.Equal(back, model) {
+			return fmt.Errorf("step %d: backward iteration %q, want %q", step, back, model)
+		}
+		return nil
+	}
+
+	for round := 0; round < 10; round++ {
+		err := env.Update(func(txn *gdbx.Txn) error {
+			w, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+			r, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			nb, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer nb.Close()
+			it, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer it.Close()
+			if _, _, err := nb.Get(neighbour, []byte(val(2)), gdbx.GetBoth); err != nil {
+				return err
+			}
+
+			for step := round * 100; step < round*100+100; step++ {
+				// r stands on a random value of the key
+				held := model[rng.Intn(len(model))]
+				if _, _, err := r.Get(key, []byte(held), gdbx.GetBoth); err != nil {
+					return fmt.Errorf("step %d: GetBoth %s: %w", step, held, err)
+				}
+
+				v := val(rng.Intn(40))
+				i, found := slices.BinarySearch(model, v)
+				switch {
+				case found && len(model) > 2:
+					if _, _, err := w.Get(key, []byte(v), gdbx.GetBoth); err != nil {
+						return fmt.Errorf("step %d: GetBoth %s: %w", step, v, err)
+					}
+					if err := w.Del(0); err != nil {
+						return fmt.Errorf("step %d: Del %s: %w", step, v, err)
+					}
+					model = slices.Delete(model, i, i+1)
+				case !found:
+					if err := w.Put(key, []byte(v), 0); err != nil {
+						return fmt.Errorf("step %d: Put %s: %w", step, v, err)
+					}
+					model = slices.Insert(model, i, v)
+				default:
+					continue
+				}
+
+				if _, got, err := nb.Get(nil, nil, gdbx.GetCurrent); err != nil || string(got) != val(2) {
+					return fmt.Errorf("step %d: neighbour cursor on %q (%v), want %q", step, got, err, val(2))
+				}
+				j, found := slices.BinarySearch(model, held)
+				if found {
+					if _, got, err := r.Get(nil, nil, gdbx.GetCurrent); err != nil || string(got) != held {
+						return fmt.Errorf("step %d: standing cursor on %q (%v), want %q", step, got, err, held)
+					}
+					j++
+				}
+				if n, err := r.Count(); err != nil || int(n) != len(model) {
+					return fmt.Errorf("step %d: standing cursor counts %d (%v), want %d", step, n, err, len(model))
+				}
+				_, got, err := r.Get(nil, nil, gdbx.NextDup)
+				switch {
+				case j < len(model) && (err != nil || string(got) != model[j]):
+					return fmt.Errorf("step %d: standing cursor on %q moved to %q (%v), want %q", step, held, got, err, model[j])
+				case j == len(model) && !gdbx.IsNotFound(err):
+					return fmt.Errorf("step %d: standing cursor on %q moved to %q (%v), want the end", step, held, got, err)
+				}
+				if err := check(it, step); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if violated != "" {
+			t.Fatalf("invariant violated: %s", violated)
+		}
+	}
+	if err := env.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
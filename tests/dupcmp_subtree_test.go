@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestGetBothRangeSubTreeComparator positions GetBothRange and GetBoth in a
+// DupSort sub-tree of several leaves, once under a custom dup comparator
+// whose order differs from the bytewise one and once under the bytewise
+// order, for targets on, between and around the stored values.
+func TestGetBothRangeSubTreeComparator(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	// Values are "<n>:<padding>", ordered by the number n
+	numeric := func(a, b []byte) int {
+		na, _ := strconv.Atoi(string(a[:bytes.IndexByte(a, ':')]))
+		nb, _ := strconv.Atoi(string(b[:bytes.IndexByte(b, ':')]))
+		return cmp.Compare(na, nb)
+	}
+	padding := strings.Repeat("p", 40)
+	numValue := func(n int) []byte { return []byte(fmt.Sprintf("%d:%s", n, padding)) }
+	byteValue := func(n int) []byte { return []byte(fmt.Sprintf("v%05d%s", n, padding)) }
+	key := []byte("k")
+
+	var custom, bytewise gdbx.DBI
+	err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if custom, err = txn.OpenDBI("custom", gdbx.Create|gdbx.DupSort, nil, numeric); err != nil {
+			return err
+		}
+		if bytewise, err = txn.OpenDBISimple("bytewise", gdbx.Create|gdbx.DupSort); err != nil {
+			return err
+		}
+		for i := 0; i < 200; i++ {
+			if err := txn.Put(custom, key, numValue(5*i), 0); err != nil {
+				return err
+			}
+			if err := txn.Put(bytewise, key, byteValue(5*i), 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dbi := range []gdbx.DBI{custom, bytewise} {
+		var dump bytes.Buffer
+		if err := env.DumpTree(dbi, &dump); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(dump.String(), "branch") {
+			t.Fatalf("the duplicates don't form a multi-leaf sub-tree:\n%s", dump.String())
+		}
+	}
+
+	txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	for _, tc := range []struct {
+		name  string
+		dbi   gdbx.DBI
+		value func(int) []byte
+	}{
+		{"custom", custom, numValue},
+		{"bytewise", bytewise, byteValue},
+	} {
+		cur, err := txn.OpenCursor(tc.dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for target := -1; target <= 1000; target++ {
+			_, v, err := cur.Get(key, tc.value(target), gdbx.GetBothRange)
+			want := (target + 4) / 5 * 5
+			if target < 0 {
+				want = 0
+			}
+			if want > 995 {
+				if !gdbx.IsNotFound(err) {
+					t.Fatalf("%s: GetBothRange(%d) = %q, %v, want not found", tc.name, target, v, err)
+				}
+				continue
+			}
+			if err != nil || !bytes.Equal(v, tc.value(want)) {
+				t.Fatalf("%s: GetBothRange(%d) = %q, %v, want %q", tc.name, target, v, err, tc.value(want))
+			}
+			// The cursor continues from where it landed
+			if want < 995 {
+				_, next, err := cur.Get(nil, nil, gdbx.NextDup)
+				if err != nil || !bytes.Equal(next, tc.value(want+5)) {
+					t.Fatalf("%s: NextDup after GetBothRange(%d) = %q, %v", tc.name, target, next, err)
+				}
+			}
+			_, v, err = cur.Get(key, tc.value(target), gdbx.GetBoth)
+			if found := target >= 0 && target%5 == 0 && target <= 995; found != (err == nil) {
+				t.Fatalf("%s: GetBoth(%d) = %q, %v", tc.name, target, v, err)
+			}
+		}
+		cur.Close()
+	}
+}
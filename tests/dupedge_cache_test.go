@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestFirstLastDupRepeated alternates FirstDup and LastDup with dup moves in
+// between on keys with large sub-trees, in a read-only transaction where
+// their edge paths are cached, and checks that each lands on the right
+// value and that NextDup and PrevDup continue from there.
+func TestFirstLastDupRepeated(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	const perKey = 5000
+	val := func(k, i int) []byte {
+		v := make([]byte, 16)
+		binary.BigEndian.PutUint64(v, uint64(k))
+		binary.BigEndian.PutUint64(v[8:], uint64(i))
+		return v
+	}
+	var dbi gdbx.DBI
+	err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if dbi, err = txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort); err != nil {
+			return err
+		}
+		for k := 0; k < 3; k++ {
+			for i := 0; i < perKey; i++ {
+				if err := txn.Put(dbi, []byte{byte(k)}, val(k, i), 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+
+	expect := func(op uint, name string, k, i int) {
+		t.Helper()
+		key, v, err := cur.Get(nil, nil, op)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if key[0] != byte(k) || string(v) != string(val(k, i)) {
+			t.Fatalf("%s = key %d, value %d/%d, want key %d, value %d", name, key[0], binary.BigEndian.Uint64(v), binary.BigEndian.Uint64(v[8:]), k, i)
+		}
+	}
+	for _, k := range []int{1, 0, 2, 1} {
+		if _, _, err := cur.Get([]byte{byte(k)}, nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		for round := 0; round < 3; round++ {
+			expect(gdbx.LastDup, "LastDup", k, perKey-1)
+			expect(gdbx.PrevDup, "PrevDup after LastDup", k, perKey-2)
+			expect(gdbx.FirstDup, "FirstDup", k, 0)
+			for i := 1; i < 700; i++ {
+				expect(gdbx.NextDup, "NextDup after FirstDup", k, i)
+			}
+			expect(gdbx.FirstDup, "FirstDup after NextDup", k, 0)
+			expect(gdbx.LastDup, "LastDup after FirstDup", k, perKey-1)
+		}
+		if _, _, err := cur.Get(nil, nil, gdbx.NextDup); !gdbx.IsNotFound(err) {
+			t.Fatalf("NextDup past the last value: %v", err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestDupFixedSubTree puts enough DUPFIXED values under one key to turn its
+// inline sub-page into a multi-level sub-tree of DUPFIX pages, deletes some
+// of them, and checks the values with gdbx and libmdbx. libmdbx then adds
+// values of its own, which gdbx must read back.
+func TestDupFixedSubTree(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	const total = 3000
+	val := func(i int) []byte {
+		v := make([]byte, 260)
+		binary.BigEndian.PutUint32(v, uint32(i))
+		return v
+	}
+	// want reports whether value i should be present
+	want := func(i int) bool { return i%3 != 0 }
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := txn.OpenDBISimple("fixed", gdbx.Create|gdbx.DupSort|gdbx.DupFixed)
+	if err != nil {
+		txn.Abort()
+		t.Fatal(err)
+	}
+	// The first values go in order so the conversion sees a full sub-page,
+	// the rest in random order to split DUPFIX pages in the middle
+	order := rand.New(rand.NewSource(1)).Perm(total - 10)
+	for i := range order {
+		order[i] += 10
+	}
+	order = append([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, order...)
+	for _, i := range order {
+		if err := txn.Put(dbi, []byte("k"), val(i), 0); err != nil {
+			txn.Abort()
+			t.Fatalf("Put %d: %v", i, err)
+		}
+	}
+	if err := txn.Put(dbi, []byte("k"), val(total-1), gdbx.AppendDup); err != nil {
+		txn.Abort()
+		t.Fatalf("AppendDup of the last value: %v", err)
+	}
+	if err := txn.Put(dbi, []byte("k"), val(5), gdbx.NoDupData); gdbx.Code(err) != gdbx.ErrKeyExist {
+		txn.Abort()
+		t.Fatalf("NoDupData of an existing value: got %v, want ErrKeyExist", err)
+	}
+	for i := 0; i < total; i++ {
+		if !want(i) {
+			if err := txn.Del(dbi, []byte("k"), val(i)); err != nil {
+				txn.Abort()
+				t.Fatalf("Del %d: %v", i, err)
+			}
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for i := 0; i < total; i++ {
+		if want(i) {
+			count++
+		}
+	}
+	checkGdbx := func(count int) {
+		t.Helper()
+		rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rtxn.Abort()
+		dbi, err := rtxn.OpenDBISimple("fixed", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat, err := rtxn.Stat(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Entries != uint64(count) {
+			t.Fatalf("Stat reports %d entries, want %d", stat.Entries, count)
+		}
+		cur, err := rtxn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		n, prev := 0, -1
+		for _, v, err := cur.Get([]byte("k"), nil, gdbx.Set); !gdbx.IsNotFound(err); _, v, err = cur.Get(nil, nil, gdbx.NextDup) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := int(binary.BigEndian.Uint32(v))
+			if i <= prev || (i < total && !want(i)) {
+				t.Fatalf("value %d after %d", i, prev)
+			}
+			prev = i
+			n++
+		}
+		if n != count {
+			t.Fatalf("iterated %d values, want %d", n, count)
+		}
+		if _, _, err := cur.Get([]byte("k"), val(total/2+1), gdbx.GetBoth); err != nil {
+			t.Fatalf("GetBoth: %v", err)
+		}
+		if _, v, err := cur.Get([]byte("k"), nil, gdbx.LastDup); err != nil || int(binary.BigEndian.Uint32(v)) != prev {
+			t.Fatalf("LastDup: %v", err)
+		}
+	}
+	checkGdbx(count)
+	env.Close()
+
+	// libmdbx reads the sub-tree and adds values to it
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, 0, 0644); err != nil {
+		menv.Close()
+		t.Fatal(err)
+	}
+	err = menv.Update(func(mtxn *mdbx.Txn) error {
+		mdbi, err := mtxn.OpenDBI("fixed", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		mcur, err := mtxn.OpenCursor(mdbi)
+		if err != nil {
+			return err
+		}
+		defer mcur.Close()
+		n := 0
+		for _, v, err := mcur.Get([]byte("k"), nil, mdbx.Set); !mdbx.IsNotFound(err); _, v, err = mcur.Get(nil, nil, mdbx.NextDup) {
+			if err != nil {
+				return err
+			}
+			if i := int(binary.BigEndian.Uint32(v)); !want(i) {
+				t.Errorf("libmdbx: unexpected value %d", i)
+			}
+			n++
+		}
+		if n != count {
+			t.Errorf("libmdbx iterated %d values, want %d", n, count)
+		}
+		for i := total; i < total+500; i++ {
+			if err := mtxn.Put(mdbi, []byte("k"), val(i), 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	menv.Close()
+	if err != nil {
+		t.Fatalf("libmdbx: %v", err)
+	}
+
+	env = openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	checkGdbx(count + 500)
+
+	// Deleting through a cursor walk empties leaves along the way and
+	// must still visit every value once
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	if dbi, err = txn.OpenDBISimple("fixed", 0); err != nil {
+		t.Fatal(err)
+	}
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	seen, kept := 0, 0
+	for _, v, err := cur.Get([]byte("k"), nil, gdbx.Set); !gdbx.IsNotFound(err); _, v, err = cur.Get(nil, nil, gdbx.NextDup) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen++
+		if binary.BigEndian.Uint32(v) < total/2 {
+			if err := cur.Del(0); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			kept++
+		}
+	}
+	if seen != count+500 {
+		t.Fatalf("deleting walk visited %d values, want %d", seen, count+500)
+	}
+	if n, err := cur.Count(); err != nil || n != uint64(kept) {
+		t.Fatalf("Count after deleting walk = %d, %v, want %d", n, err, kept)
+	}
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestDupFixedWidth puts an 8-byte value in a DupFixed database and then
+// values of 7 bytes, under the same key and a new one. Those must fail with
+// ErrBadValSize and leave the database as it was, the width must outlast the
+// commit, and libmdbx must refuse a 7-byte value too.
+func TestDupFixedWidth(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	var dbi gdbx.DBI
+	err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if dbi, err = txn.OpenDBISimple("fixed", gdbx.Create|gdbx.DupSort|gdbx.DupFixed); err != nil {
+			return err
+		}
+		if err := txn.Put(dbi, []byte("a"), []byte("value-08"), 0); err != nil {
+			return err
+		}
+		for _, k := range []string{"a", "b"} {
+			if err := txn.Put(dbi, []byte(k), []byte("value-7"), 0); gdbx.Code(err) != gdbx.ErrBadValSize {
+				t.Errorf("7-byte value under %s: got %v, want ErrBadValSize", k, err)
+			}
+		}
+		return txn.Put(dbi, []byte("a"), []byte("value-09"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *gdbx.Txn) error {
+		if err := txn.Put(dbi, []byte("c"), []byte("value-7"), 0); gdbx.Code(err) != gdbx.ErrBadValSize {
+			t.Errorf("7-byte value after the commit: got %v, want ErrBadValSize", err)
+		}
+		return txn.Put(dbi, []byte("c"), []byte("value-10"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, 0, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = menv.Update(func(txn *mdbx.Txn) error {
+		dbi, err := txn.OpenDBI("fixed", mdbx.DupSort|mdbx.DupFixed, nil, nil)
+		if err != nil {
+			return err
+		}
+		stat, err := txn.StatDBI(dbi)
+		if err != nil {
+			return err
+		}
+		if stat.Entries != 3 {
+			t.Errorf("libmdbx counts %d entries, want 3", stat.Entries)
+		}
+		if err := txn.Put(dbi, []byte("d"), []byte("value-7"), 0); !mdbx.IsErrno(err, mdbx.BadValSize) {
+			t.Errorf("libmdbx put of a 7-byte value: got %v, want MDBX_BAD_VALSIZE", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
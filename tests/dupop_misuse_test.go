@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestDupOpsOnPlainTable checks that duplicate-only cursor operations,
+// GetBoth and GetBothRange among them, fail with ErrIncompatible on a table
+// without DupSort, bounded cursors included, that GetBoth and GetBothRange
+// require a value, and that the failed calls leave the cursor where it was.
+func TestDupOpsOnPlainTable(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if err := txn.Put(plain, []byte(k), []byte("v"+k), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Put(dups, []byte(k), []byte("v"+k), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cur, err := txn.OpenCursor(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	if _, _, err := cur.Get([]byte("b"), nil, gdbx.Set); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := map[string]uint{
+		"FirstDup": gdbx.FirstDup,
+		"LastDup":  gdbx.LastDup,
+		"NextDup":  gdbx.NextDup,
+		"PrevDup":  gdbx.PrevDup,
+	}
+	for name, op := range ops {
+		if _, _, err := cur.Get(nil, nil, op); gdbx.Code(err) != gdbx.ErrIncompatible {
+			t.Fatalf("%s on a plain table: got %v, want ErrIncompatible", name, err)
+		}
+	}
+	both := map[string]uint{"GetBoth": gdbx.GetBoth, "GetBothRange": gdbx.GetBothRange}
+	for name, op := range both {
+		if _, _, err := cur.Get([]byte("c"), []byte("vc"), op); gdbx.Code(err) != gdbx.ErrIncompatible {
+			t.Fatalf("%s on a plain table: got %v, want ErrIncompatible", name, err)
+		}
+	}
+	if k, v, err := cur.Get(nil, nil, gdbx.GetCurrent); err != nil || string(k) != "b" || string(v) != "vb" {
+		t.Fatalf("GetCurrent after rejected ops = %s, %s, %v, want b, vb", k, v, err)
+	}
+
+	// Bounds don't get in the way of the checks
+	cur.SetBounds([]byte("a"), []byte("c"))
+	for name, op := range ops {
+		if _, _, err := cur.Get(nil, nil, op); gdbx.Code(err) != gdbx.ErrIncompatible {
+			t.Fatalf("%s on a bounded cursor of a plain table: got %v, want ErrIncompatible", name, err)
+		}
+	}
+	for name, op := range both {
+		if _, _, err := cur.Get([]byte("b"), []byte("vb"), op); gdbx.Code(err) != gdbx.ErrIncompatible {
+			t.Fatalf("%s on a bounded cursor of a plain table: got %v, want ErrIncompatible", name, err)
+		}
+	}
+	cur.SetBounds(nil, nil)
+
+	// The NoDup moves still work on a plain table
+	if k, _, err := cur.Get(nil, nil, gdbx.NextNoDup); err != nil || string(k) != "c" {
+		t.Fatalf("NextNoDup = %s, %v, want c", k, err)
+	}
+	if k, _, err := cur.Get(nil, nil, gdbx.PrevNoDup); err != nil || string(k) != "b" {
+		t.Fatalf("PrevNoDup = %s, %v, want b", k, err)
+	}
+	if n, err := cur.Count(); err != nil || n != 1 {
+		t.Fatalf("Count on a plain table = %d, %v, want 1", n, err)
+	}
+
+	// The same operations are accepted on a DupSort table
+	dcur, err := txn.OpenCursor(dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dcur.Close()
+	if _, _, err := dcur.Get([]byte("b"), nil, gdbx.Set); err != nil {
+		t.Fatal(err)
+	}
+	for name, op := range ops {
+		if _, _, err := dcur.Get(nil, nil, op); err != nil && !gdbx.IsNotFound(err) {
+			t.Fatalf("%s on a DupSort table: %v", name, err)
+		}
+	}
+	for name, op := range both {
+		if _, _, err := dcur.Get([]byte("b"), nil, op); gdbx.Code(err) != gdbx.ErrIncompatible {
+			t.Fatalf("%s without a value: got %v, want ErrIncompatible", name, err)
+		}
+		if _, v, err := dcur.Get([]byte("c"), []byte("vc"), op); err != nil || string(v) != "vc" {
+			t.Fatalf("%s(c, vc) on a DupSort table = %s, %v", name, v, err)
+		}
+	}
+}
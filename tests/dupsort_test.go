@@ -113,8 +113,11 @@ func TestDupSortBasic(t *testing.T) {
 	}
 
 	// Debug: check tree flags
-	tree := gdbxTxn.GetTree(gdbxDbi)
-	fmt.Printf("gdbx tree flags: 0x%04x (DupSort=%v)\n", tree.Flags, tree.Flags&uint16(gdbx.DupSort) != 0)
+	tree, err := gdbxTxn.TreeInfo(gdbxDbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("gdbx tree flags: 0x%04x (DupSort=%v)\n", tree.Flags, tree.Flags&gdbx.DupSort != 0)
 
 	gdbxCursor, err := gdbxTxn.OpenCursor(gdbxDbi)
 	if err != nil {
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestEstimateSize estimates batches of several shapes, sequential and random
+// keys into empty and filled tables, values on large pages, replacements and
+// DupSort values, then writes each and checks that the estimate is within
+// a tenth of the pages the commit added to the file, and that estimating
+// wrote nothing.
+func TestEstimateSize(t *testing.T) {
+	type batch struct{ keys, vals [][]byte }
+	key := func(n uint64) []byte { return binary.BigEndian.AppendUint64(nil, n) }
+	fill := func(n int, k func(i int) []byte, vsize int) batch {
+		var b batch
+		for i := 0; i < n; i++ {
+			b.keys = append(b.keys, k(i))
+			b.vals = append(b.vals, make([]byte, vsize))
+		}
+		return b
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		name    string
+		flags   uint
+		initial batch
+		batch   batch
+	}{
+		{"sequential into empty", 0, batch{},
+			fill(20000, func(i int) []byte { return key(uint64(i)) }, 100)},
+		{"random into empty", 0, batch{},
+			fill(20000, func(int) []byte { return key(rng.Uint64()) }, 100)},
+		{"random into filled", 0,
+			fill(20000, func(int) []byte { return key(rng.Uint64()) }, 100),
+			fill(5000, func(int) []byte { return key(rng.Uint64()) }, 100)},
+		{"appended to filled", 0,
+			fill(20000, func(i int) []byte { return key(uint64(i)) }, 100),
+			fill(5000, func(i int) []byte { return key(uint64(20000 + i)) }, 100)},
+		{"replacing", 0,
+			fill(20000, func(i int) []byte { return key(uint64(i)) }, 100),
+			fill(2000, func(i int) []byte { return key(uint64(i * 10)) }, 200)},
+		{"large values", 0, batch{},
+			fill(300, func(i int) []byte { return key(uint64(i)) }, 10000)},
+		{"dupsort", gdbx.DupSort,
+			fill(2000, func(i int) []byte { return key(uint64(i % 200)) }, 16),
+			fill(20000, func(i int) []byte { return key(uint64(rng.Intn(400))) }, 16)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.flags&gdbx.DupSort != 0 {
+				for i := range tc.batch.vals {
+					tc.batch.vals[i] = []byte(fmt.Sprintf("%016d", rng.Int63()))
+				}
+			}
+			db := newTestDB(t)
+			defer db.cleanup()
+			env := openGdbxEnv(t, db.path, 0)
+			defer env.Close()
+
+			var dbi gdbx.DBI
+			if err := env.Update(func(txn *gdbx.Txn) error {
+				var err error
+				if dbi, err = txn.OpenDBISimple("t", gdbx.Create|tc.flags); err != nil {
+					return err
+				}
+				for i := range tc.initial.keys {
+					if err := txn.Put(dbi, tc.initial.keys[i], tc.initial.vals[i], 0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			before := lastPgNo(t, env)
+
+			txn, err := env.BeginTxn(nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer txn.Abort()
+			stat, err := txn.Stat(dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			est, err := txn.EstimateSize(dbi, tc.batch.keys, tc.batch.vals)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after, err := txn.Stat(dbi); err != nil || *after != *stat {
+				t.Fatalf("estimating changed the table: %+v -> %+v, %v", stat, after, err)
+			}
+			for i := range tc.batch.keys {
+				if err := txn.Put(dbi, tc.batch.keys[i], tc.batch.vals[i], 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := txn.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			grew := lastPgNo(t, env) - before
+			t.Logf("estimated %d pages, the file grew by %d", est, grew)
+			if diff := int64(est) - grew; diff > grew/10+4 || -diff > grew/10+4 {
+				t.Fatalf("estimated %d pages, the file grew by %d", est, grew)
+			}
+		})
+	}
+
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	if _, err := txn.EstimateSize(gdbx.MainDBI, [][]byte{[]byte("k")}, nil); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("mismatched lengths: %v", err)
+	}
+	big := make([]byte, env.MaxKeySize()+1)
+	if _, err := txn.EstimateSize(gdbx.MainDBI, [][]byte{big}, [][]byte{nil}); gdbx.Code(err) != gdbx.ErrBadKeySize {
+		t.Fatalf("oversized key: %v", err)
+	}
+}
+
+func lastPgNo(t *testing.T, env *gdbx.Env) int64 {
+	t.Helper()
+	info, err := env.Info(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.LastPgNo
+}
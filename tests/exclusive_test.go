@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestExclusiveOpen checks that an Exclusive opener and other openers of the
+// same data file refuse each other, within the process and across processes,
+// and that closing the holder lets the other kind open.
+func TestExclusiveOpen(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	open := func(flags uint) (*gdbx.Env, error) {
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Open(db.path, flags, 0644); err != nil {
+			env.Close()
+			return nil, err
+		}
+		return env, nil
+	}
+	// inChild opens the environment with flags from another process and
+	// returns the error code it got
+	inChild := func(flags uint) gdbx.ErrorCode {
+		t.Helper()
+		cmd := exec.Command(os.Args[0], "-test.run=^TestExclusiveOpenChild$")
+		cmd.Env = append(os.Environ(), "GDBX_EXCLUSIVE_PATH="+db.path, "GDBX_EXCLUSIVE_FLAGS="+strconv.FormatUint(uint64(flags), 10))
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("child: %v\n%s", err, out)
+		}
+		code, err := os.ReadFile(db.path + "/child-result")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := strconv.Atoi(string(code))
+		return gdbx.ErrorCode(n)
+	}
+
+	env, err := open(gdbx.Exclusive)
+	if err != nil {
+		t.Fatalf("Exclusive Open: %v", err)
+	}
+	if _, err := open(gdbx.Exclusive); gdbx.Code(err) != gdbx.ErrBusy {
+		t.Fatalf("second Exclusive Open: got %v, want ErrBusy", err)
+	}
+	if code := inChild(gdbx.Exclusive); code != gdbx.ErrBusy {
+		t.Fatalf("Exclusive Open in another process: got code %d, want ErrBusy", code)
+	}
+	if code := inChild(0); code != gdbx.ErrBusy {
+		t.Fatalf("Open in another process while Exclusive is held: got code %d, want ErrBusy", code)
+	}
+	env.Close()
+
+	// An ordinary opener keeps Exclusive ones out until it closes
+	env, err = open(0)
+	if err != nil {
+		t.Fatalf("Open after the Exclusive holder closed: %v", err)
+	}
+	if code := inChild(gdbx.Exclusive); code != gdbx.ErrBusy {
+		t.Fatalf("Exclusive Open in another process while shared: got code %d, want ErrBusy", code)
+	}
+	if code := inChild(0); code != 0 {
+		t.Fatalf("second ordinary Open in another process: got code %d, want success", code)
+	}
+	env.Close()
+	if code := inChild(gdbx.Exclusive); code != 0 {
+		t.Fatalf("Exclusive Open after all closed: got code %d, want success", code)
+	}
+}
+
+// TestExclusiveOpenChild is the other process of TestExclusiveOpen and of
+// openInChild. It opens the environment it is given, with OpenRelocated if
+// GDBX_EXCLUSIVE_RELOCATED is set, and records the resulting error code.
+func TestExclusiveOpenChild(t *testing.T) {
+	path := os.Getenv("GDBX_EXCLUSIVE_PATH")
+	if path == "" {
+		t.Skip("only run by TestExclusiveOpen")
+	}
+	flags, err := strconv.ParseUint(os.Getenv("GDBX_EXCLUSIVE_FLAGS"), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if os.Getenv("GDBX_EXCLUSIVE_RELOCATED") != "" {
+		err = env.OpenRelocated(path, uint(flags))
+	} else {
+		err = env.Open(path, uint(flags), 0644)
+	}
+	code := 0
+	if err != nil {
+		code = int(gdbx.Code(err))
+	}
+	if err := os.WriteFile(path+"/child-result", []byte(strconv.Itoa(code)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
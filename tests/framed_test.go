@@ -0,0 +1,255 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestExportImportFramed exports a plain table with big values and a
+// DupSort table, checks the stream frame by frame against the tables, and
+// imports it into fresh tables of another environment, which must then
+// hold the same pairs. It also checks that SetBounds limits the export, and
+// that ImportFramed refuses a stream cut inside a frame and a length
+// beyond the limits.
+func TestExportImportFramed(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	db2 := newTestDB(t)
+	defer db2.cleanup()
+	env2 := openGdbxEnv(t, db2.path, 0)
+	defer env2.Close()
+
+	key := func(i int) []byte { return binary.BigEndian.AppendUint64(nil, uint64(i)) }
+	err := env.Update(func(txn *gdbx.Txn) error {
+		plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+		if err != nil {
+			return err
+		}
+		dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 2000; i++ {
+			val := []byte(fmt.Sprintf("value-%d", i))
+			if i%250 == 0 {
+				val = bytes.Repeat([]byte{byte(i)}, 30000)
+			}
+			if err := txn.Put(plain, key(i), val, 0); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < 30; i++ {
+			for j := 0; j < 1+i%4*100; j++ {
+				if err := txn.Put(dups, key(i), []byte(fmt.Sprintf("d%04d", j)), 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pairs reads every pair of a table, duplicates expanded
+	pairs := func(txn *gdbx.Txn, dbi gdbx.DBI) [][2][]byte {
+		t.Helper()
+		c, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		var out [][2][]byte
+		for k, v, err := c.Get(nil, nil, gdbx.First); err == nil; k, v, err = c.Get(nil, nil, gdbx.Next) {
+			out = append(out, [2][]byte{bytes.Clone(k), bytes.Clone(v)})
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{{"plain", 0}, {"dups", gdbx.DupSort}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stream bytes.Buffer
+			var want [][2][]byte
+			err := env.View(func(txn *gdbx.Txn) error {
+				dbi, err := txn.OpenDBISimple(tc.name, 0)
+				if err != nil {
+					return err
+				}
+				want = pairs(txn, dbi)
+				c, err := txn.OpenCursor(dbi)
+				if err != nil {
+					return err
+				}
+				defer c.Close()
+				n, err := c.ExportFramed(&stream)
+				if err != nil {
+					return err
+				}
+				if n != uint64(len(want)) {
+					return fmt.Errorf("exported %d frames, want %d", n, len(want))
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The frames, parsed by hand
+			data := stream.Bytes()
+			field := func() []byte {
+				n, w := binary.Uvarint(data)
+				if w <= 0 || uint64(len(data)-w) < n {
+					t.Fatalf("bad frame with %d bytes left", len(data))
+				}
+				b := data[w : w+int(n)]
+				data = data[w+int(n):]
+				return b
+			}
+			for i, p := range want {
+				if k, v := field(), field(); !bytes.Equal(k, p[0]) || !bytes.Equal(v, p[1]) {
+					t.Fatalf("frame %d is %x/%d bytes, want %x/%d bytes", i, k, len(v), p[0], len(p[1]))
+				}
+			}
+			if len(data) != 0 {
+				t.Fatalf("%d bytes after the last frame", len(data))
+			}
+
+			err = env2.Update(func(txn *gdbx.Txn) error {
+				dbi, err := txn.OpenDBISimple(tc.name, gdbx.Create|tc.flags)
+				if err != nil {
+					return err
+				}
+				flags := gdbx.Append
+				if tc.flags&gdbx.DupSort != 0 {
+					flags = gdbx.AppendDup
+				}
+				n, err := txn.ImportFramed(dbi, bytes.NewReader(stream.Bytes()), flags)
+				if err != nil {
+					return err
+				}
+				if n != uint64(len(want)) {
+					return fmt.Errorf("imported %d pairs, want %d", n, len(want))
+				}
+				if got := pairs(txn, dbi); len(got) != len(want) {
+					return fmt.Errorf("table holds %d pairs after the import, want %d", len(got), len(want))
+				} else {
+					for i := range got {
+						if !bytes.Equal(got[i][0], want[i][0]) || !bytes.Equal(got[i][1], want[i][1]) {
+							return fmt.Errorf("pair %d differs after the import", i)
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	t.Run("Bounds", func(t *testing.T) {
+		err := env.View(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("plain", 0)
+			if err != nil {
+				return err
+			}
+			c, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			c.SetBounds(key(100), key(200))
+			var stream bytes.Buffer
+			n, err := c.ExportFramed(&stream)
+			if err != nil {
+				return err
+			}
+			if n != 100 {
+				return fmt.Errorf("exported %d frames within the bounds, want 100", n)
+			}
+			if k, w := binary.Uvarint(stream.Bytes()); k != 8 || !bytes.Equal(stream.Bytes()[w:w+8], key(100)) {
+				return fmt.Errorf("export starts at %x", stream.Bytes()[w:w+8])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		frame := func(k, v []byte) []byte {
+			b := binary.AppendUvarint(nil, uint64(len(k)))
+			b = append(b, k...)
+			b = binary.AppendUvarint(b, uint64(len(v)))
+			return append(b, v...)
+		}
+		good := frame([]byte("a"), []byte("1"))
+		overflow := append(bytes.Repeat([]byte{0xff}, 10), 0x01)
+		for _, tc := range []struct {
+			name   string
+			stream []byte
+			code   gdbx.ErrorCode
+		}{
+			{"empty", nil, gdbx.Success},
+			{"cut in a value", slices.Concat(good, frame([]byte("b"), []byte("22"))[:4]), gdbx.ErrInvalid},
+			{"cut in a length", slices.Concat(good, []byte{0x80}), gdbx.ErrInvalid},
+			{"length overflow", slices.Concat(good, overflow), gdbx.ErrInvalid},
+			{"long key", slices.Concat(good, binary.AppendUvarint(nil, 1<<40)), gdbx.ErrBadKeySize},
+			{"long value", slices.Concat(good, []byte{1, 'b'}, binary.AppendUvarint(nil, 1<<40)), gdbx.ErrBadValSize},
+		} {
+			txn, err := env2.BeginTxn(nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dbi, err := txn.OpenDBISimple("malformed", gdbx.Create)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = txn.ImportFramed(dbi, bytes.NewReader(tc.stream), 0)
+			txn.Abort()
+			if tc.code == gdbx.Success {
+				if err != nil {
+					t.Errorf("%s: %v", tc.name, err)
+				}
+				continue
+			}
+			if gdbx.Code(err) != tc.code {
+				t.Errorf("%s: got %v, want code %d", tc.name, err, tc.code)
+			}
+		}
+	})
+
+	// A failing reader's error comes back wrapped
+	t.Run("ReadError", func(t *testing.T) {
+		txn, err := env2.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		dbi, err := txn.OpenDBISimple("readerr", gdbx.Create)
+		if err != nil {
+			t.Fatal(err)
+		}
+		boom := errors.New("boom")
+		if _, err := txn.ImportFramed(dbi, failingReader{boom}, 0); gdbx.Code(err) != gdbx.ErrProblem || !errors.Is(err, boom) {
+			t.Fatalf("ImportFramed from a failing reader = %v", err)
+		}
+	})
+}
+
+// failingReader fails every read with err.
+type failingReader struct{ err error }
+
+func (r failingReader) Read([]byte) (int, error) { return 0, r.err }
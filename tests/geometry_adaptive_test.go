@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestGeometryAdaptive commits a steady stream of writes to one database
+// growing by a tiny fixed step and to one growing adaptively, and checks that
+// the adaptive one remaps many times less, stays under its upper limit and
+// keeps every entry, in both the default and the WriteMap mode.
+func TestGeometryAdaptive(t *testing.T) {
+	const (
+		commits   = 200
+		perCommit = 64
+		upper     = 1 << 30
+	)
+	for _, flags := range []uint{0, gdbx.WriteMap} {
+		run := func(adaptive bool) uint64 {
+			db := newTestDB(t)
+			defer db.cleanup()
+
+			env, err := gdbx.NewEnv(gdbx.Default)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer env.Close()
+			if adaptive {
+				err = env.SetGeometryAdaptive(-1, upper, 64<<20)
+			} else {
+				err = env.SetGeometry(-1, -1, upper, 4096, -1, -1)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := env.Open(db.path, flags, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			value := make([]byte, 1000)
+			var key [8]byte
+			for c := 0; c < commits; c++ {
+				if err := env.Update(func(txn *gdbx.Txn) error {
+					for i := 0; i < perCommit; i++ {
+						binary.BigEndian.PutUint64(key[:], uint64(c*perCommit+i))
+						if err := txn.Put(gdbx.MainDBI, key[:], value, 0); err != nil {
+							return err
+						}
+					}
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info, err := env.Info(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.MapSize > upper {
+				t.Fatalf("map of %d bytes above the upper limit", info.MapSize)
+			}
+			stat, err := env.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat.Entries != commits*perCommit {
+				t.Fatalf("%d entries, want %d", stat.Entries, commits*perCommit)
+			}
+			return info.MapGrowths
+		}
+		fixed, adaptive := run(false), run(true)
+		t.Logf("flags %#x: %d growths with a fixed step, %d adaptive", flags, fixed, adaptive)
+		if adaptive == 0 || adaptive*4 > fixed {
+			t.Fatalf("flags %#x: %d growths with a fixed step, %d adaptive", flags, fixed, adaptive)
+		}
+	}
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	for _, args := range [][3]int64{{-1, upper, 0}, {upper, 1 << 20, 1 << 20}} {
+		if err := env.SetGeometryAdaptive(args[0], args[1], args[2]); gdbx.Code(err) != gdbx.ErrInvalid {
+			t.Fatalf("SetGeometryAdaptive%v: %v, want ErrInvalid", args, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestGeometryLowerPresize creates databases with a lower bound set through
+// SetGeometry and checks that the file starts at that size and that a bulk
+// load fitting in it never grows the map, where the same load into a
+// database without the bound grows it step by step.
+func TestGeometryLowerPresize(t *testing.T) {
+	const (
+		lower = 64 << 20
+		keys  = 20000
+	)
+	for _, flags := range []uint{0, gdbx.WriteMap} {
+		load := func(lowerSize int64) (growths uint64, created int64) {
+			db := newTestDB(t)
+			defer db.cleanup()
+
+			env, err := gdbx.NewEnv(gdbx.Default)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer env.Close()
+			if err := env.SetGeometry(lowerSize, -1, 1<<30, 1<<20, -1, -1); err != nil {
+				t.Fatal(err)
+			}
+			if err := env.Open(db.path, flags, 0644); err != nil {
+				t.Fatal(err)
+			}
+			fi, err := os.Stat(filepath.Join(db.path, gdbx.DataFileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value := make([]byte, 1000)
+			var key [8]byte
+			err = env.Update(func(txn *gdbx.Txn) error {
+				for i := 0; i < keys; i++ {
+					binary.BigEndian.PutUint64(key[:], uint64(i))
+					if err := txn.Put(gdbx.MainDBI, key[:], value, 0); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := env.Info(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stat, err := env.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat.Entries != keys {
+				t.Fatalf("%d entries, want %d", stat.Entries, keys)
+			}
+			return info.MapGrowths, fi.Size()
+		}
+
+		growths, size := load(lower)
+		if size < lower {
+			t.Fatalf("flags %#x: new file of %d bytes, want at least the lower bound %d", flags, size, lower)
+		}
+		if growths != 0 {
+			t.Fatalf("flags %#x: bulk load into a presized file grew the map %d times", flags, growths)
+		}
+		if growths, size = load(-1); growths == 0 || size >= lower {
+			t.Fatalf("flags %#x: without a lower bound the file started at %d bytes and grew %d times", flags, size, growths)
+		}
+	}
+}
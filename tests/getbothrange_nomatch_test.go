@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/Giulio2002/gdbx"
@@ -124,3 +125,76 @@ func TestGetBothRangeNoMatch(t *testing.T) {
 		t.Errorf("gdbx: expected value3.1, got %q", gv2)
 	}
 }
+
+// TestGetBothRangeNoMatchContinues verifies that when GetBothRange finds no value
+// >= target under a middle key, it returns NotFound and leaves the cursor on the
+// next key's first value so a scan can resume with GetCurrent/Next.
+func TestGetBothRangeNoMatchContinues(t *testing.T) {
+	// 3 values fit in a sub-page, 500 force a sub-tree for the middle key
+	for _, numDups := range []int{3, 500} {
+		t.Run(fmt.Sprintf("dups=%d", numDups), func(t *testing.T) {
+			db := newTestDB(t)
+			defer db.cleanup()
+
+			env := openGdbxEnv(t, db.path, 0)
+			defer env.Close()
+
+			txn, err := env.BeginTxn(nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer txn.Abort()
+
+			dbi, err := txn.OpenDBISimple("test", gdbx.Create|gdbx.DupSort)
+			if err != nil {
+				t.Fatal(err)
+			}
+			put := func(k, v string) {
+				if err := txn.Put(dbi, []byte(k), []byte(v), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			put("a", "v000")
+			for i := 0; i < numDups; i++ {
+				put("b", fmt.Sprintf("v%03d", i))
+			}
+			put("c", "v000")
+			put("c", "v001")
+
+			cursor, err := txn.OpenCursor(dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cursor.Close()
+
+			// Target beyond the last duplicate of the middle key
+			_, _, err = cursor.Get([]byte("b"), []byte("zzz"), gdbx.GetBothRange)
+			if !gdbx.IsNotFound(err) {
+				t.Fatalf("GetBothRange(b, zzz): err=%v, want NotFound", err)
+			}
+			k, v, err := cursor.Get(nil, nil, gdbx.GetCurrent)
+			if err != nil || string(k) != "c" || string(v) != "v000" {
+				t.Fatalf("GetCurrent after miss: k=%q v=%q err=%v, want c/v000", k, v, err)
+			}
+			k, v, err = cursor.Get(nil, nil, gdbx.Next)
+			if err != nil || string(k) != "c" || string(v) != "v001" {
+				t.Fatalf("Next after miss: k=%q v=%q err=%v, want c/v001", k, v, err)
+			}
+
+			// A match still positions within the key
+			k, v, err = cursor.Get([]byte("b"), []byte("v001x"), gdbx.GetBothRange)
+			if err != nil || string(k) != "b" || string(v) != "v002" {
+				t.Fatalf("GetBothRange(b, v001x): k=%q v=%q err=%v, want b/v002", k, v, err)
+			}
+
+			// A miss on the last key leaves the cursor at EOF
+			_, _, err = cursor.Get([]byte("c"), []byte("zzz"), gdbx.GetBothRange)
+			if !gdbx.IsNotFound(err) {
+				t.Fatalf("GetBothRange(c, zzz): err=%v, want NotFound", err)
+			}
+			if !cursor.EOF() {
+				t.Fatal("cursor not at EOF after miss on last key")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestGetSize checks that GetSize reports the length Get returns for empty,
+// inline and big values, committed and dirty, and for the first value of
+// DupSort keys holding one value, a sub-page or a sub-tree; and that it
+// fails like Get for absent keys and bad handles.
+func TestGetSize(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	sizes := []int{0, 1, 100, 1000, 4000, 5000, 100000, 3 << 20}
+	var plain, dups gdbx.DBI
+	err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if plain, err = txn.OpenDBISimple("plain", gdbx.Create); err != nil {
+			return err
+		}
+		if dups, err = txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort); err != nil {
+			return err
+		}
+		for i, n := range sizes {
+			if err := txn.Put(plain, []byte(fmt.Sprintf("k%d", i)), bytes.Repeat([]byte{'x'}, n), 0); err != nil {
+				return err
+			}
+		}
+		// d1 holds one value, d3 a sub-page and d500 a sub-tree; the first
+		// value is the shortest
+		for _, n := range []int{1, 3, 500} {
+			for j := 0; j < n; j++ {
+				if err := txn.Put(dups, []byte(fmt.Sprintf("d%d", n)), []byte(fmt.Sprintf("%0*d", 5+j%7, j)), 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, txn *gdbx.Txn, dbi gdbx.DBI, key string) {
+		t.Helper()
+		v, gerr := txn.Get(dbi, []byte(key))
+		n, err := txn.GetSize(dbi, []byte(key))
+		if gdbx.Code(err) != gdbx.Code(gerr) {
+			t.Fatalf("GetSize(%s) = %d, %v; Get gave %v", key, n, err, gerr)
+		}
+		if err == nil && n != len(v) {
+			t.Fatalf("GetSize(%s) = %d, Get returned %d bytes", key, n, len(v))
+		}
+	}
+	keys := []string{"missing", "k", "k00"}
+	for i := range sizes {
+		keys = append(keys, fmt.Sprintf("k%d", i))
+	}
+
+	t.Run("Committed", func(t *testing.T) {
+		err := env.View(func(txn *gdbx.Txn) error {
+			for _, k := range keys {
+				check(t, txn, plain, k)
+			}
+			for _, k := range []string{"d1", "d3", "d500", "d2"} {
+				check(t, txn, dups, k)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Dirty", func(t *testing.T) {
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		for i := range sizes {
+			if err := txn.Put(plain, []byte(fmt.Sprintf("k%d", i)), make([]byte, 2*sizes[i]+1), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := txn.Del(plain, []byte("k1"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Put(dups, []byte("d3"), []byte("0"), 0); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range keys {
+			check(t, txn, plain, k)
+		}
+		check(t, txn, dups, "d3")
+		if n, err := txn.GetSize(plain, []byte("k6")); err != nil || n != 2*sizes[6]+1 {
+			t.Fatalf("GetSize of a rewritten big value = %d, %v", n, err)
+		}
+	})
+
+	t.Run("BadHandle", func(t *testing.T) {
+		err := env.View(func(txn *gdbx.Txn) error {
+			if _, err := txn.GetSize(gdbx.DBI(1000), []byte("k")); gdbx.Code(err) != gdbx.ErrBadDBI {
+				return fmt.Errorf("GetSize on a bad handle: %v", err)
+			}
+			if _, err := txn.GetSize(gdbx.FreeDBI, []byte("k")); gdbx.Code(err) != gdbx.ErrBadDBI {
+				return fmt.Errorf("GetSize on the GC table: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
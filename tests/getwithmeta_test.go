@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestGetWithMeta checks that GetWithMeta tells an absent key from one
+// stored with an empty value without returning ErrNotFound, in plain and
+// DupSort tables, in empty tables, and still returns real errors.
+func TestGetWithMeta(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := txn.OpenDBISimple("empty", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range [][2]string{{"full", "value"}, {"blank", ""}} {
+		if err := txn.Put(plain, []byte(kv[0]), []byte(kv[1]), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range []string{"b", "a", "c"} {
+		if err := txn.Put(dups, []byte("k"), []byte(v), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		dbi     gdbx.DBI
+		key     string
+		value   string
+		present bool
+	}{
+		{"stored value", plain, "full", "value", true},
+		{"empty value", plain, "blank", "", true},
+		{"absent key", plain, "missing", "", false},
+		{"key between stored ones", plain, "c", "", false},
+		{"DupSort key", dups, "k", "a", true},
+		{"absent DupSort key", dups, "j", "", false},
+		{"empty table", empty, "full", "", false},
+	} {
+		v, present, err := txn.GetWithMeta(tc.dbi, []byte(tc.key))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if present != tc.present || string(v) != tc.value || (v != nil) != tc.present {
+			t.Fatalf("%s: got %q (nil %v), %v, want %q, %v", tc.name, v, v == nil, present, tc.value, tc.present)
+		}
+	}
+
+	if _, present, err := txn.GetWithMeta(gdbx.FreeDBI, []byte("k")); gdbx.Code(err) != gdbx.ErrBadDBI || present {
+		t.Fatalf("GC table: got %v, %v, want ErrBadDBI", present, err)
+	}
+}
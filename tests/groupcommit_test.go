@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestGroupCommit commits from several goroutines at once. Without group
+// commit every commit syncs; with it the commits share far fewer syncs, and
+// each one is still readable once Commit returns, and kept by Open after a
+// system restart.
+func TestGroupCommit(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer func() { env.Close() }()
+
+	fsyncs := func() uint64 {
+		t.Helper()
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.PageOps.Fsync
+	}
+	const writers, commits = 8, 20
+	burst := func(round int) uint64 {
+		t.Helper()
+		before := fsyncs()
+		var wg sync.WaitGroup
+		errs := make(chan error, writers)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < commits; i++ {
+					key := []byte(fmt.Sprintf("r%d-w%d-%03d", round, w, i))
+					err := env.Update(func(txn *gdbx.Txn) error {
+						return txn.Put(gdbx.MainDBI, key, key, 0)
+					})
+					if err == nil {
+						err = env.View(func(txn *gdbx.Txn) error {
+							_, err := txn.Get(gdbx.MainDBI, key)
+							return err
+						})
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatal(err)
+		}
+		return fsyncs() - before
+	}
+
+	if n := burst(0); n != writers*commits {
+		t.Fatalf("without group commit: %d syncs for %d commits", n, writers*commits)
+	}
+	if err := env.SetGroupCommit(2 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if n := burst(1); n == 0 || n > writers*commits/2 {
+		t.Fatalf("with group commit: %d syncs for %d commits", n, writers*commits)
+	}
+
+	if err := env.SetGroupCommit(-time.Second); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("negative window: got %v, want ErrInvalid", err)
+	}
+
+	// The shared sync signed the last commit's meta steady
+	if runtime.GOOS == "linux" {
+		env.CloseEx(true)
+		simulateRestart(t, filepath.Join(db.path, gdbx.DataFileName))
+		env = openGdbxEnv(t, db.path, 0)
+		stat, err := env.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Entries != 2*writers*commits {
+			t.Fatalf("%d keys after a restart, want %d", stat.Entries, 2*writers*commits)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestGetBothRange8ByteValues looks up 8-byte values in large sub-trees, with
+// and without DupFixed, where the search compares them as integers. Exact,
+// in-between, below-first and past-last values must land where a bytewise
+// search would.
+func TestGetBothRange8ByteValues(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{
+		{"dupsort", gdbx.DupSort},
+		{"dupfixed", gdbx.DupSort | gdbx.DupFixed | gdbx.IntegerDup},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			defer db.cleanup()
+
+			env := openGdbxEnv(t, db.path, 0)
+			defer env.Close()
+
+			// Values 10, 20, ..., with the high bytes set on some so that
+			// byte order matters
+			const perKey = 20000
+			val := func(i int) []byte {
+				return binary.BigEndian.AppendUint64(nil, uint64(i%3)<<56|uint64(i+1)*10)
+			}
+			var dbi gdbx.DBI
+			err := env.Update(func(txn *gdbx.Txn) error {
+				var err error
+				if dbi, err = txn.OpenDBISimple("ids", gdbx.Create|tc.flags); err != nil {
+					return err
+				}
+				for i := 0; i < perKey; i++ {
+					if err := txn.Put(dbi, []byte("k"), val(i), 0); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer txn.Abort()
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cur.Close()
+
+			expect := func(op uint, search []byte, want []byte) {
+				t.Helper()
+				_, v, err := cur.Get([]byte("k"), search, op)
+				if want == nil {
+					if !gdbx.IsNotFound(err) {
+						t.Fatalf("Get(%x): got %x, %v, want not found", search, v, err)
+					}
+					return
+				}
+				if err != nil || string(v) != string(want) {
+					t.Fatalf("Get(%x) = %x, %v, want %x", search, v, err, want)
+				}
+			}
+			// Sorted bytewise, the values run 0<<56 | ..., 1<<56 | ..., 2<<56 | ...
+			sorted := make([][]byte, 0, perKey)
+			for hi := 0; hi < 3; hi++ {
+				for i := hi; i < perKey; i += 3 {
+					sorted = append(sorted, val(i))
+				}
+			}
+			for n, v := range sorted {
+				if n%97 != 0 && n != len(sorted)-1 {
+					continue
+				}
+				expect(gdbx.GetBoth, v, v)
+				expect(gdbx.GetBothRange, v, v)
+				below := binary.BigEndian.AppendUint64(nil, binary.BigEndian.Uint64(v)-1)
+				expect(gdbx.GetBoth, below, nil)
+				expect(gdbx.GetBothRange, below, v)
+				// The cursor continues from the value it landed on
+				if n+1 < len(sorted) {
+					if _, next, err := cur.Get(nil, nil, gdbx.NextDup); err != nil || string(next) != string(sorted[n+1]) {
+						t.Fatalf("NextDup after %x = %x, %v", v, next, err)
+					}
+				}
+			}
+			expect(gdbx.GetBothRange, make([]byte, 8), sorted[0])
+			expect(gdbx.GetBothRange, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, nil)
+		})
+	}
+}
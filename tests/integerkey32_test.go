@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestIntegerKeyUint32 fills an IntegerKey table with uint32 keys in random
+// order and checks that they iterate and SetRange numerically, and that keys
+// of another width are refused while the table holds 4-byte keys, and
+// likewise for a table of 8-byte keys.
+func TestIntegerKeyUint32(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	key32 := func(n uint32) []byte { return binary.BigEndian.AppendUint32(nil, n) }
+	key64 := func(n uint64) []byte { return binary.BigEndian.AppendUint64(nil, n) }
+
+	rng := rand.New(rand.NewSource(1))
+	keys := make([]uint32, 5000)
+	for i := range keys {
+		keys[i] = rng.Uint32()
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	u32, err := txn.OpenDBISimple("u32", gdbx.Create|gdbx.IntegerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u64, err := txn.OpenDBISimple("u64", gdbx.Create|gdbx.IntegerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if err := txn.Put(u32, key32(k), []byte("v"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := txn.Put(u64, key64(1<<40), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		dbi  gdbx.DBI
+		key  []byte
+	}{
+		{"8-byte key in a uint32 table", u32, key64(7)},
+		{"3-byte key", u32, []byte{1, 2, 3}},
+		{"4-byte key in a uint64 table", u64, key32(7)},
+		{"empty key", u64, nil},
+	} {
+		if err := txn.Put(tc.dbi, tc.key, []byte("v"), 0); gdbx.Code(err) != gdbx.ErrBadKeySize {
+			t.Fatalf("%s: got %v, want ErrBadKeySize", tc.name, err)
+		}
+	}
+
+	stat, err := txn.Stat(u32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Depth < 2 {
+		t.Fatalf("test tree has depth %d, want branch pages", stat.Depth)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	if stat.Entries != uint64(len(keys)) {
+		t.Fatalf("%d entries, want %d", stat.Entries, len(keys))
+	}
+
+	cur, err := txn.OpenCursor(u32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	i := 0
+	for k, _, err := cur.Get(nil, nil, gdbx.First); err == nil; k, _, err = cur.Get(nil, nil, gdbx.Next) {
+		if got := binary.BigEndian.Uint32(k); got != keys[i] {
+			t.Fatalf("key %d is %d, want %d", i, got, keys[i])
+		}
+		i++
+	}
+	if i != len(keys) {
+		t.Fatalf("iterated %d keys, want %d", i, len(keys))
+	}
+
+	for range 2000 {
+		target := rng.Uint32()
+		j, _ := slices.BinarySearch(keys, target)
+		k, _, err := cur.Get(key32(target), nil, gdbx.SetRange)
+		if j == len(keys) {
+			if !gdbx.IsNotFound(err) {
+				t.Fatalf("SetRange(%d) past the last key: %x, %v", target, k, err)
+			}
+			continue
+		}
+		if err != nil || binary.BigEndian.Uint32(k) != keys[j] {
+			t.Fatalf("SetRange(%d) = %x, %v, want %d", target, k, err, keys[j])
+		}
+	}
+}
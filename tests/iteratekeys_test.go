@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCursorIterateKeys checks that IterateKeys yields each key of a DupSort
+// table once and in order, whether it holds a single value, a sub-page or a
+// sub-tree, that it works on plain and empty tables, and that breaking out
+// leaves the cursor on the last key yielded.
+func TestCursorIterateKeys(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := txn.OpenDBISimple("empty", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key%04d", i)
+		want = append(want, key)
+		values := []int{1, 10, 1000}[i%3]
+		for j := 0; j < values; j++ {
+			if err := txn.Put(dups, []byte(key), []byte(fmt.Sprintf("value%05d", j)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := txn.Put(plain, []byte(key), []byte("v"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	collect := func(dbi gdbx.DBI) []string {
+		t.Helper()
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		var keys []string
+		for k := range cur.IterateKeys() {
+			keys = append(keys, string(k))
+		}
+		if err := cur.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	if got := collect(dups); !slices.Equal(got, want) {
+		t.Fatalf("DupSort table: %d keys %q .. , want %d", len(got), got[:min(len(got), 5)], len(want))
+	}
+	if got := collect(plain); !slices.Equal(got, want) {
+		t.Fatalf("plain table: %d keys, want %d", len(got), len(want))
+	}
+	if got := collect(empty); len(got) != 0 {
+		t.Fatalf("empty table yielded %q", got)
+	}
+
+	cur, err := txn.OpenCursor(dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	// IterateKeys starts at the first key wherever the cursor stands
+	if _, _, err := cur.Get([]byte("key0100"), nil, gdbx.Set); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for k := range cur.IterateKeys() {
+		if n == 0 && string(k) != want[0] {
+			t.Fatalf("iteration starts at %q, want %q", k, want[0])
+		}
+		if n++; n == 50 {
+			break
+		}
+	}
+	k, _, err := cur.Get(nil, nil, gdbx.GetCurrent)
+	if err != nil || string(k) != want[49] {
+		t.Fatalf("after breaking out the cursor is on %q, %v, want %q", k, err, want[49])
+	}
+	if k, _, err = cur.Get(nil, nil, gdbx.NextNoDup); err != nil || string(k) != want[50] {
+		t.Fatalf("NextNoDup after breaking out: %q, %v, want %q", k, err, want[50])
+	}
+}
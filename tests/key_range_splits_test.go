@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestKeyRangeSplits cuts a plain and a DupSort table into ranges, scans
+// each range from its own goroutine and read transaction with SetBounds, and
+// checks that the ranges are ordered, cover every entry once, keep a key's
+// values together and are roughly balanced.
+func TestKeyRangeSplits(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	var plain, dups, empty gdbx.DBI
+	err := env.Update(func(txn *gdbx.Txn) error {
+		var err error
+		if plain, err = txn.OpenDBISimple("plain", gdbx.Create); err != nil {
+			return err
+		}
+		if dups, err = txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort); err != nil {
+			return err
+		}
+		if empty, err = txn.OpenDBISimple("empty", gdbx.Create); err != nil {
+			return err
+		}
+		for i := 0; i < 20000; i++ {
+			if err := txn.Put(plain, []byte(fmt.Sprintf("key-%06d", i*7919%20000)), bytes.Repeat([]byte{'v'}, 30), 0); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < 2000; i++ {
+			for j := 0; j < 1+i%5*40; j++ {
+				if err := txn.Put(dups, []byte(fmt.Sprintf("key-%05d", i)), []byte(fmt.Sprintf("dup-%04d", j)), 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scan counts the entries of each range and the keys seen in more than one
+	scan := func(t *testing.T, dbi gdbx.DBI, ranges [][2][]byte) []int {
+		counts := make([]int, len(ranges))
+		keys := make([]map[string]bool, len(ranges))
+		var wg sync.WaitGroup
+		errs := make(chan error, len(ranges))
+		for i, r := range ranges {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				keys[i] = map[string]bool{}
+				errs <- env.View(func(txn *gdbx.Txn) error {
+					cur, err := txn.OpenCursor(dbi)
+					if err != nil {
+						return err
+					}
+					defer cur.Close()
+					cur.SetBounds(r[0], r[1])
+					for k, _, err := cur.Get(nil, nil, gdbx.First); ; k, _, err = cur.Get(nil, nil, gdbx.Next) {
+						if gdbx.IsNotFound(err) {
+							return nil
+						}
+						if err != nil {
+							return err
+						}
+						counts[i]++
+						keys[i][string(k)] = true
+					}
+				})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		seen := map[string]int{}
+		for i := range keys {
+			for k := range keys[i] {
+				if j, ok := seen[k]; ok {
+					t.Fatalf("key %q in ranges %d and %d", k, j, i)
+				}
+				seen[k] = i
+			}
+		}
+		return counts
+	}
+
+	for _, tc := range []struct {
+		name  string
+		dbi   gdbx.DBI
+		total int
+	}{
+		{"plain", plain, 20000},
+		{"dupsort", dups, 2000 * 81},
+	} {
+		for _, n := range []int{1, 2, 4, 7, 16, 5000} {
+			t.Run(fmt.Sprintf("%s/%d", tc.name, n), func(t *testing.T) {
+				ranges, err := env.KeyRangeSplits(tc.dbi, n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ranges) == 0 || len(ranges) > n {
+					t.Fatalf("%d ranges for n=%d", len(ranges), n)
+				}
+				if ranges[0][0] != nil || ranges[len(ranges)-1][1] != nil {
+					t.Fatalf("outer bounds %q and %q, want nil", ranges[0][0], ranges[len(ranges)-1][1])
+				}
+				for i := 1; i < len(ranges); i++ {
+					if !bytes.Equal(ranges[i][0], ranges[i-1][1]) || bytes.Compare(ranges[i][0], ranges[i-1][0]) <= 0 && i > 1 {
+						t.Fatalf("range %d %q follows %q", i, ranges[i], ranges[i-1])
+					}
+				}
+				if n > 1 && n <= 16 && len(ranges) < n/2 {
+					t.Fatalf("%d ranges for n=%d", len(ranges), n)
+				}
+				counts := scan(t, tc.dbi, ranges)
+				sum := 0
+				for _, c := range counts {
+					sum += c
+				}
+				if sum != tc.total {
+					t.Fatalf("ranges hold %d entries, want %d", sum, tc.total)
+				}
+				if n > 1 && n <= 16 {
+					for i, c := range counts {
+						if c > 3*tc.total/len(ranges) {
+							t.Errorf("range %d holds %d of %d entries", i, c, tc.total)
+						}
+					}
+				}
+			})
+		}
+	}
+
+	ranges, err := env.KeyRangeSplits(empty, 8)
+	if err != nil || len(ranges) != 1 || ranges[0][0] != nil || ranges[0][1] != nil {
+		t.Fatalf("empty table: %q, %v; want one open range", ranges, err)
+	}
+	if _, err := env.KeyRangeSplits(plain, 0); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("n=0: %v, want ErrInvalid", err)
+	}
+	if _, err := env.KeyRangeSplits(gdbx.DBI(200), 4); gdbx.Code(err) != gdbx.ErrBadDBI {
+		t.Fatalf("unknown DBI: %v, want ErrBadDBI", err)
+	}
+}
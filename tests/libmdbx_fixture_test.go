@@ -0,0 +1,368 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// The fixture testdata/libmdbx/mdbx.dat was written by the C library, through
+// mdbx-go, with TestWriteLibmdbxFixture. It holds the tables libmdbxFixture
+// describes, and a GC with the pages its last commit freed.
+
+// fixtureTable is a table of the libmdbx fixture and its pairs in table order.
+type fixtureTable struct {
+	name  string
+	flags uint
+	pairs [][2][]byte
+}
+
+// libmdbxFixture returns the contents of the libmdbx fixture: a plain table
+// of several levels with big values, a DupSort table whose values sit in
+// sub-pages and, for one key, a sub-tree, the same for DupFixed values, an
+// IntegerKey table and an IntegerDup one.
+func libmdbxFixture() []fixtureTable {
+	u64 := func(n uint64, order binary.ByteOrder) []byte {
+		b := make([]byte, 8)
+		order.PutUint64(b, n)
+		return b
+	}
+
+	plain := fixtureTable{name: "plain"}
+	for i := 0; i < 400; i++ {
+		n := 10 + i%90
+		switch i {
+		case 50, 150, 250:
+			n = 5000 + i*50
+		}
+		v := strings.Repeat(fmt.Sprintf("%d.", i), n)[:n]
+		plain.pairs = append(plain.pairs, [2][]byte{[]byte(fmt.Sprintf("key-%04d", i)), []byte(v)})
+	}
+
+	dups := fixtureTable{name: "dupsort", flags: gdbx.DupSort}
+	for i := 0; i < 60; i++ {
+		n := 1
+		if i%3 != 0 {
+			n = i%7 + 2
+		}
+		for j := 0; j < n; j++ {
+			dups.pairs = append(dups.pairs, [2][]byte{[]byte(fmt.Sprintf("d-%03d", i)), []byte(fmt.Sprintf("v-%d-%02d", i, j))})
+		}
+	}
+	for j := 0; j < 700; j++ {
+		dups.pairs = append(dups.pairs, [2][]byte{[]byte("d-many"), []byte(fmt.Sprintf("value-%05d", j))})
+	}
+
+	fixed := fixtureTable{name: "dupfixed", flags: gdbx.DupSort | gdbx.DupFixed}
+	for i := 0; i < 20; i++ {
+		for j := 0; j <= i%5; j++ {
+			fixed.pairs = append(fixed.pairs, [2][]byte{[]byte(fmt.Sprintf("f-%02d", i)), u64(uint64(i*100+j), binary.BigEndian)})
+		}
+	}
+	for j := 0; j < 1500; j++ {
+		fixed.pairs = append(fixed.pairs, [2][]byte{[]byte("f-many"), u64(uint64(j*3), binary.BigEndian)})
+	}
+
+	ints := fixtureTable{name: "intkey", flags: gdbx.IntegerKey}
+	for i := 0; i < 300; i++ {
+		ints.pairs = append(ints.pairs, [2][]byte{u64(uint64(i*7+1), binary.LittleEndian), []byte(fmt.Sprintf("int-%d", i))})
+	}
+
+	intDups := fixtureTable{name: "intdup", flags: gdbx.DupSort | gdbx.DupFixed | gdbx.IntegerDup}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 1+i*5; j++ {
+			v := make([]byte, 4)
+			binary.LittleEndian.PutUint32(v, uint32(j*1000+i))
+			intDups.pairs = append(intDups.pairs, [2][]byte{[]byte(fmt.Sprintf("i-%02d", i)), v})
+		}
+	}
+
+	return []fixtureTable{plain, dups, fixed, ints, intDups}
+}
+
+// TestWriteLibmdbxFixture writes testdata/libmdbx/mdbx.dat with libmdbx when
+// GDBX_WRITE_FIXTURE is set. The first commit puts the fixture tables, pairs
+// that the second commit deletes again, and a table it drops, so that the
+// second leaves freed pages in the GC.
+func TestWriteLibmdbxFixture(t *testing.T) {
+	if os.Getenv("GDBX_WRITE_FIXTURE") == "" {
+		t.Skip("set GDBX_WRITE_FIXTURE to write testdata/libmdbx/mdbx.dat")
+	}
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	menv.SetGeometry(-1, -1, 1<<26, 1<<16, -1, 4096)
+	if err := menv.Open(db.path, 0, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = menv.Update(func(txn *mdbx.Txn) error {
+		for _, tbl := range libmdbxFixture() {
+			dbi, err := txn.OpenDBI(tbl.name, mdbx.Create|tbl.flags, nil, nil)
+			if err != nil {
+				return err
+			}
+			for _, kv := range tbl.pairs {
+				if err := txn.Put(dbi, kv[0], kv[1], 0); err != nil {
+					return fmt.Errorf("%s: %w", tbl.name, err)
+				}
+			}
+		}
+		plain, err := txn.OpenDBI("plain", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		dropped, err := txn.OpenDBI("dropped", mdbx.Create, nil, nil)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 300; i++ {
+			if err := txn.Put(plain, []byte(fmt.Sprintf("gone-%04d", i)), bytes.Repeat([]byte{'g'}, 100), 0); err != nil {
+				return err
+			}
+			if err := txn.Put(dropped, []byte(fmt.Sprintf("k-%04d", i)), bytes.Repeat([]byte{'x'}, 60), 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = menv.Update(func(txn *mdbx.Txn) error {
+		plain, err := txn.OpenDBI("plain", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 300; i++ {
+			if err := txn.Del(plain, []byte(fmt.Sprintf("gone-%04d", i)), nil); err != nil {
+				return err
+			}
+		}
+		dropped, err := txn.OpenDBI("dropped", 0, nil, nil)
+		if err != nil {
+			return err
+		}
+		return txn.Drop(dropped, true)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	menv.Close()
+
+	data, err := os.ReadFile(filepath.Join(db.path, "mdbx.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join("testdata", "libmdbx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("testdata", "libmdbx", "mdbx.dat"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkFixture checks that txn, on a copy of the libmdbx fixture, holds the
+// fixture tables and nothing else, walking each with a cursor and looking up
+// each pair.
+func checkFixture(txn *gdbx.Txn) error {
+	for _, tbl := range libmdbxFixture() {
+		dbi, err := openFixtureTable(txn, tbl.name, tbl.flags)
+		if err != nil {
+			return fmt.Errorf("%s: %w", tbl.name, err)
+		}
+		stat, err := txn.Stat(dbi)
+		if err != nil {
+			return err
+		}
+		if stat.Entries != uint64(len(tbl.pairs)) {
+			return fmt.Errorf("%s: %d entries, want %d", tbl.name, stat.Entries, len(tbl.pairs))
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		i := 0
+		k, v, err := cur.Get(nil, nil, gdbx.First)
+		for ; err == nil; k, v, err = cur.Get(nil, nil, gdbx.Next) {
+			if i == len(tbl.pairs) {
+				cur.Close()
+				return fmt.Errorf("%s: pair %x=%x past the last one", tbl.name, k, v)
+			}
+			want := tbl.pairs[i]
+			if !bytes.Equal(k, want[0]) || !bytes.Equal(v, want[1]) {
+				cur.Close()
+				return fmt.Errorf("%s: pair %d is %x=%.40x, want %x=%.40x", tbl.name, i, k, v, want[0], want[1])
+			}
+			i++
+		}
+		cur.Close()
+		if !gdbx.IsNotFound(err) {
+			return fmt.Errorf("%s: %w", tbl.name, err)
+		}
+		if i != len(tbl.pairs) {
+			return fmt.Errorf("%s: walked %d pairs, want %d", tbl.name, i, len(tbl.pairs))
+		}
+
+		for _, kv := range tbl.pairs {
+			if tbl.flags&gdbx.DupSort != 0 {
+				if !hasPair(txn, dbi, kv[0], kv[1]) {
+					return fmt.Errorf("%s: GetBoth %x=%x finds nothing", tbl.name, kv[0], kv[1])
+				}
+				continue
+			}
+			if got, err := txn.Get(dbi, kv[0]); err != nil || !bytes.Equal(got, kv[1]) {
+				return fmt.Errorf("%s: Get %x = %.40x (%v)", tbl.name, kv[0], got, err)
+			}
+		}
+	}
+	if _, err := txn.OpenDBISimple("dropped", 0); !gdbx.IsNotFound(err) {
+		return fmt.Errorf("dropped table opens: %v", err)
+	}
+	return nil
+}
+
+// openFixtureTable opens a table of the libmdbx fixture, ordering integer
+// keys and values natively as libmdbx wrote them.
+func openFixtureTable(txn *gdbx.Txn, name string, flags uint) (gdbx.DBI, error) {
+	return txn.OpenDBIWithSpec(name, gdbx.DBISpec{Flags: flags, NativeIntegers: true})
+}
+
+// hasPair reports whether a table holds the pair k, v.
+func hasPair(txn *gdbx.Txn, dbi gdbx.DBI, k, v []byte) bool {
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return false
+	}
+	defer cur.Close()
+	op := uint(gdbx.Set)
+	if flags, err := txn.Flags(dbi); err == nil && flags&gdbx.DupSort != 0 {
+		op = gdbx.GetBoth
+	}
+	_, got, err := cur.Get(k, v, op)
+	return err == nil && bytes.Equal(got, v)
+}
+
+// TestOpenLibmdbxFixture opens a copy of a file written by libmdbx, checks
+// every table by cursor and lookup, and that the GC the C library left is
+// there. It then commits on the file and checks the result with Verify and
+// with libmdbx.
+func TestOpenLibmdbxFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "libmdbx", "mdbx.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	defer db.cleanup()
+	if err := os.WriteFile(filepath.Join(db.path, "mdbx.dat"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	if err := env.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *gdbx.Txn) error {
+		gc, err := txn.Stat(gdbx.FreeDBI)
+		if err != nil {
+			return err
+		}
+		if gc.Entries == 0 {
+			return fmt.Errorf("the GC is empty")
+		}
+		return checkFixture(txn)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Put a pair into each table, a value into a sub-page libmdbx wrote with
+	// room to spare, and take them out again
+	err = env.Update(func(txn *gdbx.Txn) error {
+		for _, put := range []struct {
+			table string
+			flags uint
+			k, v  []byte
+		}{
+			{"plain", 0, []byte("key-9999"), []byte("added by gdbx")},
+			{"dupsort", gdbx.DupSort, []byte("d-001"), []byte("v-1-00x")},
+			{"dupfixed", gdbx.DupSort | gdbx.DupFixed, []byte("f-01"), binary.BigEndian.AppendUint64(nil, 150)},
+			{"intkey", gdbx.IntegerKey, binary.LittleEndian.AppendUint64(nil, 1000), []byte("added by gdbx")},
+			{"intdup", gdbx.DupSort | gdbx.DupFixed | gdbx.IntegerDup, []byte("i-09"), binary.LittleEndian.AppendUint32(nil, 500)},
+		} {
+			dbi, err := openFixtureTable(txn, put.table, put.flags)
+			if err != nil {
+				return err
+			}
+			if err := txn.Put(dbi, put.k, put.v, 0); err != nil {
+				return fmt.Errorf("%s: %w", put.table, err)
+			}
+			if !hasPair(txn, dbi, put.k, put.v) {
+				return fmt.Errorf("%s: %x=%x put by gdbx is missing", put.table, put.k, put.v)
+			}
+			if err := txn.Del(dbi, put.k, put.v); err != nil {
+				return fmt.Errorf("%s: %w", put.table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.View(checkFixture); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, 0, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = menv.View(func(txn *mdbx.Txn) error {
+		for _, tbl := range libmdbxFixture() {
+			dbi, err := txn.OpenDBI(tbl.name, tbl.flags, nil, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", tbl.name, err)
+			}
+			stat, err := txn.StatDBI(dbi)
+			if err != nil {
+				return err
+			}
+			if stat.Entries != uint64(len(tbl.pairs)) {
+				return fmt.Errorf("libmdbx counts %d entries in %s, want %d", stat.Entries, tbl.name, len(tbl.pairs))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestLockFileOwner pairs a database with the lock file of another, copied
+// with its owner record while that one had a reader, and checks that Open
+// resets it, dropping the foreign reader and saying so, when no one else has
+// the database open; that it refuses a lock file put in place of its own
+// with a clear error while another process is using the database, and
+// read-only; and that the recorded lock file serves concurrent openers of
+// its own database.
+func TestLockFileOwner(t *testing.T) {
+	dbA := newTestDB(t)
+	defer dbA.cleanup()
+	dbB := newTestDB(t)
+	defer dbB.cleanup()
+	lockA := filepath.Join(dbA.path, gdbx.LockFileName)
+	lockB := filepath.Join(dbB.path, gdbx.LockFileName)
+
+	put := func(path, value string) {
+		t.Helper()
+		env := openGdbxEnv(t, path, 0)
+		defer env.Close()
+		if err := env.Update(func(txn *gdbx.Txn) error {
+			return txn.Put(gdbx.MainDBI, []byte("k"), []byte(value), 0)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(dbA.path, "a")
+	put(dbB.path, "b")
+
+	// A's lock file, with a reader in it
+	envA := openGdbxEnv(t, dbA.path, 0)
+	txnA, err := envA.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := os.ReadFile(lockA)
+	txnA.Abort()
+	envA.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreignOwner, err := os.ReadFile(lockA + ".owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// replace puts a copy of data at path, as a new file
+	replace := func(path string, data []byte) {
+		t.Helper()
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var warnings []string
+	gdbx.SetLogger(func(msg string, args ...any) {
+		mu.Lock()
+		warnings = append(warnings, msg)
+		mu.Unlock()
+	}, gdbx.LogLvlDoNotChange)
+	defer gdbx.SetLogger(nil, gdbx.LogLvlDoNotChange)
+
+	readers := func(env *gdbx.Env) int {
+		n := 0
+		if err := env.ReaderList(func(gdbx.ReaderInfo) error { n++; return nil }); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	check := func(env *gdbx.Env) {
+		t.Helper()
+		if err := env.View(func(txn *gdbx.Txn) error {
+			v, err := txn.Get(gdbx.MainDBI, []byte("k"))
+			if err == nil && string(v) != "b" {
+				t.Errorf("k = %q", v)
+			}
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	open := func(flags uint) (*gdbx.Env, error) {
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Open(dbB.path, flags, 0644); err != nil {
+			env.Close()
+			return nil, err
+		}
+		return env, nil
+	}
+
+	// Alone with the foreign lock file: it is reset
+	replace(lockB, foreign)
+	replace(lockB+".owner", foreignOwner)
+	env, err := open(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := readers(env); n != 0 {
+		t.Fatalf("%d readers of the other database kept", n)
+	}
+	check(env)
+	mu.Lock()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "lock file belonged to database") {
+		t.Fatalf("warnings %q", warnings)
+	}
+	mu.Unlock()
+
+	// The stamped lock file serves an opener in another process
+	if code := openInChild(t, dbB.path, 0); code != 0 {
+		t.Fatalf("second opener: got code %d", code)
+	}
+
+	// With the lock file replaced under an opener, others refuse it
+	replace(lockB, foreign)
+	for _, flags := range []uint{0, gdbx.ReadOnly} {
+		if code := openInChild(t, dbB.path, flags); code != gdbx.ErrInvalid {
+			t.Fatalf("flags %#x: got code %d, want ErrInvalid for the foreign lock file", flags, code)
+		}
+	}
+	env.Close()
+
+	// Read-only, even alone, does not reset it
+	if _, err := open(gdbx.ReadOnly); gdbx.Code(err) != gdbx.ErrInvalid || !strings.Contains(err.Error(), "was put in place of the one recorded") {
+		t.Fatalf("read-only: got %v, want ErrInvalid for the foreign lock file", err)
+	}
+	env, err = open(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if n := readers(env); n != 0 {
+		t.Fatalf("%d readers of the other database kept", n)
+	}
+	check(env)
+	mu.Lock()
+	if len(warnings) != 1 {
+		t.Fatalf("warnings %q", warnings)
+	}
+	mu.Unlock()
+}
+
+// openInChild opens the environment at path with flags from another process,
+// through TestExclusiveOpenChild, and returns the error code it got. env is
+// added to the child's environment.
+func openInChild(t *testing.T, path string, flags uint, env ...string) gdbx.ErrorCode {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExclusiveOpenChild$")
+	cmd.Env = append(os.Environ(), "GDBX_EXCLUSIVE_PATH="+path, "GDBX_EXCLUSIVE_FLAGS="+strconv.FormatUint(uint64(flags), 10))
+	cmd.Env = append(cmd.Env, env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("child: %v\n%s", err, out)
+	}
+	code, err := os.ReadFile(path + "/child-result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := strconv.Atoi(string(code))
+	return gdbx.ErrorCode(n)
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestPutMapFull fills a small map until Put fails with ErrMapFull, and checks
+// that the failed Put left the transaction usable and that committed data
+// survives both aborting and committing it.
+func TestPutMapFull(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{
+		{"default", 0},
+		{"writemap", gdbx.WriteMap},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testPutMapFull(t, tc.flags)
+		})
+	}
+}
+
+func testPutMapFull(t *testing.T, flags uint) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := newEnv(t)
+	defer env.Close()
+	if err := env.SetGeometry(-1, -1, 1<<20, -1, -1, 4096); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Open(db.path, flags, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%06d", i)) }
+	val := func(i int) []byte { return bytes.Repeat([]byte{byte(i)}, 100+i%300) }
+
+	// fill puts keys from start until the map is full and returns the first key that failed
+	fill := func(txn *gdbx.Txn, start int) int {
+		t.Helper()
+		for i := start; ; i++ {
+			err := txn.Put(gdbx.MainDBI, key(i), val(i), 0)
+			if gdbx.IsMapFull(err) {
+				return i
+			}
+			if err != nil {
+				t.Fatalf("Put %d: %v", i, err)
+			}
+		}
+	}
+	verify := func(n int) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		stat, err := txn.Stat(gdbx.MainDBI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Entries != uint64(n) {
+			t.Fatalf("%d entries, want %d", stat.Entries, n)
+		}
+		for i := 0; i < n; i++ {
+			if v, err := txn.Get(gdbx.MainDBI, key(i)); err != nil || !bytes.Equal(v, val(i)) {
+				t.Fatalf("key %d: %v", i, err)
+			}
+		}
+	}
+
+	// Commit a base that uses part of the map
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		if err := txn.Put(gdbx.MainDBI, key(i), val(i), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the rest and abort: the committed base is intact
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := fill(txn, 500)
+	if full == 500 {
+		t.Fatal("map was full before the second transaction wrote anything")
+	}
+
+	// The failed Put changed nothing: reads and deletes still work
+	if _, err := txn.Get(gdbx.MainDBI, key(full)); !gdbx.IsNotFound(err) {
+		t.Fatalf("failed key is visible: %v", err)
+	}
+	if v, err := txn.Get(gdbx.MainDBI, key(full-1)); err != nil || !bytes.Equal(v, val(full-1)) {
+		t.Fatalf("last stored key: %v", err)
+	}
+	txn.Abort()
+	verify(500)
+
+	// Fill again and commit everything stored before the failure
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full = fill(txn, 500)
+	if _, err := txn.Commit(); err != nil {
+		t.Fatalf("Commit after ErrMapFull: %v", err)
+	}
+	verify(full)
+
+	// The map stays full for later transactions
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := fill(txn, full); next > full+1 {
+		t.Fatalf("%d more keys fit into a full map", next-full)
+	}
+	txn.Abort()
+	verify(full)
+}
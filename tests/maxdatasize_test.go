@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestMaxDataSize checks that every way of storing a value refuses sizes
+// above MaxDataSize, those past the 4GB a node's 32-bit size can hold
+// included, with ErrBadValSize and without growing the file or allocating
+// the value, and that the table is unchanged afterwards.
+func TestMaxDataSize(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("blobs", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.Join(db.path, gdbx.DataFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(what string, err error) {
+		t.Helper()
+		if gdbx.Code(err) != gdbx.ErrBadValSize {
+			t.Fatalf("%s: got %v, want ErrBadValSize", what, err)
+		}
+	}
+	// Sizes that would wrap a 32-bit size to something small
+	for _, n := range []int{gdbx.MaxDataSize + 1, 1 << 32, 1<<32 + 100} {
+		_, err := txn.PutReserve(dbi, []byte("big"), n, 0)
+		check("PutReserve", err)
+		_, err = txn.GetWriter(dbi, []byte("big"), int64(n), 0)
+		check("GetWriter", err)
+		check("PutWithCap", txn.PutWithCap(dbi, []byte("big"), []byte("small"), n, 0))
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cur.PutReserve([]byte("big"), n, 0)
+		check("Cursor.PutReserve", err)
+		cur.Close()
+	}
+	// The value itself is never touched, so this costs no memory
+	check("Put", txn.Put(dbi, []byte("big"), make([]byte, gdbx.MaxDataSize+1), 0))
+
+	if _, err := txn.Get(dbi, []byte("big")); !gdbx.IsNotFound(err) {
+		t.Fatalf("a refused value was stored: %v", err)
+	}
+	if _, err := txn.PutReserve(dbi, []byte("neg"), -1, 0); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("negative PutReserve: got %v, want ErrInvalid", err)
+	}
+	if err := txn.Put(dbi, []byte("ok"), make([]byte, 1<<20), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(filepath.Join(db.path, gdbx.DataFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() > fi.Size()+64<<20 {
+		t.Fatalf("refused values grew the file from %d to %d bytes", fi.Size(), after.Size())
+	}
+}
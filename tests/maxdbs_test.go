@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestMaxDBsAcrossReopens creates named databases over several opens of one
+// file with different SetMaxDBs limits. Each open allows exactly that many
+// named databases, existing or new; one more fails with ErrDBsFull without
+// leaving a record behind or touching the core databases, and every
+// database created keeps its contents.
+func TestMaxDBsAcrossReopens(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	open := func(maxDBs uint32) *gdbx.Env {
+		t.Helper()
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.SetMaxDBs(maxDBs); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Open(db.path, 0, 0644); err != nil {
+			env.Close()
+			t.Fatal(err)
+		}
+		return env
+	}
+	name := func(i int) string { return fmt.Sprintf("db%02d", i) }
+
+	created := 0
+	for _, maxDBs := range []int{3, 6, 20} {
+		env := open(uint32(maxDBs))
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < maxDBs; i++ {
+			dbi, err := txn.OpenDBISimple(name(i), gdbx.Create)
+			if err != nil {
+				t.Fatalf("MaxDBs %d: opening %s: %v", maxDBs, name(i), err)
+			}
+			if dbi < gdbx.CoreDBs {
+				t.Fatalf("MaxDBs %d: %s got core handle %d", maxDBs, name(i), dbi)
+			}
+			if err := txn.Put(dbi, []byte("owner"), []byte(name(i)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		created = maxDBs
+		if _, err := txn.OpenDBISimple(name(maxDBs), gdbx.Create); gdbx.Code(err) != gdbx.ErrDBsFull {
+			t.Fatalf("MaxDBs %d: one database too many: got %v, want ErrDBsFull", maxDBs, err)
+		}
+		if _, err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		env.Close()
+	}
+
+	// A smaller limit still applies to existing databases
+	env := open(2)
+	if err := env.View(func(txn *gdbx.Txn) error {
+		for i := 0; i < 2; i++ {
+			if _, err := txn.OpenDBISimple(name(i), 0); err != nil {
+				return err
+			}
+		}
+		if _, err := txn.OpenDBISimple(name(2), 0); gdbx.Code(err) != gdbx.ErrDBsFull {
+			return fmt.Errorf("third database under MaxDBs 2: got %v, want ErrDBsFull", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	env = open(gdbx.MaxDBI + 10)
+	defer env.Close()
+	if got := env.MaxDBs(); got != gdbx.MaxDBI {
+		t.Fatalf("MaxDBs() = %d, want the cap %d", got, gdbx.MaxDBI)
+	}
+	if err := env.View(func(txn *gdbx.Txn) error {
+		names, err := txn.ListDBI()
+		if err != nil {
+			return err
+		}
+		var want []string
+		for i := 0; i < created; i++ {
+			want = append(want, name(i))
+		}
+		slices.Sort(names)
+		if !slices.Equal(names, want) {
+			return fmt.Errorf("databases %q, want %q", names, want)
+		}
+		for _, n := range want {
+			dbi, err := txn.OpenDBISimple(n, 0)
+			if err != nil {
+				return err
+			}
+			if v, err := txn.Get(dbi, []byte("owner")); err != nil || string(v) != n {
+				return fmt.Errorf("%s holds %q, %v", n, v, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
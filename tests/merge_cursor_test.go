@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestMergeCursor fills three plain and three DupSort tables with random,
+// overlapping entries and checks that a MergeCursor over each set yields the
+// entries of all of them in sorted order with their source, every copy of an
+// entry with MergeAll and the first source's with MergeDedup, from First and
+// from Seek. It also checks that tables ordered differently are refused.
+func TestMergeCursor(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	type entry struct {
+		key, value string
+		source     int
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, dupSort := range []bool{false, true} {
+		flags := gdbx.Create
+		if dupSort {
+			flags |= gdbx.DupSort
+		}
+		var all []entry
+		var cursors []*gdbx.Cursor
+		for s := 0; s < 3; s++ {
+			dbi, err := txn.OpenDBISimple(fmt.Sprintf("src%d-%v", s, dupSort), flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored := map[entry]bool{}
+			for i := 0; i < 300; i++ {
+				e := entry{fmt.Sprintf("k%03d", rng.Intn(200)), fmt.Sprintf("v%d", rng.Intn(3)), s}
+				if !dupSort {
+					e.value = fmt.Sprintf("v%d-%s", s, e.key)
+				}
+				if err := txn.Put(dbi, []byte(e.key), []byte(e.value), 0); err != nil {
+					t.Fatal(err)
+				}
+				if !stored[e] {
+					stored[e] = true
+					all = append(all, e)
+				}
+			}
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cursors = append(cursors, cur)
+		}
+		slices.SortFunc(all, func(a, b entry) int {
+			if c := strings.Compare(a.key, b.key); c != 0 {
+				return c
+			}
+			if dupSort {
+				if c := strings.Compare(a.value, b.value); c != 0 {
+					return c
+				}
+			}
+			return a.source - b.source
+		})
+		var dedup []entry
+		for _, e := range all {
+			if n := len(dedup); n > 0 && dedup[n-1].key == e.key && (!dupSort || dedup[n-1].value == e.value) {
+				continue
+			}
+			dedup = append(dedup, e)
+		}
+
+		for _, mode := range []gdbx.MergeMode{gdbx.MergeAll, gdbx.MergeDedup} {
+			want := all
+			if mode == gdbx.MergeDedup {
+				want = dedup
+			}
+			m, err := gdbx.NewMergeCursor(cursors, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			collect := func(k, v []byte, err error) []entry {
+				var got []entry
+				for ; err == nil; k, v, err = m.Next() {
+					got = append(got, entry{string(k), string(v), m.Source()})
+				}
+				if !gdbx.IsNotFound(err) {
+					t.Fatal(err)
+				}
+				if m.Source() != -1 {
+					t.Fatalf("source %d after the end", m.Source())
+				}
+				return got
+			}
+			if got := collect(m.First()); !slices.Equal(got, want) {
+				t.Fatalf("dupsort %v mode %d: %d entries, want %d\n got %v\nwant %v", dupSort, mode, len(got), len(want), got, want)
+			}
+			if _, _, err := m.Next(); !gdbx.IsNotFound(err) {
+				t.Fatalf("Next past the end: %v", err)
+			}
+
+			from := "k100"
+			i := slices.IndexFunc(want, func(e entry) bool { return e.key >= from })
+			if got := collect(m.Seek([]byte(from))); !slices.Equal(got, want[i:]) {
+				t.Fatalf("dupsort %v mode %d: Seek(%s) gave %d entries, want %d", dupSort, mode, from, len(got), len(want)-i)
+			}
+			if _, _, err := m.Seek([]byte("z")); !gdbx.IsNotFound(err) {
+				t.Fatalf("Seek past the end: %v", err)
+			}
+		}
+		m, err := gdbx.NewMergeCursor(cursors, gdbx.MergeAll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Close()
+	}
+
+	// Tables ordered differently cannot be merged
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed, err := txn.OpenDBI("reversed", gdbx.Create, func(a, b []byte) int { return bytes.Compare(b, a) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, other := range []gdbx.DBI{dups, reversed} {
+		a, err := txn.OpenCursor(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := txn.OpenCursor(other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gdbx.NewMergeCursor([]*gdbx.Cursor{a, b}, gdbx.MergeAll); gdbx.Code(err) != gdbx.ErrIncompatible {
+			t.Fatalf("merging differently ordered tables: %v", err)
+		}
+		a.Close()
+		b.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestInfoMetaRotation commits several times and checks that Info reports
+// the meta slot holding the newest txnid, that each commit moves it to the
+// next slot, and that the other two slots hold older txnids.
+func TestInfoMetaRotation(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	prev := -1
+	for i := 0; i < 6; i++ {
+		err := env.Update(func(txn *gdbx.Txn) error {
+			return txn.Put(gdbx.MainDBI, []byte(fmt.Sprintf("k%d", i)), []byte("v"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txnids := []uint64{info.Meta0Txnid, info.Meta1Txnid, info.Meta2Txnid}
+		active := info.ActiveMetaIndex
+		if active < 0 || active > 2 {
+			t.Fatalf("commit %d: active meta index %d", i, active)
+		}
+		if txnids[active] != info.RecentTxnID {
+			t.Fatalf("commit %d: active slot %d holds txnid %d, want %d", i, active, txnids[active], info.RecentTxnID)
+		}
+		for slot, id := range txnids {
+			if slot != active && id >= info.RecentTxnID {
+				t.Fatalf("commit %d: slot %d holds txnid %d, not older than the active %d", i, slot, id, info.RecentTxnID)
+			}
+		}
+		if prev >= 0 && active == prev {
+			t.Fatalf("commit %d: active meta stayed in slot %d", i, active)
+		}
+		prev = active
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestMultiGet verifies that MultiGet matches per-key Get results for sorted,
+// unsorted, missing and duplicate keys, in both write and read transactions.
+func TestMultiGet(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := txn.OpenDBISimple("multi", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Even keys only, so odd keys are missing; every 100th value is large
+	const numKeys = 5000
+	for i := 0; i < numKeys; i += 2 {
+		val := []byte(fmt.Sprintf("val%06d", i))
+		if i%100 == 0 {
+			val = bytes.Repeat(val, 1000)
+		}
+		if err := txn.Put(dbi, []byte(fmt.Sprintf("key%06d", i)), val, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	sortedKeys := make([][]byte, 0, numKeys+1)
+	for i := 0; i < numKeys; i++ {
+		sortedKeys = append(sortedKeys, []byte(fmt.Sprintf("key%06d", i)))
+	}
+	sortedKeys = append(sortedKeys, []byte("zzz")) // beyond the last key
+	shuffled := append([][]byte(nil), sortedKeys...)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	withDups := [][]byte{[]byte("key000010"), []byte("a"), []byte("key000010"), []byte("key004998"), []byte("key000011")}
+
+	check := func(t *testing.T, txn *gdbx.Txn, keys [][]byte) {
+		t.Helper()
+		vals, errs := txn.MultiGet(dbi, keys)
+		if len(vals) != len(keys) || len(errs) != len(keys) {
+			t.Fatalf("MultiGet returned %d values, %d errors for %d keys", len(vals), len(errs), len(keys))
+		}
+		for i, key := range keys {
+			want, wantErr := txn.Get(dbi, key)
+			if gdbx.IsNotFound(wantErr) != gdbx.IsNotFound(errs[i]) || !bytes.Equal(vals[i], want) {
+				t.Fatalf("key %q: MultiGet = (%.20q, %v), Get = (%.20q, %v)", key, vals[i], errs[i], want, wantErr)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		keys [][]byte
+	}{
+		{"sorted", sortedKeys},
+		{"shuffled", shuffled},
+		{"duplicates", withDups},
+		{"empty", nil},
+	} {
+		t.Run("write/"+tc.name, func(t *testing.T) { check(t, txn, tc.keys) })
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	t.Run("read/sorted", func(t *testing.T) { check(t, rtxn, sortedKeys) })
+	t.Run("read/shuffled", func(t *testing.T) { check(t, rtxn, shuffled) })
+
+	// Invalid DBI reports an error for every key
+	_, errs := rtxn.MultiGet(gdbx.DBI(99), withDups)
+	for i, err := range errs {
+		if err == nil {
+			t.Fatalf("key %d: expected error for invalid DBI", i)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCurrentNodeInfo checks the storage reported for inline and big values
+// and for DupSort keys with one value, a sub-page and a sub-tree.
+func TestCurrentNodeInfo(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := bytes.Repeat([]byte("x"), 10000)
+	if err := txn.Put(plain, []byte("small"), []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Put(plain, []byte("big"), big, 0); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{"single": 1, "subpage": 7, "subtree": 2000}
+	for k, n := range counts {
+		for i := 0; i < n; i++ {
+			if err := txn.Put(dups, []byte(k), []byte(fmt.Sprintf("v%05d", i)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	check := func(dbi gdbx.DBI, key string, want func(gdbx.NodeInfo) bool) {
+		t.Helper()
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		if _, _, err := cur.Get([]byte(key), nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		info, err := cur.CurrentNodeInfo()
+		if err != nil {
+			t.Fatalf("CurrentNodeInfo(%s): %v", key, err)
+		}
+		if !want(info) {
+			t.Fatalf("CurrentNodeInfo(%s) = %+v", key, info)
+		}
+	}
+	check(plain, "small", func(i gdbx.NodeInfo) bool { return i.Flags == 0 && i.DataSize == 5 && i.Count == 1 })
+	check(plain, "big", func(i gdbx.NodeInfo) bool { return i.Flags == gdbx.NodeBig && i.DataSize == 10000 && i.Count == 1 })
+	check(dups, "single", func(i gdbx.NodeInfo) bool { return i.Flags == 0 && i.DataSize == 6 && i.Count == 1 })
+	check(dups, "subpage", func(i gdbx.NodeInfo) bool { return i.Flags == gdbx.NodeDup && i.DataSize > 7*6 && i.Count == 7 })
+	check(dups, "subtree", func(i gdbx.NodeInfo) bool {
+		return i.Flags == gdbx.NodeDup|gdbx.NodeTree && i.DataSize == 48 && i.Count == 2000
+	})
+
+	cur, err := txn.OpenCursor(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	if _, err := cur.CurrentNodeInfo(); !gdbx.IsNotFound(err) {
+		t.Fatalf("CurrentNodeInfo on an unpositioned cursor: got %v, want not found", err)
+	}
+}
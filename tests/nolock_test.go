@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestNoLock opens a database in a read-only directory, without its lock
+// file, with ReadOnly|NoLock and checks that it reads the data, creates no
+// lock file, refuses writes, and holds no lock on the data file; and that
+// NoLock requires ReadOnly.
+func TestNoLock(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		for _, k := range []string{"a", "b", "c"} {
+			if err := txn.Put(gdbx.MainDBI, []byte(k), []byte("v"+k), 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	lockPath := filepath.Join(db.path, gdbx.LockFileName)
+	dataPath := filepath.Join(db.path, gdbx.DataFileName)
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dataPath, 0444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(db.path, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(db.path, 0755)
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Open(db.path, gdbx.NoLock, 0644); gdbx.Code(err) != gdbx.ErrIncompatible {
+		t.Fatalf("NoLock without ReadOnly: got %v, want ErrIncompatible", err)
+	}
+	env.Close()
+
+	env, err = gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.Open(db.path, gdbx.ReadOnly|gdbx.NoLock, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatalf("lock file created: %v", err)
+	}
+	txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	cur, err := txn.OpenCursor(gdbx.MainDBI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for k, v, err := cur.Get(nil, nil, gdbx.First); err == nil; k, v, err = cur.Get(nil, nil, gdbx.Next) {
+		got = append(got, string(k)+"="+string(v))
+	}
+	cur.Close()
+	if len(got) != 3 || got[0] != "a=va" || got[2] != "c=vc" {
+		t.Fatalf("read %q", got)
+	}
+	if wtxn, err := env.BeginTxn(nil, 0); err == nil {
+		wtxn.Abort()
+		t.Fatal("write transaction began on a NoLock environment")
+	}
+
+	// Nothing coordinates with other openers: an Exclusive one gets in
+	if err := os.Chmod(db.path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if code := openInChild(t, db.path, gdbx.Exclusive|gdbx.ReadOnly); code != 0 {
+		t.Fatalf("Exclusive opener: got code %d", code)
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestPutNoOverwriteKey checks that NoOverwriteKey refuses a DupSort key
+// holding one value, a sub-page or a sub-tree, whatever the value, and
+// adds the value to a new key, where NoOverwrite and NoDupData refuse only
+// a pair already present; that it behaves as NoOverwrite on a plain table;
+// and that Append and cursor puts honor it too.
+func TestPutNoOverwriteKey(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 3, 500} {
+		for j := 0; j < n; j++ {
+			if err := txn.Put(dups, []byte(fmt.Sprintf("d%03d", n)), []byte(fmt.Sprintf("v%04d", j)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := txn.Put(plain, []byte("k"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	c, err := txn.OpenCursor(dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	count := func(key string) uint64 {
+		t.Helper()
+		if _, _, err := c.Get([]byte(key), nil, gdbx.Set); gdbx.IsNotFound(err) {
+			return 0
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		n, err := c.Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	for _, tc := range []struct {
+		name       string
+		dbi        gdbx.DBI
+		key, value string
+		flags      uint
+		exist      bool
+	}{
+		{"new value, NoOverwrite", dups, "d003", "new", gdbx.NoOverwrite, false},
+		{"new value, NoDupData", dups, "d003", "new2", gdbx.NoDupData, false},
+		{"present pair, NoDupData", dups, "d003", "v0001", gdbx.NoDupData, true},
+		{"single value", dups, "d001", "new", gdbx.NoOverwriteKey, true},
+		{"sub-page", dups, "d003", "new3", gdbx.NoOverwriteKey, true},
+		{"sub-tree", dups, "d500", "new", gdbx.NoOverwriteKey, true},
+		{"present pair", dups, "d500", "v0007", gdbx.NoOverwriteKey, true},
+		{"new key", dups, "d000", "first", gdbx.NoOverwriteKey, false},
+		{"key now present", dups, "d000", "second", gdbx.NoOverwriteKey, true},
+		{"appended past the last key", dups, "d999", "last", gdbx.NoOverwriteKey | gdbx.Append, false},
+		{"appended to the last key", dups, "d999", "more", gdbx.NoOverwriteKey | gdbx.Append, true},
+		{"plain key", plain, "k", "w", gdbx.NoOverwriteKey, true},
+		{"plain new key", plain, "l", "w", gdbx.NoOverwriteKey, false},
+	} {
+		before := count(tc.key)
+		if tc.dbi == plain {
+			before = 0
+		}
+		err := txn.Put(tc.dbi, []byte(tc.key), []byte(tc.value), tc.flags)
+		if tc.exist != gdbx.IsKeyExist(err) || (!tc.exist && err != nil) {
+			t.Errorf("%s: Put = %v, want key exists %v", tc.name, err, tc.exist)
+			continue
+		}
+		if tc.dbi != dups {
+			continue
+		}
+		want := before
+		if !tc.exist {
+			want++
+		}
+		if after := count(tc.key); after != want {
+			t.Errorf("%s: %s holds %d values, want %d", tc.name, tc.key, after, want)
+		}
+	}
+
+	// Through the cursor
+	if err := c.Put([]byte("d500"), []byte("cursor"), gdbx.NoOverwriteKey); !gdbx.IsKeyExist(err) {
+		t.Fatalf("cursor Put on a present key = %v", err)
+	}
+	if err := c.Put([]byte("d002"), []byte("cursor"), gdbx.NoOverwriteKey); err != nil {
+		t.Fatalf("cursor Put on a new key = %v", err)
+	}
+	if v, err := txn.Get(plain, []byte("k")); err != nil || string(v) != "v" {
+		t.Fatalf("plain k = %q, %v after the refused put", v, err)
+	}
+}
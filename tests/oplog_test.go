@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestOpLogReplay records a randomized workload mixing Txn and cursor
+// operations, replays it into a fresh environment, and checks that both
+// environments end up with identical contents.
+func TestOpLogReplay(t *testing.T) {
+	src := newTestDB(t)
+	defer src.cleanup()
+	dst := newTestDB(t)
+	defer dst.cleanup()
+
+	env := openGdbxEnv(t, src.path, 0)
+	defer env.Close()
+	env.EnableOpLog()
+
+	rng := rand.New(rand.NewSource(7))
+	for round := 0; round < 5; round++ {
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursor, err := txn.OpenCursor(dups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if round >= 2 {
+			cursor.SetBounds([]byte("k02"), []byte("k08"))
+		}
+
+		for i := 0; i < 2000; i++ {
+			k := []byte(fmt.Sprintf("k%04d", rng.Intn(1000)))
+			switch rng.Intn(6) {
+			case 0, 1:
+				txn.Put(plain, k, []byte(fmt.Sprintf("v%d-%d", round, i)), 0)
+			case 2:
+				txn.Put(plain, k, []byte("new"), gdbx.NoOverwrite) // May fail with KeyExist
+			case 3:
+				txn.Del(plain, k, nil) // May fail with NotFound
+			case 4:
+				cursor.Put(k[:3], []byte(fmt.Sprintf("d%04d", rng.Intn(300))), 0)
+			case 5:
+				// Position by cursor, then delete where it landed
+				if _, _, err := cursor.Get(k[:3], nil, gdbx.SetRange); err == nil {
+					cursor.Del(0)
+				}
+			}
+		}
+		txn.Sequence(plain, 1)
+		cursor.Close()
+
+		// Abort every other round after some changes
+		if round%2 == 1 {
+			txn.Abort()
+			continue
+		}
+		if _, err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var log bytes.Buffer
+	if err := env.WriteOpLog(&log); err != nil {
+		t.Fatal(err)
+	}
+	recorded := log.Bytes()
+
+	replayEnv := openGdbxEnv(t, dst.path, 0)
+	defer replayEnv.Close()
+	if err := replayEnv.ReplayOpLog(bytes.NewReader(recorded)); err != nil {
+		t.Fatalf("ReplayOpLog: %v", err)
+	}
+
+	for _, name := range []string{"plain", "dups"} {
+		want, wantSeq := dumpDBI(t, env, name)
+		got, gotSeq := dumpDBI(t, replayEnv, name)
+		if len(want) == 0 {
+			t.Fatalf("%s: source database is empty", name)
+		}
+		if !bytes.Equal(want, got) || wantSeq != gotSeq {
+			t.Errorf("%s: replayed contents differ (%d vs %d bytes, sequence %d vs %d)", name, len(got), len(want), gotSeq, wantSeq)
+		}
+	}
+
+	// Replaying onto a non-empty environment diverges (NoOverwrite results change)
+	if err := replayEnv.ReplayOpLog(bytes.NewReader(recorded)); err == nil {
+		t.Error("replay onto a populated environment should report a divergence")
+	}
+
+	// A truncated log is rejected
+	truncEnv := openGdbxEnv(t, t.TempDir(), 0)
+	defer truncEnv.Close()
+	if err := truncEnv.ReplayOpLog(bytes.NewReader(recorded[:len(recorded)-1])); err == nil {
+		t.Error("replay of a truncated log should fail")
+	}
+
+	// So are transactions begun inside one or ended outside one, rather than
+	// waiting on the writer lock. Records: opcode, flags if Begin, result code.
+	for name, bad := range map[string][]byte{
+		"nested Begin":         {1, 0, 0, 1, 0, 0},
+		"Commit without Begin": {2, 0},
+		"Abort without Begin":  {3, 0},
+		"Commit after Commit":  {1, 0, 0, 2, 0, 2, 0},
+	} {
+		badEnv := openGdbxEnv(t, t.TempDir(), 0)
+		err := badEnv.ReplayOpLog(bytes.NewReader(bad))
+		badEnv.Close()
+		if gdbx.Code(err) != gdbx.ErrInvalid {
+			t.Errorf("replay of a log with %s: got %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+// TestOpLogDBISpec records tables opened with OpenDBIWithSpec, whose
+// policies decide which writes succeed and in what order keys are stored,
+// and an append guard, and checks that replay applies them.
+func TestOpLogDBISpec(t *testing.T) {
+	env := openGdbxEnv(t, t.TempDir(), 0)
+	defer env.Close()
+	env.EnableOpLog()
+
+	err := env.Update(func(txn *gdbx.Txn) error {
+		dbi, err := txn.OpenDBIWithSpec("limited", gdbx.DBISpec{Flags: gdbx.Create, MaxValue: 8})
+		if err != nil {
+			return err
+		}
+		if err := txn.Put(dbi, []byte("small"), []byte("12345678"), 0); err != nil {
+			return err
+		}
+		if err := txn.Put(dbi, []byte("big"), []byte("123456789"), 0); gdbx.Code(err) != gdbx.ErrBadValSize {
+			return fmt.Errorf("Put over MaxValue: got %v, want ErrBadValSize", err)
+		}
+		numbers, err := txn.OpenDBIWithSpec("numbers", gdbx.DBISpec{Flags: gdbx.Create, BigEndianKeys: true})
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"\x01\x00", "\x02", "\x00\x03", "\xff"} {
+			if err := txn.Put(numbers, []byte(k), []byte("v"), 0); err != nil {
+				return err
+			}
+		}
+		native, err := txn.OpenDBIWithSpec("native", gdbx.DBISpec{Flags: gdbx.Create | gdbx.IntegerKey, NativeIntegers: true})
+		if err != nil {
+			return err
+		}
+		for _, k := range []uint64{256, 1, 1 << 16} {
+			if err := txn.Put(native, binary.NativeEndian.AppendUint64(nil, k), []byte("v"), 0); err != nil {
+				return err
+			}
+		}
+		if err := txn.SetAppendGuard(dbi); err != nil {
+			return err
+		}
+		if err := txn.Put(dbi, []byte("z"), []byte("v"), 0); err != nil {
+			return err
+		}
+		if err := txn.Put(dbi, []byte("y"), []byte("v"), 0); gdbx.Code(err) != gdbx.ErrKeyMismatch {
+			return fmt.Errorf("Put before the last key: got %v, want ErrKeyMismatch", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log bytes.Buffer
+	if err := env.WriteOpLog(&log); err != nil {
+		t.Fatal(err)
+	}
+	replayEnv := openGdbxEnv(t, t.TempDir(), 0)
+	defer replayEnv.Close()
+	if err := replayEnv.ReplayOpLog(&log); err != nil {
+		t.Fatalf("ReplayOpLog: %v", err)
+	}
+	for _, name := range []string{"limited", "numbers", "native"} {
+		want, _ := dumpDBI(t, env, name)
+		if got, _ := dumpDBI(t, replayEnv, name); !bytes.Equal(got, want) {
+			t.Errorf("%s: replayed contents %q, want %q", name, got, want)
+		}
+	}
+}
+
+// dumpDBI returns every key/value pair of a named database serialized in
+// order, plus its sequence value.
+func dumpDBI(t *testing.T, env *gdbx.Env, name string) ([]byte, uint64) {
+	t.Helper()
+	txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple(name, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := txn.Sequence(dbi, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := txn.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+
+	var out []byte
+	for k, v, err := cursor.Get(nil, nil, gdbx.First); err == nil; k, v, err = cursor.Get(nil, nil, gdbx.Next) {
+		out = fmt.Appendf(out, "%q=%q\n", k, v)
+	}
+	return out, seq
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestFreedPageReuse verifies that a transaction reuses the pages it freed
+// itself, so deleting and reinserting doesn't grow the file, and that it never
+// reuses pages of the committed snapshot while a reader may still need them.
+func TestFreedPageReuse(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{
+		{"default", 0},
+		{"writemap", gdbx.WriteMap},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testFreedPageReuse(t, tc.flags)
+		})
+	}
+}
+
+func testFreedPageReuse(t *testing.T, flags uint) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, flags)
+	defer env.Close()
+
+	const n = 5000
+	small := func(prefix string, i int) ([]byte, []byte) {
+		return []byte(fmt.Sprintf("%s%06d", prefix, i)), bytes.Repeat([]byte{byte(i)}, 200)
+	}
+	big := func(i int) ([]byte, []byte) {
+		return []byte(fmt.Sprintf("big%04d", i)), bytes.Repeat([]byte{byte(i), 0xAB}, 5000)
+	}
+	lastPage := func() int64 {
+		t.Helper()
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.LastPgNo
+	}
+	update := func(fn func(txn *gdbx.Txn)) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn(txn)
+		if _, err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put := func(txn *gdbx.Txn, k, v []byte) {
+		t.Helper()
+		if err := txn.Put(gdbx.MainDBI, k, v, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	del := func(txn *gdbx.Txn, k []byte) {
+		t.Helper()
+		if err := txn.Del(gdbx.MainDBI, k, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	update(func(txn *gdbx.Txn) {
+		for i := 0; i < n; i++ {
+			k, v := small("old", i)
+			put(txn, k, v)
+		}
+	})
+	before := lastPage()
+
+	// Deleting everything and inserting as much again needs well under one
+	// copy of the tree: the pages emptied by the deletes take the inserts
+	var treePages int64
+	update(func(txn *gdbx.Txn) {
+		stat, err := txn.Stat(gdbx.MainDBI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		treePages = int64(stat.LeafPages + stat.BranchPages)
+		for i := 0; i < n; i++ {
+			k, _ := small("old", i)
+			del(txn, k)
+		}
+		for i := 0; i < n; i++ {
+			k, v := small("new", i)
+			put(txn, k, v)
+		}
+	})
+	if grown := lastPage() - before; grown > treePages*3/4 {
+		t.Fatalf("delete-then-insert grew the file by %d pages for a tree of %d pages", grown, treePages)
+	}
+
+	// Pages of the committed snapshot stay untouched while a reader holds it
+	update(func(txn *gdbx.Txn) {
+		for i := 0; i < 50; i++ {
+			k, v := big(i)
+			put(txn, k, v)
+		}
+	})
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	update(func(txn *gdbx.Txn) {
+		for i := 0; i < 50; i++ {
+			k, _ := big(i)
+			del(txn, k)
+		}
+		for i := 0; i < n; i++ {
+			k, v := small("more", i)
+			put(txn, k, v)
+		}
+	})
+	for i := 0; i < 50; i++ {
+		k, want := big(i)
+		if v, err := rtxn.Get(gdbx.MainDBI, k); err != nil || !bytes.Equal(v, want) {
+			t.Fatalf("reader of the old snapshot: %s changed (%v)", k, err)
+		}
+	}
+}
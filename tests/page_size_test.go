@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestRecommendedPageSize checks SysPageSize and RecommendedPageSize against
+// the OS, and that creating a database with pages below the OS page size is
+// logged while one with the recommended size, or an existing file, is not.
+func TestRecommendedPageSize(t *testing.T) {
+	dir := t.TempDir()
+
+	rec := gdbx.RecommendedPageSize()
+	if rec < gdbx.DefaultPageSize || rec > gdbx.MaxPageSize || rec&(rec-1) != 0 {
+		t.Fatalf("RecommendedPageSize = %d, not a valid page size of at least %d", rec, gdbx.DefaultPageSize)
+	}
+	if rec < os.Getpagesize() && rec != gdbx.MaxPageSize {
+		t.Fatalf("RecommendedPageSize = %d, below the OS page size %d", rec, os.Getpagesize())
+	}
+
+	var logged []string
+	gdbx.SetLogger(func(msg string, args ...any) { logged = append(logged, msg) }, gdbx.LogLvlDoNotChange)
+	defer gdbx.SetLogger(nil, gdbx.LogLvlDoNotChange)
+
+	create := func(name string, pageSize int) {
+		t.Helper()
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.Close()
+		if err := env.SetGeometry(-1, -1, 1<<26, -1, -1, pageSize); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Open(filepath.Join(dir, name), 0, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := env.SysPageSize(); got != os.Getpagesize() {
+			t.Fatalf("SysPageSize = %d, want %d", got, os.Getpagesize())
+		}
+	}
+
+	create("recommended", rec)
+	if len(logged) != 0 {
+		t.Fatalf("recommended page size logged %q", logged)
+	}
+	create("small", gdbx.MinPageSize)
+	if len(logged) != 1 || !strings.Contains(logged[0], "below the OS page size") {
+		t.Fatalf("page size %d logged %q, want one warning", gdbx.MinPageSize, logged)
+	}
+	create("small", gdbx.MinPageSize)
+	if len(logged) != 1 {
+		t.Fatalf("reopening the existing database logged %q", logged[1:])
+	}
+}
+
+// TestReopenLargePages commits to databases of pages larger than the default
+// and reopens them after each commit without naming the page size. The metas
+// are a page apart, so whichever slot holds the last commit must be found at
+// the file's page size, with every commit made so far.
+func TestReopenLargePages(t *testing.T) {
+	for _, pageSize := range []int{16 << 10, 64 << 10} {
+		dir := t.TempDir()
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.SetGeometry(-1, -1, 1<<28, -1, -1, pageSize); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Open(dir, 0, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		for _, k := range []string{"a", "b", "c", "d"} {
+			keys = append(keys, k)
+			if err := env.Update(func(txn *gdbx.Txn) error {
+				return txn.Put(gdbx.MainDBI, []byte(k), []byte(k), 0)
+			}); err != nil {
+				t.Fatal(err)
+			}
+			want, err := env.Info(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env.Close()
+
+			env = openGdbxEnv(t, dir, 0)
+			got, err := env.Info(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.PageSize != uint32(pageSize) || got.RecentTxnID != want.RecentTxnID {
+				t.Fatalf("page size %d: reopened at page size %d, txn %d, want txn %d",
+					pageSize, got.PageSize, got.RecentTxnID, want.RecentTxnID)
+			}
+			err = env.View(func(txn *gdbx.Txn) error {
+				for _, k := range keys {
+					if _, err := txn.Get(gdbx.MainDBI, []byte(k)); err != nil {
+						return fmt.Errorf("page size %d: get %s: %w", pageSize, k, err)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		env.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestOpenPartialFile verifies that Open initializes files left empty by a
+// crash during creation and rejects foreign or truncated data files, and
+// those whose meta pages were zeroed but whose data pages remain.
+func TestOpenPartialFile(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	// Build a valid database to take truncated prefixes from
+	validPath := filepath.Join(db.path, "valid.db")
+	env := openGdbxEnv(t, validPath, gdbx.NoSubdir)
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		return txn.Put(gdbx.MainDBI, []byte("k"), []byte("v"), 0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+	valid, err := os.ReadFile(validPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metasZeroed := bytes.Clone(valid)
+	clear(metasZeroed[:gdbx.NumMetas*gdbx.DefaultPageSize])
+
+	tests := []struct {
+		name     string
+		data     []byte
+		flags    uint
+		wantCode gdbx.ErrorCode // 0 means Open must succeed
+	}{
+		{"empty", nil, 0, 0},
+		{"empty-readonly", nil, gdbx.ReadOnly, gdbx.ErrInvalid},
+		{"zero-100", make([]byte, 100), 0, 0},
+		{"zero-one-page", make([]byte, gdbx.DefaultPageSize), 0, 0},
+		{"zero-3mb", make([]byte, 3<<20), 0, 0},
+		{"metas-zeroed", metasZeroed, 0, gdbx.ErrCorrupted},
+		{"garbage-100", bytes.Repeat([]byte("not gdbx"), 13)[:100], 0, gdbx.ErrInvalid},
+		{"truncated-100", valid[:100], 0, gdbx.ErrCorrupted},
+		{"truncated-one-meta", valid[:gdbx.DefaultPageSize], 0, gdbx.ErrCorrupted},
+		{"truncated-one-meta-readonly", valid[:gdbx.DefaultPageSize], gdbx.ReadOnly, gdbx.ErrCorrupted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(db.path, tt.name+".db")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			env, err := gdbx.NewEnv(gdbx.Default)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer env.Close()
+			err = env.Open(path, gdbx.NoSubdir|tt.flags, 0644)
+
+			if tt.wantCode != 0 {
+				if err == nil {
+					t.Fatalf("Open succeeded, want error code %d", tt.wantCode)
+				}
+				if code := gdbx.Code(err); code != tt.wantCode {
+					t.Fatalf("Open error %v (code %d), want code %d", err, code, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+
+			// The reinitialized file must be fully usable
+			txn, err := env.BeginTxn(nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dbi, err := txn.OpenRoot(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := txn.Put(dbi, []byte("k"), []byte("v"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := txn.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
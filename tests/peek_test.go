@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestCursorPeek walks tables forward and backward, checking at every entry
+// that PeekNext and PeekPrev report the key Next and Prev then move to and
+// leave the cursor where it was, on a table of several levels, on a DupSort
+// table with sub-pages and sub-trees, from an unpositioned cursor, at both
+// ends, and within SetBounds.
+func TestCursorPeek(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	key := func(i int) []byte { return binary.BigEndian.AppendUint64(nil, uint64(i)) }
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3000; i++ {
+		if err := txn.Put(plain, key(i), make([]byte, 64), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		// Every fourth key gets enough values for a sub-tree
+		n := 1 + i%3
+		if i%4 == 0 {
+			n = 300
+		}
+		for j := 0; j < n; j++ {
+			if err := txn.Put(dups, key(i), []byte(fmt.Sprintf("v%04d", j)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	check := func(t *testing.T, c *gdbx.Cursor, peek func() ([]byte, bool, error), op gdbx.CursorOp) bool {
+		t.Helper()
+		ck, cv, cerr := c.Get(nil, nil, gdbx.GetCurrent)
+		pk, found, err := peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pk = bytes.Clone(pk)
+		if k, v, err := c.Get(nil, nil, gdbx.GetCurrent); !bytes.Equal(k, ck) || !bytes.Equal(v, cv) || gdbx.Code(err) != gdbx.Code(cerr) {
+			t.Fatalf("peek moved the cursor from %x/%q to %x/%q", ck, cv, k, v)
+		}
+		k, _, err := c.Get(nil, nil, op)
+		if gdbx.IsNotFound(err) {
+			if found {
+				t.Fatalf("peek found %x where the move found nothing", pk)
+			}
+			return false
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || !bytes.Equal(pk, k) {
+			t.Fatalf("peek = %x, %v; the move went to %x", pk, found, k)
+		}
+		return true
+	}
+
+	for _, tc := range []struct {
+		name string
+		dbi  gdbx.DBI
+	}{{"Plain", plain}, {"DupSort", dups}} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := txn.OpenCursor(tc.dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			stat, err := txn.Stat(tc.dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// From unpositioned, Next goes to the first entry
+			n := 0
+			for check(t, c, c.PeekNext, gdbx.Next) {
+				n++
+			}
+			if uint64(n) != stat.Entries {
+				t.Fatalf("forward: %d entries, want %d", n, stat.Entries)
+			}
+			if _, found, err := c.PeekNext(); err != nil || found {
+				t.Fatalf("PeekNext past the end: %v, %v", found, err)
+			}
+
+			c2, err := txn.OpenCursor(tc.dbi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c2.Close()
+			n = 0
+			for check(t, c2, c2.PeekPrev, gdbx.Prev) {
+				n++
+			}
+			if uint64(n) != stat.Entries {
+				t.Fatalf("backward: %d entries, want %d", n, stat.Entries)
+			}
+		})
+	}
+
+	t.Run("Bounds", func(t *testing.T) {
+		c, err := txn.OpenCursor(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		c.SetBounds(key(100), key(200))
+		if _, _, err := c.Get(key(199), nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		if k, found, err := c.PeekNext(); err != nil || found {
+			t.Fatalf("PeekNext at the bound = %x, %v, %v", k, found, err)
+		}
+		if k, found, err := c.PeekPrev(); err != nil || !found || !bytes.Equal(k, key(198)) {
+			t.Fatalf("PeekPrev = %x, %v, %v", k, found, err)
+		}
+		if k, _, err := c.Get(nil, nil, gdbx.Next); !gdbx.IsNotFound(err) {
+			t.Fatalf("Next at the bound = %x, %v", k, err)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestPrefixDBI keeps two prefix tables in one database next to keys just
+// outside their ranges, and checks that gets, puts, deletes and cursor
+// movements in either direction stay within a table and see its keys
+// without the prefix, for plain and DupSort databases, and that a database
+// not in bytewise key order is refused.
+func TestPrefixDBI(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("shared", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neighbours just below and above both ranges
+	for _, k := range []string{"a", "a\xff\xff", "b", "b\x01", "c", "\xff\xff", "\xff\xff\x00"} {
+		if err := txn.Put(dbi, []byte(k), []byte("outside"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users, err := txn.OpenPrefix(dbi, []byte("b\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tail, err := txn.OpenPrefix(dbi, []byte("\xff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for i := 0; i < 500; i++ {
+		k := fmt.Sprintf("u%03d", i)
+		want = append(want, k)
+		if err := users.Put([]byte(k), []byte("user"+k), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tail.Put([]byte("x"), []byte("tail"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := users.Get([]byte("u042")); err != nil || string(v) != "useru042" {
+		t.Fatalf("Get: %q, %v", v, err)
+	}
+	if v, err := txn.Get(dbi, []byte("b\x00u042")); err != nil || string(v) != "useru042" {
+		t.Fatalf("stored key: %q, %v", v, err)
+	}
+	if _, err := users.Get([]byte("")); !gdbx.IsNotFound(err) {
+		t.Fatalf("prefix itself: %v, want not found", err)
+	}
+
+	keys := func(p *gdbx.PrefixDBI, first, next gdbx.CursorOp) []string {
+		t.Helper()
+		cur, err := p.OpenCursor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		var got []string
+		for k, _, err := cur.Get(nil, nil, first); ; k, _, err = cur.Get(nil, nil, next) {
+			if gdbx.IsNotFound(err) {
+				return got
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, string(k))
+		}
+	}
+	if got := keys(users, gdbx.First, gdbx.Next); !slices.Equal(got, want) {
+		t.Fatalf("forward: %d keys, want %d", len(got), len(want))
+	}
+	rev := slices.Clone(want)
+	slices.Reverse(rev)
+	if got := keys(users, gdbx.Last, gdbx.Prev); !slices.Equal(got, rev) {
+		t.Fatalf("backward: %d keys, want %d", len(got), len(want))
+	}
+	if got := keys(tail, gdbx.First, gdbx.Next); !slices.Equal(got, []string{"x", "\xff", "\xff\x00"}) {
+		t.Fatalf("all-0xff prefix: %q", got)
+	}
+
+	cur, err := users.OpenCursor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	if k, v, err := cur.Get([]byte("u1"), nil, gdbx.SetRange); err != nil || string(k) != "u100" || string(v) != "useru100" {
+		t.Fatalf("SetRange: %q, %q, %v", k, v, err)
+	}
+	if _, _, err := cur.Get([]byte("v"), nil, gdbx.SetRange); !gdbx.IsNotFound(err) {
+		t.Fatalf("SetRange past the table: %v, want not found", err)
+	}
+	if _, _, err := cur.Get([]byte("u100"), nil, gdbx.Set); err != nil {
+		t.Fatal(err)
+	}
+	if err := cur.Del(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Del([]byte("u101"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := cur.Put([]byte("new"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	want = append([]string{"new"}, slices.Delete(want, 100, 102)...)
+	if got := keys(users, gdbx.First, gdbx.Next); !slices.Equal(got, want) {
+		t.Fatalf("after edits: %d keys, want %d", len(got), len(want))
+	}
+	for _, k := range []string{"a", "a\xff\xff", "b", "b\x01", "c", "\xff\xff", "\xff\xff\x00"} {
+		if v, err := txn.Get(dbi, []byte(k)); err != nil || string(v) != "outside" {
+			t.Fatalf("neighbour %q: %q, %v", k, v, err)
+		}
+	}
+
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Put(dups, []byte("q"), []byte("outside"), 0); err != nil {
+		t.Fatal(err)
+	}
+	pd, err := txn.OpenPrefix(dups, []byte("p"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		if err := pd.Put([]byte("k"), []byte(fmt.Sprintf("%03d", i)), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dcur, err := pd.OpenCursor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dcur.Close()
+	if k, v, err := dcur.Get([]byte("k"), []byte("150"), gdbx.GetBoth); err != nil || string(k) != "k" || string(v) != "150" {
+		t.Fatalf("GetBoth: %q, %q, %v", k, v, err)
+	}
+	if n, err := dcur.Count(); err != nil || n != 200 {
+		t.Fatalf("Count: %d, %v", n, err)
+	}
+	if _, _, err := dcur.Get(nil, nil, gdbx.NextNoDup); !gdbx.IsNotFound(err) {
+		t.Fatalf("NextNoDup past the table: %v, want not found", err)
+	}
+
+	rdbi, err := txn.OpenDBISimple("reverse", gdbx.Create|gdbx.ReverseKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.OpenPrefix(rdbi, []byte("p")); gdbx.Code(err) != gdbx.ErrIncompatible {
+		t.Fatalf("ReverseKey database: got %v, want ErrIncompatible", err)
+	}
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestOpenDBIPurge verifies that opening with Create|Purge yields an empty
+// database whether or not it existed, while plain Create keeps the data.
+func TestOpenDBIPurge(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+
+	update := func(fn func(txn *gdbx.Txn)) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		fn(txn)
+		if _, err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	open := func(txn *gdbx.Txn, flags uint) gdbx.DBI {
+		t.Helper()
+		dbi, err := txn.OpenDBISimple("t", flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dbi
+	}
+	entries := func(txn *gdbx.Txn, dbi gdbx.DBI) uint64 {
+		t.Helper()
+		stat, err := txn.Stat(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stat.Entries
+	}
+
+	// Purge on a database that doesn't exist yet just creates it
+	update(func(txn *gdbx.Txn) {
+		dbi := open(txn, gdbx.Create|gdbx.Purge)
+		if n := entries(txn, dbi); n != 0 {
+			t.Fatalf("new database has %d entries", n)
+		}
+		for i := 0; i < 1000; i++ {
+			if err := txn.Put(dbi, []byte(fmt.Sprintf("key%04d", i)), []byte("value"), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	env.Close()
+
+	// Plain Create keeps existing data
+	env = openGdbxEnv(t, db.path, 0)
+	update(func(txn *gdbx.Txn) {
+		if n := entries(txn, open(txn, gdbx.Create)); n != 1000 {
+			t.Fatalf("reopen with Create: %d entries, want 1000", n)
+		}
+	})
+
+	// Create|Purge empties it, and the database stays usable
+	update(func(txn *gdbx.Txn) {
+		dbi := open(txn, gdbx.Create|gdbx.Purge)
+		if n := entries(txn, dbi); n != 0 {
+			t.Fatalf("reopen with Create|Purge: %d entries, want 0", n)
+		}
+		if err := txn.Put(dbi, []byte("after"), []byte("purge"), 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	env.Close()
+
+	env = openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi := open(txn, 0)
+	if n := entries(txn, dbi); n != 1 {
+		t.Fatalf("after purge and reopen: %d entries, want 1", n)
+	}
+	v, err := txn.Get(dbi, []byte("after"))
+	if err != nil || string(v) != "purge" {
+		t.Fatalf("Get(after) = %q, %v", v, err)
+	}
+	if _, err := txn.Get(dbi, []byte("key0000")); !gdbx.IsNotFound(err) {
+		t.Fatalf("purged key still present: %v", err)
+	}
+
+	// Purging needs a write transaction
+	if _, err := txn.OpenDBISimple("t", gdbx.Purge); gdbx.Code(err) != gdbx.ErrPermissionDenied {
+		t.Fatalf("Purge in read-only txn: got %v, want ErrPermissionDenied", err)
+	}
+}
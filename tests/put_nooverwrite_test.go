@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestPutNoOverwrite checks that PutNoOverwrite inserts missing keys and
+// returns the stored value, inline or big, with ErrKeyExist for present ones
+// without changing it.
+func TestPutNoOverwrite(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	big := bytes.Repeat([]byte("big"), 5000)
+	err := env.Update(func(txn *gdbx.Txn) error {
+		dbi, err := txn.OpenDBISimple("plain", gdbx.Create)
+		if err != nil {
+			return err
+		}
+		for _, kv := range []struct{ key, value []byte }{
+			{[]byte("small"), []byte("first")},
+			{[]byte("large"), big},
+		} {
+			existing, err := txn.PutNoOverwrite(dbi, kv.key, kv.value)
+			if err != nil || existing != nil {
+				t.Fatalf("insert %s: existing %q, err %v", kv.key, existing, err)
+			}
+			existing, err = txn.PutNoOverwrite(dbi, kv.key, []byte("second"))
+			if !gdbx.IsKeyExist(err) {
+				t.Fatalf("second insert %s: got %v, want ErrKeyExist", kv.key, err)
+			}
+			if !bytes.Equal(existing, kv.value) {
+				t.Fatalf("second insert %s returned %d bytes, want the stored %d", kv.key, len(existing), len(kv.value))
+			}
+			// The returned value is a copy
+			existing[0] ^= 0xff
+			if v, err := txn.Get(dbi, kv.key); err != nil || !bytes.Equal(v, kv.value) {
+				t.Fatalf("Get(%s) after the conflict: %d bytes, %v", kv.key, len(v), err)
+			}
+		}
+
+		dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+		if err != nil {
+			return err
+		}
+		if _, err := txn.PutNoOverwrite(dups, []byte("k"), []byte("a")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := txn.PutNoOverwrite(dups, []byte("k"), []byte("b")); err != nil {
+			t.Fatalf("adding a duplicate: %v", err)
+		}
+		existing, err := txn.PutNoOverwrite(dups, []byte("k"), []byte("a"))
+		if !gdbx.IsKeyExist(err) || string(existing) != "a" {
+			t.Fatalf("repeating a pair: existing %q, err %v", existing, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *gdbx.Txn) error {
+		_, err := txn.PutNoOverwrite(gdbx.DBI(1), []byte("k"), []byte("v"))
+		if gdbx.Code(err) != gdbx.ErrPermissionDenied {
+			t.Fatalf("PutNoOverwrite in a read-only txn: got %v, want ErrPermissionDenied", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestPutWithCap verifies that a capacity reservation sizes the overflow run,
+// survives growing and shrinking updates across commits, and is released when
+// the value moves inline or is deleted.
+func TestPutWithCap(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{
+		{"default", 0},
+		{"writemap", gdbx.WriteMap},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testPutWithCap(t, tc.flags)
+		})
+	}
+}
+
+// TestPutWithCapAppend appends to a value with reserved capacity, one commit
+// per append, and checks that no overflow pages are allocated until the value
+// outgrows the reservation, starting while it would still fit inline.
+func TestPutWithCapAppend(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		flags uint
+	}{
+		{"default", 0},
+		{"writemap", gdbx.WriteMap},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			defer db.cleanup()
+
+			env := openGdbxEnv(t, db.path, tc.flags)
+			defer env.Close()
+
+			const capacity = 16 << 10
+			key := []byte("log")
+			value := []byte("start")
+			var reserved uint64
+			for i := 0; ; i++ {
+				txn, err := env.BeginTxn(nil, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dbi, err := txn.OpenDBISimple("logs", gdbx.Create)
+				if err == nil {
+					if i == 0 {
+						err = txn.PutWithCap(dbi, key, value, capacity, 0)
+					} else {
+						value = append(value, bytes.Repeat([]byte{byte(i)}, 700)...)
+						err = txn.Put(dbi, key, value, 0)
+					}
+				}
+				if err != nil {
+					txn.Abort()
+					t.Fatal(err)
+				}
+				allocated := txn.Counters().OverflowPages
+				stat, err := txn.Stat(dbi)
+				if err != nil {
+					txn.Abort()
+					t.Fatal(err)
+				}
+				if _, err := txn.Commit(); err != nil {
+					t.Fatal(err)
+				}
+
+				switch {
+				case i == 0:
+					reserved = allocated
+					if reserved == 0 || stat.LargePages != reserved {
+						t.Fatalf("PutWithCap allocated %d overflow pages, LargePages = %d", allocated, stat.LargePages)
+					}
+				case len(value) <= capacity:
+					if allocated != 0 || stat.LargePages != reserved {
+						t.Fatalf("append to %d bytes allocated %d overflow pages, LargePages = %d, want 0 and %d",
+							len(value), allocated, stat.LargePages, reserved)
+					}
+				case allocated != 0:
+					// Outgrowing the reservation relocates the value to a new run
+					if stat.LargePages != allocated {
+						t.Fatalf("LargePages = %d after relocating to %d pages", stat.LargePages, allocated)
+					}
+					err = env.View(func(txn *gdbx.Txn) error {
+						dbi, err := txn.OpenDBISimple("logs", 0)
+						if err != nil {
+							return err
+						}
+						got, err := txn.Get(dbi, key)
+						if err == nil && !bytes.Equal(got, value) {
+							t.Errorf("value has %d bytes after relocation, want %d", len(got), len(value))
+						}
+						return err
+					})
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				if len(value) > 2*capacity {
+					t.Fatalf("no relocation by %d bytes", len(value))
+				}
+			}
+		})
+	}
+}
+
+func testPutWithCap(t *testing.T, flags uint) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, flags)
+	defer env.Close()
+
+	const capacity = 64 << 10
+	key := []byte("blob")
+	value := func(size int) []byte { return bytes.Repeat([]byte{byte(size)}, size) }
+
+	var dbi gdbx.DBI
+	update := func(fn func(txn *gdbx.Txn) error) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dbi, err = txn.OpenDBISimple("blobs", gdbx.Create); err != nil {
+			txn.Abort()
+			t.Fatal(err)
+		}
+		if err := fn(txn); err != nil {
+			txn.Abort()
+			t.Fatal(err)
+		}
+		if _, err := txn.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(size int, wantLargePages uint64) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		got, err := txn.Get(dbi, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, value(size)) {
+			t.Fatalf("value has %d bytes, want %d", len(got), size)
+		}
+		stat, err := txn.Stat(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.LargePages != wantLargePages {
+			t.Fatalf("LargePages = %d after %d-byte value, want %d", stat.LargePages, size, wantLargePages)
+		}
+	}
+
+	// Even a small value goes to overflow pages sized for the capacity
+	update(func(txn *gdbx.Txn) error { return txn.PutWithCap(dbi, key, value(100), capacity, 0) })
+	txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := txn.Stat(dbi)
+	txn.Abort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageSize := int(stat.PageSize)
+
+	// Overflow pages needed for size bytes; the first page keeps a 20-byte header
+	pagesFor := func(size int) uint64 {
+		return uint64(1 + (size-(pageSize-20)+pageSize-1)/pageSize)
+	}
+	reserved := pagesFor(capacity)
+	check(100, reserved)
+
+	// Plain Put growth and shrink stay within the reservation
+	for _, size := range []int{20000, capacity - 100, 9000} {
+		update(func(txn *gdbx.Txn) error { return txn.Put(dbi, key, value(size), 0) })
+		check(size, reserved)
+	}
+
+	// A value that fits inline gives the reservation back
+	update(func(txn *gdbx.Txn) error { return txn.Put(dbi, key, value(50), 0) })
+	check(50, 0)
+
+	// Growing past the reservation relocates to a run sized for the new capacity
+	update(func(txn *gdbx.Txn) error { return txn.PutWithCap(dbi, key, value(capacity+1), 2*capacity, 0) })
+	check(capacity+1, pagesFor(2*capacity))
+
+	// Without a reservation a shrinking update releases pages as before
+	update(func(txn *gdbx.Txn) error {
+		if err := txn.Del(dbi, key, nil); err != nil {
+			return err
+		}
+		return txn.Put(dbi, key, value(20000), 0)
+	})
+	check(20000, pagesFor(20000))
+	update(func(txn *gdbx.Txn) error { return txn.Put(dbi, key, value(5000), 0) })
+	check(5000, pagesFor(5000))
+
+	update(func(txn *gdbx.Txn) error { return txn.Del(dbi, key, nil) })
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	stat, err = txn.Stat(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.LargePages != 0 {
+		t.Fatalf("LargePages = %d after delete", stat.LargePages)
+	}
+
+	// Reservations are rejected for DupSort tables and negative capacities
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.PutWithCap(dups, key, value(10), capacity, 0); gdbx.Code(err) != gdbx.ErrIncompatible {
+		t.Fatalf("PutWithCap on DupSort: %v, want ErrIncompatible", err)
+	}
+	if err := txn.PutWithCap(dbi, key, value(10), -1, 0); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("PutWithCap with negative capacity: %v, want ErrInvalid", err)
+	}
+}
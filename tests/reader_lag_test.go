@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestInfoReaderLag holds a read transaction open across commits. Info must
+// report its snapshot as the oldest and the commits made since as the lag,
+// and ReaderList must single it out; once it ends the lag is gone.
+func TestInfoReaderLag(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	put := func(i int) {
+		t.Helper()
+		err := env.Update(func(txn *gdbx.Txn) error {
+			return txn.Put(gdbx.MainDBI, []byte(fmt.Sprintf("k%d", i)), []byte("v"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	info := func() *gdbx.EnvInfo {
+		t.Helper()
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	put(0)
+	reader, err := env.BeginTxn(nil, gdbx.Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Abort()
+	snapshot := reader.ID()
+	for i := 1; i <= 3; i++ {
+		put(i)
+	}
+
+	got := info()
+	if got.LatterReaderTxnID != snapshot || got.ReaderLag != 3 || got.NumReaders != 1 {
+		t.Fatalf("Info with a held reader: oldest %d, lag %d, %d readers; want %d, 3, 1",
+			got.LatterReaderTxnID, got.ReaderLag, got.NumReaders, snapshot)
+	}
+	var found []gdbx.ReaderInfo
+	err = env.ReaderList(func(r gdbx.ReaderInfo) error {
+		found = append(found, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].TxnID != snapshot || found[0].Lag != 3 {
+		t.Fatalf("ReaderList = %+v, want the reader at txnid %d with lag 3", found, snapshot)
+	}
+
+	reader.Abort()
+	got = info()
+	if got.LatterReaderTxnID != got.RecentTxnID || got.ReaderLag != 0 || got.NumReaders != 0 {
+		t.Fatalf("Info without readers: oldest %d of %d, lag %d, %d readers",
+			got.LatterReaderTxnID, got.RecentTxnID, got.ReaderLag, got.NumReaders)
+	}
+}
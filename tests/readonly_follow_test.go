@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestReadOnlyFollowsWriter opens a database ReadOnly while a writer in
+// another process commits enough to grow the file past the read-only
+// mapping. A transaction begun before keeps its snapshot, and renewing it or
+// beginning a new one sees the writer's commit, including pages past the old
+// end of the file.
+func TestReadOnlyFollowsWriter(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	if err := env.Update(func(txn *gdbx.Txn) error { return followGens.put(txn, 0) }); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	ro, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	ro.SetMaxDBs(10)
+	if err := ro.Open(db.path, gdbx.ReadOnly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	old, err := ro.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer old.Abort()
+	if err := followGens.check(old, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(filepath.Join(db.path, gdbx.DataFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped := fi.Size()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestReadOnlyFollowsWriterChild$")
+	cmd.Env = append(os.Environ(), "GDBX_FOLLOW_PATH="+db.path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("writer: %v\n%s", err, out)
+	}
+	if fi, err = os.Stat(filepath.Join(db.path, gdbx.DataFileName)); err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() <= mapped {
+		t.Fatalf("writer left the file at %d bytes, want more than %d", fi.Size(), mapped)
+	}
+
+	// The old snapshot is still intact
+	if err := followGens.check(old, 0); err != nil {
+		t.Fatalf("before renewing: %v", err)
+	}
+
+	old.Reset()
+	if err := old.Renew(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFollowed(old); err != nil {
+		t.Fatalf("after renewing: %v", err)
+	}
+
+	txn, err := ro.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	if txn.ID() != old.ID() {
+		t.Fatalf("new transaction at txnid %d, renewed one at %d", txn.ID(), old.ID())
+	}
+	if err := checkFollowed(txn); err != nil {
+		t.Fatalf("new transaction: %v", err)
+	}
+}
+
+// TestReadOnlyFollowsWriterChild is the writer process of
+// TestReadOnlyFollowsWriter. It commits values that outgrow the data file.
+func TestReadOnlyFollowsWriterChild(t *testing.T) {
+	path := os.Getenv("GDBX_FOLLOW_PATH")
+	if path == "" {
+		t.Skip("only run by TestReadOnlyFollowsWriter")
+	}
+	fi, err := os.Stat(filepath.Join(path, gdbx.DataFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := openGdbxEnv(t, path, 0)
+	defer env.Close()
+	err = env.Update(func(txn *gdbx.Txn) error {
+		if err := followGens.put(txn, 1); err != nil {
+			return err
+		}
+		dbi, err := txn.OpenDBISimple("added", gdbx.Create)
+		if err != nil {
+			return err
+		}
+		big := bytes.Repeat([]byte{2}, 64<<10)
+		for i := 0; int64(i)*int64(len(big)) <= fi.Size(); i++ {
+			if err := txn.Put(dbi, []byte(fmt.Sprintf("big%06d", i)), big, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// followGens are the generations TestReadOnlyFollowsWriter checks: the first
+// written before the read-only open, the second by the writer process.
+var followGens = genFixture{keys: 100, bigs: 1, dups: 50}
+
+// checkFollowed checks that txn sees the writer's commit: the second
+// generation, and the big values it put in a database of its own, past the
+// old end of the file.
+func checkFollowed(txn *gdbx.Txn) error {
+	if err := followGens.check(txn, 1); err != nil {
+		return err
+	}
+	dbi, err := txn.OpenDBISimple("added", 0)
+	if err != nil {
+		return fmt.Errorf("opening the writer's new database: %w", err)
+	}
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	bigs := 0
+	for k, v, err := cur.Get(nil, nil, gdbx.First); err == nil; k, v, err = cur.Get(nil, nil, gdbx.Next) {
+		if len(v) != 64<<10 || v[len(v)-1] != 2 {
+			return fmt.Errorf("%s has %d bytes", k, len(v))
+		}
+		bigs++
+	}
+	if bigs == 0 {
+		return fmt.Errorf("no big values in the writer's database")
+	}
+	return nil
+}
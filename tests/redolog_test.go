@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestRedoLogReplay crashes a RedoLog writer in another process after a
+// series of commits and puts back the data file as it was before them, as if
+// none of their writes had reached the disk. Reopening must replay every
+// commit from the log. It then cuts the last record of a log short and checks
+// that replay keeps the commits before it and drops that one.
+func TestRedoLogReplay(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	dataPath := filepath.Join(db.path, gdbx.DataFileName)
+	logPath := dataPath + gdbx.RedoSuffix
+
+	env := openGdbxEnv(t, db.path, gdbx.RedoLog)
+	if err := env.Update(func(txn *gdbx.Txn) error { return redoGens.put(txn, 0) }); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+	if fi, err := os.Stat(logPath); err != nil || fi.Size() != 0 {
+		t.Fatalf("log after Close: %v, %v, want empty", fi, err)
+	}
+	before, err := os.ReadFile(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRedoLogReplayChild$")
+	cmd.Env = append(os.Environ(), "GDBX_REDO_PATH="+db.path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("writer: %v\n%s", err, out)
+	}
+	if fi, err := os.Stat(logPath); err != nil || fi.Size() == 0 {
+		t.Fatalf("log after the crash: %v, %v, want records", fi, err)
+	}
+	if err := os.WriteFile(dataPath, before, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env = openGdbxEnv(t, db.path, gdbx.RedoLog)
+	if fi, err := os.Stat(logPath); err != nil || fi.Size() != 0 {
+		t.Fatalf("log after replay: %v, %v, want empty", fi, err)
+	}
+	if err := env.View(func(txn *gdbx.Txn) error { return redoGens.check(txn, redoChildCommits) }); err != nil {
+		t.Fatalf("after replay: %v", err)
+	}
+	env.Close()
+
+	// Two more commits, the last of them torn
+	before, err = os.ReadFile(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env = openGdbxEnv(t, db.path, gdbx.RedoLog)
+	for gen := redoChildCommits + 1; gen <= redoChildCommits+2; gen++ {
+		if err := env.Update(func(txn *gdbx.Txn) error { return redoGens.put(txn, gen) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env.CloseEx(true)
+	fi, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(logPath, fi.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dataPath, before, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env = openGdbxEnv(t, db.path, gdbx.RedoLog)
+	defer env.Close()
+	if err := env.View(func(txn *gdbx.Txn) error { return redoGens.check(txn, redoChildCommits+1) }); err != nil {
+		t.Fatalf("after replaying a torn log: %v", err)
+	}
+	// The environment takes new commits over the replayed ones
+	if err := env.Update(func(txn *gdbx.Txn) error { return redoGens.put(txn, redoChildCommits+2) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.View(func(txn *gdbx.Txn) error { return redoGens.check(txn, redoChildCommits+2) }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// redoChildCommits is how many commits TestRedoLogReplayChild makes.
+const redoChildCommits = 20
+
+// TestRedoLogReplayChild is the writer process of TestRedoLogReplay. It
+// commits generations 1 to redoChildCommits, then exits without closing the
+// environment.
+func TestRedoLogReplayChild(t *testing.T) {
+	path := os.Getenv("GDBX_REDO_PATH")
+	if path == "" {
+		t.Skip("only run by TestRedoLogReplay")
+	}
+	env := openGdbxEnv(t, path, gdbx.RedoLog)
+	for gen := 1; gen <= redoChildCommits; gen++ {
+		err := env.Update(func(txn *gdbx.Txn) error { return redoGens.put(txn, gen) })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Exit(0)
+}
+
+// redoGens are the generations TestRedoLogReplay commits, one per commit.
+var redoGens = genFixture{keys: 5, bigs: 1, dups: 50}
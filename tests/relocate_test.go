@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestOpenRelocated moves a closed database to a new directory and opens it
+// there with OpenRelocated, then checks that it is refused while another Env
+// has the database open, and that damaged or missing data files are refused
+// instead of being initialized.
+func TestOpenRelocated(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	oldPath := filepath.Join(db.path, "old")
+	env := openGdbxEnv(t, oldPath, 0)
+	err := env.Update(func(txn *gdbx.Txn) error {
+		dbi, err := txn.OpenDBISimple("t", gdbx.Create)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			if err := txn.Put(dbi, []byte(fmt.Sprintf("k%04d", i)), make([]byte, 100), 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	env.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newPath := filepath.Join(db.path, "new")
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Open through a relative path to check that Path reports it absolute
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(wd, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err = gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.SetMaxDBs(10)
+	if err := env.OpenRelocated(rel, 0); err != nil {
+		env.Close()
+		t.Fatalf("OpenRelocated: %v", err)
+	}
+	if env.Path() != newPath {
+		t.Errorf("Path() = %q, want %q", env.Path(), newPath)
+	}
+	err = env.Update(func(txn *gdbx.Txn) error {
+		dbi, err := txn.OpenDBISimple("t", 0)
+		if err != nil {
+			return err
+		}
+		if _, err := txn.Get(dbi, []byte("k0999")); err != nil {
+			return err
+		}
+		return txn.Put(dbi, []byte("after"), []byte("move"), 0)
+	})
+	env.Close()
+	if err != nil {
+		t.Fatalf("using the relocated database: %v", err)
+	}
+
+	openRelocated := func(path string) error {
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.Close()
+		return env.OpenRelocated(path, 0)
+	}
+
+	// While another Env has it open, in this process or another, the lock
+	// file that Env relies on is kept
+	holder := openGdbxEnv(t, newPath, 0)
+	if err := openRelocated(newPath); gdbx.Code(err) != gdbx.ErrBusy {
+		t.Fatalf("OpenRelocated while open in the process: got %v, want ErrBusy", err)
+	}
+	if code := openInChild(t, newPath, 0, "GDBX_EXCLUSIVE_RELOCATED=1"); code != gdbx.ErrBusy {
+		t.Fatalf("OpenRelocated while open in another process: got code %d, want ErrBusy", code)
+	}
+	if err := holder.View(func(txn *gdbx.Txn) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	holder.Close()
+	if code := openInChild(t, newPath, 0, "GDBX_EXCLUSIVE_RELOCATED=1"); code != 0 {
+		t.Fatalf("OpenRelocated in another process once closed: got code %d, want success", code)
+	}
+
+	// A directory without a database is not initialized
+	empty := filepath.Join(db.path, "empty")
+	if err := os.Mkdir(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := openRelocated(empty); gdbx.Code(err) != gdbx.ErrInvalid {
+		t.Fatalf("OpenRelocated of an empty directory: got %v, want ErrInvalid", err)
+	}
+	if _, err := os.Stat(filepath.Join(empty, gdbx.DataFileName)); !os.IsNotExist(err) {
+		t.Fatalf("OpenRelocated created a data file: %v", err)
+	}
+
+	dataPath := filepath.Join(newPath, gdbx.DataFileName)
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageSize := gdbx.DefaultPageSize
+
+	// A damaged meta page is refused even though another one is valid
+	damaged := append([]byte(nil), data...)
+	damaged[2*pageSize+20] ^= 0xff
+	if err := os.WriteFile(dataPath, damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := openRelocated(newPath); gdbx.Code(err) != gdbx.ErrCorrupted {
+		t.Fatalf("OpenRelocated with a damaged meta: got %v, want ErrCorrupted", err)
+	}
+
+	// So is a file cut short of the pages the metas refer to
+	if err := os.WriteFile(dataPath, data[:4*pageSize], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := openRelocated(newPath); gdbx.Code(err) != gdbx.ErrCorrupted {
+		t.Fatalf("OpenRelocated of a truncated file: got %v, want ErrCorrupted", err)
+	}
+}
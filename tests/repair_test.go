@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestOpenRepair damages the pages of the last commit, as a commit whose data
+// never reached the disk would, and checks that OpenRepair rolls back to the
+// commit before it, logs that, and leaves a database that gdbx and libmdbx
+// use normally. It also checks that a healthy file is left alone and that a
+// file without a usable meta is refused unchanged.
+func TestOpenRepair(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	var logged []string
+	gdbx.SetLogger(func(msg string, args ...any) { logged = append(logged, msg) }, gdbx.LogLvlDoNotChange)
+	defer gdbx.SetLogger(nil, gdbx.LogLvlDoNotChange)
+
+	put := func(env *gdbx.Env, gen int) (txnID uint64, root uint32, pageSize int) {
+		t.Helper()
+		err := env.Update(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("t", gdbx.Create)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 1000; i++ {
+				if err := txn.Put(dbi, []byte(fmt.Sprintf("k%04d", i)), []byte(fmt.Sprintf("v%d-%d", gen, i)), 0); err != nil {
+					return err
+				}
+			}
+			txnID = txn.ID()
+			info, err := txn.TreeInfo(dbi)
+			if err != nil {
+				return err
+			}
+			stat, err := txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+			root, pageSize = info.Root, int(stat.PageSize)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return txnID, root, pageSize
+	}
+	openRepair := func() (*gdbx.Env, uint64, error) {
+		t.Helper()
+		env, err := gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.SetMaxDBs(10)
+		to, err := env.OpenRepair(db.path, 0)
+		if err != nil {
+			env.Close()
+			return nil, 0, err
+		}
+		return env, to, nil
+	}
+	check := func(env *gdbx.Env, gen int) {
+		t.Helper()
+		err := env.View(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("t", 0)
+			if err != nil {
+				return err
+			}
+			v, err := txn.Get(dbi, []byte("k0500"))
+			if err != nil || string(v) != fmt.Sprintf("v%d-500", gen) {
+				return fmt.Errorf("k0500 = %q, %v, want generation %d", v, err, gen)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := openGdbxEnv(t, db.path, 0)
+	good, _, _ := put(env, 1)
+	_, root, pageSize := put(env, 2)
+	env.Close()
+
+	// The second commit's root page never made it to the file
+	dataPath := filepath.Join(db.path, gdbx.DataFileName)
+	f, err := os.OpenFile(dataPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(make([]byte, pageSize), int64(root)*int64(pageSize)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	env, to, err := openRepair()
+	if err != nil {
+		t.Fatalf("OpenRepair: %v", err)
+	}
+	if to != good {
+		t.Fatalf("OpenRepair rolled back to txnid %d, want %d", to, good)
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], fmt.Sprintf("to txnid %d", good)) {
+		t.Fatalf("logged %q, want the rollback", logged)
+	}
+	check(env, 1)
+	put(env, 3)
+	check(env, 3)
+	env.Close()
+
+	// A healthy file opens at its last commit without changes
+	env, to, err = openRepair()
+	if err != nil {
+		t.Fatalf("OpenRepair of a healthy file: %v", err)
+	}
+	check(env, 3)
+	env.Close()
+	if len(logged) != 1 {
+		t.Fatalf("repairing a healthy file logged %q", logged[1:])
+	}
+
+	// libmdbx reads the repaired file
+	func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		menv, err := mdbx.NewEnv(mdbx.Label("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer menv.Close()
+		menv.SetOption(mdbx.OptMaxDB, 10)
+		if err := menv.Open(db.path, mdbx.Readonly, 0644); err != nil {
+			t.Fatalf("libmdbx open: %v", err)
+		}
+		err = menv.View(func(txn *mdbx.Txn) error {
+			if txn.ID() != to {
+				return fmt.Errorf("libmdbx at txnid %d, want %d", txn.ID(), to)
+			}
+			dbi, err := txn.OpenDBI("t", 0, nil, nil)
+			if err != nil {
+				return err
+			}
+			v, err := txn.Get(dbi, []byte("k0500"))
+			if err != nil || string(v) != "v3-500" {
+				return fmt.Errorf("libmdbx k0500 = %q, %v", v, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Without a usable meta the file is refused and left as it was
+	f, err = os.OpenFile(dataPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for slot := 0; slot < gdbx.NumMetas; slot++ {
+		if _, err := f.WriteAt(bytes.Repeat([]byte{0xa5}, 64), int64(slot*pageSize+gdbx.PageHeaderSize)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+	before, err := os.ReadFile(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openRepair(); gdbx.Code(err) != gdbx.ErrCorrupted {
+		t.Fatalf("OpenRepair without a usable meta: got %v, want ErrCorrupted", err)
+	}
+	after, err := os.ReadFile(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatal("a failed OpenRepair changed the file")
+	}
+}
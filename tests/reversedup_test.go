@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// reverseCmp orders byte strings from their last byte backwards, like
+// libmdbx's REVERSEDUP comparator.
+func reverseCmp(a, b []byte) int {
+	for i, j := len(a)-1, len(b)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if a[i] != b[j] {
+			return int(a[i]) - int(b[j])
+		}
+	}
+	return len(a) - len(b)
+}
+
+// TestReverseDup checks that DupSort|ReverseDup tables keep duplicates in
+// reverse byte order through sub-page inserts, conversion to a sub-tree,
+// GetBothRange searches and deletes, and that libmdbx reads the same order.
+func TestReverseDup(t *testing.T) {
+	for _, n := range []int{6, 3000} {
+		t.Run(fmt.Sprintf("%d-values", n), func(t *testing.T) {
+			testReverseDup(t, n)
+		})
+	}
+}
+
+func testReverseDup(t *testing.T, n int) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	rng := rand.New(rand.NewSource(int64(n)))
+	key := []byte("key")
+	var values [][]byte
+	for _, i := range rng.Perm(n) {
+		values = append(values, []byte(fmt.Sprintf("v%06d", i*7919%1000003)))
+	}
+	sorted := slices.Clone(values)
+	slices.SortFunc(sorted, reverseCmp)
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("rev", gdbx.Create|gdbx.DupSort|gdbx.ReverseDup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		if err := txn.Put(dbi, key, v, 0); err != nil {
+			t.Fatal(err)
+		}
+		// Putting an existing pair again is a no-op
+		if i%10 == 0 {
+			if err := txn.Put(dbi, key, values[i/2], 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+
+	checkOrder := func(want [][]byte) {
+		t.Helper()
+		var got [][]byte
+		_, v, err := cur.Get(key, nil, gdbx.Set)
+		for ; err == nil; _, v, err = cur.Get(nil, nil, gdbx.NextDup) {
+			got = append(got, slices.Clone(v))
+		}
+		if !gdbx.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		if !slices.EqualFunc(got, want, slices.Equal) {
+			t.Fatalf("NextDup order differs: got %d values, want %d (first %q, want %q)", len(got), len(want), got[0], want[0])
+		}
+
+		got = got[:0]
+		_, v, err = cur.Get(key, nil, gdbx.Set)
+		if err == nil {
+			_, v, err = cur.Get(nil, nil, gdbx.LastDup)
+		}
+		for ; err == nil; _, v, err = cur.Get(nil, nil, gdbx.PrevDup) {
+			got = append(got, slices.Clone(v))
+		}
+		slices.Reverse(got)
+		if !slices.EqualFunc(got, want, slices.Equal) {
+			t.Fatalf("PrevDup order differs from NextDup order")
+		}
+
+		// GetBothRange finds the first value at or after the probe in reverse order
+		for range 50 {
+			probe := []byte(fmt.Sprintf("%c%06d", "pvz"[rng.Intn(3)], rng.Intn(1000003)))
+			idx, _ := slices.BinarySearchFunc(want, probe, reverseCmp)
+			_, v, err := cur.Get(key, probe, gdbx.GetBothRange)
+			if idx == len(want) {
+				if !gdbx.IsNotFound(err) {
+					t.Fatalf("GetBothRange(%s) = %q, %v, want not found", probe, v, err)
+				}
+				continue
+			}
+			if err != nil || string(v) != string(want[idx]) {
+				t.Fatalf("GetBothRange(%s) = %q, %v, want %q", probe, v, err, want[idx])
+			}
+		}
+	}
+	checkOrder(sorted)
+
+	// Delete every third value by exact match
+	var kept [][]byte
+	for i, v := range sorted {
+		if i%3 != 0 {
+			kept = append(kept, v)
+			continue
+		}
+		if _, _, err := cur.Get(key, v, gdbx.GetBoth); err != nil {
+			t.Fatalf("GetBoth(%s): %v", v, err)
+		}
+		if err := cur.Del(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkOrder(kept)
+	cur.Close()
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	// libmdbx reads the duplicates in the same order
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, mdbx.Readonly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtxn, err := menv.BeginTxn(nil, mdbx.Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mtxn.Abort()
+	mdbi, err := mtxn.OpenDBI("rev", mdbx.DupSort|mdbx.ReverseDup, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mcur, err := mtxn.OpenCursor(mdbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mcur.Close()
+	var got [][]byte
+	_, v, err := mcur.Get(key, nil, mdbx.Set)
+	for ; err == nil; _, v, err = mcur.Get(nil, nil, mdbx.NextDup) {
+		got = append(got, slices.Clone(v))
+	}
+	if !mdbx.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(got, kept, slices.Equal) {
+		t.Fatalf("libmdbx reads %d values in another order", len(got))
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestSafeValues checks that with SetSafeValues the keys and values from
+// Get, GetWithMeta, MultiGet and cursors are copies: they keep their bytes
+// when the write transaction overwrites the entries in place and after it
+// ends, and writing to them does not change the database.
+func TestSafeValues(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	if env.SafeValues() {
+		t.Fatal("safe values on by default")
+	}
+	if err := env.SetSafeValues(true); err != nil {
+		t.Fatal(err)
+	}
+
+	old := bytes.Repeat([]byte{'a'}, 100)
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"k1", "k2"} {
+		if err := txn.Put(gdbx.MainDBI, []byte(k), old, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Put(dups, []byte(k), old, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v, err := txn.Get(gdbx.MainDBI, []byte("k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := txn.GetWithMeta(gdbx.MainDBI, []byte("k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, errs := txn.MultiGet(gdbx.MainDBI, [][]byte{[]byte("k2"), []byte("k1")})
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cur, err := txn.OpenCursor(gdbx.MainDBI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ck, cv, err := cur.Get([]byte("k1"), nil, gdbx.SetKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dcur, err := txn.OpenCursor(dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dv, err := dcur.Get([]byte("k2"), nil, gdbx.Set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := [][]byte{v, m, multi[0], multi[1], cv, dv}
+
+	// Overwrite in place, through a slice and through the database
+	v[0] = 'x'
+	if v2, err := txn.Get(gdbx.MainDBI, []byte("k1")); err != nil || !bytes.Equal(v2, old) {
+		t.Fatalf("writing to a returned value changed the database: %q, %v", v2, err)
+	}
+	v[0] = 'a'
+	repl := bytes.Repeat([]byte{'b'}, 100)
+	for _, k := range []string{"k1", "k2"} {
+		if err := txn.Put(gdbx.MainDBI, []byte(k), repl, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Del(dups, []byte(k), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Put(dups, []byte(k), repl, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for i, g := range got {
+		if !bytes.Equal(g, old) {
+			t.Fatalf("result %d changed to %q", i, g)
+		}
+	}
+	if string(ck) != "k1" {
+		t.Fatalf("cursor key changed to %q", ck)
+	}
+
+	// Off again, reads alias the map
+	if err := env.SetSafeValues(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.View(func(txn *gdbx.Txn) error {
+		v, err := txn.Get(gdbx.MainDBI, []byte("k1"))
+		if err == nil && !bytes.Equal(v, repl) {
+			t.Errorf("k1 = %q", v)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestSeekFloor checks SeekFloor against a sorted list of the keys, for
+// random targets in a table several levels deep with runs of keys deleted,
+// so that leaves start past their separators, before and after commit; that
+// the cursor can move on from the floor; that a DupSort key gives its last
+// value; and that bounds and a target below every key are honoured.
+func TestSeekFloor(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	key := func(n int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(n)) }
+	rng := rand.New(rand.NewSource(1))
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := txn.OpenDBISimple("empty", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 100; i < 100000; i += 2 {
+		if err := txn.Put(plain, key(i), make([]byte, 40), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 100; i < 3000; i += 10 {
+		for v := 0; v < 1+i%7*20; v++ {
+			if err := txn.Put(dups, key(i), key(v), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var keys []int
+	for i := 100; i < 100000; i += 2 {
+		// Whole leaves go in some runs, the first keys of leaves in others
+		if i/1000%7 == 3 || (i/1000%7 == 5 && i%1000 < 300) {
+			if err := txn.Del(plain, key(i), nil); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		keys = append(keys, i)
+	}
+
+	// floor returns the largest key <= n in keys, or -1
+	floor := func(n int) int {
+		i := sort.SearchInts(keys, n+1)
+		if i == 0 {
+			return -1
+		}
+		return keys[i-1]
+	}
+	check := func(txn *gdbx.Txn) {
+		t.Helper()
+		cur, err := txn.OpenCursor(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+		for r := 0; r < 2000; r++ {
+			n := rng.Intn(100100)
+			k, _, err := cur.SeekFloor(key(n))
+			want := floor(n)
+			if want < 0 {
+				if !gdbx.IsNotFound(err) {
+					t.Fatalf("SeekFloor(%d) = %x, %v, want ErrNotFound", n, k, err)
+				}
+				continue
+			}
+			if err != nil || string(k) != string(key(want)) {
+				t.Fatalf("SeekFloor(%d) = %x, %v, want %d", n, k, err, want)
+			}
+			next, _, err := cur.Get(nil, nil, gdbx.Next)
+			if i := sort.SearchInts(keys, want+1); i < len(keys) {
+				if err != nil || string(next) != string(key(keys[i])) {
+					t.Fatalf("Next after SeekFloor(%d) = %x, %v, want %d", n, next, err, keys[i])
+				}
+			} else if !gdbx.IsNotFound(err) {
+				t.Fatalf("Next after the last key: %x, %v", next, err)
+			}
+		}
+	}
+	check(txn)
+
+	cur, err := txn.OpenCursor(dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, v, err := cur.SeekFloor(key(2005))
+	if err != nil || string(k) != string(key(2000)) || string(v) != string(key(2000%7*20)) {
+		t.Fatalf("DupSort SeekFloor = %x %x, %v", k, v, err)
+	}
+	if _, v, err := cur.Get(nil, nil, gdbx.PrevDup); err != nil || string(v) != string(key(2000%7*20-1)) {
+		t.Fatalf("PrevDup after SeekFloor = %x, %v", v, err)
+	}
+	if _, _, err := cur.SeekFloor(key(99)); !gdbx.IsNotFound(err) || !cur.AtEnd() {
+		t.Fatalf("below every key: %v, at end %v", err, cur.AtEnd())
+	}
+
+	cur.SetBounds(key(1000), key(2000))
+	if k, _, err := cur.SeekFloor(key(5000)); err != nil || string(k) != string(key(1990)) {
+		t.Fatalf("above high: %x, %v", k, err)
+	}
+	if k, _, err := cur.SeekFloor(key(1505)); err != nil || string(k) != string(key(1500)) {
+		t.Fatalf("within bounds: %x, %v", k, err)
+	}
+	if _, _, err := cur.SeekFloor(key(995)); !gdbx.IsNotFound(err) {
+		t.Fatalf("below low: %v", err)
+	}
+
+	ecur, err := txn.OpenCursor(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ecur.SeekFloor(key(1)); !gdbx.IsNotFound(err) {
+		t.Fatalf("empty table: %v", err)
+	}
+
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	check(rtxn)
+}
+
+// TestSetKeyNearest checks that SetKeyNearest reports a hit as exact and a
+// miss with the next key above, which Next continues from and PeekPrev
+// precedes, on a plain and a DupSort table; and that it gives ErrNotFound
+// past the last key, and past SetBounds.
+func TestSetKeyNearest(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := []string{"apple", "apricot", "banana", "blueberry", "cherry", "grape", "grapefruit", "melon"}
+	for _, w := range words {
+		if err := txn.Put(plain, []byte(w), []byte("fruit:"+w), 0); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []string{"b", "a", "c"} {
+			if err := txn.Put(dups, []byte(w), []byte(v), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		dbi         gdbx.DBI
+		key         string
+		exact       bool
+		want, value string
+		prev        string
+	}{
+		{plain, "banana", true, "banana", "fruit:banana", "apricot"},
+		{plain, "b", false, "banana", "fruit:banana", "apricot"},
+		{plain, "", false, "apple", "fruit:apple", ""},
+		{plain, "grape", true, "grape", "fruit:grape", "cherry"},
+		{plain, "grapes", false, "melon", "fruit:melon", "grapefruit"},
+		{dups, "blue", false, "blueberry", "a", "banana"},
+		{dups, "cherry", true, "cherry", "a", "blueberry"},
+	} {
+		c, err := txn.OpenCursor(tc.dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exact, k, v, err := c.SetKeyNearest([]byte(tc.key))
+		if err != nil || exact != tc.exact || string(k) != tc.want || string(v) != tc.value {
+			t.Fatalf("SetKeyNearest(%q) = %v, %q, %q, %v; want %v, %q, %q", tc.key, exact, k, v, err, tc.exact, tc.want, tc.value)
+		}
+		prev, found, err := c.PeekPrev()
+		if err != nil || found != (tc.prev != "") || string(prev) != tc.prev {
+			t.Fatalf("PeekPrev after SetKeyNearest(%q) = %q, %v, %v; want %q", tc.key, prev, found, err, tc.prev)
+		}
+		if tc.dbi == plain && tc.want != "melon" {
+			next, _, err := c.Get(nil, nil, gdbx.Next)
+			if err != nil || string(next) <= tc.want {
+				t.Fatalf("Next after SetKeyNearest(%q) = %q, %v", tc.key, next, err)
+			}
+		}
+		c.Close()
+	}
+
+	c, err := txn.OpenCursor(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if exact, k, _, err := c.SetKeyNearest([]byte("zucchini")); !gdbx.IsNotFound(err) {
+		t.Fatalf("SetKeyNearest past the last key = %v, %q, %v", exact, k, err)
+	}
+	c.SetBounds([]byte("apple"), []byte("cherry"))
+	if exact, k, _, err := c.SetKeyNearest([]byte("c")); !gdbx.IsNotFound(err) {
+		t.Fatalf("SetKeyNearest past the bounds = %v, %q, %v", exact, k, err)
+	}
+	if exact, k, _, err := c.SetKeyNearest([]byte("bl")); err != nil || exact || string(k) != "blueberry" {
+		t.Fatalf("SetKeyNearest within the bounds = %v, %q, %v", exact, k, err)
+	}
+}
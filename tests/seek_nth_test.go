@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestSeekNth checks SeekNth and SeekNthValue against the entries a full
+// scan visits, on a plain table and on a DupSort table whose keys hold one
+// value, a sub-page or a sub-tree, and that Next continues from the entry
+// found.
+func TestSeekNth(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { txn.Abort() }()
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5000; i++ {
+		if err := txn.Put(plain, []byte(fmt.Sprintf("key-%05d", i)), []byte(fmt.Sprintf("value-%d", i)), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 300; i++ {
+		n := 1
+		switch i % 10 {
+		case 3:
+			n = 4
+		case 7:
+			n = 1500
+		}
+		for j := 0; j < n; j++ {
+			if err := txn.Put(dups, []byte(fmt.Sprintf("key-%03d", i)), []byte(fmt.Sprintf("dup-%04d", j)), 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	type entry struct{ k, v []byte }
+	check := func(t *testing.T, dbi gdbx.DBI) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+
+		var values, keys []entry
+		for k, v, err := cur.Get(nil, nil, gdbx.First); err == nil; k, v, err = cur.Get(nil, nil, gdbx.Next) {
+			e := entry{bytes.Clone(k), bytes.Clone(v)}
+			if len(keys) == 0 || !bytes.Equal(keys[len(keys)-1].k, k) {
+				keys = append(keys, e)
+			}
+			values = append(values, e)
+		}
+
+		seekers := []struct {
+			name string
+			seek func(uint64) ([]byte, []byte, error)
+			want []entry
+		}{
+			{"SeekNth", cur.SeekNth, keys},
+			{"SeekNthValue", cur.SeekNthValue, values},
+		}
+		for _, s := range seekers {
+			for n := 0; n < len(s.want); n += 1 + n/7 {
+				k, v, err := s.seek(uint64(n))
+				if err != nil {
+					t.Fatalf("%s(%d): %v", s.name, n, err)
+				}
+				if !bytes.Equal(k, s.want[n].k) || !bytes.Equal(v, s.want[n].v) {
+					t.Fatalf("%s(%d) = %q/%q, want %q/%q", s.name, n, k, v, s.want[n].k, s.want[n].v)
+				}
+				if n+1 == len(s.want) {
+					continue
+				}
+				if s.name == "SeekNthValue" {
+					// Next steps through the values, so it reaches the next one
+					k, v, err = cur.Get(nil, nil, gdbx.Next)
+					if err != nil || !bytes.Equal(k, values[n+1].k) || !bytes.Equal(v, values[n+1].v) {
+						t.Fatalf("Next after %s(%d) = %q/%q (%v), want %q/%q", s.name, n, k, v, err, values[n+1].k, values[n+1].v)
+					}
+				} else {
+					k, _, err = cur.Get(nil, nil, gdbx.NextNoDup)
+					if err != nil || !bytes.Equal(k, keys[n+1].k) {
+						t.Fatalf("NextNoDup after %s(%d) = %q (%v), want %q", s.name, n, k, err, keys[n+1].k)
+					}
+				}
+			}
+			for _, n := range []uint64{uint64(len(s.want)), uint64(len(s.want)) + 10, 1 << 62} {
+				if _, _, err := s.seek(n); !gdbx.IsNotFound(err) {
+					t.Fatalf("%s(%d) past the end: %v, want not found", s.name, n, err)
+				}
+				if !cur.EOF() {
+					t.Fatalf("%s(%d) past the end left the cursor off EOF", s.name, n)
+				}
+			}
+		}
+	}
+	t.Run("plain", func(t *testing.T) { check(t, plain) })
+	t.Run("dupsort", func(t *testing.T) { check(t, dups) })
+
+	// The same seeks through a read transaction's cursors
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if txn, err = env.BeginTxn(nil, gdbx.Readonly); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("plain read-only", func(t *testing.T) { check(t, plain) })
+	t.Run("dupsort read-only", func(t *testing.T) { check(t, dups) })
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestSequencePersistence verifies that Txn.Sequence returns the previous value,
+// advances within a write transaction, and survives commit and reopen.
+func TestSequencePersistence(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := txn.OpenDBISimple("seq", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct{ inc, want uint64 }{{0, 0}, {5, 0}, {1, 5}, {1, 6}}
+	for i, step := range steps {
+		got, err := txn.Sequence(dbi, step.inc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != step.want {
+			t.Fatalf("step %d: Sequence = %d, want %d", i, got, step.want)
+		}
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Aborted increments must not be persisted
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.Sequence(dbi, 100); err != nil {
+		t.Fatal(err)
+	}
+	txn.Abort()
+	env.Close()
+
+	env = openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	dbi, err = rtxn.OpenDBISimple("seq", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := rtxn.Sequence(dbi, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 7 {
+		t.Fatalf("Sequence after reopen = %d, want 7", got)
+	}
+	if _, err := rtxn.Sequence(dbi, 1); err == nil {
+		t.Fatal("expected error incrementing sequence in read-only transaction")
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestSetRangeDupSort checks that SetRange on a DUPSORT table lands on the
+// first duplicate of the found key, so Count and NextDup work right away,
+// whatever the cursor was doing before.
+func TestSetRangeDupSort(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	// Keys alternate between single values, inline sub-pages and sub-trees
+	const numKeys = 200
+	values := func(i int) [][]byte {
+		n := 1 + i%4
+		if i%5 == 0 {
+			n = 600
+		}
+		var vals [][]byte
+		for j := 0; j < n; j++ {
+			vals = append(vals, []byte(fmt.Sprintf("val%04d", j)))
+		}
+		return vals
+	}
+	key := func(i int) []byte { return []byte(fmt.Sprintf("k%03d", i)) }
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < numKeys; i++ {
+		vals := values(i)
+		// Insert in reverse so the first duplicate is not the last one written
+		for j := len(vals) - 1; j >= 0; j-- {
+			if err := txn.Put(dbi, key(i), vals[j], 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	check := func(t *testing.T, txn *gdbx.Txn) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+
+		// Leave the cursor in various dup positions before each SetRange
+		moves := []func(){
+			func() { cur.Get(key(5), nil, gdbx.Set); cur.Get(nil, nil, gdbx.LastDup) },
+			func() { cur.Get(key(10), []byte("val0300"), gdbx.GetBoth) },
+			func() { cur.Get(key(3), nil, gdbx.Set); cur.Get(nil, nil, gdbx.Next) },
+			func() { cur.Get(nil, nil, gdbx.Last) },
+			func() {},
+		}
+		for i := 0; i < numKeys; i++ {
+			// Probe with the exact key and with one just below it
+			for _, probe := range [][]byte{key(i), append(key(i - 1), '~')} {
+				moves[i%len(moves)]()
+
+				want := values(i)
+				k, v, err := cur.Get(probe, nil, gdbx.SetRange)
+				if err != nil {
+					t.Fatalf("SetRange(%s): %v", probe, err)
+				}
+				if string(k) != string(key(i)) || string(v) != string(want[0]) {
+					t.Fatalf("SetRange(%s) = %s/%s, want %s/%s", probe, k, v, key(i), want[0])
+				}
+				count, err := cur.Count()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count != uint64(len(want)) {
+					t.Fatalf("SetRange(%s): Count = %d, want %d", probe, count, len(want))
+				}
+				got := [][]byte{slices.Clone(v)}
+				for {
+					_, v, err := cur.Get(nil, nil, gdbx.NextDup)
+					if gdbx.IsNotFound(err) {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = append(got, slices.Clone(v))
+				}
+				if !slices.EqualFunc(got, want, slices.Equal) {
+					t.Fatalf("SetRange(%s): NextDup visited %d values, want %d", probe, len(got), len(want))
+				}
+			}
+		}
+	}
+
+	t.Run("write-txn", func(t *testing.T) { check(t, txn) })
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	t.Run("read-txn", func(t *testing.T) { check(t, rtxn) })
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestSetWithCount checks that SetWithCount returns the first value and the
+// number of duplicates for single values, inline sub-pages and sub-trees,
+// of DupSort and DupFixed tables, and leaves the cursor ready for NextDup.
+func TestSetWithCount(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	counts := map[string]int{"single": 1, "subpage": 7, "subtree": 2000}
+	for name, flags := range map[string]uint{"dups": gdbx.DupSort, "dupfixed": gdbx.DupSort | gdbx.DupFixed} {
+		dups, err := txn.OpenDBISimple(name, gdbx.Create|flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k, n := range counts {
+			for i := n - 1; i >= 0; i-- {
+				if err := txn.Put(dups, []byte(k), []byte(fmt.Sprintf("v%05d", i)), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		cur, err := txn.OpenCursor(dups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cur.Close()
+
+		for k, n := range counts {
+			v, count, err := cur.SetWithCount([]byte(k))
+			if err != nil {
+				t.Fatalf("%s: SetWithCount(%s): %v", name, k, err)
+			}
+			if string(v) != "v00000" || count != uint64(n) {
+				t.Fatalf("%s: SetWithCount(%s) = %s, %d, want v00000, %d", name, k, v, count, n)
+			}
+			seen := 1
+			for {
+				if _, _, err := cur.Get(nil, nil, gdbx.NextDup); gdbx.IsNotFound(err) {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				seen++
+			}
+			if seen != n {
+				t.Fatalf("%s: %s: NextDup after SetWithCount visited %d values, want %d", name, k, seen, n)
+			}
+		}
+
+		if _, _, err := cur.SetWithCount([]byte("missing")); !gdbx.IsNotFound(err) {
+			t.Fatalf("%s: SetWithCount(missing): got %v, want not found", name, err)
+		}
+	}
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Put(plain, []byte("k"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	pcur, err := txn.OpenCursor(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcur.Close()
+	if v, count, err := pcur.SetWithCount([]byte("k")); err != nil || string(v) != "v" || count != 1 {
+		t.Fatalf("SetWithCount on plain table = %s, %d, %v", v, count, err)
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestOpenSharedEnv verifies that OpenShared hands out references to the Env
+// that has a data file open, under any spelling of its path, while Open
+// still maps it again, and that concurrent OpenShared calls share one Env.
+func TestOpenSharedEnv(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(db.path, link); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second Open of the same file maps it again, as it always has
+	other := newEnv(t)
+	if err := other.Open(db.path, 0, 0644); err != nil {
+		t.Fatalf("second Open: %v", err)
+	}
+	other.Close()
+
+	// OpenShared returns the open Env, and needs matching flags
+	shared := newEnv(t)
+	got, err := shared.OpenShared(link, 0, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != env {
+		t.Fatal("OpenShared did not return the open environment")
+	}
+	if _, err := newEnv(t).OpenShared(db.path, gdbx.ReadOnly, 0644); gdbx.Code(err) != gdbx.ErrIncompatible {
+		t.Fatalf("OpenShared with other flags: %v, want ErrIncompatible", err)
+	}
+
+	// Closing the shared reference leaves the environment usable
+	got.Close()
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatalf("BeginTxn after closing shared reference: %v", err)
+	}
+	if err := txn.Put(gdbx.MainDBI, []byte("k"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	env.Close()
+
+	// After the last reference is closed OpenShared on the path opens the
+	// receiver itself
+	reopen := newEnv(t)
+	got, err = reopen.OpenShared(db.path, 0, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Close()
+	if got != reopen {
+		t.Fatal("OpenShared on a closed path returned another environment")
+	}
+	rtxn, err := got.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	if v, err := rtxn.Get(gdbx.MainDBI, []byte("k")); err != nil || string(v) != "v" {
+		t.Fatalf("Get after reopen = %q, %v", v, err)
+	}
+}
+
+// TestOpenSharedConcurrent opens one path with OpenShared from several
+// goroutines at once. Exactly one of them must open it, and the others share
+// its Env.
+func TestOpenSharedConcurrent(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	const n = 8
+	envs := make([]*gdbx.Env, n)
+	got := make([]*gdbx.Env, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range envs {
+		envs[i] = newEnv(t)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], errs[i] = envs[i].OpenShared(db.path, 0, 0644)
+		}()
+	}
+	wg.Wait()
+
+	opened := 0
+	for i := range got {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if got[i] != got[0] {
+			t.Fatalf("OpenShared %d returned another environment", i)
+		}
+		if got[i] == envs[i] {
+			opened++
+		}
+	}
+	if opened != 1 {
+		t.Fatalf("%d OpenShared calls opened the path, want 1", opened)
+	}
+	for i := range got {
+		got[i].Close()
+	}
+}
+
+func newEnv(t *testing.T) *gdbx.Env {
+	t.Helper()
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
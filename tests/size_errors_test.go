@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestPutSizeErrors verifies that oversized keys and values are rejected with
+// distinct codes, ErrBadKeySize and ErrBadValSize, naming the limit. A
+// duplicate beyond the key limit is rejected the same way whether its key
+// holds one value, a sub-page or a sub-tree, and leaves the key's values as
+// they were.
+func TestPutSizeErrors(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := txn.OpenCursor(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+
+	maxKey := env.MaxKeySize()
+	sized := func(n int) []byte { return bytes.Repeat([]byte{'x'}, n) }
+	put := func(dbi gdbx.DBI, k, v []byte) error { return txn.Put(dbi, k, v, 0) }
+
+	// "sub" holds a few values, on a sub-page; "tree" enough for a sub-tree
+	for i := 0; i < 500; i++ {
+		v := []byte(fmt.Sprintf("value-%04d", i))
+		if i < 3 {
+			if err := put(dups, []byte("sub"), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := put(dups, []byte("tree"), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dcur, err := txn.OpenCursor(dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dcur.Close()
+
+	tests := []struct {
+		name  string
+		put   func() error
+		code  gdbx.ErrorCode
+		limit int
+	}{
+		{"max key", func() error { return put(plain, sized(maxKey), []byte("v")) }, gdbx.Success, 0},
+		{"long key", func() error { return put(plain, sized(maxKey+1), []byte("v")) }, gdbx.ErrBadKeySize, maxKey},
+		{"long key via cursor", func() error { return cur.Put(sized(maxKey+1), []byte("v"), 0) }, gdbx.ErrBadKeySize, maxKey},
+		{"long key in dupsort", func() error { return put(dups, sized(maxKey+1), []byte("v")) }, gdbx.ErrBadKeySize, maxKey},
+		{"large value", func() error { return put(plain, []byte("big"), sized(1<<20)) }, gdbx.Success, 0},
+		{"max duplicate", func() error { return put(dups, []byte("k"), sized(maxKey)) }, gdbx.Success, 0},
+		{"long duplicate", func() error { return put(dups, []byte("k"), sized(maxKey+1)) }, gdbx.ErrBadValSize, maxKey},
+		{"long duplicate on a sub-page", func() error { return put(dups, []byte("sub"), sized(maxKey+1)) }, gdbx.ErrBadValSize, maxKey},
+		{"long duplicate in a sub-tree", func() error { return put(dups, []byte("tree"), sized(maxKey+1)) }, gdbx.ErrBadValSize, maxKey},
+		{"long duplicate via cursor", func() error { return dcur.Put([]byte("tree"), sized(2*maxKey), gdbx.AppendDup) }, gdbx.ErrBadValSize, maxKey},
+	}
+	for _, tt := range tests {
+		err := tt.put()
+		if tt.code == gdbx.Success {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if code := gdbx.Code(err); code != tt.code {
+			t.Errorf("%s: got %v (code %d), want code %d", tt.name, err, code, tt.code)
+			continue
+		}
+		if !strings.Contains(err.Error(), strconv.Itoa(tt.limit)) {
+			t.Errorf("%s: error %q does not name the limit %d", tt.name, err, tt.limit)
+		}
+	}
+
+	for key, want := range map[string]uint64{"k": 1, "sub": 3, "tree": 500} {
+		if _, _, err := dcur.Get([]byte(key), nil, gdbx.Set); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := dcur.Count(); err != nil || n != want {
+			t.Errorf("%s holds %d values after the rejected puts (%v), want %d", key, n, err, want)
+		}
+	}
+}
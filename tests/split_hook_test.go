@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestOnSplit records the splits of a growing table through OnSplit and
+// checks that each names the table, two distinct pages and a separator that
+// is one of its keys; that a DupSort key's sub-tree splits unreported; and
+// that a nil hook stops the calls.
+func TestOnSplit(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	table, err := txn.OpenDBISimple("table", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type split struct {
+		dbi         gdbx.DBI
+		left, right uint32
+		sep         []byte
+	}
+	var splits []split
+	if err := txn.OnSplit(func(dbi gdbx.DBI, left, right uint32, sep []byte) {
+		splits = append(splits, split{dbi, left, right, bytes.Clone(sep)})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3000; i++ {
+		if err := txn.Put(table, []byte(fmt.Sprintf("key-%05d", i)), bytes.Repeat([]byte{'v'}, 40), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := txn.Put(dups, []byte("one"), []byte(fmt.Sprintf("dup-%05d", i)), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := txn.Stat(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LeafPages < 2 {
+		t.Fatalf("table has %d leaf pages, want a split", st.LeafPages)
+	}
+	if len(splits) == 0 {
+		t.Fatal("no split reported")
+	}
+	for _, s := range splits {
+		if s.dbi == dups {
+			t.Fatalf("split of the DupSort sub-tree reported: %+v", s)
+		}
+		if s.dbi != table && s.dbi != gdbx.MainDBI {
+			t.Fatalf("split reported for DBI %d", s.dbi)
+		}
+		if s.left == s.right || s.left < 3 || s.right < 3 {
+			t.Fatalf("split between pages %d and %d", s.left, s.right)
+		}
+		if s.dbi != table {
+			continue
+		}
+		if _, err := txn.Get(table, s.sep); err != nil {
+			t.Fatalf("separator %q of split %d/%d: %v", s.sep, s.left, s.right, err)
+		}
+	}
+	if uint64(len(splits)) < st.LeafPages-1 {
+		t.Fatalf("%d splits reported for %d leaf pages", len(splits), st.LeafPages)
+	}
+
+	if err := txn.OnSplit(nil); err != nil {
+		t.Fatal(err)
+	}
+	n := len(splits)
+	for i := 3000; i < 6000; i++ {
+		if err := txn.Put(table, []byte(fmt.Sprintf("key-%05d", i)), []byte("v"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(splits) != n {
+		t.Fatalf("%d splits reported after the hook was removed", len(splits)-n)
+	}
+
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *gdbx.Txn) error {
+		if err := txn.OnSplit(func(gdbx.DBI, uint32, uint32, []byte) {}); gdbx.Code(err) != gdbx.ErrPermissionDenied {
+			t.Errorf("OnSplit on a read transaction: %v, want ErrPermissionDenied", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
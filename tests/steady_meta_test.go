@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestSafeNoSyncRollback commits with SafeNoSync on top of a synced commit
+// and makes the data file look as if the system had restarted since, by
+// changing the boot ID in the newest meta. Open must fall back to the synced
+// commit, however many weak ones followed it, ReadOnly must refuse to, and
+// commits made durable by Sync or Close must survive.
+func TestSafeNoSyncRollback(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the boot ID is only known on Linux")
+	}
+	db := newTestDB(t)
+	defer db.cleanup()
+	dataPath := filepath.Join(db.path, gdbx.DataFileName)
+
+	put := func(env *gdbx.Env, key string) {
+		t.Helper()
+		if err := env.Update(func(txn *gdbx.Txn) error {
+			return txn.Put(gdbx.MainDBI, []byte(key), []byte("v"), 0)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys := func(env *gdbx.Env) (n int) {
+		t.Helper()
+		if err := env.View(func(txn *gdbx.Txn) error {
+			c, err := txn.OpenCursor(gdbx.MainDBI)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			for _, _, err := c.Get(nil, nil, gdbx.First); err == nil; _, _, err = c.Get(nil, nil, gdbx.Next) {
+				n++
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	env := openGdbxEnv(t, db.path, 0)
+	put(env, "synced")
+	env.Close()
+
+	env = openGdbxEnv(t, db.path, gdbx.SafeNoSync)
+	for i := 0; i < 5; i++ {
+		put(env, fmt.Sprintf("weak%d", i))
+	}
+	env.CloseEx(true)
+	simulateRestart(t, dataPath)
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Open(db.path, gdbx.ReadOnly, 0644)
+	env.Close()
+	if gdbx.Code(err) != gdbx.ErrWannaRecovery {
+		t.Fatalf("ReadOnly open after a restart: got %v, want ErrWannaRecovery", err)
+	}
+
+	env = openGdbxEnv(t, db.path, gdbx.SafeNoSync)
+	if n := keys(env); n != 1 {
+		t.Fatalf("%d keys after a restart, want only the synced one", n)
+	}
+
+	// Synced by Sync, and by Close
+	put(env, "weak")
+	if err := env.Sync(true, false); err != nil {
+		t.Fatal(err)
+	}
+	env.CloseEx(true)
+	simulateRestart(t, dataPath)
+	env = openGdbxEnv(t, db.path, gdbx.SafeNoSync)
+	put(env, "closed")
+	env.Close()
+	simulateRestart(t, dataPath)
+	env = openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+	if n := keys(env); n != 3 {
+		t.Fatalf("%d keys after synced commits and a restart, want 3", n)
+	}
+	if err := env.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// simulateRestart changes the boot ID recorded in the newest meta of the data
+// file, as if the system had restarted since it was written.
+func simulateRestart(t *testing.T, dataPath string) {
+	t.Helper()
+	f, err := os.OpenFile(dataPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	const txnidOffset, bootIDOffset = 20 + 8, 20 + 192
+	page := make([]byte, gdbx.DefaultPageSize)
+	newest, newestID := 0, uint64(0)
+	for i := 0; i < gdbx.NumMetas; i++ {
+		if _, err := f.ReadAt(page, int64(i*gdbx.DefaultPageSize)); err != nil {
+			t.Fatal(err)
+		}
+		if id := binary.LittleEndian.Uint64(page[txnidOffset:]); id > newestID {
+			newest, newestID = i, id
+		}
+	}
+	if _, err := f.WriteAt([]byte("another boot ID!"), int64(newest*gdbx.DefaultPageSize+bootIDOffset)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbx "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestValueStreaming writes values through Txn.GetWriter in uneven chunks,
+// reads them back through Txn.GetReader in both write and read transactions,
+// and checks that libmdbx sees the same values.
+func TestValueStreaming(t *testing.T) {
+	for _, mode := range []struct {
+		name  string
+		flags uint
+	}{{"default", 0}, {"writemap", gdbx.WriteMap}} {
+		t.Run(mode.name, func(t *testing.T) {
+			testValueStreaming(t, mode.flags)
+		})
+	}
+}
+
+func testValueStreaming(t *testing.T, flags uint) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, flags)
+	defer env.Close()
+
+	rng := rand.New(rand.NewSource(1))
+	pattern := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+	values := map[string][]byte{
+		"blob":  pattern(3<<20 + 123),
+		"small": pattern(100),
+		"empty": {},
+	}
+
+	// stream copies r into a buffer using reads of uneven sizes
+	stream := func(r io.Reader) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		buf := make([]byte, 10000)
+		for {
+			n, err := r.Read(buf[:1+rng.Intn(len(buf))])
+			out.Write(buf[:n])
+			if err == io.EOF {
+				return out.Bytes()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	checkRead := func(txn *gdbx.Txn, dbi gdbx.DBI) {
+		t.Helper()
+		for k, want := range values {
+			r, size, err := txn.GetReader(dbi, []byte(k))
+			if err != nil {
+				t.Fatalf("GetReader(%s): %v", k, err)
+			}
+			if size != int64(len(want)) {
+				t.Fatalf("GetReader(%s) size = %d, want %d", k, size, len(want))
+			}
+			if got := stream(r); !bytes.Equal(got, want) {
+				t.Fatalf("GetReader(%s) returned %d bytes differing from the value", k, len(got))
+			}
+		}
+		if _, _, err := txn.GetReader(dbi, []byte("missing")); !gdbx.IsNotFound(err) {
+			t.Fatalf("GetReader(missing): got %v, want not found", err)
+		}
+	}
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("blobs", gdbx.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An existing big value of another size is replaced
+	if err := txn.Put(dbi, []byte("blob"), pattern(50000), 0); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range values {
+		w, err := txn.GetWriter(dbi, []byte(k), int64(len(v)), 0)
+		if err != nil {
+			t.Fatalf("GetWriter(%s): %v", k, err)
+		}
+		for rest := v; len(rest) > 0; {
+			n := min(len(rest), 1+rng.Intn(20000))
+			if m, err := w.Write(rest[:n]); err != nil || m != n {
+				t.Fatalf("Write(%s) = %d, %v", k, m, err)
+			}
+			rest = rest[n:]
+		}
+		if _, err := w.Write([]byte{1}); gdbx.Code(err) != gdbx.ErrBadValSize {
+			t.Fatalf("writing past the size: got %v, want ErrBadValSize", err)
+		}
+		got, err := txn.Get(dbi, []byte(k))
+		if err != nil || !bytes.Equal(got, v) {
+			t.Fatalf("Get(%s) after streaming: %d bytes, %v", k, len(got), err)
+		}
+	}
+	if _, err := txn.GetWriter(dbi, []byte("blob"), 10, gdbx.NoOverwrite); gdbx.Code(err) != gdbx.ErrKeyExist {
+		t.Fatalf("GetWriter with NoOverwrite: got %v, want ErrKeyExist", err)
+	}
+	checkRead(txn, dbi)
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRead(rtxn, dbi)
+	r, _, err := rtxn.GetReader(dbi, []byte("blob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtxn.Abort()
+	if _, err := r.Read(make([]byte, 10)); gdbx.Code(err) != gdbx.ErrBadTxn {
+		t.Fatalf("Read after the txn ended: got %v, want ErrBadTxn", err)
+	}
+	env.Close()
+
+	// libmdbx reads the streamed values
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	menv, err := mdbx.NewEnv(mdbx.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer menv.Close()
+	menv.SetOption(mdbx.OptMaxDB, 10)
+	if err := menv.Open(db.path, mdbx.Readonly, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtxn, err := menv.BeginTxn(nil, mdbx.Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mtxn.Abort()
+	mdbi, err := mtxn.OpenDBI("blobs", 0, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range values {
+		got, err := mtxn.Get(mdbi, []byte(k))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("libmdbx Get(%s): %d bytes, %v", k, len(got), err)
+		}
+	}
+}
@@ -483,8 +483,8 @@ func TestSubPageBinaryCompat(t *testing.T) {
 	gdbxTxn, _ = gdbxEnv.BeginTxn(nil, gdbx.TxnReadOnly)
 	defer gdbxTxn.Abort()
 	dbi, _ = gdbxTxn.OpenDBISimple("test", 0)
-	tree := gdbxTxn.GetTree(dbi)
-	gdbxPage, _ := gdbxTxn.DebugGetPage(uint32(tree.Root))
+	tree, _ := gdbxTxn.TreeInfo(dbi)
+	gdbxPage, _ := gdbxTxn.DebugGetPage(tree.Root)
 
 	mdbxEnv, _ = mdbx.NewEnv(mdbx.Label("test"))
 	mdbxEnv.SetGeometry(-1, -1, 1<<30, -1, -1, 4096)
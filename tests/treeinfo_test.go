@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestTreeInfo checks that TreeInfo reports the tree header, agrees with
+// Stat, and returns a copy.
+func TestTreeInfo(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	dbi, err := txn.OpenDBISimple("info", gdbx.Create|gdbx.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := txn.TreeInfo(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Root != 0xFFFFFFFF || info.Height != 0 || info.Items != 0 {
+		t.Fatalf("empty tree: %+v", info)
+	}
+	if info.Flags&gdbx.DupSort == 0 {
+		t.Fatalf("flags 0x%x lack DupSort", info.Flags)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if err := txn.Put(dbi, []byte(fmt.Sprintf("key%04d", i/2)), []byte(fmt.Sprintf("val%04d", i)), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := txn.Sequence(dbi, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = txn.TreeInfo(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := txn.Stat(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Root != stat.Root || uint32(info.Height) != stat.Depth || info.Items != stat.Entries ||
+		info.BranchPages != stat.BranchPages || info.LeafPages != stat.LeafPages || info.LargePages != stat.LargePages {
+		t.Fatalf("TreeInfo %+v disagrees with Stat %+v", info, stat)
+	}
+	if info.Items != 1000 || info.Height == 0 || info.Sequence != 7 {
+		t.Fatalf("after 1000 puts and Sequence(7): %+v", info)
+	}
+
+	// The result is a copy
+	info.Items = 0
+	info.Root = 0
+	if again, _ := txn.TreeInfo(dbi); again.Items != 1000 || again.Root != stat.Root {
+		t.Fatalf("modifying the returned TreeInfo changed the tree: %+v", again)
+	}
+
+	if _, err := txn.TreeInfo(gdbx.DBI(1 << 20)); gdbx.Code(err) != gdbx.ErrBadDBI {
+		t.Fatalf("TreeInfo of unknown DBI: %v, want ErrBadDBI", err)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.TreeInfo(dbi); gdbx.Code(err) != gdbx.ErrBadTxn {
+		t.Fatalf("TreeInfo after Commit: %v, want ErrBadTxn", err)
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestBeginTxnAt commits three generations of a value in the main and a
+// named table, then opens a transaction on each snapshot the metas still
+// name. Each must see its own generation, stay on it across later commits,
+// and Renew must move it to the newest. A txnid no meta names any longer
+// must give ErrNotFound.
+func TestBeginTxnAt(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	var dbi gdbx.DBI
+	ids := make(map[uint64]string)
+	var first uint64
+	for gen := 0; gen < 5; gen++ {
+		v := fmt.Sprintf("gen-%d", gen)
+		err := env.Update(func(txn *gdbx.Txn) error {
+			var err error
+			if dbi, err = txn.OpenDBISimple("named", gdbx.Create); err != nil {
+				return err
+			}
+			if err := txn.Put(gdbx.MainDBI, []byte("main"), []byte(v), 0); err != nil {
+				return err
+			}
+			return txn.Put(dbi, []byte("key"), []byte(v), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[info.RecentTxnID] = v
+		if gen == 0 {
+			first = info.RecentTxnID
+		}
+	}
+
+	check := func(txn *gdbx.Txn, want string) {
+		t.Helper()
+		if v, err := txn.Get(gdbx.MainDBI, []byte("main")); err != nil || string(v) != want {
+			t.Fatalf("main table holds %q (%v), want %q", v, err, want)
+		}
+		if v, err := txn.Get(dbi, []byte("key")); err != nil || string(v) != want {
+			t.Fatalf("named table holds %q (%v), want %q", v, err, want)
+		}
+	}
+
+	info, err := env.Info(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newest := ids[info.RecentTxnID]
+	var txns []*gdbx.Txn
+	defer func() {
+		for _, txn := range txns {
+			txn.Abort()
+		}
+	}()
+	for _, id := range []uint64{info.Meta0Txnid, info.Meta1Txnid, info.Meta2Txnid} {
+		txn, err := env.BeginTxnAt(id)
+		if err != nil {
+			t.Fatalf("txnid %d: %v", id, err)
+		}
+		txns = append(txns, txn)
+		if txn.ID() != id {
+			t.Fatalf("transaction at txnid %d reports %d", id, txn.ID())
+		}
+		check(txn, ids[id])
+	}
+
+	// The open transactions keep their snapshots while the writer moves on
+	for i := 0; i < 3; i++ {
+		err := env.Update(func(txn *gdbx.Txn) error {
+			newest = fmt.Sprintf("later-%d", i)
+			if err := txn.Put(gdbx.MainDBI, []byte("main"), []byte(newest), 0); err != nil {
+				return err
+			}
+			return txn.Put(dbi, []byte("key"), []byte(newest), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, txn := range txns {
+		check(txn, ids[txn.ID()])
+	}
+	txns[0].Reset()
+	if err := txns[0].Renew(); err != nil {
+		t.Fatal(err)
+	}
+	check(txns[0], newest)
+
+	for _, id := range []uint64{first, 1 << 40, 0} {
+		if txn, err := env.BeginTxnAt(id); !gdbx.IsNotFound(err) {
+			if txn != nil {
+				txn.Abort()
+			}
+			t.Fatalf("txnid %d: got %v, want ErrNotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestTxnCounters checks the per-transaction counters for splits, overflow
+// allocation, page reads and copy-on-write, and that each write transaction
+// starts from zero.
+func TestTxnCounters(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%06d", i)) }
+
+	// Filling a fresh tree splits pages; one large value takes an overflow run
+	txn, err := env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Put(gdbx.MainDBI, key(0), make([]byte, 10000), 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := txn.Counters(); got.OverflowPages != 3 || got.Splits != 0 {
+		t.Fatalf("after one large Put: %+v, want 3 overflow pages and no splits", got)
+	}
+	for i := 1; i < 2000; i++ {
+		if err := txn.Put(gdbx.MainDBI, key(i), make([]byte, 100), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filled := txn.Counters()
+	if filled.Splits == 0 {
+		t.Fatalf("filling 2000 keys caused no splits: %+v", filled)
+	}
+	if filled.PagesCOW != 0 {
+		t.Fatalf("new pages counted as copied on write: %+v", filled)
+	}
+	stat, err := txn.Stat(gdbx.MainDBI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := txn.Counters(); got != (gdbx.TxnCounters{}) {
+		t.Fatalf("Counters after Commit = %+v, want zero", got)
+	}
+
+	// Updating one committed key reads and copies its root-to-leaf path
+	txn, err = env.BeginTxn(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	if err := txn.Put(gdbx.MainDBI, key(1000), bytes.Repeat([]byte{1}, 100), 0); err != nil {
+		t.Fatal(err)
+	}
+	got := txn.Counters()
+	if got.PagesCOW != uint64(stat.Depth) || got.PagesRead < uint64(stat.Depth) {
+		t.Fatalf("update in a tree of depth %d: %+v", stat.Depth, got)
+	}
+	if got.Splits != 0 || got.OverflowPages != 0 {
+		t.Fatalf("counters carried over from the previous transaction: %+v", got)
+	}
+
+	// Read-only transactions don't count
+	rtxn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtxn.Abort()
+	if _, err := rtxn.Get(gdbx.MainDBI, key(5)); err != nil {
+		t.Fatal(err)
+	}
+	if got := rtxn.Counters(); got != (gdbx.TxnCounters{}) {
+		t.Fatalf("read-only Counters = %+v, want zero", got)
+	}
+}
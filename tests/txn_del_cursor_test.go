@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestTxnDelSiblingCursor scans with a cursor while Txn.Del removes keys
+// ahead of it, behind it and under it, on one page and across the tree, and
+// checks the scan sees every remaining key once, in order. A DupSort table
+// does the same with single values.
+func TestTxnDelSiblingCursor(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+	env := openGdbxEnv(t, db.path, 0)
+	defer env.Close()
+
+	const n = 2000
+	key := func(i int) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(i))
+		return b
+	}
+	val := make([]byte, 100)
+
+	// What the scan visits when, at key i, del(i) is deleted: the keys left
+	// in order, minus those deleted ahead of the scan before it got there
+	scan := func(t *testing.T, del func(i int) []int) {
+		t.Helper()
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		dbi, err := txn.OpenDBISimple("scan", gdbx.Create)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			if err := txn.Put(dbi, key(i), val, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		gone := map[int]bool{}
+		var got, want []int
+		for i := 0; i < n; i++ {
+			if !gone[i] {
+				want = append(want, i)
+				for _, d := range del(i) {
+					gone[d] = true
+				}
+			}
+		}
+		for k, _, err := c.Get(nil, nil, gdbx.First); err == nil; k, _, err = c.Get(nil, nil, gdbx.Next) {
+			i := int(binary.BigEndian.Uint64(k))
+			got = append(got, i)
+			for _, d := range del(i) {
+				if d >= 0 && d < n {
+					if err := txn.Del(dbi, key(d), nil); err != nil && !gdbx.IsNotFound(err) {
+						t.Fatalf("Del(%d): %v", d, err)
+					}
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("scan visited %d keys, want %d; first difference at %d", len(got), len(want), firstDiff(got, want))
+		}
+	}
+
+	t.Run("Current", func(t *testing.T) { scan(t, func(i int) []int { return []int{i} }) })
+	t.Run("Next", func(t *testing.T) { scan(t, func(i int) []int { return []int{i + 1} }) })
+	t.Run("Behind", func(t *testing.T) { scan(t, func(i int) []int { return []int{i - 1} }) })
+	t.Run("Ahead", func(t *testing.T) { scan(t, func(i int) []int { return []int{i + 3, i + 500} }) })
+	t.Run("Mixed", func(t *testing.T) {
+		scan(t, func(i int) []int {
+			if i%7 == 0 {
+				return []int{i, i + 2}
+			}
+			return []int{i - 2}
+		})
+	})
+
+	t.Run("DupSort", func(t *testing.T) {
+		txn, err := env.BeginTxn(nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer txn.Abort()
+		dbi, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := func(j int) []byte { return []byte(fmt.Sprintf("value-%04d", j)) }
+		for i := 0; i < 20; i++ {
+			for j := 0; j < 200; j++ {
+				if err := txn.Put(dbi, key(i), value(j), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		c, err := txn.OpenCursor(dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		// Delete each value under the cursor and the one after it, and every
+		// fifth key's remaining values at once
+		seen := 0
+		for k, v, err := c.Get(nil, nil, gdbx.First); err == nil; k, v, err = c.Get(nil, nil, gdbx.Next) {
+			i := int(binary.BigEndian.Uint64(k))
+			var j int
+			fmt.Sscanf(string(v), "value-%d", &j)
+			if want := seen % 100 * 2; i != seen/100 || j != want {
+				t.Fatalf("scan at %d/%d, want %d/%d", i, j, seen/100, want)
+			}
+			seen++
+			if i%5 == 4 && j == 100 {
+				if err := txn.Del(dbi, k, nil); err != nil {
+					t.Fatal(err)
+				}
+				seen += 49
+				continue
+			}
+			if err := txn.Del(dbi, k, v); err != nil {
+				t.Fatal(err)
+			}
+			if err := txn.Del(dbi, key(i), value(j+1)); err != nil && !gdbx.IsNotFound(err) {
+				t.Fatal(err)
+			}
+		}
+		if seen != 2000 {
+			t.Fatalf("scan visited %d values, want 2000", seen)
+		}
+	})
+}
+
+// firstDiff returns the first index at which a and b differ.
+func firstDiff(a, b []int) int {
+	for i := range a {
+		if i >= len(b) || a[i] != b[i] {
+			return i
+		}
+	}
+	return len(a)
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestLeakedReadTxnReleased drops read transactions without aborting them,
+// some with cursors open, one reset and one parked, and checks that garbage
+// collection frees their reader slots, logs a warning for each, leaves a
+// reader table that later transactions can fill to MaxReaders again, and
+// lets Close return.
+func TestLeakedReadTxnReleased(t *testing.T) {
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	const maxReaders = 8
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.SetMaxReaders(maxReaders); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Open(db.path, 0, 0644); err != nil {
+		env.Close()
+		t.Fatal(err)
+	}
+	closed := false
+	defer func() {
+		// Leaked readers that were never released would block Close
+		if !closed && !t.Failed() {
+			env.Close()
+		}
+	}()
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		return txn.Put(gdbx.MainDBI, []byte("k"), []byte("v"), 0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var warnings []string
+	gdbx.SetLogger(func(msg string, args ...any) {
+		mu.Lock()
+		warnings = append(warnings, msg)
+		mu.Unlock()
+	}, gdbx.LogLvlDoNotChange)
+	defer gdbx.SetLogger(nil, gdbx.LogLvlDoNotChange)
+
+	readers := func() int {
+		n := 0
+		if err := env.ReaderList(func(gdbx.ReaderInfo) error { n++; return nil }); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	const leaked = maxReaders - 2
+	leak(t, env, leaked)
+	if n := readers(); n != leaked-2 {
+		t.Fatalf("%d readers before GC, want %d", n, leaked-2)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for readers() != 0 || len(warningsCopy(&mu, &warnings)) < leaked {
+		if time.Now().After(deadline) {
+			t.Fatalf("after GC: %d readers, %d warnings", readers(), len(warningsCopy(&mu, &warnings)))
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	for _, w := range warningsCopy(&mu, &warnings) {
+		if !strings.Contains(w, "without Abort") {
+			t.Fatalf("warning %q", w)
+		}
+	}
+
+	// Every slot can be taken again, and the snapshot is intact
+	var txns []*gdbx.Txn
+	for i := 0; i < maxReaders; i++ {
+		txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+		if err != nil {
+			t.Fatalf("reader %d: %v", i, err)
+		}
+		txns = append(txns, txn)
+		if v, err := txn.Get(gdbx.MainDBI, []byte("k")); err != nil || string(v) != "v" {
+			t.Fatalf("reader %d: %q, %v", i, v, err)
+		}
+	}
+	if n := readers(); n != maxReaders {
+		t.Fatalf("%d readers, want %d", n, maxReaders)
+	}
+	for _, txn := range txns {
+		txn.Abort()
+	}
+	if n := len(warningsCopy(&mu, &warnings)); n != leaked {
+		t.Fatalf("%d warnings after aborting properly, want %d", n, leaked)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		env.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		closed = true
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close blocked by a collected transaction")
+	}
+}
+
+// leak begins n read transactions and drops them: the first with an open
+// cursor, the second reset, the third parked, the rest after a Get.
+func leak(t *testing.T, env *gdbx.Env, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		txn, err := env.BeginTxn(nil, gdbx.TxnReadOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 0:
+			cur, err := txn.OpenCursor(gdbx.MainDBI)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := cur.Get(nil, nil, gdbx.First); err != nil {
+				t.Fatal(err)
+			}
+		case 1:
+			txn.Reset()
+		case 2:
+			if err := txn.Park(false); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			if _, err := txn.Get(gdbx.MainDBI, []byte(fmt.Sprint(i))); !gdbx.IsNotFound(err) {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func warningsCopy(mu *sync.Mutex, w *[]string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string{}, *w...)
+}
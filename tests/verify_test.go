@@ -0,0 +1,252 @@
+package tests
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbxgo "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestVerify checks that Verify passes databases gdbx and MDBX wrote, with
+// plain and DupSort tables, sub-trees, big values and GC records, and that
+// the Validation flag makes Open refuse one with a page that carries the
+// wrong page number or overlapping entries, naming the page, while Open
+// without the flag still opens it.
+func TestVerify(t *testing.T) {
+	key := func(i int) []byte { return binary.BigEndian.AppendUint64(nil, uint64(i)) }
+
+	t.Run("Gdbx", func(t *testing.T) {
+		db := newTestDB(t)
+		defer db.cleanup()
+		env := openGdbxEnv(t, db.path, 0)
+		defer env.Close()
+
+		if err := env.Verify(); err != nil {
+			t.Fatalf("Verify of an empty database: %v", err)
+		}
+		err := env.Update(func(txn *gdbx.Txn) error {
+			plain, err := txn.OpenDBISimple("plain", gdbx.Create)
+			if err != nil {
+				return err
+			}
+			dups, err := txn.OpenDBISimple("dups", gdbx.Create|gdbx.DupSort)
+			if err != nil {
+				return err
+			}
+			fixed, err := txn.OpenDBISimple("fixed", gdbx.Create|gdbx.DupSort|gdbx.DupFixed)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 3000; i++ {
+				val := make([]byte, 64)
+				if i%100 == 0 {
+					val = make([]byte, 10000)
+				}
+				if err := txn.Put(plain, key(i), val, 0); err != nil {
+					return err
+				}
+			}
+			for i := 0; i < 50; i++ {
+				n := 1 + i%4
+				if i%5 == 0 {
+					n = 500
+				}
+				for j := 0; j < n; j++ {
+					if err := txn.Put(dups, key(i), []byte(fmt.Sprintf("value-%04d", j)), 0); err != nil {
+						return err
+					}
+					if err := txn.Put(fixed, key(i), key(j), 0); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Verify(); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+
+		// And after deletes shrink the trees
+		err = env.Update(func(txn *gdbx.Txn) error {
+			plain, err := txn.OpenDBISimple("plain", 0)
+			if err != nil {
+				return err
+			}
+			dups, err := txn.OpenDBISimple("dups", gdbx.DupSort)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 3000; i += 2 {
+				if err := txn.Del(plain, key(i), nil); err != nil {
+					return err
+				}
+			}
+			for i := 0; i < 50; i += 5 {
+				for j := 0; j < 490; j++ {
+					if err := txn.Del(dups, key(i), []byte(fmt.Sprintf("value-%04d", j))); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Verify(); err != nil {
+			t.Fatalf("Verify after deletes: %v", err)
+		}
+	})
+
+	t.Run("MDBX", func(t *testing.T) {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		db := newTestDB(t)
+		defer db.cleanup()
+		menv, err := mdbxgo.NewEnv(mdbxgo.Label("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		menv.SetOption(mdbxgo.OptMaxDB, 10)
+		menv.SetGeometry(-1, -1, 1<<30, -1, -1, 4096)
+		if err := menv.Open(db.path, mdbxgo.Create, 0644); err != nil {
+			menv.Close()
+			t.Fatal(err)
+		}
+		for round := 0; round < 4; round++ {
+			err := menv.Update(func(txn *mdbxgo.Txn) error {
+				plain, err := txn.OpenDBI("plain", mdbxgo.Create, nil, nil)
+				if err != nil {
+					return err
+				}
+				dups, err := txn.OpenDBI("dups", mdbxgo.Create|mdbxgo.DupSort, nil, nil)
+				if err != nil {
+					return err
+				}
+				for i := round; i < 2000; i++ {
+					if round > 0 && i%3 == round%3 {
+						if err := txn.Del(plain, key(i), nil); err != nil && !mdbxgo.IsNotFound(err) {
+							return err
+						}
+						continue
+					}
+					if err := txn.Put(plain, key(i), make([]byte, 100+i%5*2000), 0); err != nil {
+						return err
+					}
+				}
+				for i := 0; i < 30; i++ {
+					for j := 0; j < 1+i%3*150; j++ {
+						if err := txn.Put(dups, key(i), []byte(fmt.Sprintf("r%d-%04d", round, j)), 0); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				menv.Close()
+				t.Fatal(err)
+			}
+		}
+		menv.Close()
+
+		env := openGdbxEnv(t, db.path, gdbx.Validation)
+		defer env.Close()
+		gc := 0
+		if err := env.WalkGC(func(uint64, []uint32) error { gc++; return nil }); err != nil {
+			t.Fatal(err)
+		}
+		if gc == 0 {
+			t.Fatal("MDBX left no GC records to check")
+		}
+	})
+
+	// corrupt builds a database, closes it, and lets damage change the root
+	// page of its table; Open with Validation must then fail naming the page
+	corrupt := func(t *testing.T, damage func(page []byte)) {
+		db := newTestDB(t)
+		defer db.cleanup()
+		env := openGdbxEnv(t, db.path, 0)
+		var root uint32
+		var pageSize int
+		err := env.Update(func(txn *gdbx.Txn) error {
+			dbi, err := txn.OpenDBISimple("t", gdbx.Create)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 1000; i++ {
+				if err := txn.Put(dbi, key(i), make([]byte, 50), 0); err != nil {
+					return err
+				}
+			}
+			info, err := txn.TreeInfo(dbi)
+			if err != nil {
+				return err
+			}
+			stat, err := txn.Stat(dbi)
+			if err != nil {
+				return err
+			}
+			root, pageSize = info.Root, int(stat.PageSize)
+			return nil
+		})
+		env.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.OpenFile(filepath.Join(db.path, gdbx.DataFileName), os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := make([]byte, pageSize)
+		if _, err := f.ReadAt(page, int64(root)*int64(pageSize)); err != nil {
+			t.Fatal(err)
+		}
+		damage(page)
+		if _, err := f.WriteAt(page, int64(root)*int64(pageSize)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		env, err = gdbx.NewEnv(gdbx.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.SetMaxDBs(10)
+		err = env.Open(db.path, gdbx.Validation, 0644)
+		env.Close()
+		if gdbx.Code(err) != gdbx.ErrCorrupted || !strings.Contains(err.Error(), fmt.Sprintf("page %d:", root)) {
+			t.Fatalf("Open with Validation = %v, want ErrCorrupted at page %d", err, root)
+		}
+
+		env = openGdbxEnv(t, db.path, 0)
+		if err := env.Verify(); gdbx.Code(err) != gdbx.ErrCorrupted {
+			t.Fatalf("Verify = %v, want ErrCorrupted", err)
+		}
+		env.Close()
+	}
+
+	t.Run("PageNumber", func(t *testing.T) {
+		corrupt(t, func(page []byte) {
+			binary.LittleEndian.PutUint32(page[16:], binary.LittleEndian.Uint32(page[16:])+1)
+		})
+	})
+	t.Run("Entries", func(t *testing.T) {
+		// The first entry's offset points into the entry array
+		corrupt(t, func(page []byte) {
+			binary.LittleEndian.PutUint16(page[gdbx.PageHeaderSize:], 0)
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+
+	mdbxgo "github.com/erigontech/mdbx-go/mdbx"
+)
+
+// TestWalkGC has MDBX free pages, reads its GC records back through MDBX,
+// and checks that WalkGC reports the same transactions and pages; that an
+// error from the callback stops the walk; and that a database only gdbx has
+// written has no records.
+func TestWalkGC(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	db := newTestDB(t)
+	defer db.cleanup()
+
+	menv, err := mdbxgo.NewEnv(mdbxgo.Label("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	menv.SetOption(mdbxgo.OptMaxDB, 10)
+	menv.SetGeometry(-1, -1, 1<<30, -1, -1, 4096)
+	if err := menv.Open(db.path, mdbxgo.Create, 0644); err != nil {
+		menv.Close()
+		t.Fatal(err)
+	}
+
+	key := make([]byte, 8)
+	val := make([]byte, 500)
+	update := func(fn func(txn *mdbxgo.Txn, dbi mdbxgo.DBI) error) {
+		t.Helper()
+		if err := menv.Update(func(txn *mdbxgo.Txn) error {
+			dbi, err := txn.OpenDBI("data", mdbxgo.Create, nil, nil)
+			if err != nil {
+				return err
+			}
+			return fn(txn, dbi)
+		}); err != nil {
+			menv.Close()
+			t.Fatal(err)
+		}
+	}
+	update(func(txn *mdbxgo.Txn, dbi mdbxgo.DBI) error {
+		for i := 0; i < 2000; i++ {
+			binary.BigEndian.PutUint64(key, uint64(i))
+			if err := txn.Put(dbi, key, val, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for round := 0; round < 3; round++ {
+		update(func(txn *mdbxgo.Txn, dbi mdbxgo.DBI) error {
+			for i := round; i < 2000; i += 3 {
+				binary.BigEndian.PutUint64(key, uint64(i))
+				if err := txn.Del(dbi, key, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	// The records as MDBX sees them
+	want := map[uint64][]uint32{}
+	if err := menv.View(func(txn *mdbxgo.Txn) error {
+		c, err := txn.OpenCursor(0)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		for k, v, err := c.Get(nil, nil, mdbxgo.First); ; k, v, err = c.Get(nil, nil, mdbxgo.Next) {
+			if mdbxgo.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			n := binary.LittleEndian.Uint32(v)
+			pages := make([]uint32, n)
+			for i := range pages {
+				pages[i] = binary.LittleEndian.Uint32(v[4+4*i:])
+			}
+			slices.Sort(pages)
+			want[binary.LittleEndian.Uint64(k)] = pages
+		}
+	}); err != nil {
+		menv.Close()
+		t.Fatal(err)
+	}
+	menv.Close()
+	if len(want) == 0 {
+		t.Fatal("MDBX left no GC records")
+	}
+
+	env := openGdbxEnv(t, db.path, gdbx.ReadOnly)
+	defer env.Close()
+	got := map[uint64][]uint32{}
+	var last uint64
+	if err := env.WalkGC(func(txnid uint64, pages []uint32) error {
+		if txnid <= last {
+			t.Errorf("txnid %d after %d", txnid, last)
+		}
+		last = txnid
+		got[txnid] = pages
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("WalkGC = %v, want %v", got, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	if err := env.WalkGC(func(uint64, []uint32) error { calls++; return stop }); err != stop || calls != 1 {
+		t.Fatalf("stopping walk: %v after %d calls", err, calls)
+	}
+
+	// gdbx keeps no records of its own
+	fresh := newTestDB(t)
+	defer fresh.cleanup()
+	genv := openGdbxEnv(t, fresh.path, 0)
+	defer genv.Close()
+	for i := 0; i < 3; i++ {
+		if err := genv.Update(func(txn *gdbx.Txn) error {
+			return txn.Put(gdbx.MainDBI, []byte("k"), val[:i+1], 0)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := genv.WalkGC(func(txnid uint64, pages []uint32) error {
+		return errors.New("unexpected GC record")
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
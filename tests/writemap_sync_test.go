@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/Giulio2002/gdbx"
+)
+
+// TestWriteMapSyncRanges makes small random updates to a WriteMap database of
+// 64KB pages. Each durable commit must be synced by range, not by an fsync of
+// the file, and sync fewer bytes than the pages it copied; a commit after one
+// that didn't sync must fsync instead. Reopened without WriteMap or a page
+// size, the database must hold every update, including a big value
+// rewritten in place.
+func TestWriteMapSyncRanges(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WriteMap commits sync by range only on Linux")
+	}
+	const pageSize = 64 << 10
+	dir := t.TempDir()
+
+	env, err := gdbx.NewEnv(gdbx.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.SetGeometry(-1, -1, 1<<30, -1, -1, pageSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Open(dir, gdbx.WriteMap, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { env.Close() }()
+
+	rng := rand.New(rand.NewSource(1214))
+	key := func(i int) []byte { return binary.BigEndian.AppendUint64(nil, uint64(i)) }
+	model := make(map[string][]byte)
+	const numKeys = 20000
+	big := bytes.Repeat([]byte("b"), 3*pageSize)
+
+	err = env.Update(func(txn *gdbx.Txn) error {
+		for i := 0; i < numKeys; i++ {
+			v := []byte(fmt.Sprintf("value-%026d", i))
+			model[string(key(i))] = v
+			if err := txn.Put(gdbx.MainDBI, key(i), v, 0); err != nil {
+				return err
+			}
+		}
+		model["big"] = big
+		return txn.Put(gdbx.MainDBI, []byte("big"), big, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := func() *gdbx.EnvInfo {
+		t.Helper()
+		info, err := env.Info(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	for round := 0; round < 20; round++ {
+		before := info()
+		var copied uint64
+		err := env.Update(func(txn *gdbx.Txn) error {
+			for j := 0; j < 4; j++ {
+				k := key(rng.Intn(numKeys + 100))
+				v := []byte(fmt.Sprintf("round-%02d-%020d", round, j))
+				model[string(k)] = v
+				if err := txn.Put(gdbx.MainDBI, k, v, 0); err != nil {
+					return err
+				}
+			}
+			if round%5 == 0 {
+				big = bytes.Repeat([]byte{byte('c' + round)}, len(big))
+				model["big"] = big
+				if err := txn.Put(gdbx.MainDBI, []byte("big"), big, 0); err != nil {
+					return err
+				}
+			}
+			copied = txn.Counters().PagesCOW * pageSize
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		after := info()
+		if after.PageOps.Fsync != before.PageOps.Fsync {
+			t.Fatalf("round %d: commit fsynced the data file", round)
+		}
+		synced := after.MsyncBytes - before.MsyncBytes
+		if after.PageOps.Msync == before.PageOps.Msync || synced == 0 {
+			t.Fatalf("round %d: commit synced no range", round)
+		}
+		if round%5 != 0 && synced >= copied {
+			t.Fatalf("round %d: synced %d bytes for %d bytes of pages copied", round, synced, copied)
+		}
+	}
+
+	// After a commit that didn't sync, the next durable one covers it whole
+	err = env.RunTxn(gdbx.TxnNoSync, func(txn *gdbx.Txn) error {
+		model["nosync"] = []byte("x")
+		return txn.Put(gdbx.MainDBI, []byte("nosync"), []byte("x"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := info()
+	if err := env.Update(func(txn *gdbx.Txn) error {
+		model["after-nosync"] = []byte("x")
+		return txn.Put(gdbx.MainDBI, []byte("after-nosync"), []byte("x"), 0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if after := info(); after.PageOps.Fsync == before.PageOps.Fsync {
+		t.Fatal("commit after an unsynced one didn't fsync the data file")
+	}
+
+	env.Close()
+	env = openGdbxEnv(t, dir, 0)
+	if err := env.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *gdbx.Txn) error {
+		for k, want := range model {
+			got, err := txn.Get(gdbx.MainDBI, []byte(k))
+			if err != nil {
+				return fmt.Errorf("get %x: %w", k, err)
+			}
+			if !bytes.Equal(got, want) {
+				return fmt.Errorf("get %x = %.20q, want %.20q", k, got, want)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
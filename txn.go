@@ -2,7 +2,11 @@ package gdbx
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 	"unsafe"
@@ -25,6 +29,7 @@ func newCursorFromCache() *Cursor {
 	n := len(globalCursorCache)
 	if n > 0 {
 		c := globalCursorCache[n-1]
+		globalCursorCache[n-1] = nil // Don't keep a leaked Txn reachable through its cursor
 		globalCursorCache = globalCursorCache[:n-1]
 		globalCursorCacheMu.Unlock()
 		return c
@@ -109,6 +114,7 @@ func getReadTxnFromCache() *Txn {
 	n := len(globalReadTxnCache)
 	if n > 0 {
 		txn := globalReadTxnCache[n-1]
+		globalReadTxnCache[n-1] = nil // Don't keep a leaked Txn reachable
 		globalReadTxnCache = globalReadTxnCache[:n-1]
 		globalReadTxnCacheMu.Unlock()
 		return txn
@@ -194,12 +200,23 @@ type Txn struct {
 	// Read transaction state
 	readerSlot *readerSlot
 	slotIdx    int
+	guard      *readGuard // Releases the slot if the Txn is dropped without Abort
 
 	// Write transaction state
 	dirtyTracker    dirtyPageTracker
-	freePages       []pgno
-	allocatedPg     pgno // Next page to allocate
-	hasNonMmapPages bool // True if any pages were allocated outside mmap (WriteMap mode)
+	freePages       []pgno // Pages this transaction allocated and freed again, for reuse
+	firstNewPg      pgno   // allocatedPg when the transaction began; later pages are its own
+	allocatedPg     pgno   // Next page to allocate
+	hasNonMmapPages bool   // True if any pages were allocated outside mmap (WriteMap mode)
+	inPlacePages    []pgno // Overflow pages rewritten through the map, for the change history
+	allocSequential bool   // Don't reuse freed pages (AllocSequential)
+	arenaNext       pgno   // Next unused page of the overflow arena
+	arenaEnd        pgno   // End of the overflow arena
+	arenaChunk      int    // Pages to reserve for the next overflow arena
+	pageWrites      uint64 // Pages touched for writing, to tell cursors' sub-page views are stale
+
+	// Map bytes written past dirtyTracker, for syncWriteMapPages to sync
+	mapSpans []mapSpan
 
 	// Cursor tracking
 	cursors []*Cursor
@@ -210,6 +227,24 @@ type Txn struct {
 	// DBI state
 	dbiDirty []bool
 
+	// Per-DBI max appended key for the IntegerKey append fast path
+	appendHints []appendHint
+
+	// Per-DBI last key put under SetAppendGuard
+	appendGuards []appendGuard
+
+	// Called on every page split (see OnSplit)
+	onSplit SplitFunc
+
+	// True if operations are recorded in the environment's op log
+	logOps bool
+
+	// Set when a write failed after modifying pages; only Abort is allowed then
+	broken bool
+
+	// Page work done by this write transaction (see Counters)
+	counters TxnCounters
+
 	// Cached per-DBI state for hot path (avoids mutex lookups)
 	dbiComparators       []func(a, b []byte) int // Cached key comparators per DBI
 	dbiDupComparators    []func(a, b []byte) int // Cached dup value comparators per DBI
@@ -281,7 +316,7 @@ func (txn *Txn) persistNamedDBTrees() error {
 	}
 
 	// Open cursor on MainDBI to update named DB entries
-	cursor, err := txn.OpenCursor(MainDBI)
+	cursor, err := txn.openCursor(MainDBI)
 	if err != nil {
 		return err
 	}
@@ -316,6 +351,54 @@ func (txn *Txn) persistNamedDBTrees() error {
 	return nil
 }
 
+// errTreeNotPersisted is wrapped in the error commit returns when a named
+// database changed in the transaction but MainDBI holds a different header.
+var errTreeNotPersisted = errors.New("modified database tree header not persisted")
+
+// verifyNamedDBTrees checks, after persistNamedDBTrees, that every named
+// database modified by this transaction has its current tree header stored
+// in MainDBI. A mismatch means a change would be lost on commit, such as a
+// new root the dirty tracking missed, and fails with ErrProblem.
+func (txn *Txn) verifyNamedDBTrees() error {
+	var cursor *Cursor
+	defer func() {
+		if cursor != nil {
+			cursor.Close()
+		}
+	}()
+
+	txn.env.dbisMu.RLock()
+	defer txn.env.dbisMu.RUnlock()
+
+	var want [treeSize]byte
+	for i := CoreDBs; i < len(txn.trees) && i < len(txn.env.dbis); i++ {
+		tree := &txn.trees[i]
+		info := txn.env.dbis[i]
+		if tree.ModTxnid != txnid(txn.txnID) || info == nil || info.name == "" {
+			continue
+		}
+		if cursor == nil {
+			var err error
+			if cursor, err = txn.openCursor(MainDBI); err != nil {
+				return err
+			}
+		}
+		// A handle created by an aborted transaction has no header to check
+		_, got, err := cursor.Get([]byte(info.name), nil, Set)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		serializeTreeToBuf(tree, want[:])
+		if !bytes.Equal(got, want[:]) {
+			return WrapError(ErrProblem, fmt.Errorf("%w: %q", errTreeNotPersisted, info.name))
+		}
+	}
+	return nil
+}
+
 // updateCachedDBITrees updates the cached trees in env.dbis after commit completes.
 // This must be called AFTER updateMeta() to ensure read transactions don't see
 // new tree roots before the mmap has been extended to include those pages.
@@ -359,6 +442,48 @@ type CommitLatency struct {
 // Commit commits the transaction and returns latency information.
 // Returns (CommitLatency, error) for mdbx-go API compatibility.
 func (txn *Txn) Commit() (CommitLatency, error) {
+	return txn.commitLogged(nil)
+}
+
+// RootChange is the root page of a database before and after a commit,
+// 0xFFFFFFFF for an empty tree.
+type RootChange struct {
+	OldRoot uint32
+	NewRoot uint32
+}
+
+// CommitEx commits the transaction like Commit and reports, for each
+// database the transaction modified, its root page before and after. The
+// pages of a tree that changed are those reachable from its new root that
+// are not reachable from its old one, so a follower holding the old trees
+// can fetch just those. The main database changes along with any named one,
+// its records holding their roots, and the free database with most commits.
+func (txn *Txn) CommitEx() (map[DBI]RootChange, error) {
+	roots := make(map[DBI]RootChange)
+	if _, err := txn.commitLogged(roots); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// commitLogged commits, recording the commit in the op log if the
+// transaction is logged.
+func (txn *Txn) commitLogged(roots map[DBI]RootChange) (CommitLatency, error) {
+	if !txn.valid() || !txn.logOps {
+		return txn.commit(roots)
+	}
+
+	// Aborts on the failure paths are part of the commit, not separate records
+	log := txn.env.opLog
+	txn.logOps = false
+	latency, err := txn.commit(roots)
+	log.start(opCommit).end(err)
+	return latency, err
+}
+
+// commit performs the actual commit. If roots is not nil, it receives the
+// root changes of the modified databases.
+func (txn *Txn) commit(roots map[DBI]RootChange) (CommitLatency, error) {
 	var latency CommitLatency
 	if !txn.valid() {
 		return latency, NewError(ErrBadTxn)
@@ -369,11 +494,23 @@ func (txn *Txn) Commit() (CommitLatency, error) {
 		return latency, nil
 	}
 
+	if txn.broken {
+		txn.Abort()
+		return latency, NewError(ErrBadTxn)
+	}
+
+	// Unused overflow arena pages go back before the last tree updates
+	txn.releaseOverflowArena()
+
 	// Persist named database trees back to MainDBI (before acquiring lock)
 	if err := txn.persistNamedDBTrees(); err != nil {
 		txn.Abort()
 		return latency, err
 	}
+	if err := txn.verifyNamedDBTrees(); err != nil {
+		txn.Abort()
+		return latency, err
+	}
 
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
@@ -381,17 +518,38 @@ func (txn *Txn) Commit() (CommitLatency, error) {
 	// Close all cursors
 	txn.closeAllCursors()
 
+	var oldRoots []pgno
+	if roots != nil {
+		oldRoots = txn.committedRoots()
+	}
+
 	// Write dirty pages
 	if err := txn.writeDirtyPages(); err != nil {
 		txn.abortInternal()
 		return latency, err
 	}
 
-	// Update meta page
-	if err := txn.updateMeta(); err != nil {
+	// Update meta page. With group commit the sync is shared with the
+	// commits that follow, and waited for once the writer lock is released.
+	window := time.Duration(txn.env.groupWindow.Load())
+	deferSync := window > 0 && txn.willSync() && txn.env.redo == nil
+	if err := txn.updateMeta(deferSync); err != nil {
 		txn.abortInternal()
 		return latency, err
 	}
+	// A failed checkpoint leaves the log to the next commit or Close
+	if r := txn.env.redo; r != nil && r.size >= redoCheckpointBytes {
+		txn.env.checkpointRedo()
+	}
+	var groupSeq uint64
+	if deferSync {
+		groupSeq = txn.env.noteGroupCommit()
+	}
+	txn.env.noteCommit(int64(txn.dirtyTracker.len()+1)*int64(txn.env.pageSize), txn.willSync())
+	txn.env.recordChanges(txn)
+	if roots != nil {
+		txn.rootChanges(oldRoots, roots)
+	}
 
 	// Update cached DBI trees AFTER meta is committed and mmap is extended.
 	// This ensures read transactions don't see new tree roots before the
@@ -407,6 +565,13 @@ func (txn *Txn) Commit() (CommitLatency, error) {
 	txn.env.txnCond.Broadcast()
 	txn.env.txnMu.Unlock()
 
+	var syncErr error
+	if deferSync {
+		start := time.Now()
+		syncErr = txn.env.waitGroupSync(groupSeq, window)
+		latency.Sync = time.Since(start)
+	}
+
 	// Return page data to env cache (avoids sync.Pool overhead)
 	txn.env.returnPageDataToCache(txn.pooledPageData)
 	txn.pooledPageData = txn.pooledPageData[:0]
@@ -437,7 +602,45 @@ func (txn *Txn) Commit() (CommitLatency, error) {
 	txn.parent = nil
 	txn.mmapData = nil // Clear cached mmap - may have changed size
 	returnWriteTxnToCache(txn)
-	return latency, nil
+	return latency, syncErr
+}
+
+// committedRoots returns the roots of the transaction's databases as last
+// committed, indexed by DBI, invalidPgno for a database not open.
+func (txn *Txn) committedRoots() []pgno {
+	old := make([]pgno, len(txn.trees))
+	for i := range old {
+		old[i] = invalidPgno
+	}
+	m := txn.env.meta.Load().recentMeta()
+	old[FreeDBI] = m.GCTree.Root
+	old[MainDBI] = m.MainTree.Root
+
+	txn.env.dbisMu.RLock()
+	defer txn.env.dbisMu.RUnlock()
+	for i := CoreDBs; i < len(old) && i < len(txn.env.dbis); i++ {
+		if info := txn.env.dbis[i]; info != nil && info.tree != nil {
+			old[i] = info.tree.Root
+		}
+	}
+	return old
+}
+
+// rootChanges adds to roots the databases whose tree this transaction
+// modified, with their roots in old and now.
+func (txn *Txn) rootChanges(old []pgno, roots map[DBI]RootChange) {
+	txn.env.dbisMu.RLock()
+	defer txn.env.dbisMu.RUnlock()
+	for i := range old {
+		if i >= CoreDBs && (i >= len(txn.env.dbis) || txn.env.dbis[i] == nil || txn.env.dbis[i].name == "") {
+			continue
+		}
+		t := &txn.trees[i]
+		if t.ModTxnid != txnid(txn.txnID) && t.Root == old[i] {
+			continue
+		}
+		roots[DBI(i)] = RootChange{OldRoot: uint32(old[i]), NewRoot: uint32(t.Root)}
+	}
 }
 
 // Abort aborts the transaction.
@@ -446,6 +649,11 @@ func (txn *Txn) Abort() {
 		return
 	}
 
+	if txn.logOps {
+		txn.logOps = false
+		txn.env.opLog.start(opAbort).end(nil)
+	}
+
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
@@ -513,6 +721,7 @@ func (txn *Txn) abortInternal() error {
 		txn.env.txnWg.Done()
 		// Clear references before returning to cache
 		txn.env = nil
+		txn.syncGuard()
 		txn.userCtx = nil
 		txn.mmapData = nil // Clear cached mmap - may have changed size
 		returnReadTxnToCache(txn)
@@ -544,15 +753,27 @@ func (txn *Txn) Reset() {
 	if txn.readerSlot != nil {
 		txn.env.lockFile.releaseReaderSlot(txn.readerSlot, txn.slotIdx)
 		txn.readerSlot = nil
+		txn.syncGuard()
 	}
 }
 
-// Renew renews a reset read-only transaction.
+// Renew renews a reset read-only transaction. It moves the transaction to
+// the newest commit, including, in a ReadOnly Env, those made by other
+// processes since it began.
 func (txn *Txn) Renew() error {
 	if !txn.valid() || !txn.IsReadOnly() {
 		return NewError(ErrBadTxn)
 	}
+	if err := txn.renew(); err != nil {
+		return err
+	}
+	if txn.env.flags&ReadOnly != 0 {
+		return txn.followNamedTrees()
+	}
+	return nil
+}
 
+func (txn *Txn) renew() error {
 	txn.mu.Lock()
 	defer txn.mu.Unlock()
 
@@ -560,6 +781,12 @@ func (txn *Txn) Renew() error {
 		return NewError(ErrBadTxn) // Not reset
 	}
 
+	if txn.env.flags&ReadOnly != 0 {
+		if err := txn.env.followWriter(); err != nil {
+			return err
+		}
+	}
+
 	// Re-acquire reader slot
 	pid := uint32(0) // TODO: os.Getpid()
 	tid := uint64(0) // TODO: goroutine ID
@@ -584,6 +811,7 @@ func (txn *Txn) Renew() error {
 	txn.readerSlot = slot
 	txn.slotIdx = slotIdx
 	txn.txnID = meta.txnID()
+	txn.syncGuard()
 
 	// Set reader's txnid
 	txn.env.lockFile.setReaderTxnid(slot, uint64(meta.txnID()))
@@ -621,10 +849,24 @@ func (txn *Txn) OpenDBI(name string, flags uint, cmp, dcmp CmpFunc) (DBI, error)
 
 	// Empty name means the main database
 	if name == "" {
+		if flags&Purge != 0 {
+			return 0, NewError(ErrInvalid) // Can't purge the main DB
+		}
 		return MainDBI, nil
 	}
 
-	return txn.openNamedDBI(name, flags, cmp, dcmp)
+	dbi, err := txn.openNamedDBI(name, flags, cmp, dcmp)
+	if err == nil && flags&Purge != 0 {
+		if txn.IsReadOnly() {
+			err = NewError(ErrPermissionDenied)
+		} else {
+			txn.emptyTree(dbi)
+		}
+	}
+	if txn.logOps {
+		txn.env.opLog.start(opOpenDBI).bytes([]byte(name)).uint(uint64(flags)).uint(uint64(dbi)).end(err)
+	}
+	return dbi, err
 }
 
 // openNamedDBI opens a named database.
@@ -655,7 +897,7 @@ func (txn *Txn) openNamedDBI(name string, flags uint, cmp, dcmp CmpFunc) (DBI, e
 
 	// Search main database for the named db's Tree metadata
 	// Note: This must happen without holding txn.mu to avoid deadlock
-	cursor, err := txn.OpenCursor(MainDBI)
+	cursor, err := txn.openCursor(MainDBI)
 	if err != nil {
 		return 0, err
 	}
@@ -693,11 +935,15 @@ func (txn *Txn) openNamedDBI(name string, flags uint, cmp, dcmp CmpFunc) (DBI, e
 	// Check again in case another goroutine added it
 	for i, info := range txn.env.dbis {
 		if info != nil && info.name == name {
+			// Use the tree of our snapshot, not the one cached in env
+			if i < len(txn.trees) {
+				txn.trees[i] = *tree
+			}
 			return DBI(i), nil
 		}
 	}
 
-	for i := CoreDBs; i < int(txn.env.maxDBs); i++ {
+	for i := CoreDBs; i < txn.env.dbiSlots(); i++ {
 		if txn.env.dbis[i] == nil {
 			txn.env.dbis[i] = &dbiInfo{
 				name:  name,
@@ -706,6 +952,10 @@ func (txn *Txn) openNamedDBI(name string, flags uint, cmp, dcmp CmpFunc) (DBI, e
 				cmp:   cmp,
 				dcmp:  dcmp,
 			}
+			// The cached trees no longer come from a single snapshot
+			if txn.env.dbisTxnID != txnid(txn.txnID) {
+				txn.env.dbisTxnID = 0
+			}
 			// Also copy tree to txn.trees for cursor access
 			if i < len(txn.trees) {
 				txn.trees[i] = *tree
@@ -733,6 +983,11 @@ func (txn *Txn) createNamedDBI(name string, flags uint, cmp, dcmp CmpFunc, curso
 		ModTxnid:    txnid(txn.txnID),
 	}
 
+	// A full handle table refuses the database before its record is stored
+	if !txn.env.hasFreeDBISlot() {
+		return 0, NewError(ErrDBsFull)
+	}
+
 	// Serialize tree to 48 bytes
 	treeData := serializeTreeToBytes(tree)
 
@@ -747,7 +1002,7 @@ func (txn *Txn) createNamedDBI(name string, flags uint, cmp, dcmp CmpFunc, curso
 	defer txn.env.dbisMu.Unlock()
 
 	// Find an empty slot
-	for i := CoreDBs; i < int(txn.env.maxDBs); i++ {
+	for i := CoreDBs; i < txn.env.dbiSlots(); i++ {
 		if txn.env.dbis[i] == nil {
 			txn.env.dbis[i] = &dbiInfo{
 				name:  name,
@@ -815,6 +1070,8 @@ func parseTreeFromBytes(data []byte) *tree {
 }
 
 // GetTree returns the tree info for a DBI (for debugging).
+//
+// Deprecated: GetTree exposes the transaction's live tree state. Use TreeInfo.
 func (txn *Txn) GetTree(dbi DBI) *tree {
 	if int(dbi) < len(txn.trees) {
 		return &txn.trees[dbi]
@@ -860,9 +1117,127 @@ func (txn *Txn) Get(dbi DBI, key []byte) ([]byte, error) {
 	}
 
 	// Fast path: direct tree search without cursor allocation
+	if txn.env.safeValues.Load() {
+		v, err := txn.directGet(tree, dbi, key)
+		return bytes.Clone(v), err
+	}
 	return txn.directGet(tree, dbi, key)
 }
 
+// GetWithMeta looks up key like Get, but reports whether it is stored
+// through present instead of an error: an absent key gives nil, false and a
+// nil error, and a key stored with an empty value gives a non-nil empty
+// value and true. err is left for real failures, such as a bad handle.
+func (txn *Txn) GetWithMeta(dbi DBI, key []byte) (value []byte, present bool, err error) {
+	value, err = txn.Get(dbi, key)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, true, nil
+}
+
+// GetSize returns the size of key's value without reading the value: for a
+// value on overflow pages only the leaf node recording its size is read, so
+// learning the size of a value of many megabytes touches none of its pages.
+// For DUPSORT tables it is the size of the first value, like Get. An absent
+// key gives ErrNotFound.
+func (txn *Txn) GetSize(dbi DBI, key []byte) (int, error) {
+	if !txn.valid() {
+		return 0, NewError(ErrBadTxn)
+	}
+	if dbi == FreeDBI || int(dbi) >= len(txn.trees) {
+		return 0, NewError(ErrBadDBI)
+	}
+	if txn.trees[dbi].isEmpty() {
+		return 0, ErrNotFoundError
+	}
+
+	c, err := txn.getCachedCursor(dbi)
+	if err != nil {
+		return 0, err
+	}
+	c.reset()
+	exact, err := c.searchForInsert(key)
+	if err != nil && !IsNotFound(err) {
+		return 0, err
+	}
+	if !exact {
+		return 0, ErrNotFoundError
+	}
+
+	p := c.pages[c.top]
+	idx := int(c.indices[c.top])
+	if nodeGetFlagsDirect(p, idx)&(nodeDup|nodeTree) != 0 {
+		// The node holds the key's values; the first is in there
+		_, v, err := c.getCurrent()
+		return len(v), err
+	}
+	return int(nodeGetDataSizeDirect(p, idx)), nil
+}
+
+// MultiGet retrieves several keys in one pass, returning a value and an error
+// per key in the order of keys. Keys are resolved in ascending order by a single
+// cursor, so keys landing on the same leaf page skip the descent from the root.
+// Unsorted input is sorted internally. Like Get, a DUPSORT key yields its first value.
+func (txn *Txn) MultiGet(dbi DBI, keys [][]byte) ([][]byte, []error) {
+	vals := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+
+	fail := func(err error) ([][]byte, []error) {
+		for i := range errs {
+			errs[i] = err
+		}
+		return vals, errs
+	}
+	if !txn.valid() {
+		return fail(NewError(ErrBadTxn))
+	}
+	if int(dbi) >= len(txn.trees) || dbi == FreeDBI {
+		return fail(NewError(ErrBadDBI))
+	}
+	if len(keys) == 0 {
+		return vals, errs
+	}
+
+	cursor, err := txn.getCachedCursor(dbi)
+	if err != nil {
+		return fail(err)
+	}
+	cursor.reset()
+	txn.cacheComparator(dbi)
+
+	// Visit keys in ascending order, remembering where each result belongs
+	order := make([]int, len(keys))
+	sorted := true
+	for i := range order {
+		order[i] = i
+		if i > 0 && txn.compareKeys(dbi, keys[i-1], keys[i]) > 0 {
+			sorted = false
+		}
+	}
+	if !sorted {
+		sort.SliceStable(order, func(a, b int) bool {
+			return txn.compareKeys(dbi, keys[order[a]], keys[order[b]]) < 0
+		})
+	}
+
+	for _, i := range order {
+		vals[i], errs[i] = cursor.seekForward(keys[i])
+	}
+	if txn.env.safeValues.Load() {
+		for i := range vals {
+			vals[i] = bytes.Clone(vals[i])
+		}
+	}
+	return vals, errs
+}
+
 // directGet performs a direct tree search without cursor overhead.
 // Uses allocation-free methods for maximum performance.
 func (txn *Txn) directGet(tree *tree, dbi DBI, key []byte) ([]byte, error) {
@@ -1201,6 +1576,9 @@ func (txn *Txn) getFirstSubTreeValueReadOnly(treeData []byte) ([]byte, error) {
 	// Now at leaf page - get first entry
 	offset := uint64(currentPgno) * pageSz
 	pageData := mmapData[offset : offset+pageSz]
+	if pageFlagsDirect(pageData)&pageDupfix != 0 {
+		return pageDupfixKeyDirect(pageData, 0), nil
+	}
 
 	storedOffset := int(uint16(pageData[pageHeaderSize]) | uint16(pageData[pageHeaderSize+1])<<8)
 	nodeOffset := storedOffset + pageHeaderSize
@@ -1288,7 +1666,7 @@ func (txn *Txn) getCachedCursor(dbi DBI) (*Cursor, error) {
 	}
 
 	// Create new cursor
-	cursor, err := txn.OpenCursor(dbi)
+	cursor, err := txn.openCursor(dbi)
 	if err != nil {
 		return nil, err
 	}
@@ -1311,7 +1689,92 @@ func (txn *Txn) Put(dbi DBI, key, value []byte, flags uint) error {
 		return err
 	}
 
-	return cursor.Put(key, value, flags)
+	err = cursor.Put(key, value, flags)
+	if txn.logOps {
+		txn.env.opLog.start(opPut).uint(uint64(dbi)).uint(uint64(flags)).bytes(key).bytes(value).end(err)
+	}
+	return err
+}
+
+// PutNoOverwrite stores a key-value pair unless the key is already present,
+// in which case it returns a copy of the stored value with ErrKeyExist. The
+// lookup and the insert share one descent, so it serves as get-or-insert.
+// For DupSort databases, as with the NoOverwrite flag, only an identical
+// key-value pair conflicts, and the returned value is that duplicate.
+func (txn *Txn) PutNoOverwrite(dbi DBI, key, value []byte) ([]byte, error) {
+	if !txn.valid() {
+		return nil, NewError(ErrBadTxn)
+	}
+
+	if txn.IsReadOnly() {
+		return nil, NewError(ErrPermissionDenied)
+	}
+
+	cursor, err := txn.getCachedCursor(dbi)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []byte
+	err = cursor.Put(key, value, NoOverwrite)
+	if IsKeyExist(err) {
+		if cursor.tree.Flags&uint16(DupSort) != 0 {
+			existing = bytes.Clone(value)
+		} else if _, v, gerr := cursor.Get(nil, nil, GetCurrent); gerr != nil {
+			err = gerr
+		} else {
+			existing = bytes.Clone(v)
+		}
+	}
+	if txn.logOps {
+		txn.env.opLog.start(opPut).uint(uint64(dbi)).uint(uint64(NoOverwrite)).bytes(key).bytes(value).end(err)
+	}
+	return existing, err
+}
+
+// PutWithCap stores a key-value pair like Put, reserving room on overflow pages
+// for the value to grow to capacity bytes. Later updates of the key that fit
+// the reservation are written in place instead of relocating the value.
+// Growing updates that fit the reservation stay on its pages, even while the
+// value would fit inline, so appending to the value allocates nothing until
+// it outgrows the reservation. Shrinking updates keep the reservation unless
+// the value becomes small enough to be stored inline. A capacity above the
+// inline value limit stores even a small value on overflow pages. DupSort
+// databases don't support reservations.
+func (txn *Txn) PutWithCap(dbi DBI, key, value []byte, capacity int, flags uint) error {
+	err := txn.putWithCap(dbi, key, value, capacity, flags)
+	if txn.logOps {
+		txn.env.opLog.start(opPutWithCap).uint(uint64(dbi)).uint(uint64(flags)).uint(uint64(capacity)).bytes(key).bytes(value).end(err)
+	}
+	return err
+}
+
+func (txn *Txn) putWithCap(dbi DBI, key, value []byte, capacity int, flags uint) error {
+	if !txn.valid() {
+		return NewError(ErrBadTxn)
+	}
+
+	if txn.IsReadOnly() {
+		return NewError(ErrPermissionDenied)
+	}
+
+	if err := checkDataSize(capacity); err != nil {
+		return err
+	}
+
+	cursor, err := txn.getCachedCursor(dbi)
+	if err != nil {
+		return err
+	}
+
+	if capacity > len(value) && cursor.tree.Flags&uint16(DupSort) != 0 {
+		return NewError(ErrIncompatible)
+	}
+
+	cursor.overflowCap = capacity
+	err = cursor.Put(key, value, flags)
+	cursor.overflowCap = 0
+	return err
 }
 
 // Del deletes a key (and optionally a specific value for DUPSORT).
@@ -1331,24 +1794,110 @@ func (txn *Txn) Del(dbi DBI, key, value []byte) error {
 
 	var op CursorOp = Set
 	var delFlags uint = 0
+	isDupSort := cursor.tree.Flags&uint16(DupSort) != 0
 
-	if value != nil {
+	if value != nil && isDupSort {
 		op = GetBoth
-	} else {
+	} else if value == nil {
 		// When value is nil, delete all values for the key (NoDupData)
 		delFlags = NoDupData
 	}
 
-	_, _, err = cursor.Get(key, value, op)
+	_, v, err := cursor.Get(key, value, op)
+	if err == nil && value != nil && !isDupSort && !bytes.Equal(v, value) {
+		// A plain table's key is deleted only with the value it holds
+		err = ErrNotFoundError
+	}
+	if err == nil {
+		anchors := txn.anchorSiblings(cursor, key, value)
+		err = cursor.Del(delFlags)
+		if anchors != nil {
+			txn.repositionSiblings(anchors)
+		}
+	}
+	if txn.logOps {
+		txn.env.opLog.start(opDel).uint(uint64(dbi)).bytes(key).bytes(value).end(err)
+	}
+	return err
+}
+
+// DelDupRange deletes the values of key in a DupSort database that fall in
+// [vlow, vhigh), in the database's value order, and returns how many it
+// deleted. A nil vlow starts at the first value and a nil vhigh runs to the
+// last. A key left without values is removed, and a sub-tree left small
+// enough to be stored inline becomes a sub-page again. Other databases fail
+// with ErrIncompatible.
+func (txn *Txn) DelDupRange(dbi DBI, key, vlow, vhigh []byte) (uint64, error) {
+	n, err := txn.delDupRange(dbi, key, vlow, vhigh)
+	if txn.logOps {
+		txn.env.opLog.start(opDelDupRange).uint(uint64(dbi)).bytes(key).bytes(vlow).bytes(vhigh).end(err)
+	}
+	return n, err
+}
+
+func (txn *Txn) delDupRange(dbi DBI, key, vlow, vhigh []byte) (uint64, error) {
+	if !txn.valid() {
+		return 0, NewError(ErrBadTxn)
+	}
+
+	if txn.IsReadOnly() {
+		return 0, NewError(ErrPermissionDenied)
+	}
+
+	cursor, err := txn.getCachedCursor(dbi)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if cursor.tree.Flags&uint16(DupSort) == 0 {
+		return 0, NewError(ErrIncompatible)
 	}
 
-	return cursor.Del(delFlags)
+	var k, v []byte
+	if vlow == nil {
+		k, v, err = cursor.Get(key, nil, Set)
+	} else {
+		k, v, err = cursor.Get(key, vlow, GetBothRange)
+	}
+	var deleted uint64
+	var last []byte
+	for err == nil {
+		if txn.compareKeys(dbi, k, key) != 0 || (vhigh != nil && txn.compareDupValues(dbi, v, vhigh) >= 0) {
+			break
+		}
+		last = append(last[:0], v...)
+		if err := cursor.Del(0); err != nil {
+			return deleted, err
+		}
+		deleted++
+
+		// A delete can leave the cursor before the value it removed, as when
+		// the key is left with one value; search past it again then
+		k, v, err = cursor.Get(nil, nil, Next)
+		if err == nil && txn.compareKeys(dbi, k, key) == 0 && txn.compareDupValues(dbi, v, last) <= 0 {
+			k, v, err = cursor.Get(key, last, GetBothRange)
+		}
+	}
+	if err != nil && !IsNotFound(err) {
+		return deleted, err
+	}
+	return deleted, nil
 }
 
 // OpenCursor opens a cursor on a database.
 func (txn *Txn) OpenCursor(dbi DBI) (*Cursor, error) {
+	cursor, err := txn.openCursor(dbi)
+	if err == nil {
+		cursor.safeValues = txn.env.safeValues.Load()
+	}
+	if err == nil && txn.logOps {
+		cursor.logID = txn.env.opLog.cursorID()
+		txn.env.opLog.start(opCursorOpen).uint(uint64(dbi)).uint(cursor.logID).end(nil)
+	}
+	return cursor, err
+}
+
+// openCursor opens a cursor for internal use; its operations are never logged.
+func (txn *Txn) openCursor(dbi DBI) (*Cursor, error) {
 	if !txn.valid() {
 		return nil, NewError(ErrBadTxn)
 	}
@@ -1375,6 +1924,11 @@ func (txn *Txn) OpenCursor(dbi DBI) (*Cursor, error) {
 	cursor.subcur = nil
 	cursor.next = nil
 	cursor.userCtx = nil
+	cursor.logID = 0
+	cursor.boundLow, cursor.boundHigh, cursor.bounded = nil, nil, false
+	cursor.safeValues = false
+	cursor.iterErr = nil
+	cursor.atEnd = false
 	cursor.dirtyMask = 0
 	// Reset ALL dup state to prevent corruption from cached cursors
 	cursor.dup.initialized = false
@@ -1387,6 +1941,7 @@ func (txn *Txn) OpenCursor(dbi DBI) (*Cursor, error) {
 	cursor.dup.dupfixSize = 0
 	cursor.dup.subPageData = nil
 	cursor.dup.nodePositions = nil
+	cursor.dup.edges[0].txnid, cursor.dup.edges[1].txnid = 0, 0
 	cursor.initMmapCache() // Initialize mmap cache for fast page access
 
 	// Add to transaction's cursor list
@@ -1409,6 +1964,8 @@ func returnCursor(c *Cursor) {
 		c.dup.subPages[i] = &c.dup.subPagesBuf[i]
 		c.dup.subIndices[i] = 0
 	}
+	c.logID = 0
+	c.boundLow, c.boundHigh, c.bounded = nil, nil, false
 	// Reset all dup state to prevent corruption when cursor is reused
 	c.dup.initialized = false
 	c.dup.isSubTree = false
@@ -1420,6 +1977,7 @@ func returnCursor(c *Cursor) {
 	c.dup.dupfixSize = 0
 	c.dup.subPageData = nil
 	c.dup.nodePositions = nil
+	c.dup.edges[0].txnid, c.dup.edges[1].txnid = 0, 0
 	c.dirtyMask = 0
 
 	// Return to global cache
@@ -1484,6 +2042,9 @@ func (txn *Txn) getPage(pg pgno) (*page, error) {
 	if err != nil {
 		return nil, err
 	}
+	if txn.flags&uint32(TxnReadOnly) == 0 {
+		txn.counters.PagesRead++
+	}
 
 	// Create Page struct using pool
 	p := getPooledPageStruct(data)
@@ -1567,6 +2128,7 @@ func (txn *Txn) fillPageHotPath(pgno pgno, buf *page) *page {
 		return p
 	}
 	// Not dirty, fill from mmap
+	txn.counters.PagesRead++
 	buf.Data = txn.getPageDataFast(pgno)
 	return buf
 }
@@ -1653,6 +2215,21 @@ func (txn *Txn) cacheComparator(dbi DBI) {
 	txn.env.dbisMu.RUnlock()
 }
 
+// cmpReverse compares byte strings from their last byte backwards, the
+// REVERSEKEY/REVERSEDUP order of libmdbx. When one string is a suffix of the
+// other, the shorter one sorts first.
+func cmpReverse(a, b []byte) int {
+	for i, j := len(a)-1, len(b)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if a[i] != b[j] {
+			if a[i] < b[j] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
 // compareDupValues compares two values using the database's dup comparator.
 // For DUPSORT databases, this uses the custom dup comparator if set, otherwise bytes.Compare.
 func (txn *Txn) compareDupValues(dbi DBI, a, b []byte) int {
@@ -1699,6 +2276,11 @@ func (txn *Txn) initDupComparator(dbi DBI) {
 	}
 	txn.env.dbisMu.RUnlock()
 
+	// ReverseDup tables without a custom comparator order values from the end
+	if dcmp == nil && int(dbi) < len(txn.trees) && txn.trees[dbi].Flags&treeFlagReverseDup != 0 {
+		dcmp = cmpReverse
+	}
+
 	if dcmp != nil {
 		txn.dbiDupComparators[dbi] = dcmp
 		txn.dbiUsesDefaultDupCmp[dbi] = false
@@ -1708,6 +2290,71 @@ func (txn *Txn) initDupComparator(dbi DBI) {
 	}
 }
 
+// allocPages takes n consecutive page numbers from the end of the file. It
+// fails with ErrMapFull if the file would grow past the map's upper limit.
+func (txn *Txn) allocPages(n int) (pgno, error) {
+	if err := txn.checkRoom(n); err != nil {
+		return 0, err
+	}
+	first := txn.allocatedPg
+	txn.allocatedPg += pgno(n)
+	return first, nil
+}
+
+// allocPage takes a page number for one new page. It prefers a page that
+// this transaction allocated and then freed, and returns that page's buffer
+// so it is reused along with the number; data is nil for a page from the end
+// of the file.
+func (txn *Txn) allocPage() (pg pgno, data []byte, err error) {
+	if n := len(txn.freePages); n > 0 {
+		pg = txn.freePages[n-1]
+		txn.freePages = txn.freePages[:n-1]
+		if p := txn.dirtyTracker.get(pg); p != nil {
+			data = p.Data
+		}
+		return pg, data, nil
+	}
+	pg, err = txn.allocPages(1)
+	return pg, nil, err
+}
+
+// freePage releases a page removed from a tree. Only pages allocated by this
+// transaction are kept for reuse: an older page is still part of the last
+// committed snapshot, and readers of that snapshot may read it until it is
+// reclaimed, so overwriting it in this transaction is not safe. With
+// AllocSequential no page is kept.
+func (txn *Txn) freePage(pg pgno) {
+	if pg >= txn.firstNewPg && !txn.allocSequential {
+		txn.freePages = append(txn.freePages, pg)
+	}
+}
+
+// checkRoom fails with ErrMapFull unless n more pages can be allocated.
+func (txn *Txn) checkRoom(n int) error {
+	if limit := txn.env.pageLimit(); limit > 0 && uint64(txn.allocatedPg)+uint64(n) > limit {
+		return NewError(ErrMapFull)
+	}
+	return nil
+}
+
+// beginWrite refuses a write on a broken transaction, and one that might run
+// out of pages part-way: reserve is the most pages the write can allocate.
+func (txn *Txn) beginWrite(reserve int) error {
+	if txn.broken {
+		return NewError(ErrBadTxn)
+	}
+	return txn.checkRoom(reserve)
+}
+
+// endWrite marks the transaction broken if a write that passed beginWrite
+// still ran out of pages, or split a tree already at its maximum depth, as
+// it may have left a tree half-modified.
+func (txn *Txn) endWrite(err error) {
+	if IsMapFull(err) || errors.Is(err, errTreeTooDeep) {
+		txn.broken = true
+	}
+}
+
 // writeDirtyPages writes all dirty pages to the data file.
 func (txn *Txn) writeDirtyPages() error {
 	if txn.dirtyTracker.len() == 0 {
@@ -1719,14 +2366,13 @@ func (txn *Txn) writeDirtyPages() error {
 	// Calculate required file size
 	requiredSize := int64(txn.allocatedPg) * pageSize
 
-	// Align to system page size for mdbx read-only compatibility
-	requiredSize = alignToSysPageSize(requiredSize)
-
 	// Get current mmap size (avoids syscall)
 	currentSize := txn.env.dataMap.Size()
 
-	// Extend file if needed
-	if requiredSize > currentSize {
+	// Extend file if needed, by the growth step if there is one
+	if alignToSysPageSize(requiredSize) > currentSize {
+		requiredSize = txn.env.growSize(requiredSize, txn.txnID, 0)
+		txn.env.mapGrowths.Add(1)
 		if err := txn.env.dataFile.Truncate(requiredSize); err != nil {
 			return WrapError(ErrProblem, err)
 		}
@@ -1740,6 +2386,7 @@ func (txn *Txn) writeDirtyPages() error {
 		if err != nil {
 			return WrapError(ErrProblem, err)
 		}
+		txn.env.adviseReadahead(dm)
 
 		// Hold write lock while updating dataMap to prevent readers from racing
 		txn.env.mu.Lock()
@@ -1804,17 +2451,25 @@ func (txn *Txn) writeDirtyPages() error {
 	return writeErr
 }
 
-// updateMeta writes a new meta page.
-func (txn *Txn) updateMeta() error {
+// willSync reports whether the commit syncs the data file.
+func (txn *Txn) willSync() bool {
+	noSync := txn.flags&uint32(TxnNoSync) != 0 || txn.env.flags&SafeNoSync != 0
+	noMetaSync := txn.env.flags&NoMetaSync != 0
+	return !noSync && !noMetaSync
+}
+
+// updateMeta writes a new meta page. With deferSync it leaves the sync to
+// the caller.
+func (txn *Txn) updateMeta(deferSync bool) error {
+	// Determine if we will sync - this affects the meta signature. A commit
+	// whose sync is deferred to the group stays weak until the group syncs.
+	willSync := txn.willSync()
+	steady := willSync && !deferSync
+
 	// Get next meta page index
-	metaIdx := txn.env.meta.Load().nextMetaIndex()
+	metaIdx := txn.env.meta.Load().nextMetaIndex(!steady)
 	pageSize := txn.env.pageSize
 
-	// Determine if we will sync - this affects the meta signature
-	noSync := txn.flags&uint32(TxnNoSync) != 0
-	noMetaSync := txn.env.flags&NoMetaSync != 0
-	willSync := !noSync && !noMetaSync
-
 	// WriteMap fast path: write directly to mmap (avoids WriteAt syscalls)
 	useWriteMap := txn.env.flags&WriteMap != 0 && txn.env.dataMap != nil
 	if useWriteMap {
@@ -1823,6 +2478,15 @@ func (txn *Txn) updateMeta() error {
 		end := offset + int(pageSize)
 
 		if end <= len(mmapData) {
+			// The pages reach the disk before the meta pointing at them
+			ranged := false
+			if steady {
+				var err error
+				if ranged, err = txn.syncWriteMapPages(); err != nil {
+					return WrapError(ErrProblem, err)
+				}
+			}
+
 			// Write directly to mmap
 			metaPage := mmapData[offset:end]
 
@@ -1851,8 +2515,9 @@ func (txn *Txn) updateMeta() error {
 			alignedPgCount := pgno(alignedFileSize / int64(pageSize))
 			meta.Geometry.Now = alignedPgCount
 			meta.Geometry.Next = alignedPgCount
+			meta.BootID = bootID()
 
-			if willSync {
+			if steady {
 				meta.setSignSteady()
 			} else {
 				meta.setSignWeak()
@@ -1862,12 +2527,21 @@ func (txn *Txn) updateMeta() error {
 			meta.beginMetaUpdate(txn.txnID)
 			meta.endMetaUpdate(txn.txnID)
 
-			// Sync if needed
-			if willSync {
-				if err := txn.env.dataFile.Sync(); err != nil {
+			// Then the meta, or the whole file if the pages couldn't go by range
+			if steady {
+				var err error
+				if ranged {
+					err = txn.env.syncWriteMapMeta(offset)
+				} else {
+					err = txn.env.syncDataFile()
+				}
+				if err != nil {
 					return WrapError(ErrProblem, err)
 				}
 			}
+			if err := txn.verifyMeta(metaIdx, metaPage); err != nil {
+				return err
+			}
 
 			// Update environment's meta from mmap
 			txn.env.mu.Lock()
@@ -1917,19 +2591,27 @@ func (txn *Txn) updateMeta() error {
 	alignedPgCount := pgno(alignedFileSize / int64(pageSize))
 	meta.Geometry.Now = alignedPgCount
 	meta.Geometry.Next = alignedPgCount
+	meta.BootID = bootID()
 
-	if willSync {
+	if steady {
 		meta.setSignSteady()
 	} else {
 		meta.setSignWeak()
 	}
 
+	// With a redo log the commit is durable once logged
+	if txn.env.redo != nil {
+		if err := txn.appendRedo(metaIdx, metaPage, willSync); err != nil {
+			return err
+		}
+	}
+
 	// Two-phase update: set txnid_b to 0 first
 	meta.beginMetaUpdate(txn.txnID)
 
 	// Write meta page
 	offset := int64(metaIdx) * int64(pageSize)
-	if _, err := txn.env.dataFile.WriteAt(metaPage, offset); err != nil {
+	if err := txn.env.writeMetaPage(metaPage, offset); err != nil {
 		return WrapError(ErrProblem, err)
 	}
 
@@ -1937,16 +2619,19 @@ func (txn *Txn) updateMeta() error {
 	meta.endMetaUpdate(txn.txnID)
 
 	// Write meta page again with updated txnid_b
-	if _, err := txn.env.dataFile.WriteAt(metaPage, offset); err != nil {
+	if err := txn.env.writeMetaPage(metaPage, offset); err != nil {
 		return WrapError(ErrProblem, err)
 	}
 
 	// Sync if needed
-	if willSync {
-		if err := txn.env.dataFile.Sync(); err != nil {
+	if willSync && !deferSync && txn.env.redo == nil {
+		if err := txn.env.syncDataFile(); err != nil {
 			return WrapError(ErrProblem, err)
 		}
 	}
+	if err := txn.verifyMeta(metaIdx, metaPage); err != nil {
+		return err
+	}
 
 	// Update environment's meta from the current mmap
 	txn.env.mu.Lock()
@@ -1996,7 +2681,7 @@ func (txn *Txn) Stat(dbi DBI) (*Stat, error) {
 // Stat holds database statistics.
 type Stat struct {
 	PageSize      uint32 // Page size in bytes
-	Depth         uint32 // Tree depth
+	Depth         uint32 // Tree depth, at most CursorStackSize
 	BranchPages   uint64 // Number of branch pages
 	LeafPages     uint64 // Number of leaf pages
 	LargePages    uint64 // Number of overflow pages
@@ -2006,6 +2691,42 @@ type Stat struct {
 	ModTxnID      uint64 // Last modification transaction ID
 }
 
+// TreeInfo is a copy of a database's tree header as seen by a transaction.
+// Changing it has no effect on the database.
+type TreeInfo struct {
+	Root        uint32 // Root page number (0xFFFFFFFF for an empty tree)
+	Height      uint16 // Number of tree levels (0 for an empty tree)
+	Flags       uint   // Database flags (DupSort, ReverseKey, ...)
+	Items       uint64 // Number of entries
+	BranchPages uint64 // Number of branch pages
+	LeafPages   uint64 // Number of leaf pages
+	LargePages  uint64 // Number of overflow pages
+	Sequence    uint64 // Sequence counter (see Sequence)
+}
+
+// TreeInfo returns a snapshot of the tree header of a database.
+func (txn *Txn) TreeInfo(dbi DBI) (TreeInfo, error) {
+	if !txn.valid() {
+		return TreeInfo{}, NewError(ErrBadTxn)
+	}
+
+	if int(dbi) >= len(txn.trees) {
+		return TreeInfo{}, NewError(ErrBadDBI)
+	}
+
+	t := &txn.trees[dbi]
+	return TreeInfo{
+		Root:        uint32(t.Root),
+		Height:      t.Height,
+		Flags:       uint(t.Flags),
+		Items:       t.Items,
+		BranchPages: uint64(t.BranchPages),
+		LeafPages:   uint64(t.LeafPages),
+		LargePages:  uint64(t.LargePages),
+		Sequence:    t.Sequence,
+	}, nil
+}
+
 // Cmp compares two keys using the database's comparator.
 func (txn *Txn) Cmp(dbi DBI, a, b []byte) int {
 	txn.cacheComparator(dbi)
@@ -2026,7 +2747,7 @@ func (txn *Txn) ListDBI() ([]string, error) {
 		return nil, NewError(ErrBadTxn)
 	}
 
-	cursor, err := txn.OpenCursor(MainDBI)
+	cursor, err := txn.openCursor(MainDBI)
 	if err != nil {
 		return nil, err
 	}
@@ -2093,6 +2814,9 @@ func (txn *Txn) RunOp(fn TxnOp, terminate bool) error {
 
 // PutReserve reserves space for a value and returns a slice to write into.
 func (txn *Txn) PutReserve(dbi DBI, key []byte, n int, flags uint) ([]byte, error) {
+	if err := checkDataSize(n); err != nil {
+		return nil, err
+	}
 	// For now, just do a regular put with a zero-filled slice
 	value := make([]byte, n)
 	err := txn.Put(dbi, key, value, flags)
@@ -2104,6 +2828,18 @@ func (txn *Txn) PutReserve(dbi DBI, key []byte, n int, flags uint) ([]byte, erro
 	return value, nil
 }
 
+// checkDataSize refuses a value size beyond MaxDataSize, or negative, before
+// anything is allocated for it.
+func checkDataSize(n int) error {
+	if n < 0 {
+		return NewError(ErrInvalid)
+	}
+	if n > MaxDataSize {
+		return newSizeError(ErrBadValSize, n, MaxDataSize)
+	}
+	return nil
+}
+
 // ReleaseAllCursors closes all cursors in the transaction.
 func (txn *Txn) ReleaseAllCursors(unbind bool) error {
 	for _, c := range txn.cursors {
@@ -2126,6 +2862,7 @@ func (txn *Txn) Park(autounpark bool) error {
 	if txn.readerSlot != nil {
 		txn.env.lockFile.releaseReaderSlot(txn.readerSlot, txn.slotIdx)
 		txn.readerSlot = nil
+		txn.syncGuard()
 	}
 	return nil
 }
@@ -2143,6 +2880,7 @@ func (txn *Txn) Unpark(restartIfOusted bool) error {
 		}
 		txn.readerSlot = slot
 		txn.slotIdx = idx
+		txn.syncGuard()
 		txn.env.lockFile.setReaderTxnid(slot, uint64(txn.txnID))
 	}
 	return nil
@@ -2168,6 +2906,25 @@ type TxInfo struct {
 	Unspill        uint64 // Pages unspilled from disk
 }
 
+// TxnCounters counts the page work done by a write transaction, to attribute
+// cost to individual transactions. Read-only transactions don't count.
+type TxnCounters struct {
+	PagesRead     uint64 // Clean pages read from the map
+	PagesCOW      uint64 // Pages copied on write
+	Splits        uint64 // Page splits
+	OverflowPages uint64 // Overflow pages allocated for large values
+}
+
+// Counters returns the counters accumulated by the transaction so far. Call it
+// just before Commit or Abort for the transaction's totals; the transaction
+// can't be used afterwards.
+func (txn *Txn) Counters() TxnCounters {
+	if !txn.valid() {
+		return TxnCounters{}
+	}
+	return txn.counters
+}
+
 // Info returns information about the transaction.
 func (txn *Txn) Info(scanRlt bool) (*TxInfo, error) {
 	if !txn.valid() {
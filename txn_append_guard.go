@@ -0,0 +1,70 @@
+package gdbx
+
+import "bytes"
+
+// appendGuard is the state of SetAppendGuard for one DBI.
+type appendGuard struct {
+	on   bool
+	seen bool   // A key has been put since the guard was set
+	last []byte // The last key put
+}
+
+// SetAppendGuard makes every later put to dbi in the transaction, by Put,
+// a cursor or GetWriter, fail with ErrKeyMismatch wrapping an
+// *AppendGuardError unless its key sorts after the last key put to dbi
+// since the call. It checks what the Append flag would, without relying on
+// each caller to pass it, so an append-only writer that puts a key out of
+// order fails at that put. On DUPSORT tables the key may also equal the
+// last, to add values to it.
+//
+// The first put after the call may have any key: the guard compares keys
+// with each other, in the table's order, not with the keys the table
+// already holds. It lasts until the transaction ends.
+func (txn *Txn) SetAppendGuard(dbi DBI) (err error) {
+	if !txn.valid() {
+		return NewError(ErrBadTxn)
+	}
+	if txn.logOps {
+		defer func() { txn.env.opLog.start(opSetAppendGuard).uint(uint64(dbi)).end(err) }()
+	}
+	if txn.IsReadOnly() {
+		return NewError(ErrPermissionDenied)
+	}
+	if dbi == FreeDBI || int(dbi) >= len(txn.trees) {
+		return NewError(ErrBadDBI)
+	}
+	if len(txn.appendGuards) < len(txn.trees) {
+		txn.appendGuards = append(txn.appendGuards, make([]appendGuard, len(txn.trees)-len(txn.appendGuards))...)
+	}
+	g := &txn.appendGuards[dbi]
+	g.on, g.seen, g.last = true, false, g.last[:0]
+	return nil
+}
+
+// checkAppendGuard returns the error a put of key to dbi gets from its
+// append guard, or nil.
+func (txn *Txn) checkAppendGuard(dbi DBI, key []byte) error {
+	if int(dbi) >= len(txn.appendGuards) {
+		return nil
+	}
+	g := &txn.appendGuards[dbi]
+	if !g.on || !g.seen {
+		return nil
+	}
+	txn.cacheComparator(dbi)
+	cmp := txn.compareKeys(dbi, key, g.last)
+	if cmp > 0 || cmp == 0 && txn.trees[dbi].Flags&uint16(DupSort) != 0 {
+		return nil
+	}
+	return WrapError(ErrKeyMismatch, &AppendGuardError{Last: bytes.Clone(g.last)})
+}
+
+// advanceAppendGuard records key as the last key put to dbi.
+func (txn *Txn) advanceAppendGuard(dbi DBI, key []byte) {
+	if int(dbi) >= len(txn.appendGuards) || !txn.appendGuards[dbi].on {
+		return
+	}
+	g := &txn.appendGuards[dbi]
+	g.last = append(g.last[:0], key...)
+	g.seen = true
+}
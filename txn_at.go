@@ -0,0 +1,119 @@
+package gdbx
+
+// BeginTxnAt starts a read-only transaction on the snapshot committed as
+// txnid, which may be up to two commits older than the newest; EnvInfo's
+// Meta0Txnid to Meta2Txnid list the snapshots on offer. It fails with
+// ErrNotFound if no meta page names txnid any longer, or if the snapshot's
+// root pages were reclaimed, which only a libmdbx writer does, and only
+// while no reader pins the snapshot. The transaction is used like any read
+// transaction, and Renew moves it to the newest snapshot.
+func (e *Env) BeginTxnAt(id uint64) (*Txn, error) {
+	if !e.valid() {
+		return nil, NewError(ErrInvalid)
+	}
+	if e.flags&ReadOnly != 0 {
+		if err := e.followWriter(); err != nil {
+			return nil, err
+		}
+	}
+	txn, err := e.openReadTxnAt(txnid(id))
+	if err != nil {
+		return nil, err
+	}
+	if err := txn.loadNamedTrees(); err != nil {
+		txn.Abort()
+		return nil, err
+	}
+	return txn, nil
+}
+
+// openReadTxnAt starts a read-only transaction on the snapshot of the meta
+// holding txnid id.
+func (e *Env) openReadTxnAt(id txnid) (*Txn, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.dataMap == nil {
+		return nil, NewError(ErrInvalid)
+	}
+	slot, slotIdx, err := e.lockFile.acquireReaderSlot(cachedPID, 0)
+	if err != nil {
+		return nil, WrapError(ErrReadersFull, err)
+	}
+
+	// The next commit may overwrite the meta, so keep a copy of it
+	var m meta
+	found := false
+	if mt := e.meta.Load(); mt != nil {
+		for i, t := range mt.txnids {
+			if t == id && id != 0 && mt.metas[i] != nil {
+				m, found = *mt.metas[i], true
+				break
+			}
+		}
+	}
+	if !found {
+		e.lockFile.releaseReaderSlot(slot, slotIdx)
+		return nil, ErrNotFoundError
+	}
+	txn := e.initReadTxn(slot, slotIdx, &m)
+	if !txn.rootIntact(m.GCTree.Root) || !txn.rootIntact(m.MainTree.Root) {
+		txn.Abort()
+		return nil, ErrNotFoundError
+	}
+	return txn, nil
+}
+
+// loadNamedTrees replaces the trees of the opened named databases, which
+// initReadTxn takes from the Env, with those of txn's snapshot.
+func (txn *Txn) loadNamedTrees() error {
+	e := txn.env
+	var opened []*dbiInfo
+	var dbis []int
+	e.dbisMu.RLock()
+	for i := CoreDBs; i < len(e.dbis) && i < len(txn.trees); i++ {
+		if e.dbis[i] != nil {
+			opened = append(opened, e.dbis[i])
+			dbis = append(dbis, i)
+		}
+	}
+	e.dbisMu.RUnlock()
+	if len(opened) == 0 {
+		return nil
+	}
+
+	cursor, err := txn.openCursor(MainDBI)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for j, info := range opened {
+		t, err := readNamedTree(cursor, info)
+		if err != nil {
+			return err
+		}
+		if !txn.rootIntact(t.Root) {
+			return ErrNotFoundError
+		}
+		txn.trees[dbis[j]] = *t
+	}
+	return nil
+}
+
+// rootIntact reports whether the root page pn of a tree in txn's snapshot
+// is still the page the snapshot wrote: a page that carries its own number
+// and a txnid no newer than the snapshot.
+func (txn *Txn) rootIntact(pn pgno) bool {
+	if pn == invalidPgno {
+		return true
+	}
+	if pn < NumMetas || uint64(pn+1)*uint64(txn.pageSize) > uint64(len(txn.mmapData)) {
+		return false
+	}
+	p, err := txn.getPage(pn)
+	if err != nil {
+		return false
+	}
+	h := p.header()
+	return h.PageNo == pn && h.Txnid <= txn.txnID && (p.isBranchFast() || p.isLeafFast())
+}
@@ -0,0 +1,50 @@
+package gdbx
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// readGuard ends a read transaction dropped without Abort, which would
+// otherwise hold its reader slot and keep Env.Close waiting, once a GC finds
+// the Txn unreachable, and logs the leak. The Txn keeps it current with
+// syncGuard. It must never point back at the Txn: the finalizer sits on the
+// guard because the Txn's cursors reach the Txn, and the runtime never
+// finalizes an object that can reach itself.
+type readGuard struct {
+	env     *Env        // nil once the transaction has ended
+	slot    *readerSlot // nil while reset or parked
+	slotIdx int
+	txnID   txnid
+}
+
+// syncGuard copies the transaction's reader state to its guard, allocating
+// the guard on first use. A nil env disarms it.
+func (txn *Txn) syncGuard() {
+	g := txn.guard
+	if g == nil {
+		g = &readGuard{}
+		runtime.SetFinalizer(g, (*readGuard).release)
+		txn.guard = g
+	}
+	g.env, g.slot, g.slotIdx, g.txnID = txn.env, txn.readerSlot, txn.slotIdx, txn.txnID
+}
+
+// release ends a read transaction that was garbage collected without Abort.
+func (g *readGuard) release() {
+	env := g.env
+	if env == nil {
+		return
+	}
+	g.env = nil
+	if g.slot != nil {
+		env.lockFile.releaseReaderSlot(g.slot, g.slotIdx)
+		g.slot = nil
+	}
+	env.tryCleanupOldMmaps()
+	if globalLogger != nil && (globalLogLevel == LogLvlDoNotChange || globalLogLevel >= LogLvlWarn) {
+		globalLogger(fmt.Sprintf("gdbx: read transaction %d was garbage collected without Abort; released its reader slot", g.txnID))
+	}
+	// Last: Close may be waiting on it to unmap the file
+	env.txnWg.Done()
+}
@@ -0,0 +1,27 @@
+package gdbx
+
+// SplitFunc is called by a write transaction set up with OnSplit each time
+// a page of dbi splits: the entries from sepKey on moved from page leftPgno
+// to the new page rightPgno, and sepKey is about to be put in their parent.
+// sepKey points into the page and is valid only during the call. The
+// function must not use the transaction.
+type SplitFunc func(dbi DBI, leftPgno, rightPgno uint32, sepKey []byte)
+
+// OnSplit sets fn to be called on every page split of the transaction's
+// tables, leaf and branch alike, so a caller keeping page-level metadata of
+// its own, such as page ranges for parallel scans, can follow the tree as it
+// grows. A split of the GC table or the table of named databases reports
+// FreeDBI or MainDBI; the pages of a DupSort key's sub-tree are not reported.
+// A nil fn removes the hook, which costs a put nothing but a check while
+// unset. It lasts until the transaction ends, and nested transactions begin
+// with their parent's.
+func (txn *Txn) OnSplit(fn SplitFunc) error {
+	if !txn.valid() {
+		return NewError(ErrBadTxn)
+	}
+	if txn.IsReadOnly() {
+		return NewError(ErrPermissionDenied)
+	}
+	txn.onSplit = fn
+	return nil
+}
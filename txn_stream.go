@@ -0,0 +1,241 @@
+package gdbx
+
+import "io"
+
+// GetReader returns a reader over the value of key and the value's size.
+// Values on overflow pages are read page by page, never as a single slice,
+// from the mmap or from the transaction's dirty pages in a write
+// transaction; smaller values are read from the leaf page. For DUPSORT
+// tables the reader returns the first value, like Get.
+//
+// The reader is only valid during the transaction and until the key is
+// modified; reads after the transaction ends fail with ErrBadTxn.
+func (txn *Txn) GetReader(dbi DBI, key []byte) (io.Reader, int64, error) {
+	if !txn.valid() {
+		return nil, 0, NewError(ErrBadTxn)
+	}
+	if dbi == FreeDBI || int(dbi) >= len(txn.trees) {
+		return nil, 0, NewError(ErrBadDBI)
+	}
+
+	c, err := txn.openCursor(dbi)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer c.Close()
+
+	c.reset()
+	if exact, err := c.searchForInsert(key); err != nil || !exact {
+		return nil, 0, ErrNotFoundError
+	}
+
+	r := &valueReader{stream: valueStream{txn: txn, id: txn.txnID, first: invalidPgno}}
+	p := c.pages[c.top]
+	idx := int(c.indices[c.top])
+	if nodeGetFlagsDirect(p, idx)&nodeBig != 0 {
+		r.stream.first = nodeGetOverflowPgnoDirect(p, idx)
+		r.stream.size = int64(nodeGetDataSizeDirect(p, idx))
+		return r, r.stream.size, nil
+	}
+
+	_, v, err := c.getCurrent()
+	if err != nil {
+		return nil, 0, err
+	}
+	r.inline = v
+	r.stream.size = int64(len(v))
+	return r, r.stream.size, nil
+}
+
+// GetWriter stores a value of size bytes under key and returns a writer
+// that fills it in. Values above the inline limit get their overflow pages
+// reserved up front and the writer copies into them page by page; smaller
+// values are assembled in memory and stored once complete. Until all size
+// bytes are written the unwritten part of the value reads as zeros. Writing
+// more than size bytes fails with ErrBadValSize.
+//
+// flags are the Put flags for the reservation. DUPSORT tables are not
+// supported. The writer is only valid during the transaction and until the
+// key is modified again. The operation log records the reservation but not
+// the bytes written through the writer.
+func (txn *Txn) GetWriter(dbi DBI, key []byte, size int64, flags uint) (io.Writer, error) {
+	if !txn.valid() {
+		return nil, NewError(ErrBadTxn)
+	}
+	if txn.IsReadOnly() {
+		return nil, NewError(ErrPermissionDenied)
+	}
+	if size < 0 || size > MaxDataSize {
+		return nil, newSizeError(ErrBadValSize, int(size), MaxDataSize)
+	}
+
+	c, err := txn.getCachedCursor(dbi)
+	if err != nil {
+		return nil, err
+	}
+	if c.tree.Flags&uint16(DupSort) != 0 {
+		return nil, NewError(ErrIncompatible)
+	}
+	if limit := txn.env.valueLimit(dbi); limit > 0 && size > int64(limit) {
+		return nil, newSizeError(ErrBadValSize, int(size), limit)
+	}
+
+	// Before the existing value is removed
+	if err := txn.checkAppendGuard(dbi, key); err != nil {
+		return nil, err
+	}
+
+	// Remove an existing value first, so the reservation below gets fresh
+	// overflow pages instead of updating the old ones in place
+	c.reset()
+	exact, err := c.searchForInsert(key)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	if exact {
+		if flags&(NoOverwrite|NoOverwriteKey) != 0 {
+			return nil, NewError(ErrKeyExist)
+		}
+		if err := c.Del(0); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &valueWriter{stream: valueStream{txn: txn, id: txn.txnID, first: invalidPgno, size: size}, dbi: dbi}
+	if size <= int64(txn.env.MaxValSize()) {
+		w.inline = make([]byte, size)
+		w.key = append([]byte(nil), key...)
+		if err := txn.putWithCap(dbi, key, w.inline, 0, flags); err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+
+	// Reserve the overflow run with an empty value, then give the node the
+	// full size so the reserved (zeroed) pages become the value
+	if err := txn.putWithCap(dbi, key, nil, int(size), flags); err != nil {
+		return nil, err
+	}
+	c.reset()
+	if exact, err := c.searchForInsert(key); err != nil || !exact {
+		return nil, ErrCorruptedError
+	}
+	p := c.pages[c.top]
+	idx := int(c.indices[c.top])
+	if nodeGetFlagsDirect(p, idx)&nodeBig == 0 {
+		return nil, ErrCorruptedError
+	}
+	w.stream.first = nodeGetOverflowPgnoDirect(p, idx)
+	if err := c.updateBigNodeSize(uint32(size)); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// valueStream tracks a position within a value on an overflow run.
+type valueStream struct {
+	txn   *Txn
+	id    txnid // Transaction ID, to detect use after the txn ended
+	first pgno  // First overflow page, or invalidPgno for inline values
+	size  int64
+	off   int64
+}
+
+// check returns ErrBadTxn if the transaction has ended.
+func (s *valueStream) check() error {
+	if !s.txn.valid() || s.txn.txnID != s.id {
+		return NewError(ErrBadTxn)
+	}
+	return nil
+}
+
+// chunk returns the page holding the byte at the current offset and the
+// position of that byte within it.
+func (s *valueStream) chunk() (pg pgno, at int) {
+	pageSize := int64(s.txn.env.pageSize)
+	pos := s.off + pageHeaderSize
+	return s.first + pgno(pos/pageSize), int(pos % pageSize)
+}
+
+// valueReader implements GetReader.
+type valueReader struct {
+	stream valueStream
+	inline []byte
+}
+
+func (r *valueReader) Read(b []byte) (int, error) {
+	s := &r.stream
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	if s.off >= s.size {
+		return 0, io.EOF
+	}
+
+	if s.first == invalidPgno {
+		n := copy(b, r.inline[s.off:])
+		s.off += int64(n)
+		return n, nil
+	}
+
+	var n int
+	for n < len(b) && s.off < s.size {
+		pg, at := s.chunk()
+		var data []byte
+		if !s.txn.IsReadOnly() {
+			if p := s.txn.dirtyTracker.get(pg); p != nil {
+				data = p.Data
+			}
+		}
+		if data == nil {
+			if data = s.txn.getPageDataFast(pg); data == nil {
+				return n, ErrPageNotFoundError
+			}
+		}
+		m := copy(b[n:], data[at:min(len(data), at+int(s.size-s.off))])
+		n += m
+		s.off += int64(m)
+	}
+	return n, nil
+}
+
+// valueWriter implements GetWriter.
+type valueWriter struct {
+	stream valueStream
+	dbi    DBI
+	key    []byte
+	inline []byte // Value being assembled, for values stored inline
+}
+
+func (w *valueWriter) Write(b []byte) (int, error) {
+	s := &w.stream
+	if err := s.check(); err != nil {
+		return 0, err
+	}
+	if int64(len(b)) > s.size-s.off {
+		return 0, newSizeError(ErrBadValSize, int(s.off)+len(b), int(s.size))
+	}
+
+	if s.first == invalidPgno {
+		s.off += int64(copy(w.inline[s.off:], b))
+		if s.off == s.size {
+			return len(b), s.txn.putWithCap(w.dbi, w.key, w.inline, 0, 0)
+		}
+		return len(b), nil
+	}
+
+	var n int
+	for n < len(b) {
+		pg, at := s.chunk()
+		// The reserved pages were allocated by this transaction, so they
+		// are dirty unless the key has been modified since
+		p := s.txn.dirtyTracker.get(pg)
+		if p == nil {
+			return n, NewError(ErrBadTxn)
+		}
+		m := copy(p.Data[at:], b[n:])
+		n += m
+		s.off += int64(m)
+	}
+	return n, nil
+}